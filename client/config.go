@@ -24,6 +24,7 @@ type CliConfig struct {
 	URL            string
 	OneShot        bool
 	Removable      bool
+	StripMetadata  bool
 	Stream         bool
 	Secure         bool
 	SecureMethod   string
@@ -31,6 +32,7 @@ type CliConfig struct {
 	Archive        bool
 	ArchiveMethod  string
 	ArchiveOptions map[string]interface{}
+	Recursive      bool
 	DownloadBinary string
 	Comments       string
 	Login          string
@@ -211,6 +213,11 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 		return fmt.Errorf("No files specified")
 	}
 
+	// Upload directories recursively instead of archiving them ?
+	if opts["--recursive"].(bool) {
+		config.Recursive = true
+	}
+
 	for _, path := range config.filePaths {
 		// Test if file exists
 		fileInfo, err := os.Stat(path)
@@ -219,7 +226,7 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 		}
 
 		// Automatically enable archive mode is at least one file is a directory
-		if fileInfo.IsDir() {
+		if fileInfo.IsDir() && !config.Recursive {
 			config.Archive = true
 		}
 	}
@@ -236,6 +243,9 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 	if opts["--removable"].(bool) {
 		config.Removable = true
 	}
+	if opts["--strip-metadata"].(bool) {
+		config.StripMetadata = true
+	}
 
 	if opts["--stream"].(bool) {
 		config.Stream = true
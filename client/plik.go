@@ -44,6 +44,7 @@ Options:
   -o, --oneshot             Enable OneShot ( Each file will be deleted on first download )
   -r, --removable           Enable Removable upload ( Each file can be deleted by anyone at anymoment )
   -S, --stream              Enable Streaming ( It will block until remote user starts downloading )
+  --strip-metadata          Remove EXIF, XMP and IPTC metadata ( GPS location, device, ... ) from JPEG, PNG and WebP images
   -t, --ttl TTL             Time before expiration (Upload will be removed in m|h|d)
   -n, --name NAME           Set file name when piping from STDIN
   --stdin                   Enable pipe from stdin explicitly when DisableStdin is set in .plikrc
@@ -56,6 +57,7 @@ Options:
   --archive MODE            Archive upload using the specified archive backend : tar|zip
   --compress MODE           [tar] Compression codec : gzip|bzip2|xz|lzip|lzma|lzop|compress|no
   --archive-options OPTIONS [tar|zip] Additional command line options
+  -R, --recursive           Upload directories recursively keeping their structure instead of archiving them
   -s                        Encrypt upload using the default encryption parameters ( see ~/.plikrc )
   --not-secure              Do not encrypt upload files regardless of the ~/.plikrc configurations
   --secure MODE             Encrypt upload files using the specified crypto backend : openssl|pgp
@@ -151,6 +153,7 @@ Options:
 	upload.Stream = config.Stream
 	upload.OneShot = config.OneShot
 	upload.Removable = config.Removable
+	upload.StripMetadata = config.StripMetadata
 	upload.Comments = config.Comments
 	upload.Login = config.Login
 	upload.Password = config.Password
@@ -185,6 +188,15 @@ Options:
 			upload.AddFileFromReader(filename, reader)
 		} else {
 			for _, path := range config.filePaths {
+				if fileInfo, err := os.Stat(path); err == nil && fileInfo.IsDir() {
+					_, err = upload.AddDirectoryFromPath(path)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "%s : %s\n", path, err)
+						os.Exit(1)
+					}
+					continue
+				}
+
 				_, err := upload.AddFileFromPath(path)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "%s : %s\n", path, err)
@@ -364,6 +376,9 @@ func getFileCommand(file *plik.File) (command string, err error) {
 		} else {
 			command += fmt.Sprintf(" | %s", archiveBackend.Comments())
 		}
+	} else if file.Path != "" {
+		// Recreate the directory structure of recursive uploads
+		command = fmt.Sprintf(`mkdir -p '%s' && %s > '%s/%s'`, file.Path, command, file.Path, file.Name)
 	} else {
 		command += fmt.Sprintf(` > '%s'`, file.Name)
 	}
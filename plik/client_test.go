@@ -1,10 +1,13 @@
 package plik
 
 import (
+	"archive/tar"
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -237,6 +240,90 @@ func TestUploadFiles(t *testing.T) {
 	}
 }
 
+func createTestDirectory(t *testing.T) (dir string) {
+	dir, err := ioutil.TempDir("", "pliktmpdir")
+	require.NoError(t, err, "unable to create tmp dir")
+
+	err = os.MkdirAll(filepath.Join(dir, "foo", "bar"), 0755)
+	require.NoError(t, err, "unable to create tmp dir")
+
+	for _, name := range []string{"root", "foo/top", "foo/bar/nested"} {
+		err = ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(name), 0644)
+		require.NoError(t, err, "unable to write tmp file")
+	}
+
+	return dir
+}
+
+func checkTestDirectoryUpload(t *testing.T, pc *Client, upload *Upload, dir string) {
+	uploadResult, err := pc.GetUpload(upload.ID())
+	require.NoError(t, err, "unable to get upload")
+	require.Len(t, uploadResult.Files(), 3, "invalid file count")
+
+	base := filepath.Base(dir)
+	paths := make(map[string]string)
+	for _, file := range uploadResult.Files() {
+		require.Equal(t, common.FileUploaded, file.Metadata().Status, "invalid file status")
+		paths[file.Name] = file.Path
+	}
+	require.Equal(t, base, paths["root"], "invalid file path")
+	require.Equal(t, base+"/foo", paths["top"], "invalid file path")
+	require.Equal(t, base+"/foo/bar", paths["nested"], "invalid file path")
+}
+
+func TestUploadDirectory(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	upload := pc.NewUpload()
+	files, err := upload.AddDirectoryFromPath(dir)
+	require.NoError(t, err, "unable to add directory")
+	require.Len(t, files, 3, "invalid file count")
+
+	err = upload.Upload()
+	require.NoError(t, err, "unable to upload files")
+
+	checkTestDirectoryUpload(t, pc, upload, dir)
+}
+
+func TestAddDirectoryToExistingUpload(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	dir := createTestDirectory(t)
+	defer os.RemoveAll(dir)
+
+	upload := pc.NewUpload()
+	err = upload.Create()
+	require.NoError(t, err, "unable to create upload")
+
+	_, err = upload.AddDirectoryFromPath(dir)
+	require.NoError(t, err, "unable to add directory")
+
+	err = upload.Upload()
+	require.NoError(t, err, "unable to upload files")
+
+	checkTestDirectoryUpload(t, pc, upload, dir)
+}
+
+func TestAddDirectoryNotFound(t *testing.T) {
+	pc := NewClient("http://127.0.0.1:1")
+	upload := pc.NewUpload()
+
+	_, err := upload.AddDirectoryFromPath("/this/does/not/exist")
+	require.Error(t, err, "missing error")
+	require.Len(t, upload.Files(), 0, "invalid file count")
+}
+
 func TestUploadMultipleFiles(t *testing.T) {
 	ps, pc := newPlikServerAndClient()
 	defer shutdown(ps)
@@ -388,6 +475,48 @@ func TestDeleteUpload(t *testing.T) {
 	common.RequireError(t, err, "not found")
 }
 
+func TestUpdateUpload(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	data := "data data data"
+	upload, file, err := pc.UploadReader("filename", ioutil.NopCloser(bytes.NewBufferString(data)))
+	require.NoError(t, err, "unable to upload file")
+
+	comments := "comments"
+	ttl := 3600
+	removable := true
+	password := "password"
+	err = upload.Update(&common.UploadUpdate{Comments: &comments, TTL: &ttl, Removable: &removable, Password: &password})
+	require.NoError(t, err, "unable to update upload")
+	require.Equal(t, comments, upload.Comments, "invalid comments")
+	require.Equal(t, ttl, upload.TTL, "invalid ttl")
+	require.True(t, upload.Metadata().ProtectedByPassword, "upload should be protected by password")
+	require.NotEmpty(t, upload.Metadata().UploadToken, "missing upload token")
+
+	// The client is still able to authenticate with the new password
+	uploadResult, err := pc.GetUploadProtectedByPassword(upload.ID(), "plik", password)
+	require.NoError(t, err, "unable to get upload")
+	require.Equal(t, comments, uploadResult.Metadata().Comments, "invalid comments")
+
+	_, err = file.Download()
+	require.NoError(t, err, "unable to download file")
+
+	ttl = -1
+	err = upload.Update(&common.UploadUpdate{TTL: &ttl})
+	common.RequireError(t, err, "cannot set infinite ttl")
+}
+
+func TestUpdateUploadNotCreated(t *testing.T) {
+	_, pc := newPlikServerAndClient()
+
+	err := pc.NewUpload().Update(&common.UploadUpdate{})
+	common.RequireError(t, err, "upload has not been created yet")
+}
+
 func TestDeleteUploadNotFound(t *testing.T) {
 	ps, pc := newPlikServerAndClient()
 	defer shutdown(ps)
@@ -437,6 +566,47 @@ func TestDownloadArchive(t *testing.T) {
 	require.NotEmpty(t, content, "empty archive")
 }
 
+func TestDownloadArchiveSelection(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	upload := pc.NewUpload()
+	file1 := upload.AddFileFromReader("file1", bytes.NewBufferString("data 1"))
+	upload.AddFileFromReader("file2", bytes.NewBufferString("data 2"))
+
+	err = upload.Upload()
+	require.NoError(t, err, "unable to upload files")
+
+	reader, err := upload.DownloadArchive("archive.tar", file1)
+	require.NoError(t, err, "unable to download archive")
+	defer reader.Close()
+
+	tarReader := tar.NewReader(reader)
+	header, err := tarReader.Next()
+	require.NoError(t, err, "unable to read archive")
+	require.Equal(t, "file1", header.Name, "invalid archived file name")
+
+	content, err := ioutil.ReadAll(tarReader)
+	require.NoError(t, err, "unable to read archived file")
+	require.Equal(t, "data 1", string(content), "invalid archived file content")
+
+	_, err = tarReader.Next()
+	require.Equal(t, io.EOF, err, "invalid archive file count")
+}
+
+func TestDownloadArchiveFileNotUploaded(t *testing.T) {
+	pc := NewClient("http://127.0.0.1:1")
+	upload := pc.NewUpload()
+	file := upload.AddFileFromReader("file", bytes.NewBufferString("data"))
+
+	_, err := upload.DownloadArchive("archive.zip", file)
+	require.Error(t, err, "missing error")
+	require.Contains(t, err.Error(), "has not been uploaded yet", "invalid error")
+}
+
 func TestGetArchiveNotFound(t *testing.T) {
 	ps, pc := newPlikServerAndClient()
 	defer shutdown(ps)
@@ -446,7 +616,7 @@ func TestGetArchiveNotFound(t *testing.T) {
 
 	upload := &common.Upload{}
 	upload.PrepareInsertForTests()
-	_, err = pc.downloadArchive(upload)
+	_, err = pc.downloadArchive(upload, "archive.zip", nil)
 	common.RequireError(t, err, "not found")
 
 	upload2 := pc.NewUpload()
@@ -460,6 +630,6 @@ func TestGetArchiveNoServer(t *testing.T) {
 
 	upload := &common.Upload{}
 	upload.PrepareInsertForTests()
-	_, err := pc.downloadArchive(upload)
+	_, err := pc.downloadArchive(upload, "archive.zip", nil)
 	common.RequireError(t, err, "connection refused")
 }
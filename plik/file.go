@@ -15,6 +15,7 @@ import (
 // File contains all relevant info needed to upload data to a Plik server
 type File struct {
 	Name string
+	Path string // Directory of the file inside the upload
 	Size int64
 
 	reader io.ReadCloser // Byte stream to upload
@@ -76,6 +77,7 @@ func newFileFromParams(upload *Upload, params *common.File) *File {
 	file.upload = upload
 	file.metadata = params
 	file.Name = params.Name
+	file.Path = params.Path
 	file.Size = params.Size
 	return file
 }
@@ -95,6 +97,7 @@ func (file *File) getParams() (params *common.File) {
 
 	params = &common.File{}
 	params.Name = file.Name
+	params.Path = file.Path
 
 	if file.metadata != nil {
 		params.ID = file.metadata.ID
@@ -186,6 +189,20 @@ func (file *File) Upload() (err error) {
 	return err
 }
 
+// UploadVersion replaces the data of an uploaded file by a new version, the file keeps its ID and URL
+func (file *File) UploadVersion(reader io.Reader) (err error) {
+	fileMetadata, err := file.upload.client.uploadFileVersion(file.upload.getParams(), file.getParams(), reader)
+	if err != nil {
+		return err
+	}
+
+	file.lock.Lock()
+	file.metadata = fileMetadata
+	file.lock.Unlock()
+
+	return nil
+}
+
 // GetURL returns the URL to download the file
 func (file *File) GetURL() (URL *url.URL, err error) {
 
@@ -73,6 +73,16 @@ func (c *Client) uploadFile(upload *common.Upload, fileParams *common.File, read
 
 	errCh := make(chan error)
 	go func(errCh chan error) {
+		if fileParams.Path != "" {
+			err := multipartWriter.WriteField("path", fileParams.Path)
+			if err != nil {
+				err = fmt.Errorf("unable to write path field : %s", err)
+				_ = pipeWriter.CloseWithError(err)
+				errCh <- err
+				return
+			}
+		}
+
 		writer, err := multipartWriter.CreateFormFile("file", fileParams.Name)
 		if err != nil {
 			err = fmt.Errorf("unable to create multipartWriter : %s", err)
@@ -229,9 +239,14 @@ func (c *Client) downloadFile(uploadParams *common.Upload, fileParams *common.Fi
 	return resp.Body, nil
 }
 
-// downloadArchive download the remote upload files as a zip archive from the server
-func (c *Client) downloadArchive(uploadParams *common.Upload) (reader io.ReadCloser, err error) {
-	URL := c.URL + "/archive/" + uploadParams.ID + "/archive.zip"
+// downloadArchive download the remote upload files as an archive from the server
+// The archive format is chosen by the archive name extension ( .zip, .tar, .tar.gz, .tar.zst )
+// If file IDs are given only those files are archived
+func (c *Client) downloadArchive(uploadParams *common.Upload, name string, fileIDs []string) (reader io.ReadCloser, err error) {
+	URL := c.URL + "/archive/" + uploadParams.ID + "/" + url.PathEscape(name)
+	if len(fileIDs) > 0 {
+		URL += "?files=" + url.QueryEscape(strings.Join(fileIDs, ","))
+	}
 
 	req, err := c.UploadRequest(uploadParams, "GET", URL, nil)
 	if err != nil {
@@ -284,6 +299,118 @@ func (c *Client) removeUpload(uploadParams *common.Upload) (err error) {
 	return nil
 }
 
+// updateUpload apply a partial update to the upload on the Plik Server and return the upload metadata
+func (c *Client) updateUpload(uploadParams *common.Upload, update *common.UploadUpdate) (uploadMetadata *common.Upload, err error) {
+	if update == nil {
+		return nil, errors.New("missing upload update")
+	}
+
+	j, err := json.Marshal(update)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.UploadRequest(uploadParams, "PATCH", c.URL+"/upload/"+uploadParams.ID, bytes.NewBuffer(j))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.MakeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse json response
+	uploadMetadata = &common.Upload{}
+	err = json.Unmarshal(body, uploadMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return uploadMetadata, nil
+}
+
+// fetchFile ask the Plik Server to add a file to the upload from a remote URL and return the file metadata
+func (c *Client) fetchFile(uploadParams *common.Upload, fileParams *common.File, URL string) (fileInfo *common.File, err error) {
+	params := map[string]string{"url": URL, "fileName": fileParams.Name, "path": fileParams.Path}
+	j, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.UploadRequest(uploadParams, "POST", c.URL+"/file/"+uploadParams.ID+"/fetch", bytes.NewBuffer(j))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.MakeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse json response
+	fileInfo = &common.File{}
+	err = json.Unmarshal(body, fileInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo, nil
+}
+
+// uploadFileVersion upload a new version of an uploaded file and return the file metadata
+func (c *Client) uploadFileVersion(uploadParams *common.Upload, fileParams *common.File, reader io.Reader) (fileInfo *common.File, err error) {
+	if uploadParams == nil || fileParams == nil || fileParams.ID == "" || reader == nil {
+		return nil, errors.New("missing file version upload parameter")
+	}
+
+	URL, err := url.Parse(c.URL + "/file/" + uploadParams.ID + "/" + fileParams.ID + "/" + fileParams.Name + "/versions")
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.UploadRequest(uploadParams, "PUT", URL.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.MakeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse json response
+	fileInfo = &common.File{}
+	err = json.Unmarshal(body, fileInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo, nil
+}
+
 // MakeRequest perform an HTTP request to a Plik Server HTTP API.
 //  - Manage request header X-ClientApp and X-ClientVersion
 //  - Log the request and response if the client is in Debug mode
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 
@@ -17,6 +19,10 @@ type UploadParams struct {
 	OneShot   bool // Force deletion of the file from the server after the first download
 	Removable bool // Allow upload and upload files to be removed from the server at any time
 
+	MaxDownloads int // Remove each file from the server once it has been downloaded this many times
+
+	StripMetadata bool // Remove EXIF, XMP and IPTC metadata from the uploaded images
+
 	TTL      int    // Time in second before automatic deletion of the file from the server
 	Comments string // Arbitrary comment to attach to the upload ( the web interface support markdown language )
 
@@ -56,6 +62,8 @@ func newUploadFromMetadata(client *Client, uploadMetadata *common.Upload) (uploa
 	upload.Stream = uploadMetadata.Stream
 	upload.OneShot = uploadMetadata.OneShot
 	upload.Removable = uploadMetadata.Removable
+	upload.MaxDownloads = uploadMetadata.MaxDownloads
+	upload.StripMetadata = uploadMetadata.StripMetadata
 	upload.TTL = uploadMetadata.TTL
 	upload.Comments = uploadMetadata.Comments
 	upload.metadata = uploadMetadata
@@ -89,6 +97,52 @@ func (upload *Upload) AddFileFromPath(name string) (file *File, err error) {
 	return file, nil
 }
 
+// AddDirectoryFromPath recursively add all the files of a filesystem directory
+// Each file keeps its path relative to the parent of the directory so the upload mirrors the directory tree
+func (upload *Upload) AddDirectoryFromPath(dir string) (files []*File, err error) {
+	dir = filepath.Clean(dir)
+	root := filepath.Dir(dir)
+
+	walk := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		file, err := newFileFromPath(upload, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			file.Path = filepath.ToSlash(rel)
+		}
+
+		return nil
+	}
+
+	err = filepath.Walk(dir, walk)
+	if err != nil {
+		for _, file := range files {
+			_ = file.reader.Close()
+		}
+		return nil, err
+	}
+
+	for _, file := range files {
+		upload.add(file)
+	}
+
+	return files, nil
+}
+
 // AddFileFromReader add a new file from a filename and io.Reader
 func (upload *Upload) AddFileFromReader(name string, reader io.Reader) (file *File) {
 	file = newFileFromReader(upload, name, reader)
@@ -103,6 +157,25 @@ func (upload *Upload) AddFileFromReadCloser(name string, reader io.ReadCloser) (
 	return file
 }
 
+// FetchFile ask the server to add a file to the upload from a remote URL, the upload is created if needed
+// The name defaults to the last element of the URL path. The file is downloaded by the server in the background,
+// its status is "uploading" until the fetch completes and "removed" with the reason in FetchError if it fails.
+func (upload *Upload) FetchFile(URL string, name string) (file *File, err error) {
+	err = upload.Create()
+	if err != nil {
+		return nil, err
+	}
+
+	fileMetadata, err := upload.client.fetchFile(upload.getParams(), &common.File{Name: name}, URL)
+	if err != nil {
+		return nil, err
+	}
+
+	file = newFileFromParams(upload, fileMetadata)
+	upload.add(file)
+	return file, nil
+}
+
 // Metadata return the upload metadata returned by the server
 func (upload *Upload) Metadata() (details *common.Upload) {
 	upload.lock.Lock()
@@ -120,6 +193,8 @@ func (upload *Upload) getParams() (params *common.Upload) {
 	params.Stream = upload.Stream
 	params.OneShot = upload.OneShot
 	params.Removable = upload.Removable
+	params.MaxDownloads = upload.MaxDownloads
+	params.StripMetadata = upload.StripMetadata
 	params.TTL = upload.TTL
 	params.Comments = upload.Comments
 	params.Token = upload.Token
@@ -318,7 +393,65 @@ func (upload *Upload) GetAdminURL() (u *url.URL, err error) {
 
 // DownloadZipArchive downloads all the upload files in a zip archive
 func (upload *Upload) DownloadZipArchive() (reader io.ReadCloser, err error) {
-	return upload.client.downloadArchive(upload.getParams())
+	return upload.client.downloadArchive(upload.getParams(), "archive.zip", nil)
+}
+
+// DownloadArchive downloads the given files or all the upload files if none in an archive
+// The archive format is chosen by the archive name extension ( .zip, .tar, .tar.gz, .tar.zst )
+func (upload *Upload) DownloadArchive(name string, files ...*File) (reader io.ReadCloser, err error) {
+	var fileIDs []string
+	for _, file := range files {
+		metadata := file.Metadata()
+		if metadata == nil || metadata.ID == "" {
+			return nil, fmt.Errorf("file %s has not been uploaded yet", file.Name)
+		}
+		fileIDs = append(fileIDs, metadata.ID)
+	}
+
+	return upload.client.downloadArchive(upload.getParams(), name, fileIDs)
+}
+
+// Update change the comments, expiration date or options of an upload already created on the server
+func (upload *Upload) Update(update *common.UploadUpdate) (err error) {
+	if upload.Metadata() == nil {
+		return fmt.Errorf("upload has not been created yet")
+	}
+
+	uploadMetadata, err := upload.client.updateUpload(upload.getParams(), update)
+	if err != nil {
+		return err
+	}
+
+	upload.lock.Lock()
+	defer upload.lock.Unlock()
+
+	upload.OneShot = uploadMetadata.OneShot
+	upload.Removable = uploadMetadata.Removable
+	upload.MaxDownloads = uploadMetadata.MaxDownloads
+	upload.StripMetadata = uploadMetadata.StripMetadata
+	upload.TTL = uploadMetadata.TTL
+	upload.Comments = uploadMetadata.Comments
+
+	// Keep the credentials up to date to authenticate the next requests
+	if update.Password != nil {
+		upload.Login = "plik"
+		if update.Login != nil && *update.Login != "" {
+			upload.Login = *update.Login
+		}
+		upload.Password = *update.Password
+	} else if !uploadMetadata.ProtectedByPassword {
+		upload.Login = ""
+		upload.Password = ""
+	}
+
+	// The upload token is not sent back by the server
+	uploadMetadata.UploadToken = upload.metadata.UploadToken
+
+	// Remove files from metadata as this could be misleading
+	uploadMetadata.Files = nil
+	upload.metadata = uploadMetadata
+
+	return nil
 }
 
 // Delete remove the upload and all the associated files from the remote server
@@ -2,11 +2,13 @@ package plik
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"sync"
@@ -192,6 +194,73 @@ func TestRemovable(t *testing.T) {
 	require.NoError(t, err, "unable to upload file")
 }
 
+func TestStripMetadata(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	pc.StripMetadata = true
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	data := "data data data"
+	upload, file, err := pc.UploadReader("filename", bytes.NewBufferString(data))
+	require.NoError(t, err, "unable to upload file")
+	require.True(t, upload.Metadata().StripMetadata, "invalid strip metadata option")
+
+	// Only images are stripped
+	require.False(t, file.Metadata().MetadataStripped, "unexpected metadata stripping")
+	require.Equal(t, int64(len(data)), file.Metadata().Size, "invalid file size")
+}
+
+func TestFetchFile(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	ps.GetConfig().Fetch = true
+	ps.GetConfig().FetchAllowedNetworks = []string{"127.0.0.1"}
+	err := ps.GetConfig().Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	err = start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	data := "data data data"
+	remote := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		_, _ = resp.Write([]byte(data))
+	}))
+	defer remote.Close()
+
+	upload := pc.NewUpload()
+	file, err := upload.FetchFile(remote.URL+"/dir/filename?token=secret", "")
+	require.NoError(t, err, "unable to fetch file")
+	require.Equal(t, "filename", file.Name, "invalid file name")
+	require.Equal(t, remote.URL+"/dir/filename", file.Metadata().Source, "invalid file source")
+
+	var metadata *common.File
+	for i := 0; i < 100; i++ {
+		uploadResult, err := pc.GetUpload(upload.ID())
+		require.NoError(t, err, "unable to get upload")
+		require.Len(t, uploadResult.Files(), 1, "invalid file count")
+
+		metadata = uploadResult.Files()[0].Metadata()
+		if metadata.Status != common.FileUploading {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.Equal(t, common.FileUploaded, metadata.Status, "invalid file status")
+	require.Equal(t, int64(len(data)), metadata.Size, "invalid file size")
+
+	reader, err := pc.downloadFile(upload.getParams(), metadata)
+	require.NoError(t, err, "unable to download file")
+	defer func() { _ = reader.Close() }()
+
+	content, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, data, string(content), "invalid file content")
+}
+
 func TestUploadWithoutUploadToken(t *testing.T) {
 	ps, pc := newPlikServerAndClient()
 	defer shutdown(ps)
@@ -336,3 +405,141 @@ func TestQuickUpload(t *testing.T) {
 
 	require.Equal(t, content, string(respBody), "invalid file content")
 }
+
+func TestQuickUploadPut(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	content := "data data data"
+
+	req, err := http.NewRequest("PUT", pc.URL+"/filename.txt", bytes.NewBufferString(content))
+	require.NoError(t, err, "unable to create plik request")
+	req.Header.Set("X-Plik-Max-Downloads", "2")
+	req.Header.Set("X-Plik-Output", "json")
+
+	resp, err := pc.MakeRequest(req)
+	require.NoError(t, err, "unable to make quick request (%s) %s", req.Method, req.URL.String())
+	require.Equal(t, 200, resp.StatusCode, "invalid HTTP response status %s", resp.Status)
+
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	result := struct {
+		URL      string `json:"url"`
+		AdminURL string `json:"adminUrl"`
+	}{}
+	err = json.Unmarshal(respBody, &result)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, result.AdminURL, resp.Header.Get("X-Plik-Admin-URL"), "invalid admin url")
+
+	download := func() (*http.Response, error) {
+		req, err := http.NewRequest("GET", result.URL, nil)
+		require.NoError(t, err, "unable to create plik request")
+		return pc.MakeRequest(req)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err = download()
+		require.NoError(t, err, "unable to download file")
+		respBody, err = ioutil.ReadAll(resp.Body)
+		require.NoError(t, err, "unable to read response body")
+		_ = resp.Body.Close()
+		require.Equal(t, content, string(respBody), "invalid file content")
+	}
+
+	_, err = download()
+	require.Error(t, err, "download limit should be reached")
+	require.Contains(t, err.Error(), "is not available", "invalid error")
+}
+
+func TestPaste(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	req, err := http.NewRequest("POST", pc.URL+"/paste?language=go", bytes.NewBufferString("package main"))
+	require.NoError(t, err, "unable to create plik request")
+	req.Header.Set("X-Plik-OneShot", "true")
+	req.Header.Set("X-Plik-Password", "secret")
+
+	resp, err := pc.MakeRequest(req)
+	require.NoError(t, err, "unable to make paste request")
+	respBody, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err, "unable to read response body")
+	_ = resp.Body.Close()
+
+	pasteURL := strings.TrimSpace(string(respBody))
+	require.Contains(t, pasteURL, "/paste/", "invalid paste url")
+
+	get := func(URL string, password string) (*http.Response, error) {
+		req, err := http.NewRequest("GET", URL, nil)
+		require.NoError(t, err, "unable to create plik request")
+		if password != "" {
+			req.SetBasicAuth("plik", password)
+		}
+		return pc.MakeRequest(req)
+	}
+
+	_, err = get(pasteURL, "")
+	require.Error(t, err, "password protected paste should not be displayed")
+
+	resp, err = get(pasteURL, "secret")
+	require.NoError(t, err, "unable to get paste")
+	respBody, err = ioutil.ReadAll(resp.Body)
+	require.NoError(t, err, "unable to read response body")
+	_ = resp.Body.Close()
+	require.Contains(t, string(respBody), `<span class="k">package</span> main`, "invalid highlighted paste")
+
+	_, err = get(pasteURL+"/raw", "secret")
+	require.Error(t, err, "one shot paste should not be displayed twice")
+	require.Contains(t, err.Error(), "is not available", "invalid error")
+}
+
+func TestFileVersions(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	upload := pc.NewUpload()
+	file := upload.AddFileFromReader("filename", bytes.NewBufferString("version 1"))
+	err = upload.Upload()
+	require.NoError(t, err, "unable to upload file")
+
+	fileURL, err := file.GetURL()
+	require.NoError(t, err, "unable to get file url")
+
+	err = file.UploadVersion(bytes.NewBufferString("version 2"))
+	require.NoError(t, err, "unable to upload file version")
+	require.Equal(t, 2, file.Metadata().Version, "invalid file version")
+
+	// The file URL doesn't change
+	newURL, err := file.GetURL()
+	require.NoError(t, err, "unable to get file url")
+	require.Equal(t, fileURL.String(), newURL.String(), "invalid file url")
+
+	get := func(URL string) string {
+		req, err := http.NewRequest("GET", URL, nil)
+		require.NoError(t, err, "unable to create plik request")
+		resp, err := pc.MakeRequest(req)
+		require.NoError(t, err, "unable to download file")
+		defer func() { _ = resp.Body.Close() }()
+		content, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err, "unable to read file")
+		return string(content)
+	}
+
+	require.Equal(t, "version 2", get(fileURL.String()), "invalid latest version")
+	require.Equal(t, "version 1", get(fileURL.String()+"?version=1"), "invalid previous version")
+
+	uploadResult, err := pc.GetUpload(upload.ID())
+	require.NoError(t, err, "unable to get upload")
+	require.Len(t, uploadResult.Files(), 1, "invalid file count")
+	versions := uploadResult.Files()[0].Metadata().Versions
+	require.Len(t, versions, 1, "invalid version history")
+	require.Equal(t, 1, versions[0].Version, "invalid previous version")
+}
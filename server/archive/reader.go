@@ -0,0 +1,492 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+)
+
+// MaxMembers is the maximum number of members of an archive that can be indexed
+const MaxMembers = 10000
+
+// MaxNameLength is the maximum length of an archive member name
+const MaxNameLength = 1024
+
+// MaxCompressionRatio is the maximum ratio between the uncompressed and the compressed size of archive data
+// Above this ratio, and a few megabytes, the archive is considered as a decompression bomb
+const MaxCompressionRatio = 200
+
+// minCheckedSize is the uncompressed size under which the compression ratio is not checked
+const minCheckedSize = 1 << 20
+
+// readAheadSize is the minimum size of the ranged reads performed on the data backend
+const readAheadSize = 1 << 20
+
+// InvalidArchiveError is returned when a file is not a valid archive or exceeds the archive limits
+type InvalidArchiveError struct {
+	msg string
+}
+
+func (e *InvalidArchiveError) Error() string {
+	return e.msg
+}
+
+func invalidArchive(format string, args ...interface{}) error {
+	return &InvalidArchiveError{msg: fmt.Sprintf(format, args...)}
+}
+
+// IsInvalidArchive returns true if the error is due to the archive content
+// and not to a transient data backend error
+func IsInvalidArchive(err error) bool {
+	_, ok := err.(*InvalidArchiveError)
+	return ok
+}
+
+// Index lists the regular file members of an archive stored in the data backend
+// The archive format is chosen by the file name extension
+func Index(backend data.Backend, file *common.File) (format *Format, members []*common.ArchiveMember, err error) {
+	format = GetFormat(file.Name)
+	if format == nil {
+		return nil, nil, invalidArchive("unsupported archive format")
+	}
+
+	members, err = format.index(backend, file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, member := range members {
+		member.FileID = file.ID
+	}
+
+	return format, members, nil
+}
+
+// Extract returns the content of a single member of an indexed archive
+func Extract(backend data.Backend, file *common.File, member *common.ArchiveMember) (reader io.ReadCloser, err error) {
+	format := GetFormatByName(file.Archive)
+	if format == nil {
+		return nil, invalidArchive("file has not been indexed as an archive")
+	}
+
+	return format.extract(backend, file, member)
+}
+
+// checkMember validates a member before adding it to the archive index
+func checkMember(members []*common.ArchiveMember, name string) error {
+	if len(members) >= MaxMembers {
+		return invalidArchive("too many archive members, maximum is %d", MaxMembers)
+	}
+	if len(name) > MaxNameLength {
+		return invalidArchive("archive member name is too long, maximum length is %d characters", MaxNameLength)
+	}
+	return nil
+}
+
+// checkRatio returns an error if the data looks like a decompression bomb
+func checkRatio(uncompressedSize int64, compressedSize int64) error {
+	if uncompressedSize <= minCheckedSize {
+		return nil
+	}
+	if compressedSize <= 0 || uncompressedSize/compressedSize > MaxCompressionRatio {
+		return invalidArchive("archive compression ratio is too high, maximum is %d", MaxCompressionRatio)
+	}
+	return nil
+}
+
+// rangeReaderAt implements io.ReaderAt over the ranged reads of a data backend
+// Reads are buffered as zip directories are read in small sequential chunks
+type rangeReaderAt struct {
+	backend data.RangeBackend
+	file    *common.File
+	buffer  []byte
+	offset  int64
+	err     error // Last data backend error
+}
+
+func newRangeReaderAt(backend data.Backend, file *common.File) (*rangeReaderAt, error) {
+	rangeBackend, ok := backend.(data.RangeBackend)
+	if !ok {
+		return nil, errors.New("data backend does not support ranged reads")
+	}
+	return &rangeReaderAt{backend: rangeBackend, file: file}, nil
+}
+
+// ReadAt implements io.ReaderAt
+func (r *rangeReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.file.Size {
+		return 0, io.EOF
+	}
+
+	end := off + int64(len(p))
+	if end > r.file.Size {
+		end = r.file.Size
+	}
+
+	if off < r.offset || end > r.offset+int64(len(r.buffer)) {
+		length := end - off
+		if length < readAheadSize {
+			length = readAheadSize
+		}
+		if off+length > r.file.Size {
+			length = r.file.Size - off
+		}
+
+		err = r.fill(off, length)
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+	}
+
+	n = copy(p, r.buffer[off-r.offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *rangeReaderAt) fill(offset int64, length int64) error {
+	reader, err := r.backend.GetFileRange(r.file, offset, length)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+
+	buffer := make([]byte, length)
+	_, err = io.ReadFull(reader, buffer)
+	if err != nil {
+		return err
+	}
+
+	r.buffer = buffer
+	r.offset = offset
+	return nil
+}
+
+// errorRecorder records the errors of a data backend reader
+// to tell transient backend errors from invalid archive errors
+type errorRecorder struct {
+	reader io.Reader
+	err    error
+}
+
+func (r *errorRecorder) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// countingReader counts the bytes read and fails above limit bytes if limit is positive
+type countingReader struct {
+	reader io.Reader
+	count  int64
+	limit  int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.count += int64(n)
+	if r.limit > 0 && r.count > r.limit {
+		return n, invalidArchive("archive compression ratio is too high, maximum is %d", MaxCompressionRatio)
+	}
+	return n, err
+}
+
+// memberReader returns exactly size bytes of an archive member and verifies the checksum if any
+type memberReader struct {
+	reader    io.Reader
+	closers   []io.Closer
+	remaining int64
+	hash      hash.Hash32
+	crc       uint32
+}
+
+func (r *memberReader) Read(p []byte) (n int, err error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err = r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.hash != nil {
+		_, _ = r.hash.Write(p[:n])
+	}
+
+	if r.remaining == 0 {
+		if r.hash != nil && r.hash.Sum32() != r.crc {
+			return n, invalidArchive("archive member checksum mismatch")
+		}
+		return n, io.EOF
+	}
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Close closes the decompressors then the data backend reader
+func (r *memberReader) Close() (err error) {
+	return closeAll(r.closers)
+}
+
+// closeAll closes the readers in reverse order and returns the first error
+func closeAll(closers []io.Closer) (err error) {
+	for i := len(closers) - 1; i >= 0; i-- {
+		e := closers[i].Close()
+		if err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func openZip(backend data.Backend, file *common.File) (*zip.Reader, *rangeReaderAt, error) {
+	readerAt, err := newRangeReaderAt(backend, file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := zip.NewReader(readerAt, file.Size)
+	if err != nil {
+		if readerAt.err != nil {
+			return nil, nil, readerAt.err
+		}
+		return nil, nil, invalidArchive("invalid zip archive : %s", err)
+	}
+
+	return reader, readerAt, nil
+}
+
+// indexZip reads the zip central directory using ranged reads
+func indexZip(backend data.Backend, file *common.File) (members []*common.ArchiveMember, err error) {
+	reader, _, err := openZip(backend, file)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		err = checkMember(members, f.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		member := &common.ArchiveMember{
+			Index:          i,
+			Name:           f.Name,
+			Size:           int64(f.UncompressedSize64),
+			CompressedSize: int64(f.CompressedSize64),
+			Method:         f.Method,
+			ModTime:        f.Modified,
+		}
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// extractZip reads the zip central directory again to locate the member
+// then decompress the member data fetched with a single ranged read
+func extractZip(backend data.Backend, file *common.File, member *common.ArchiveMember) (io.ReadCloser, error) {
+	reader, readerAt, err := openZip(backend, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if member.Index < 0 || member.Index >= len(reader.File) || reader.File[member.Index].Name != member.Name {
+		return nil, invalidArchive("archive member not found")
+	}
+	f := reader.File[member.Index]
+
+	if f.Method != zip.Store && f.Method != zip.Deflate {
+		return nil, invalidArchive("unsupported zip compression method %d", f.Method)
+	}
+
+	err = checkRatio(int64(f.UncompressedSize64), int64(f.CompressedSize64))
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := f.DataOffset()
+	if err != nil {
+		if readerAt.err != nil {
+			return nil, readerAt.err
+		}
+		return nil, invalidArchive("invalid zip archive : %s", err)
+	}
+
+	data, err := readerAt.backend.GetFileRange(file, offset, int64(f.CompressedSize64))
+	if err != nil {
+		return nil, err
+	}
+
+	content := &memberReader{
+		reader:    data,
+		closers:   []io.Closer{data},
+		remaining: int64(f.UncompressedSize64),
+		hash:      crc32.NewIEEE(),
+		crc:       f.CRC32,
+	}
+
+	if f.Method == zip.Deflate {
+		decompressor := flate.NewReader(data)
+		content.reader = decompressor
+		content.closers = append(content.closers, decompressor)
+	}
+
+	return content, nil
+}
+
+// decompressor wraps a compressed tar archive reader
+type decompressor func(reader io.Reader) (io.ReadCloser, error)
+
+func gunzip(reader io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(reader)
+}
+
+func unzstd(reader io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
+
+// openTar returns the uncompressed tar stream of the file and the readers to close
+func openTar(backend data.Backend, file *common.File, decompress decompressor) (content io.Reader, recorder *errorRecorder, closers []io.Closer, err error) {
+	reader, err := backend.GetFile(file)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	closers = append(closers, reader)
+
+	recorder = &errorRecorder{reader: reader}
+	content = recorder
+
+	if decompress != nil {
+		decompressor, err := decompress(recorder)
+		if err != nil {
+			_ = reader.Close()
+			if recorder.err != nil {
+				return nil, nil, nil, recorder.err
+			}
+			return nil, nil, nil, invalidArchive("invalid compressed archive : %s", err)
+		}
+		closers = append(closers, decompressor)
+		content = decompressor
+	}
+
+	return content, recorder, closers, nil
+}
+
+// indexTar reads the tar headers sequentially and records the offset of each member data
+// in the uncompressed stream. Compressed archives are limited to MaxCompressionRatio.
+func indexTar(decompress decompressor) func(backend data.Backend, file *common.File) ([]*common.ArchiveMember, error) {
+	return func(backend data.Backend, file *common.File) (members []*common.ArchiveMember, err error) {
+		content, recorder, closers, err := openTar(backend, file, decompress)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = closeAll(closers) }()
+
+		counter := &countingReader{reader: content}
+		if decompress != nil && file.Size*MaxCompressionRatio > minCheckedSize {
+			counter.limit = file.Size * MaxCompressionRatio
+		}
+
+		// The tar reader does not buffer so the data of each member starts at the current count
+		reader := tar.NewReader(counter)
+		for i := 0; ; i++ {
+			header, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if recorder.err != nil {
+					return nil, recorder.err
+				}
+				if IsInvalidArchive(err) {
+					return nil, err
+				}
+				return nil, invalidArchive("invalid tar archive : %s", err)
+			}
+
+			if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+				continue
+			}
+
+			err = checkMember(members, header.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			member := &common.ArchiveMember{
+				Index:   i,
+				Name:    strings.TrimPrefix(header.Name, "./"),
+				Size:    header.Size,
+				Offset:  counter.count,
+				ModTime: header.ModTime,
+			}
+			members = append(members, member)
+		}
+
+		return members, nil
+	}
+}
+
+// extractTar uses a single ranged read for uncompressed archives
+// compressed archives have to be decompressed up to the member data
+func extractTar(decompress decompressor) func(backend data.Backend, file *common.File, member *common.ArchiveMember) (io.ReadCloser, error) {
+	return func(backend data.Backend, file *common.File, member *common.ArchiveMember) (io.ReadCloser, error) {
+		if rangeBackend, ok := backend.(data.RangeBackend); ok && decompress == nil {
+			reader, err := rangeBackend.GetFileRange(file, member.Offset, member.Size)
+			if err != nil {
+				return nil, err
+			}
+			return &memberReader{reader: reader, closers: []io.Closer{reader}, remaining: member.Size}, nil
+		}
+
+		content, recorder, closers, err := openTar(backend, file, decompress)
+		if err != nil {
+			return nil, err
+		}
+
+		reader := &memberReader{reader: content, closers: closers, remaining: member.Size}
+
+		_, err = io.CopyN(ioutil.Discard, content, member.Offset)
+		if err != nil {
+			_ = reader.Close()
+			if recorder.err != nil {
+				return nil, recorder.err
+			}
+			return nil, invalidArchive("invalid tar archive : %s", err)
+		}
+
+		return reader, nil
+	}
+}
@@ -0,0 +1,210 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+	data_test "github.com/root-gg/plik/server/data/testing"
+)
+
+// sequentialBackend hides the ranged reads of the testing data backend
+type sequentialBackend struct {
+	data.Backend
+}
+
+func newTestArchiveFile(t *testing.T, backend data.Backend, name string, content []byte) *common.File {
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = name
+	file.Size = int64(len(content))
+	upload.PrepareInsertForTests()
+
+	err := backend.AddFile(file, bytes.NewReader(content))
+	require.NoError(t, err, "unable to add file")
+
+	return file
+}
+
+func checkTestArchiveIndex(t *testing.T, backend data.Backend, file *common.File, files map[string]string) {
+	format, members, err := Index(backend, file)
+	require.NoError(t, err, "unable to index archive")
+	require.Len(t, members, len(files), "invalid member count")
+
+	file.Archive = format.Name
+	for _, member := range members {
+		require.Equal(t, file.ID, member.FileID, "invalid member file id")
+
+		expected, ok := files[member.Name]
+		require.True(t, ok, "unexpected member %s", member.Name)
+		require.Equal(t, int64(len(expected)), member.Size, "invalid member size")
+
+		reader, err := Extract(backend, file, member)
+		require.NoError(t, err, "unable to extract member")
+
+		content, err := ioutil.ReadAll(reader)
+		require.NoError(t, err, "unable to read member")
+		require.Equal(t, expected, string(content), "invalid member content")
+
+		err = reader.Close()
+		require.NoError(t, err, "unable to close member")
+	}
+}
+
+func TestIndexAndExtract(t *testing.T) {
+	files := map[string]string{
+		"file":         "data",
+		"foo/bar/file": strings.Repeat("data data data ", 1000),
+		"empty":        "",
+	}
+
+	for _, format := range Formats {
+		content := writeTestArchive(t, format, files)
+
+		backend := data_test.NewBackend()
+		file := newTestArchiveFile(t, backend, "archive"+format.Extension, content)
+		checkTestArchiveIndex(t, backend, file, files)
+
+		// Tar archives can also be indexed and extracted without ranged reads
+		if format.Name != "zip" {
+			checkTestArchiveIndex(t, &sequentialBackend{backend}, file, files)
+		}
+	}
+}
+
+func TestIndexZipWithoutRangedReads(t *testing.T) {
+	backend := data_test.NewBackend()
+	content := writeTestArchive(t, GetFormat(".zip"), testFiles)
+	file := newTestArchiveFile(t, backend, "archive.zip", content)
+
+	_, _, err := Index(&sequentialBackend{backend}, file)
+	require.Error(t, err, "missing error")
+	require.False(t, IsInvalidArchive(err), "invalid error type")
+}
+
+func TestIndexUnsupportedFormat(t *testing.T) {
+	backend := data_test.NewBackend()
+	file := newTestArchiveFile(t, backend, "archive.rar", []byte("data"))
+
+	_, _, err := Index(backend, file)
+	require.Error(t, err, "missing error")
+	require.True(t, IsInvalidArchive(err), "invalid error type")
+}
+
+func TestIndexInvalidArchive(t *testing.T) {
+	for _, format := range Formats {
+		backend := data_test.NewBackend()
+		content := bytes.Repeat([]byte("not an archive "), 100)
+		file := newTestArchiveFile(t, backend, "archive"+format.Extension, content)
+
+		_, _, err := Index(backend, file)
+		require.Error(t, err, "missing error for %s", format.Extension)
+		require.True(t, IsInvalidArchive(err), "invalid error type for %s : %s", format.Extension, err)
+	}
+}
+
+func TestIndexBackendError(t *testing.T) {
+	for _, format := range Formats {
+		backend := data_test.NewBackend()
+		content := writeTestArchive(t, format, testFiles)
+		file := newTestArchiveFile(t, backend, "archive"+format.Extension, content)
+
+		backend.SetError(io.ErrClosedPipe)
+		_, _, err := Index(backend, file)
+		require.Error(t, err, "missing error for %s", format.Extension)
+		require.False(t, IsInvalidArchive(err), "invalid error type for %s", format.Extension)
+	}
+}
+
+func TestIndexTooManyMembers(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i <= MaxMembers; i++ {
+		files[strings.Repeat("x", 10)+string(rune('a'+i%26))+time.Duration(i).String()] = ""
+	}
+
+	backend := data_test.NewBackend()
+	content := writeTestArchive(t, GetFormat(".tar"), files)
+	file := newTestArchiveFile(t, backend, "archive.tar", content)
+
+	_, _, err := Index(backend, file)
+	require.Error(t, err, "missing error")
+	require.Contains(t, err.Error(), "too many archive members", "invalid error")
+}
+
+func TestIndexNameTooLong(t *testing.T) {
+	files := map[string]string{strings.Repeat("x", MaxNameLength+1): "data"}
+
+	backend := data_test.NewBackend()
+	content := writeTestArchive(t, GetFormat(".zip"), files)
+	file := newTestArchiveFile(t, backend, "archive.zip", content)
+
+	_, _, err := Index(backend, file)
+	require.Error(t, err, "missing error")
+	require.Contains(t, err.Error(), "name is too long", "invalid error")
+}
+
+func TestDecompressionBomb(t *testing.T) {
+	files := map[string]string{"zeros": string(make([]byte, 10*minCheckedSize))}
+
+	// Compressed tar archives are refused at indexing time
+	backend := data_test.NewBackend()
+	content := writeTestArchive(t, GetFormat(".tar.gz"), files)
+	file := newTestArchiveFile(t, backend, "archive.tar.gz", content)
+
+	_, _, err := Index(backend, file)
+	require.Error(t, err, "missing error")
+	require.Contains(t, err.Error(), "compression ratio is too high", "invalid error")
+
+	// Zip members are refused at extraction time
+	content = writeTestArchive(t, GetFormat(".zip"), files)
+	file = newTestArchiveFile(t, backend, "archive.zip", content)
+
+	format, members, err := Index(backend, file)
+	require.NoError(t, err, "unable to index archive")
+	require.Len(t, members, 1, "invalid member count")
+
+	file.Archive = format.Name
+	_, err = Extract(backend, file, members[0])
+	require.Error(t, err, "missing error")
+	require.Contains(t, err.Error(), "compression ratio is too high", "invalid error")
+}
+
+func TestExtractMemberNotFound(t *testing.T) {
+	backend := data_test.NewBackend()
+	content := writeTestArchive(t, GetFormat(".zip"), testFiles)
+	file := newTestArchiveFile(t, backend, "archive.zip", content)
+	file.Archive = "zip"
+
+	_, err := Extract(backend, file, &common.ArchiveMember{Index: 42, Name: "file"})
+	require.Error(t, err, "missing error")
+	require.Contains(t, err.Error(), "archive member not found", "invalid error")
+}
+
+func TestExtractNotIndexed(t *testing.T) {
+	backend := data_test.NewBackend()
+	file := newTestArchiveFile(t, backend, "archive.zip", []byte("data"))
+
+	_, err := Extract(backend, file, &common.ArchiveMember{})
+	require.Error(t, err, "missing error")
+	require.True(t, IsInvalidArchive(err), "invalid error type")
+}
+
+func TestExtractTruncatedMember(t *testing.T) {
+	backend := data_test.NewBackend()
+	content := writeTestArchive(t, GetFormat(".tar"), testFiles)
+	file := newTestArchiveFile(t, backend, "archive.tar", content)
+	file.Archive = "tar"
+
+	reader, err := Extract(backend, file, &common.ArchiveMember{Offset: int64(len(content)) - 2, Size: 10})
+	require.NoError(t, err, "unable to extract member")
+
+	_, err = ioutil.ReadAll(reader)
+	require.Equal(t, io.ErrUnexpectedEOF, err, "invalid error")
+}
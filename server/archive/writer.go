@@ -0,0 +1,143 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+)
+
+// Writer streams files into an archive
+type Writer interface {
+	// Create adds a new entry to the archive, the returned writer must receive exactly size bytes
+	Create(name string, size int64, modTime time.Time) (io.Writer, error)
+	// Close writes the archive trailer and flushes the underlying compressor if any
+	Close() error
+}
+
+// Format describes an archive format available for download and indexing
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+	NewWriter   func(w io.Writer) (Writer, error)
+
+	index   func(backend data.Backend, file *common.File) ([]*common.ArchiveMember, error)
+	extract func(backend data.Backend, file *common.File, member *common.ArchiveMember) (io.ReadCloser, error)
+}
+
+// Formats lists the available archive formats by extension
+// Longer extensions are listed first so ".tar.gz" is not matched as ".gz"
+var Formats = []*Format{
+	{Name: "tar.gz", Extension: ".tar.gz", ContentType: "application/gzip", NewWriter: newTarGzipWriter, index: indexTar(gunzip), extract: extractTar(gunzip)},
+	{Name: "tar.zst", Extension: ".tar.zst", ContentType: "application/zstd", NewWriter: newTarZstdWriter, index: indexTar(unzstd), extract: extractTar(unzstd)},
+	{Name: "tar.gz", Extension: ".tgz", ContentType: "application/gzip", NewWriter: newTarGzipWriter, index: indexTar(gunzip), extract: extractTar(gunzip)},
+	{Name: "tar", Extension: ".tar", ContentType: "application/x-tar", NewWriter: newTarWriter, index: indexTar(nil), extract: extractTar(nil)},
+	{Name: "zip", Extension: ".zip", ContentType: "application/zip", NewWriter: newZipWriter, index: indexZip, extract: extractZip},
+}
+
+// GetFormat returns the archive format matching the file name extension or nil
+func GetFormat(filename string) *Format {
+	for _, format := range Formats {
+		if strings.HasSuffix(filename, format.Extension) {
+			return format
+		}
+	}
+	return nil
+}
+
+// GetFormatByName returns the archive format with the given name or nil
+func GetFormatByName(name string) *Format {
+	for _, format := range Formats {
+		if format.Name == name {
+			return format
+		}
+	}
+	return nil
+}
+
+// GetExtensions returns the supported archive extensions
+func GetExtensions() (extensions []string) {
+	for _, format := range Formats {
+		extensions = append(extensions, format.Extension)
+	}
+	return extensions
+}
+
+type zipWriter struct {
+	*zip.Writer
+}
+
+func newZipWriter(w io.Writer) (Writer, error) {
+	return &zipWriter{zip.NewWriter(w)}, nil
+}
+
+// Create adds a new deflated entry to the zip archive
+func (w *zipWriter) Create(name string, size int64, modTime time.Time) (io.Writer, error) {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	}
+	return w.CreateHeader(header)
+}
+
+type tarWriter struct {
+	*tar.Writer
+	compressor io.WriteCloser
+}
+
+func newTarWriter(w io.Writer) (Writer, error) {
+	return &tarWriter{Writer: tar.NewWriter(w)}, nil
+}
+
+func newTarGzipWriter(w io.Writer) (Writer, error) {
+	compressor := gzip.NewWriter(w)
+	return &tarWriter{Writer: tar.NewWriter(compressor), compressor: compressor}, nil
+}
+
+func newTarZstdWriter(w io.Writer) (Writer, error) {
+	compressor, err := zstd.NewWriter(w)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create zstd compressor : %s", err)
+	}
+	return &tarWriter{Writer: tar.NewWriter(compressor), compressor: compressor}, nil
+}
+
+// Create adds a new regular file entry to the tar archive
+func (w *tarWriter) Create(name string, size int64, modTime time.Time) (io.Writer, error) {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  modTime,
+	}
+
+	err := w.WriteHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.Writer, nil
+}
+
+// Close the tar archive then the compressor
+func (w *tarWriter) Close() error {
+	err := w.Writer.Close()
+	if w.compressor != nil {
+		errCompressor := w.compressor.Close()
+		if err == nil {
+			err = errCompressor
+		}
+	}
+	return err
+}
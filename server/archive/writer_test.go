@@ -0,0 +1,124 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFormat(t *testing.T) {
+	require.Equal(t, ".zip", GetFormat("archive.zip").Extension, "invalid format")
+	require.Equal(t, ".tar", GetFormat("archive.tar").Extension, "invalid format")
+	require.Equal(t, ".tar.gz", GetFormat("archive.tar.gz").Extension, "invalid format")
+	require.Equal(t, ".tgz", GetFormat("archive.tgz").Extension, "invalid format")
+	require.Equal(t, ".tar.zst", GetFormat("archive.tar.zst").Extension, "invalid format")
+	require.Nil(t, GetFormat("archive.rar"), "invalid format")
+	require.Nil(t, GetFormat("archive.gz"), "invalid format")
+	require.Nil(t, GetFormat("zip"), "invalid format")
+}
+
+func TestGetExtensions(t *testing.T) {
+	require.Len(t, GetExtensions(), len(Formats), "invalid extension count")
+	require.Contains(t, GetExtensions(), ".zip", "missing extension")
+}
+
+func writeTestArchive(t *testing.T, format *Format, files map[string]string) []byte {
+	buffer := new(bytes.Buffer)
+	writer, err := format.NewWriter(buffer)
+	require.NoError(t, err, "unable to create archive writer")
+
+	for name, content := range files {
+		fileWriter, err := writer.Create(name, int64(len(content)), time.Now())
+		require.NoError(t, err, "unable to create archive entry")
+
+		_, err = io.WriteString(fileWriter, content)
+		require.NoError(t, err, "unable to write archive entry")
+	}
+
+	err = writer.Close()
+	require.NoError(t, err, "unable to close archive")
+
+	return buffer.Bytes()
+}
+
+func readTestTarArchive(t *testing.T, reader io.Reader) map[string]string {
+	entries := make(map[string]string)
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err, "unable to read tar archive")
+
+		content, err := ioutil.ReadAll(tarReader)
+		require.NoError(t, err, "unable to read tar entry")
+		entries[header.Name] = string(content)
+	}
+	return entries
+}
+
+var testFiles = map[string]string{
+	"file":         "data",
+	"foo/bar/file": "data data data",
+}
+
+func TestZipWriter(t *testing.T) {
+	data := writeTestArchive(t, GetFormat(".zip"), testFiles)
+
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err, "unable to read zip archive")
+	require.Len(t, z.File, len(testFiles), "invalid entry count")
+
+	for _, f := range z.File {
+		require.Equal(t, zip.Deflate, f.Method, "invalid compression method")
+
+		reader, err := f.Open()
+		require.NoError(t, err, "unable to open zip entry")
+
+		content, err := ioutil.ReadAll(reader)
+		require.NoError(t, err, "unable to read zip entry")
+		require.Equal(t, testFiles[f.Name], string(content), "invalid entry content")
+	}
+}
+
+func TestTarWriter(t *testing.T) {
+	data := writeTestArchive(t, GetFormat(".tar"), testFiles)
+	require.Equal(t, testFiles, readTestTarArchive(t, bytes.NewReader(data)), "invalid archive content")
+}
+
+func TestTarGzipWriter(t *testing.T) {
+	data := writeTestArchive(t, GetFormat(".tar.gz"), testFiles)
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err, "unable to create gzip reader")
+	require.Equal(t, testFiles, readTestTarArchive(t, reader), "invalid archive content")
+}
+
+func TestTarZstdWriter(t *testing.T) {
+	data := writeTestArchive(t, GetFormat(".tar.zst"), testFiles)
+
+	reader, err := zstd.NewReader(bytes.NewReader(data))
+	require.NoError(t, err, "unable to create zstd reader")
+	defer reader.Close()
+	require.Equal(t, testFiles, readTestTarArchive(t, reader), "invalid archive content")
+}
+
+func TestTarWriterSizeMismatch(t *testing.T) {
+	writer, err := GetFormat(".tar").NewWriter(ioutil.Discard)
+	require.NoError(t, err, "unable to create archive writer")
+
+	fileWriter, err := writer.Create("file", 2, time.Now())
+	require.NoError(t, err, "unable to create archive entry")
+
+	_, err = io.WriteString(fileWriter, "too long")
+	require.Error(t, err, "missing error")
+}
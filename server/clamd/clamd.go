@@ -0,0 +1,213 @@
+package clamd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// chunkSize is the size of the INSTREAM chunks sent to clamd
+const chunkSize = 32768
+
+// SizeLimitSignature is the virus name of the files quarantined because they are too big to be scanned
+const SizeLimitSignature = "Plik.SizeLimitExceeded"
+
+// ErrSizeLimitExceeded is returned when a stream is bigger than what clamd ( or the client ) accepts to scan.
+// Scanning the same file again will fail the same way.
+var ErrSizeLimitExceeded = errors.New("clamd stream size limit exceeded")
+
+// Result of a clamd scan
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Client talks to a clamd daemon over a unix or a TCP socket
+type Client struct {
+	network string
+	address string
+	timeout time.Duration
+	maxSize int64
+}
+
+// NewClient create a new clamd client
+// address is either unix:///path/to/clamd.socket or tcp://host:port
+// Streams bigger than maxSize bytes are not sent to clamd ( 0 for no limit )
+func NewClient(address string, timeout time.Duration, maxSize int64) (client *Client, err error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid clamd address %s : %s", address, err)
+	}
+
+	client = &Client{timeout: timeout, maxSize: maxSize}
+	switch u.Scheme {
+	case "unix":
+		client.network = "unix"
+		client.address = u.Path
+	case "tcp":
+		client.network = "tcp"
+		client.address = u.Host
+	default:
+		return nil, fmt.Errorf("invalid clamd address %s, expected unix:///path or tcp://host:port", address)
+	}
+
+	if client.address == "" {
+		return nil, fmt.Errorf("invalid clamd address %s", address)
+	}
+
+	return client, nil
+}
+
+// Ping check that clamd is reachable
+func (c *Client) Ping() (err error) {
+	response, err := c.command("PING")
+	if err != nil {
+		return err
+	}
+	if response != "PONG" {
+		return fmt.Errorf("unexpected clamd response : %s", response)
+	}
+	return nil
+}
+
+// Version return the clamd version string ( ex : ClamAV 0.103.2/26200/Mon Jun 14 08:01:48 2021 )
+func (c *Client) Version() (version string, err error) {
+	return c.command("VERSION")
+}
+
+// SignaturesVersion extract the signature database version from a clamd version string
+func SignaturesVersion(version string) string {
+	fields := strings.Split(version, "/")
+	if len(fields) < 2 {
+		return version
+	}
+	return fields[1]
+}
+
+// MaxSize return the maximum size of the streams sent to clamd ( 0 for no limit )
+func (c *Client) MaxSize() int64 {
+	return c.maxSize
+}
+
+// Scan stream the reader content to clamd using the INSTREAM command
+// ErrSizeLimitExceeded is returned if the reader content is bigger than the client or clamd limit
+func (c *Client) Scan(reader io.Reader) (result *Result, err error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.Write([]byte("zINSTREAM\x00"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to send clamd command : %s", err)
+	}
+
+	buf := make([]byte, chunkSize)
+	size := make([]byte, 4)
+	var sent int64
+	for {
+		n, errRead := reader.Read(buf)
+		if n > 0 {
+			sent += int64(n)
+			if c.maxSize > 0 && sent > c.maxSize {
+				return nil, ErrSizeLimitExceeded
+			}
+
+			c.extendDeadline(conn)
+			binary.BigEndian.PutUint32(size, uint32(n))
+			_, err = conn.Write(append(size, buf[:n]...))
+			if err != nil {
+				// clamd closes the connection when the stream is too big, try to get the reason
+				if response, errResponse := c.readResponse(conn); errResponse == nil {
+					return parseScanResponse(response)
+				}
+				return nil, fmt.Errorf("unable to send data to clamd : %s", err)
+			}
+		}
+		if errRead == io.EOF {
+			break
+		}
+		if errRead != nil {
+			return nil, fmt.Errorf("unable to read data to scan : %s", errRead)
+		}
+	}
+
+	// A zero length chunk ends the stream
+	c.extendDeadline(conn)
+	_, err = conn.Write([]byte{0, 0, 0, 0})
+	if err != nil {
+		return nil, fmt.Errorf("unable to send data to clamd : %s", err)
+	}
+
+	response, err := c.readResponse(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseScanResponse(response)
+}
+
+// parseScanResponse parse an INSTREAM response ( stream: OK | stream: <signature> FOUND | <message> ERROR )
+func parseScanResponse(response string) (result *Result, err error) {
+	response = strings.TrimPrefix(response, "stream: ")
+	switch {
+	case response == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(response, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(response, " FOUND")}, nil
+	case strings.Contains(response, "size limit exceeded"):
+		// StreamMaxLength of clamd.conf
+		return nil, ErrSizeLimitExceeded
+	default:
+		return nil, fmt.Errorf("clamd error : %s", response)
+	}
+}
+
+// command send a simple command to clamd and return the response
+func (c *Client) command(command string) (response string, err error) {
+	conn, err := c.dial()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.Write([]byte("z" + command + "\x00"))
+	if err != nil {
+		return "", fmt.Errorf("unable to send clamd command : %s", err)
+	}
+
+	return c.readResponse(conn)
+}
+
+func (c *Client) dial() (conn net.Conn, err error) {
+	conn, err = net.DialTimeout(c.network, c.address, c.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to clamd : %s", err)
+	}
+	c.extendDeadline(conn)
+	return conn, nil
+}
+
+// readResponse read a null terminated clamd response
+func (c *Client) readResponse(conn net.Conn) (response string, err error) {
+	c.extendDeadline(conn)
+	response, err = bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(err == io.EOF && len(response) > 0) {
+		return "", fmt.Errorf("unable to read clamd response : %s", err)
+	}
+	return string(bytes.TrimRight([]byte(response), "\x00\n")), nil
+}
+
+func (c *Client) extendDeadline(conn net.Conn) {
+	if c.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+}
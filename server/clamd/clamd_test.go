@@ -0,0 +1,127 @@
+package clamd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	clamd_test "github.com/root-gg/plik/server/clamd/testing"
+)
+
+func newTestClient(t *testing.T) (client *Client, server *clamd_test.Server) {
+	server, err := clamd_test.NewServer()
+	require.NoError(t, err, "unable to start fake clamd")
+	t.Cleanup(func() { _ = server.Close() })
+
+	client, err = NewClient(server.Address(), time.Second, 0)
+	require.NoError(t, err, "unable to create clamd client")
+
+	return client, server
+}
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient("tcp://127.0.0.1:3310", time.Second, 0)
+	require.NoError(t, err)
+	require.Equal(t, "tcp", client.network)
+	require.Equal(t, "127.0.0.1:3310", client.address)
+
+	client, err = NewClient("unix:///var/run/clamd.sock", time.Second, 0)
+	require.NoError(t, err)
+	require.Equal(t, "unix", client.network)
+	require.Equal(t, "/var/run/clamd.sock", client.address)
+
+	_, err = NewClient("127.0.0.1:3310", time.Second, 0)
+	require.Error(t, err)
+
+	_, err = NewClient("tcp://", time.Second, 0)
+	require.Error(t, err)
+}
+
+func TestPing(t *testing.T) {
+	client, _ := newTestClient(t)
+	require.NoError(t, client.Ping())
+}
+
+func TestPingUnreachable(t *testing.T) {
+	client, server := newTestClient(t)
+	require.NoError(t, server.Close())
+	require.Error(t, client.Ping())
+}
+
+func TestVersion(t *testing.T) {
+	client, server := newTestClient(t)
+
+	server.SetVersion("ClamAV 0.103.2/26201/Tue Jun 15 08:01:48 2021")
+	version, err := client.Version()
+	require.NoError(t, err)
+	require.Equal(t, "ClamAV 0.103.2/26201/Tue Jun 15 08:01:48 2021", version)
+	require.Equal(t, "26201", SignaturesVersion(version))
+	require.Equal(t, "foo", SignaturesVersion("foo"))
+}
+
+func TestScanClean(t *testing.T) {
+	client, server := newTestClient(t)
+	server.AddSignature("EICAR", "Eicar-Signature")
+
+	result, err := client.Scan(bytes.NewBufferString("data data data"))
+	require.NoError(t, err)
+	require.False(t, result.Infected)
+}
+
+func TestScanInfected(t *testing.T) {
+	client, server := newTestClient(t)
+	server.AddSignature("EICAR", "Eicar-Signature")
+
+	// Big enough to be split in several chunks
+	data := strings.Repeat("x", 3*chunkSize) + "EICAR"
+	result, err := client.Scan(bytes.NewBufferString(data))
+	require.NoError(t, err)
+	require.True(t, result.Infected)
+	require.Equal(t, "Eicar-Signature", result.Signature)
+}
+
+func TestScanError(t *testing.T) {
+	client, server := newTestClient(t)
+	server.SetError("INSTREAM size limit exceeded.")
+
+	_, err := client.Scan(bytes.NewBufferString("data data data"))
+	require.Equal(t, ErrSizeLimitExceeded, err)
+
+	server.SetError("Can't allocate memory")
+	_, err = client.Scan(bytes.NewBufferString("data data data"))
+	require.Error(t, err)
+	require.NotEqual(t, ErrSizeLimitExceeded, err)
+}
+
+func TestScanMaxSize(t *testing.T) {
+	_, server := newTestClient(t)
+	client, err := NewClient(server.Address(), time.Second, int64(chunkSize))
+	require.NoError(t, err)
+
+	result, err := client.Scan(bytes.NewBufferString(strings.Repeat("x", chunkSize)))
+	require.NoError(t, err)
+	require.False(t, result.Infected)
+
+	_, err = client.Scan(bytes.NewBufferString(strings.Repeat("x", chunkSize+1)))
+	require.Equal(t, ErrSizeLimitExceeded, err)
+}
+
+func TestParseScanResponse(t *testing.T) {
+	result, err := parseScanResponse("stream: OK")
+	require.NoError(t, err)
+	require.False(t, result.Infected)
+
+	result, err = parseScanResponse("stream: Win.Test.EICAR_HDB-1 FOUND")
+	require.NoError(t, err)
+	require.True(t, result.Infected)
+	require.Equal(t, "Win.Test.EICAR_HDB-1", result.Signature)
+
+	_, err = parseScanResponse("stream: Can't allocate memory ERROR")
+	require.Error(t, err)
+
+	_, err = parseScanResponse("INSTREAM size limit exceeded. ERROR")
+	require.Equal(t, ErrSizeLimitExceeded, err)
+}
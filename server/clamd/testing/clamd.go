@@ -0,0 +1,154 @@
+package testing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Server is a fake clamd daemon speaking the clamd protocol over a local TCP socket
+// Streams containing one of the signature patterns are reported as infected
+type Server struct {
+	listener   net.Listener
+	version    string
+	signatures map[string]string
+	err        string
+	scans      int
+	mu         sync.Mutex
+}
+
+// NewServer start a new fake clamd server
+func NewServer() (s *Server, err error) {
+	s = &Server{version: "ClamAV 0.103.2/26200/Mon Jun 14 08:01:48 2021", signatures: make(map[string]string)}
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	go s.serve()
+	return s, nil
+}
+
+// Address return the address to configure the clamd client with
+func (s *Server) Address() string {
+	return "tcp://" + s.listener.Addr().String()
+}
+
+// AddSignature report the streams containing pattern as infected by signature
+func (s *Server) AddSignature(pattern string, signature string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signatures[pattern] = signature
+}
+
+// SetVersion change the version string returned by the VERSION command
+func (s *Server) SetVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
+// SetError make the INSTREAM command fail with message
+func (s *Server) SetError(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = message
+}
+
+// Scans return the number of INSTREAM commands received
+func (s *Server) Scans() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scans
+}
+
+// Close stop the fake clamd server
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	reader := bufio.NewReader(conn)
+	command, err := reader.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	version := s.version
+	s.mu.Unlock()
+
+	switch strings.TrimSuffix(command, "\x00") {
+	case "zPING":
+		_, _ = conn.Write([]byte("PONG\x00"))
+	case "zVERSION":
+		_, _ = conn.Write([]byte(version + "\x00"))
+	case "zINSTREAM":
+		data, err := readStream(reader)
+		if err != nil {
+			_, _ = conn.Write([]byte(fmt.Sprintf("%s ERROR\x00", err)))
+			return
+		}
+		_, _ = conn.Write([]byte("stream: " + s.scan(data) + "\x00"))
+	default:
+		_, _ = conn.Write([]byte("UNKNOWN COMMAND\x00"))
+	}
+}
+
+func (s *Server) scan(data []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scans++
+	if s.err != "" {
+		return s.err + " ERROR"
+	}
+
+	for pattern, signature := range s.signatures {
+		if bytes.Contains(data, []byte(pattern)) {
+			return signature + " FOUND"
+		}
+	}
+
+	return "OK"
+}
+
+// readStream read INSTREAM chunks until the zero length chunk
+func readStream(reader io.Reader) (data []byte, err error) {
+	size := make([]byte, 4)
+	for {
+		_, err = io.ReadFull(reader, size)
+		if err != nil {
+			return nil, err
+		}
+
+		length := binary.BigEndian.Uint32(size)
+		if length == 0 {
+			return data, nil
+		}
+
+		chunk := make([]byte, length)
+		_, err = io.ReadFull(reader, chunk)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+}
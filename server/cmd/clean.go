@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/server"
+)
+
+type cleanFlagParams struct {
+	dryRun    bool
+	batchSize int
+}
+
+var cleanParams = cleanFlagParams{}
+
+// cleanCmd represents the clean command
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Delete expired uploads and files",
+	Run:   clean,
+}
+
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+
+	// Here you will define your flags and configuration settings.
+	cleanCmd.Flags().BoolVar(&cleanParams.dryRun, "dry-run", false, "only display what would be deleted")
+	cleanCmd.Flags().IntVar(&cleanParams.batchSize, "batch-size", -1, "maximum number of uploads/files deleted at each step ( default CleaningBatchSize, 0 for no limit )")
+}
+
+func clean(cmd *cobra.Command, args []string) {
+	initializeMetadataBackend()
+
+	stats, err := metadataBackend.GetCleaningStatistics()
+	if err != nil {
+		fmt.Printf("Unable to get cleaning statistics : %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d expired uploads ( %d files, %s )\n", stats.ExpiredUploads, stats.ExpiredFiles, humanize.Bytes(uint64(stats.ExpiredSize)))
+	fmt.Printf("%d removed files ( %s )\n", stats.RemovedFiles, humanize.Bytes(uint64(stats.RemovedSize)))
+	fmt.Printf("%d deleted uploads\n", stats.DeletedUploads)
+	fmt.Printf("%d expired upload requests\n", stats.UploadRequests)
+	fmt.Printf("%s would be freed\n", humanize.Bytes(uint64(stats.ExpiredSize+stats.RemovedSize)))
+
+	if cleanParams.dryRun {
+		return
+	}
+
+	if cleanParams.batchSize >= 0 {
+		config.CleaningBatchSize = cleanParams.batchSize
+	}
+
+	plik := server.NewPlikServer(config)
+	plik.WithMetadataBackend(metadataBackend)
+
+	initializeDataBackend()
+	plik.WithDataBackend(dataBackend)
+
+	// Don't race with the Plik instance in charge of the cleaning
+	instanceID := "plikd-clean-" + common.GenerateRandomID(8)
+	leaseDuration := 2 * time.Duration(config.CleaningInterval+config.CleaningRandomDelay) * time.Second
+	acquired, err := metadataBackend.AcquireLease(common.CleaningLeaseSettingKey, instanceID, leaseDuration)
+	if err != nil {
+		fmt.Printf("Unable to acquire cleaning lease : %s\n", err)
+		os.Exit(1)
+	}
+	if !acquired {
+		fmt.Printf("Another Plik instance is cleaning expired uploads, please try again later\n")
+		os.Exit(1)
+	}
+	defer func() {
+		err := metadataBackend.ReleaseLease(common.CleaningLeaseSettingKey, instanceID)
+		if err != nil {
+			fmt.Printf("Unable to release cleaning lease : %s\n", err)
+		}
+	}()
+
+	plik.Clean()
+}
@@ -0,0 +1,449 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/root-gg/utils"
+	"github.com/spf13/cobra"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+type groupFlagParams struct {
+	group      string
+	name       string
+	maxUploads int
+	maxSize    int64
+	provider   string
+	login      string
+	role       string
+	comment    string
+	token      string
+}
+
+var groupParams = groupFlagParams{}
+
+// groupCmd represents all group command
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Manipulate groups",
+}
+
+// createGroupCmd represents the "group create" command
+var createGroupCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create group",
+	Run:   createGroup,
+}
+
+// listGroupsCmd represents the "group list" command
+var listGroupsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List groups",
+	Run:   listGroups,
+}
+
+// showGroupCmd represents the "group show" command
+var showGroupCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show group info, members and statistics",
+	Run:   showGroup,
+}
+
+// updateGroupCmd represents the "group update" command
+var updateGroupCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update group name and quotas",
+	Run:   updateGroup,
+}
+
+// deleteGroupCmd represents the "group delete" command
+var deleteGroupCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete group and all group uploads",
+	Run:   deleteGroup,
+}
+
+// addGroupMemberCmd represents the "group add-member" command
+var addGroupMemberCmd = &cobra.Command{
+	Use:   "add-member",
+	Short: "Add a user to a group or update the role of a group member",
+	Run:   addGroupMember,
+}
+
+// removeGroupMemberCmd represents the "group remove-member" command
+var removeGroupMemberCmd = &cobra.Command{
+	Use:   "remove-member",
+	Short: "Remove a user from a group",
+	Run:   removeGroupMember,
+}
+
+// createGroupTokenCmd represents the "group create-token" command
+var createGroupTokenCmd = &cobra.Command{
+	Use:   "create-token",
+	Short: "Create group token",
+	Run:   createGroupToken,
+}
+
+// deleteGroupTokenCmd represents the "group delete-token" command
+var deleteGroupTokenCmd = &cobra.Command{
+	Use:   "delete-token",
+	Short: "Delete group token",
+	Run:   deleteGroupToken,
+}
+
+func init() {
+	rootCmd.AddCommand(groupCmd)
+
+	groupCmd.AddCommand(createGroupCmd)
+	createGroupCmd.Flags().StringVar(&groupParams.name, "name", "", "group name")
+	createGroupCmd.Flags().IntVar(&groupParams.maxUploads, "max-uploads", 0, "maximum number of group uploads (0 for unlimited)")
+	createGroupCmd.Flags().Int64Var(&groupParams.maxSize, "max-size", 0, "maximum total size of group uploads in bytes (0 for unlimited)")
+
+	groupCmd.AddCommand(listGroupsCmd)
+
+	groupCmd.AddCommand(showGroupCmd)
+	showGroupCmd.Flags().StringVar(&groupParams.group, "group", "", "group id or name")
+
+	groupCmd.AddCommand(updateGroupCmd)
+	updateGroupCmd.Flags().StringVar(&groupParams.group, "group", "", "group id or name")
+	updateGroupCmd.Flags().StringVar(&groupParams.name, "name", "", "new group name")
+	updateGroupCmd.Flags().IntVar(&groupParams.maxUploads, "max-uploads", 0, "maximum number of group uploads (0 for unlimited)")
+	updateGroupCmd.Flags().Int64Var(&groupParams.maxSize, "max-size", 0, "maximum total size of group uploads in bytes (0 for unlimited)")
+
+	groupCmd.AddCommand(deleteGroupCmd)
+	deleteGroupCmd.Flags().StringVar(&groupParams.group, "group", "", "group id or name")
+
+	groupCmd.AddCommand(addGroupMemberCmd)
+	addGroupMemberCmd.Flags().StringVar(&groupParams.group, "group", "", "group id or name")
+	addGroupMemberCmd.Flags().StringVar(&groupParams.provider, "provider", common.ProviderLocal, "user provider [local|google|ovh|cert]")
+	addGroupMemberCmd.Flags().StringVar(&groupParams.login, "login", "", "user login")
+	addGroupMemberCmd.Flags().StringVar(&groupParams.role, "role", common.GroupRoleMember, "member role [member|manager]")
+
+	groupCmd.AddCommand(removeGroupMemberCmd)
+	removeGroupMemberCmd.Flags().StringVar(&groupParams.group, "group", "", "group id or name")
+	removeGroupMemberCmd.Flags().StringVar(&groupParams.provider, "provider", common.ProviderLocal, "user provider [local|google|ovh|cert]")
+	removeGroupMemberCmd.Flags().StringVar(&groupParams.login, "login", "", "user login")
+
+	groupCmd.AddCommand(createGroupTokenCmd)
+	createGroupTokenCmd.Flags().StringVar(&groupParams.group, "group", "", "group id or name")
+	createGroupTokenCmd.Flags().StringVar(&groupParams.comment, "comment", "", "token comment")
+
+	groupCmd.AddCommand(deleteGroupTokenCmd)
+	deleteGroupTokenCmd.Flags().StringVar(&groupParams.token, "token", "", "token")
+}
+
+func createGroup(cmd *cobra.Command, args []string) {
+	if !config.Authentication {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	group := common.NewGroup(groupParams.name)
+	group.MaxUploads = groupParams.maxUploads
+	group.MaxSize = groupParams.maxSize
+
+	err := group.Validate()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	other, err := metadataBackend.GetGroupByName(group.Name)
+	if err != nil {
+		fmt.Printf("Unable to get group : %s\n", err)
+		os.Exit(1)
+	}
+	if other != nil {
+		fmt.Println("Group already exists")
+		os.Exit(1)
+	}
+
+	err = metadataBackend.CreateGroup(group)
+	if err != nil {
+		fmt.Printf("Unable to create group : %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Group %s created : %s\n", group.Name, group.ID)
+}
+
+func listGroups(cmd *cobra.Command, args []string) {
+	if !config.Authentication {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	f := func(group *common.Group) error {
+		fmt.Printf("%s %s max uploads : %d, max size : %d\n", group.ID, group.Name, group.MaxUploads, group.MaxSize)
+		return nil
+	}
+
+	err := metadataBackend.ForEachGroup(f)
+	if err != nil {
+		fmt.Printf("Unable to get groups : %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func showGroup(cmd *cobra.Command, args []string) {
+	if !config.Authentication {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	group := getGroupFromParams()
+
+	var err error
+	group.Members, err = metadataBackend.GetGroupMembers(group.ID)
+	if err != nil {
+		fmt.Printf("Unable to get group members : %s\n", err)
+		os.Exit(1)
+	}
+
+	stats, err := metadataBackend.GetGroupStatistics(group)
+	if err != nil {
+		fmt.Printf("Unable to get group statistics : %s\n", err)
+		os.Exit(1)
+	}
+
+	utils.Dump(group)
+	utils.Dump(stats)
+}
+
+func updateGroup(cmd *cobra.Command, args []string) {
+	if !config.Authentication {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	group := getGroupFromParams()
+
+	if cmd.Flags().Changed("name") && groupParams.name != group.Name {
+		other, err := metadataBackend.GetGroupByName(groupParams.name)
+		if err != nil {
+			fmt.Printf("Unable to get group : %s\n", err)
+			os.Exit(1)
+		}
+		if other != nil {
+			fmt.Println("Group name already used")
+			os.Exit(1)
+		}
+		group.Name = groupParams.name
+	}
+	if cmd.Flags().Changed("max-uploads") {
+		group.MaxUploads = groupParams.maxUploads
+	}
+	if cmd.Flags().Changed("max-size") {
+		group.MaxSize = groupParams.maxSize
+	}
+
+	err := group.Validate()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = metadataBackend.UpdateGroup(group)
+	if err != nil {
+		fmt.Printf("Unable to update group : %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("group %s has been updated\n", group.Name)
+}
+
+func deleteGroup(cmd *cobra.Command, args []string) {
+	if !config.Authentication {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	group := getGroupFromParams()
+
+	// Ask confirmation
+	fmt.Printf("Do you really want to delete group %s and all its uploads ? [y/N]\n", group.Name)
+	ok, err := common.AskConfirmation(false)
+	if err != nil {
+		fmt.Printf("Unable to ask for confirmation : %s", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(0)
+	}
+
+	deleted, err := metadataBackend.DeleteGroup(group.ID)
+	if err != nil {
+		fmt.Printf("Unable to delete group : %s\n", err)
+		os.Exit(1)
+	}
+	if !deleted {
+		fmt.Printf("group %s not found\n", group.ID)
+		os.Exit(1)
+	}
+
+	fmt.Printf("group %s has been deleted\n", group.Name)
+}
+
+func addGroupMember(cmd *cobra.Command, args []string) {
+	if !config.Authentication {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	group := getGroupFromParams()
+	userID := getGroupMemberIDFromParams()
+
+	if !common.IsValidGroupRole(groupParams.role) {
+		fmt.Println("invalid role")
+		os.Exit(1)
+	}
+
+	user, err := metadataBackend.GetUser(userID)
+	if err != nil {
+		fmt.Printf("Unable to get user : %s\n", err)
+		os.Exit(1)
+	}
+	if user == nil {
+		fmt.Printf("User %s not found\n", userID)
+		os.Exit(1)
+	}
+
+	member := &common.GroupMember{GroupID: group.ID, UserID: user.ID, Role: groupParams.role}
+	err = metadataBackend.SaveGroupMember(member)
+	if err != nil {
+		fmt.Printf("Unable to save group member : %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("user %s is now a %s of group %s\n", user.ID, member.Role, group.Name)
+}
+
+func removeGroupMember(cmd *cobra.Command, args []string) {
+	if !config.Authentication {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	group := getGroupFromParams()
+	userID := getGroupMemberIDFromParams()
+
+	deleted, err := metadataBackend.DeleteGroupMember(group.ID, userID)
+	if err != nil {
+		fmt.Printf("Unable to delete group member : %s\n", err)
+		os.Exit(1)
+	}
+	if !deleted {
+		fmt.Printf("user %s is not a member of group %s\n", userID, group.Name)
+		os.Exit(1)
+	}
+
+	fmt.Printf("user %s has been removed from group %s\n", userID, group.Name)
+}
+
+func createGroupToken(cmd *cobra.Command, args []string) {
+	if !config.Authentication {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	group := getGroupFromParams()
+
+	token := group.NewToken()
+	token.Comment = groupParams.comment
+
+	err := metadataBackend.CreateGroupToken(token)
+	if err != nil {
+		fmt.Printf("Unable to create group token : %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Token created : %s\n", token.Token)
+}
+
+func deleteGroupToken(cmd *cobra.Command, args []string) {
+	if !config.Authentication {
+		fmt.Println("Authentication is disabled !")
+		os.Exit(1)
+	}
+
+	initializeMetadataBackend()
+
+	if groupParams.token == "" {
+		fmt.Println("missing token")
+		os.Exit(1)
+	}
+
+	deleted, err := metadataBackend.DeleteGroupToken(groupParams.token)
+	if err != nil {
+		fmt.Printf("Unable to delete group token : %s\n", err)
+		os.Exit(1)
+	}
+	if !deleted {
+		fmt.Printf("token %s not found\n", groupParams.token)
+		os.Exit(1)
+	}
+
+	fmt.Printf("token %s has been deleted\n", groupParams.token)
+}
+
+// getGroupFromParams return the group matching the --group flag by ID or by name
+func getGroupFromParams() (group *common.Group) {
+	if groupParams.group == "" {
+		fmt.Println("missing group")
+		os.Exit(1)
+	}
+
+	group, err := metadataBackend.GetGroup(groupParams.group)
+	if err != nil {
+		fmt.Printf("Unable to get group : %s\n", err)
+		os.Exit(1)
+	}
+
+	if group == nil {
+		group, err = metadataBackend.GetGroupByName(groupParams.group)
+		if err != nil {
+			fmt.Printf("Unable to get group : %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if group == nil {
+		fmt.Printf("Group %s not found\n", groupParams.group)
+		os.Exit(1)
+	}
+
+	return group
+}
+
+// getGroupMemberIDFromParams return the user ID from the --provider and --login flags
+func getGroupMemberIDFromParams() string {
+	if groupParams.login == "" {
+		fmt.Println("missing login")
+		os.Exit(1)
+	}
+
+	if !common.IsValidProvider(groupParams.provider) {
+		fmt.Println("invalid provider")
+		os.Exit(1)
+	}
+
+	return common.GetUserID(groupParams.provider, groupParams.login)
+}
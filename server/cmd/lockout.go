@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+type lockoutFlagParams struct {
+	provider string
+	login    string
+	uploadID string
+	ip       string
+	all      bool
+}
+
+var lockoutParams = lockoutFlagParams{}
+
+// lockoutCmd represents all lockout command
+var lockoutCmd = &cobra.Command{
+	Use:   "lockout",
+	Short: "Manipulate authentication lockouts",
+}
+
+// listLockoutsCmd represents the "lockout list" command
+var listLockoutsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List failed authentication attempts and lockouts",
+	Run:   listLockouts,
+}
+
+// clearLockoutCmd represents the "lockout clear" command
+var clearLockoutCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Clear failed authentication attempts and lockout of a user, an upload or a source IP",
+	Run:   clearLockout,
+}
+
+func init() {
+	rootCmd.AddCommand(lockoutCmd)
+
+	lockoutCmd.AddCommand(listLockoutsCmd)
+
+	lockoutCmd.AddCommand(clearLockoutCmd)
+	clearLockoutCmd.Flags().StringVar(&lockoutParams.provider, "provider", common.ProviderLocal, "user provider [local|google|ovh|cert]")
+	clearLockoutCmd.Flags().StringVar(&lockoutParams.login, "login", "", "user login")
+	clearLockoutCmd.Flags().StringVar(&lockoutParams.uploadID, "upload", "", "upload ID")
+	clearLockoutCmd.Flags().StringVar(&lockoutParams.ip, "ip", "", "source IP address")
+	clearLockoutCmd.Flags().BoolVar(&lockoutParams.all, "all", false, "clear all lockouts")
+}
+
+func listLockouts(cmd *cobra.Command, args []string) {
+	initializeMetadataBackend()
+
+	f := func(failure *common.AuthFailure) error {
+		status := "failing"
+		if failure.IsLocked() {
+			status = fmt.Sprintf("locked until %s", failure.LockedUntil.Format(time.RFC3339))
+		}
+		fmt.Printf("%s %d failures, last at %s, %s\n", failure.Key, failure.Count, failure.UpdatedAt.Format(time.RFC3339), status)
+		return nil
+	}
+
+	err := metadataBackend.ForEachAuthFailure(f)
+	if err != nil {
+		fmt.Printf("Unable to get authentication failures : %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func clearLockout(cmd *cobra.Command, args []string) {
+	initializeMetadataBackend()
+
+	var keys []string
+	if lockoutParams.login != "" {
+		if !common.IsValidProvider(lockoutParams.provider) {
+			fmt.Println("invalid provider")
+			os.Exit(1)
+		}
+		keys = append(keys, common.UserAuthFailureKey(common.GetUserID(lockoutParams.provider, lockoutParams.login)))
+	}
+	if lockoutParams.uploadID != "" {
+		keys = append(keys, common.UploadAuthFailureKey(lockoutParams.uploadID))
+	}
+	if lockoutParams.ip != "" {
+		keys = append(keys, common.IPAuthFailureKey(lockoutParams.ip))
+	}
+
+	if lockoutParams.all {
+		f := func(failure *common.AuthFailure) error {
+			keys = append(keys, failure.Key)
+			return nil
+		}
+		err := metadataBackend.ForEachAuthFailure(f)
+		if err != nil {
+			fmt.Printf("Unable to get authentication failures : %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if len(keys) == 0 {
+		fmt.Println("missing --login, --upload, --ip or --all")
+		os.Exit(1)
+	}
+
+	for _, key := range keys {
+		deleted, err := metadataBackend.DeleteAuthFailure(key)
+		if err != nil {
+			fmt.Printf("Unable to clear lockout of %s : %s\n", key, err)
+			os.Exit(1)
+		}
+		if deleted {
+			fmt.Printf("lockout of %s has been cleared\n", key)
+		} else {
+			fmt.Printf("no failed authentication attempt for %s\n", key)
+		}
+	}
+}
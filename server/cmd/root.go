@@ -145,7 +145,7 @@ func startPlikServer(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
@@ -0,0 +1,443 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/root-gg/utils"
+	"github.com/spf13/cobra"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+type uploadFlagParams struct {
+	uploadID  string
+	provider  string
+	login     string
+	token     string
+	anonymous bool
+	expired   bool
+	olderThan string
+	newerThan string
+	minSize   string
+	maxSize   string
+	ttl       string
+	output    string
+	json      bool
+	human     bool
+	dryRun    bool
+}
+
+var uploadParams = uploadFlagParams{}
+
+// uploadCmd represents all upload command
+var uploadCmd = &cobra.Command{
+	Use:   "upload",
+	Short: "Manipulate uploads",
+}
+
+// listUploadsCmd represents the "upload list" command
+var listUploadsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List uploads",
+	Run:   listUploads,
+}
+
+// showUploadCmd represents the "upload show" command
+var showUploadCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show upload info and files",
+	Run:   showUpload,
+}
+
+// deleteUploadsCmd represents the "upload delete" command
+var deleteUploadsCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete uploads",
+	Run:   deleteUploads,
+}
+
+// extendUploadsCmd represents the "upload extend" command
+var extendUploadsCmd = &cobra.Command{
+	Use:   "extend",
+	Short: "Change the expiration date of uploads",
+	Run:   extendUploads,
+}
+
+// exportUploadsCmd represents the "upload export" command
+var exportUploadsCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export uploads and files metadata as json",
+	Run:   exportUploads,
+}
+
+func init() {
+	rootCmd.AddCommand(uploadCmd)
+
+	// Here you will define your flags and configuration settings.
+	uploadCmd.PersistentFlags().StringVar(&uploadParams.uploadID, "upload", "", "upload ID")
+	uploadCmd.PersistentFlags().StringVar(&uploadParams.provider, "provider", common.ProviderLocal, "owner provider [local|google|ovh|cert]")
+	uploadCmd.PersistentFlags().StringVar(&uploadParams.login, "login", "", "filter by owner login")
+	uploadCmd.PersistentFlags().StringVar(&uploadParams.token, "token", "", "filter by token")
+	uploadCmd.PersistentFlags().BoolVar(&uploadParams.anonymous, "anonymous", false, "filter anonymous uploads")
+	uploadCmd.PersistentFlags().BoolVar(&uploadParams.expired, "expired", false, "filter expired uploads")
+	uploadCmd.PersistentFlags().StringVar(&uploadParams.olderThan, "older-than", "", "filter uploads created before this age ( ex : 30d, 12h )")
+	uploadCmd.PersistentFlags().StringVar(&uploadParams.newerThan, "newer-than", "", "filter uploads created after this age ( ex : 30d, 12h )")
+	uploadCmd.PersistentFlags().StringVar(&uploadParams.minSize, "min-size", "", "filter uploads bigger than this size ( ex : 1GB )")
+	uploadCmd.PersistentFlags().StringVar(&uploadParams.maxSize, "max-size", "", "filter uploads smaller than this size ( ex : 10MB )")
+
+	uploadCmd.AddCommand(listUploadsCmd)
+	listUploadsCmd.Flags().BoolVar(&uploadParams.json, "json", false, "json output")
+	listUploadsCmd.Flags().BoolVar(&uploadParams.human, "human", true, "human readable size")
+
+	uploadCmd.AddCommand(showUploadCmd)
+	showUploadCmd.Flags().BoolVar(&uploadParams.json, "json", false, "json output")
+
+	uploadCmd.AddCommand(deleteUploadsCmd)
+	deleteUploadsCmd.Flags().BoolVar(&uploadParams.dryRun, "dry-run", false, "only display the uploads that would be deleted")
+
+	uploadCmd.AddCommand(extendUploadsCmd)
+	extendUploadsCmd.Flags().StringVar(&uploadParams.ttl, "ttl", "", "new time to live from now ( ex : 30d, 12h, -1 for no expiration )")
+	extendUploadsCmd.Flags().BoolVar(&uploadParams.dryRun, "dry-run", false, "only display the uploads that would be extended")
+
+	uploadCmd.AddCommand(exportUploadsCmd)
+	exportUploadsCmd.Flags().StringVar(&uploadParams.output, "output", "", "output file ( default stdout )")
+}
+
+func listUploads(cmd *cobra.Command, args []string) {
+	initializeMetadataBackend()
+
+	uploads := getUploads(cmd, true)
+
+	if uploadParams.json {
+		printJSON(os.Stdout, uploads)
+		return
+	}
+
+	for _, upload := range uploads {
+		fmt.Println(formatUpload(upload, uploadParams.human))
+	}
+}
+
+func showUpload(cmd *cobra.Command, args []string) {
+	initializeMetadataBackend()
+
+	if uploadParams.uploadID == "" {
+		fmt.Println("missing upload id")
+		os.Exit(1)
+	}
+
+	uploads := getUploads(cmd, true)
+	upload := uploads[0]
+
+	if uploadParams.json {
+		printJSON(os.Stdout, upload)
+		return
+	}
+
+	utils.Dump(upload)
+}
+
+func deleteUploads(cmd *cobra.Command, args []string) {
+	initializeMetadataBackend()
+
+	uploads := getUploads(cmd, true)
+	if len(uploads) == 0 {
+		fmt.Println("No upload to delete")
+		return
+	}
+
+	for _, upload := range uploads {
+		fmt.Println(formatUpload(upload, true))
+	}
+
+	if uploadParams.dryRun {
+		fmt.Printf("%d uploads would be deleted\n", len(uploads))
+		return
+	}
+
+	// Ask confirmation
+	fmt.Printf("Do you really want to delete those %d uploads ? [y/N]\n", len(uploads))
+	ok, err := common.AskConfirmation(false)
+	if err != nil {
+		fmt.Printf("Unable to ask for confirmation : %s", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(0)
+	}
+
+	// Soft delete the uploads, files are then removed from the data backend by the server cleaning routine
+	deleted := 0
+	for _, upload := range uploads {
+		err = metadataBackend.DeleteUpload(upload.ID)
+		if err != nil {
+			fmt.Printf("Unable to delete upload %s : %s\n", upload.ID, err)
+			continue
+		}
+		deleted++
+	}
+
+	fmt.Printf("%d uploads deleted\n", deleted)
+	if deleted != len(uploads) {
+		os.Exit(1)
+	}
+}
+
+func extendUploads(cmd *cobra.Command, args []string) {
+	initializeMetadataBackend()
+
+	if uploadParams.ttl == "" {
+		fmt.Println("missing ttl")
+		os.Exit(1)
+	}
+
+	ttl := -1
+	if uploadParams.ttl != "-1" {
+		duration, err := parseAge(uploadParams.ttl)
+		if err != nil || duration < time.Second {
+			fmt.Printf("Invalid ttl %s\n", uploadParams.ttl)
+			os.Exit(1)
+		}
+		ttl = int(duration / time.Second)
+	}
+
+	uploads := getUploads(cmd, true)
+	if len(uploads) == 0 {
+		fmt.Println("No upload to extend")
+		return
+	}
+
+	var expireAt *time.Time
+	if ttl > 0 {
+		deadline := time.Now().Add(time.Duration(ttl) * time.Second)
+		expireAt = &deadline
+	}
+
+	for _, upload := range uploads {
+		fmt.Println(formatUpload(upload, true))
+	}
+
+	if uploadParams.dryRun {
+		fmt.Printf("%d uploads would expire %s\n", len(uploads), formatExpireAt(expireAt))
+		return
+	}
+
+	// Ask confirmation
+	fmt.Printf("Do you really want to set the expiration date of those %d uploads to %s ? [y/N]\n", len(uploads), formatExpireAt(expireAt))
+	ok, err := common.AskConfirmation(false)
+	if err != nil {
+		fmt.Printf("Unable to ask for confirmation : %s", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(0)
+	}
+
+	extended := 0
+	for _, upload := range uploads {
+		err = metadataBackend.UpdateUploadExpiration(upload.ID, ttl, expireAt)
+		if err != nil {
+			fmt.Printf("Unable to update upload %s : %s\n", upload.ID, err)
+			continue
+		}
+		extended++
+	}
+
+	fmt.Printf("%d uploads will expire %s\n", extended, formatExpireAt(expireAt))
+	if extended != len(uploads) {
+		os.Exit(1)
+	}
+}
+
+func exportUploads(cmd *cobra.Command, args []string) {
+	initializeMetadataBackend()
+
+	uploads := getUploads(cmd, true)
+
+	var output io.Writer = os.Stdout
+	if uploadParams.output != "" {
+		file, err := os.Create(uploadParams.output)
+		if err != nil {
+			fmt.Printf("Unable to create output file : %s\n", err)
+			os.Exit(1)
+		}
+		defer func() { _ = file.Close() }()
+		output = file
+	}
+
+	printJSON(output, uploads)
+
+	if uploadParams.output != "" {
+		fmt.Printf("exported %d uploads\n", len(uploads))
+	}
+}
+
+// getUploads return the upload matching the --upload flag or all the uploads matching the filter flags
+func getUploads(cmd *cobra.Command, withFiles bool) (uploads []*common.Upload) {
+	if uploadParams.uploadID != "" {
+		upload, err := metadataBackend.GetUpload(uploadParams.uploadID)
+		if err != nil {
+			fmt.Printf("Unable to get upload : %s\n", err)
+			os.Exit(1)
+		}
+		if upload == nil {
+			fmt.Printf("Upload %s not found\n", uploadParams.uploadID)
+			os.Exit(1)
+		}
+		if withFiles {
+			upload.Files, err = metadataBackend.GetFiles(upload.ID)
+			if err != nil {
+				fmt.Printf("Unable to get upload files : %s\n", err)
+				os.Exit(1)
+			}
+		}
+		return []*common.Upload{upload}
+	}
+
+	filter, err := getUploadFilter(cmd)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	pagingQuery := common.NewPagingQuery().WithLimit(100).WithOrder("asc")
+	for {
+		page, cursor, err := metadataBackend.SearchUploads(filter, withFiles, pagingQuery)
+		if err != nil {
+			fmt.Printf("Unable to get uploads : %s\n", err)
+			os.Exit(1)
+		}
+		uploads = append(uploads, page...)
+		if cursor.After == nil {
+			break
+		}
+		pagingQuery.WithAfterCursor(*cursor.After)
+	}
+
+	return uploads
+}
+
+// getUploadFilter build the upload filter from the command line flags
+func getUploadFilter(cmd *cobra.Command) (filter *common.UploadFilter, err error) {
+	filter = &common.UploadFilter{}
+
+	if uploadParams.login != "" {
+		if !common.IsValidProvider(uploadParams.provider) {
+			return nil, fmt.Errorf("invalid provider")
+		}
+		filter.User = common.GetUserID(uploadParams.provider, uploadParams.login)
+	}
+
+	filter.Token = uploadParams.token
+
+	if cmd.Flags().Changed("anonymous") {
+		filter.Anonymous = &uploadParams.anonymous
+	}
+
+	if cmd.Flags().Changed("expired") {
+		filter.Expired = &uploadParams.expired
+	}
+
+	if uploadParams.olderThan != "" {
+		age, err := parseAge(uploadParams.olderThan)
+		if err != nil {
+			return nil, fmt.Errorf("invalid older than age : %s", err)
+		}
+		date := time.Now().Add(-age)
+		filter.CreatedBefore = &date
+	}
+
+	if uploadParams.newerThan != "" {
+		age, err := parseAge(uploadParams.newerThan)
+		if err != nil {
+			return nil, fmt.Errorf("invalid newer than age : %s", err)
+		}
+		date := time.Now().Add(-age)
+		filter.CreatedAfter = &date
+	}
+
+	if uploadParams.minSize != "" {
+		size, err := humanize.ParseBytes(uploadParams.minSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min size : %s", err)
+		}
+		filter.MinSize = int64(size)
+	}
+
+	if uploadParams.maxSize != "" {
+		size, err := humanize.ParseBytes(uploadParams.maxSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max size : %s", err)
+		}
+		filter.MaxSize = int64(size)
+	}
+
+	err = filter.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// parseAge parse a duration, a "d" suffix can be used for days
+func parseAge(value string) (age time.Duration, err error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", value)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(value)
+}
+
+// formatUpload return a single line description of the upload
+func formatUpload(upload *common.Upload, human bool) string {
+	var size int64
+	for _, file := range upload.Files {
+		if file.Status == common.FileUploaded {
+			size += file.Size
+		}
+	}
+
+	var sizeStr string
+	if human {
+		sizeStr = humanize.Bytes(uint64(size))
+	} else {
+		sizeStr = fmt.Sprintf("%d", size)
+	}
+
+	owner := upload.User
+	if owner == "" {
+		owner = "anonymous"
+	}
+
+	return fmt.Sprintf("%s %s %s %d %s %s", upload.ID, upload.CreatedAt.Format(time.RFC3339), owner, len(upload.Files), sizeStr, formatExpireAt(upload.ExpireAt))
+}
+
+// formatExpireAt return a human readable expiration date
+func formatExpireAt(expireAt *time.Time) string {
+	if expireAt == nil {
+		return "never"
+	}
+	return expireAt.Format(time.RFC3339)
+}
+
+// printJSON print an indented json document
+func printJSON(w io.Writer, v interface{}) {
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Printf("Unable to serialize json : %s\n", err)
+		os.Exit(1)
+	}
+	_, _ = fmt.Fprintln(w, string(bytes))
+}
@@ -68,7 +68,7 @@ func init() {
 	rootCmd.AddCommand(userCmd)
 
 	// Here you will define your flags and configuration settings.
-	userCmd.PersistentFlags().StringVar(&userParams.provider, "provider", common.ProviderLocal, "user provider [local|google|ovh]")
+	userCmd.PersistentFlags().StringVar(&userParams.provider, "provider", common.ProviderLocal, "user provider [local|google|ovh|cert]")
 	userCmd.PersistentFlags().StringVar(&userParams.login, "login", "", "user login")
 
 	userCmd.AddCommand(createUserCmd)
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const aclUserPrefix = "user:"
+const aclGroupPrefix = "group:"
+
+// UploadACL grants access to a restricted upload to a user or to a group of users
+//
+// Subjects are formatted as :
+//  - user:<provider>:<login>   ( ex : user:local:bob )
+//  - group:<provider>:<group>  ( ex : group:google:example.com for every user of a Google domain )
+type UploadACL struct {
+	UploadID string `json:"uploadId" gorm:"primary_key"`
+	Subject  string `json:"subject" gorm:"primary_key;index:idx_upload_acl_subject"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ACLUserSubject return the access control list subject of a user
+func ACLUserSubject(userID string) string {
+	return aclUserPrefix + userID
+}
+
+// ACLGroupSubject return the access control list subject of a provider group
+func ACLGroupSubject(provider string, group string) string {
+	return aclGroupPrefix + provider + ":" + group
+}
+
+// ValidateACLSubject return an error if the access control list subject is malformed
+func ValidateACLSubject(subject string) error {
+	var value string
+	switch {
+	case strings.HasPrefix(subject, aclUserPrefix):
+		value = strings.TrimPrefix(subject, aclUserPrefix)
+	case strings.HasPrefix(subject, aclGroupPrefix):
+		value = strings.TrimPrefix(subject, aclGroupPrefix)
+	default:
+		return fmt.Errorf("invalid access control list subject %s, expecting user:<provider>:<login> or group:<provider>:<group>", subject)
+	}
+
+	fields := strings.SplitN(value, ":", 2)
+	if len(fields) != 2 || fields[1] == "" {
+		return fmt.Errorf("invalid access control list subject %s", subject)
+	}
+	if !IsValidProvider(fields[0]) {
+		return fmt.Errorf("invalid access control list subject %s : invalid provider %s", subject, fields[0])
+	}
+
+	// A group subject would never match if the provider doesn't tell which groups the users belong to
+	if strings.HasPrefix(subject, aclGroupPrefix) && !IsGroupProvider(fields[0]) {
+		return fmt.Errorf("invalid access control list subject %s : groups are not supported by the %s provider", subject, fields[0])
+	}
+
+	return nil
+}
+
+// IsGroupProvider return true if the users of the provider belong to groups matching the group subjects
+func IsGroupProvider(provider string) bool {
+	return provider == ProviderGoogle
+}
+
+// GetACLSubjects return the access control list subjects matching the user
+func (user *User) GetACLSubjects() (subjects []string) {
+	subjects = append(subjects, ACLUserSubject(user.ID))
+
+	// Google users belong to the group of their email domain
+	if user.Provider == ProviderGoogle {
+		if i := strings.LastIndex(user.Email, "@"); i >= 0 && i < len(user.Email)-1 {
+			subjects = append(subjects, ACLGroupSubject(ProviderGoogle, strings.ToLower(user.Email[i+1:])))
+		}
+	}
+
+	return subjects
+}
+
+// MatchACL return true if one of the subjects is granted access by the access control list
+func MatchACL(acl []string, subjects []string) bool {
+	for _, entry := range acl {
+		for _, subject := range subjects {
+			if entry == subject {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// prepareACL validate and deduplicate the upload access control list
+func (upload *Upload) prepareACL(config *Configuration) (err error) {
+	upload.Restricted = len(upload.ACL) > 0
+	if !upload.Restricted {
+		return nil
+	}
+
+	if !config.Authentication {
+		return fmt.Errorf("access control lists require authentication to be enabled")
+	}
+
+	var acl []string
+	seen := make(map[string]bool)
+	for _, subject := range upload.ACL {
+		subject = strings.TrimSpace(subject)
+		err = ValidateACLSubject(subject)
+		if err != nil {
+			return err
+		}
+		if !seen[subject] {
+			seen[subject] = true
+			acl = append(acl, subject)
+		}
+	}
+	upload.ACL = acl
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateACLSubject(t *testing.T) {
+	require.NoError(t, ValidateACLSubject("user:local:bob"), "valid user subject")
+	require.NoError(t, ValidateACLSubject("user:google:bob@example.com"), "valid user subject")
+	require.NoError(t, ValidateACLSubject("group:google:example.com"), "valid group subject")
+
+	require.Error(t, ValidateACLSubject("bob"), "missing subject type")
+	require.Error(t, ValidateACLSubject("user:bob"), "missing provider")
+	require.Error(t, ValidateACLSubject("user:local:"), "missing login")
+	require.Error(t, ValidateACLSubject("user:invalid:bob"), "invalid provider")
+	require.Error(t, ValidateACLSubject("group:local:team"), "groups not supported by the provider")
+	require.Error(t, ValidateACLSubject("group:ovh:team"), "groups not supported by the provider")
+}
+
+func TestUserGetACLSubjects(t *testing.T) {
+	user := NewUser(ProviderLocal, "bob")
+	require.Equal(t, []string{"user:local:bob"}, user.GetACLSubjects(), "invalid local user subjects")
+
+	user = NewUser(ProviderGoogle, "bob@Example.com")
+	user.Email = "bob@Example.com"
+	require.Equal(t, []string{"user:google:bob@Example.com", "group:google:example.com"}, user.GetACLSubjects(), "invalid google user subjects")
+}
+
+func TestMatchACL(t *testing.T) {
+	acl := []string{"user:local:alice", "group:google:example.com"}
+	require.True(t, MatchACL(acl, []string{"user:local:alice"}), "user should match")
+	require.True(t, MatchACL(acl, []string{"user:google:bob@example.com", "group:google:example.com"}), "group should match")
+	require.False(t, MatchACL(acl, []string{"user:local:bob"}), "user should not match")
+	require.False(t, MatchACL(nil, []string{"user:local:bob"}), "empty acl should not match")
+}
+
+func TestUploadPrepareInsertACL(t *testing.T) {
+	config := NewConfiguration()
+	config.Authentication = true
+
+	upload := &Upload{ACL: []string{"user:local:alice", " user:local:alice", "group:google:example.com"}}
+	err := upload.PrepareInsert(config)
+	require.NoError(t, err, "unable to prepare upload")
+	require.True(t, upload.Restricted, "upload should be restricted")
+	require.Equal(t, []string{"user:local:alice", "group:google:example.com"}, upload.ACL, "invalid upload acl")
+
+	upload = &Upload{Restricted: true}
+	err = upload.PrepareInsert(config)
+	require.NoError(t, err, "unable to prepare upload")
+	require.False(t, upload.Restricted, "upload should not be restricted")
+
+	upload = &Upload{ACL: []string{"invalid"}}
+	err = upload.PrepareInsert(config)
+	require.Error(t, err, "able to prepare upload with invalid acl")
+
+	config.Authentication = false
+	upload = &Upload{ACL: []string{"user:local:alice"}}
+	err = upload.PrepareInsert(config)
+	require.Error(t, err, "able to prepare restricted upload without authentication")
+}
@@ -0,0 +1,23 @@
+package common
+
+import (
+	"time"
+)
+
+// ArchiveInvalid when a file looks like an archive but its members can't be indexed
+const ArchiveInvalid = "invalid"
+
+// ArchiveMember is a regular file stored inside an uploaded zip or tar archive
+type ArchiveMember struct {
+	FileID string `json:"-" gorm:"unique_index:idx_archive_member;type:varchar(255) REFERENCES files(id) ON UPDATE RESTRICT ON DELETE RESTRICT"`
+	Index  int    `json:"index" gorm:"unique_index:idx_archive_member;column:member_index"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+
+	// Position of the member data inside the archive, used to extract a single member
+	CompressedSize int64  `json:"-"`
+	Method         uint16 `json:"-"`
+	Offset         int64  `json:"-"`
+
+	ModTime time.Time `json:"modTime"`
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"math"
+	"time"
+)
+
+// AuthFailure tracks the consecutive failed authentication attempts for an account, an upload or a source IP
+// It is stored in the metadata backend to be shared by all Plik instances
+type AuthFailure struct {
+	Key         string     `json:"key" gorm:"primary_key"`
+	Count       int        `json:"count"`
+	LockedUntil *time.Time `json:"lockedUntil"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"index:idx_auth_failure_updated_at"`
+}
+
+// UserAuthFailureKey return the auth failure key of a user account
+func UserAuthFailureKey(userID string) string {
+	return "user:" + userID
+}
+
+// UploadAuthFailureKey return the auth failure key of a password protected upload
+func UploadAuthFailureKey(uploadID string) string {
+	return "upload:" + uploadID
+}
+
+// UploadRequestAuthFailureKey return the auth failure key of a password protected upload request
+func UploadRequestAuthFailureKey(requestID string) string {
+	return "request:" + requestID
+}
+
+// IPAuthFailureKey return the auth failure key of a source IP address
+func IPAuthFailureKey(ip string) string {
+	return "ip:" + ip
+}
+
+// IsLocked return true if the authentication is temporarily locked
+func (failure *AuthFailure) IsLocked() bool {
+	return failure.LockedUntil != nil && time.Now().Before(*failure.LockedUntil)
+}
+
+// Lock compute the lockout deadline from the failure count
+// Once the threshold is reached the lockout duration doubles at each new failure up to maxDuration
+func (failure *AuthFailure) Lock(threshold int, duration time.Duration, maxDuration time.Duration) {
+	if threshold <= 0 || failure.Count < threshold {
+		return
+	}
+
+	exponent := float64(failure.Count - threshold)
+	lockout := time.Duration(math.Min(float64(duration)*math.Pow(2, exponent), float64(maxDuration)))
+
+	deadline := time.Now().Add(lockout)
+	failure.LockedUntil = &deadline
+}
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthFailureKeys(t *testing.T) {
+	require.Equal(t, "user:local:foo", UserAuthFailureKey(GetUserID(ProviderLocal, "foo")), "invalid user auth failure key")
+	require.Equal(t, "upload:id", UploadAuthFailureKey("id"), "invalid upload auth failure key")
+	require.Equal(t, "ip:1.2.3.4", IPAuthFailureKey("1.2.3.4"), "invalid ip auth failure key")
+}
+
+func TestAuthFailureLockUnderThreshold(t *testing.T) {
+	failure := &AuthFailure{Key: "key", Count: 2}
+	failure.Lock(3, time.Minute, time.Hour)
+	require.Nil(t, failure.LockedUntil, "unexpected lockout")
+	require.False(t, failure.IsLocked(), "unexpected lockout")
+}
+
+func TestAuthFailureLockDisabled(t *testing.T) {
+	failure := &AuthFailure{Key: "key", Count: 100}
+	failure.Lock(0, time.Minute, time.Hour)
+	require.False(t, failure.IsLocked(), "unexpected lockout")
+}
+
+func TestAuthFailureLockBackoff(t *testing.T) {
+	failure := &AuthFailure{Key: "key", Count: 3}
+	failure.Lock(3, time.Minute, time.Hour)
+	require.True(t, failure.IsLocked(), "missing lockout")
+	require.WithinDuration(t, time.Now().Add(time.Minute), *failure.LockedUntil, time.Second, "invalid lockout deadline")
+
+	failure.Count = 5
+	failure.Lock(3, time.Minute, time.Hour)
+	require.WithinDuration(t, time.Now().Add(4*time.Minute), *failure.LockedUntil, time.Second, "invalid lockout deadline")
+
+	failure.Count = 100
+	failure.Lock(3, time.Minute, time.Hour)
+	require.WithinDuration(t, time.Now().Add(time.Hour), *failure.LockedUntil, time.Second, "invalid maximum lockout deadline")
+}
+
+func TestAuthFailureLockExpired(t *testing.T) {
+	deadline := time.Now().Add(-time.Second)
+	failure := &AuthFailure{Key: "key", Count: 5, LockedUntil: &deadline}
+	require.False(t, failure.IsLocked(), "lockout should have expired")
+}
@@ -1,17 +1,38 @@
 package common
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	uuid "github.com/nu7hatch/gouuid"
+	"github.com/root-gg/utils"
 	"golang.org/x/crypto/bcrypt"
 )
 
 const sessionCookieName = "plik-session"
 const xsrfCookieName = "plik-xsrf"
+const uploadSessionCookiePrefix = "plik-upload-"
+
+// UploadSessionTTL is the validity period of the upload session cookies
+const UploadSessionTTL = time.Hour
+
+// Upload credentials are bcrypt hashed with a lower cost than user passwords as API clients and curl send them on
+// every request. A check still costs tens of milliseconds of CPU, successful checks are cached for UploadSessionTTL
+// and failed checks are limited by the authentication lockout.
+const uploadPasswordHashCost = bcrypt.DefaultCost
+
+// uploadPasswordCacheSize is the maximum number of cached successful upload credentials checks
+const uploadPasswordCacheSize = 10000
+
+var uploadPasswordCache = newPasswordCache(uploadPasswordCacheSize, UploadSessionTTL)
+
+// Upload credentials used to be stored as an unsalted md5sum
+var legacyUploadPasswordHashRegexp = regexp.MustCompile("^[0-9a-f]{32}$")
 
 // GenerateAuthenticationSignatureKey create an new random key
 func GenerateAuthenticationSignatureKey() (s *Setting) {
@@ -153,3 +174,110 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// HashUploadPassword return bcrypt hash ( with salt ) of the upload basic auth credentials base64("login:password")
+func HashUploadPassword(credentials string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(credentials), uploadPasswordHashCost)
+	return string(bytes), err
+}
+
+// CheckUploadPassword check the upload basic auth credentials base64("login:password") against the upload password hash
+// legacy is true if the hash is a legacy md5sum that should be upgraded to a bcrypt hash
+func CheckUploadPassword(credentials string, hash string) (ok bool, legacy bool) {
+	if legacyUploadPasswordHashRegexp.MatchString(hash) {
+		md5sum, err := utils.Md5sum(credentials)
+		if err != nil {
+			return false, true
+		}
+		return md5sum == hash, true
+	}
+
+	if uploadPasswordCache.check(credentials, hash) {
+		return true, false
+	}
+
+	ok = CheckPasswordHash(credentials, hash)
+	if ok {
+		uploadPasswordCache.add(credentials, hash)
+	}
+
+	return ok, false
+}
+
+// UploadSessionCookieName return the name of the session cookie of an upload
+func UploadSessionCookieName(uploadID string) string {
+	return uploadSessionCookiePrefix + uploadID
+}
+
+// Fingerprint of the upload password hash so that upload session cookies are invalidated if the password changes
+func uploadPasswordFingerprint(upload *Upload) string {
+	sum := sha256.Sum256([]byte(upload.Password))
+	return hex.EncodeToString(sum[:8])
+}
+
+// GenUploadSessionCookie generate a signed jwt cookie to access a password protected upload
+func (sa *SessionAuthenticator) GenUploadSessionCookie(upload *Upload) (cookie *http.Cookie, err error) {
+	expire := time.Now().Add(UploadSessionTTL)
+	if upload.ExpireAt != nil && upload.ExpireAt.Before(expire) {
+		expire = *upload.ExpireAt
+	}
+
+	session := jwt.New(jwt.SigningMethodHS512)
+	session.Claims.(jwt.MapClaims)["upload"] = upload.ID
+	session.Claims.(jwt.MapClaims)["fingerprint"] = uploadPasswordFingerprint(upload)
+	session.Claims.(jwt.MapClaims)["exp"] = expire.Unix()
+
+	sessionString, err := session.SignedString([]byte(sa.SignatureKey))
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign upload session cookie : %s", err)
+	}
+
+	cookie = &http.Cookie{}
+	cookie.HttpOnly = true
+	cookie.Name = UploadSessionCookieName(upload.ID)
+	cookie.Value = sessionString
+	cookie.Expires = expire
+	cookie.MaxAge = int(time.Until(expire).Seconds())
+	cookie.Path = "/"
+	cookie.SameSite = http.SameSiteLaxMode
+
+	if sa.SecureCookies {
+		cookie.Secure = true
+	}
+
+	return cookie, nil
+}
+
+// ParseUploadSessionCookie parse and validate an upload session cookie
+func (sa *SessionAuthenticator) ParseUploadSessionCookie(value string, upload *Upload) (err error) {
+	session, err := jwt.Parse(value, func(t *jwt.Token) (interface{}, error) {
+		// Verify signing algorithm
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected siging method : %v", t.Header["alg"])
+		}
+
+		return []byte(sa.SignatureKey), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	claims := session.Claims.(jwt.MapClaims)
+
+	// jwt-go only validates the expiration date if the claim is present
+	if _, ok := claims["exp"]; !ok {
+		return fmt.Errorf("missing expiration date from upload session cookie")
+	}
+
+	uploadID, ok := claims["upload"].(string)
+	if !ok || uploadID != upload.ID {
+		return fmt.Errorf("invalid upload session cookie")
+	}
+
+	fingerprint, ok := claims["fingerprint"].(string)
+	if !ok || fingerprint != uploadPasswordFingerprint(upload) {
+		return fmt.Errorf("invalid upload session cookie")
+	}
+
+	return nil
+}
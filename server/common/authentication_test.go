@@ -4,7 +4,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/root-gg/utils"
 	"github.com/stretchr/testify/require"
 )
 
@@ -70,3 +72,73 @@ func TestHashPassword(t *testing.T) {
 	ok = CheckPasswordHash("invalid", hash)
 	require.False(t, ok)
 }
+
+func TestHashUploadPassword(t *testing.T) {
+	credentials := EncodeAuthBasicHeader("login", "password")
+	hash, err := HashUploadPassword(credentials)
+	require.NoError(t, err, "unable to hash upload password")
+	require.NotEqual(t, credentials, hash, "upload password not hashed")
+
+	ok, legacy := CheckUploadPassword(credentials, hash)
+	require.True(t, ok, "invalid upload password check")
+	require.False(t, legacy, "invalid legacy status")
+
+	ok, _ = CheckUploadPassword(EncodeAuthBasicHeader("login", "invalid"), hash)
+	require.False(t, ok, "invalid upload password check")
+}
+
+func TestCheckUploadPasswordLegacy(t *testing.T) {
+	credentials := EncodeAuthBasicHeader("login", "password")
+	hash, err := utils.Md5sum(credentials)
+	require.NoError(t, err, "unable to md5sum upload password")
+
+	ok, legacy := CheckUploadPassword(credentials, hash)
+	require.True(t, ok, "invalid upload password check")
+	require.True(t, legacy, "invalid legacy status")
+
+	ok, legacy = CheckUploadPassword(EncodeAuthBasicHeader("login", "invalid"), hash)
+	require.False(t, ok, "invalid upload password check")
+	require.True(t, legacy, "invalid legacy status")
+}
+
+func TestUploadSessionCookie(t *testing.T) {
+	setting := GenerateAuthenticationSignatureKey()
+	sa := &SessionAuthenticator{SignatureKey: setting.Value, SecureCookies: true}
+
+	upload := &Upload{ID: "upload", Password: "hash"}
+	cookie, err := sa.GenUploadSessionCookie(upload)
+	require.NoError(t, err, "unable to generate upload session cookie")
+	require.Equal(t, UploadSessionCookieName(upload.ID), cookie.Name, "invalid upload session cookie name")
+	require.True(t, cookie.Secure, "invalid upload session cookie not secure")
+	require.True(t, cookie.HttpOnly, "invalid upload session cookie not http only")
+	require.True(t, cookie.MaxAge > 0 && cookie.MaxAge <= int(UploadSessionTTL.Seconds()), "invalid upload session cookie max age")
+
+	err = sa.ParseUploadSessionCookie(cookie.Value, upload)
+	require.NoError(t, err, "unable to parse upload session cookie")
+
+	err = sa.ParseUploadSessionCookie(cookie.Value, &Upload{ID: "other", Password: "hash"})
+	require.Error(t, err, "cookie of another upload should not be valid")
+
+	err = sa.ParseUploadSessionCookie(cookie.Value, &Upload{ID: "upload", Password: "new_hash"})
+	require.Error(t, err, "cookie should not be valid after a password change")
+
+	other := &SessionAuthenticator{SignatureKey: "other"}
+	err = other.ParseUploadSessionCookie(cookie.Value, upload)
+	require.Error(t, err, "cookie with an invalid signature should not be valid")
+
+	// A user session cookie must not grant access to an upload
+	sessionCookie, _, err := sa.GenAuthCookies(NewUser("local", "user"))
+	require.NoError(t, err, "unable to generate cookies")
+	err = sa.ParseUploadSessionCookie(sessionCookie.Value, upload)
+	require.Error(t, err, "session cookie should not be a valid upload session cookie")
+}
+
+func TestUploadSessionCookieExpireWithUpload(t *testing.T) {
+	sa := &SessionAuthenticator{SignatureKey: "key"}
+
+	deadline := time.Now().Add(time.Minute)
+	upload := &Upload{ID: "upload", ExpireAt: &deadline}
+	cookie, err := sa.GenUploadSessionCookie(upload)
+	require.NoError(t, err, "unable to generate upload session cookie")
+	require.True(t, cookie.MaxAge <= 60, "invalid upload session cookie max age")
+}
@@ -0,0 +1,76 @@
+package common
+
+import (
+	"crypto/x509"
+	"fmt"
+)
+
+// SslClientAuthNone do not request client certificates
+const SslClientAuthNone = "none"
+
+// SslClientAuthRequest verify client certificates if provided
+const SslClientAuthRequest = "request"
+
+// SslClientAuthRequire reject connections without a valid client certificate
+const SslClientAuthRequire = "require"
+
+// CertificateAttributeCN maps the certificate subject common name to the user login
+const CertificateAttributeCN = "cn"
+
+// CertificateAttributeEmail maps the first email SAN to the user login
+const CertificateAttributeEmail = "email"
+
+// CertificateAttributeDNS maps the first DNS SAN to the user login
+const CertificateAttributeDNS = "dns"
+
+// CertificateAttributeURI maps the first URI SAN to the user login
+const CertificateAttributeURI = "uri"
+
+// IsValidCertificateAttribute return true if the client certificate user attribute is valid
+func IsValidCertificateAttribute(attribute string) bool {
+	switch attribute {
+	case CertificateAttributeCN, CertificateAttributeEmail, CertificateAttributeDNS, CertificateAttributeURI:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetCertificateLogin return the user login mapped from a client certificate attribute
+func GetCertificateLogin(cert *x509.Certificate, attribute string) (login string, err error) {
+	switch attribute {
+	case CertificateAttributeCN:
+		login = cert.Subject.CommonName
+	case CertificateAttributeEmail:
+		if len(cert.EmailAddresses) > 0 {
+			login = cert.EmailAddresses[0]
+		}
+	case CertificateAttributeDNS:
+		if len(cert.DNSNames) > 0 {
+			login = cert.DNSNames[0]
+		}
+	case CertificateAttributeURI:
+		if len(cert.URIs) > 0 {
+			login = cert.URIs[0].String()
+		}
+	default:
+		return "", fmt.Errorf("invalid client certificate user attribute %s", attribute)
+	}
+
+	if login == "" {
+		return "", fmt.Errorf("missing %s attribute in client certificate", attribute)
+	}
+
+	return login, nil
+}
+
+// NewCertificateUser create a new user from a client certificate
+func NewCertificateUser(cert *x509.Certificate, login string) (user *User) {
+	user = NewUser(ProviderCert, login)
+	user.Login = login
+	user.Name = cert.Subject.CommonName
+	if len(cert.EmailAddresses) > 0 {
+		user.Email = cert.EmailAddresses[0]
+	}
+	return user
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCertificateLogin(t *testing.T) {
+	uri, err := url.Parse("spiffe://ci.plik/runner")
+	require.NoError(t, err)
+
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "runner"},
+		EmailAddresses: []string{"runner@plik", "ci@plik"},
+		DNSNames:       []string{"runner.plik"},
+		URIs:           []*url.URL{uri},
+	}
+
+	login, err := GetCertificateLogin(cert, CertificateAttributeCN)
+	require.NoError(t, err, "unable to get certificate login")
+	require.Equal(t, "runner", login, "invalid certificate login")
+
+	login, err = GetCertificateLogin(cert, CertificateAttributeEmail)
+	require.NoError(t, err, "unable to get certificate login")
+	require.Equal(t, "runner@plik", login, "invalid certificate login")
+
+	login, err = GetCertificateLogin(cert, CertificateAttributeDNS)
+	require.NoError(t, err, "unable to get certificate login")
+	require.Equal(t, "runner.plik", login, "invalid certificate login")
+
+	login, err = GetCertificateLogin(cert, CertificateAttributeURI)
+	require.NoError(t, err, "unable to get certificate login")
+	require.Equal(t, "spiffe://ci.plik/runner", login, "invalid certificate login")
+
+	_, err = GetCertificateLogin(cert, "invalid")
+	require.Error(t, err, "able to get certificate login with invalid attribute")
+}
+
+func TestGetCertificateLoginMissingAttribute(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "runner"}}
+
+	_, err := GetCertificateLogin(cert, CertificateAttributeEmail)
+	require.Error(t, err, "able to get certificate login without email")
+
+	_, err = GetCertificateLogin(&x509.Certificate{}, CertificateAttributeCN)
+	require.Error(t, err, "able to get certificate login without common name")
+}
+
+func TestNewCertificateUser(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "runner"},
+		EmailAddresses: []string{"runner@plik"},
+	}
+
+	user := NewCertificateUser(cert, "runner@plik")
+	require.Equal(t, GetUserID(ProviderCert, "runner@plik"), user.ID, "invalid user id")
+	require.Equal(t, ProviderCert, user.Provider, "invalid user provider")
+	require.Equal(t, "runner@plik", user.Login, "invalid user login")
+	require.Equal(t, "runner", user.Name, "invalid user name")
+	require.Equal(t, "runner@plik", user.Email, "invalid user email")
+}
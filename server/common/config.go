@@ -1,7 +1,10 @@
 package common
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/url"
 	"os"
@@ -39,6 +42,10 @@ type Configuration struct {
 	SslCert    string `json:"-"`
 	SslKey     string `json:"-"`
 
+	SslClientAuth          string `json:"-"` // none|request|require
+	SslClientCA            string `json:"-"`
+	SslClientUserAttribute string `json:"-"` // cn|email|dns|uri
+
 	NoWebInterface      bool   `json:"-"`
 	DownloadDomain      string `json:"downloadDomain"`
 	EnhancedWebSecurity bool   `json:"-"`
@@ -47,6 +54,62 @@ type Configuration struct {
 	SourceIPHeader  string   `json:"-"`
 	UploadWhitelist []string `json:"-"`
 
+	AuthFailureThreshold   int `json:"-"`
+	AuthFailureIPThreshold int `json:"-"`
+	AuthLockoutDuration    int `json:"-"`
+	AuthMaxLockoutDuration int `json:"-"`
+
+	CleaningInterval    int `json:"-"`
+	CleaningRandomDelay int `json:"-"`
+	CleaningBatchSize   int `json:"-"`
+
+	ClamdAddress             string `json:"-"`
+	ClamdTimeout             int    `json:"-"`
+	ClamdRescanInterval      int    `json:"-"`
+	ClamdMaxScanSize         int64  `json:"-"`
+	ClamdQuarantineOversized bool   `json:"-"`
+	Antivirus                bool   `json:"antivirus"`
+
+	PolicyHookCommand  string `json:"-"`
+	PolicyHookURL      string `json:"-"`
+	PolicyHookTimeout  int    `json:"-"`
+	PolicyHookFailOpen bool   `json:"-"`
+
+	Webhooks            []*Webhook `json:"-"`
+	UserWebhooks        bool       `json:"userWebhooks"`
+	WebhookTimeout      int        `json:"-"`
+	WebhookMaxAttempts  int        `json:"-"`
+	WebhookPollInterval int        `json:"-"`
+
+	SMTPAddress        string `json:"-"`
+	SMTPUsername       string `json:"-"`
+	SMTPPassword       string `json:"-"`
+	SMTPFrom           string `json:"-"`
+	SMTPTLS            bool   `json:"-"`
+	SMTPInsecure       bool   `json:"-"`
+	SMTPTimeout        int    `json:"-"`
+	PublicURL          string `json:"-"`
+	EmailNotifications bool   `json:"emailNotifications"`
+
+	ForceStripMetadata bool `json:"forceStripMetadata"`
+
+	Previews           bool  `json:"previews"`
+	PreviewMaxFileSize int64 `json:"-"`
+
+	Paste        bool  `json:"paste"`
+	PasteMaxSize int64 `json:"-"`
+
+	FileVersions    bool `json:"fileVersions"`
+	MaxFileVersions int  `json:"maxFileVersions"`
+
+	Fetch                     bool     `json:"fetch"`
+	FetchAllowedHosts         []string `json:"-"`
+	FetchAllowedNetworks      []string `json:"-"`
+	FetchMaxRedirects         int      `json:"-"`
+	FetchTimeout              int      `json:"-"`
+	FetchMaxConcurrent        int      `json:"-"`
+	FetchMaxConcurrentPerUser int      `json:"-"`
+
 	Authentication       bool     `json:"authentication"`
 	NoAnonymousUploads   bool     `json:"noAnonymousUploads"`
 	OneShot              bool     `json:"oneShot"`
@@ -68,7 +131,9 @@ type Configuration struct {
 	DataBackendConfig map[string]interface{} `json:"-"`
 
 	downloadDomainURL *url.URL
+	sslClientCAs      *x509.CertPool
 	uploadWhitelist   []*net.IPNet
+	fetchNetworks     []*net.IPNet
 	clean             bool
 }
 
@@ -94,6 +159,44 @@ func NewConfiguration() (config *Configuration) {
 
 	config.OvhAPIEndpoint = "https://eu.api.ovh.com/1.0"
 
+	config.SslClientAuth = SslClientAuthNone
+	config.SslClientUserAttribute = CertificateAttributeCN
+
+	config.AuthFailureThreshold = 5    // failed attempts per account/upload
+	config.AuthFailureIPThreshold = 20 // failed attempts per source IP
+	config.AuthLockoutDuration = 60    // 1 minute
+	config.AuthMaxLockoutDuration = 3600
+
+	config.CleaningInterval = 7200    // 2 hours
+	config.CleaningRandomDelay = 3600 // 1 hour
+	config.CleaningBatchSize = 1000
+
+	config.ClamdTimeout = 60           // 1 minute
+	config.ClamdRescanInterval = 3600  // 1 hour
+	config.ClamdMaxScanSize = 26214400 // 25MB ( clamd StreamMaxLength default )
+
+	config.PolicyHookTimeout = 10
+
+	config.WebhookTimeout = 10
+	config.WebhookMaxAttempts = 10
+	config.WebhookPollInterval = 10
+
+	config.SMTPTimeout = 30
+
+	config.Previews = true
+	config.PreviewMaxFileSize = 20000000 // 20MB
+
+	config.Paste = true
+	config.PasteMaxSize = 1000000 // 1MB
+
+	config.FileVersions = true
+	config.MaxFileVersions = 10
+
+	config.FetchMaxRedirects = 5
+	config.FetchTimeout = 3600 // 1 hour
+	config.FetchMaxConcurrent = 20
+	config.FetchMaxConcurrentPerUser = 2
+
 	config.DataBackend = "file"
 
 	config.clean = true
@@ -187,6 +290,151 @@ func (config *Configuration) Initialize() (err error) {
 		return fmt.Errorf("DefaultTTL should not be more than MaxTTL")
 	}
 
+	err = config.initializeSslClientAuth()
+	if err != nil {
+		return err
+	}
+
+	if config.AuthLockoutDuration < 0 || config.AuthMaxLockoutDuration < config.AuthLockoutDuration {
+		return fmt.Errorf("invalid authentication lockout duration")
+	}
+
+	if config.CleaningInterval <= 0 || config.CleaningRandomDelay < 0 {
+		return fmt.Errorf("invalid cleaning interval")
+	}
+
+	if config.CleaningBatchSize < 0 {
+		return fmt.Errorf("invalid cleaning batch size")
+	}
+
+	// Streamed files never reach the data backend and can't be scanned
+	config.Antivirus = config.ClamdAddress != ""
+	if config.Antivirus {
+		config.Stream = false
+		if config.ClamdTimeout <= 0 || config.ClamdRescanInterval <= 0 {
+			return fmt.Errorf("invalid clamd timeout or rescan interval")
+		}
+		if config.ClamdMaxScanSize < 0 {
+			return fmt.Errorf("invalid clamd max scan size")
+		}
+	}
+
+	if config.PolicyHookCommand != "" && config.PolicyHookURL != "" {
+		return fmt.Errorf("only one of PolicyHookCommand and PolicyHookURL can be set")
+	}
+
+	if config.PolicyHookTimeout <= 0 {
+		return fmt.Errorf("invalid policy hook timeout")
+	}
+
+	for _, webhook := range config.Webhooks {
+		err = webhook.Validate(nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.WebhookTimeout <= 0 || config.WebhookMaxAttempts <= 0 || config.WebhookPollInterval <= 0 {
+		return fmt.Errorf("invalid webhook timeout, max attempts or poll interval")
+	}
+
+	if config.PreviewMaxFileSize <= 0 {
+		return fmt.Errorf("invalid preview max file size")
+	}
+
+	if config.PasteMaxSize <= 0 {
+		return fmt.Errorf("invalid paste max size")
+	}
+
+	if config.MaxFileVersions < 0 {
+		return fmt.Errorf("invalid max file versions")
+	}
+
+	// FetchAllowedNetworks is only parsed once at startup time
+	config.fetchNetworks = nil
+	for _, cidr := range config.FetchAllowedNetworks {
+		if !strings.Contains(cidr, "/") {
+			if strings.Contains(cidr, ":") {
+				cidr += "/128"
+			} else {
+				cidr += "/32"
+			}
+		}
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			config.fetchNetworks = append(config.fetchNetworks, network)
+		} else {
+			return fmt.Errorf("failed to parse fetch allowed networks : %s", cidr)
+		}
+	}
+
+	if config.FetchMaxRedirects < 0 || config.FetchTimeout <= 0 {
+		return fmt.Errorf("invalid fetch max redirects or timeout")
+	}
+
+	if config.FetchMaxConcurrent < 0 || config.FetchMaxConcurrentPerUser < 0 {
+		return fmt.Errorf("invalid fetch max concurrent")
+	}
+
+	// Users can only opt-in to email notifications if an SMTP server is configured
+	config.EmailNotifications = config.SMTPAddress != ""
+	if config.EmailNotifications {
+		if _, _, err := net.SplitHostPort(config.SMTPAddress); err != nil {
+			return fmt.Errorf("invalid SMTP address %s : %s", config.SMTPAddress, err)
+		}
+		if config.SMTPFrom == "" {
+			return fmt.Errorf("missing SMTP sender address")
+		}
+		if config.SMTPTimeout <= 0 {
+			return fmt.Errorf("invalid SMTP timeout")
+		}
+	}
+
+	config.PublicURL = strings.TrimSuffix(config.PublicURL, "/")
+	if config.PublicURL != "" {
+		if _, err := url.Parse(config.PublicURL); err != nil {
+			return fmt.Errorf("invalid public URL %s : %s", config.PublicURL, err)
+		}
+	}
+
+	return nil
+}
+
+func (config *Configuration) initializeSslClientAuth() (err error) {
+	switch config.SslClientAuth {
+	case "":
+		config.SslClientAuth = SslClientAuthNone
+	case SslClientAuthNone, SslClientAuthRequest, SslClientAuthRequire:
+	default:
+		return fmt.Errorf("invalid client certificate authentication mode %s", config.SslClientAuth)
+	}
+
+	if config.SslClientAuth == SslClientAuthNone {
+		return nil
+	}
+
+	if !config.SslEnabled {
+		return fmt.Errorf("client certificate authentication requires SSL to be enabled")
+	}
+
+	if !IsValidCertificateAttribute(config.SslClientUserAttribute) {
+		return fmt.Errorf("invalid client certificate user attribute %s", config.SslClientUserAttribute)
+	}
+
+	if config.SslClientCA == "" {
+		return fmt.Errorf("missing client certificate CA bundle")
+	}
+
+	// The CA bundle is only loaded once at startup time
+	pem, err := ioutil.ReadFile(config.SslClientCA)
+	if err != nil {
+		return fmt.Errorf("unable to read client certificate CA bundle : %s", err)
+	}
+
+	config.sslClientCAs = x509.NewCertPool()
+	if !config.sslClientCAs.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no valid certificate in client certificate CA bundle %s", config.SslClientCA)
+	}
+
 	return nil
 }
 
@@ -204,11 +452,38 @@ func (config *Configuration) GetUploadWhitelist() []*net.IPNet {
 	return config.uploadWhitelist
 }
 
+// GetFetchAllowedNetworks return the parsed networks the server-side fetch can connect to despite the private ranges denylist
+func (config *Configuration) GetFetchAllowedNetworks() []*net.IPNet {
+	return config.fetchNetworks
+}
+
 // GetDownloadDomain return the parsed download domain URL
 func (config *Configuration) GetDownloadDomain() *url.URL {
 	return config.downloadDomainURL
 }
 
+// GetSslClientCAs return the parsed client certificate CA bundle
+func (config *Configuration) GetSslClientCAs() *x509.CertPool {
+	return config.sslClientCAs
+}
+
+// GetSslClientAuthType return the TLS client authentication policy
+func (config *Configuration) GetSslClientAuthType() tls.ClientAuthType {
+	switch config.SslClientAuth {
+	case SslClientAuthRequest:
+		return tls.VerifyClientCertIfGiven
+	case SslClientAuthRequire:
+		return tls.RequireAndVerifyClientCert
+	default:
+		return tls.NoClientCert
+	}
+}
+
+// IsClientCertAuthentication return true if verified client certificates should authenticate users
+func (config *Configuration) IsClientCertAuthentication() bool {
+	return config.Authentication && config.SslClientAuth != SslClientAuthNone
+}
+
 // AutoClean enable or disables the periodical upload cleaning goroutine.
 // This needs to be called before Plik server starts to have effect
 func (config *Configuration) AutoClean(value bool) {
@@ -220,6 +495,11 @@ func (config *Configuration) IsAutoClean() bool {
 	return config.clean
 }
 
+// IsBruteForceProtected return true if failed authentication attempts should be tracked
+func (config *Configuration) IsBruteForceProtected() bool {
+	return config.AuthFailureThreshold > 0 || config.AuthFailureIPThreshold > 0
+}
+
 // IsWhitelisted return weather or not the IP matches of the config upload whitelist
 func (config *Configuration) IsWhitelisted(ip net.IP) bool {
 	if len(config.uploadWhitelist) == 0 {
@@ -237,6 +517,14 @@ func (config *Configuration) IsWhitelisted(ip net.IP) bool {
 	return false
 }
 
+// GetPublicURL return the URL of the Plik web interface to use in links sent to the users
+func (config *Configuration) GetPublicURL() string {
+	if config.PublicURL != "" {
+		return config.PublicURL
+	}
+	return config.GetServerURL().String()
+}
+
 // GetServerURL is a helper to get the server HTTP URL
 func (config *Configuration) GetServerURL() *url.URL {
 	URL := &url.URL{}
@@ -299,6 +587,62 @@ func (config *Configuration) String() string {
 		str += fmt.Sprintf("Streaming upload : disabled\n")
 	}
 
+	if config.Antivirus {
+		str += fmt.Sprintf("Antivirus : enabled (%s)\n", config.ClamdAddress)
+	} else {
+		str += fmt.Sprintf("Antivirus : disabled\n")
+	}
+
+	if config.PolicyHookCommand != "" {
+		str += fmt.Sprintf("Upload policy hook : %s\n", config.PolicyHookCommand)
+	} else if config.PolicyHookURL != "" {
+		str += fmt.Sprintf("Upload policy hook : %s\n", config.PolicyHookURL)
+	}
+
+	if config.EmailNotifications {
+		str += fmt.Sprintf("Email notifications : enabled (%s)\n", config.SMTPAddress)
+	} else {
+		str += fmt.Sprintf("Email notifications : disabled\n")
+	}
+
+	if config.ForceStripMetadata {
+		str += fmt.Sprintf("Image metadata stripping : forced\n")
+	}
+
+	if config.Previews {
+		str += fmt.Sprintf("File previews : enabled\n")
+	} else {
+		str += fmt.Sprintf("File previews : disabled\n")
+	}
+
+	if config.Paste {
+		str += fmt.Sprintf("Paste mode : enabled (max size %s)\n", humanize.Bytes(uint64(config.PasteMaxSize)))
+	} else {
+		str += fmt.Sprintf("Paste mode : disabled\n")
+	}
+
+	if config.FileVersions {
+		if config.MaxFileVersions > 0 {
+			str += fmt.Sprintf("File versions : enabled (keep %d previous versions)\n", config.MaxFileVersions)
+		} else {
+			str += fmt.Sprintf("File versions : enabled (keep all previous versions)\n")
+		}
+	} else {
+		str += fmt.Sprintf("File versions : disabled\n")
+	}
+
+	if config.UserWebhooks {
+		str += fmt.Sprintf("User webhooks : enabled\n")
+	} else {
+		str += fmt.Sprintf("User webhooks : administrators only\n")
+	}
+
+	if config.Fetch {
+		str += fmt.Sprintf("Server-side fetch : enabled\n")
+	} else {
+		str += fmt.Sprintf("Server-side fetch : disabled\n")
+	}
+
 	if config.ProtectedByPassword {
 		str += fmt.Sprintf("Upload password : enabled\n")
 	} else {
@@ -1,9 +1,20 @@
 package common
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
 	"net"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/iancoleman/strcase"
 
@@ -46,6 +57,38 @@ func TestInitializeConfigUploadWhitelist(t *testing.T) {
 	require.Equal(t, config.UploadWhitelist[1], config.uploadWhitelist[2].String(), "invalid parsed upload IP")
 }
 
+func TestInitializeConfigFetchAllowedNetworks(t *testing.T) {
+	config := NewConfiguration()
+	config.FetchAllowedNetworks = []string{"10.0.0.1", "192.168.1.0/24", "fd00::1"}
+
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	networks := config.GetFetchAllowedNetworks()
+	require.Len(t, networks, 3, "invalid parsed fetch allowed networks length")
+	require.Equal(t, "10.0.0.1/32", networks[0].String(), "invalid parsed fetch allowed network")
+	require.Equal(t, "192.168.1.0/24", networks[1].String(), "invalid parsed fetch allowed network")
+	require.Equal(t, "fd00::1/128", networks[2].String(), "invalid parsed fetch allowed network")
+
+	config.FetchAllowedNetworks = []string{"invalid"}
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+}
+
+func TestInitializeConfigInvalidFetchTimeout(t *testing.T) {
+	config := NewConfiguration()
+	config.FetchTimeout = 0
+	err := config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+}
+
+func TestInitializeConfigInvalidFetchMaxConcurrent(t *testing.T) {
+	config := NewConfiguration()
+	config.FetchMaxConcurrentPerUser = -1
+	err := config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+}
+
 func TestIsWhitelisted(t *testing.T) {
 	config := NewConfiguration()
 
@@ -77,6 +120,211 @@ func TestInitializeConfigAuthentication(t *testing.T) {
 	require.NoError(t, err, "unable to initialize config")
 }
 
+func TestInitializeConfigInvalidAuthLockoutDuration(t *testing.T) {
+	config := NewConfiguration()
+	config.AuthLockoutDuration = -1
+	err := config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+
+	config = NewConfiguration()
+	config.AuthLockoutDuration = 3600
+	config.AuthMaxLockoutDuration = 60
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+}
+
+func TestInitializeConfigInvalidCleaning(t *testing.T) {
+	config := NewConfiguration()
+	config.CleaningInterval = 0
+	err := config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+
+	config = NewConfiguration()
+	config.CleaningRandomDelay = -1
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+
+	config = NewConfiguration()
+	config.CleaningBatchSize = -1
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+}
+
+func TestInitializeConfigPolicyHook(t *testing.T) {
+	config := NewConfiguration()
+	config.PolicyHookCommand = "/usr/local/bin/plik-policy"
+	config.PolicyHookURL = "http://127.0.0.1:8081/policy"
+	err := config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+
+	config = NewConfiguration()
+	config.PolicyHookURL = "http://127.0.0.1:8081/policy"
+	config.PolicyHookTimeout = 0
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+}
+
+func TestInitializeConfigWebhooks(t *testing.T) {
+	config := NewConfiguration()
+	config.Webhooks = []*Webhook{{URL: "https://ci.example.com/hook", Events: EventFileUploaded}}
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize valid config")
+
+	config.Webhooks = append(config.Webhooks, &Webhook{URL: "https://ci.example.com/hook", Events: "foo"})
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+
+	config = NewConfiguration()
+	config.WebhookMaxAttempts = 0
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+}
+
+func TestInitializeConfigEmailNotifications(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize valid config")
+	require.False(t, config.EmailNotifications)
+
+	config.SMTPAddress = "127.0.0.1:25"
+	config.SMTPFrom = "plik@root.gg"
+	config.PublicURL = "https://plik.root.gg/"
+	err = config.Initialize()
+	require.NoError(t, err, "unable to initialize valid config")
+	require.True(t, config.EmailNotifications)
+	require.Equal(t, "https://plik.root.gg", config.GetPublicURL(), "invalid public url")
+
+	config.SMTPFrom = ""
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+
+	config = NewConfiguration()
+	config.SMTPAddress = "127.0.0.1"
+	config.SMTPFrom = "plik@root.gg"
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+}
+
+func TestInitializeConfigInvalidPreviewMaxFileSize(t *testing.T) {
+	config := NewConfiguration()
+	config.PreviewMaxFileSize = 0
+	err := config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+}
+
+func TestInitializeConfigInvalidPasteMaxSize(t *testing.T) {
+	config := NewConfiguration()
+	config.PasteMaxSize = 0
+	err := config.Initialize()
+	require.EqualError(t, err, "invalid paste max size")
+}
+
+func TestInitializeConfigInvalidMaxFileVersions(t *testing.T) {
+	config := NewConfiguration()
+	config.MaxFileVersions = -1
+	err := config.Initialize()
+	require.EqualError(t, err, "invalid max file versions")
+}
+
+func TestInitializeConfigAntivirus(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize valid config")
+	require.False(t, config.Antivirus)
+	require.True(t, config.Stream)
+
+	config = NewConfiguration()
+	config.ClamdAddress = "tcp://127.0.0.1:3310"
+	err = config.Initialize()
+	require.NoError(t, err, "unable to initialize valid config")
+	require.True(t, config.Antivirus)
+	require.False(t, config.Stream, "stream mode should be disabled")
+
+	config = NewConfiguration()
+	config.ClamdAddress = "tcp://127.0.0.1:3310"
+	config.ClamdRescanInterval = 0
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+
+	config = NewConfiguration()
+	config.ClamdAddress = "tcp://127.0.0.1:3310"
+	config.ClamdMaxScanSize = -1
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+}
+
+func TestInitializeConfigSslClientAuth(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "unable to generate key")
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "plik ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "unable to create certificate")
+
+	dir, err := ioutil.TempDir("", "pliktest")
+	require.NoError(t, err, "unable to create temp directory")
+	defer os.RemoveAll(dir)
+
+	ca := filepath.Join(dir, "ca.pem")
+	err = ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	require.NoError(t, err, "unable to write CA bundle")
+
+	config := NewConfiguration()
+	config.Authentication = true
+	config.SslEnabled = true
+	config.SslClientAuth = SslClientAuthRequest
+	config.SslClientCA = ca
+
+	err = config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.NotNil(t, config.GetSslClientCAs(), "missing client CA pool")
+	require.Equal(t, tls.VerifyClientCertIfGiven, config.GetSslClientAuthType(), "invalid client auth type")
+	require.True(t, config.IsClientCertAuthentication(), "invalid client certificate authentication status")
+
+	config.SslClientAuth = SslClientAuthRequire
+	require.Equal(t, tls.RequireAndVerifyClientCert, config.GetSslClientAuthType(), "invalid client auth type")
+}
+
+func TestInitializeConfigInvalidSslClientAuth(t *testing.T) {
+	config := NewConfiguration()
+	require.False(t, config.IsClientCertAuthentication(), "invalid client certificate authentication status")
+	require.Equal(t, tls.NoClientCert, config.GetSslClientAuthType(), "invalid client auth type")
+
+	config.SslClientAuth = "invalid"
+	err := config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+
+	// SSL is not enabled
+	config = NewConfiguration()
+	config.SslClientAuth = SslClientAuthRequire
+	config.SslClientCA = "ca.pem"
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+
+	// Missing CA bundle
+	config.SslEnabled = true
+	config.SslClientCA = ""
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+
+	// Invalid CA bundle
+	config.SslClientCA = "invalid_ca_path"
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+
+	config.SslClientCA = "ca.pem"
+	config.SslClientUserAttribute = "invalid"
+	err = config.Initialize()
+	require.Error(t, err, "able to initialize invalid config")
+}
+
 func TestInitializeConfigDownloadDomain(t *testing.T) {
 	config := NewConfiguration()
 	config.DownloadDomain = "https://dl.plik.root.gg"
@@ -2,7 +2,9 @@ package common
 
 import (
 	"fmt"
+	"strings"
 	"time"
+	"unicode"
 )
 
 // FileMissing when a file is waiting to be uploaded
@@ -14,17 +16,33 @@ const FileUploading = "uploading"
 // FileUploaded when a file has been uploaded and is ready to be downloaded
 const FileUploaded = "uploaded"
 
+// FileScanning when a file has been uploaded but is waiting for the antivirus verdict
+const FileScanning = "scanning"
+
+// FileQuarantined when the antivirus has found a virus in the file
+const FileQuarantined = "quarantined"
+
 // FileRemoved when a file has been removed and can't be downloaded anymore but has not yet been deleted
 const FileRemoved = "removed"
 
 // FileDeleted when a file has been deleted from the data backend
 const FileDeleted = "deleted"
 
+// PreviewImage when a thumbnail of the file is available
+const PreviewImage = "image"
+
+// PreviewText when a snippet of the file text is available
+const PreviewText = "text"
+
+// PreviewNone when no preview can be generated for the file
+const PreviewNone = "none"
+
 // File object
 type File struct {
 	ID       string `json:"id"`
 	UploadID string `json:"-"  gorm:"type:varchar(255) REFERENCES uploads(id) ON UPDATE RESTRICT ON DELETE RESTRICT"`
 	Name     string `json:"fileName"`
+	Path     string `json:"path,omitempty"`
 
 	Status string `json:"status"`
 
@@ -32,6 +50,46 @@ type File struct {
 	Type      string `json:"fileType"`
 	Size      int64  `json:"fileSize"`
 	Reference string `json:"reference"`
+	Virus     string `json:"virus,omitempty"`
+
+	// Number of downloads counted against the upload max downloads
+	Downloads int `json:"downloads,omitempty"`
+
+	// True when the EXIF, XMP and IPTC metadata of the image have been removed
+	MetadataStripped bool `json:"metadataStripped,omitempty"`
+
+	// Archive format once the archive members have been indexed or ArchiveInvalid
+	Archive string `json:"archive,omitempty"`
+
+	// Kind of the preview stored in the data backend once generated or PreviewNone
+	Preview string `json:"preview,omitempty"`
+
+	// URL of the file when it is fetched by the server, without credentials nor query string
+	Source string `json:"source,omitempty"`
+
+	// Size announced by the remote server, fileSize is updated while the file is being fetched
+	FetchSize int64 `json:"fetchSize,omitempty"`
+
+	// Reason of the server-side fetch failure
+	FetchError string `json:"fetchError,omitempty"`
+
+	// True when the file is a text snippet created in paste mode
+	Paste bool `json:"paste,omitempty"`
+
+	// Syntax of the paste used by the highlighted view, empty for plain text
+	Language string `json:"language,omitempty"`
+
+	// Number of the current version once a new version of the file has been uploaded
+	Version int `json:"version,omitempty"`
+
+	// Upload date of the current version, the file creation date if empty
+	VersionCreatedAt *time.Time `json:"versionCreatedAt,omitempty"`
+
+	// Previous versions of the file, only set by the upload handler
+	Versions []*FileVersion `json:"versions,omitempty" gorm:"-"`
+
+	// Key of the file data in the data backend, the file ID if empty
+	DataID string `json:"-"`
 
 	BackendDetails string `json:"-"`
 
@@ -51,6 +109,59 @@ func (file *File) GenerateID() {
 	file.ID = GenerateRandomID(16)
 }
 
+// GetPath returns the relative path of the file inside the upload directory tree
+func (file *File) GetPath() string {
+	if file.Path == "" {
+		return file.Name
+	}
+	return file.Path + "/" + file.Name
+}
+
+// HasPreview returns true if a preview of the file is stored in the data backend
+func (file *File) HasPreview() bool {
+	return file.Preview == PreviewImage || file.Preview == PreviewText
+}
+
+// GetDataID returns the key of the current version of the file data in the data backend
+func (file *File) GetDataID() string {
+	if file.DataID == "" {
+		return file.ID
+	}
+	return file.DataID
+}
+
+// GetVersion returns the number of the current version of the file
+func (file *File) GetVersion() int {
+	if file.Version == 0 {
+		return 1
+	}
+	return file.Version
+}
+
+// GetVersionFile returns the derived file object used to read a previous version from the data backend
+func (file *File) GetVersionFile(version *FileVersion) *File {
+	previous := *file
+	previous.DataID = version.DataID
+	previous.Version = version.Version
+	previous.Status = version.Status
+	previous.Md5 = version.Md5
+	previous.Type = version.Type
+	previous.Size = version.Size
+	previous.Preview = version.Preview
+	previous.Archive = ""
+	previous.Versions = nil
+	return &previous
+}
+
+// GetPreviewFile returns the derived file object used to store the preview in the data backend
+func (file *File) GetPreviewFile() *File {
+	preview := *file
+	preview.ID = file.GetDataID() + ".preview"
+	preview.DataID = ""
+	preview.Name = file.Name + ".preview"
+	return &preview
+}
+
 // Sanitize removes sensible information from
 // object. Used to hide information in API.
 func (file *File) Sanitize() {
@@ -73,6 +184,25 @@ func (file *File) PrepareInsert(upload *Upload) (err error) {
 		return fmt.Errorf("missing file name")
 	}
 
+	// A file name containing a slash is split into its directory and base name
+	if i := strings.LastIndexAny(file.Name, "/\\"); i >= 0 {
+		if file.Path == "" {
+			file.Path = file.Name[:i]
+		} else {
+			file.Path = file.Path + "/" + file.Name[:i]
+		}
+		file.Name = file.Name[i+1:]
+	}
+
+	if file.Name == "" || file.Name == "." || file.Name == ".." {
+		return fmt.Errorf("invalid file name")
+	}
+
+	file.Path, err = CleanFilePath(file.Path)
+	if err != nil {
+		return err
+	}
+
 	// Check file name length
 	if len(file.Name) > 1024 {
 		return fmt.Errorf("file name %s... is too long, maximum length is 1024 characters", file.Name[:20])
@@ -81,5 +211,57 @@ func (file *File) PrepareInsert(upload *Upload) (err error) {
 	file.GenerateID()
 	file.Status = FileMissing
 
+	// Server managed fields can't be set by the client
+	file.Virus = ""
+	file.Downloads = 0
+	file.MetadataStripped = false
+	file.Archive = ""
+	file.Preview = ""
+	file.Source = ""
+	file.FetchSize = 0
+	file.FetchError = ""
+	file.Version = 0
+	file.VersionCreatedAt = nil
+	file.Versions = nil
+	file.DataID = ""
+
+	// Pastes are only created by the paste handler which enforces the paste size limit
+	file.Paste = false
+
 	return nil
 }
+
+// CleanFilePath validates and normalizes the relative directory path of a file inside an upload.
+// Backslashes are converted to slashes, empty and "." elements are removed.
+// Absolute paths, drive letters, ".." elements and control characters are rejected.
+func CleanFilePath(p string) (string, error) {
+	if len(p) > 1024 {
+		return "", fmt.Errorf("file path is too long, maximum length is 1024 characters")
+	}
+
+	p = strings.Replace(p, "\\", "/", -1)
+	if strings.HasPrefix(p, "/") {
+		return "", fmt.Errorf("invalid file path %q : absolute paths are not allowed", p)
+	}
+
+	var elements []string
+	for i, element := range strings.Split(p, "/") {
+		if element == "" || element == "." {
+			continue
+		}
+		if element == ".." {
+			return "", fmt.Errorf("invalid file path %q : parent directory references are not allowed", p)
+		}
+		if i == 0 && len(element) >= 2 && element[1] == ':' {
+			return "", fmt.Errorf("invalid file path %q : drive letters are not allowed", p)
+		}
+		for _, r := range element {
+			if unicode.IsControl(r) {
+				return "", fmt.Errorf("invalid file path %q : control characters are not allowed", p)
+			}
+		}
+		elements = append(elements, element)
+	}
+
+	return strings.Join(elements, "/"), nil
+}
@@ -1,7 +1,9 @@
 package common
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -49,9 +51,176 @@ func TestFilePrepareInsert(t *testing.T) {
 	require.Errorf(t, err, "too long")
 
 	file.Name = "file name"
+	file.Paste = true
 	err = file.PrepareInsert(upload)
 	require.NoError(t, err, "too long")
+	require.False(t, file.Paste, "pastes can't be created with the upload")
 
 	require.NotNil(t, file.ID, "missing file id")
 	require.Equal(t, FileMissing, file.Status, "missing file id")
 }
+
+func TestFilePrepareInsertServerFields(t *testing.T) {
+	upload := &Upload{}
+	upload.PrepareInsertForTests()
+
+	now := time.Now()
+	file := &File{
+		Name:             "file",
+		Status:           FileUploaded,
+		Virus:            "virus",
+		Downloads:        -10,
+		MetadataStripped: true,
+		Archive:          "zip",
+		Preview:          PreviewImage,
+		Source:           "https://example.com/file",
+		FetchSize:        42,
+		FetchError:       "error",
+		Paste:            true,
+		Version:          3,
+		VersionCreatedAt: &now,
+		Versions:         []*FileVersion{{Version: 1}},
+		DataID:           "data",
+	}
+
+	err := file.PrepareInsert(upload)
+	require.NoError(t, err, "unable to prepare file")
+
+	expected := &File{ID: file.ID, UploadID: upload.ID, Name: "file", Status: FileMissing}
+	require.Equal(t, expected, file, "server managed fields should be reset")
+}
+
+func TestFileGetPath(t *testing.T) {
+	file := &File{Name: "file.txt"}
+	require.Equal(t, "file.txt", file.GetPath(), "invalid path")
+
+	file.Path = "foo/bar"
+	require.Equal(t, "foo/bar/file.txt", file.GetPath(), "invalid path")
+}
+
+func TestFileGetPreviewFile(t *testing.T) {
+	file := &File{ID: "id", UploadID: "upload", Name: "image.png", BackendDetails: "details"}
+	require.False(t, file.HasPreview(), "unexpected preview")
+
+	preview := file.GetPreviewFile()
+	require.Equal(t, "id.preview", preview.ID, "invalid preview id")
+	require.Equal(t, "upload", preview.UploadID, "invalid preview upload id")
+	require.Equal(t, "details", preview.BackendDetails, "invalid preview backend details")
+	require.Equal(t, "id", file.ID, "parent file has been modified")
+
+	file.Preview = PreviewImage
+	require.True(t, file.HasPreview(), "missing preview")
+
+	file.Preview = PreviewNone
+	require.False(t, file.HasPreview(), "unexpected preview")
+
+	// The preview of a new version is stored next to the version data
+	file.DataID = "data"
+	require.Equal(t, "data.preview", file.GetPreviewFile().GetDataID(), "invalid preview data id")
+}
+
+func TestFileGetDataID(t *testing.T) {
+	file := &File{ID: "id"}
+	require.Equal(t, "id", file.GetDataID(), "invalid data id")
+	require.Equal(t, 1, file.GetVersion(), "invalid version")
+
+	file.DataID = "data"
+	file.Version = 2
+	require.Equal(t, "data", file.GetDataID(), "invalid data id")
+	require.Equal(t, 2, file.GetVersion(), "invalid version")
+}
+
+func TestFileGetVersionFile(t *testing.T) {
+	createdAt := time.Now()
+	file := &File{ID: "id", UploadID: "upload", Name: "file.txt", Status: FileUploaded, Size: 1, Md5: "md5", Archive: ArchiveInvalid, CreatedAt: createdAt}
+
+	version := NewFileVersion(file)
+	require.NotZero(t, version.ID, "missing version id")
+	require.Equal(t, "id", version.FileID, "invalid version file id")
+	require.Equal(t, "upload", version.UploadID, "invalid version upload id")
+	require.Equal(t, 1, version.Version, "invalid version number")
+	require.Equal(t, "id", version.DataID, "invalid version data id")
+	require.Equal(t, createdAt, version.CreatedAt, "invalid version creation date")
+
+	file.DataID = "data"
+	file.Version = 2
+	file.Size = 2
+	file.Md5 = "md5bis"
+
+	previous := file.GetVersionFile(version)
+	require.Equal(t, "id", previous.ID, "invalid previous version id")
+	require.Equal(t, "file.txt", previous.Name, "invalid previous version name")
+	require.Equal(t, "id", previous.GetDataID(), "invalid previous version data id")
+	require.Equal(t, 1, previous.GetVersion(), "invalid previous version number")
+	require.Equal(t, int64(1), previous.Size, "invalid previous version size")
+	require.Equal(t, "md5", previous.Md5, "invalid previous version md5")
+	require.Empty(t, previous.Archive, "previous versions are not indexed")
+	require.Equal(t, "data", file.GetDataID(), "parent file has been modified")
+}
+
+func TestFilePrepareInsertPath(t *testing.T) {
+	upload := &Upload{}
+	upload.PrepareInsertForTests()
+
+	file := &File{Name: "file.txt", Path: "foo//./bar/"}
+	err := file.PrepareInsert(upload)
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, "foo/bar", file.Path, "invalid path")
+	require.Equal(t, "file.txt", file.Name, "invalid name")
+
+	file = &File{Name: "bar\\baz/file.txt", Path: "foo"}
+	err = file.PrepareInsert(upload)
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, "foo/bar/baz", file.Path, "invalid path")
+	require.Equal(t, "file.txt", file.Name, "invalid name")
+
+	file = &File{Name: "../../etc/passwd"}
+	err = file.PrepareInsert(upload)
+	require.Error(t, err, "missing error")
+	require.Contains(t, err.Error(), "parent directory", "invalid error")
+
+	file = &File{Name: "foo/"}
+	err = file.PrepareInsert(upload)
+	require.Error(t, err, "missing error")
+	require.Contains(t, err.Error(), "invalid file name", "invalid error")
+
+	file = &File{Name: ".."}
+	err = file.PrepareInsert(upload)
+	require.Error(t, err, "missing error")
+	require.Contains(t, err.Error(), "invalid file name", "invalid error")
+}
+
+func TestCleanFilePath(t *testing.T) {
+	valid := map[string]string{
+		"":                "",
+		".":               "",
+		"foo":             "foo",
+		"foo/bar":         "foo/bar",
+		"foo/./bar//":     "foo/bar",
+		"foo\\bar":        "foo/bar",
+		"foo/..bar/baz..": "foo/..bar/baz..",
+	}
+	for p, expected := range valid {
+		cleaned, err := CleanFilePath(p)
+		require.NoError(t, err, "unexpected error for %q", p)
+		require.Equal(t, expected, cleaned, "invalid path for %q", p)
+	}
+
+	invalid := []string{
+		"/",
+		"/etc",
+		"\\\\server\\share",
+		"..",
+		"foo/../bar",
+		"foo\\..\\..\\bar",
+		"C:",
+		"c:\\windows",
+		"foo/\x00bar",
+		"foo/\nbar",
+		strings.Repeat("x", 1025),
+	}
+	for _, p := range invalid {
+		_, err := CleanFilePath(p)
+		require.Error(t, err, "missing error for %q", p)
+	}
+}
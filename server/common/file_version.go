@@ -0,0 +1,64 @@
+package common
+
+import (
+	"time"
+)
+
+// FileVersion is a previous version of the data of an uploaded file
+// The file keeps its ID and URL while its data is replaced by a new version
+type FileVersion struct {
+	ID       string `json:"-"`
+	FileID   string `json:"-" gorm:"index;type:varchar(255) REFERENCES files(id) ON UPDATE RESTRICT ON DELETE RESTRICT"`
+	UploadID string `json:"-" gorm:"index"`
+	Version  int    `json:"version"`
+
+	Status string `json:"status"`
+
+	Md5  string `json:"fileMd5"`
+	Type string `json:"fileType"`
+	Size int64  `json:"fileSize"`
+
+	// Kind of the preview stored in the data backend, removed along with the version data
+	Preview string `json:"-"`
+
+	// Key of the version data in the data backend, the file ID if empty
+	DataID string `json:"-"`
+
+	// Upload date of the version
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NewFileVersion save the current version of the file data as a previous version
+func NewFileVersion(file *File) (version *FileVersion) {
+	version = new(FileVersion)
+	version.ID = GenerateRandomID(16)
+	version.FileID = file.ID
+	version.UploadID = file.UploadID
+	version.Version = file.GetVersion()
+	version.Status = file.Status
+	version.Md5 = file.Md5
+	version.Type = file.Type
+	version.Size = file.Size
+	version.Preview = file.Preview
+	version.DataID = file.GetDataID()
+	version.CreatedAt = file.CreatedAt
+	if file.VersionCreatedAt != nil {
+		version.CreatedAt = *file.VersionCreatedAt
+	}
+	return version
+}
+
+// GetDataFile returns the derived file object used to access the version data in the data backend
+func (version *FileVersion) GetDataFile() *File {
+	return &File{
+		ID:       version.FileID,
+		UploadID: version.UploadID,
+		DataID:   version.DataID,
+		Version:  version.Version,
+		Status:   version.Status,
+		Md5:      version.Md5,
+		Type:     version.Type,
+		Size:     version.Size,
+		Preview:  version.Preview,
+	}
+}
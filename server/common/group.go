@@ -0,0 +1,94 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+// GroupRoleMember can create uploads owned by the group
+const GroupRoleMember = "member"
+
+// GroupRoleManager can also manage the group members, tokens and uploads
+const GroupRoleManager = "manager"
+
+// Group is a team of users sharing the ownership of uploads and tokens
+// Group uploads are not removed when the user who created them leaves the group or deletes their account
+type Group struct {
+	ID   string `json:"id"`
+	Name string `json:"name" gorm:"unique_index:idx_group_name"`
+
+	// Quotas, 0 means unlimited
+	MaxUploads int   `json:"maxUploads"`
+	MaxSize    int64 `json:"maxSize"`
+
+	Role    string         `json:"role,omitempty" gorm:"-"`
+	Members []*GroupMember `json:"members,omitempty" gorm:"-"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GroupMember grants a role in a group to a user
+type GroupMember struct {
+	GroupID string `json:"groupId" gorm:"primary_key;type:varchar(255) REFERENCES groups(id) ON UPDATE RESTRICT ON DELETE CASCADE"`
+	UserID  string `json:"userId" gorm:"primary_key;index:idx_group_member_user;type:varchar(255) REFERENCES users(id) ON UPDATE RESTRICT ON DELETE CASCADE"`
+	Role    string `json:"role"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GroupToken is a token owned by a group, uploads created with it belong to the group
+type GroupToken struct {
+	Token   string `json:"token" gorm:"primary_key"`
+	Comment string `json:"comment,omitempty"`
+
+	GroupID string `json:"-" gorm:"index:idx_group_token_group;type:varchar(255) REFERENCES groups(id) ON UPDATE RESTRICT ON DELETE CASCADE"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NewGroup create a new group object
+func NewGroup(name string) (group *Group) {
+	group = &Group{}
+	group.ID = GenerateRandomID(16)
+	group.Name = name
+	return group
+}
+
+// IsValidGroupRole return true if the group role string is valid
+func IsValidGroupRole(role string) bool {
+	switch role {
+	case GroupRoleMember, GroupRoleManager:
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate return an error if the group parameters are invalid
+func (group *Group) Validate() error {
+	if group.Name == "" {
+		return fmt.Errorf("missing group name")
+	}
+	if len(group.Name) > 128 {
+		return fmt.Errorf("group name is too long, maximum is 128 characters")
+	}
+	if group.MaxUploads < 0 {
+		return fmt.Errorf("invalid group max uploads")
+	}
+	if group.MaxSize < 0 {
+		return fmt.Errorf("invalid group max size")
+	}
+	return nil
+}
+
+// NewToken create a new token owned by the group
+func (group *Group) NewToken() (token *GroupToken) {
+	t := NewToken()
+	token = &GroupToken{Token: t.Token, GroupID: group.ID}
+	return token
+}
+
+// IsManager return true if the member can manage the group
+func (member *GroupMember) IsManager() bool {
+	return member != nil && member.Role == GroupRoleManager
+}
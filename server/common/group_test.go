@@ -0,0 +1,54 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGroup(t *testing.T) {
+	group := NewGroup("team")
+	require.NotZero(t, group.ID, "missing group id")
+	require.Equal(t, "team", group.Name, "invalid group name")
+}
+
+func TestIsValidGroupRole(t *testing.T) {
+	require.True(t, IsValidGroupRole(GroupRoleMember))
+	require.True(t, IsValidGroupRole(GroupRoleManager))
+	require.False(t, IsValidGroupRole("admin"))
+	require.False(t, IsValidGroupRole(""))
+}
+
+func TestGroupValidate(t *testing.T) {
+	group := NewGroup("team")
+	require.NoError(t, group.Validate())
+
+	group.Name = ""
+	require.Error(t, group.Validate(), "missing error")
+
+	group = NewGroup("team")
+	group.MaxUploads = -1
+	require.Error(t, group.Validate(), "missing error")
+
+	group = NewGroup("team")
+	group.MaxSize = -1
+	require.Error(t, group.Validate(), "missing error")
+}
+
+func TestGroupNewToken(t *testing.T) {
+	group := NewGroup("team")
+	token := group.NewToken()
+	require.NotZero(t, token.Token, "missing token")
+	require.Equal(t, group.ID, token.GroupID, "invalid token group")
+}
+
+func TestGroupMemberIsManager(t *testing.T) {
+	var member *GroupMember
+	require.False(t, member.IsManager())
+
+	member = &GroupMember{Role: GroupRoleMember}
+	require.False(t, member.IsManager())
+
+	member.Role = GroupRoleManager
+	require.True(t, member.IsManager())
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"net"
+)
+
+// deniedNetworks are the loopback, private, link-local, multicast and reserved ranges
+// the server never connects to on behalf of a user unless explicitly allowed in the configuration
+var deniedNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func parseNetworks(cidrs ...string) (networks []*net.IPNet) {
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// IsDeniedAddress return true if the IP address is in a denied range and not in one of the allowed networks
+func IsDeniedAddress(ip net.IP, allowedNetworks []*net.IPNet) bool {
+	for _, network := range allowedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	for _, network := range deniedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,85 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// passwordCache remember the successful password hash checks for a limited time
+// Entries are keyed by an HMAC of the password and hash with a random key so the passwords can't be recovered
+// from the memory and a password change invalidates the cached checks
+type passwordCache struct {
+	key     []byte
+	size    int
+	ttl     time.Duration
+	entries map[string]time.Time
+	mu      sync.Mutex
+}
+
+func newPasswordCache(size int, ttl time.Duration) (cache *passwordCache) {
+	cache = &passwordCache{size: size, ttl: ttl, entries: make(map[string]time.Time)}
+
+	cache.key = make([]byte, 32)
+	_, err := rand.Read(cache.key)
+	if err != nil {
+		panic(err)
+	}
+
+	return cache
+}
+
+func (cache *passwordCache) entryKey(password string, hash string) string {
+	mac := hmac.New(sha256.New, cache.key)
+	_, _ = mac.Write([]byte(hash))
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write([]byte(password))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// check return true if the password has been successfully checked against the hash less than ttl ago
+func (cache *passwordCache) check(password string, hash string) bool {
+	key := cache.entryKey(password, hash)
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	expire, ok := cache.entries[key]
+	if !ok {
+		return false
+	}
+
+	if time.Now().After(expire) {
+		delete(cache.entries, key)
+		return false
+	}
+
+	return true
+}
+
+// add a successful password check to the cache
+func (cache *passwordCache) add(password string, hash string) {
+	key := cache.entryKey(password, hash)
+	now := time.Now()
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if len(cache.entries) >= cache.size {
+		for k, expire := range cache.entries {
+			if now.After(expire) {
+				delete(cache.entries, k)
+			}
+		}
+
+		// Start over rather than growing without limit
+		if len(cache.entries) >= cache.size {
+			cache.entries = make(map[string]time.Time)
+		}
+	}
+
+	cache.entries[key] = now.Add(cache.ttl)
+}
@@ -0,0 +1,36 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordCache(t *testing.T) {
+	cache := newPasswordCache(2, time.Hour)
+
+	require.False(t, cache.check("password", "hash"), "unexpected cached check")
+
+	cache.add("password", "hash")
+	require.True(t, cache.check("password", "hash"), "missing cached check")
+	require.False(t, cache.check("invalid", "hash"), "invalid password should not match")
+	require.False(t, cache.check("password", "other"), "password change should invalidate the cached check")
+}
+
+func TestPasswordCacheExpire(t *testing.T) {
+	cache := newPasswordCache(2, -time.Second)
+
+	cache.add("password", "hash")
+	require.False(t, cache.check("password", "hash"), "cached check should be expired")
+}
+
+func TestPasswordCacheSize(t *testing.T) {
+	cache := newPasswordCache(2, time.Hour)
+
+	cache.add("password1", "hash")
+	cache.add("password2", "hash")
+	cache.add("password3", "hash")
+	require.True(t, len(cache.entries) <= 2, "cache should not grow beyond its size")
+	require.True(t, cache.check("password3", "hash"), "missing cached check")
+}
@@ -0,0 +1,20 @@
+package common
+
+// QuickOutputText when the quick mode response is the file URL
+const QuickOutputText = "text"
+
+// QuickOutputJSON when the quick mode response is a JSON object with the file and upload admin URLs
+const QuickOutputJSON = "json"
+
+// QuickOutputShell when the quick mode response is a list of shell variable assignments to eval
+const QuickOutputShell = "shell"
+
+// IsValidQuickOutput return true if the quick mode response format is supported
+func IsValidQuickOutput(output string) bool {
+	switch output {
+	case QuickOutputText, QuickOutputJSON, QuickOutputShell:
+		return true
+	default:
+		return false
+	}
+}
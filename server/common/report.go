@@ -0,0 +1,102 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReportOpen when a report is waiting to be handled by an administrator
+const ReportOpen = "open"
+
+// ReportResolved when a moderation action has been taken on the reported content
+const ReportResolved = "resolved"
+
+// ReportDismissed when an administrator decided not to take action on the reported content
+const ReportDismissed = "dismissed"
+
+// ModerationFreeze hide the upload from everybody but the administrators pending review
+const ModerationFreeze = "freeze"
+
+// ModerationTakedown remove the content and add the file hashes to the blocklist
+const ModerationTakedown = "takedown"
+
+// ModerationRestore lift a freeze
+const ModerationRestore = "restore"
+
+// ModerationDismiss close a report without taking action
+const ModerationDismiss = "dismiss"
+
+var reportReasons = []string{"copyright", "malware", "phishing", "illegal", "spam", "other"}
+
+// Report is an abuse report about an upload or a single file, reports are handled by administrators in the moderation queue
+type Report struct {
+	ID       string `json:"id"`
+	UploadID string `json:"uploadId" gorm:"index:idx_report_upload"`
+	FileID   string `json:"fileId,omitempty"`
+
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Email   string `json:"email,omitempty"`
+
+	RemoteIP string `json:"remoteIp,omitempty"`
+	User     string `json:"user,omitempty"`
+
+	Status string `json:"status" gorm:"index:idx_report_status"`
+	Action string `json:"action,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// IsValidReportReason return true if the reason is a known report reason
+func IsValidReportReason(reason string) bool {
+	for _, r := range reportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidModerationAction return true if the action is a known moderation action
+func IsValidModerationAction(action string) bool {
+	switch action {
+	case ModerationFreeze, ModerationTakedown, ModerationRestore, ModerationDismiss:
+		return true
+	}
+	return false
+}
+
+// PrepareInsert report for database insert ( check values, generate ID, ... )
+func (report *Report) PrepareInsert() (err error) {
+	report.ID = GenerateRandomID(16)
+	report.Status = ReportOpen
+	report.Action = ""
+
+	if report.UploadID == "" {
+		return fmt.Errorf("missing upload id")
+	}
+
+	if !IsValidReportReason(report.Reason) {
+		return fmt.Errorf("invalid report reason, expected one of %v", reportReasons)
+	}
+
+	if len(report.Message) > 1024 {
+		return fmt.Errorf("report message is too long ( maximum is 1024 characters )")
+	}
+
+	if len(report.Email) > 255 {
+		return fmt.Errorf("invalid email")
+	}
+
+	return nil
+}
+
+// BlockedHash is the md5 hash of a file content that can't be uploaded anymore
+type BlockedHash struct {
+	Hash     string `json:"hash" gorm:"primary_key"`
+	ReportID string `json:"reportId,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,36 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportPrepareInsert(t *testing.T) {
+	report := &Report{UploadID: "upload", Reason: "malware", Status: ReportResolved, Action: ModerationTakedown}
+	err := report.PrepareInsert()
+	require.NoError(t, err, "unexpected error")
+	require.NotEmpty(t, report.ID, "missing report id")
+	require.Equal(t, ReportOpen, report.Status, "invalid report status")
+	require.Empty(t, report.Action, "invalid report action")
+}
+
+func TestReportPrepareInsertInvalid(t *testing.T) {
+	report := &Report{Reason: "malware"}
+	require.Error(t, report.PrepareInsert(), "missing upload id error expected")
+
+	report = &Report{UploadID: "upload", Reason: "foo"}
+	require.Error(t, report.PrepareInsert(), "invalid reason error expected")
+
+	report = &Report{UploadID: "upload", Reason: "other", Message: strings.Repeat("x", 1025)}
+	require.Error(t, report.PrepareInsert(), "message too long error expected")
+}
+
+func TestIsValidModerationAction(t *testing.T) {
+	require.True(t, IsValidModerationAction(ModerationFreeze))
+	require.True(t, IsValidModerationAction(ModerationTakedown))
+	require.True(t, IsValidModerationAction(ModerationRestore))
+	require.True(t, IsValidModerationAction(ModerationDismiss))
+	require.False(t, IsValidModerationAction("foo"))
+}
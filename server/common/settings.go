@@ -3,6 +3,18 @@ package common
 // AuthenticationSignatureKeySettingKey setting key for authentication_signature_key
 const AuthenticationSignatureKeySettingKey = "authentication_signature_key"
 
+// CleaningLeaseSettingKey setting key for the lease electing the Plik instance in charge of the cleaning
+const CleaningLeaseSettingKey = "cleaning_lease"
+
+// AntivirusLeaseSettingKey setting key for the lease electing the Plik instance in charge of the antivirus re-scans
+const AntivirusLeaseSettingKey = "antivirus_lease"
+
+// AntivirusSignaturesSettingKey setting key for the clamd signature database version the files were last scanned with
+const AntivirusSignaturesSettingKey = "antivirus_signatures"
+
+// NotificationLeaseSettingKey setting key for the lease electing the Plik instance in charge of the expiration emails
+const NotificationLeaseSettingKey = "notification_lease"
+
 // Setting is a config object meant to be shard by all Plik instances using the metadata backend
 type Setting struct {
 	Key   string `gorm:"primary_key"`
@@ -0,0 +1,204 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// DefaultShareLinkTTL is the validity period of share links created without an explicit TTL
+const DefaultShareLinkTTL = 24 * 60 * 60
+
+// ShareLink grants temporary access to a single file or to the archive of an upload without the upload credentials
+// Share links are signed with the upload share secret, rotating the secret revokes all the share links of the upload
+type ShareLink struct {
+	ID           string    `json:"id"`
+	UploadID     string    `json:"uploadId"`
+	FileID       string    `json:"fileId,omitempty"`
+	TTL          int       `json:"ttl,omitempty"`
+	MaxDownloads int       `json:"maxDownloads,omitempty"`
+	CIDR         string    `json:"cidr,omitempty"`
+	ExpireAt     time.Time `json:"expireAt"`
+
+	Token string `json:"token,omitempty"`
+	URL   string `json:"url,omitempty"`
+
+	network *net.IPNet
+}
+
+// ShareLinkDownload count the downloads of a share link with a download cap
+type ShareLinkDownload struct {
+	ID       string    `json:"id"`
+	UploadID string    `json:"uploadId" gorm:"index:idx_share_link_download_upload_id"`
+	Count    int       `json:"count"`
+	ExpireAt time.Time `json:"expireAt" gorm:"index:idx_share_link_download_expire_at"`
+}
+
+// GenerateShareSecret generate a new random upload share secret
+func GenerateShareSecret() string {
+	return GenerateRandomID(32)
+}
+
+// IsArchive return true if the share link grants access to the upload archive
+func (link *ShareLink) IsArchive() bool {
+	return link.FileID == ""
+}
+
+// PrepareInsert check the share link parameters and compute the expiration date
+func (link *ShareLink) PrepareInsert(upload *Upload) (err error) {
+	link.ID = GenerateRandomID(16)
+	link.UploadID = upload.ID
+
+	if link.FileID != "" {
+		file := upload.GetFile(link.FileID)
+		if file == nil {
+			return fmt.Errorf("file %s not found", link.FileID)
+		}
+		if file.Status != FileUploaded {
+			return fmt.Errorf("file %s is not downloadable", link.FileID)
+		}
+	}
+
+	if link.TTL < 0 {
+		return fmt.Errorf("invalid ttl")
+	}
+	if link.TTL == 0 {
+		link.TTL = DefaultShareLinkTTL
+	}
+
+	link.ExpireAt = time.Now().Add(time.Duration(link.TTL) * time.Second)
+	if upload.ExpireAt != nil && upload.ExpireAt.Before(link.ExpireAt) {
+		link.ExpireAt = *upload.ExpireAt
+	}
+
+	if link.MaxDownloads < 0 {
+		return fmt.Errorf("invalid max downloads")
+	}
+
+	return link.parseCIDR()
+}
+
+func (link *ShareLink) parseCIDR() (err error) {
+	if link.CIDR == "" {
+		return nil
+	}
+
+	cidr := link.CIDR
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return fmt.Errorf("invalid cidr %s", link.CIDR)
+		}
+		if ip.To4() != nil {
+			cidr += "/32"
+		} else {
+			cidr += "/128"
+		}
+	}
+
+	_, link.network, err = net.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid cidr %s", link.CIDR)
+	}
+
+	return nil
+}
+
+// IsAuthorizedIP return true if the share link can be used from the IP address
+func (link *ShareLink) IsAuthorizedIP(ip net.IP) bool {
+	if link.network == nil {
+		return true
+	}
+
+	return ip != nil && link.network.Contains(ip)
+}
+
+// Sign generate the signed share link token
+func (link *ShareLink) Sign(upload *Upload) (err error) {
+	if upload.ShareSecret == "" {
+		return fmt.Errorf("missing upload share secret")
+	}
+
+	token := jwt.New(jwt.SigningMethodHS256)
+	claims := token.Claims.(jwt.MapClaims)
+	claims["jti"] = link.ID
+	claims["upload"] = link.UploadID
+	claims["exp"] = link.ExpireAt.Unix()
+	if link.FileID != "" {
+		claims["file"] = link.FileID
+	}
+	if link.MaxDownloads > 0 {
+		claims["max"] = link.MaxDownloads
+	}
+	if link.CIDR != "" {
+		claims["cidr"] = link.CIDR
+	}
+
+	link.Token, err = token.SignedString([]byte(upload.ShareSecret))
+	if err != nil {
+		return fmt.Errorf("unable to sign share link : %s", err)
+	}
+
+	return nil
+}
+
+// ParseShareLink parse and validate a share link token signed with the upload share secret
+func ParseShareLink(value string, upload *Upload) (link *ShareLink, err error) {
+	if upload.ShareSecret == "" {
+		return nil, fmt.Errorf("upload has no share link")
+	}
+
+	token, err := jwt.Parse(value, func(t *jwt.Token) (interface{}, error) {
+		// Verify signing algorithm
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected siging method : %v", t.Header["alg"])
+		}
+
+		return []byte(upload.ShareSecret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+
+	link = &ShareLink{}
+
+	// jwt-go only validates the expiration date if the claim is present
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("missing expiration date")
+	}
+	link.ExpireAt = time.Unix(int64(exp), 0)
+
+	link.ID, ok = claims["jti"].(string)
+	if !ok || link.ID == "" {
+		return nil, fmt.Errorf("missing share link id")
+	}
+
+	link.UploadID, ok = claims["upload"].(string)
+	if !ok || link.UploadID != upload.ID {
+		return nil, fmt.Errorf("invalid upload id")
+	}
+
+	if file, ok := claims["file"].(string); ok {
+		link.FileID = file
+	}
+
+	if max, ok := claims["max"].(float64); ok {
+		link.MaxDownloads = int(max)
+	}
+
+	if cidr, ok := claims["cidr"].(string); ok {
+		link.CIDR = cidr
+		err = link.parseCIDR()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return link, nil
+}
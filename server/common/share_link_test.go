@@ -0,0 +1,111 @@
+package common
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newShareLinkTestUpload() (upload *Upload, file *File) {
+	upload = &Upload{}
+	upload.TTL = 3600
+	file = upload.NewFile()
+	file.Status = FileUploaded
+	upload.PrepareInsertForTests()
+	return upload, file
+}
+
+func TestShareLinkSignAndParse(t *testing.T) {
+	upload, file := newShareLinkTestUpload()
+
+	link := &ShareLink{FileID: file.ID, MaxDownloads: 3, CIDR: "10.0.0.0/8"}
+	err := link.PrepareInsert(upload)
+	require.NoError(t, err, "unable to prepare share link")
+	require.Equal(t, DefaultShareLinkTTL, link.TTL, "invalid default ttl")
+	require.False(t, link.ExpireAt.After(*upload.ExpireAt), "share link outlives upload")
+
+	err = link.Sign(upload)
+	require.NoError(t, err, "unable to sign share link")
+	require.NotEmpty(t, link.Token, "missing share link token")
+
+	parsed, err := ParseShareLink(link.Token, upload)
+	require.NoError(t, err, "unable to parse share link")
+	require.Equal(t, link.ID, parsed.ID, "invalid share link id")
+	require.Equal(t, upload.ID, parsed.UploadID, "invalid share link upload id")
+	require.Equal(t, file.ID, parsed.FileID, "invalid share link file id")
+	require.Equal(t, 3, parsed.MaxDownloads, "invalid share link max downloads")
+	require.Equal(t, link.ExpireAt.Unix(), parsed.ExpireAt.Unix(), "invalid share link expiration date")
+	require.True(t, parsed.IsAuthorizedIP(net.ParseIP("10.1.2.3")), "ip should be authorized")
+	require.False(t, parsed.IsAuthorizedIP(net.ParseIP("1.2.3.4")), "ip should not be authorized")
+	require.False(t, parsed.IsAuthorizedIP(nil), "ip should not be authorized")
+}
+
+func TestShareLinkRotatedSecret(t *testing.T) {
+	upload, _ := newShareLinkTestUpload()
+
+	link := &ShareLink{}
+	err := link.PrepareInsert(upload)
+	require.NoError(t, err, "unable to prepare share link")
+	require.True(t, link.IsArchive(), "invalid archive share link")
+
+	err = link.Sign(upload)
+	require.NoError(t, err, "unable to sign share link")
+
+	upload.ShareSecret = GenerateShareSecret()
+	_, err = ParseShareLink(link.Token, upload)
+	require.Error(t, err, "able to parse share link with rotated secret")
+}
+
+func TestShareLinkInvalidUpload(t *testing.T) {
+	upload, _ := newShareLinkTestUpload()
+
+	link := &ShareLink{}
+	err := link.PrepareInsert(upload)
+	require.NoError(t, err, "unable to prepare share link")
+	err = link.Sign(upload)
+	require.NoError(t, err, "unable to sign share link")
+
+	other, _ := newShareLinkTestUpload()
+	other.ShareSecret = upload.ShareSecret
+	_, err = ParseShareLink(link.Token, other)
+	require.Error(t, err, "able to parse share link of another upload")
+}
+
+func TestShareLinkExpired(t *testing.T) {
+	upload, _ := newShareLinkTestUpload()
+
+	link := &ShareLink{ID: "id", UploadID: upload.ID, ExpireAt: time.Now().Add(-time.Minute)}
+	err := link.Sign(upload)
+	require.NoError(t, err, "unable to sign share link")
+
+	_, err = ParseShareLink(link.Token, upload)
+	require.Error(t, err, "able to parse expired share link")
+}
+
+func TestShareLinkPrepareInsertInvalid(t *testing.T) {
+	upload, file := newShareLinkTestUpload()
+
+	err := (&ShareLink{FileID: "invalid"}).PrepareInsert(upload)
+	require.Error(t, err, "able to share missing file")
+
+	file.Status = FileMissing
+	err = (&ShareLink{FileID: file.ID}).PrepareInsert(upload)
+	require.Error(t, err, "able to share missing file")
+
+	err = (&ShareLink{TTL: -1}).PrepareInsert(upload)
+	require.Error(t, err, "able to create share link with invalid ttl")
+
+	err = (&ShareLink{MaxDownloads: -1}).PrepareInsert(upload)
+	require.Error(t, err, "able to create share link with invalid max downloads")
+
+	err = (&ShareLink{CIDR: "invalid"}).PrepareInsert(upload)
+	require.Error(t, err, "able to create share link with invalid cidr")
+
+	link := &ShareLink{CIDR: "1.2.3.4"}
+	err = link.PrepareInsert(upload)
+	require.NoError(t, err, "unable to create share link with single ip")
+	require.True(t, link.IsAuthorizedIP(net.ParseIP("1.2.3.4")), "ip should be authorized")
+	require.False(t, link.IsAuthorizedIP(net.ParseIP("1.2.3.5")), "ip should not be authorized")
+}
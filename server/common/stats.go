@@ -19,6 +19,26 @@ type UserStats struct {
 	TotalSize int64 `json:"totalSize"`
 }
 
+// GroupStats group statistics and quotas
+type GroupStats struct {
+	Uploads    int   `json:"uploads"`
+	Files      int   `json:"files"`
+	TotalSize  int64 `json:"totalSize"`
+	MaxUploads int   `json:"maxUploads"`
+	MaxSize    int64 `json:"maxSize"`
+}
+
+// CleaningStats describe what the next cleaning would delete
+type CleaningStats struct {
+	ExpiredUploads int   `json:"expiredUploads"`
+	ExpiredFiles   int   `json:"expiredFiles"`
+	ExpiredSize    int64 `json:"expiredSize"`
+	RemovedFiles   int   `json:"removedFiles"`
+	RemovedSize    int64 `json:"removedSize"`
+	DeletedUploads int   `json:"deletedUploads"`
+	UploadRequests int   `json:"expiredUploadRequests"`
+}
+
 // Helpers to build the Server Stats
 
 // AddUpload add statistics of one upload to the ServerStats
@@ -25,19 +25,38 @@ type Upload struct {
 	UploadToken string `json:"uploadToken,omitempty"`
 	User        string `json:"user,omitempty" gorm:"index:idx_upload_user"`
 	Token       string `json:"token,omitempty" gorm:"index:idx_upload_user_token"`
+	GroupID     string `json:"group,omitempty" gorm:"index:idx_upload_group"`
+	RequestID   string `json:"requestId,omitempty" gorm:"index:idx_upload_request"`
 	IsAdmin     bool   `json:"admin"`
 
 	Stream    bool `json:"stream"`
 	OneShot   bool `json:"oneShot"`
 	Removable bool `json:"removable"`
 
+	// Remove EXIF, XMP and IPTC metadata from the uploaded images
+	StripMetadata bool `json:"stripMetadata"`
+
+	// Files are removed once downloaded this number of times, 0 means unlimited
+	MaxDownloads int `json:"maxDownloads,omitempty"`
+
 	ProtectedByPassword bool   `json:"protectedByPassword"`
 	Login               string `json:"login,omitempty"`
 	Password            string `json:"password,omitempty"`
 
+	ShareSecret string `json:"-"`
+
+	// Frozen uploads can only be accessed by administrators pending moderation
+	Frozen bool `json:"frozen,omitempty"`
+
+	Restricted bool     `json:"restricted"`
+	ACL        []string `json:"acl,omitempty" gorm:"-"`
+
 	CreatedAt time.Time  `json:"createdAt"`
 	DeletedAt *time.Time `json:"-" gorm:"index:idx_upload_deleted_at"`
 	ExpireAt  *time.Time `json:"expireAt" gorm:"index:idx_upload_expire_at"`
+
+	// Expiration date the upload owner has been notified of
+	NotifiedExpireAt *time.Time `json:"-"`
 }
 
 // NewFile creates a new file and add it to the current upload
@@ -79,11 +98,20 @@ func (upload *Upload) Sanitize() {
 	upload.UploadToken = ""
 	upload.User = ""
 	upload.Token = ""
+	upload.RequestID = ""
+	upload.ACL = nil
 	for _, file := range upload.Files {
 		file.Sanitize()
 	}
 }
 
+// SanitizeForAdmin remove the upload secrets before sending the upload metadata to an administrator
+// The user, token and remote IP are kept so administrators can tell who created the upload
+func (upload *Upload) SanitizeForAdmin() {
+	upload.Password = ""
+	upload.UploadToken = ""
+}
+
 // GenerateRandomID generates a random string with specified length.
 // Used to generate upload id, tokens, ...
 func GenerateRandomID(length int) string {
@@ -111,20 +139,49 @@ func (upload *Upload) IsExpired() bool {
 func (upload *Upload) PrepareInsert(config *Configuration) (err error) {
 	upload.ID = GenerateRandomID(16)
 	upload.UploadToken = GenerateRandomID(32)
+	upload.ShareSecret = GenerateShareSecret()
+	upload.Frozen = false
 
 	// Limit number of files per upload
 	if len(upload.Files) > config.MaxFilePerUpload {
 		return fmt.Errorf("too many files. maximum is %d", config.MaxFilePerUpload)
 	}
 
-	if config.NoAnonymousUploads && upload.User == "" {
+	if config.NoAnonymousUploads && upload.User == "" && upload.GroupID == "" {
 		return fmt.Errorf("anonymous uploads are disabled")
 	}
 
-	if !config.Authentication && (upload.User != "" || upload.Token != "") {
+	if !config.Authentication && (upload.User != "" || upload.Token != "" || upload.GroupID != "") {
 		return fmt.Errorf("authentication is disabled")
 	}
 
+	err = upload.checkOptions(config)
+	if err != nil {
+		return err
+	}
+
+	err = upload.prepareACL(config)
+	if err != nil {
+		return err
+	}
+
+	err = upload.prepareTTL(config)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range upload.Files {
+		err = file.PrepareInsert(upload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkOptions verify that the upload options are enabled in the configuration
+func (upload *Upload) checkOptions(config *Configuration) (err error) {
 	if upload.OneShot && !config.OneShot {
 		return fmt.Errorf("one shot uploads are not enabled")
 	}
@@ -143,6 +200,28 @@ func (upload *Upload) PrepareInsert(config *Configuration) (err error) {
 		return fmt.Errorf("password protection is not enabled")
 	}
 
+	if upload.MaxDownloads < 0 {
+		return fmt.Errorf("invalid max downloads")
+	}
+
+	if upload.Stream && upload.MaxDownloads > 0 {
+		return fmt.Errorf("max downloads can't be set on stream uploads")
+	}
+
+	if config.ForceStripMetadata {
+		upload.StripMetadata = true
+	}
+
+	return nil
+}
+
+// IsDownloadLimited return true if the upload files can only be downloaded a limited number of times
+func (upload *Upload) IsDownloadLimited() bool {
+	return upload.OneShot || upload.MaxDownloads > 0
+}
+
+// prepareTTL validate the upload TTL and set the expiration date accordingly
+func (upload *Upload) prepareTTL(config *Configuration) (err error) {
 	// TTL = Time in second before the upload expiration
 	// 0 	-> No ttl specified : default value from configuration
 	// -1	-> No expiration : checking with configuration if that's ok
@@ -162,18 +241,12 @@ func (upload *Upload) PrepareInsert(config *Configuration) (err error) {
 		}
 	}
 
+	upload.ExpireAt = nil
 	if upload.TTL > 0 {
 		deadline := time.Now().Add(time.Duration(upload.TTL) * time.Second)
 		upload.ExpireAt = &deadline
 	}
 
-	for _, file := range upload.Files {
-		err = file.PrepareInsert(upload)
-		if err != nil {
-			return err
-		}
-	}
-
 	return nil
 }
 
@@ -183,6 +256,12 @@ func (upload *Upload) PrepareInsertForTests() {
 		upload.ID = GenerateRandomID(16)
 	}
 
+	if upload.ShareSecret == "" {
+		upload.ShareSecret = GenerateShareSecret()
+	}
+
+	upload.Restricted = len(upload.ACL) > 0
+
 	if upload.ExpireAt == nil && upload.TTL > 0 {
 		deadline := time.Now().Add(time.Duration(upload.TTL) * time.Second)
 		upload.ExpireAt = &deadline
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+// UploadFilter select uploads across all users for the administration API, zero values disable the filters
+type UploadFilter struct {
+	User  string
+	Token string
+
+	// Anonymous uploads have no owner, nil disables the filter
+	Anonymous *bool
+	// Expired uploads have not yet been removed by the cleaning routine, nil disables the filter
+	Expired *bool
+
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+
+	// Total size of the uploaded files in bytes
+	MinSize int64
+	MaxSize int64
+}
+
+// Validate return an error if the upload filter is inconsistent
+func (filter *UploadFilter) Validate() error {
+	if filter.MinSize < 0 || filter.MaxSize < 0 {
+		return fmt.Errorf("invalid size filter")
+	}
+	if filter.MaxSize > 0 && filter.MinSize > filter.MaxSize {
+		return fmt.Errorf("min size is greater than max size")
+	}
+	if filter.Anonymous != nil && *filter.Anonymous && filter.User != "" {
+		return fmt.Errorf("anonymous uploads have no user")
+	}
+	if filter.CreatedAfter != nil && filter.CreatedBefore != nil && filter.CreatedAfter.After(*filter.CreatedBefore) {
+		return fmt.Errorf("created after date is after created before date")
+	}
+	return nil
+}
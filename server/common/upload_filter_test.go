@@ -0,0 +1,23 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadFilterValidate(t *testing.T) {
+	require.NoError(t, (&UploadFilter{}).Validate(), "empty filter should be valid")
+	require.NoError(t, (&UploadFilter{MinSize: 1, MaxSize: 10}).Validate(), "size range should be valid")
+
+	require.Error(t, (&UploadFilter{MinSize: -1}).Validate(), "negative size should be invalid")
+	require.Error(t, (&UploadFilter{MinSize: 10, MaxSize: 1}).Validate(), "inverted size range should be invalid")
+
+	anonymous := true
+	require.Error(t, (&UploadFilter{User: "user", Anonymous: &anonymous}).Validate(), "anonymous user uploads should be invalid")
+
+	now := time.Now()
+	before := now.Add(-time.Hour)
+	require.Error(t, (&UploadFilter{CreatedAfter: &now, CreatedBefore: &before}).Validate(), "inverted date range should be invalid")
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+// UploadRequest let users without a Plik account upload files to a registered user ( a.k.a reverse share )
+// Anyone knowing the request ID can create uploads owned by the requesting user and only visible to this user
+type UploadRequest struct {
+	ID      string `json:"id"`
+	User    string `json:"-" gorm:"index:idx_upload_request_user;type:varchar(255) REFERENCES users(id) ON UPDATE RESTRICT ON DELETE CASCADE"`
+	Message string `json:"message"`
+	TTL     int    `json:"ttl"`
+
+	// Limits of all the files uploaded through the request, 0 means unlimited
+	MaxFiles int   `json:"maxFiles"`
+	MaxSize  int64 `json:"maxSize"`
+
+	ProtectedByPassword bool   `json:"protectedByPassword"`
+	Login               string `json:"login,omitempty"`
+	Password            string `json:"password,omitempty"`
+
+	URL string `json:"url,omitempty" gorm:"-"`
+
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpireAt  *time.Time `json:"expireAt" gorm:"index:idx_upload_request_expire_at"`
+}
+
+// IsExpired check if the upload request is expired
+func (request *UploadRequest) IsExpired() bool {
+	if request.ExpireAt != nil {
+		if time.Now().After(*request.ExpireAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrepareInsert upload request for database insert ( check configuration and default values, generate ID, ... )
+// The password must already be hashed
+func (request *UploadRequest) PrepareInsert(config *Configuration) (err error) {
+	request.ID = GenerateRandomID(16)
+
+	if !config.Authentication {
+		return fmt.Errorf("authentication is disabled")
+	}
+
+	if request.User == "" {
+		return fmt.Errorf("missing user")
+	}
+
+	if request.ProtectedByPassword && !config.ProtectedByPassword {
+		return fmt.Errorf("password protection is not enabled")
+	}
+
+	if request.MaxFiles < 0 {
+		return fmt.Errorf("invalid max files")
+	}
+
+	if request.MaxSize < 0 {
+		return fmt.Errorf("invalid max size")
+	}
+
+	// TTL = Time in second before the upload request expiration
+	// 0 	-> No ttl specified : default value from configuration
+	// -1	-> No expiration : checking with configuration if that's ok
+	switch request.TTL {
+	case 0:
+		request.TTL = config.DefaultTTL
+	case -1:
+		if config.MaxTTL != -1 {
+			return fmt.Errorf("cannot set infinite ttl (maximum allowed is : %d)", config.MaxTTL)
+		}
+	default:
+		if request.TTL <= 0 {
+			return fmt.Errorf("invalid ttl")
+		}
+		if config.MaxTTL > 0 && request.TTL > config.MaxTTL {
+			return fmt.Errorf("invalid ttl. (maximum allowed is : %d)", config.MaxTTL)
+		}
+	}
+
+	if request.TTL > 0 {
+		deadline := time.Now().Add(time.Duration(request.TTL) * time.Second)
+		request.ExpireAt = &deadline
+	}
+
+	return nil
+}
+
+// Sanitize removes sensible information from the upload request
+func (request *UploadRequest) Sanitize() {
+	request.Login = ""
+	request.Password = ""
+}
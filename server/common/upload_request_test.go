@@ -0,0 +1,65 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadRequestPrepareInsert(t *testing.T) {
+	config := NewConfiguration()
+	config.Authentication = true
+
+	request := &UploadRequest{User: "user", MaxFiles: 10}
+	err := request.PrepareInsert(config)
+	require.NoError(t, err, "unable to prepare upload request")
+	require.NotEmpty(t, request.ID, "missing upload request id")
+	require.Equal(t, config.DefaultTTL, request.TTL, "invalid default ttl")
+	require.NotNil(t, request.ExpireAt, "missing expiration date")
+	require.False(t, request.IsExpired(), "upload request should not be expired")
+}
+
+func TestUploadRequestPrepareInsertErrors(t *testing.T) {
+	config := NewConfiguration()
+
+	request := &UploadRequest{User: "user"}
+	require.Error(t, request.PrepareInsert(config), "authentication is disabled")
+
+	config.Authentication = true
+
+	request = &UploadRequest{}
+	require.Error(t, request.PrepareInsert(config), "missing user")
+
+	request = &UploadRequest{User: "user", MaxFiles: -1}
+	require.Error(t, request.PrepareInsert(config), "invalid max files")
+
+	request = &UploadRequest{User: "user", MaxSize: -1}
+	require.Error(t, request.PrepareInsert(config), "invalid max size")
+
+	request = &UploadRequest{User: "user", TTL: config.MaxTTL + 1}
+	require.Error(t, request.PrepareInsert(config), "ttl too long")
+
+	request = &UploadRequest{User: "user", TTL: -1}
+	require.Error(t, request.PrepareInsert(config), "infinite ttl")
+
+	config.ProtectedByPassword = false
+	request = &UploadRequest{User: "user", ProtectedByPassword: true}
+	require.Error(t, request.PrepareInsert(config), "password protection is disabled")
+}
+
+func TestUploadRequestIsExpired(t *testing.T) {
+	request := &UploadRequest{}
+	require.False(t, request.IsExpired(), "upload request without expiration date should not be expired")
+
+	deadline := time.Now().Add(-time.Minute)
+	request.ExpireAt = &deadline
+	require.True(t, request.IsExpired(), "upload request should be expired")
+}
+
+func TestUploadRequestSanitize(t *testing.T) {
+	request := &UploadRequest{Login: "login", Password: "password"}
+	request.Sanitize()
+	require.Empty(t, request.Login, "login not sanitized")
+	require.Empty(t, request.Password, "password not sanitized")
+}
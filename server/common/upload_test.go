@@ -28,6 +28,7 @@ func TestUploadSanitize(t *testing.T) {
 	upload.UploadToken = "token"
 	upload.Token = "token"
 	upload.User = "user"
+	upload.RequestID = "request"
 	upload.Sanitize()
 
 	require.Zero(t, upload.RemoteIP, "invalid sanitized upload")
@@ -36,6 +37,23 @@ func TestUploadSanitize(t *testing.T) {
 	require.Zero(t, upload.UploadToken, "invalid sanitized upload")
 	require.Zero(t, upload.Token, "invalid sanitized upload")
 	require.Zero(t, upload.UploadToken, "invalid sanitized upload")
+	require.Zero(t, upload.RequestID, "invalid sanitized upload")
+}
+
+func TestUploadSanitizeForAdmin(t *testing.T) {
+	upload := &Upload{}
+	upload.RemoteIP = "ip"
+	upload.Password = "password"
+	upload.UploadToken = "token"
+	upload.Token = "token"
+	upload.User = "user"
+	upload.SanitizeForAdmin()
+
+	require.Zero(t, upload.Password, "invalid sanitized upload")
+	require.Zero(t, upload.UploadToken, "invalid sanitized upload")
+	require.Equal(t, "ip", upload.RemoteIP, "invalid sanitized upload")
+	require.Equal(t, "token", upload.Token, "invalid sanitized upload")
+	require.Equal(t, "user", upload.User, "invalid sanitized upload")
 }
 
 func TestUpload_GetFile(t *testing.T) {
@@ -99,6 +117,25 @@ func TestUpload_PrepareInsertNoAuthentication(t *testing.T) {
 
 	err = upload.PrepareInsert(config)
 	require.Errorf(t, err, "authentication is disabled")
+
+	upload = &Upload{}
+	upload.GroupID = "group"
+
+	err = upload.PrepareInsert(config)
+	require.Errorf(t, err, "authentication is disabled")
+}
+
+func TestUpload_PrepareInsertGroupUploadIsNotAnonymous(t *testing.T) {
+	config := NewConfiguration()
+	config.Authentication = true
+	config.NoAnonymousUploads = true
+
+	upload := &Upload{}
+	upload.GroupID = "group"
+	upload.Token = "token"
+
+	err := upload.PrepareInsert(config)
+	require.NoError(t, err, "unexpected error")
 }
 
 func TestUpload_PrepareInsertNoOneShot(t *testing.T) {
@@ -123,6 +160,16 @@ func TestUpload_PrepareInsertNoRemovable(t *testing.T) {
 	require.Errorf(t, err, "removable uploads are not enabled")
 }
 
+func TestUpload_PrepareInsertForceStripMetadata(t *testing.T) {
+	config := NewConfiguration()
+	config.ForceStripMetadata = true
+
+	upload := &Upload{}
+	err := upload.PrepareInsert(config)
+	require.NoError(t, err, "unable to prepare upload")
+	require.True(t, upload.StripMetadata, "metadata stripping not forced")
+}
+
 func TestUpload_PrepareInsertNoStream(t *testing.T) {
 	config := NewConfiguration()
 	config.Stream = false
@@ -146,6 +193,23 @@ func TestUpload_PrepareInsertNoBasicAuth(t *testing.T) {
 	require.Errorf(t, err, "password protection is not enabled")
 }
 
+func TestUpload_PrepareInsertMaxDownloads(t *testing.T) {
+	config := NewConfiguration()
+
+	upload := &Upload{MaxDownloads: -1}
+	err := upload.PrepareInsert(config)
+	require.EqualError(t, err, "invalid max downloads")
+
+	upload = &Upload{MaxDownloads: 3, Stream: true}
+	err = upload.PrepareInsert(config)
+	require.EqualError(t, err, "max downloads can't be set on stream uploads")
+
+	upload = &Upload{MaxDownloads: 3}
+	err = upload.PrepareInsert(config)
+	require.NoError(t, err, "unable to prepare upload")
+	require.True(t, upload.IsDownloadLimited(), "download should be limited")
+}
+
 func TestUpload_PrepareInsertTTL(t *testing.T) {
 	config := NewConfiguration()
 
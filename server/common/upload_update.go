@@ -0,0 +1,153 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// UploadUpdate is a partial update of the editable upload parameters, nil fields are left unchanged
+type UploadUpdate struct {
+	Comments *string `json:"comments,omitempty"`
+
+	// Either a new TTL in seconds from now ( same values as the upload creation ) or a new expiration date
+	TTL      *int       `json:"ttl,omitempty"`
+	ExpireAt *time.Time `json:"expireAt,omitempty"`
+
+	OneShot      *bool `json:"oneShot,omitempty"`
+	Removable    *bool `json:"removable,omitempty"`
+	MaxDownloads *int  `json:"maxDownloads,omitempty"`
+
+	// Only applies to the files added after the update
+	StripMetadata *bool `json:"stripMetadata,omitempty"`
+
+	// Setting a password protects the upload, setting ProtectedByPassword to false removes the protection
+	ProtectedByPassword *bool   `json:"protectedByPassword,omitempty"`
+	Login               *string `json:"login,omitempty"`
+	Password            *string `json:"password,omitempty"`
+}
+
+// HasOptions return true if the update changes anything else than the upload comments
+func (update *UploadUpdate) HasOptions() bool {
+	return update.TTL != nil || update.ExpireAt != nil || update.OneShot != nil || update.Removable != nil || update.MaxDownloads != nil ||
+		update.StripMetadata != nil || update.ProtectedByPassword != nil || update.Login != nil || update.Password != nil
+}
+
+// Columns return the upload columns changed by the update once it has been applied to the upload
+func (update *UploadUpdate) Columns(upload *Upload) (columns map[string]interface{}) {
+	columns = make(map[string]interface{})
+
+	if update.Comments != nil {
+		columns["comments"] = upload.Comments
+	}
+	if update.OneShot != nil {
+		columns["one_shot"] = upload.OneShot
+	}
+	if update.Removable != nil {
+		columns["removable"] = upload.Removable
+	}
+	if update.MaxDownloads != nil {
+		columns["max_downloads"] = upload.MaxDownloads
+	}
+	if update.StripMetadata != nil {
+		columns["strip_metadata"] = upload.StripMetadata
+	}
+	if update.ProtectedByPassword != nil || update.Password != nil {
+		columns["protected_by_password"] = upload.ProtectedByPassword
+		columns["login"] = upload.Login
+		columns["password"] = upload.Password
+	}
+	if update.TTL != nil || update.ExpireAt != nil {
+		columns["ttl"] = upload.TTL
+		columns["expire_at"] = upload.ExpireAt
+	}
+
+	return columns
+}
+
+// Apply the update to the upload with the same rules as the upload creation
+// A new password is set in clear text and must be hashed before saving the upload
+func (update *UploadUpdate) Apply(upload *Upload, config *Configuration) (err error) {
+	if update.Comments != nil {
+		upload.Comments = *update.Comments
+	}
+
+	if update.OneShot != nil && *update.OneShot != upload.OneShot {
+		// Streamed files can only be downloaded once anyway
+		if upload.Stream {
+			return fmt.Errorf("one shot can't be changed for stream uploads")
+		}
+		upload.OneShot = *update.OneShot
+	}
+
+	if update.Removable != nil {
+		upload.Removable = *update.Removable
+	}
+
+	if update.MaxDownloads != nil {
+		upload.MaxDownloads = *update.MaxDownloads
+	}
+
+	if update.StripMetadata != nil {
+		if !*update.StripMetadata && config.ForceStripMetadata {
+			return fmt.Errorf("image metadata stripping is forced by the server")
+		}
+		upload.StripMetadata = *update.StripMetadata
+	}
+
+	if update.ProtectedByPassword != nil && !*update.ProtectedByPassword {
+		if update.Password != nil {
+			return fmt.Errorf("unable to set a password and remove the password protection at the same time")
+		}
+		upload.ProtectedByPassword = false
+		upload.Login = ""
+		upload.Password = ""
+	}
+
+	if update.Password != nil {
+		if *update.Password == "" {
+			return fmt.Errorf("missing password")
+		}
+		upload.Login = "plik"
+		if update.Login != nil && *update.Login != "" {
+			upload.Login = *update.Login
+		}
+		upload.Password = *update.Password
+		upload.ProtectedByPassword = true
+	} else if update.Login != nil {
+		return fmt.Errorf("missing password")
+	}
+
+	err = upload.checkOptions(config)
+	if err != nil {
+		return err
+	}
+
+	if update.TTL != nil && update.ExpireAt != nil {
+		return fmt.Errorf("ttl and expireAt can't be set at the same time")
+	}
+
+	if update.TTL != nil {
+		upload.TTL = *update.TTL
+		err = upload.prepareTTL(config)
+		if err != nil {
+			return err
+		}
+	}
+
+	if update.ExpireAt != nil {
+		ttl := time.Until(*update.ExpireAt).Seconds()
+		if ttl <= 0 {
+			return fmt.Errorf("invalid expiration date")
+		}
+		upload.TTL = int(math.Ceil(ttl))
+		err = upload.prepareTTL(config)
+		if err != nil {
+			return err
+		}
+		deadline := *update.ExpireAt
+		upload.ExpireAt = &deadline
+	}
+
+	return nil
+}
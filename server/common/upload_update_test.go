@@ -0,0 +1,180 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadUpdateComments(t *testing.T) {
+	upload := &Upload{Comments: "foo"}
+	comments := "bar"
+	update := &UploadUpdate{Comments: &comments}
+	require.False(t, update.HasOptions(), "comments are not an option")
+
+	err := update.Apply(upload, NewConfiguration())
+	require.NoError(t, err, "unable to apply update")
+	require.Equal(t, "bar", upload.Comments, "invalid comments")
+}
+
+func TestUploadUpdateTTL(t *testing.T) {
+	config := NewConfiguration()
+	config.MaxTTL = 86400
+
+	upload := &Upload{TTL: 60}
+	ttl := 3600
+	update := &UploadUpdate{TTL: &ttl}
+	require.True(t, update.HasOptions(), "ttl is an option")
+
+	err := update.Apply(upload, config)
+	require.NoError(t, err, "unable to apply update")
+	require.Equal(t, 3600, upload.TTL, "invalid ttl")
+	require.NotNil(t, upload.ExpireAt, "missing expiration date")
+	require.WithinDuration(t, time.Now().Add(time.Hour), *upload.ExpireAt, 5*time.Second, "invalid expiration date")
+
+	ttl = 2 * 86400
+	err = update.Apply(upload, config)
+	require.Error(t, err, "able to extend beyond max ttl")
+
+	ttl = -1
+	err = update.Apply(upload, config)
+	require.Error(t, err, "able to remove expiration")
+
+	config.MaxTTL = -1
+	err = update.Apply(upload, config)
+	require.NoError(t, err, "unable to apply update")
+	require.Nil(t, upload.ExpireAt, "upload should not expire")
+}
+
+func TestUploadUpdateExpireAt(t *testing.T) {
+	config := NewConfiguration()
+	config.MaxTTL = 86400
+
+	upload := &Upload{TTL: 60}
+	deadline := time.Now().Add(2 * time.Hour)
+	update := &UploadUpdate{ExpireAt: &deadline}
+
+	err := update.Apply(upload, config)
+	require.NoError(t, err, "unable to apply update")
+	require.Equal(t, deadline, *upload.ExpireAt, "invalid expiration date")
+	require.Equal(t, 7200, upload.TTL, "invalid ttl")
+
+	deadline = time.Now().Add(-time.Hour)
+	err = update.Apply(upload, config)
+	require.Error(t, err, "able to set an expiration date in the past")
+
+	deadline = time.Now().Add(48 * time.Hour)
+	err = update.Apply(upload, config)
+	require.Error(t, err, "able to extend beyond max ttl")
+
+	ttl := 60
+	update.TTL = &ttl
+	err = update.Apply(upload, config)
+	require.Error(t, err, "able to set both ttl and expiration date")
+}
+
+func TestUploadUpdateOptions(t *testing.T) {
+	config := NewConfiguration()
+	upload := &Upload{}
+
+	yes := true
+	update := &UploadUpdate{OneShot: &yes, Removable: &yes}
+	err := update.Apply(upload, config)
+	require.NoError(t, err, "unable to apply update")
+	require.True(t, upload.OneShot, "invalid one shot")
+	require.True(t, upload.Removable, "invalid removable")
+
+	config.Removable = false
+	err = update.Apply(upload, config)
+	require.Error(t, err, "able to enable a disabled option")
+
+	stream := &Upload{Stream: true, OneShot: false}
+	err = (&UploadUpdate{OneShot: &yes}).Apply(stream, NewConfiguration())
+	require.Error(t, err, "able to change one shot of a stream upload")
+}
+
+func TestUploadUpdateMaxDownloads(t *testing.T) {
+	config := NewConfiguration()
+	upload := &Upload{}
+
+	maxDownloads := 5
+	update := &UploadUpdate{MaxDownloads: &maxDownloads}
+	require.True(t, update.HasOptions(), "max downloads is an option")
+	err := update.Apply(upload, config)
+	require.NoError(t, err, "unable to apply update")
+	require.Equal(t, 5, upload.MaxDownloads, "invalid max downloads")
+
+	maxDownloads = -1
+	err = update.Apply(upload, config)
+	require.Error(t, err, "able to set invalid max downloads")
+}
+
+func TestUploadUpdateStripMetadata(t *testing.T) {
+	config := NewConfiguration()
+	upload := &Upload{}
+
+	yes, no := true, false
+	update := &UploadUpdate{StripMetadata: &yes}
+	require.True(t, update.HasOptions(), "strip metadata is an option")
+	err := update.Apply(upload, config)
+	require.NoError(t, err, "unable to apply update")
+	require.True(t, upload.StripMetadata, "invalid strip metadata")
+
+	config.ForceStripMetadata = true
+	err = (&UploadUpdate{StripMetadata: &no}).Apply(upload, config)
+	require.Error(t, err, "able to disable forced metadata stripping")
+}
+
+func TestUploadUpdatePassword(t *testing.T) {
+	config := NewConfiguration()
+	upload := &Upload{}
+
+	password := "secret"
+	update := &UploadUpdate{Password: &password}
+	err := update.Apply(upload, config)
+	require.NoError(t, err, "unable to apply update")
+	require.True(t, upload.ProtectedByPassword, "upload should be protected by password")
+	require.Equal(t, "plik", upload.Login, "invalid default login")
+	require.Equal(t, "secret", upload.Password, "invalid password")
+
+	no := false
+	update = &UploadUpdate{ProtectedByPassword: &no}
+	err = update.Apply(upload, config)
+	require.NoError(t, err, "unable to apply update")
+	require.False(t, upload.ProtectedByPassword, "upload should not be protected by password")
+	require.Empty(t, upload.Login, "login should be removed")
+	require.Empty(t, upload.Password, "password should be removed")
+
+	login := "login"
+	err = (&UploadUpdate{Login: &login}).Apply(upload, config)
+	require.Error(t, err, "able to set a login without a password")
+
+	config.ProtectedByPassword = false
+	err = (&UploadUpdate{Password: &password}).Apply(upload, config)
+	require.Error(t, err, "able to set a password when password protection is disabled")
+}
+
+func TestUploadUpdateColumns(t *testing.T) {
+	upload := &Upload{Comments: "foo", TTL: 60}
+	comments := "bar"
+	update := &UploadUpdate{Comments: &comments}
+
+	err := update.Apply(upload, NewConfiguration())
+	require.NoError(t, err, "unable to apply update")
+	require.Equal(t, map[string]interface{}{"comments": "bar"}, update.Columns(upload), "invalid columns")
+
+	ttl := 3600
+	password := "password"
+	update = &UploadUpdate{TTL: &ttl, Password: &password}
+	err = update.Apply(upload, NewConfiguration())
+	require.NoError(t, err, "unable to apply update")
+
+	columns := update.Columns(upload)
+	require.Len(t, columns, 5, "invalid columns")
+	require.Equal(t, 3600, columns["ttl"], "invalid ttl column")
+	require.Equal(t, upload.ExpireAt, columns["expire_at"], "invalid expire at column")
+	require.Equal(t, true, columns["protected_by_password"], "invalid protected by password column")
+	require.Equal(t, "plik", columns["login"], "invalid login column")
+	require.Equal(t, "password", columns["password"], "invalid password column")
+}
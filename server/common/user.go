@@ -14,6 +14,9 @@ const ProviderOVH = "ovh"
 // ProviderLocal for authentication
 const ProviderLocal = "local"
 
+// ProviderCert for authentication
+const ProviderCert = "cert"
+
 // User is a plik user
 type User struct {
 	ID       string `json:"id,omitempty"`
@@ -24,6 +27,11 @@ type User struct {
 	Email    string `json:"email,omitempty"`
 	IsAdmin  bool   `json:"admin"`
 
+	// Opt-in email notifications
+	NotifyDownloads      bool `json:"notifyDownloads"`
+	NotifyExpiration     bool `json:"notifyExpiration"`
+	NotifyUploadRequests bool `json:"notifyUploadRequests"`
+
 	Tokens []*Token `json:"tokens,omitempty"`
 
 	CreatedAt time.Time `json:"createdAt"`
@@ -45,7 +53,7 @@ func GetUserID(provider string, providerID string) string {
 // IsValidProvider return true if the provider string is valid
 func IsValidProvider(provider string) bool {
 	switch provider {
-	case ProviderLocal, ProviderGoogle, ProviderOVH:
+	case ProviderLocal, ProviderGoogle, ProviderOVH, ProviderCert:
 		return true
 	default:
 		return false
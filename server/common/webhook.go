@@ -0,0 +1,180 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// EventUploadCreated when an upload has been created
+const EventUploadCreated = "upload.created"
+
+// EventFileUploaded when a file has been uploaded
+const EventFileUploaded = "file.uploaded"
+
+// EventFileDownloaded when a file has been downloaded
+const EventFileDownloaded = "file.downloaded"
+
+// EventUploadRemoved when an upload has been removed by a user or an administrator
+const EventUploadRemoved = "upload.removed"
+
+// EventUploadExpired when an expired upload has been removed by the cleaning routine
+const EventUploadExpired = "upload.expired"
+
+var events = []string{EventUploadCreated, EventFileUploaded, EventFileDownloaded, EventUploadRemoved, EventUploadExpired}
+
+// WebhookDeliveryPending when a webhook delivery is waiting to be sent
+const WebhookDeliveryPending = "pending"
+
+// WebhookDeliveryFailed when a webhook delivery has been given up after too many attempts
+const WebhookDeliveryFailed = "failed"
+
+// Webhook POST upload lifecycle events to an URL
+// Webhooks are configured server-wide, or registered by a user for all his uploads or for a single upload
+type Webhook struct {
+	ID       string `json:"id"`
+	User     string `json:"-" gorm:"index:idx_webhook_user"`
+	UploadID string `json:"uploadId,omitempty" gorm:"index:idx_webhook_upload"`
+	URL      string `json:"url"`
+	Secret   string `json:"secret,omitempty"`
+
+	// Comma separated list of events, empty for every event
+	Events string `json:"events"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IsValidEvent return true if event is a known webhook event
+func IsValidEvent(event string) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate check the webhook URL and events
+// User and upload webhooks can't target an address of the denied networks unless in allowedNetworks,
+// host names are checked again once resolved when the deliveries are sent
+func (webhook *Webhook) Validate(allowedNetworks []*net.IPNet) (err error) {
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %s", webhook.URL)
+	}
+
+	if webhook.User != "" || webhook.UploadID != "" {
+		if ip := net.ParseIP(u.Hostname()); ip != nil && IsDeniedAddress(ip, allowedNetworks) {
+			return fmt.Errorf("webhook address %s is not allowed", ip)
+		}
+	}
+
+	for _, event := range webhook.GetEvents() {
+		if !IsValidEvent(event) {
+			return fmt.Errorf("invalid webhook event %s", event)
+		}
+	}
+
+	return nil
+}
+
+// PrepareInsert webhook for database insert ( validate, generate ID and secret )
+func (webhook *Webhook) PrepareInsert(allowedNetworks []*net.IPNet) (err error) {
+	webhook.ID = GenerateRandomID(16)
+
+	err = webhook.Validate(allowedNetworks)
+	if err != nil {
+		return err
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret = GenerateRandomID(32)
+	}
+
+	return nil
+}
+
+// GetEvents return the list of events the webhook is subscribed to ( empty for every event )
+func (webhook *Webhook) GetEvents() (events []string) {
+	for _, event := range strings.Split(webhook.Events, ",") {
+		event = strings.TrimSpace(event)
+		if event != "" {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Match return true if the webhook is subscribed to event
+func (webhook *Webhook) Match(event string) bool {
+	events := webhook.GetEvents()
+	if len(events) == 0 {
+		return true
+	}
+
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the JSON payload of a webhook delivery
+type Event struct {
+	ID     string    `json:"id"`
+	Type   string    `json:"type"`
+	Time   time.Time `json:"time"`
+	Upload *Upload   `json:"upload"`
+	File   *File     `json:"file,omitempty"`
+}
+
+// NewEvent create a new event, the upload and file are sanitized like in the API responses
+func NewEvent(eventType string, upload *Upload, file *File) (event *Event) {
+	event = &Event{ID: GenerateRandomID(16), Type: eventType, Time: time.Now()}
+
+	// Server-wide and group webhooks are notified of the uploads of other users
+	u := *upload
+	u.Files = nil
+	u.ShareSecret = ""
+	u.Sanitize()
+	event.Upload = &u
+
+	if file != nil {
+		f := *file
+		f.Sanitize()
+		event.File = &f
+	}
+
+	return event
+}
+
+// WebhookDelivery is a webhook request waiting in the outbox to be sent
+// The URL and secret are copied from the webhook so the event is delivered even if the webhook is removed
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhookId,omitempty"`
+	URL       string `json:"url"`
+	Secret    string `json:"-"`
+	Event     string `json:"event"`
+	Payload   string `json:"-" gorm:"type:text"`
+
+	Status        string    `json:"status" gorm:"index:idx_webhook_delivery_status"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"lastError,omitempty"`
+	NextAttemptAt time.Time `json:"nextAttemptAt" gorm:"index:idx_webhook_delivery_next_attempt_at"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SignWebhookPayload return the HMAC-SHA256 signature of the payload sent in the X-Plik-Signature header
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,101 @@
+package common
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookPrepareInsert(t *testing.T) {
+	webhook := &Webhook{URL: "https://ci.example.com/hook", Events: "file.uploaded, upload.created"}
+	err := webhook.PrepareInsert(nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, webhook.ID, "missing webhook id")
+	require.NotEmpty(t, webhook.Secret, "missing webhook secret")
+
+	webhook = &Webhook{URL: "https://ci.example.com/hook", Secret: "secret"}
+	err = webhook.PrepareInsert(nil)
+	require.NoError(t, err)
+	require.Equal(t, "secret", webhook.Secret, "invalid webhook secret")
+
+	webhook = &Webhook{URL: "ftp://ci.example.com/hook"}
+	require.Error(t, webhook.PrepareInsert(nil), "invalid URL scheme expected")
+
+	webhook = &Webhook{URL: "https://ci.example.com/hook", Events: "file.uploaded,foo"}
+	require.Error(t, webhook.PrepareInsert(nil), "invalid event expected")
+}
+
+func TestWebhookValidateDeniedAddress(t *testing.T) {
+	for _, URL := range []string{
+		"http://127.0.0.1/hook",
+		"http://10.1.2.3:8080/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+	} {
+		webhook := &Webhook{User: "user", URL: URL}
+		require.Error(t, webhook.Validate(nil), "denied address expected for %s", URL)
+
+		webhook = &Webhook{UploadID: "upload", URL: URL}
+		require.Error(t, webhook.Validate(nil), "denied address expected for %s", URL)
+
+		// Server-wide webhooks are set by the administrators
+		webhook = &Webhook{URL: URL}
+		require.NoError(t, webhook.Validate(nil), "server-wide webhook should be allowed for %s", URL)
+	}
+
+	_, network, err := net.ParseCIDR("10.1.2.0/24")
+	require.NoError(t, err)
+
+	webhook := &Webhook{User: "user", URL: "http://10.1.2.3:8080/hook"}
+	require.NoError(t, webhook.Validate([]*net.IPNet{network}), "allowed network")
+
+	webhook = &Webhook{User: "user", URL: "https://1.1.1.1/hook"}
+	require.NoError(t, webhook.Validate(nil), "public address")
+}
+
+func TestWebhookMatch(t *testing.T) {
+	webhook := &Webhook{}
+	require.True(t, webhook.Match(EventFileDownloaded))
+
+	webhook.Events = "file.uploaded, upload.created"
+	require.Equal(t, []string{EventFileUploaded, EventUploadCreated}, webhook.GetEvents())
+	require.True(t, webhook.Match(EventFileUploaded))
+	require.False(t, webhook.Match(EventFileDownloaded))
+}
+
+func TestNewEvent(t *testing.T) {
+	upload := &Upload{ID: "upload", UploadToken: "token", Password: "password", User: "user", Token: "api-token", RemoteIP: "1.2.3.4"}
+	upload.ACL = []string{ACLUserSubject("other")}
+	file := upload.NewFile()
+	file.BackendDetails = "details"
+
+	event := NewEvent(EventFileUploaded, upload, file)
+	require.NotEmpty(t, event.ID, "missing event id")
+	require.Equal(t, EventFileUploaded, event.Type)
+	require.Equal(t, "upload", event.Upload.ID)
+	require.Empty(t, event.Upload.User)
+	require.Empty(t, event.Upload.UploadToken)
+	require.Empty(t, event.Upload.Password)
+	require.Empty(t, event.Upload.Token)
+	require.Empty(t, event.Upload.RemoteIP)
+	require.Nil(t, event.Upload.ACL)
+	require.Nil(t, event.Upload.Files)
+	require.Empty(t, event.File.BackendDetails)
+
+	payload, err := json.Marshal(event)
+	require.NoError(t, err, "unable to serialize event")
+	require.NotContains(t, string(payload), "api-token", "API token leaked")
+	require.NotContains(t, string(payload), "1.2.3.4", "IP address leaked")
+
+	require.Equal(t, "token", upload.UploadToken, "upload should not be modified")
+	require.Equal(t, "api-token", upload.Token, "upload should not be modified")
+	require.Len(t, upload.ACL, 1, "upload should not be modified")
+	require.Equal(t, "details", file.BackendDetails, "file should not be modified")
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	signature := SignWebhookPayload("secret", []byte("payload"))
+	require.Equal(t, "sha256=b82fcb791acec57859b989b430a826488ce2e479fdf92326bd0a2e8375a42ba4", signature)
+}
@@ -0,0 +1,119 @@
+package context
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CheckAuthLockout verify that authentication is not locked for key or the source IP
+// because of too many failed attempts. If it is, the error response is written and false returned.
+func (ctx *Context) CheckAuthLockout(key string) bool {
+	config := ctx.GetConfig()
+	if !config.IsBruteForceProtected() {
+		return true
+	}
+
+	for _, k := range ctx.getAuthFailureKeys(key) {
+		failure, err := ctx.GetMetadataBackend().GetAuthFailure(k)
+		if err != nil {
+			ctx.InternalServerError("unable to get authentication failures", err)
+			return false
+		}
+
+		if failure != nil {
+			ctx.setAuthFailure(k)
+		}
+
+		if failure != nil && failure.IsLocked() {
+			retry := int(math.Ceil(time.Until(*failure.LockedUntil).Seconds()))
+			if resp := ctx.GetResp(); resp != nil {
+				resp.Header().Set("Retry-After", fmt.Sprintf("%d", retry))
+			}
+			ctx.TooManyRequests("too many failed authentication attempts, please retry in %d seconds", retry)
+			return false
+		}
+	}
+
+	return true
+}
+
+// AuthFailed record a failed authentication attempt for key and the source IP
+func (ctx *Context) AuthFailed(key string) {
+	log := ctx.GetLogger()
+	config := ctx.GetConfig()
+	if !config.IsBruteForceProtected() {
+		return
+	}
+
+	duration := time.Duration(config.AuthLockoutDuration) * time.Second
+	maxDuration := time.Duration(config.AuthMaxLockoutDuration) * time.Second
+
+	for _, k := range ctx.getAuthFailureKeys(key) {
+		threshold := config.AuthFailureThreshold
+		if k != key {
+			threshold = config.AuthFailureIPThreshold
+		}
+
+		failure, err := ctx.GetMetadataBackend().IncrementAuthFailure(k, threshold, duration, maxDuration)
+		if err != nil {
+			log.Warningf("unable to record authentication failure for %s : %s", k, err)
+			continue
+		}
+		ctx.setAuthFailure(k)
+
+		log.Warningf("authentication failure for %s from %s (%d consecutive failures)", k, ctx.GetSourceIP(), failure.Count)
+		if failure.IsLocked() {
+			log.Warningf("authentication for %s is locked until %s", k, failure.LockedUntil.Format(time.RFC3339))
+		}
+	}
+}
+
+// AuthSucceeded reset the failed authentication attempts for key
+// The source IP failures are not reset so that one valid credential can't be used to keep guessing others
+// Nothing is done unless CheckAuthLockout or AuthFailed have seen failed attempts for key
+func (ctx *Context) AuthSucceeded(key string) {
+	if !ctx.GetConfig().IsBruteForceProtected() || !ctx.hasAuthFailure(key) {
+		return
+	}
+
+	_, err := ctx.GetMetadataBackend().DeleteAuthFailure(key)
+	if err != nil {
+		ctx.GetLogger().Warningf("unable to reset authentication failures for %s : %s", key, err)
+	}
+}
+
+// setAuthFailure remember that there are failed authentication attempts recorded for key
+func (ctx *Context) setAuthFailure(key string) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if ctx.authFailures == nil {
+		ctx.authFailures = make(map[string]bool)
+	}
+	ctx.authFailures[key] = true
+}
+
+// hasAuthFailure return true if there are failed authentication attempts recorded for key
+func (ctx *Context) hasAuthFailure(key string) bool {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.authFailures[key]
+}
+
+func (ctx *Context) getAuthFailureKeys(key string) (keys []string) {
+	config := ctx.GetConfig()
+
+	if config.AuthFailureThreshold > 0 {
+		keys = append(keys, key)
+	}
+
+	if config.AuthFailureIPThreshold > 0 && ctx.GetSourceIP() != nil {
+		keys = append(keys, common.IPAuthFailureKey(ctx.GetSourceIP().String()))
+	}
+
+	return keys
+}
@@ -0,0 +1,70 @@
+package context
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+func newAuthFailureTestingContext(t *testing.T) (ctx *Context) {
+	config := common.NewConfiguration()
+	ctx = &Context{}
+	ctx.SetConfig(config)
+	ctx.SetLogger(config.NewLogger())
+	ctx.SetSourceIP(net.ParseIP("1.2.3.4"))
+
+	metadataBackend, err := metadata.NewBackend(&metadata.Config{Driver: "sqlite3", ConnectionString: "/tmp/plik.context.test.db", EraseFirst: true})
+	require.NoError(t, err, "unable to create metadata backend")
+	ctx.SetMetadataBackend(metadataBackend)
+
+	return ctx
+}
+
+func TestAuthSucceeded(t *testing.T) {
+	ctx := newAuthFailureTestingContext(t)
+	key := common.UploadAuthFailureKey("upload")
+
+	_, err := ctx.GetMetadataBackend().IncrementAuthFailure(key, 5, time.Minute, time.Hour)
+	require.NoError(t, err, "unable to increment auth failure")
+
+	// The failures are only reset once they have been loaded
+	ctx.AuthSucceeded(key)
+
+	failure, err := ctx.GetMetadataBackend().GetAuthFailure(key)
+	require.NoError(t, err, "unable to get auth failure")
+	require.NotNil(t, failure, "auth failure should not be reset")
+
+	require.True(t, ctx.CheckAuthLockout(key), "authentication should not be locked")
+	ctx.AuthSucceeded(key)
+
+	failure, err = ctx.GetMetadataBackend().GetAuthFailure(key)
+	require.NoError(t, err, "unable to get auth failure")
+	require.Nil(t, failure, "auth failure should be reset")
+
+	// The source IP failures are never reset
+	failure, err = ctx.GetMetadataBackend().GetAuthFailure(common.IPAuthFailureKey("1.2.3.4"))
+	require.NoError(t, err, "unable to get auth failure")
+	require.Nil(t, failure, "invalid source ip auth failure")
+}
+
+func TestAuthSucceededAfterFailure(t *testing.T) {
+	ctx := newAuthFailureTestingContext(t)
+	key := common.UploadAuthFailureKey("upload")
+
+	require.True(t, ctx.CheckAuthLockout(key), "authentication should not be locked")
+	ctx.AuthFailed(key)
+	ctx.AuthSucceeded(key)
+
+	failure, err := ctx.GetMetadataBackend().GetAuthFailure(key)
+	require.NoError(t, err, "unable to get auth failure")
+	require.Nil(t, failure, "auth failure should be reset")
+
+	failure, err = ctx.GetMetadataBackend().GetAuthFailure(common.IPAuthFailureKey("1.2.3.4"))
+	require.NoError(t, err, "unable to get auth failure")
+	require.NotNil(t, failure, "source ip auth failure should not be reset")
+}
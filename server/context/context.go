@@ -7,9 +7,14 @@ import (
 
 	"github.com/root-gg/logger"
 
+	"github.com/root-gg/plik/server/clamd"
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/email"
+	"github.com/root-gg/plik/server/fetch"
 	"github.com/root-gg/plik/server/metadata"
+	"github.com/root-gg/plik/server/policy"
+	"github.com/root-gg/plik/server/webhook"
 )
 
 // Context to be propagated throughout the middleware chain
@@ -20,16 +25,26 @@ type Context struct {
 	dataBackend         data.Backend
 	streamBackend       data.Backend
 	authenticator       *common.SessionAuthenticator
+	antivirus           *clamd.Client
+	policy              *policy.Policy
+	webhooks            *webhook.Dispatcher
+	notifier            *email.Notifier
+	fetchLimiter        *fetch.Limiter
 	pagingQuery         *common.PagingQuery
 	sourceIP            net.IP
 	upload              *common.Upload
 	file                *common.File
 	user                *common.User
 	token               *common.Token
+	shareLink           *common.ShareLink
+	group               *common.Group
+	uploadRequest       *common.UploadRequest
 	isWhitelisted       *bool
 	isUploadAdmin       bool
 	isRedirectOnFailure bool
 	isQuick             bool
+	quickOutput         string
+	authFailures        map[string]bool
 	req                 *http.Request
 	resp                http.ResponseWriter
 	mu                  sync.RWMutex
@@ -155,6 +170,86 @@ func (ctx *Context) SetAuthenticator(authenticator *common.SessionAuthenticator)
 	ctx.authenticator = authenticator
 }
 
+// GetAntivirus get antivirus from the context.
+func (ctx *Context) GetAntivirus() *clamd.Client {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.antivirus
+}
+
+// SetAntivirus set antivirus in the context
+func (ctx *Context) SetAntivirus(antivirus *clamd.Client) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.antivirus = antivirus
+}
+
+// GetPolicy get policy from the context.
+func (ctx *Context) GetPolicy() *policy.Policy {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.policy
+}
+
+// SetPolicy set policy in the context
+func (ctx *Context) SetPolicy(policy *policy.Policy) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.policy = policy
+}
+
+// GetWebhooks get webhooks from the context.
+func (ctx *Context) GetWebhooks() *webhook.Dispatcher {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.webhooks
+}
+
+// SetWebhooks set webhooks in the context
+func (ctx *Context) SetWebhooks(webhooks *webhook.Dispatcher) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.webhooks = webhooks
+}
+
+// GetNotifier get notifier from the context.
+func (ctx *Context) GetNotifier() *email.Notifier {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.notifier
+}
+
+// SetNotifier set notifier in the context
+func (ctx *Context) SetNotifier(notifier *email.Notifier) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.notifier = notifier
+}
+
+// GetFetchLimiter get fetchLimiter from the context.
+func (ctx *Context) GetFetchLimiter() *fetch.Limiter {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.fetchLimiter
+}
+
+// SetFetchLimiter set fetchLimiter in the context
+func (ctx *Context) SetFetchLimiter(fetchLimiter *fetch.Limiter) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.fetchLimiter = fetchLimiter
+}
+
 // GetPagingQuery get pagingQuery from the context.
 func (ctx *Context) GetPagingQuery() *common.PagingQuery {
 	ctx.mu.RLock()
@@ -255,6 +350,54 @@ func (ctx *Context) SetToken(token *common.Token) {
 	ctx.token = token
 }
 
+// GetShareLink get shareLink from the context.
+func (ctx *Context) GetShareLink() *common.ShareLink {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.shareLink
+}
+
+// SetShareLink set shareLink in the context
+func (ctx *Context) SetShareLink(shareLink *common.ShareLink) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.shareLink = shareLink
+}
+
+// GetGroup get group from the context.
+func (ctx *Context) GetGroup() *common.Group {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.group
+}
+
+// SetGroup set group in the context
+func (ctx *Context) SetGroup(group *common.Group) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.group = group
+}
+
+// GetUploadRequest get uploadRequest from the context.
+func (ctx *Context) GetUploadRequest() *common.UploadRequest {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.uploadRequest
+}
+
+// SetUploadRequest set uploadRequest in the context
+func (ctx *Context) SetUploadRequest(uploadRequest *common.UploadRequest) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.uploadRequest = uploadRequest
+}
+
 // IsUploadAdmin get isUploadAdmin from the context.
 func (ctx *Context) IsUploadAdmin() bool {
 	ctx.mu.RLock()
@@ -303,6 +446,22 @@ func (ctx *Context) SetQuick(isQuick bool) {
 	ctx.isQuick = isQuick
 }
 
+// GetQuickOutput get quickOutput from the context.
+func (ctx *Context) GetQuickOutput() string {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.quickOutput
+}
+
+// SetQuickOutput set quickOutput in the context
+func (ctx *Context) SetQuickOutput(quickOutput string) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.quickOutput = quickOutput
+}
+
 // GetReq get req from the context.
 func (ctx *Context) GetReq() *http.Request {
 	ctx.mu.RLock()
@@ -0,0 +1,22 @@
+package context
+
+// Detach return a new context sharing the server services of the request context
+// It is safe to use by the goroutines still running once the request has been served
+func (ctx *Context) Detach() *Context {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return &Context{
+		config:          ctx.config,
+		logger:          ctx.logger.Copy(),
+		metadataBackend: ctx.metadataBackend,
+		dataBackend:     ctx.dataBackend,
+		streamBackend:   ctx.streamBackend,
+		authenticator:   ctx.authenticator,
+		antivirus:       ctx.antivirus,
+		policy:          ctx.policy,
+		webhooks:        ctx.webhooks,
+		notifier:        ctx.notifier,
+		fetchLimiter:    ctx.fetchLimiter,
+	}
+}
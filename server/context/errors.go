@@ -54,6 +54,18 @@ func (ctx *Context) Unauthorized(message string, params ...interface{}) {
 	ctx.Fail(message, nil, http.StatusUnauthorized)
 }
 
+// TooManyRequests is a helper to generate http.StatusTooManyRequests responses
+func (ctx *Context) TooManyRequests(message string, params ...interface{}) {
+	message = fmt.Sprintf(message, params...)
+	ctx.Fail(message, nil, http.StatusTooManyRequests)
+}
+
+// UnavailableForLegalReasons is a helper to generate http.StatusUnavailableForLegalReasons responses
+func (ctx *Context) UnavailableForLegalReasons(message string, params ...interface{}) {
+	message = fmt.Sprintf(message, params...)
+	ctx.Fail(message, nil, http.StatusUnavailableForLegalReasons)
+}
+
 // MissingParameter is a helper to generate http.BadRequest responses
 func (ctx *Context) MissingParameter(message string, params ...interface{}) {
 	message = fmt.Sprintf(message, params...)
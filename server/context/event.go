@@ -0,0 +1,31 @@
+package context
+
+import (
+	"github.com/root-gg/plik/server/common"
+)
+
+// NotifyEvent queue the webhook deliveries and send the email notifications of an upload lifecycle event
+// Failing to notify the event does not fail the request
+func (ctx *Context) NotifyEvent(event string, upload *common.Upload, file *common.File) {
+	dispatcher := ctx.GetWebhooks()
+	if dispatcher != nil {
+		err := dispatcher.Notify(event, upload, file)
+		if err != nil {
+			ctx.GetLogger().Warningf("unable to queue %s webhooks : %s", event, err)
+		}
+	}
+
+	notifier := ctx.GetNotifier()
+	if notifier != nil {
+		// Users are not notified of their own downloads
+		user := ctx.GetUser()
+		if event == common.EventFileDownloaded && user != nil && user.ID == upload.User {
+			return
+		}
+
+		err := notifier.Notify(event, upload, file)
+		if err != nil {
+			ctx.GetLogger().Warningf("unable to send %s email notification : %s", event, err)
+		}
+	}
+}
@@ -13,6 +13,11 @@ my $struct = [
 	'dataBackend', 'data.Backend', { panic => 1 },
 	'streamBackend', 'data.Backend', { panic => 1 },
 	'authenticator', '*common.SessionAuthenticator', { panic => 1 },
+	'antivirus', '*clamd.Client', {},
+	'policy', '*policy.Policy', {},
+	'webhooks', '*webhook.Dispatcher', {},
+	'notifier', '*email.Notifier', {},
+	'fetchLimiter', '*fetch.Limiter', {},
 
     'pagingQuery',  '*common.PagingQuery', { panic => 1 },
 
@@ -22,11 +27,17 @@ my $struct = [
 	'file', '*common.File', {},
 	'user', '*common.User', {},
 	'token', '*common.Token', {},
+	'shareLink', '*common.ShareLink', {},
+	'group', '*common.Group', {},
+	'uploadRequest', '*common.UploadRequest', {},
 
 	'isWhitelisted', '*bool', { internal => 1 },
 	'isUploadAdmin', 'bool', {},
 	'isRedirectOnFailure', 'bool', {},
 	'isQuick', 'bool', {},
+	'quickOutput', 'string', {},
+
+	'authFailures', 'map[string]bool', { internal => 1 },
 
 	'req', '*http.Request', {},
 	'resp', 'http.ResponseWriter', {},
@@ -118,9 +129,14 @@ import (
 	"sync"
 
 	"github.com/root-gg/logger"
+	"github.com/root-gg/plik/server/clamd"
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/email"
+	"github.com/root-gg/plik/server/fetch"
 	"github.com/root-gg/plik/server/metadata"
+	"github.com/root-gg/plik/server/policy"
+	"github.com/root-gg/plik/server/webhook"
 )
 
 EOF
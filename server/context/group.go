@@ -0,0 +1,133 @@
+package context
+
+import (
+	"github.com/root-gg/plik/server/common"
+)
+
+// CheckUploadGroup verify that the upload can be created in the upload group and that the group quotas are not exceeded
+// If not, the error response is written and false returned.
+func (ctx *Context) CheckUploadGroup(upload *common.Upload) bool {
+	if upload.GroupID == "" {
+		return true
+	}
+
+	group := ctx.GetGroup()
+	if group == nil || group.ID != upload.GroupID {
+		// Users must be a member of the group to create group uploads
+		user := ctx.GetUser()
+		if user == nil {
+			ctx.Forbidden("please login to create a group upload")
+			return false
+		}
+
+		var err error
+		group, err = ctx.GetMetadataBackend().GetGroup(upload.GroupID)
+		if err != nil {
+			ctx.InternalServerError("unable to get group", err)
+			return false
+		}
+		if group == nil {
+			ctx.NotFound("group %s not found", upload.GroupID)
+			return false
+		}
+
+		member, err := ctx.GetMetadataBackend().GetGroupMember(group.ID, user.ID)
+		if err != nil {
+			ctx.InternalServerError("unable to get group member", err)
+			return false
+		}
+		if member == nil {
+			ctx.Forbidden("you are not a member of group %s", group.Name)
+			return false
+		}
+	}
+
+	if group.MaxUploads <= 0 && group.MaxSize <= 0 {
+		return true
+	}
+
+	stats, err := ctx.GetMetadataBackend().GetGroupStatistics(group)
+	if err != nil {
+		ctx.InternalServerError("unable to get group statistics", err)
+		return false
+	}
+
+	if group.MaxUploads > 0 && stats.Uploads >= group.MaxUploads {
+		ctx.Forbidden("group %s upload quota reached, limit is %d uploads", group.Name, group.MaxUploads)
+		return false
+	}
+
+	if group.MaxSize > 0 && stats.TotalSize >= group.MaxSize {
+		ctx.Forbidden("group %s size quota reached, limit is %d bytes", group.Name, group.MaxSize)
+		return false
+	}
+
+	return true
+}
+
+// GetMaxFileSize return the maximum size of a new file of the upload.
+// Group uploads are also limited by the group size quota and request uploads by the upload request size limit.
+// If a quota is already exceeded, the error response is written and false returned.
+func (ctx *Context) GetMaxFileSize(upload *common.Upload) (maxFileSize int64, ok bool) {
+	return ctx.limitFileSize(upload, ctx.GetConfig().MaxFileSize)
+}
+
+// GetMaxPasteSize return the maximum size of a new paste of the upload.
+// Pastes are limited by PasteMaxSize instead of MaxFileSize but share the same quotas.
+func (ctx *Context) GetMaxPasteSize(upload *common.Upload) (maxPasteSize int64, ok bool) {
+	return ctx.limitFileSize(upload, ctx.GetConfig().PasteMaxSize)
+}
+
+// limitFileSize lower maxFileSize to the remaining size of the group and upload request quotas
+func (ctx *Context) limitFileSize(upload *common.Upload, maxFileSize int64) (int64, bool) {
+	if upload.GroupID != "" {
+		group, err := ctx.GetMetadataBackend().GetGroup(upload.GroupID)
+		if err != nil {
+			ctx.InternalServerError("unable to get group", err)
+			return 0, false
+		}
+		if group != nil && group.MaxSize > 0 {
+			stats, err := ctx.GetMetadataBackend().GetGroupStatistics(group)
+			if err != nil {
+				ctx.InternalServerError("unable to get group statistics", err)
+				return 0, false
+			}
+
+			remaining := group.MaxSize - stats.TotalSize
+			if remaining <= 0 {
+				ctx.Forbidden("group %s size quota reached, limit is %d bytes", group.Name, group.MaxSize)
+				return 0, false
+			}
+
+			if remaining < maxFileSize {
+				maxFileSize = remaining
+			}
+		}
+	}
+
+	if upload.RequestID != "" {
+		request, ok := ctx.getUploadRequest(upload)
+		if !ok {
+			return 0, false
+		}
+		if request.MaxSize > 0 {
+			stats, err := ctx.GetMetadataBackend().GetUploadRequestStatistics(request.ID)
+			if err != nil {
+				ctx.InternalServerError("unable to get upload request statistics", err)
+				return 0, false
+			}
+
+			remaining := request.MaxSize - stats.TotalSize
+			if remaining <= 0 {
+				ctx.Forbidden("upload request size limit reached, limit is %d bytes", request.MaxSize)
+				return 0, false
+			}
+
+			if remaining < maxFileSize {
+				maxFileSize = remaining
+			}
+		}
+	}
+
+	return maxFileSize, true
+}
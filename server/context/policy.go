@@ -0,0 +1,26 @@
+package context
+
+import (
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/policy"
+)
+
+// CheckUploadPolicy ask the policy hook if the upload can be created
+func (ctx *Context) CheckUploadPolicy(upload *common.Upload) bool {
+	p := ctx.GetPolicy()
+	if p == nil {
+		return true
+	}
+
+	err := p.Check(ctx.GetLogger(), policy.NewRequest(policy.UploadStage, upload))
+	if err != nil {
+		if httpError, ok := err.(common.HTTPError); ok {
+			ctx.Fail(httpError.Message, httpError.Err, httpError.StatusCode)
+		} else {
+			ctx.InternalServerError("unable to check upload policy", err)
+		}
+		return false
+	}
+
+	return true
+}
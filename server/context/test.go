@@ -43,6 +43,16 @@ func TestUnauthorized(t *testing.T, resp *httptest.ResponseRecorder, message str
 	TestFail(t, resp, http.StatusUnauthorized, message)
 }
 
+// TestTooManyRequests is a helper to test a httptest.ResponseRecorder status
+func TestTooManyRequests(t *testing.T, resp *httptest.ResponseRecorder, message string) {
+	TestFail(t, resp, http.StatusTooManyRequests, message)
+}
+
+// TestUnavailableForLegalReasons is a helper to test a httptest.ResponseRecorder status
+func TestUnavailableForLegalReasons(t *testing.T, resp *httptest.ResponseRecorder, message string) {
+	TestFail(t, resp, http.StatusUnavailableForLegalReasons, message)
+}
+
 // TestBadRequest is a helper to test a httptest.ResponseRecorder status
 func TestBadRequest(t *testing.T, resp *httptest.ResponseRecorder, message string) {
 	TestFail(t, resp, http.StatusBadRequest, message)
@@ -4,6 +4,10 @@ import "github.com/root-gg/plik/server/common"
 
 // ConfigureUploadFromContext assign context values to upload
 func (ctx *Context) ConfigureUploadFromContext(upload *common.Upload) {
+	// Those are never set by the client
+	upload.RequestID = ""
+	upload.Frozen = false
+
 	if ctx.GetSourceIP() != nil {
 		// Set upload remote IP
 		upload.RemoteIP = ctx.GetSourceIP().String()
@@ -21,4 +25,24 @@ func (ctx *Context) ConfigureUploadFromContext(upload *common.Upload) {
 			}
 		}
 	}
+
+	// Set upload group and token for uploads created with a group token
+	group := ctx.GetGroup()
+	if group != nil {
+		upload.GroupID = group.ID
+		token := ctx.GetToken()
+		if token != nil {
+			upload.Token = token.Token
+		}
+	}
+
+	// Uploads created through an upload request are owned by the requesting user and only visible to this user
+	request := ctx.GetUploadRequest()
+	if request != nil {
+		upload.User = request.User
+		upload.Token = ""
+		upload.GroupID = ""
+		upload.RequestID = request.ID
+		upload.ACL = []string{common.ACLUserSubject(request.User)}
+	}
 }
@@ -0,0 +1,56 @@
+package context
+
+import (
+	"github.com/root-gg/plik/server/common"
+)
+
+// CheckUploadRequestFiles verify that a new file can be added to an upload created through an upload request
+// If not, the error response is written and false returned.
+func (ctx *Context) CheckUploadRequestFiles(upload *common.Upload) bool {
+	if upload.RequestID == "" {
+		return true
+	}
+
+	request, ok := ctx.getUploadRequest(upload)
+	if !ok {
+		return false
+	}
+
+	if request.MaxFiles <= 0 {
+		return true
+	}
+
+	count, err := ctx.GetMetadataBackend().CountUploadRequestFiles(request.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload request file count", err)
+		return false
+	}
+
+	if count >= request.MaxFiles {
+		ctx.Forbidden("upload request file limit reached, limit is %d files", request.MaxFiles)
+		return false
+	}
+
+	return true
+}
+
+// getUploadRequest return the upload request the upload has been created through
+// Files can't be added to the upload once the upload request has expired or has been deleted
+func (ctx *Context) getUploadRequest(upload *common.Upload) (request *common.UploadRequest, ok bool) {
+	request = ctx.GetUploadRequest()
+	if request == nil || request.ID != upload.RequestID {
+		var err error
+		request, err = ctx.GetMetadataBackend().GetUploadRequest(upload.RequestID)
+		if err != nil {
+			ctx.InternalServerError("unable to get upload request", err)
+			return nil, false
+		}
+	}
+
+	if request == nil || request.IsExpired() {
+		ctx.Forbidden("upload request has expired")
+		return nil, false
+	}
+
+	return request, true
+}
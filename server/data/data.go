@@ -13,3 +13,9 @@ type Backend interface {
 	GetFile(file *common.File) (reader io.ReadCloser, err error)
 	RemoveFile(file *common.File) (err error)
 }
+
+// RangeBackend is implemented by data backends able to read a part of a file
+// without reading it from the beginning
+type RangeBackend interface {
+	GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error)
+}
@@ -11,8 +11,9 @@ import (
 	"github.com/root-gg/plik/server/data"
 )
 
-// Ensure File Data Backend implements data.Backend interface
+// Ensure File Data Backend implements data.Backend and data.RangeBackend interfaces
 var _ data.Backend = (*Backend)(nil)
+var _ data.RangeBackend = (*Backend)(nil)
 
 // Config describes configuration for File Databackend
 type Config struct {
@@ -59,6 +60,31 @@ func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
 	return reader, nil
 }
 
+// GetFileRange implementation for file data backend will seek
+// to offset and return a reader limited to length bytes
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	_, path, err := b.getPathCompat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file %s : %s", path, err)
+	}
+
+	_, err = fh.Seek(offset, io.SeekStart)
+	if err != nil {
+		_ = fh.Close()
+		return nil, fmt.Errorf("unable to seek file %s : %s", path, err)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(fh, length), fh}, nil
+}
+
 // AddFile implementation for file data backend will creates a new file for the given upload
 // and save it on filesystem with the given file reader
 func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
@@ -113,12 +139,13 @@ func (b *Backend) getPath(file *common.File) (dir string, path string, err error
 	// it gives 3844 possibilities reaching 65535 files per
 	// directory at ~250.000.000 files uploaded.
 
-	if file == nil || file.ID == "" || len(file.ID) < 3 || len(file.UploadID) < 3 {
+	if file == nil || len(file.GetDataID()) < 3 || len(file.UploadID) < 3 {
 		return "", "", fmt.Errorf("file not initialized")
 	}
 
-	dir = fmt.Sprintf("%s/%s", b.Config.Directory, file.ID[:2])
-	path = fmt.Sprintf("%s/%s", dir, file.ID)
+	dataID := file.GetDataID()
+	dir = fmt.Sprintf("%s/%s", b.Config.Directory, dataID[:2])
+	path = fmt.Sprintf("%s/%s", dir, dataID)
 
 	return dir, path, nil
 }
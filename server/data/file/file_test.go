@@ -98,6 +98,33 @@ func TestAddFile(t *testing.T) {
 	require.Equal(t, "data", string(read), "inavlid file content")
 }
 
+func TestAddFileDataID(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.PrepareInsertForTests()
+
+	err := backend.AddFile(file, bytes.NewBufferString("version 1"))
+	require.NoError(t, err, "unable to add file")
+
+	// A new version of the file is stored under its own key
+	version := *file
+	version.DataID = common.GenerateRandomID(16)
+	err = backend.AddFile(&version, bytes.NewBufferString("version 2"))
+	require.NoError(t, err, "unable to add file version")
+
+	for f, content := range map[*common.File]string{file: "version 1", &version: "version 2"} {
+		reader, err := backend.GetFile(f)
+		require.NoError(t, err, "unable to get file")
+		read, err := ioutil.ReadAll(reader)
+		require.NoError(t, err, "unable to read file")
+		require.NoError(t, reader.Close(), "unable to close file")
+		require.Equal(t, content, string(read), "invalid file content")
+	}
+}
+
 func TestGetFileInvalidDirectory(t *testing.T) {
 	backend, clean := newBackend(t)
 	defer clean()
@@ -146,6 +173,38 @@ func TestGetFile(t *testing.T) {
 	require.Equal(t, "data", string(read), "inavlid file content")
 }
 
+func TestGetFileRange(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.PrepareInsertForTests()
+
+	err := backend.AddFile(file, bytes.NewBufferString("data data data"))
+	require.NoError(t, err, "unable to add file")
+
+	fileReader, err := backend.GetFileRange(file, 5, 4)
+	require.NoError(t, err, "unable to get file range")
+	defer fileReader.Close()
+
+	read, err := ioutil.ReadAll(fileReader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data", string(read), "invalid file content")
+}
+
+func TestGetFileRangeMissingFile(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.PrepareInsertForTests()
+
+	_, err := backend.GetFileRange(file, 0, 1)
+	require.Error(t, err, "no error with missing file")
+}
+
 func TestGetFileCompathPath(t *testing.T) {
 	backend, clean := newBackend(t)
 	defer clean()
@@ -1,9 +1,11 @@
 package s3
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -13,8 +15,9 @@ import (
 	"github.com/root-gg/plik/server/data"
 )
 
-// Ensure Swift Data Backend implements data.Backend interface
+// Ensure S3 Data Backend implements data.Backend and data.RangeBackend interfaces
 var _ data.Backend = (*Backend)(nil)
+var _ data.RangeBackend = (*Backend)(nil)
 
 // Config describes configuration for Swift data backend
 type Config struct {
@@ -121,7 +124,29 @@ func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
 		return nil, err
 	}
 
-	return b.client.GetObject(context.TODO(), b.config.Bucket, b.getObjectName(file.ID), getOpts)
+	return b.client.GetObject(context.TODO(), b.config.Bucket, b.getObjectName(file.GetDataID()), getOpts)
+}
+
+// GetFileRange implementation for S3 Data Backend
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	if length <= 0 {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	getOpts := minio.GetObjectOptions{}
+
+	// Configure server side encryption
+	getOpts.ServerSideEncryption, err = b.getServerSideEncryption(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = getOpts.SetRange(offset, offset+length-1)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.client.GetObject(context.TODO(), b.config.Bucket, b.getObjectName(file.GetDataID()), getOpts)
 }
 
 // AddFile implementation for S3 Data Backend
@@ -135,7 +160,7 @@ func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 	}
 
 	if file.Size > 0 {
-		_, err = b.client.PutObject(context.TODO(), b.config.Bucket, b.getObjectName(file.ID), fileReader, file.Size, putOpts)
+		_, err = b.client.PutObject(context.TODO(), b.config.Bucket, b.getObjectName(file.GetDataID()), fileReader, file.Size, putOpts)
 	} else {
 		// https://github.com/minio/minio-go/issues/989
 		// Minio defaults to 128MB chunks and has to actually allocate a buffer of this size before uploading the chunk
@@ -143,14 +168,14 @@ func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 		// We default to 16MB which allow to store files up to 160GB ( 10000 chunks of 16MB ), feel free to adjust this parameter to your needs.
 		putOpts.PartSize = b.config.PartSize
 
-		_, err = b.client.PutObject(context.TODO(), b.config.Bucket, b.getObjectName(file.ID), fileReader, -1, putOpts)
+		_, err = b.client.PutObject(context.TODO(), b.config.Bucket, b.getObjectName(file.GetDataID()), fileReader, -1, putOpts)
 	}
 	return err
 }
 
 // RemoveFile implementation for S3 Data Backend
 func (b *Backend) RemoveFile(file *common.File) (err error) {
-	return b.client.RemoveObject(context.TODO(), b.config.Bucket, b.getObjectName(file.ID), minio.RemoveObjectOptions{})
+	return b.client.RemoveObject(context.TODO(), b.config.Bucket, b.getObjectName(file.GetDataID()), minio.RemoveObjectOptions{})
 }
 
 func (b *Backend) getObjectName(name string) string {
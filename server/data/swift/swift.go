@@ -1,8 +1,10 @@
 package swift
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 
 	"github.com/ncw/swift"
 	"github.com/root-gg/utils"
@@ -11,8 +13,9 @@ import (
 	"github.com/root-gg/plik/server/data"
 )
 
-// Ensure Swift Data Backend implements data.Backend interface
+// Ensure Swift Data Backend implements data.Backend and data.RangeBackend interfaces
 var _ data.Backend = (*Backend)(nil)
+var _ data.RangeBackend = (*Backend)(nil)
 
 // Config describes configuration for Swift data backend
 type Config struct {
@@ -64,6 +67,29 @@ func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
 	return reader, nil
 }
 
+// GetFileRange implementation for Swift Data Backend
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	if length <= 0 {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	err = b.auth()
+	if err != nil {
+		return nil, err
+	}
+
+	headers := swift.Headers{"Range": fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)}
+
+	reader, pipeWriter := io.Pipe()
+	objectID := objectID(file)
+	go func() {
+		_, err := b.connection.ObjectGet(b.config.Container, objectID, pipeWriter, false, headers)
+		_ = pipeWriter.CloseWithError(err)
+	}()
+
+	return reader, nil
+}
+
 // AddFile implementation for Swift Data Backend
 func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 	err = b.auth()
@@ -103,7 +129,7 @@ func (b *Backend) RemoveFile(file *common.File) (err error) {
 }
 
 func objectID(file *common.File) string {
-	return file.UploadID + "." + file.ID
+	return file.UploadID + "." + file.GetDataID()
 }
 
 func (b *Backend) auth() (err error) {
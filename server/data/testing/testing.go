@@ -11,8 +11,9 @@ import (
 	"github.com/root-gg/plik/server/data"
 )
 
-// Ensure Testing Data Backend implements data.Backend interface
+// Ensure Testing Data Backend implements data.Backend and data.RangeBackend interfaces
 var _ data.Backend = (*Backend)(nil)
+var _ data.RangeBackend = (*Backend)(nil)
 
 // Backend object
 type Backend struct {
@@ -39,13 +40,40 @@ func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
 		return nil, b.err
 	}
 
-	if content, ok := b.files[file.ID]; ok {
+	if content, ok := b.files[file.GetDataID()]; ok {
 		return ioutil.NopCloser(bytes.NewBuffer(content)), nil
 	}
 
 	return nil, errors.New("file not found")
 }
 
+// GetFileRange implementation for testing data backend return a reader
+// over length bytes of the file content starting at offset
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	content, ok := b.files[file.GetDataID()]
+	if !ok {
+		return nil, errors.New("file not found")
+	}
+
+	if offset < 0 || length < 0 || offset > int64(len(content)) {
+		return nil, errors.New("invalid range")
+	}
+
+	end := offset + length
+	if end > int64(len(content)) {
+		end = int64(len(content))
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(content[offset:end])), nil
+}
+
 // AddFile implementation for testing data backend will creates a new file for the given upload
 // and save it on filesystem with the given file reader
 func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
@@ -56,7 +84,7 @@ func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 		return b.err
 	}
 
-	if _, ok := b.files[file.ID]; ok {
+	if _, ok := b.files[file.GetDataID()]; ok {
 		return errors.New("file exists")
 	}
 
@@ -65,7 +93,7 @@ func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 		return err
 	}
 
-	b.files[file.ID] = content
+	b.files[file.GetDataID()] = content
 
 	return nil
 }
@@ -80,7 +108,7 @@ func (b *Backend) RemoveFile(file *common.File) (err error) {
 		return b.err
 	}
 
-	delete(b.files, file.ID)
+	delete(b.files, file.GetDataID())
 
 	return nil
 }
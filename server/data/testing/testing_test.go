@@ -3,6 +3,7 @@ package file
 import (
 	"bytes"
 	"errors"
+	"io/ioutil"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -71,6 +72,25 @@ func TestGetFile(t *testing.T) {
 	require.NoError(t, err, "unable to get file")
 }
 
+func TestGetFileRange(t *testing.T) {
+	backend := NewBackend()
+	upload := &common.Upload{}
+	file := upload.NewFile()
+
+	err := backend.AddFile(file, bytes.NewBufferString("data data data"))
+	require.NoError(t, err, "unable to add file")
+
+	reader, err := backend.GetFileRange(file, 5, 100)
+	require.NoError(t, err, "unable to get file range")
+
+	content, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data data", string(content), "invalid file content")
+
+	_, err = backend.GetFileRange(file, 100, 1)
+	require.Error(t, err, "missing error")
+}
+
 func TestRemoveFileError(t *testing.T) {
 	backend := NewBackend()
 	backend.SetError(errors.New("error"))
@@ -0,0 +1,158 @@
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// Mailer send plain text emails through an SMTP server
+type Mailer struct {
+	address  string
+	host     string
+	username string
+	password string
+	from     string
+	tls      bool
+	insecure bool
+	timeout  time.Duration
+}
+
+// NewMailer create a new SMTP mailer from the configuration ( return nil if no SMTP server is configured )
+func NewMailer(config *common.Configuration) (mailer *Mailer, err error) {
+	if config.SMTPAddress == "" {
+		return nil, nil
+	}
+
+	host, _, err := net.SplitHostPort(config.SMTPAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP address %s : %s", config.SMTPAddress, err)
+	}
+
+	mailer = &Mailer{
+		address:  config.SMTPAddress,
+		host:     host,
+		username: config.SMTPUsername,
+		password: config.SMTPPassword,
+		from:     config.SMTPFrom,
+		tls:      config.SMTPTLS,
+		insecure: config.SMTPInsecure,
+		timeout:  time.Duration(config.SMTPTimeout) * time.Second,
+	}
+
+	return mailer, nil
+}
+
+// Send a plain text email
+func (m *Mailer) Send(to string, subject string, body string) (err error) {
+	message, err := m.newMessage(to, subject, body)
+	if err != nil {
+		return fmt.Errorf("unable to build email : %s", err)
+	}
+
+	conn, err := m.dial()
+	if err != nil {
+		return fmt.Errorf("unable to connect to SMTP server : %s", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// The deadline covers the whole SMTP session
+	err = conn.SetDeadline(time.Now().Add(m.timeout))
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return fmt.Errorf("unable to initialize SMTP session : %s", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if !m.tls {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			err = client.StartTLS(m.tlsConfig())
+			if err != nil {
+				return fmt.Errorf("unable to start TLS : %s", err)
+			}
+		}
+	}
+
+	if m.username != "" {
+		err = client.Auth(smtp.PlainAuth("", m.username, m.password, m.host))
+		if err != nil {
+			return fmt.Errorf("SMTP authentication failed : %s", err)
+		}
+	}
+
+	err = client.Mail(m.from)
+	if err != nil {
+		return fmt.Errorf("SMTP server rejected sender : %s", err)
+	}
+
+	err = client.Rcpt(to)
+	if err != nil {
+		return fmt.Errorf("SMTP server rejected recipient %s : %s", to, err)
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("unable to send email : %s", err)
+	}
+
+	_, err = writer.Write(message)
+	if err != nil {
+		return fmt.Errorf("unable to send email : %s", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return fmt.Errorf("unable to send email : %s", err)
+	}
+
+	return client.Quit()
+}
+
+func (m *Mailer) dial() (conn net.Conn, err error) {
+	dialer := &net.Dialer{Timeout: m.timeout}
+	if m.tls {
+		return tls.DialWithDialer(dialer, "tcp", m.address, m.tlsConfig())
+	}
+	return dialer.Dial("tcp", m.address)
+}
+
+func (m *Mailer) tlsConfig() *tls.Config {
+	return &tls.Config{ServerName: m.host, InsecureSkipVerify: m.insecure}
+}
+
+// newMessage build a RFC 5322 message with a quoted-printable UTF-8 body
+func (m *Mailer) newMessage(to string, subject string, body string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	_, _ = fmt.Fprintf(buf, "From: %s\r\n", m.from)
+	_, _ = fmt.Fprintf(buf, "To: %s\r\n", to)
+	_, _ = fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	_, _ = fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	_, _ = fmt.Fprintf(buf, "Message-ID: <%s@%s>\r\n", common.GenerateRandomID(32), m.host)
+	_, _ = fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	_, _ = fmt.Fprintf(buf, "Content-Type: text/plain; charset=utf-8\r\n")
+	_, _ = fmt.Fprintf(buf, "Content-Transfer-Encoding: quoted-printable\r\n")
+	_, _ = fmt.Fprintf(buf, "\r\n")
+
+	writer := quotedprintable.NewWriter(buf)
+	_, err := writer.Write([]byte(body))
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
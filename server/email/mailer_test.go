@@ -0,0 +1,83 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	smtp_test "github.com/root-gg/plik/server/email/testing"
+)
+
+func newTestMailer(t *testing.T) (mailer *Mailer, server *smtp_test.Server) {
+	server, err := smtp_test.NewServer()
+	require.NoError(t, err, "unable to start fake SMTP server")
+
+	config := common.NewConfiguration()
+	config.SMTPAddress = server.Address()
+	config.SMTPFrom = "plik@root.gg"
+	config.SMTPTimeout = 5
+
+	mailer, err = NewMailer(config)
+	require.NoError(t, err, "unable to create mailer")
+	require.NotNil(t, mailer, "missing mailer")
+
+	return mailer, server
+}
+
+func TestNewMailerDisabled(t *testing.T) {
+	mailer, err := NewMailer(common.NewConfiguration())
+	require.NoError(t, err, "unable to create mailer")
+	require.Nil(t, mailer, "mailer should be disabled")
+}
+
+func TestSend(t *testing.T) {
+	mailer, server := newTestMailer(t)
+	defer func() { _ = server.Close() }()
+
+	err := mailer.Send("user@root.gg", "Hello élève", "first line\nsecond line with a very long content that needs to be wrapped by the quoted printable encoding to fit in 76 characters\n")
+	require.NoError(t, err, "unable to send email")
+
+	message, err := server.Next(time.Second)
+	require.NoError(t, err, "missing email")
+	require.Equal(t, "plik@root.gg", message.From, "invalid sender")
+	require.Equal(t, []string{"user@root.gg"}, message.To, "invalid recipients")
+	require.Equal(t, "Hello élève", message.Subject, "invalid subject")
+	require.Equal(t, "first line\nsecond line with a very long content that needs to be wrapped by the quoted printable encoding to fit in 76 characters\n", message.Body, "invalid body")
+	require.Empty(t, message.Username, "unexpected authentication")
+}
+
+func TestSendAuthentication(t *testing.T) {
+	mailer, server := newTestMailer(t)
+	defer func() { _ = server.Close() }()
+
+	mailer.username = "plik"
+	mailer.password = "secret"
+
+	err := mailer.Send("user@root.gg", "subject", "body")
+	require.NoError(t, err, "unable to send email")
+
+	message, err := server.Next(time.Second)
+	require.NoError(t, err, "missing email")
+	require.Equal(t, "plik", message.Username, "invalid username")
+}
+
+func TestSendRejected(t *testing.T) {
+	mailer, server := newTestMailer(t)
+	defer func() { _ = server.Close() }()
+
+	server.Reject("no such user")
+
+	err := mailer.Send("user@root.gg", "subject", "body")
+	require.Error(t, err, "able to send email")
+	require.Contains(t, err.Error(), "no such user", "invalid error")
+}
+
+func TestSendUnreachable(t *testing.T) {
+	mailer, server := newTestMailer(t)
+	require.NoError(t, server.Close())
+
+	err := mailer.Send("user@root.gg", "subject", "body")
+	require.Error(t, err, "able to send email")
+}
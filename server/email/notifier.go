@@ -0,0 +1,150 @@
+package email
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/root-gg/logger"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+// ExpirationNoticeDelay is how long before the expiration of their uploads the users are notified
+const ExpirationNoticeDelay = 24 * time.Hour
+
+// Notifier send the email notifications the users opted-in to
+type Notifier struct {
+	config          *common.Configuration
+	metadataBackend *metadata.Backend
+	mailer          *Mailer
+	log             *logger.Logger
+}
+
+// NewNotifier create a new email notifier
+func NewNotifier(config *common.Configuration, metadataBackend *metadata.Backend, mailer *Mailer) (notifier *Notifier) {
+	notifier = &Notifier{config: config, metadataBackend: metadataBackend, mailer: mailer}
+	notifier.log = config.NewLogger()
+	return notifier
+}
+
+// Notify the upload owner of an upload lifecycle event
+// The user is resolved synchronously but the email is sent in the background to not delay the request
+func (n *Notifier) Notify(eventType string, upload *common.Upload, file *common.File) (err error) {
+	var template *messageTemplate
+	var optIn func(user *common.User) bool
+
+	switch eventType {
+	case common.EventFileDownloaded:
+		template = downloadTemplate
+		optIn = func(user *common.User) bool { return user.NotifyDownloads }
+	case common.EventFileUploaded:
+		if upload.RequestID == "" {
+			return nil
+		}
+		template = uploadRequestTemplate
+		optIn = func(user *common.User) bool { return user.NotifyUploadRequests }
+	default:
+		return nil
+	}
+
+	if upload.User == "" || file == nil {
+		return nil
+	}
+
+	user, err := n.metadataBackend.GetUser(upload.User)
+	if err != nil {
+		return fmt.Errorf("unable to get user %s : %s", upload.User, err)
+	}
+	if user == nil || user.Email == "" || !optIn(user) {
+		return nil
+	}
+
+	message, err := template.render(n.newMessageData(user, upload, file))
+	if err != nil {
+		return fmt.Errorf("unable to render %s email : %s", eventType, err)
+	}
+
+	go func() {
+		err := n.mailer.Send(user.Email, message.Subject, message.Body)
+		if err != nil {
+			n.log.Warningf("unable to send %s email to %s : %s", eventType, user.Email, err)
+		}
+	}()
+
+	return nil
+}
+
+// NotifyExpiringUploads warn the users about their uploads expiring in less than ExpirationNoticeDelay
+// Every upload is only notified once for a given expiration date
+func (n *Notifier) NotifyExpiringUploads(limit int) (notified int, err error) {
+	uploads, err := n.metadataBackend.GetExpiringUploads(time.Now().Add(ExpirationNoticeDelay), limit)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get expiring uploads : %s", err)
+	}
+
+	users := make(map[string]*common.User)
+
+	var errors []error
+	for _, upload := range uploads {
+		user, ok := users[upload.User]
+		if !ok {
+			user, err = n.metadataBackend.GetUser(upload.User)
+			if err != nil {
+				errors = append(errors, err)
+				continue
+			}
+			users[upload.User] = user
+		}
+
+		if user != nil && user.Email != "" && user.NotifyExpiration {
+			err = n.notifyExpiringUpload(user, upload)
+			if err != nil {
+				errors = append(errors, err)
+				continue
+			}
+			notified++
+		}
+
+		// Don't look at this upload again until its expiration date changes
+		err = n.metadataBackend.SetUploadExpirationNotified(upload)
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	if len(errors) > 0 {
+		return notified, fmt.Errorf("unable to notify %d expiring uploads : %s", len(errors), errors[0])
+	}
+
+	return notified, nil
+}
+
+func (n *Notifier) notifyExpiringUpload(user *common.User, upload *common.Upload) (err error) {
+	files, err := n.metadataBackend.GetFiles(upload.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.Status == common.FileUploaded {
+			upload.Files = append(upload.Files, file)
+		}
+	}
+
+	message, err := expirationTemplate.render(n.newMessageData(user, upload, nil))
+	if err != nil {
+		return err
+	}
+
+	return n.mailer.Send(user.Email, message.Subject, message.Body)
+}
+
+func (n *Notifier) newMessageData(user *common.User, upload *common.Upload, file *common.File) *messageData {
+	return &messageData{
+		User:   user,
+		Upload: upload,
+		File:   file,
+		URL:    n.config.GetPublicURL() + "/#/?id=" + upload.ID,
+	}
+}
@@ -0,0 +1,157 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	smtp_test "github.com/root-gg/plik/server/email/testing"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+func newTestNotifier(t *testing.T) (notifier *Notifier, server *smtp_test.Server) {
+	mailer, server := newTestMailer(t)
+
+	metadataBackendConfig := &metadata.Config{Driver: "sqlite3", ConnectionString: "/tmp/plik.test.db", EraseFirst: true}
+	metadataBackend, err := metadata.NewBackend(metadataBackendConfig)
+	require.NoError(t, err, "unable to create metadata backend")
+
+	config := common.NewConfiguration()
+	config.PublicURL = "https://plik.root.gg"
+
+	return NewNotifier(config, metadataBackend, mailer), server
+}
+
+func createTestUser(t *testing.T, notifier *Notifier) (user *common.User) {
+	user = common.NewUser(common.ProviderLocal, "user")
+	user.Login = "user"
+	user.Name = "Plik User"
+	user.Email = "user@root.gg"
+	user.NotifyDownloads = true
+	user.NotifyExpiration = true
+	user.NotifyUploadRequests = true
+	err := notifier.metadataBackend.CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+	return user
+}
+
+func createTestUpload(t *testing.T, notifier *Notifier, upload *common.Upload) {
+	upload.PrepareInsertForTests()
+	err := notifier.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+}
+
+func TestNotifyDownload(t *testing.T) {
+	notifier, server := newTestNotifier(t)
+	defer func() { _ = server.Close() }()
+
+	user := createTestUser(t, notifier)
+	upload := &common.Upload{User: user.ID}
+	file := upload.NewFile()
+	file.Name = "report.pdf"
+	createTestUpload(t, notifier, upload)
+
+	err := notifier.Notify(common.EventFileDownloaded, upload, file)
+	require.NoError(t, err, "unable to notify download")
+
+	message, err := server.Next(time.Second)
+	require.NoError(t, err, "missing email")
+	require.Equal(t, []string{user.Email}, message.To, "invalid recipient")
+	require.Equal(t, "[Plik] report.pdf has been downloaded", message.Subject, "invalid subject")
+	require.Contains(t, message.Body, "Hello Plik User", "invalid body")
+	require.Contains(t, message.Body, "https://plik.root.gg/#/?id="+upload.ID, "invalid body")
+}
+
+func TestNotifyOptOut(t *testing.T) {
+	notifier, server := newTestNotifier(t)
+	defer func() { _ = server.Close() }()
+
+	user := createTestUser(t, notifier)
+	user.NotifyDownloads = false
+	err := notifier.metadataBackend.UpdateUser(user)
+	require.NoError(t, err, "unable to update user")
+
+	upload := &common.Upload{User: user.ID}
+	file := upload.NewFile()
+	createTestUpload(t, notifier, upload)
+
+	err = notifier.Notify(common.EventFileDownloaded, upload, file)
+	require.NoError(t, err, "unable to notify download")
+
+	// Anonymous uploads have nobody to notify
+	anonymous := &common.Upload{}
+	anonymousFile := anonymous.NewFile()
+	createTestUpload(t, notifier, anonymous)
+
+	err = notifier.Notify(common.EventFileDownloaded, anonymous, anonymousFile)
+	require.NoError(t, err, "unable to notify download")
+
+	_, err = server.Next(100 * time.Millisecond)
+	require.Error(t, err, "unexpected email")
+}
+
+func TestNotifyUploadRequest(t *testing.T) {
+	notifier, server := newTestNotifier(t)
+	defer func() { _ = server.Close() }()
+
+	user := createTestUser(t, notifier)
+
+	// Only files uploaded through an upload request are notified
+	upload := &common.Upload{User: user.ID}
+	file := upload.NewFile()
+	createTestUpload(t, notifier, upload)
+
+	err := notifier.Notify(common.EventFileUploaded, upload, file)
+	require.NoError(t, err, "unable to notify upload")
+
+	upload = &common.Upload{User: user.ID, RequestID: "request"}
+	file = upload.NewFile()
+	file.Name = "contract.pdf"
+	createTestUpload(t, notifier, upload)
+
+	err = notifier.Notify(common.EventFileUploaded, upload, file)
+	require.NoError(t, err, "unable to notify upload")
+
+	message, err := server.Next(time.Second)
+	require.NoError(t, err, "missing email")
+	require.Equal(t, "[Plik] You received contract.pdf", message.Subject, "invalid subject")
+	require.Equal(t, 0, server.Count(), "unexpected email")
+}
+
+func TestNotifyExpiringUploads(t *testing.T) {
+	notifier, server := newTestNotifier(t)
+	defer func() { _ = server.Close() }()
+
+	user := createTestUser(t, notifier)
+
+	other := common.NewUser(common.ProviderLocal, "other")
+	other.Email = "other@root.gg"
+	err := notifier.metadataBackend.CreateUser(other)
+	require.NoError(t, err, "unable to create user")
+
+	soon := time.Now().Add(time.Hour)
+	upload := &common.Upload{User: user.ID, ExpireAt: &soon}
+	file := upload.NewFile()
+	file.Name = "backup.tar"
+	file.Status = common.FileUploaded
+	createTestUpload(t, notifier, upload)
+
+	createTestUpload(t, notifier, &common.Upload{User: other.ID, ExpireAt: &soon})
+
+	notified, err := notifier.NotifyExpiringUploads(0)
+	require.NoError(t, err, "unable to notify expiring uploads")
+	require.Equal(t, 1, notified, "invalid notified upload count")
+
+	message, err := server.Next(time.Second)
+	require.NoError(t, err, "missing email")
+	require.Equal(t, []string{user.Email}, message.To, "invalid recipient")
+	require.Contains(t, message.Body, "backup.tar", "invalid body")
+	require.Contains(t, message.Body, "https://plik.root.gg/#/?id="+upload.ID, "invalid body")
+
+	// Uploads are only notified once
+	notified, err = notifier.NotifyExpiringUploads(0)
+	require.NoError(t, err, "unable to notify expiring uploads")
+	require.Equal(t, 0, notified, "invalid notified upload count")
+}
@@ -0,0 +1,96 @@
+package email
+
+import (
+	"bytes"
+	"text/template"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// Message is a rendered email
+type Message struct {
+	Subject string
+	Body    string
+}
+
+// messageData is the data available to the email templates
+type messageData struct {
+	User   *common.User
+	Upload *common.Upload
+	File   *common.File
+	URL    string
+}
+
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+var funcs = template.FuncMap{
+	"date": func(date *time.Time) string {
+		if date == nil {
+			return "never"
+		}
+		return date.Format("Mon, 02 Jan 2006 15:04 MST")
+	},
+}
+
+func newMessageTemplate(name string, subject string, body string) *messageTemplate {
+	return &messageTemplate{
+		subject: template.Must(template.New(name + "_subject").Funcs(funcs).Parse(subject)),
+		body:    template.Must(template.New(name).Funcs(funcs).Parse(body)),
+	}
+}
+
+func (t *messageTemplate) render(data *messageData) (message *Message, err error) {
+	subject := &bytes.Buffer{}
+	err = t.subject.Execute(subject, data)
+	if err != nil {
+		return nil, err
+	}
+
+	body := &bytes.Buffer{}
+	err = t.body.Execute(body, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{Subject: subject.String(), Body: body.String()}, nil
+}
+
+var downloadTemplate = newMessageTemplate("download", `[Plik] {{.File.Name}} has been downloaded`, `Hello {{if .User.Name}}{{.User.Name}}{{else}}{{.User.Login}}{{end}},
+
+Your file {{.File.Name}} has just been downloaded.
+{{if .Upload.OneShot}}
+This was a one shot upload, the file is not available anymore.
+{{end}}
+Upload : {{.URL}}
+
+--
+You received this email because you enabled download notifications in your Plik account.
+`)
+
+var expirationTemplate = newMessageTemplate("expiration", `[Plik] Your upload expires on {{date .Upload.ExpireAt}}`, `Hello {{if .User.Name}}{{.User.Name}}{{else}}{{.User.Login}}{{end}},
+
+Your upload {{.Upload.ID}} expires on {{date .Upload.ExpireAt}}.
+{{range .Upload.Files}}
+  - {{.Name}}{{end}}
+
+Open it to extend its expiration date before the files are removed :
+
+{{.URL}}
+
+--
+You received this email because you enabled expiration notifications in your Plik account.
+`)
+
+var uploadRequestTemplate = newMessageTemplate("upload_request", `[Plik] You received {{.File.Name}}`, `Hello {{if .User.Name}}{{.User.Name}}{{else}}{{.User.Login}}{{end}},
+
+You received {{.File.Name}} through one of your upload requests.
+
+Upload : {{.URL}}
+
+--
+You received this email because you enabled upload request notifications in your Plik account.
+`)
@@ -0,0 +1,203 @@
+package testing
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is an email received by the fake SMTP server
+type Message struct {
+	From     string
+	To       []string
+	Username string
+	Subject  string
+	Body     string
+	Header   mail.Header
+}
+
+// Server is a fake SMTP server accepting every email over a local TCP socket
+type Server struct {
+	listener net.Listener
+	messages chan *Message
+	reject   string
+	mu       sync.Mutex
+}
+
+// NewServer start a new fake SMTP server
+func NewServer() (s *Server, err error) {
+	s = &Server{messages: make(chan *Message, 100)}
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	go s.serve()
+	return s, nil
+}
+
+// Address return the host:port to configure the mailer with
+func (s *Server) Address() string {
+	return s.listener.Addr().String()
+}
+
+// Reject make the server reject the recipients with message
+func (s *Server) Reject(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = message
+}
+
+// Next wait for the next received email
+func (s *Server) Next(timeout time.Duration) (message *Message, err error) {
+	select {
+	case message = <-s.messages:
+		return message, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("no email received after %s", timeout)
+	}
+}
+
+// Count return the number of received emails not consumed by Next yet
+func (s *Server) Count() int {
+	return len(s.messages)
+}
+
+// Close stop the fake SMTP server
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	text := textproto.NewConn(conn)
+	reply := func(code int, message string) {
+		_ = text.PrintfLine("%d %s", code, message)
+	}
+
+	reply(220, "localhost fake SMTP server")
+
+	message := &Message{}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			_ = text.PrintfLine("250-localhost")
+			_ = text.PrintfLine("250-8BITMIME")
+			_ = text.PrintfLine("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "HELO"):
+			reply(250, "localhost")
+		case strings.HasPrefix(command, "AUTH PLAIN "):
+			credentials, err := base64.StdEncoding.DecodeString(line[len("AUTH PLAIN "):])
+			if err != nil {
+				reply(501, "invalid credentials")
+				continue
+			}
+			fields := strings.Split(string(credentials), "\x00")
+			if len(fields) != 3 {
+				reply(501, "invalid credentials")
+				continue
+			}
+			message.Username = fields[1]
+			reply(235, "authenticated")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			message.From = trimAddress(line[len("MAIL FROM:"):])
+			reply(250, "OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.mu.Lock()
+			reject := s.reject
+			s.mu.Unlock()
+			if reject != "" {
+				reply(550, reject)
+				continue
+			}
+			message.To = append(message.To, trimAddress(line[len("RCPT TO:"):]))
+			reply(250, "OK")
+		case command == "DATA":
+			reply(354, "end data with <CR><LF>.<CR><LF>")
+			err = parseMessage(message, text.DotReader())
+			if err != nil {
+				reply(554, err.Error())
+				continue
+			}
+			s.messages <- message
+			message = &Message{Username: message.Username}
+			reply(250, "OK")
+		case command == "RSET":
+			message = &Message{Username: message.Username}
+			reply(250, "OK")
+		case command == "NOOP":
+			reply(250, "OK")
+		case command == "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "command not implemented")
+		}
+	}
+}
+
+func trimAddress(address string) string {
+	address = strings.TrimSpace(address)
+	if i := strings.Index(address, " "); i >= 0 {
+		address = address[:i]
+	}
+	return strings.Trim(address, "<>")
+}
+
+func parseMessage(message *Message, reader io.Reader) (err error) {
+	// DotReader must be drained before replying
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		return err
+	}
+	message.Header = msg.Header
+
+	message.Subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		return err
+	}
+
+	body := msg.Body
+	if strings.EqualFold(msg.Header.Get("Content-Transfer-Encoding"), "quoted-printable") {
+		body = quotedprintable.NewReader(body)
+	}
+
+	buf, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	message.Body = strings.Replace(string(buf), "\r\n", "\n", -1)
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package fetch
+
+import (
+	goContext "context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// ForbiddenError is returned when a URL or an address is not allowed by the fetch policy
+type ForbiddenError struct {
+	msg string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.msg
+}
+
+func forbidden(format string, params ...interface{}) error {
+	return &ForbiddenError{msg: fmt.Sprintf(format, params...)}
+}
+
+// Client download remote files while protecting the internal network of the server
+type Client struct {
+	allowedHosts    []string
+	allowedNetworks []*net.IPNet
+	maxRedirects    int
+	client          *http.Client
+}
+
+// NewClient create a new fetch client from the configuration
+func NewClient(config *common.Configuration) (client *Client) {
+	return newClient(config.FetchAllowedHosts, config.GetFetchAllowedNetworks(), config.FetchMaxRedirects, time.Duration(config.FetchTimeout)*time.Second)
+}
+
+// NewWebhookClient create the HTTP client sending the webhook deliveries of the users
+// Webhooks can target any host but the denied networks are protected like for the server-side fetch
+func NewWebhookClient(config *common.Configuration) *http.Client {
+	return newClient(nil, config.GetFetchAllowedNetworks(), config.FetchMaxRedirects, time.Duration(config.WebhookTimeout)*time.Second).client
+}
+
+func newClient(allowedHosts []string, allowedNetworks []*net.IPNet, maxRedirects int, timeout time.Duration) (client *Client) {
+	client = &Client{
+		allowedHosts:    allowedHosts,
+		allowedNetworks: allowedNetworks,
+		maxRedirects:    maxRedirects,
+	}
+
+	// The address is checked once resolved so a host name can't point to a denied address
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return forbidden("invalid address %s", host)
+			}
+			return client.CheckAddress(ip)
+		},
+	}
+
+	client.client = &http.Client{
+		Transport: &http.Transport{
+			// Never go through a proxy as it would be the only address checked
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > client.maxRedirects {
+				return forbidden("too many redirects (limit is set to %d)", client.maxRedirects)
+			}
+			return client.CheckURL(req.URL)
+		},
+		Timeout: timeout,
+	}
+
+	return client
+}
+
+// CheckURL returns an error if the URL is not allowed by the fetch policy
+// Host names are only resolved when connecting
+func (c *Client) CheckURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return forbidden("invalid URL scheme %q, expected http or https", u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return forbidden("missing URL host")
+	}
+
+	if len(c.allowedHosts) > 0 && !c.isAllowedHost(host) {
+		return forbidden("host %s is not allowed", host)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return c.CheckAddress(ip)
+	}
+
+	return nil
+}
+
+// isAllowedHost return true if the host matches the allowed hosts
+// Entries starting with a dot also match the sub-domains
+func (c *Client) isAllowedHost(host string) bool {
+	for _, allowed := range c.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || host == strings.TrimPrefix(allowed, ".") {
+			return true
+		}
+		if strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckAddress returns an error if the IP address is in a denied range and not explicitly allowed
+func (c *Client) CheckAddress(ip net.IP) error {
+	if common.IsDeniedAddress(ip, c.allowedNetworks) {
+		return forbidden("address %s is not allowed", ip)
+	}
+	return nil
+}
+
+// Get the remote file, the caller must close the response body
+// Only successful responses are returned
+func (c *Client) Get(ctx goContext.Context, u *url.URL) (resp *http.Response, err error) {
+	err = c.CheckURL(u)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", "plik/"+common.GetBuildInfo().Version)
+
+	resp, err = c.client.Do(req)
+	if err != nil {
+		// Don't leak the credentials of the URL in the error message
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+// GetSource returns the URL without credentials nor query string so it can be displayed to the users
+func GetSource(u *url.URL) string {
+	source := *u
+	source.User = nil
+	source.RawQuery = ""
+	source.ForceQuery = false
+	source.Fragment = ""
+	return source.String()
+}
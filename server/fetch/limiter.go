@@ -0,0 +1,49 @@
+package fetch
+
+import (
+	"sync"
+)
+
+// Limiter limit the number of concurrent fetches server-wide and for each user
+// A limit of 0 means no limit
+type Limiter struct {
+	max       int
+	maxPerKey int
+	count     int
+	counts    map[string]int
+	mu        sync.Mutex
+}
+
+// NewLimiter create a new fetch limiter
+func NewLimiter(max int, maxPerKey int) (limiter *Limiter) {
+	return &Limiter{max: max, maxPerKey: maxPerKey, counts: make(map[string]int)}
+}
+
+// Acquire a fetch slot for key, return false if the server-wide or the key limit is reached
+func (l *Limiter) Acquire(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.max > 0 && l.count >= l.max {
+		return false
+	}
+	if l.maxPerKey > 0 && l.counts[key] >= l.maxPerKey {
+		return false
+	}
+
+	l.count++
+	l.counts[key]++
+	return true
+}
+
+// Release a fetch slot acquired for key
+func (l *Limiter) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.count--
+	l.counts[key]--
+	if l.counts[key] <= 0 {
+		delete(l.counts, key)
+	}
+}
@@ -0,0 +1,33 @@
+package fetch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	limiter := NewLimiter(3, 2)
+
+	require.True(t, limiter.Acquire("user1"))
+	require.True(t, limiter.Acquire("user1"))
+	require.False(t, limiter.Acquire("user1"), "user limit should be reached")
+
+	require.True(t, limiter.Acquire("user2"))
+	require.False(t, limiter.Acquire("user3"), "server-wide limit should be reached")
+
+	limiter.Release("user1")
+	require.True(t, limiter.Acquire("user3"))
+	require.False(t, limiter.Acquire("user1"), "server-wide limit should be reached")
+
+	limiter.Release("user2")
+	limiter.Release("user3")
+	require.True(t, limiter.Acquire("user1"))
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	limiter := NewLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		require.True(t, limiter.Acquire("user"))
+	}
+}
@@ -4,24 +4,38 @@ import (
 	"crypto/md5"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/archive"
+	"github.com/root-gg/plik/server/clamd"
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/metadata"
+	"github.com/root-gg/plik/server/policy"
+	"github.com/root-gg/plik/server/preview"
+	"github.com/root-gg/plik/server/strip"
 )
 
 type preprocessOutputReturn struct {
 	size     int64
 	md5sum   string
 	mimeType string
+	blocked  bool
 	err      error
 }
 
+type scanOutputReturn struct {
+	result *clamd.Result
+	err    error
+}
+
 // AddFile add a file to an existing upload.
 func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
 	log := ctx.GetLogger()
-	config := ctx.GetConfig()
 
 	// Get upload from context
 	upload := ctx.GetUpload()
@@ -36,15 +50,88 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 	}
 
 	// Get file handle form multipart request
+	// Quick mode PUT requests send the raw file data as the request body
 	var fileReader io.Reader
+	var fileName string
+	var filePath string
+	if req.Method == "PUT" {
+		fileReader = req.Body
+		fileName = mux.Vars(req)["filename"]
+	} else {
+		var ok bool
+		fileReader, fileName, filePath, ok = readMultipartFile(ctx, req)
+		if !ok {
+			return
+		}
+	}
+	if fileName == "" {
+		ctx.MissingParameter("file name")
+		return
+	}
+
+	// Group and upload request uploads are also limited by the group size quota and the request size limit
+	maxFileSize, ok := ctx.GetMaxFileSize(upload)
+	if !ok {
+		return
+	}
+
+	// Get file from context
+	file := ctx.GetFile()
+	if file == nil {
+		file, ok = createFile(ctx, upload, fileName, filePath)
+		if !ok {
+			return
+		}
+	} else {
+		if file.Name != fileName {
+			ctx.BadRequest("invalid file name")
+			return
+		}
+	}
+
+	// Update request logger prefix
+	prefix := fmt.Sprintf("%s[%s]", log.Prefix, file.Name)
+	log.SetPrefix(prefix)
+
+	if file.Status != common.FileMissing {
+		ctx.BadRequest("invalid file status %s, expected %s", file.Status, common.FileMissing)
+		return
+	}
+
+	// Update file status
+	err := ctx.GetMetadataBackend().UpdateFileStatus(file, file.Status, common.FileUploading)
+	if err != nil {
+		ctx.InternalServerError("unable to update file status", err)
+		return
+	}
+
+	err = saveFile(ctx, upload, file, fileReader, maxFileSize)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	// Remove all private information (ip, data backend details, ...) before
+	// sending metadata back to the client
+	file.Sanitize()
+
+	if ctx.IsQuick() {
+		url := getDownloadURL(ctx.GetConfig(), fmt.Sprintf("/file/%s/%s/%s", upload.ID, file.ID, file.Name))
+		writeQuickResponse(ctx, resp, upload, file, url)
+	} else {
+		common.WriteJSONResponse(resp, file)
+	}
+}
+
+// readMultipartFile read the multipart body until the "file" part
+// An optional "path" part sets the directory of the file inside the upload
+func readMultipartFile(ctx *context.Context, req *http.Request) (fileReader io.Reader, fileName string, filePath string, ok bool) {
 	multiPartReader, err := req.MultipartReader()
 	if err != nil {
 		ctx.InvalidParameter("multipart form : %s", err)
-		return
+		return nil, "", "", false
 	}
 
-	// Read multipart body until the "file" part
-	var fileName string
 	for {
 		part, errPart := multiPartReader.NextPart()
 		if errPart == io.EOF {
@@ -52,7 +139,16 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		}
 		if errPart != nil {
 			ctx.InvalidParameter("multipart form : %s", errPart)
-			return
+			return nil, "", "", false
+		}
+		if part.FormName() == "path" {
+			value, err := ioutil.ReadAll(io.LimitReader(part, 1025))
+			if err != nil {
+				ctx.InvalidParameter("multipart form : %s", err)
+				return nil, "", "", false
+			}
+			filePath = string(value)
+			continue
 		}
 		if part.FormName() == "file" {
 			fileReader = part
@@ -62,76 +158,137 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 	}
 	if fileReader == nil {
 		ctx.MissingParameter("file from multipart form")
-		return
+		return nil, "", "", false
 	}
 	if fileName == "" {
 		ctx.MissingParameter("file name from multipart form")
-		return
+		return nil, "", "", false
 	}
 
-	// Get file from context
-	file := ctx.GetFile()
-	if file == nil {
-		count, err := ctx.GetMetadataBackend().CountUploadFiles(upload.ID)
-		if err != nil {
-			ctx.InternalServerError("unable get upload file count", err)
-			return
-		}
+	return fileReader, fileName, filePath, true
+}
 
-		if count >= config.MaxFilePerUpload {
-			// TODO there is a slight race condition here
-			// THIS SHOULD BE A DB CONSTRAINT
-			ctx.BadRequest("maximum number file per upload reached, limit is %d", config.MaxFilePerUpload)
-			return
-		}
+// createFile add a new file to the upload after checking the upload file limits
+func createFile(ctx *context.Context, upload *common.Upload, fileName string, filePath string) (file *common.File, ok bool) {
+	config := ctx.GetConfig()
 
-		// Create a new file object
-		file = common.NewFile()
-		file.Name = fileName
+	count, err := ctx.GetMetadataBackend().CountUploadFiles(upload.ID)
+	if err != nil {
+		ctx.InternalServerError("unable get upload file count", err)
+		return nil, false
+	}
 
-		// Set and verify parameters
-		err = file.PrepareInsert(upload)
-		if err != nil {
-			ctx.BadRequest(err.Error())
-			return
-		}
+	if count >= config.MaxFilePerUpload {
+		// TODO there is a slight race condition here
+		// THIS SHOULD BE A DB CONSTRAINT
+		ctx.BadRequest("maximum number file per upload reached, limit is %d", config.MaxFilePerUpload)
+		return nil, false
+	}
 
-		// Update metadata
-		err = ctx.GetMetadataBackend().CreateFile(file)
-		if err != nil {
-			ctx.InternalServerError("unable to create file", err)
-			return
-		}
-	} else {
-		if file.Name != fileName {
-			ctx.BadRequest("invalid file name")
-			return
-		}
+	// Uploads created through an upload request are also limited by the upload request file limit
+	if !ctx.CheckUploadRequestFiles(upload) {
+		return nil, false
 	}
 
-	// Update request logger prefix
-	prefix := fmt.Sprintf("%s[%s]", log.Prefix, file.Name)
-	log.SetPrefix(prefix)
+	// Create a new file object
+	file = common.NewFile()
+	file.Name = fileName
+	file.Path = filePath
 
-	if file.Status != common.FileMissing {
-		ctx.BadRequest("invalid file status %s, expected %s", file.Status, common.FileMissing)
-		return
+	// Set and verify parameters
+	err = file.PrepareInsert(upload)
+	if err != nil {
+		ctx.BadRequest(err.Error())
+		return nil, false
 	}
 
-	// Update file status
-	err = ctx.GetMetadataBackend().UpdateFileStatus(file, file.Status, common.FileUploading)
+	// Update metadata
+	err = ctx.GetMetadataBackend().CreateFile(file)
 	if err != nil {
-		ctx.InternalServerError("unable to update file status", err)
-		return
+		ctx.InternalServerError("unable to create file", err)
+		return nil, false
+	}
+
+	return file, true
+}
+
+// saveFile stream the file data to the data backend and update the file metadata
+// The file status must be uploading, errors are common.HTTPError if they should be reported to the client
+func saveFile(ctx *context.Context, upload *common.Upload, file *common.File, fileReader io.Reader, maxFileSize int64) (err error) {
+	log := ctx.GetLogger()
+
+	blocked, err := storeFile(ctx, upload, file, fileReader, maxFileSize)
+	if blocked && !upload.Stream {
+		// Let the cleaning routine delete the blocked or rejected content from the data backend
+		errStatus := ctx.GetMetadataBackend().UpdateFileStatus(file, common.FileUploading, common.FileRemoved)
+		if errStatus != nil {
+			log.Warningf("unable to remove blocked file : %s", errStatus)
+		}
+	}
+	if err != nil {
+		// TODO : file status is left to common.FileUploading we should set it to some common.FileUploadError
+		// TODO : or we can set it back to common.FileMissing if we are sure data backends will handle that
+		return err
+	}
+
+	// Update file metadata
+	err = ctx.GetMetadataBackend().UpdateFile(file, common.FileUploading)
+	if err != nil {
+		return common.NewHTTPError("unable to update file metadata", err, http.StatusInternalServerError)
+	}
+
+	if file.Status == common.FileQuarantined {
+		return common.NewHTTPError(fmt.Sprintf("file has been quarantined by the antivirus : %s", file.Virus), nil, http.StatusBadRequest)
+	}
+
+	if file.Status == common.FileUploaded {
+		fileUploaded(ctx, upload, file)
+	}
+
+	return nil
+}
+
+// storeFile stream the file data to the data backend and fill-in the file information and status
+// blocked is true if the data has reached the data backend but has been rejected by the blocklist or the upload policy
+func storeFile(ctx *context.Context, upload *common.Upload, file *common.File, fileReader io.Reader, maxFileSize int64) (blocked bool, err error) {
+	log := ctx.GetLogger()
+
+	// Remove the EXIF, XMP and IPTC metadata of the images before anything else
+	// so the size, md5sum and content type are computed from the stored data
+	var stripReader *strip.Reader
+	if upload.StripMetadata {
+		stripReader = strip.NewReader(fileReader)
+		defer func() { _ = stripReader.Close() }()
+		fileReader = stripReader
 	}
 
 	// Pipe file data from the request body to a preprocessing goroutine
 	//  - Guess content type
 	//  - Compute/Limit upload size
 	//  - Compute md5sum
+	//  - Check the md5sum against the blocklist
+	//  - Check the file against the external upload policy
 	preprocessReader, preprocessWriter := io.Pipe()
 	preprocessOutputCh := make(chan preprocessOutputReturn)
-	go preprocessor(ctx, fileReader, preprocessWriter, preprocessOutputCh)
+
+	// Tee file data to clamd in parallel of the data backend
+	// Streamed files never reach the data backend so they can't be quarantined
+	var scanWriter *io.PipeWriter
+	var scanOutputCh chan scanOutputReturn
+	if ctx.GetAntivirus() != nil && !upload.Stream {
+		var scanReader *io.PipeReader
+		scanReader, scanWriter = io.Pipe()
+		scanOutputCh = make(chan scanOutputReturn, 1)
+		go scanner(ctx, scanReader, scanOutputCh)
+	}
+
+	var policyRequest *policy.Request
+	if ctx.GetPolicy() != nil {
+		policyRequest = policy.NewRequest(policy.FileStage, upload)
+		policyRequest.FileName = file.Name
+	}
+
+	go preprocessor(ctx, fileReader, maxFileSize, preprocessWriter, scanWriter, policyRequest, preprocessOutputCh)
 
 	// Save file in the data backend
 	var backend data.Backend
@@ -143,24 +300,19 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 
 	err = backend.AddFile(file, preprocessReader)
 	if err != nil {
-		// TODO : file status is left to common.FileUploading we should set it to some common.FileUploadError
-		// TODO : or we can set it back to common.FileMissing if we are sure data backends will handle that
-		ctx.InternalServerError("unable to save file", err)
-		return
+		return false, common.NewHTTPError("unable to save file", err, http.StatusInternalServerError)
 	}
 
 	// Get preprocessor goroutine output
 	preprocessOutput := <-preprocessOutputCh
 	if preprocessOutput.err != nil {
-		// TODO : file status is left to common.FileUploading we should set it to some common.FileUploadError
-		// TODO : or we can set it back to common.FileMissing if we are sure data backends will handle that
-		handleHTTPError(ctx, preprocessOutput.err)
-		return
+		return preprocessOutput.blocked, preprocessOutput.err
 	}
 
 	// Fill-in file information
 	file.Type = preprocessOutput.mimeType
 	file.Size = preprocessOutput.size
+	file.MetadataStripped = stripReader != nil && stripReader.Stripped()
 	file.Md5 = preprocessOutput.md5sum
 
 	// Update file status
@@ -171,45 +323,71 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		file.Status = common.FileUploaded
 	}
 
-	// Update file metadata
-	err = ctx.GetMetadataBackend().UpdateFile(file, common.FileUploading)
-	if err != nil {
-		ctx.InternalServerError("unable to update file metadata", err)
-		return
+	// Files can't be downloaded until the antivirus says they are clean
+	if scanOutputCh != nil {
+		scanOutput := <-scanOutputCh
+		if scanOutput.err == clamd.ErrSizeLimitExceeded {
+			// Scanning it again would fail the same way
+			if ctx.GetConfig().ClamdQuarantineOversized {
+				file.Status = common.FileQuarantined
+				file.Virus = clamd.SizeLimitSignature
+			} else {
+				log.Warningf("file %s is too big to be scanned by the antivirus", file.ID)
+			}
+		} else if scanOutput.err != nil {
+			// The antivirus routine will scan the file again later
+			log.Warningf("unable to scan file : %s", scanOutput.err)
+			file.Status = common.FileScanning
+		} else if scanOutput.result.Infected {
+			file.Status = common.FileQuarantined
+			file.Virus = scanOutput.result.Signature
+		}
 	}
 
-	// Remove all private information (ip, data backend details, ...) before
-	// sending metadata back to the client
-	file.Sanitize()
+	return preprocessOutput.blocked, nil
+}
 
-	if ctx.IsQuick() {
-		// Print the file url in the response.
-		var url string
-		if ctx.GetConfig().GetDownloadDomain() != nil {
-			url = ctx.GetConfig().GetDownloadDomain().String()
-		} else {
-			url = ctx.GetConfig().GetServerURL().String()
-		}
+// fileUploaded notify the upload of the file and start the background indexing and preview generation
+func fileUploaded(ctx *context.Context, upload *common.Upload, file *common.File) {
+	log := ctx.GetLogger()
 
-		url += fmt.Sprintf("/file/%s/%s/%s", upload.ID, file.ID, file.Name)
+	ctx.NotifyEvent(common.EventFileUploaded, upload, file)
 
-		_, _ = resp.Write([]byte(url + "\n"))
-	} else {
-		common.WriteJSONResponse(resp, file)
+	// Index archive members in the background so they can be browsed and extracted
+	if !upload.IsDownloadLimited() && archive.GetFormat(file.Name) != nil {
+		go func(metadataBackend *metadata.Backend, dataBackend data.Backend, file common.File) {
+			err := indexArchive(metadataBackend, dataBackend, &file)
+			if err != nil {
+				log.Warningf("unable to index archive %s : %s", file.ID, err)
+			}
+		}(ctx.GetMetadataBackend(), ctx.GetDataBackend(), *file)
+	}
+
+	// Generate the thumbnail or text snippet in the background
+	if ctx.GetConfig().Previews && !upload.IsDownloadLimited() && preview.GetKind(file.Type) != common.PreviewNone {
+		go func(metadataBackend *metadata.Backend, dataBackend data.Backend, file common.File, maxFileSize int64) {
+			err := generatePreview(metadataBackend, dataBackend, &file, maxFileSize)
+			if err != nil {
+				log.Warningf("unable to generate preview of file %s : %s", file.ID, err)
+			}
+		}(ctx.GetMetadataBackend(), ctx.GetDataBackend(), *file, ctx.GetConfig().PreviewMaxFileSize)
 	}
 }
 
 //  - Guess content type
 //  - Compute/Limit upload size
 //  - Compute md5sum
-func preprocessor(ctx *context.Context, file io.Reader, preprocessWriter io.WriteCloser, outputCh chan preprocessOutputReturn) {
+//  - Check the md5sum against the blocklist
+//  - Forward data to the antivirus if scanWriter is not nil
+//  - Check the file against the external upload policy if policyRequest is not nil
+func preprocessor(ctx *context.Context, file io.Reader, maxFileSize int64, preprocessWriter io.WriteCloser, scanWriter *io.PipeWriter, policyRequest *policy.Request, outputCh chan preprocessOutputReturn) {
 	log := ctx.GetLogger()
-	config := ctx.GetConfig()
 
 	var err error
 	var totalBytes int64
 	var mimeType string
 	var md5sum string
+	var rejected bool
 
 	md5Hash := md5.New()
 	buf := make([]byte, 1048)
@@ -224,6 +402,9 @@ func preprocessor(ctx *context.Context, file io.Reader, preprocessWriter io.Writ
 			if bytesRead <= 0 {
 				break
 			}
+		} else if strip.IsInvalidImage(err) {
+			err = common.NewHTTPError("unable to strip image metadata", err, http.StatusBadRequest)
+			break
 		} else if err != nil {
 			err = common.NewHTTPError("unable to read data from request body : %s", err, http.StatusInternalServerError)
 			break
@@ -232,14 +413,23 @@ func preprocessor(ctx *context.Context, file io.Reader, preprocessWriter io.Writ
 		// Detect the content-type using the 512 first bytes
 		if totalBytes == 0 {
 			mimeType = http.DetectContentType(buf)
+
+			// Ask the policy hook before accepting any data
+			if policyRequest != nil {
+				err = checkFilePolicy(ctx, policyRequest, mimeType, buf[:bytesRead])
+				if err != nil {
+					rejected = true
+					break
+				}
+			}
 		}
 
 		// Increment size
 		totalBytes += int64(bytesRead)
 
 		// Check upload max size limit
-		if int64(totalBytes) > config.MaxFileSize {
-			err = common.NewHTTPError(fmt.Sprintf("file too big (limit is set to %d bytes)", config.MaxFileSize), nil, http.StatusBadRequest)
+		if int64(totalBytes) > maxFileSize {
+			err = common.NewHTTPError(fmt.Sprintf("file too big (limit is set to %d bytes)", maxFileSize), nil, http.StatusBadRequest)
 			break
 		}
 
@@ -260,6 +450,22 @@ func preprocessor(ctx *context.Context, file io.Reader, preprocessWriter io.Writ
 			err = fmt.Errorf("invalid number of bytes written. Expected %d but got %d", bytesRead, bytesWritten)
 			break
 		}
+
+		// Forward data to the antivirus ( the scanner drains the pipe even if clamd fails )
+		if scanWriter != nil {
+			_, err = scanWriter.Write(buf[:bytesRead])
+			if err != nil {
+				err = fmt.Errorf("unable to forward data to the antivirus : %s", err)
+				break
+			}
+		}
+	}
+
+	// Empty files never enter the read loop
+	if err == nil && totalBytes == 0 && policyRequest != nil {
+		mimeType = http.DetectContentType(nil)
+		err = checkFilePolicy(ctx, policyRequest, mimeType, nil)
+		rejected = err != nil
 	}
 
 	errClose := preprocessWriter.Close()
@@ -267,12 +473,66 @@ func preprocessor(ctx *context.Context, file io.Reader, preprocessWriter io.Writ
 		log.Warningf("unable to close preprocessWriter : %s", err)
 	}
 
+	if scanWriter != nil {
+		if err != nil {
+			_ = scanWriter.CloseWithError(err)
+		} else {
+			_ = scanWriter.Close()
+		}
+	}
+
 	if err != nil {
-		outputCh <- preprocessOutputReturn{err: err}
+		outputCh <- preprocessOutputReturn{blocked: rejected, err: err}
 	} else {
 		md5sum = fmt.Sprintf("%x", md5Hash.Sum(nil))
-		outputCh <- preprocessOutputReturn{size: totalBytes, md5sum: md5sum, mimeType: mimeType}
+
+		// Content taken down by the administrators can't be uploaded again
+		blocked, err := ctx.GetMetadataBackend().IsBlockedHash(md5sum)
+		if err != nil {
+			outputCh <- preprocessOutputReturn{err: common.NewHTTPError("unable to check file hash", err, http.StatusInternalServerError)}
+		} else if blocked {
+			err = common.NewHTTPError("this file content has been blocked by an administrator", nil, http.StatusUnavailableForLegalReasons)
+			outputCh <- preprocessOutputReturn{blocked: true, err: err}
+		} else if err = checkCompletePolicy(ctx, policyRequest, totalBytes, md5sum); err != nil {
+			outputCh <- preprocessOutputReturn{blocked: true, err: err}
+		} else {
+			outputCh <- preprocessOutputReturn{size: totalBytes, md5sum: md5sum, mimeType: mimeType}
+		}
 	}
 
 	close(outputCh)
 }
+
+// checkFilePolicy ask the policy hook if the file data can be accepted
+func checkFilePolicy(ctx *context.Context, request *policy.Request, mimeType string, head []byte) error {
+	if len(head) > policy.HeadSize {
+		head = head[:policy.HeadSize]
+	}
+
+	request.Stage = policy.FileStage
+	request.MimeType = mimeType
+	request.Head = append([]byte{}, head...)
+
+	return ctx.GetPolicy().Check(ctx.GetLogger(), request)
+}
+
+// checkCompletePolicy ask the policy hook if the complete file can be accepted
+func checkCompletePolicy(ctx *context.Context, request *policy.Request, size int64, md5sum string) error {
+	if request == nil {
+		return nil
+	}
+
+	request.Stage = policy.CompleteStage
+	request.Size = size
+	request.Md5 = md5sum
+
+	return ctx.GetPolicy().Check(ctx.GetLogger(), request)
+}
+
+// scanner stream file data to clamd and drain whatever clamd did not read
+func scanner(ctx *context.Context, reader *io.PipeReader, outputCh chan scanOutputReturn) {
+	result, err := ctx.GetAntivirus().Scan(reader)
+	_, _ = io.Copy(ioutil.Discard, reader)
+	outputCh <- scanOutputReturn{result: result, err: err}
+	close(outputCh)
+}
@@ -2,19 +2,30 @@ package handlers
 
 import (
 	"bytes"
+	goContext "context"
+	"crypto/md5"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
 
+	"github.com/root-gg/plik/server/clamd"
+	clamd_test "github.com/root-gg/plik/server/clamd/testing"
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/policy"
 )
 
 var content = "data data data"
@@ -169,6 +180,91 @@ func TestAddFileWithoutID(t *testing.T) {
 	require.Equal(t, int64(len(content)), fileResult.Size, "invalid file size")
 }
 
+func getMultipartFormDataWithPath(filePath string, name string, in io.Reader) (out io.Reader, contentType string, err error) {
+	buffer := new(bytes.Buffer)
+	multipartWriter := multipart.NewWriter(buffer)
+
+	err = multipartWriter.WriteField("path", filePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to write path field : %s", err)
+	}
+
+	writer, err := multipartWriter.CreateFormFile("file", name)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to create multipartWriter : %s", err)
+	}
+
+	_, err = io.Copy(writer, in)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = multipartWriter.Close()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return buffer, multipartWriter.FormDataContentType(), nil
+}
+
+func TestAddFileWithPath(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+	ctx.SetUpload(upload)
+
+	reader, contentType, err := getMultipartFormDataWithPath("foo\\./bar/", "file", bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var fileResult = &common.File{}
+	err = json.Unmarshal(respBody, fileResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	require.Equal(t, "file", fileResult.Name, "invalid file name")
+	require.Equal(t, "foo/bar", fileResult.Path, "invalid file path")
+
+	file, err := ctx.GetMetadataBackend().GetFile(fileResult.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, "foo/bar", file.Path, "invalid file path")
+}
+
+func TestAddFileWithInvalidPath(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+	ctx.SetUpload(upload)
+
+	reader, contentType, err := getMultipartFormDataWithPath("foo/../../bar", "file", bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+
+	context.TestBadRequest(t, rr, "parent directory references are not allowed")
+}
+
 func TestAddFileWithoutUploadInContext(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -583,6 +679,109 @@ func TestAddFileQuickDownloadDomain(t *testing.T) {
 	require.Equal(t, url, string(respBody), "invalid url")
 }
 
+func TestAddFileQuickPut(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+	ctx.SetQuick(true)
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("PUT", "/file.txt", bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"filename": "file.txt"})
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	files, err := ctx.GetMetadataBackend().GetFiles(upload.ID)
+	require.NoError(t, err, "unable to get upload files")
+	require.Len(t, files, 1, "missing file")
+	require.Equal(t, "file.txt", files[0].Name, "invalid file name")
+	require.Equal(t, int64(len(content)), files[0].Size, "invalid file size")
+
+	url := fmt.Sprintf("http://127.0.0.1:8080/file/%s/%s/file.txt\n", upload.ID, files[0].ID)
+	require.Equal(t, url, rr.Body.String(), "invalid url")
+
+	adminURL := fmt.Sprintf("http://127.0.0.1:8080/#/?id=%s&uploadToken=%s", upload.ID, upload.UploadToken)
+	require.Equal(t, adminURL, rr.Header().Get("X-Plik-Admin-URL"), "invalid admin url")
+}
+
+func TestAddFileQuickPutMissingName(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+	ctx.SetQuick(true)
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("PUT", "/", bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestMissingParameter(t, rr, "file name")
+}
+
+func TestAddFileQuickOutputJSON(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+	ctx.SetQuick(true)
+	ctx.SetQuickOutput(common.QuickOutputJSON)
+
+	upload := &common.Upload{UploadToken: "token"}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("PUT", "/file.txt", bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"filename": "file.txt"})
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result := &quickResponse{}
+	err = json.Unmarshal(rr.Body.Bytes(), result)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	require.Equal(t, upload.ID, result.UploadID, "invalid upload id")
+	require.Equal(t, "token", result.UploadToken, "invalid upload token")
+	require.NotNil(t, result.File, "missing file")
+	require.Equal(t, "file.txt", result.File.Name, "invalid file name")
+	require.Equal(t, fmt.Sprintf("http://127.0.0.1:8080/file/%s/%s/file.txt", upload.ID, result.File.ID), result.URL, "invalid url")
+	require.Equal(t, fmt.Sprintf("http://127.0.0.1:8080/#/?id=%s&uploadToken=token", upload.ID), result.AdminURL, "invalid admin url")
+}
+
+func TestAddFileQuickOutputShell(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+	ctx.SetQuick(true)
+	ctx.SetQuickOutput(common.QuickOutputShell)
+
+	upload := &common.Upload{UploadToken: "token"}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("PUT", "/it's.txt", bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"filename": "it's.txt"})
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	files, err := ctx.GetMetadataBackend().GetFiles(upload.ID)
+	require.NoError(t, err, "unable to get upload files")
+	require.Len(t, files, 1, "missing file")
+
+	expected := fmt.Sprintf("PLIK_UPLOAD_ID='%s'\n", upload.ID) +
+		"PLIK_UPLOAD_TOKEN='token'\n" +
+		fmt.Sprintf("PLIK_FILE_ID='%s'\n", files[0].ID) +
+		fmt.Sprintf("PLIK_URL='http://127.0.0.1:8080/file/%s/%s/it'\\''s.txt'\n", upload.ID, files[0].ID) +
+		fmt.Sprintf("PLIK_ADMIN_URL='http://127.0.0.1:8080/#/?id=%s&uploadToken=token'\n", upload.ID)
+	require.Equal(t, expected, rr.Body.String(), "invalid shell output")
+}
+
 func TestAddFileTooBig(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	ctx.GetConfig().MaxFileSize = 5
@@ -605,3 +804,417 @@ func TestAddFileTooBig(t *testing.T) {
 
 	context.TestBadRequest(t, rr, fmt.Sprintf("file too big (limit is set to %d bytes)", ctx.GetConfig().MaxFileSize))
 }
+
+func TestAddFileBlockedHash(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	err := ctx.GetMetadataBackend().AddBlockedHash(&common.BlockedHash{Hash: contentMD5})
+	require.NoError(t, err, "unable to add blocked hash")
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+
+	context.TestUnavailableForLegalReasons(t, rr, "this file content has been blocked by an administrator")
+
+	// The blocked content is deleted by the cleaning routine
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
+}
+
+func newTestAntivirus(t *testing.T, ctx *context.Context) (server *clamd_test.Server) {
+	server, err := clamd_test.NewServer()
+	require.NoError(t, err, "unable to start fake clamd")
+	t.Cleanup(func() { _ = server.Close() })
+
+	client, err := clamd.NewClient(server.Address(), time.Second, ctx.GetConfig().ClamdMaxScanSize)
+	require.NoError(t, err, "unable to create clamd client")
+	ctx.SetAntivirus(client)
+
+	return server
+}
+
+func TestAddFileAntivirusClean(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+	server := newTestAntivirus(t, ctx)
+	server.AddSignature("EICAR", "Eicar-Signature")
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+	require.Equal(t, contentMD5, f.Md5, "invalid file md5")
+}
+
+func TestAddFileAntivirusInfected(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+	server := newTestAntivirus(t, ctx)
+	server.AddSignature("EICAR", "Eicar-Signature")
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte("data EICAR data")))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestBadRequest(t, rr, "file has been quarantined by the antivirus : Eicar-Signature")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileQuarantined, f.Status, "invalid file status")
+	require.Equal(t, "Eicar-Signature", f.Virus, "invalid virus")
+}
+
+func TestAddFileAntivirusUnavailable(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+	server := newTestAntivirus(t, ctx)
+	require.NoError(t, server.Close())
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	// The file waits for the antivirus routine to scan it again
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileScanning, f.Status, "invalid file status")
+}
+
+func TestAddFileAntivirusSizeLimit(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ClamdMaxScanSize = 4
+	ctx := newTestingContext(config)
+	ctx.SetUploadAdmin(true)
+	server := newTestAntivirus(t, ctx)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	// Files too big to be scanned are available unscanned by default
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+	require.Equal(t, 0, server.Scans(), "the file should not be sent to clamd")
+}
+
+func TestAddFileAntivirusSizeLimitQuarantine(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ClamdQuarantineOversized = true
+	ctx := newTestingContext(config)
+	ctx.SetUploadAdmin(true)
+	server := newTestAntivirus(t, ctx)
+	server.SetError("INSTREAM size limit exceeded.")
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestBadRequest(t, rr, "file has been quarantined by the antivirus : "+clamd.SizeLimitSignature)
+
+	// The antivirus routine won't scan it again
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileQuarantined, f.Status, "invalid file status")
+	require.Equal(t, clamd.SizeLimitSignature, f.Virus, "invalid virus")
+}
+
+type testPolicyHook func(request *policy.Request) *policy.Response
+
+func (hook testPolicyHook) Call(ctx goContext.Context, request *policy.Request) (*policy.Response, error) {
+	return hook(request), nil
+}
+
+func TestAddFilePolicyRejectFile(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	var requests []policy.Request
+	hook := func(request *policy.Request) *policy.Response {
+		requests = append(requests, *request)
+		if bytes.HasPrefix(request.Head, []byte("MZ")) {
+			return &policy.Response{Accept: false, Message: "executables are not allowed"}
+		}
+		return &policy.Response{Accept: true}
+	}
+	ctx.SetPolicy(policy.New(testPolicyHook(hook), time.Second, false))
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file.exe"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte("MZ data data")))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestForbidden(t, rr, "executables are not allowed")
+
+	require.Len(t, requests, 1, "invalid policy request count")
+	require.Equal(t, policy.FileStage, requests[0].Stage)
+	require.Equal(t, "file.exe", requests[0].FileName)
+	require.Equal(t, upload.ID, requests[0].Upload.ID)
+	require.Equal(t, "MZ data data", string(requests[0].Head))
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
+}
+
+func TestAddFilePolicyRejectComplete(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	var stages []string
+	hook := func(request *policy.Request) *policy.Response {
+		stages = append(stages, request.Stage)
+		if request.Stage == policy.CompleteStage && request.Md5 == contentMD5 {
+			return &policy.Response{Accept: false, Message: "known bad file"}
+		}
+		return &policy.Response{Accept: true}
+	}
+	ctx.SetPolicy(policy.New(testPolicyHook(hook), time.Second, false))
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestForbidden(t, rr, "known bad file")
+	require.Equal(t, []string{policy.FileStage, policy.CompleteStage}, stages)
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
+}
+
+func TestAddFilePolicyAccept(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	hook := func(request *policy.Request) *policy.Response {
+		return &policy.Response{Accept: true}
+	}
+	ctx.SetPolicy(policy.New(testPolicyHook(hook), time.Second, false))
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+}
+
+func TestAddFileGroupSizeQuota(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	group := common.NewGroup("team")
+	group.MaxSize = 5
+	err := ctx.GetMetadataBackend().CreateGroup(group)
+	require.NoError(t, err, "unable to create group")
+
+	upload := &common.Upload{GroupID: group.ID}
+	createTestUpload(t, ctx, upload)
+
+	name := "file"
+	reader, contentType, err := getMultipartFormData(name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+
+	context.TestBadRequest(t, rr, "file too big (limit is set to 5 bytes)")
+
+	// Fill the quota
+	file := upload.NewFile()
+	file.Size = 5
+	file.Status = common.FileUploaded
+	err = ctx.GetMetadataBackend().CreateFile(file)
+	require.NoError(t, err, "unable to create file")
+
+	reader, contentType, err = getMultipartFormData(name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err = http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("Content-Type", contentType)
+
+	rr = ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+
+	context.TestForbidden(t, rr, "group team size quota reached, limit is 5 bytes")
+}
+
+func newTestPNGWithMetadata(t *testing.T, metadata string) []byte {
+	buf := bytes.NewBuffer(nil)
+	err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 16, 16)))
+	require.NoError(t, err, "unable to encode png")
+
+	// Insert a tEXt chunk right before IEND
+	data := []byte("Comment\x00" + metadata)
+	chunk := bytes.NewBuffer(nil)
+	_ = binary.Write(chunk, binary.BigEndian, uint32(len(data)))
+	chunk.WriteString("tEXt")
+	chunk.Write(data)
+	_ = binary.Write(chunk, binary.BigEndian, crc32.ChecksumIEEE(append([]byte("tEXt"), data...)))
+
+	image := buf.Bytes()
+	iend := len(image) - 12
+	return append(append(append([]byte{}, image[:iend]...), chunk.Bytes()...), image[iend:]...)
+}
+
+func addTestFile(t *testing.T, ctx *context.Context, upload *common.Upload, data []byte) *httptest.ResponseRecorder {
+	reader, contentType, err := getMultipartFormData("image.png", bytes.NewReader(data))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	return rr
+}
+
+func TestAddFileStripMetadata(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{StripMetadata: true}
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestFile(t, ctx, upload, newTestPNGWithMetadata(t, "GPS 48.8584N 2.2945E"))
+	context.TestOK(t, rr)
+
+	var fileResult = &common.File{}
+	err := json.Unmarshal(rr.Body.Bytes(), fileResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.True(t, fileResult.MetadataStripped, "metadata not stripped")
+	require.Equal(t, "image/png", fileResult.Type, "invalid file type")
+
+	fileReader, err := ctx.GetDataBackend().GetFile(fileResult)
+	require.NoError(t, err, "unable to get file")
+	data, err := ioutil.ReadAll(fileReader)
+	require.NoError(t, err, "unable to read file")
+
+	require.NotContains(t, string(data), "GPS", "metadata not stripped")
+	require.Equal(t, int64(len(data)), fileResult.Size, "invalid file size")
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum(data)), fileResult.Md5, "invalid file md5")
+}
+
+func TestAddFileStripMetadataNotAnImage(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{StripMetadata: true}
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestFile(t, ctx, upload, []byte(content))
+	context.TestOK(t, rr)
+
+	var fileResult = &common.File{}
+	err := json.Unmarshal(rr.Body.Bytes(), fileResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.False(t, fileResult.MetadataStripped, "unexpected metadata stripping")
+	require.Equal(t, contentMD5, fileResult.Md5, "invalid file md5")
+}
+
+func TestAddFileStripMetadataInvalidImage(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{StripMetadata: true}
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestFile(t, ctx, upload, newTestPNGWithMetadata(t, "GPS")[:20])
+	context.TestBadRequest(t, rr, "unable to strip image metadata")
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// AddFileVersion upload a new version of an uploaded file, the file keeps its ID and URL
+func AddFileVersion(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	log := ctx.GetLogger()
+	config := ctx.GetConfig()
+
+	// Get upload from context
+	upload := ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	// Check authorization
+	if !ctx.IsUploadAdmin() {
+		ctx.Forbidden("you are not allowed to add file to this upload")
+		return
+	}
+
+	// Get file from context
+	file := ctx.GetFile()
+	if file == nil {
+		panic("missing file from context")
+	}
+
+	// Previous versions would bypass the download limit of one shot, download capped and stream files
+	if upload.Stream || upload.IsDownloadLimited() {
+		ctx.BadRequest("file versions are not available for one shot or stream uploads")
+		return
+	}
+
+	if file.Status != common.FileUploaded {
+		ctx.BadRequest("invalid file status %s, expected %s", file.Status, common.FileUploaded)
+		return
+	}
+
+	// The new version keeps the name of the file so the file URL doesn't change
+	var fileReader io.Reader
+	if req.Method == "PUT" {
+		fileReader = req.Body
+	} else {
+		var ok bool
+		fileReader, _, _, ok = readMultipartFile(ctx, req)
+		if !ok {
+			return
+		}
+	}
+
+	// Pastes are limited by PasteMaxSize instead of MaxFileSize
+	var maxFileSize int64
+	var ok bool
+	if file.Paste {
+		maxFileSize, ok = ctx.GetMaxPasteSize(upload)
+	} else {
+		maxFileSize, ok = ctx.GetMaxFileSize(upload)
+	}
+	if !ok {
+		return
+	}
+
+	// Update request logger prefix
+	prefix := fmt.Sprintf("%s[%s]", log.Prefix, file.Name)
+	log.SetPrefix(prefix)
+
+	// The new version is stored under its own key so the current version can still be downloaded meanwhile
+	version := *file
+	version.DataID = common.GenerateRandomID(16)
+	version.Status = common.FileUploading
+
+	_, err := storeFile(ctx, upload, &version, fileReader, maxFileSize)
+	if err == nil {
+		switch version.Status {
+		case common.FileQuarantined:
+			err = common.NewHTTPError(fmt.Sprintf("file has been quarantined by the antivirus : %s", version.Virus), nil, http.StatusBadRequest)
+		case common.FileScanning:
+			err = common.NewHTTPError("unable to scan file, please try again later", nil, http.StatusServiceUnavailable)
+		}
+	}
+	if err == nil {
+		err = ctx.GetMetadataBackend().AddFileVersion(file, &version, config.MaxFileVersions)
+		if err != nil {
+			err = common.NewHTTPError("unable to add file version", err, http.StatusInternalServerError)
+		}
+	}
+	if err != nil {
+		// The rejected version is not referenced by the metadata so the cleaning routine can't delete it
+		errRemove := ctx.GetDataBackend().RemoveFile(&version)
+		if errRemove != nil {
+			log.Warningf("unable to remove rejected file version : %s", errRemove)
+		}
+
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	fileUploaded(ctx, upload, file)
+
+	// Remove all private information (ip, data backend details, ...) before
+	// sending metadata back to the client
+	file.Sanitize()
+
+	if ctx.IsQuick() {
+		url := getDownloadURL(config, fmt.Sprintf("/file/%s/%s/%s", upload.ID, file.ID, file.Name))
+		writeQuickResponse(ctx, resp, upload, file, url)
+	} else {
+		common.WriteJSONResponse(resp, file)
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func createTestVersionedFile(t *testing.T, ctx *context.Context, upload *common.Upload) (file *common.File) {
+	file = upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	file.Size = int64(len("version 1"))
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBufferString("version 1"))
+	require.NoError(t, err, "unable to create test file")
+
+	return file
+}
+
+func getFileVersionRequest(t *testing.T, upload *common.Upload, file *common.File, data string) (req *http.Request) {
+	reader, contentType, err := getMultipartFormData("file-v2", bytes.NewBufferString(data))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err = http.NewRequest("POST", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"/versions", reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	return req
+}
+
+func readTestFile(t *testing.T, ctx *context.Context, file *common.File) string {
+	reader, err := ctx.GetDataBackend().GetFile(file)
+	require.NoError(t, err, "unable to get file from data backend")
+	defer func() { _ = reader.Close() }()
+
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	return string(data)
+}
+
+func TestAddFileVersion(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	file := createTestVersionedFile(t, ctx, upload)
+
+	req := getFileVersionRequest(t, upload, file, content)
+	rr := ctx.NewRecorder(req)
+	AddFileVersion(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var fileResult = &common.File{}
+	err := json.Unmarshal(rr.Body.Bytes(), fileResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, file.ID, fileResult.ID, "the file id should not change")
+	require.Equal(t, "file", fileResult.Name, "the file name should not change")
+	require.Equal(t, 2, fileResult.Version, "invalid file version")
+	require.Equal(t, contentMD5, fileResult.Md5, "invalid file md5")
+	require.Equal(t, int64(len(content)), fileResult.Size, "invalid file size")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+	require.Equal(t, content, readTestFile(t, ctx, f), "invalid file content")
+
+	version, err := ctx.GetMetadataBackend().GetFileVersion(file.ID, 1)
+	require.NoError(t, err, "unable to get file version")
+	require.NotNil(t, version, "missing previous version")
+	require.Equal(t, "version 1", readTestFile(t, ctx, f.GetVersionFile(version)), "invalid previous version content")
+}
+
+func TestAddFileVersionPut(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	file := createTestVersionedFile(t, ctx, upload)
+
+	req, err := http.NewRequest("PUT", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"/versions", bytes.NewBufferString("version 2"))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AddFileVersion(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, 2, f.Version, "invalid file version")
+	require.Equal(t, "version 2", readTestFile(t, ctx, f), "invalid file content")
+}
+
+func TestAddFileVersionMaxVersions(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().MaxFileVersions = 1
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	file := createTestVersionedFile(t, ctx, upload)
+
+	for i := 2; i <= 3; i++ {
+		req := getFileVersionRequest(t, upload, file, fmt.Sprintf("version %d", i))
+		rr := ctx.NewRecorder(req)
+		AddFileVersion(ctx, rr, req)
+		context.TestOK(t, rr)
+	}
+
+	versions, err := ctx.GetMetadataBackend().GetUploadFileVersions(upload.ID)
+	require.NoError(t, err, "unable to get file versions")
+	require.Len(t, versions, 1, "invalid version count")
+	require.Equal(t, 2, versions[0].Version, "invalid version")
+
+	version, err := ctx.GetMetadataBackend().GetFileVersion(file.ID, 1)
+	require.NoError(t, err, "unable to get file version")
+	require.Equal(t, common.FileRemoved, version.Status, "the oldest version should have been removed")
+}
+
+func TestAddFileVersionNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	file := createTestVersionedFile(t, ctx, upload)
+
+	req := getFileVersionRequest(t, upload, file, content)
+	rr := ctx.NewRecorder(req)
+	AddFileVersion(ctx, rr, req)
+	context.TestForbidden(t, rr, "you are not allowed to add file to this upload")
+}
+
+func TestAddFileVersionOneShot(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{OneShot: true}
+	file := createTestVersionedFile(t, ctx, upload)
+
+	req := getFileVersionRequest(t, upload, file, content)
+	rr := ctx.NewRecorder(req)
+	AddFileVersion(ctx, rr, req)
+	context.TestBadRequest(t, rr, "file versions are not available for one shot or stream uploads")
+}
+
+func TestAddFileVersionStatusMissing(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	file := createTestVersionedFile(t, ctx, upload)
+	file.Status = common.FileMissing
+
+	req := getFileVersionRequest(t, upload, file, content)
+	rr := ctx.NewRecorder(req)
+	AddFileVersion(ctx, rr, req)
+	context.TestBadRequest(t, rr, "invalid file status missing, expected uploaded")
+}
+
+func TestAddFileVersionTooBig(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().MaxFileSize = 5
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	file := createTestVersionedFile(t, ctx, upload)
+
+	req := getFileVersionRequest(t, upload, file, content)
+	rr := ctx.NewRecorder(req)
+	AddFileVersion(ctx, rr, req)
+	context.TestBadRequest(t, rr, "file too big (limit is set to 5 bytes)")
+
+	// The current version is left untouched
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, 0, f.Version, "invalid file version")
+	require.Equal(t, "version 1", readTestFile(t, ctx, f), "invalid file content")
+}
+
+func TestAddFileVersionAntivirusInfected(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+	server := newTestAntivirus(t, ctx)
+	server.AddSignature("EICAR", "Eicar-Signature")
+
+	upload := &common.Upload{}
+	file := createTestVersionedFile(t, ctx, upload)
+
+	req := getFileVersionRequest(t, upload, file, "data EICAR data")
+	rr := ctx.NewRecorder(req)
+	AddFileVersion(ctx, rr, req)
+	context.TestBadRequest(t, rr, "file has been quarantined by the antivirus : Eicar-Signature")
+
+	// The current version is still available
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+	require.Equal(t, "version 1", readTestFile(t, ctx, f), "invalid file content")
+}
+
+func TestGetFileVersion(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	file := createTestVersionedFile(t, ctx, upload)
+
+	req := getFileVersionRequest(t, upload, file, "version 2")
+	rr := ctx.NewRecorder(req)
+	AddFileVersion(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	for version, expected := range map[string]string{"": "version 2", "1": "version 1", "2": "version 2"} {
+		req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"?version="+version, &bytes.Buffer{})
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		GetFile(ctx, rr, req)
+		context.TestOK(t, rr)
+
+		body, err := ioutil.ReadAll(rr.Body)
+		require.NoError(t, err, "unable to read response body")
+		require.Equal(t, expected, string(body), "invalid file content for version %q", version)
+		require.Equal(t, fmt.Sprintf("%d", len(expected)), rr.Header().Get("Content-Length"), "invalid content length")
+	}
+
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"?version=3", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	rr = ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestNotFound(t, rr, fmt.Sprintf("file %s (%s) version 3 not found", file.Name, file.ID))
+
+	req, err = http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"?version=foo", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	rr = ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestInvalidParameter(t, rr, "version")
+}
+
+func TestGetUploadFileVersions(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	file := createTestVersionedFile(t, ctx, upload)
+
+	req := getFileVersionRequest(t, upload, file, "version 2")
+	rr := ctx.NewRecorder(req)
+	AddFileVersion(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	req, err := http.NewRequest("GET", "/upload/"+upload.ID, &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	GetUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var uploadResult = &common.Upload{}
+	err = json.Unmarshal(rr.Body.Bytes(), uploadResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Len(t, uploadResult.Files, 1, "invalid file count")
+	require.Equal(t, 2, uploadResult.Files[0].Version, "invalid file version")
+	require.Len(t, uploadResult.Files[0].Versions, 1, "invalid version history")
+	require.Equal(t, 1, uploadResult.Files[0].Versions[0].Version, "invalid previous version")
+	require.Equal(t, int64(len("version 1")), uploadResult.Files[0].Versions[0].Size, "invalid previous version size")
+}
@@ -1,13 +1,33 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
 
 	"github.com/root-gg/plik/server/common"
 
 	"github.com/root-gg/plik/server/context"
 )
 
+// adminUserParams are the user parameters an administrator can set
+type adminUserParams struct {
+	Provider string `json:"provider"`
+	Login    string `json:"login"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	IsAdmin  *bool  `json:"admin"`
+}
+
+// adminUploadParams are the upload parameters an administrator can update
+type adminUploadParams struct {
+	TTL int `json:"ttl"`
+}
+
 // GetUsers return users
 func GetUsers(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
 
@@ -30,6 +50,315 @@ func GetUsers(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 	common.WriteJSONResponse(resp, pagingResponse)
 }
 
+// CreateUser create a new user
+func CreateUser(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	params := &adminUserParams{Provider: common.ProviderLocal}
+	if !readJSONParams(ctx, resp, req, params) {
+		return
+	}
+
+	if params.Login == "" {
+		ctx.MissingParameter("login")
+		return
+	}
+
+	if !common.IsValidProvider(params.Provider) {
+		ctx.InvalidParameter("provider")
+		return
+	}
+
+	user, err := ctx.GetMetadataBackend().GetUser(common.GetUserID(params.Provider, params.Login))
+	if err != nil {
+		ctx.InternalServerError("unable to get user", err)
+		return
+	}
+	if user != nil {
+		ctx.BadRequest("user %s already exists", user.ID)
+		return
+	}
+
+	user = common.NewUser(params.Provider, params.Login)
+	user.Login = params.Login
+	user.Name = params.Name
+	user.Email = params.Email
+	if params.IsAdmin != nil {
+		user.IsAdmin = *params.IsAdmin
+	}
+
+	// Only local users authenticate with a password
+	if params.Provider == common.ProviderLocal {
+		if params.Password == "" {
+			ctx.MissingParameter("password")
+			return
+		}
+		user.Password, err = common.HashPassword(params.Password)
+		if err != nil {
+			ctx.InternalServerError("unable to hash password", err)
+			return
+		}
+	} else if params.Password != "" {
+		ctx.BadRequest("only local users can have a password")
+		return
+	}
+
+	err = ctx.GetMetadataBackend().CreateUser(user)
+	if err != nil {
+		ctx.InternalServerError("unable to create user", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, user)
+}
+
+// GetUser return a user
+func GetUser(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	user, err := getAdminUser(ctx, req)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, user)
+}
+
+// UpdateUser update a user info, administrator privileges or password
+func UpdateUser(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	user, err := getAdminUser(ctx, req)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	params := &adminUserParams{}
+	if !readJSONParams(ctx, resp, req, params) {
+		return
+	}
+
+	if params.Name != "" {
+		user.Name = params.Name
+	}
+
+	if params.Email != "" {
+		user.Email = params.Email
+	}
+
+	if params.IsAdmin != nil {
+		if !*params.IsAdmin && user.ID == ctx.GetUser().ID {
+			ctx.BadRequest("you can't remove your own administrator privileges")
+			return
+		}
+		user.IsAdmin = *params.IsAdmin
+	}
+
+	if params.Password != "" {
+		if user.Provider != common.ProviderLocal {
+			ctx.BadRequest("only local users can have a password")
+			return
+		}
+		user.Password, err = common.HashPassword(params.Password)
+		if err != nil {
+			ctx.InternalServerError("unable to hash password", err)
+			return
+		}
+	}
+
+	err = ctx.GetMetadataBackend().UpdateUser(user)
+	if err != nil {
+		ctx.InternalServerError("unable to update user", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, user)
+}
+
+// RemoveUser delete a user, its tokens and uploads
+func RemoveUser(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	user, err := getAdminUser(ctx, req)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	_, err = ctx.GetMetadataBackend().DeleteUser(user.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to delete user", err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// AdminGetUserTokens return the tokens of a user
+func AdminGetUserTokens(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	user, err := getAdminUser(ctx, req)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	tokens, cursor, err := ctx.GetMetadataBackend().GetTokens(user.ID, ctx.GetPagingQuery())
+	if err != nil {
+		ctx.InternalServerError("unable to get user tokens", err)
+		return
+	}
+
+	pagingResponse := common.NewPagingResponse(tokens, cursor)
+	common.WriteJSONResponse(resp, pagingResponse)
+}
+
+// AdminCreateUserToken create a new token for a user
+func AdminCreateUserToken(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	user, err := getAdminUser(ctx, req)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	token := common.NewToken()
+	if !readJSONParams(ctx, resp, req, token) {
+		return
+	}
+
+	// Generate token uuid and set creation date
+	token.Initialize()
+	token.UserID = user.ID
+
+	err = ctx.GetMetadataBackend().CreateToken(token)
+	if err != nil {
+		ctx.InternalServerError("unable to create token", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, token)
+}
+
+// AdminRevokeUserToken remove a token of a user
+func AdminRevokeUserToken(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	user, err := getAdminUser(ctx, req)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	vars := mux.Vars(req)
+	tokenStr := vars["token"]
+	if tokenStr == "" {
+		ctx.MissingParameter("token")
+		return
+	}
+
+	token, err := ctx.GetMetadataBackend().GetToken(tokenStr)
+	if err != nil {
+		ctx.InternalServerError("unable to get token", err)
+		return
+	}
+	if token == nil || token.UserID != user.ID {
+		ctx.NotFound("token not found")
+		return
+	}
+
+	_, err = ctx.GetMetadataBackend().DeleteToken(token.Token)
+	if err != nil {
+		ctx.InternalServerError("unable to delete token", err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// GetUploads search uploads across all users
+func GetUploads(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	filter, err := getUploadFilter(req)
+	if err != nil {
+		ctx.BadRequest(err.Error())
+		return
+	}
+
+	uploads, cursor, err := ctx.GetMetadataBackend().SearchUploads(filter, true, ctx.GetPagingQuery())
+	if err != nil {
+		ctx.InternalServerError("unable to get uploads", err)
+		return
+	}
+
+	for _, upload := range uploads {
+		upload.SanitizeForAdmin()
+	}
+
+	pagingResponse := common.NewPagingResponse(uploads, cursor)
+	common.WriteJSONResponse(resp, pagingResponse)
+}
+
+// AdminUpdateUpload change the expiration date of any upload
+func AdminUpdateUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	upload, err := getAdminUpload(ctx, req)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	params := &adminUploadParams{}
+	if !readJSONParams(ctx, resp, req, params) {
+		return
+	}
+
+	// TTL = Time in second before the upload expiration from now
+	// -1	-> No expiration
+	// Administrators are not limited by the MaxTTL configuration
+	switch {
+	case params.TTL == -1:
+		upload.ExpireAt = nil
+	case params.TTL > 0:
+		deadline := time.Now().Add(time.Duration(params.TTL) * time.Second)
+		upload.ExpireAt = &deadline
+	default:
+		ctx.InvalidParameter("ttl")
+		return
+	}
+	upload.TTL = params.TTL
+
+	err = ctx.GetMetadataBackend().UpdateUpload(upload)
+	if err != nil {
+		ctx.InternalServerError("unable to update upload", err)
+		return
+	}
+
+	upload.SanitizeForAdmin()
+	common.WriteJSONResponse(resp, upload)
+}
+
+// AdminRemoveUpload delete any upload and all associated files
+func AdminRemoveUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	upload, err := getAdminUpload(ctx, req)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	err = ctx.GetMetadataBackend().DeleteUpload(upload.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to delete upload", err)
+		return
+	}
+
+	ctx.NotifyEvent(common.EventUploadRemoved, upload, nil)
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
 // GetServerStatistics return the server statistics
 func GetServerStatistics(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
 
@@ -48,3 +377,120 @@ func GetServerStatistics(ctx *context.Context, resp http.ResponseWriter, req *ht
 
 	common.WriteJSONResponse(resp, stats)
 }
+
+// getAdminUser check administrator privileges and return the user from the URL params
+func getAdminUser(ctx *context.Context, req *http.Request) (user *common.User, err error) {
+	if !ctx.IsAdmin() {
+		return nil, common.NewHTTPError("you need administrator privileges", nil, http.StatusForbidden)
+	}
+
+	vars := mux.Vars(req)
+	userID := vars["userID"]
+	if userID == "" {
+		return nil, common.NewHTTPError("missing user id", nil, http.StatusBadRequest)
+	}
+
+	user, err = ctx.GetMetadataBackend().GetUser(userID)
+	if err != nil {
+		return nil, common.NewHTTPError("unable to get user", err, http.StatusInternalServerError)
+	}
+	if user == nil {
+		return nil, common.NewHTTPError(fmt.Sprintf("user %s not found", userID), nil, http.StatusNotFound)
+	}
+
+	return user, nil
+}
+
+// getAdminUpload check administrator privileges and return the upload from the URL params
+// Unlike middleware.Upload expired uploads are returned so they can be extended
+func getAdminUpload(ctx *context.Context, req *http.Request) (upload *common.Upload, err error) {
+	if !ctx.IsAdmin() {
+		return nil, common.NewHTTPError("you need administrator privileges", nil, http.StatusForbidden)
+	}
+
+	vars := mux.Vars(req)
+	uploadID := vars["uploadID"]
+	if uploadID == "" {
+		return nil, common.NewHTTPError("missing upload id", nil, http.StatusBadRequest)
+	}
+
+	upload, err = ctx.GetMetadataBackend().GetUpload(uploadID)
+	if err != nil {
+		return nil, common.NewHTTPError("unable to get upload", err, http.StatusInternalServerError)
+	}
+	if upload == nil {
+		return nil, common.NewHTTPError(fmt.Sprintf("upload %s not found", uploadID), nil, http.StatusNotFound)
+	}
+
+	return upload, nil
+}
+
+// getUploadFilter parse the upload search filters from the URL query parameters
+func getUploadFilter(req *http.Request) (filter *common.UploadFilter, err error) {
+	query := req.URL.Query()
+	filter = &common.UploadFilter{}
+	filter.User = query.Get("user")
+	filter.Token = query.Get("token")
+
+	parseBool := func(name string) (*bool, error) {
+		value := query.Get(name)
+		if value == "" {
+			return nil, nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter : %s", name, err)
+		}
+		return &b, nil
+	}
+
+	parseDate := func(name string) (*time.Time, error) {
+		value := query.Get(name)
+		if value == "" {
+			return nil, nil
+		}
+		date, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter, expecting a RFC3339 date : %s", name, err)
+		}
+		return &date, nil
+	}
+
+	parseSize := func(name string) (int64, error) {
+		value := query.Get(name)
+		if value == "" {
+			return 0, nil
+		}
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s parameter : %s", name, err)
+		}
+		return size, nil
+	}
+
+	if filter.Anonymous, err = parseBool("anonymous"); err != nil {
+		return nil, err
+	}
+	if filter.Expired, err = parseBool("expired"); err != nil {
+		return nil, err
+	}
+	if filter.CreatedAfter, err = parseDate("createdAfter"); err != nil {
+		return nil, err
+	}
+	if filter.CreatedBefore, err = parseDate("createdBefore"); err != nil {
+		return nil, err
+	}
+	if filter.MinSize, err = parseSize("minSize"); err != nil {
+		return nil, err
+	}
+	if filter.MaxSize, err = parseSize("maxSize"); err != nil {
+		return nil, err
+	}
+
+	err = filter.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
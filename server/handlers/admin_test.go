@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
 
 	"github.com/root-gg/plik/server/common"
@@ -164,3 +167,322 @@ func TestGetServerStatisticsNotAdmin(t *testing.T) {
 
 	context.TestForbidden(t, rr, "you need administrator privileges")
 }
+
+func TestCreateUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	reqBody, err := json.Marshal(map[string]interface{}{"login": "bob", "name": "Bob", "password": "secret", "admin": true})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/users", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUser(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	user, err := ctx.GetMetadataBackend().GetUser("local:bob")
+	require.NoError(t, err, "unable to get user")
+	require.NotNil(t, user, "missing user")
+	require.Equal(t, "Bob", user.Name, "invalid user name")
+	require.True(t, user.IsAdmin, "user should be admin")
+	require.True(t, common.CheckPasswordHash("secret", user.Password), "invalid user password")
+
+	// User already exists
+	req, err = http.NewRequest("POST", "/users", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	CreateUser(ctx, rr, req)
+	context.TestBadRequest(t, rr, "user local:bob already exists")
+}
+
+func TestCreateUserInvalidParams(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	create := func(params map[string]interface{}) *httptest.ResponseRecorder {
+		reqBody, err := json.Marshal(params)
+		require.NoError(t, err, "unable to marshal request body")
+
+		req, err := http.NewRequest("POST", "/users", bytes.NewBuffer(reqBody))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		CreateUser(ctx, rr, req)
+		return rr
+	}
+
+	context.TestMissingParameter(t, create(map[string]interface{}{"password": "secret"}), "login")
+	context.TestMissingParameter(t, create(map[string]interface{}{"login": "bob"}), "password")
+	context.TestInvalidParameter(t, create(map[string]interface{}{"login": "bob", "provider": "foo"}), "provider")
+	context.TestBadRequest(t, create(map[string]interface{}{"login": "bob", "provider": "google", "password": "secret"}), "only local users can have a password")
+}
+
+func TestCreateUserNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+
+	req, err := http.NewRequest("POST", "/users", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUser(ctx, rr, req)
+	context.TestForbidden(t, rr, "you need administrator privileges")
+}
+
+func TestUpdateUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	user := common.NewUser(common.ProviderLocal, "bob")
+	user.Login = "bob"
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	reqBody, err := json.Marshal(map[string]interface{}{"email": "bob@root.gg", "password": "reset", "admin": true})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/users/"+user.ID, bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"userID": user.ID})
+
+	rr := ctx.NewRecorder(req)
+	UpdateUser(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	user, err = ctx.GetMetadataBackend().GetUser(user.ID)
+	require.NoError(t, err, "unable to get user")
+	require.Equal(t, "bob@root.gg", user.Email, "invalid user email")
+	require.True(t, user.IsAdmin, "user should be admin")
+	require.True(t, common.CheckPasswordHash("reset", user.Password), "invalid user password")
+}
+
+func TestUpdateUserRemoveOwnAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	admin := createAdminUser(t, ctx)
+
+	reqBody, err := json.Marshal(map[string]interface{}{"admin": false})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/users/"+admin.ID, bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"userID": admin.ID})
+
+	rr := ctx.NewRecorder(req)
+	UpdateUser(ctx, rr, req)
+	context.TestBadRequest(t, rr, "you can't remove your own administrator privileges")
+}
+
+func TestGetUserNotFound(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	req, err := http.NewRequest("GET", "/users/local:missing", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"userID": "local:missing"})
+
+	rr := ctx.NewRecorder(req)
+	GetUser(ctx, rr, req)
+	context.TestNotFound(t, rr, "user local:missing not found")
+}
+
+func TestRemoveUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	user := common.NewUser(common.ProviderLocal, "bob")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	upload := &common.Upload{User: user.ID}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("DELETE", "/users/"+user.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"userID": user.ID})
+
+	rr := ctx.NewRecorder(req)
+	RemoveUser(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result, err := ctx.GetMetadataBackend().GetUser(user.ID)
+	require.NoError(t, err, "unable to get user")
+	require.Nil(t, result, "user should be deleted")
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Nil(t, u, "upload should be deleted")
+}
+
+func TestAdminUserTokens(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+	ctx.SetPagingQuery(&common.PagingQuery{})
+
+	user := common.NewUser(common.ProviderLocal, "bob")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	// Create token
+	reqBody, err := json.Marshal(map[string]interface{}{"comment": "ci"})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/users/"+user.ID+"/tokens", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"userID": user.ID})
+
+	rr := ctx.NewRecorder(req)
+	AdminCreateUserToken(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	token := &common.Token{}
+	err = json.Unmarshal(rr.Body.Bytes(), token)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.NotEmpty(t, token.Token, "missing token")
+	require.Equal(t, "ci", token.Comment, "invalid token comment")
+
+	// List tokens
+	req, err = http.NewRequest("GET", "/users/"+user.ID+"/tokens", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"userID": user.ID})
+
+	rr = ctx.NewRecorder(req)
+	AdminGetUserTokens(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var response common.PagingResponse
+	err = json.Unmarshal(rr.Body.Bytes(), &response)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, 1, len(response.Results), "invalid token count")
+
+	// Revoke token
+	req, err = http.NewRequest("DELETE", "/users/"+user.ID+"/tokens/"+token.Token, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"userID": user.ID, "token": token.Token})
+
+	rr = ctx.NewRecorder(req)
+	AdminRevokeUserToken(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result, err := ctx.GetMetadataBackend().GetToken(token.Token)
+	require.NoError(t, err, "unable to get token")
+	require.Nil(t, result, "token should be deleted")
+}
+
+func TestGetUploads(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+	ctx.SetPagingQuery(&common.PagingQuery{})
+
+	createTestUpload(t, ctx, &common.Upload{User: "local:bob", Token: "token", RemoteIP: "1.2.3.4", UploadToken: "secret", Password: "hash"})
+	createTestUpload(t, ctx, &common.Upload{})
+	createTestUpload(t, ctx, &common.Upload{})
+
+	search := func(query string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/uploads?"+query, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		GetUploads(ctx, rr, req)
+		return rr
+	}
+
+	count := func(rr *httptest.ResponseRecorder) int {
+		context.TestOK(t, rr)
+		var response common.PagingResponse
+		err := json.Unmarshal(rr.Body.Bytes(), &response)
+		require.NoError(t, err, "unable to unmarshal response body")
+		return len(response.Results)
+	}
+
+	require.Equal(t, 3, count(search("")), "invalid upload count")
+	require.Equal(t, 1, count(search("user=local:bob")), "invalid upload count")
+
+	// Upload secrets are never returned
+	rr := search("user=local:bob")
+	require.NotContains(t, rr.Body.String(), "secret", "upload token should not be returned")
+	require.NotContains(t, rr.Body.String(), "hash", "password hash should not be returned")
+	require.Contains(t, rr.Body.String(), "1.2.3.4", "remote ip should be returned")
+	require.Contains(t, rr.Body.String(), `"token":"token"`, "user token should be returned")
+	require.Equal(t, 2, count(search("anonymous=true")), "invalid upload count")
+
+	context.TestBadRequest(t, search("anonymous=maybe"), "invalid anonymous parameter")
+	context.TestBadRequest(t, search("createdAfter=yesterday"), "invalid createdAfter parameter")
+	context.TestBadRequest(t, search("minSize=10&maxSize=1"), "min size is greater than max size")
+}
+
+func TestAdminUpdateUpload(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	deadline := time.Now().Add(-time.Hour)
+	upload := &common.Upload{ExpireAt: &deadline, UploadToken: "secret", Password: "hash"}
+	createTestUpload(t, ctx, upload)
+
+	update := func(ttl int) *httptest.ResponseRecorder {
+		reqBody, err := json.Marshal(map[string]interface{}{"ttl": ttl})
+		require.NoError(t, err, "unable to marshal request body")
+
+		req, err := http.NewRequest("POST", "/uploads/"+upload.ID, bytes.NewBuffer(reqBody))
+		require.NoError(t, err, "unable to create new request")
+		req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+		rr := ctx.NewRecorder(req)
+		AdminUpdateUpload(ctx, rr, req)
+		return rr
+	}
+
+	// Extend an expired upload beyond the maximum ttl
+	rr := update(ctx.GetConfig().MaxTTL * 2)
+	context.TestOK(t, rr)
+	require.NotContains(t, rr.Body.String(), "secret", "upload token should not be returned")
+	require.NotContains(t, rr.Body.String(), "hash", "password hash should not be returned")
+
+	result, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.False(t, result.IsExpired(), "upload should not be expired")
+	require.Equal(t, "hash", result.Password, "password hash should be kept")
+
+	context.TestOK(t, update(-1))
+
+	result, err = ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Nil(t, result.ExpireAt, "upload should never expire")
+
+	context.TestInvalidParameter(t, update(0), "ttl")
+}
+
+func TestAdminRemoveUpload(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	upload := &common.Upload{User: "local:bob"}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("DELETE", "/uploads/"+upload.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+	rr := ctx.NewRecorder(req)
+	AdminRemoveUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Nil(t, result, "upload should be deleted")
+}
+
+func TestAdminRemoveUploadNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+
+	req, err := http.NewRequest("DELETE", "/uploads/upload", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": "upload"})
+
+	rr := ctx.NewRecorder(req)
+	AdminRemoveUpload(ctx, rr, req)
+	context.TestForbidden(t, rr, "you need administrator privileges")
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/archive"
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+// indexArchive list the members of an uploaded archive and save them in the metadata backend
+// Files that can't be parsed are flagged as invalid archives so they are not indexed again
+func indexArchive(metadataBackend *metadata.Backend, dataBackend data.Backend, file *common.File) (err error) {
+	format, members, err := archive.Index(dataBackend, file)
+	if err != nil {
+		if archive.IsInvalidArchive(err) {
+			return metadataBackend.SaveArchiveIndex(file, common.ArchiveInvalid, nil)
+		}
+		return err
+	}
+
+	return metadataBackend.SaveArchiveIndex(file, format.Name, members)
+}
+
+// getArchiveFile return the file from the context if its archive members can be browsed
+func getArchiveFile(ctx *context.Context) *common.File {
+	// Get upload from context
+	upload := ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	// Get file from context
+	file := ctx.GetFile()
+	if file == nil {
+		panic("missing file from context")
+	}
+
+	// Browsing would bypass the download limit of one shot, download capped and stream files
+	if upload.Stream || upload.IsDownloadLimited() {
+		ctx.BadRequest("archive contents are not available for one shot or stream uploads")
+		return nil
+	}
+
+	if file.Status != common.FileUploaded {
+		ctx.NotFound("file %s (%s) is not available : %s", file.Name, file.ID, file.Status)
+		return nil
+	}
+
+	if archive.GetFormat(file.Name) == nil && file.Archive == "" {
+		ctx.BadRequest("file %s is not an archive, supported extensions are %v", file.Name, archive.GetExtensions())
+		return nil
+	}
+
+	// Archives uploaded before the indexing was available are indexed on demand
+	if file.Archive == "" {
+		err := indexArchive(ctx.GetMetadataBackend(), ctx.GetDataBackend(), file)
+		if err != nil {
+			ctx.InternalServerError("unable to index archive", err)
+			return nil
+		}
+	}
+
+	if file.Archive == common.ArchiveInvalid {
+		ctx.BadRequest("file %s is not a valid archive", file.Name)
+		return nil
+	}
+
+	return file
+}
+
+// GetArchiveContents list the members of an uploaded archive
+// Like the upload metadata the listing is served from the API domain and not the download domain
+func GetArchiveContents(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	file := getArchiveFile(ctx)
+	if file == nil {
+		return
+	}
+
+	members, err := ctx.GetMetadataBackend().GetArchiveMembers(file.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get archive members", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, members)
+}
+
+// GetArchiveMember download a single member of an uploaded archive
+func GetArchiveMember(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	log := ctx.GetLogger()
+
+	if !checkDownloadDomain(ctx) {
+		return
+	}
+
+	file := getArchiveFile(ctx)
+	if file == nil {
+		return
+	}
+
+	index, err := strconv.Atoi(mux.Vars(req)["index"])
+	if err != nil || index < 0 {
+		ctx.InvalidParameter("archive member index")
+		return
+	}
+
+	member, err := ctx.GetMetadataBackend().GetArchiveMember(file.ID, index)
+	if err != nil {
+		ctx.InternalServerError("unable to get archive member", err)
+		return
+	}
+	if member == nil {
+		ctx.NotFound("archive member %d not found", index)
+		return
+	}
+
+	// Archive members are always downloaded, they can't be trusted to be displayed by the browser
+	resp.Header().Set("Content-Type", "application/octet-stream")
+	resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachement; filename="%s"`, path.Base(member.Name)))
+	resp.Header().Set("Content-Length", strconv.FormatInt(member.Size, 10))
+
+	/* Additional security headers for possibly unsafe content */
+	if ctx.GetConfig().EnhancedWebSecurity {
+		resp.Header().Set("X-Content-Type-Options", "nosniff")
+		resp.Header().Set("X-XSS-Protection", "1; mode=block")
+		resp.Header().Set("X-Frame-Options", "DENY")
+		resp.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'none'; style-src 'none'; img-src 'none'; connect-src 'none'; font-src 'none'; object-src 'none'; media-src 'self'; child-src 'none'; form-action 'none'; frame-ancestors 'none'; plugin-types; sandbox")
+	}
+
+	// HEAD Request => Do not print the member, user just wants http headers
+	if req.Method != "GET" {
+		return
+	}
+
+	reader, err := archive.Extract(ctx.GetDataBackend(), file, member)
+	if err != nil {
+		if archive.IsInvalidArchive(err) {
+			ctx.BadRequest("unable to extract archive member : %s", err)
+			return
+		}
+		ctx.InternalServerError("unable to extract archive member", err)
+		return
+	}
+	defer func() { _ = reader.Close() }()
+
+	// The member is decompressed directly to http response body without buffering
+	_, err = io.Copy(resp, reader)
+	if err != nil {
+		log.Warningf("error while copying archive member to response : %s", err)
+		return
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/archive"
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+var testArchiveMembers = []struct {
+	name    string
+	content string
+}{
+	{"README", "readme"},
+	{"foo/bar.txt", strings.Repeat("bar ", 1000)},
+}
+
+func createTestArchiveFile(t *testing.T, ctx *context.Context, name string) (upload *common.Upload, file *common.File) {
+	buf := bytes.NewBuffer(nil)
+	if format := archive.GetFormat(name); format != nil {
+		w, err := format.NewWriter(buf)
+		require.NoError(t, err, "unable to create archive writer")
+		for _, member := range testArchiveMembers {
+			mw, err := w.Create(member.name, int64(len(member.content)), time.Now())
+			require.NoError(t, err, "unable to create archive member")
+			_, err = mw.Write([]byte(member.content))
+			require.NoError(t, err, "unable to write archive member")
+		}
+		require.NoError(t, w.Close(), "unable to close archive writer")
+	} else {
+		buf.WriteString("not an archive")
+	}
+
+	upload = &common.Upload{}
+	file = upload.NewFile()
+	file.Name = name
+	file.Status = common.FileUploaded
+	file.Size = int64(buf.Len())
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, buf)
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	return upload, file
+}
+
+func getArchiveContents(t *testing.T, ctx *context.Context, upload *common.Upload, file *common.File) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"/contents", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetArchiveContents(ctx, rr, req)
+	return rr
+}
+
+func getArchiveMember(t *testing.T, ctx *context.Context, method string, upload *common.Upload, file *common.File, index string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"/contents/"+index, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	// Fake gorilla/mux vars
+	req = mux.SetURLVars(req, map[string]string{"index": index})
+
+	rr := ctx.NewRecorder(req)
+	GetArchiveMember(ctx, rr, req)
+	return rr
+}
+
+func TestGetArchiveContents(t *testing.T) {
+	for _, format := range archive.Formats {
+		ctx := newTestingContext(common.NewConfiguration())
+		upload, file := createTestArchiveFile(t, ctx, "archive"+format.Extension)
+
+		rr := getArchiveContents(t, ctx, upload, file)
+		context.TestOK(t, rr)
+
+		var members []*common.ArchiveMember
+		err := json.Unmarshal(rr.Body.Bytes(), &members)
+		require.NoError(t, err, "unable to unmarshal response body")
+		require.Len(t, members, len(testArchiveMembers), "invalid member count")
+
+		for i, member := range members {
+			require.Equal(t, i, member.Index, "invalid member index")
+			require.Equal(t, testArchiveMembers[i].name, member.Name, "invalid member name")
+			require.Equal(t, int64(len(testArchiveMembers[i].content)), member.Size, "invalid member size")
+		}
+
+		result, err := ctx.GetMetadataBackend().GetFile(file.ID)
+		require.NoError(t, err, "unable to get file")
+		require.Equal(t, format.Name, result.Archive, "invalid file archive")
+	}
+}
+
+func TestGetArchiveContentsNotAnArchive(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := createTestArchiveFile(t, ctx, "file.txt")
+
+	rr := getArchiveContents(t, ctx, upload, file)
+	context.TestBadRequest(t, rr, "file file.txt is not an archive")
+}
+
+func TestGetArchiveContentsInvalidArchive(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := createTestArchiveFile(t, ctx, "file.txt")
+	file.Name = "file.zip"
+
+	rr := getArchiveContents(t, ctx, upload, file)
+	context.TestBadRequest(t, rr, "file file.zip is not a valid archive")
+
+	result, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.ArchiveInvalid, result.Archive, "invalid file archive")
+}
+
+func TestGetArchiveContentsOneShot(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := createTestArchiveFile(t, ctx, "archive.zip")
+	upload.OneShot = true
+
+	rr := getArchiveContents(t, ctx, upload, file)
+	context.TestBadRequest(t, rr, "archive contents are not available for one shot or stream uploads")
+}
+
+func TestGetArchiveContentsFileNotUploaded(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := createTestArchiveFile(t, ctx, "archive.zip")
+	file.Status = common.FileRemoved
+
+	rr := getArchiveContents(t, ctx, upload, file)
+	context.TestNotFound(t, rr, "is not available")
+}
+
+func TestGetArchiveMember(t *testing.T) {
+	for _, format := range archive.Formats {
+		ctx := newTestingContext(common.NewConfiguration())
+		upload, file := createTestArchiveFile(t, ctx, "archive"+format.Extension)
+
+		rr := getArchiveMember(t, ctx, "GET", upload, file, "1")
+		context.TestOK(t, rr)
+
+		content, err := ioutil.ReadAll(rr.Body)
+		require.NoError(t, err, "unable to read response body")
+		require.Equal(t, testArchiveMembers[1].content, string(content), "invalid member content")
+
+		require.Equal(t, "application/octet-stream", rr.Header().Get("Content-Type"), "invalid content type")
+		require.Equal(t, `attachement; filename="bar.txt"`, rr.Header().Get("Content-Disposition"), "invalid content disposition")
+		require.Equal(t, "4000", rr.Header().Get("Content-Length"), "invalid content length")
+	}
+}
+
+func TestGetArchiveMemberHead(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := createTestArchiveFile(t, ctx, "archive.zip")
+
+	rr := getArchiveMember(t, ctx, "HEAD", upload, file, "0")
+	context.TestOK(t, rr)
+
+	require.Equal(t, "6", rr.Header().Get("Content-Length"), "invalid content length")
+	require.Equal(t, 0, rr.Body.Len(), "unexpected response body")
+}
+
+func TestGetArchiveMemberNotFound(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := createTestArchiveFile(t, ctx, "archive.zip")
+
+	rr := getArchiveMember(t, ctx, "GET", upload, file, "42")
+	context.TestNotFound(t, rr, "archive member 42 not found")
+}
+
+func TestGetArchiveMemberInvalidIndex(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := createTestArchiveFile(t, ctx, "archive.zip")
+
+	rr := getArchiveMember(t, ctx, "GET", upload, file, "foo")
+	context.TestInvalidParameter(t, rr, "archive member index")
+}
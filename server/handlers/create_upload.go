@@ -6,9 +6,6 @@ import (
 	"net/http"
 
 	"github.com/root-gg/plik/server/common"
-
-	"github.com/root-gg/utils"
-
 	"github.com/root-gg/plik/server/context"
 )
 
@@ -17,7 +14,9 @@ func CreateUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Requ
 	log := ctx.GetLogger()
 	config := ctx.GetConfig()
 
-	if !ctx.IsWhitelisted() {
+	// Upload requests let anyone knowing the request URL upload files to the requesting user
+	request := ctx.GetUploadRequest()
+	if request == nil && !ctx.IsWhitelisted() {
 		ctx.Forbidden("untrusted source IP address")
 		return
 	}
@@ -35,8 +34,9 @@ func CreateUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Requ
 	upload := &common.Upload{}
 
 	// Deserialize json body
+	// Upload options can't be set when uploading through an upload request
 	version := 0
-	if len(body) > 0 {
+	if len(body) > 0 && request == nil {
 		version, err = common.UnmarshalUpload(body, upload)
 		if err != nil {
 			ctx.BadRequest("unable to deserialize request body : %s", err.Error())
@@ -66,8 +66,8 @@ func CreateUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Requ
 		header := common.EncodeAuthBasicHeader(upload.Login, upload.Password)
 		resp.Header().Add("Authorization", "Basic "+header)
 
-		// Save only the md5sum of this string to authenticate further requests
-		upload.Password, err = utils.Md5sum(header)
+		// Save only a salted hash of this string to authenticate further requests
+		upload.Password, err = common.HashUploadPassword(header)
 		if err != nil {
 			ctx.BadRequest("unable to generate password hash : %s", err)
 			return
@@ -82,6 +82,16 @@ func CreateUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	// Check group membership and quotas
+	if !ctx.CheckUploadGroup(upload) {
+		return
+	}
+
+	// Check the upload against the external upload policy
+	if !ctx.CheckUploadPolicy(upload) {
+		return
+	}
+
 	// Save the metadata
 	err = ctx.GetMetadataBackend().CreateUpload(upload)
 	if err != nil {
@@ -89,14 +99,22 @@ func CreateUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	ctx.NotifyEvent(common.EventUploadCreated, upload, nil)
+
 	// Remove all private information (ip, data backend details, ...) before
 	// sending metadata back to the client
 	uploadToken := upload.UploadToken
+	acl := upload.ACL
+	requestID := upload.RequestID
 	upload.Sanitize()
 	upload.DownloadDomain = config.DownloadDomain
 
-	// Show upload token since its an upload creation
+	// Show upload token and access control list since its an upload creation
 	upload.UploadToken = uploadToken
+	upload.RequestID = requestID
+	if request == nil {
+		upload.ACL = acl
+	}
 	upload.IsAdmin = true
 
 	// Print upload metadata in the json response.
@@ -8,11 +8,13 @@ import (
 
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/policy"
 )
 
 func createTestUpload(t *testing.T, ctx *context.Context, upload *common.Upload) {
@@ -47,6 +49,29 @@ func TestCreateUploadWithoutOptions(t *testing.T) {
 	require.NotEqual(t, "", upload.UploadToken, "missing upload token")
 }
 
+func TestCreateUploadPolicyReject(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	hook := func(request *policy.Request) *policy.Response {
+		if request.Stage == policy.UploadStage && request.Upload.Comments == "spam" {
+			return &policy.Response{Accept: false, Message: "no spam please"}
+		}
+		return &policy.Response{Accept: true}
+	}
+	ctx.SetPolicy(policy.New(testPolicyHook(hook), time.Second, false))
+
+	uploadToCreate := &common.Upload{Comments: "spam"}
+	reqBody, err := json.Marshal(uploadToCreate)
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	context.TestForbidden(t, rr, "no spam please")
+}
+
 func TestCreateUploadWithOptions(t *testing.T) {
 	config := common.NewConfiguration()
 	config.Authentication = true
@@ -98,6 +123,12 @@ func TestCreateUploadWithOptions(t *testing.T) {
 	require.Equal(t, "", upload.Password, "invalid upload password")
 	require.Equal(t, len(uploadToCreate.Files), len(upload.Files), "invalid upload password")
 
+	uploadFromDB, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	ok, legacy := common.CheckUploadPassword(common.EncodeAuthBasicHeader("foo", "bar"), uploadFromDB.Password)
+	require.True(t, ok, "invalid upload password hash")
+	require.False(t, legacy, "upload password should not be hashed with md5")
+
 	for _, file := range upload.Files {
 		require.NotEqual(t, "", file.ID, "missing file id")
 		require.Equal(t, fileToUpload.Name, file.Name, "invalid file name")
@@ -106,6 +137,59 @@ func TestCreateUploadWithOptions(t *testing.T) {
 	}
 }
 
+func TestCreateUploadWithACL(t *testing.T) {
+	config := common.NewConfiguration()
+	config.Authentication = true
+
+	ctx := newTestingContext(config)
+
+	uploadToCreate := &common.Upload{}
+	uploadToCreate.ACL = []string{"user:local:bob", "group:google:example.com"}
+
+	reqBody, err := json.Marshal(uploadToCreate)
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var upload = &common.Upload{}
+	err = json.Unmarshal(respBody, upload)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	require.True(t, upload.Restricted, "invalid upload restricted status")
+	require.Equal(t, uploadToCreate.ACL, upload.ACL, "invalid upload acl")
+
+	acl, err := ctx.GetMetadataBackend().GetUploadACL(upload.ID)
+	require.NoError(t, err, "unable to get upload acl")
+	require.ElementsMatch(t, uploadToCreate.ACL, acl, "invalid upload acl")
+}
+
+func TestCreateUploadWithInvalidACL(t *testing.T) {
+	config := common.NewConfiguration()
+	config.Authentication = true
+
+	ctx := newTestingContext(config)
+
+	reqBody, err := json.Marshal(&common.Upload{ACL: []string{"bob"}})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+
+	context.TestBadRequest(t, rr, "invalid access control list subject bob")
+}
+
 func TestCreateWithForbiddenOptions(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -364,6 +448,64 @@ func TestCreateWithFilenameTooLong(t *testing.T) {
 	context.TestBadRequest(t, rr, "is too long")
 }
 
+func TestCreateWithServerManagedFileFields(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	reqBody := []byte(`{"files":[{"fileName":"file","status":"uploaded","downloads":-10,"archive":"zip","preview":"image","version":3,"versionCreatedAt":"2020-01-01T00:00:00Z","paste":true}]}`)
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var upload = &common.Upload{}
+	err = json.Unmarshal(rr.Body.Bytes(), upload)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Len(t, upload.Files, 1, "invalid file count")
+
+	file, err := ctx.GetMetadataBackend().GetFile(upload.Files[0].ID)
+	require.NoError(t, err, "unable to get file")
+	require.NotNil(t, file, "missing file")
+	require.Equal(t, common.FileMissing, file.Status, "invalid file status")
+	require.Equal(t, 0, file.Downloads, "invalid file downloads")
+	require.Empty(t, file.Archive, "invalid file archive")
+	require.Empty(t, file.Preview, "invalid file preview")
+	require.Equal(t, 0, file.Version, "invalid file version")
+	require.Nil(t, file.VersionCreatedAt, "invalid file version date")
+	require.False(t, file.Paste, "invalid file paste")
+}
+
+func TestCreateWithForgedRequestID(t *testing.T) {
+	ctx, user := newUploadRequestTestingContext(t)
+
+	request := &common.UploadRequest{User: user.ID, MaxFiles: 1}
+	createTestUploadRequest(t, ctx, request)
+
+	reqBody := []byte(`{"requestId":"` + request.ID + `","frozen":true}`)
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var upload = &common.Upload{}
+	err = json.Unmarshal(rr.Body.Bytes(), upload)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Empty(t, upload.RequestID, "invalid request id")
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.NotNil(t, u, "missing upload")
+	require.Empty(t, u.RequestID, "invalid request id")
+	require.False(t, u.Frozen, "invalid frozen")
+
+	stats, err := ctx.GetMetadataBackend().GetUploadRequestStatistics(request.ID)
+	require.NoError(t, err, "unable to get upload request statistics")
+	require.Equal(t, 0, stats.Uploads, "invalid request upload count")
+}
+
 //func TestCreateWithMetadataBackendError(t *testing.T) {
 //	ctx := newTestingContext(common.NewConfiguration())
 //	ctx.GetMetadataBackend().(*metadatadata_test.Backend).SetError(errors.New("metadata backend error"))
@@ -383,3 +525,112 @@ func TestCreateWithFilenameTooLong(t *testing.T) {
 //	CreateUpload(ctx, rr, req)
 //	context.TestInternalServerError(t, rr, "create upload error : metadata backend error")
 //}
+
+func TestCreateGroupUpload(t *testing.T) {
+	config := common.NewConfiguration()
+	config.Authentication = true
+
+	ctx := newTestingContext(config)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+	ctx.SetUser(user)
+
+	group := common.NewGroup("team")
+	err = ctx.GetMetadataBackend().CreateGroup(group)
+	require.NoError(t, err, "unable to create group")
+
+	reqBody, err := json.Marshal(&common.Upload{GroupID: group.ID})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	context.TestForbidden(t, rr, "you are not a member of group team")
+
+	err = ctx.GetMetadataBackend().SaveGroupMember(&common.GroupMember{GroupID: group.ID, UserID: user.ID, Role: common.GroupRoleMember})
+	require.NoError(t, err, "unable to add group member")
+
+	req, err = http.NewRequest("POST", "/upload", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var upload = &common.Upload{}
+	err = json.Unmarshal(respBody, upload)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, group.ID, upload.GroupID, "invalid upload group")
+
+	result, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Equal(t, group.ID, result.GroupID, "invalid upload group")
+	require.Equal(t, user.ID, result.User, "invalid upload user")
+}
+
+func TestCreateGroupUploadWithGroupToken(t *testing.T) {
+	config := common.NewConfiguration()
+	config.Authentication = true
+	config.NoAnonymousUploads = true
+
+	ctx := newTestingContext(config)
+
+	group := common.NewGroup("team")
+	err := ctx.GetMetadataBackend().CreateGroup(group)
+	require.NoError(t, err, "unable to create group")
+
+	token := group.NewToken()
+	ctx.SetGroup(group)
+	ctx.SetToken(&common.Token{Token: token.Token})
+
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var upload = &common.Upload{}
+	err = json.Unmarshal(respBody, upload)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	result, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Equal(t, group.ID, result.GroupID, "invalid upload group")
+	require.Equal(t, token.Token, result.Token, "invalid upload token")
+	require.Equal(t, "", result.User, "invalid upload user")
+}
+
+func TestCreateGroupUploadQuotaReached(t *testing.T) {
+	config := common.NewConfiguration()
+	config.Authentication = true
+
+	ctx := newTestingContext(config)
+
+	group := common.NewGroup("team")
+	group.MaxUploads = 1
+	err := ctx.GetMetadataBackend().CreateGroup(group)
+	require.NoError(t, err, "unable to create group")
+
+	ctx.SetGroup(group)
+	ctx.SetToken(&common.Token{Token: group.NewToken().Token})
+
+	createTestUpload(t, ctx, &common.Upload{GroupID: group.ID})
+
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	context.TestForbidden(t, rr, "group team upload quota reached, limit is 1 uploads")
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	goContext "context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/fetch"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+// fetchProgressInterval is the minimum delay between two updates of the fetch progress in the metadata
+var fetchProgressInterval = time.Second
+
+// fetchParams are the parameters of a server-side fetch
+type fetchParams struct {
+	URL  string `json:"url"`
+	Name string `json:"fileName"`
+	Path string `json:"path"`
+}
+
+// FetchFile add a file to an existing upload from a remote URL
+// The file is fetched by the server in the background, its status and size report the progress
+func FetchFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	config := ctx.GetConfig()
+
+	if !config.Fetch {
+		ctx.BadRequest("server-side fetch is disabled")
+		return
+	}
+
+	// Get upload from context
+	upload := ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	// Check authorization
+	if !ctx.IsUploadAdmin() {
+		ctx.Forbidden("you are not allowed to add file to this upload")
+		return
+	}
+
+	// Nobody would be waiting for the data of a streamed file
+	if upload.Stream {
+		ctx.BadRequest("unable to fetch a file in a stream upload")
+		return
+	}
+
+	params := &fetchParams{}
+	if !readJSONParams(ctx, resp, req, params) {
+		return
+	}
+
+	if params.URL == "" {
+		ctx.MissingParameter("url")
+		return
+	}
+
+	u, err := url.Parse(params.URL)
+	if err != nil {
+		ctx.InvalidParameter("url : %s", err)
+		return
+	}
+
+	client := fetch.NewClient(config)
+	err = client.CheckURL(u)
+	if err != nil {
+		ctx.BadRequest("unable to fetch URL : %s", err)
+		return
+	}
+
+	// Default to the last element of the URL path
+	fileName := params.Name
+	if fileName == "" {
+		fileName = path.Base(u.Path)
+		if fileName == "." || fileName == "/" {
+			fileName = ""
+		}
+	}
+	if fileName == "" {
+		ctx.MissingParameter("file name")
+		return
+	}
+
+	maxFileSize, ok := ctx.GetMaxFileSize(upload)
+	if !ok {
+		return
+	}
+
+	// Limit the number of concurrent fetches server-wide and for each user or anonymous upload
+	limiter := ctx.GetFetchLimiter()
+	limiterKey := "upload:" + upload.ID
+	if user := ctx.GetUser(); user != nil {
+		limiterKey = "user:" + user.ID
+	}
+	if limiter != nil && !limiter.Acquire(limiterKey) {
+		ctx.TooManyRequests("too many files are being fetched, please try again later")
+		return
+	}
+
+	file, ok := createFile(ctx, upload, fileName, params.Path)
+	if ok {
+		file.Status = common.FileUploading
+		file.Source = fetch.GetSource(u)
+		err = ctx.GetMetadataBackend().UpdateFile(file, common.FileMissing)
+		if err != nil {
+			ctx.InternalServerError("unable to update file status", err)
+			ok = false
+		}
+	}
+	if !ok {
+		if limiter != nil {
+			limiter.Release(limiterKey)
+		}
+		return
+	}
+
+	// The request context must not be used once the response has been sent
+	bgCtx := ctx.Detach()
+	go func(upload common.Upload, file common.File) {
+		if limiter != nil {
+			defer limiter.Release(limiterKey)
+		}
+
+		err := fetchFile(bgCtx, client, &upload, &file, u, maxFileSize)
+		if err != nil {
+			log := bgCtx.GetLogger()
+			log.Warningf("unable to fetch file %s from %s : %s", file.ID, file.Source, err)
+
+			// Only the message of the internal errors is reported to the user
+			fetchError := err.Error()
+			if httpError, ok := err.(common.HTTPError); ok {
+				fetchError = httpError.Message
+			}
+
+			err = bgCtx.GetMetadataBackend().FailFileFetch(&file, fetchError)
+			if err != nil {
+				log.Warningf("unable to update file %s : %s", file.ID, err)
+			}
+		}
+	}(*upload, *file)
+
+	// Remove all private information (ip, data backend details, ...) before
+	// sending metadata back to the client
+	file.Sanitize()
+
+	common.WriteJSONResponse(resp, file)
+}
+
+// fetchFile download the remote file and save it to the data backend like an uploaded file
+func fetchFile(ctx *context.Context, client *fetch.Client, upload *common.Upload, file *common.File, u *url.URL, maxFileSize int64) (err error) {
+	response, err := client.Get(goContext.Background(), u)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	// Fail early, the preprocessor enforces the limit anyway
+	if response.ContentLength > maxFileSize {
+		return fmt.Errorf("file too big (limit is set to %d bytes)", maxFileSize)
+	}
+
+	var fetchSize int64
+	if response.ContentLength > 0 {
+		fetchSize = response.ContentLength
+	}
+
+	reader := &fetchProgressReader{
+		reader:          response.Body,
+		metadataBackend: ctx.GetMetadataBackend(),
+		file:            *file,
+		fetchSize:       fetchSize,
+		updatedAt:       time.Now(),
+	}
+
+	return saveFile(ctx, upload, file, reader, maxFileSize)
+}
+
+// fetchProgressReader save the number of bytes read so far in the file metadata
+type fetchProgressReader struct {
+	reader          io.Reader
+	metadataBackend *metadata.Backend
+	file            common.File
+	fetchSize       int64
+	size            int64
+	updatedAt       time.Time
+}
+
+// Read implements io.Reader
+func (r *fetchProgressReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.size += int64(n)
+
+	if time.Since(r.updatedAt) >= fetchProgressInterval {
+		r.updatedAt = time.Now()
+		// The progress is only informative
+		_ = r.metadataBackend.UpdateFileFetchProgress(&r.file, r.size, r.fetchSize)
+	}
+
+	return n, err
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/fetch"
+)
+
+func newFetchTestingContext(t *testing.T) (ctx *context.Context) {
+	config := common.NewConfiguration()
+	config.Fetch = true
+	config.FetchAllowedNetworks = []string{"127.0.0.1"}
+	require.NoError(t, config.Initialize(), "unable to initialize config")
+
+	ctx = newTestingContext(config)
+	ctx.SetUploadAdmin(true)
+	createTestUpload(t, ctx, &common.Upload{})
+
+	return ctx
+}
+
+func fetchTestFile(t *testing.T, ctx *context.Context, params *fetchParams) *httptest.ResponseRecorder {
+	body, err := json.Marshal(params)
+	require.NoError(t, err, "unable to serialize params")
+
+	req, err := http.NewRequest("POST", "/file/"+ctx.GetUpload().ID+"/fetch", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	FetchFile(ctx, rr, req)
+	return rr
+}
+
+func waitFetchedFile(t *testing.T, ctx *context.Context, fileID string) *common.File {
+	for i := 0; i < 100; i++ {
+		file, err := ctx.GetMetadataBackend().GetFile(fileID)
+		require.NoError(t, err, "unable to get file")
+		require.NotNil(t, file, "missing file")
+		if file.Status != common.FileUploading {
+			return file
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.Fail(t, "file is still being fetched")
+	return nil
+}
+
+func TestFetchFile(t *testing.T) {
+	ctx := newFetchTestingContext(t)
+
+	remote := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		_, _ = resp.Write([]byte("data"))
+	}))
+	defer remote.Close()
+
+	rr := fetchTestFile(t, ctx, &fetchParams{URL: "http://user:secret@" + remote.Listener.Addr().String() + "/dir/artifact.txt?token=secret", Path: "releases"})
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	file := &common.File{}
+	err = json.Unmarshal(respBody, file)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, "artifact.txt", file.Name, "invalid file name")
+	require.Equal(t, "releases", file.Path, "invalid file path")
+	require.Equal(t, common.FileUploading, file.Status, "invalid file status")
+	require.Equal(t, remote.URL+"/dir/artifact.txt", file.Source, "invalid file source")
+
+	file = waitFetchedFile(t, ctx, file.ID)
+	require.Equal(t, common.FileUploaded, file.Status, "invalid file status")
+	require.Equal(t, int64(4), file.Size, "invalid file size")
+	require.Equal(t, "8d777f385d3dfec8815d20f7496026dc", file.Md5, "invalid file md5")
+	require.Empty(t, file.FetchError, "unexpected fetch error")
+
+	reader, err := ctx.GetDataBackend().GetFile(file)
+	require.NoError(t, err, "unable to get file from data backend")
+	content, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data", string(content), "invalid file content")
+}
+
+func TestFetchFileName(t *testing.T) {
+	ctx := newFetchTestingContext(t)
+
+	rr := fetchTestFile(t, ctx, &fetchParams{URL: "http://127.0.0.1:1/"})
+	context.TestMissingParameter(t, rr, "file name")
+
+	rr = fetchTestFile(t, ctx, &fetchParams{URL: "http://127.0.0.1:1/", Name: "index.html"})
+	context.TestOK(t, rr)
+
+	file := &common.File{}
+	err := json.Unmarshal(rr.Body.Bytes(), file)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, "index.html", file.Name, "invalid file name")
+
+	file = waitFetchedFile(t, ctx, file.ID)
+	require.Equal(t, common.FileRemoved, file.Status, "invalid file status")
+	require.NotEmpty(t, file.FetchError, "missing fetch error")
+}
+
+func TestFetchFileDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+	createTestUpload(t, ctx, &common.Upload{})
+
+	rr := fetchTestFile(t, ctx, &fetchParams{URL: "http://127.0.0.1/file"})
+	context.TestBadRequest(t, rr, "server-side fetch is disabled")
+}
+
+func TestFetchFileNotAdmin(t *testing.T) {
+	ctx := newFetchTestingContext(t)
+	ctx.SetUploadAdmin(false)
+
+	rr := fetchTestFile(t, ctx, &fetchParams{URL: "http://127.0.0.1/file"})
+	context.TestForbidden(t, rr, "you are not allowed to add file to this upload")
+}
+
+func TestFetchFileStream(t *testing.T) {
+	ctx := newFetchTestingContext(t)
+	ctx.GetUpload().Stream = true
+
+	rr := fetchTestFile(t, ctx, &fetchParams{URL: "http://127.0.0.1/file"})
+	context.TestBadRequest(t, rr, "unable to fetch a file in a stream upload")
+}
+
+func TestFetchFileMissingURL(t *testing.T) {
+	ctx := newFetchTestingContext(t)
+
+	rr := fetchTestFile(t, ctx, &fetchParams{})
+	context.TestMissingParameter(t, rr, "url")
+}
+
+func TestFetchFileInvalidScheme(t *testing.T) {
+	ctx := newFetchTestingContext(t)
+
+	rr := fetchTestFile(t, ctx, &fetchParams{URL: "file:///etc/passwd"})
+	context.TestBadRequest(t, rr, "invalid URL scheme")
+}
+
+func TestFetchFileDeniedAddress(t *testing.T) {
+	ctx := newFetchTestingContext(t)
+
+	rr := fetchTestFile(t, ctx, &fetchParams{URL: "http://169.254.169.254/latest/meta-data"})
+	context.TestBadRequest(t, rr, "address 169.254.169.254 is not allowed")
+}
+
+func TestFetchFileDeniedHostName(t *testing.T) {
+	ctx := newFetchTestingContext(t)
+	ctx.GetConfig().FetchAllowedNetworks = nil
+	require.NoError(t, ctx.GetConfig().Initialize(), "unable to initialize config")
+
+	remote := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		_, _ = resp.Write([]byte("data"))
+	}))
+	defer remote.Close()
+
+	// The host name is only resolved to a loopback address when connecting
+	port := remote.Listener.Addr().(*net.TCPAddr).Port
+	rr := fetchTestFile(t, ctx, &fetchParams{URL: fmt.Sprintf("http://localhost:%d/file", port)})
+	context.TestOK(t, rr)
+
+	file := &common.File{}
+	err := json.Unmarshal(rr.Body.Bytes(), file)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	file = waitFetchedFile(t, ctx, file.ID)
+	require.Equal(t, common.FileRemoved, file.Status, "invalid file status")
+	require.Contains(t, file.FetchError, "is not allowed", "invalid fetch error")
+}
+
+func TestFetchFileRemoteError(t *testing.T) {
+	ctx := newFetchTestingContext(t)
+
+	remote := httptest.NewServer(http.NotFoundHandler())
+	defer remote.Close()
+
+	rr := fetchTestFile(t, ctx, &fetchParams{URL: remote.URL + "/file"})
+	context.TestOK(t, rr)
+
+	file := &common.File{}
+	err := json.Unmarshal(rr.Body.Bytes(), file)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	file = waitFetchedFile(t, ctx, file.ID)
+	require.Equal(t, common.FileRemoved, file.Status, "invalid file status")
+	require.Equal(t, "unexpected response status 404 Not Found", file.FetchError, "invalid fetch error")
+}
+
+func TestFetchFileTooBig(t *testing.T) {
+	ctx := newFetchTestingContext(t)
+	ctx.GetConfig().MaxFileSize = 3
+
+	remote := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		// Don't announce the size so the limit is enforced while streaming
+		resp.(http.Flusher).Flush()
+		_, _ = resp.Write([]byte("data"))
+	}))
+	defer remote.Close()
+
+	rr := fetchTestFile(t, ctx, &fetchParams{URL: remote.URL + "/file"})
+	context.TestOK(t, rr)
+
+	file := &common.File{}
+	err := json.Unmarshal(rr.Body.Bytes(), file)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	file = waitFetchedFile(t, ctx, file.ID)
+	require.Equal(t, common.FileRemoved, file.Status, "invalid file status")
+	require.Equal(t, "file too big (limit is set to 3 bytes)", file.FetchError, "invalid fetch error")
+}
+
+func TestFetchFileTooManyRequests(t *testing.T) {
+	ctx := newFetchTestingContext(t)
+	limiter := fetch.NewLimiter(0, 1)
+	ctx.SetFetchLimiter(limiter)
+
+	unblock := make(chan struct{})
+	remote := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		<-unblock
+		_, _ = resp.Write([]byte("data"))
+	}))
+	defer remote.Close()
+
+	rr := fetchTestFile(t, ctx, &fetchParams{URL: remote.URL + "/file"})
+	context.TestOK(t, rr)
+
+	file := &common.File{}
+	err := json.Unmarshal(rr.Body.Bytes(), file)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	rr = fetchTestFile(t, ctx, &fetchParams{URL: remote.URL + "/file"})
+	context.TestTooManyRequests(t, rr, "too many files are being fetched, please try again later")
+
+	close(unblock)
+	file = waitFetchedFile(t, ctx, file.ID)
+	require.Equal(t, common.FileUploaded, file.Status, "invalid file status")
+
+	// The slot is released once the fetch is done
+	acquired := false
+	for i := 0; i < 100 && !acquired; i++ {
+		acquired = limiter.Acquire("upload:" + ctx.GetUpload().ID)
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.True(t, acquired, "the fetch slot has not been released")
+	limiter.Release("upload:" + ctx.GetUpload().ID)
+
+	rr = fetchTestFile(t, ctx, &fetchParams{URL: remote.URL + "/file"})
+	context.TestOK(t, rr)
+}
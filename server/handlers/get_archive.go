@@ -1,19 +1,21 @@
 package handlers
 
 import (
-	"archive/zip"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"path"
 	"strings"
 
 	"github.com/gorilla/mux"
 
+	"github.com/root-gg/plik/server/archive"
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 )
 
-// GetArchive download all file of the upload in a zip archive
+// GetArchive download all file of the upload or a selection of files in a zip or tar archive
 func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
 	log := ctx.GetLogger()
 
@@ -32,17 +34,14 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	// Set content type
-	resp.Header().Set("Content-Type", "application/zip")
-
 	/* Additional security headers for possibly unsafe content */
 	resp.Header().Set("X-Content-Type-Options", "nosniff")
 	resp.Header().Set("X-XSS-Protection", "1; mode=block")
 	resp.Header().Set("X-Frame-Options", "DENY")
 	resp.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'none'; style-src 'none'; img-src 'none'; connect-src 'none'; font-src 'none'; object-src 'none'; media-src 'none'; child-src 'none'; form-action 'none'; frame-ancestors 'none'; plugin-types ''; sandbox ''")
 
-	/* Additional header for disabling cache if the upload is OneShot */
-	if upload.OneShot {
+	/* Additional header for disabling cache if the upload is OneShot or has a download cap */
+	if upload.IsDownloadLimited() {
 		resp.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate") // HTTP 1.1
 		resp.Header().Set("Pragma", "no-cache")                                   // HTTP 1.0
 		resp.Header().Set("Expires", "0")                                         // Proxies
@@ -61,8 +60,27 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	if !strings.HasSuffix(fileName, ".zip") {
-		ctx.InvalidParameter("archive name, missing .zip extension")
+	// The archive format is chosen by the archive name extension
+	format := archive.GetFormat(fileName)
+	if format == nil {
+		ctx.InvalidParameter("archive name, unsupported extension, must be one of %s", strings.Join(archive.GetExtensions(), ", "))
+		return
+	}
+
+	// Set content type
+	resp.Header().Set("Content-Type", format.ContentType)
+
+	// If "path" GET params is set only the files of this sub-folder are archived
+	folder, err := common.CleanFilePath(req.URL.Query().Get("path"))
+	if err != nil {
+		ctx.InvalidParameter(err.Error())
+		return
+	}
+
+	// Only archive the selected files if any
+	selection, err := getArchiveSelection(req)
+	if err != nil {
+		ctx.BadRequest(err.Error())
 		return
 	}
 
@@ -78,7 +96,7 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 
 	// HEAD Request => Do not print file, user just wants http headers
 	// GET  Request => Print file content
-	if req.Method == "GET" {
+	if req.Method != "HEAD" {
 		// Get files to archive
 
 		var files []*common.File
@@ -88,12 +106,31 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 				return nil
 			}
 
+			// Ignore files outside of the requested sub-folder
+			if folder != "" && file.Path != folder && !strings.HasPrefix(file.Path, folder+"/") {
+				return nil
+			}
+
+			// Ignore files that have not been selected
+			if selection != nil && !selection[file.ID] {
+				return nil
+			}
+
 			if upload.OneShot {
 				// Update file status
 				err := ctx.GetMetadataBackend().UpdateFileStatus(file, file.Status, common.FileRemoved)
 				if err != nil {
 					return fmt.Errorf("unable to update file status : %s", err)
 				}
+			} else if upload.MaxDownloads > 0 {
+				// Ignore files that reached the download cap in the meantime
+				ok, err := ctx.GetMetadataBackend().IncrementFileDownloads(file, upload.MaxDownloads)
+				if err != nil {
+					return fmt.Errorf("unable to update file downloads : %s", err)
+				}
+				if !ok {
+					return nil
+				}
 			}
 
 			files = append(files, file)
@@ -101,9 +138,10 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 			return nil
 		}
 
-		err := ctx.GetMetadataBackend().ForEachUploadFiles(upload.ID, f)
+		err = ctx.GetMetadataBackend().ForEachUploadFiles(upload.ID, f)
 		if err != nil {
 			ctx.InternalServerError("unable to update file status", err)
+			return
 		}
 
 		if len(files) == 0 {
@@ -111,10 +149,18 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 			return
 		}
 
+		// Archive entries keep the directory hierarchy of the files
+		// When archiving a sub-folder entries are relative to its parent folder
+		parent := path.Dir(folder)
+
 		backend := ctx.GetDataBackend()
 
-		// The zip archive is piped directly to http response body without buffering
-		archive := zip.NewWriter(resp)
+		// The archive is piped directly to http response body without buffering
+		archiveWriter, err := format.NewWriter(resp)
+		if err != nil {
+			ctx.InternalServerError("unable to create archive", err)
+			return
+		}
 
 		for _, file := range files {
 			fileReader, err := backend.GetFile(file)
@@ -123,28 +169,75 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 				return
 			}
 
-			fileWriter, err := archive.Create(file.Name)
+			name := file.GetPath()
+			if folder != "" && parent != "." {
+				name = strings.TrimPrefix(name, parent+"/")
+			}
+
+			fileWriter, err := archiveWriter.Create(name, file.Size, file.CreatedAt)
 			if err != nil {
-				ctx.InternalServerError("error while creating zip archive", err)
+				ctx.InternalServerError("error while creating archive", err)
 				return
 			}
 
-			// File is piped directly to zip archive thus to the http response body without buffering
+			// File is piped directly to the archive thus to the http response body without buffering
 			_, err = io.Copy(fileWriter, fileReader)
 			if err != nil {
-				log.Warningf("error while copying zip archive to response body : %s", err)
+				log.Warningf("error while copying archive to response body : %s", err)
 			}
 
 			err = fileReader.Close()
 			if err != nil {
-				log.Warningf("error while closing zip archive reader : %s", err)
+				log.Warningf("error while closing archive reader : %s", err)
 			}
 		}
 
-		err = archive.Close()
+		err = archiveWriter.Close()
 		if err != nil {
-			log.Warningf("error while closing zip archive : %s", err)
+			log.Warningf("error while closing archive : %s", err)
 			return
 		}
 	}
 }
+
+// getArchiveSelection returns the set of file IDs to archive or nil to archive all the upload files
+// File IDs are read from the "files" query parameter ( repeated or comma separated ) and
+// for POST requests from a JSON body { "files" : [ ... ] } or an url encoded form body
+func getArchiveSelection(req *http.Request) (selection map[string]bool, err error) {
+	var ids []string
+
+	if req.Method == "POST" && strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		params := &struct {
+			Files []string `json:"files"`
+		}{}
+
+		err = json.NewDecoder(io.LimitReader(req.Body, 1048576)).Decode(params)
+		if err != nil {
+			return nil, fmt.Errorf("unable to deserialize request body : %s", err)
+		}
+		ids = append(ids, params.Files...)
+	}
+
+	// Parse both the query string and url encoded form bodies
+	err = req.ParseForm()
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse request form : %s", err)
+	}
+
+	for _, value := range req.Form["files"] {
+		ids = append(ids, strings.Split(value, ",")...)
+	}
+
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if selection == nil {
+			selection = make(map[string]bool)
+		}
+		selection[id] = true
+	}
+
+	return selection, nil
+}
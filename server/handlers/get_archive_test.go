@@ -1,15 +1,21 @@
 package handlers
 
 import (
+	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
-
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/require"
 
 	"github.com/root-gg/plik/server/common"
@@ -71,6 +77,287 @@ func TestGetArchive(t *testing.T) {
 	require.Equal(t, data, string(content), "invalid archived file content")
 }
 
+func getArchiveEntries(t *testing.T, ctx *context.Context, upload *common.Upload, query string) (entries map[string]string) {
+	req, err := http.NewRequest("GET", "/archive/"+upload.ID+"/"+"archive.zip"+query, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	return getArchiveEntriesFromRequest(t, ctx, req, "archive.zip")
+}
+
+func getArchiveEntriesFromRequest(t *testing.T, ctx *context.Context, req *http.Request, name string) (entries map[string]string) {
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"filename": name,
+	}
+	req = mux.SetURLVars(req, vars)
+
+	rr := ctx.NewRecorder(req)
+	GetArchive(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	entries = make(map[string]string)
+
+	if strings.HasSuffix(name, ".zip") {
+		z, err := zip.NewReader(bytes.NewReader(respBody), int64(len(respBody)))
+		require.NoError(t, err, "unable to unzip response body")
+
+		for _, f := range z.File {
+			fileReader, err := f.Open()
+			require.NoError(t, err, "unable to open archived file")
+
+			content, err := ioutil.ReadAll(fileReader)
+			require.NoError(t, err, "unable to read archived file")
+
+			entries[f.Name] = string(content)
+		}
+
+		return entries
+	}
+
+	var reader io.Reader = bytes.NewReader(respBody)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		reader, err = gzip.NewReader(reader)
+		require.NoError(t, err, "unable to gunzip response body")
+	case strings.HasSuffix(name, ".tar.zst"):
+		decoder, err := zstd.NewReader(reader)
+		require.NoError(t, err, "unable to decompress response body")
+		defer decoder.Close()
+		reader = decoder
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err, "unable to read tar archive")
+
+		content, err := ioutil.ReadAll(tarReader)
+		require.NoError(t, err, "unable to read archived file")
+
+		entries[header.Name] = string(content)
+	}
+
+	return entries
+}
+
+func TestGetArchiveWithPath(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	paths := map[string]string{
+		"root":          "",
+		"top":           "foo",
+		"nested":        "foo/bar",
+		"sibling":       "foobar",
+		"other":         "baz",
+		"deeply_nested": "foo/bar/baz",
+	}
+	for name, path := range paths {
+		file := upload.NewFile()
+		file.Name = name
+		file.Path = path
+		file.Size = int64(len(name))
+		file.Status = common.FileUploaded
+	}
+
+	createTestUpload(t, ctx, upload)
+	ctx.SetUpload(upload)
+
+	for _, file := range upload.Files {
+		err := createTestFile(ctx, file, bytes.NewBuffer([]byte(file.Name)))
+		require.NoError(t, err, "unable to create test file")
+	}
+
+	entries := getArchiveEntries(t, ctx, upload, "")
+	require.Len(t, entries, len(paths), "invalid archive file count")
+	require.Equal(t, "root", entries["root"], "invalid archived file")
+	require.Equal(t, "nested", entries["foo/bar/nested"], "invalid archived file")
+	require.Equal(t, "deeply_nested", entries["foo/bar/baz/deeply_nested"], "invalid archived file")
+
+	entries = getArchiveEntries(t, ctx, upload, "?path=foo")
+	require.Len(t, entries, 3, "invalid archive file count")
+	require.Equal(t, "top", entries["foo/top"], "invalid archived file")
+	require.Equal(t, "nested", entries["foo/bar/nested"], "invalid archived file")
+	require.Equal(t, "deeply_nested", entries["foo/bar/baz/deeply_nested"], "invalid archived file")
+
+	entries = getArchiveEntries(t, ctx, upload, "?path=foo/bar/")
+	require.Len(t, entries, 2, "invalid archive file count")
+	require.Equal(t, "nested", entries["bar/nested"], "invalid archived file")
+	require.Equal(t, "deeply_nested", entries["bar/baz/deeply_nested"], "invalid archived file")
+}
+
+func TestGetArchiveWithInvalidPath(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	ctx.SetUpload(upload)
+
+	req, err := http.NewRequest("GET", "/archive/"+upload.ID+"/"+"archive.zip?path=../foo", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"filename": "archive.zip",
+	}
+	req = mux.SetURLVars(req, vars)
+
+	rr := ctx.NewRecorder(req)
+	GetArchive(ctx, rr, req)
+	context.TestBadRequest(t, rr, "parent directory references are not allowed")
+}
+
+func createTestArchiveUpload(t *testing.T, ctx *context.Context, upload *common.Upload, names ...string) {
+	for _, name := range names {
+		file := upload.NewFile()
+		file.Name = name
+		file.Size = int64(len(name))
+		file.Status = common.FileUploaded
+	}
+
+	createTestUpload(t, ctx, upload)
+	ctx.SetUpload(upload)
+
+	for _, file := range upload.Files {
+		err := createTestFile(ctx, file, bytes.NewBuffer([]byte(file.Name)))
+		require.NoError(t, err, "unable to create test file")
+	}
+}
+
+func TestGetArchiveFormats(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	createTestArchiveUpload(t, ctx, upload, "file1", "file2")
+	upload.Files[1].Path = "foo"
+	err := ctx.GetMetadataBackend().UpdateFile(upload.Files[1], common.FileUploaded)
+	require.NoError(t, err, "unable to update file")
+
+	contentTypes := map[string]string{
+		"archive.zip":     "application/zip",
+		"archive.tar":     "application/x-tar",
+		"archive.tar.gz":  "application/gzip",
+		"archive.tgz":     "application/gzip",
+		"archive.tar.zst": "application/zstd",
+	}
+
+	for name, contentType := range contentTypes {
+		req, err := http.NewRequest("HEAD", "/archive/"+upload.ID+"/"+name, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+		req = mux.SetURLVars(req, map[string]string{"filename": name})
+
+		rr := ctx.NewRecorder(req)
+		GetArchive(ctx, rr, req)
+		context.TestOK(t, rr)
+		require.Equal(t, contentType, rr.Header().Get("Content-Type"), "invalid response content type for %s", name)
+
+		req, err = http.NewRequest("GET", "/archive/"+upload.ID+"/"+name, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		entries := getArchiveEntriesFromRequest(t, ctx, req, name)
+		require.Len(t, entries, 2, "invalid archive file count for %s", name)
+		require.Equal(t, "file1", entries["file1"], "invalid archived file for %s", name)
+		require.Equal(t, "file2", entries["foo/file2"], "invalid archived file for %s", name)
+	}
+}
+
+func TestGetArchiveSelection(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	createTestArchiveUpload(t, ctx, upload, "file1", "file2", "file3")
+
+	// Query string, repeated and comma separated
+	query := "?files=" + upload.Files[0].ID + "&files=" + upload.Files[1].ID + ",unknown"
+	req, err := http.NewRequest("GET", "/archive/"+upload.ID+"/archive.tar"+query, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	entries := getArchiveEntriesFromRequest(t, ctx, req, "archive.tar")
+	require.Len(t, entries, 2, "invalid archive file count")
+	require.Contains(t, entries, "file1", "missing archived file")
+	require.Contains(t, entries, "file2", "missing archived file")
+
+	// JSON body
+	body, err := json.Marshal(map[string][]string{"files": {upload.Files[2].ID}})
+	require.NoError(t, err, "unable to serialize request body")
+
+	req, err = http.NewRequest("POST", "/archive/"+upload.ID+"/archive.zip", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", "application/json")
+
+	entries = getArchiveEntriesFromRequest(t, ctx, req, "archive.zip")
+	require.Len(t, entries, 1, "invalid archive file count")
+	require.Contains(t, entries, "file3", "missing archived file")
+
+	// Url encoded form body
+	form := url.Values{"files": {upload.Files[0].ID, upload.Files[2].ID}}
+	req, err = http.NewRequest("POST", "/archive/"+upload.ID+"/archive.zip", strings.NewReader(form.Encode()))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	entries = getArchiveEntriesFromRequest(t, ctx, req, "archive.zip")
+	require.Len(t, entries, 2, "invalid archive file count")
+	require.Contains(t, entries, "file1", "missing archived file")
+	require.Contains(t, entries, "file3", "missing archived file")
+}
+
+func TestGetArchiveSelectionNothingToArchive(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	createTestArchiveUpload(t, ctx, upload, "file1")
+
+	req, err := http.NewRequest("GET", "/archive/"+upload.ID+"/archive.zip?files=unknown", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"filename": "archive.zip"})
+
+	rr := ctx.NewRecorder(req)
+	GetArchive(ctx, rr, req)
+	context.TestBadRequest(t, rr, "nothing to archive")
+}
+
+func TestGetArchiveSelectionInvalidJSON(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	createTestArchiveUpload(t, ctx, upload, "file1")
+
+	req, err := http.NewRequest("POST", "/archive/"+upload.ID+"/archive.zip", bytes.NewBufferString("not json"))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"filename": "archive.zip"})
+
+	rr := ctx.NewRecorder(req)
+	GetArchive(ctx, rr, req)
+	context.TestBadRequest(t, rr, "unable to deserialize request body")
+}
+
+func TestGetArchiveOneShotSelection(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{OneShot: true}
+	createTestArchiveUpload(t, ctx, upload, "file1", "file2")
+
+	req, err := http.NewRequest("GET", "/archive/"+upload.ID+"/archive.tar.gz?files="+upload.Files[0].ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	entries := getArchiveEntriesFromRequest(t, ctx, req, "archive.tar.gz")
+	require.Len(t, entries, 1, "invalid archive file count")
+
+	file, err := ctx.GetMetadataBackend().GetFile(upload.Files[0].ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, common.FileRemoved, file.Status, "invalid file status")
+
+	file, err = ctx.GetMetadataBackend().GetFile(upload.Files[1].ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, common.FileUploaded, file.Status, "invalid file status")
+}
+
 func TestGetArchiveNoFile(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -220,14 +507,14 @@ func TestGetArchiveInvalidArchiveName(t *testing.T) {
 
 	// Fake gorilla/mux vars
 	vars := map[string]string{
-		"filename": "archive.tar",
+		"filename": "archive.rar",
 	}
 	req = mux.SetURLVars(req, vars)
 
 	rr := ctx.NewRecorder(req)
 	GetArchive(ctx, rr, req)
 
-	context.TestBadRequest(t, rr, "invalid archive name, missing .zip extension")
+	context.TestBadRequest(t, rr, "invalid archive name, unsupported extension")
 }
 
 func TestGetArchiveDataBackendError(t *testing.T) {
@@ -32,6 +32,15 @@ func GetFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		panic("missing file from context")
 	}
 
+	// Previous versions are downloaded from the file URL with the version number
+	if version := req.URL.Query().Get("version"); version != "" {
+		var ok bool
+		file, ok = getFileVersion(ctx, file, version)
+		if !ok {
+			return
+		}
+	}
+
 	// File status check
 	if upload.Stream {
 		if file.Status != common.FileUploading {
@@ -45,12 +54,8 @@ func GetFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		}
 	}
 
-	if req.Method == "GET" && upload.OneShot {
-		// Update file status
-		err := ctx.GetMetadataBackend().UpdateFileStatus(file, file.Status, common.FileRemoved)
-		if err != nil {
-			ctx.InternalServerError("unable to update file status", err)
-		}
+	if req.Method == "GET" && !countDownload(ctx, upload, file) {
+		return
 	}
 
 	// Avoid rendering HTML in browser
@@ -74,8 +79,8 @@ func GetFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		resp.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'none'; style-src 'none'; img-src 'none'; connect-src 'none'; font-src 'none'; object-src 'none'; media-src 'self'; child-src 'none'; form-action 'none'; frame-ancestors 'none'; plugin-types; sandbox")
 	}
 
-	/* Additional header for disabling cache if the upload is OneShot */
-	if upload.OneShot { // If this is a one shot or stream upload we have to ensure it's downloaded only once.
+	/* Additional header for disabling cache if the upload is OneShot or has a download cap */
+	if upload.IsDownloadLimited() { // If this is a one shot or stream upload we have to ensure it's downloaded only once.
 		resp.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate") // HTTP 1.1
 		resp.Header().Set("Pragma", "no-cache")                                   // HTTP 1.0
 		resp.Header().Set("Expires", "0")                                         // Proxies
@@ -117,6 +122,61 @@ func GetFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		_, err = io.Copy(resp, fileReader)
 		if err != nil {
 			log.Warningf("error while copying file to response : %s", err)
+			return
 		}
+
+		ctx.NotifyEvent(common.EventFileDownloaded, upload, file)
 	}
 }
+
+// countDownload remove the files of one shot uploads and count the downloads of the uploads with a download cap
+// If the file can't be downloaded anymore, the error response is written and false returned.
+func countDownload(ctx *context.Context, upload *common.Upload, file *common.File) bool {
+	if upload.OneShot {
+		// Update file status
+		err := ctx.GetMetadataBackend().UpdateFileStatus(file, file.Status, common.FileRemoved)
+		if err != nil {
+			ctx.InternalServerError("unable to update file status", err)
+			return false
+		}
+	} else if upload.MaxDownloads > 0 {
+		// Count the download, the file is removed once the download cap is reached
+		ok, err := ctx.GetMetadataBackend().IncrementFileDownloads(file, upload.MaxDownloads)
+		if err != nil {
+			ctx.InternalServerError("unable to update file downloads", err)
+			return false
+		}
+		if !ok {
+			ctx.NotFound("file %s (%s) is not available : download limit reached", file.Name, file.ID)
+			return false
+		}
+	}
+
+	return true
+}
+
+// getFileVersion return the derived file object of a previous version of the file
+// If the version can't be found, the error response is written and false returned.
+func getFileVersion(ctx *context.Context, file *common.File, versionStr string) (*common.File, bool) {
+	version, err := strconv.Atoi(versionStr)
+	if err != nil || version <= 0 {
+		ctx.InvalidParameter("version")
+		return nil, false
+	}
+
+	if version == file.GetVersion() {
+		return file, true
+	}
+
+	fileVersion, err := ctx.GetMetadataBackend().GetFileVersion(file.ID, version)
+	if err != nil {
+		ctx.InternalServerError("unable to get file version", err)
+		return nil, false
+	}
+	if fileVersion == nil {
+		ctx.NotFound("file %s (%s) version %d not found", file.Name, file.ID, version)
+		return nil, false
+	}
+
+	return file.GetVersionFile(fileVersion), true
+}
@@ -10,12 +10,15 @@ import (
 
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 	data_test "github.com/root-gg/plik/server/data/testing"
+	"github.com/root-gg/plik/server/email"
+	smtp_test "github.com/root-gg/plik/server/email/testing"
 )
 
 func createTestFile(ctx *context.Context, file *common.File, reader io.Reader) (err error) {
@@ -61,6 +64,61 @@ func TestGetFile(t *testing.T) {
 	require.Equal(t, data, string(respBody), "invalid file content")
 }
 
+func TestGetFileNotifyDownload(t *testing.T) {
+	server, err := smtp_test.NewServer()
+	require.NoError(t, err, "unable to start fake SMTP server")
+	defer func() { _ = server.Close() }()
+
+	config := common.NewConfiguration()
+	config.SMTPAddress = server.Address()
+	config.SMTPFrom = "plik@root.gg"
+	ctx := newTestingContext(config)
+
+	mailer, err := email.NewMailer(config)
+	require.NoError(t, err, "unable to create mailer")
+	ctx.SetNotifier(email.NewNotifier(config, ctx.GetMetadataBackend(), mailer))
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	user.Email = "user@root.gg"
+	user.NotifyDownloads = true
+	err = ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	upload := &common.Upload{User: user.ID}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = "uploaded"
+	createTestUpload(t, ctx, upload)
+
+	err = createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
+	require.NoError(t, err, "unable to create test file")
+
+	getFile := func() {
+		ctx.SetUpload(upload)
+		ctx.SetFile(file)
+
+		req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		GetFile(ctx, rr, req)
+		context.TestOK(t, rr)
+	}
+
+	// Users are not notified of their own downloads
+	ctx.SetUser(user)
+	getFile()
+	_, err = server.Next(100 * time.Millisecond)
+	require.Error(t, err, "unexpected email")
+
+	ctx.SetUser(nil)
+	getFile()
+	message, err := server.Next(time.Second)
+	require.NoError(t, err, "missing email")
+	require.Equal(t, []string{user.Email}, message.To, "invalid recipient")
+	require.Equal(t, "[Plik] file has been downloaded", message.Subject, "invalid subject")
+}
+
 func TestGetOneShotFile(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -92,6 +150,44 @@ func TestGetOneShotFile(t *testing.T) {
 	require.Equal(t, data, string(respBody), "invalid file content")
 }
 
+func TestGetFileMaxDownloads(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{MaxDownloads: 2}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		GetFile(ctx, rr, req)
+		context.TestOK(t, rr)
+		require.Equal(t, "data", rr.Body.String(), "invalid file content")
+	}
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, 2, f.Downloads, "invalid file downloads")
+	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
+
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestNotFound(t, rr, fmt.Sprintf("file %s (%s) is not available : removed", file.Name, file.ID))
+}
+
 func TestGetRemovedFile(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -26,13 +26,40 @@ func GetUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Request
 
 	upload.Files = files
 
+	// Display the version history of the files
+	versions, err := ctx.GetMetadataBackend().GetUploadFileVersions(upload.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload file versions", err)
+		return
+	}
+
+	for _, version := range versions {
+		for _, file := range files {
+			if file.ID == version.FileID {
+				file.Versions = append(file.Versions, version)
+				break
+			}
+		}
+	}
+
 	// Remove all private information (ip, data backend details, ...) before
 	// sending metadata back to the client
+	requestID := upload.RequestID
 	upload.Sanitize()
 	upload.DownloadDomain = config.DownloadDomain
 
 	if ctx.IsUploadAdmin() {
 		upload.IsAdmin = true
+		upload.RequestID = requestID
+
+		// Only upload admins can see the upload access control list
+		if upload.Restricted {
+			upload.ACL, err = ctx.GetMetadataBackend().GetUploadACL(upload.ID)
+			if err != nil {
+				ctx.InternalServerError("unable to get upload access control list", err)
+				return
+			}
+		}
 	}
 
 	common.WriteJSONResponse(resp, upload)
@@ -64,3 +64,35 @@ func TestGetUploadMissingUpload(t *testing.T) {
 		GetUpload(ctx, rr, req)
 	})
 }
+
+func TestGetUploadACL(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{ACL: []string{"user:local:bob"}}
+	createTestUpload(t, ctx, upload)
+
+	getUpload := func() *common.Upload {
+		req, err := http.NewRequest("GET", "/upload/"+upload.ID, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		GetUpload(ctx, rr, req)
+		context.TestOK(t, rr)
+
+		respBody, err := ioutil.ReadAll(rr.Body)
+		require.NoError(t, err, "unable to read response body")
+
+		var uploadResult = &common.Upload{}
+		err = json.Unmarshal(respBody, uploadResult)
+		require.NoError(t, err, "unable to unmarshal response body")
+		return uploadResult
+	}
+
+	uploadResult := getUpload()
+	require.True(t, uploadResult.Restricted, "invalid upload restricted status")
+	require.Nil(t, uploadResult.ACL, "upload acl should only be visible to upload admins")
+
+	ctx.SetUploadAdmin(true)
+	uploadResult = getUpload()
+	require.Equal(t, []string{"user:local:bob"}, uploadResult.ACL, "invalid upload acl")
+}
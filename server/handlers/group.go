@@ -0,0 +1,495 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// GetGroups return the groups the user is a member of
+func GetGroups(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	groups, err := ctx.GetMetadataBackend().GetUserGroups(user.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get user groups", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, groups)
+}
+
+// CreateGroup create a new group managed by the user
+func CreateGroup(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	params := &common.Group{}
+	if !readJSONParams(ctx, resp, req, params) {
+		return
+	}
+
+	if (params.MaxUploads != 0 || params.MaxSize != 0) && !ctx.IsAdmin() {
+		ctx.Forbidden("only administrators can set group quotas")
+		return
+	}
+
+	group := common.NewGroup(params.Name)
+	group.MaxUploads = params.MaxUploads
+	group.MaxSize = params.MaxSize
+
+	err := group.Validate()
+	if err != nil {
+		ctx.BadRequest(err.Error())
+		return
+	}
+
+	if !checkGroupName(ctx, group) {
+		return
+	}
+
+	err = ctx.GetMetadataBackend().CreateGroup(group)
+	if err != nil {
+		ctx.InternalServerError("unable to create group", err)
+		return
+	}
+
+	member := &common.GroupMember{GroupID: group.ID, UserID: user.ID, Role: common.GroupRoleManager}
+	err = ctx.GetMetadataBackend().SaveGroupMember(member)
+	if err != nil {
+		ctx.InternalServerError("unable to add group manager", err)
+		return
+	}
+
+	group.Role = member.Role
+	group.Members = []*common.GroupMember{member}
+
+	common.WriteJSONResponse(resp, group)
+}
+
+// GetGroup return a group and its members
+func GetGroup(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	group, err := getGroup(ctx, req, false)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	group.Members, err = ctx.GetMetadataBackend().GetGroupMembers(group.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get group members", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, group)
+}
+
+// UpdateGroup rename a group or update the group quotas
+func UpdateGroup(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	group, err := getGroup(ctx, req, true)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	params := &common.Group{Name: group.Name, MaxUploads: group.MaxUploads, MaxSize: group.MaxSize}
+	if !readJSONParams(ctx, resp, req, params) {
+		return
+	}
+
+	if (params.MaxUploads != group.MaxUploads || params.MaxSize != group.MaxSize) && !ctx.IsAdmin() {
+		ctx.Forbidden("only administrators can set group quotas")
+		return
+	}
+
+	rename := params.Name != group.Name
+	group.Name = params.Name
+	group.MaxUploads = params.MaxUploads
+	group.MaxSize = params.MaxSize
+
+	err = group.Validate()
+	if err != nil {
+		ctx.BadRequest(err.Error())
+		return
+	}
+
+	if rename && !checkGroupName(ctx, group) {
+		return
+	}
+
+	err = ctx.GetMetadataBackend().UpdateGroup(group)
+	if err != nil {
+		ctx.InternalServerError("unable to update group", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, group)
+}
+
+// RemoveGroup delete a group and all the group uploads
+func RemoveGroup(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	group, err := getGroup(ctx, req, true)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	_, err = ctx.GetMetadataBackend().DeleteGroup(group.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to delete group", err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// AddGroupMember add a user to a group or update the role of a group member
+func AddGroupMember(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	group, err := getGroup(ctx, req, true)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	// Read request body
+	defer func() { _ = req.Body.Close() }()
+
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		ctx.BadRequest("unable to read request body : %s", err)
+		return
+	}
+
+	member := &common.GroupMember{}
+	err = json.Unmarshal(body, member)
+	if err != nil {
+		ctx.BadRequest("unable to deserialize request body : %s", err)
+		return
+	}
+
+	if member.UserID == "" {
+		ctx.MissingParameter("user id")
+		return
+	}
+
+	if member.Role == "" {
+		member.Role = common.GroupRoleMember
+	}
+
+	if !common.IsValidGroupRole(member.Role) {
+		ctx.InvalidParameter("role")
+		return
+	}
+
+	user, err := ctx.GetMetadataBackend().GetUser(member.UserID)
+	if err != nil {
+		ctx.InternalServerError("unable to get user", err)
+		return
+	}
+	if user == nil {
+		ctx.NotFound("user %s not found", member.UserID)
+		return
+	}
+
+	member.GroupID = group.ID
+	err = ctx.GetMetadataBackend().SaveGroupMember(member)
+	if err != nil {
+		ctx.InternalServerError("unable to save group member", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, member)
+}
+
+// RemoveGroupMember remove a user from a group, members can also leave a group by themselves
+func RemoveGroupMember(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	userID := vars["userID"]
+	if userID == "" {
+		ctx.MissingParameter("user id")
+		return
+	}
+
+	user := ctx.GetUser()
+	leave := user != nil && user.ID == userID
+
+	group, err := getGroup(ctx, req, !leave)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	deleted, err := ctx.GetMetadataBackend().DeleteGroupMember(group.ID, userID)
+	if err != nil {
+		ctx.InternalServerError("unable to delete group member", err)
+		return
+	}
+	if !deleted {
+		ctx.NotFound("group member not found")
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// GetGroupUploads return the uploads owned by a group
+func GetGroupUploads(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	config := ctx.GetConfig()
+
+	group, err := getGroup(ctx, req, true)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	pagingQuery := ctx.GetPagingQuery()
+
+	uploads, cursor, err := ctx.GetMetadataBackend().GetGroupUploads(group.ID, true, pagingQuery)
+	if err != nil {
+		ctx.InternalServerError("unable to get group uploads", err)
+		return
+	}
+
+	// Remove all private information (ip, data backend details, ...) before
+	// sending metadata back to the client
+	for _, upload := range uploads {
+		upload.Sanitize()
+		upload.DownloadDomain = config.DownloadDomain
+		upload.IsAdmin = true
+	}
+
+	pagingResponse := common.NewPagingResponse(uploads, cursor)
+	common.WriteJSONResponse(resp, pagingResponse)
+}
+
+// RemoveGroupUploads delete all the uploads owned by a group
+func RemoveGroupUploads(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	group, err := getGroup(ctx, req, true)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	deleted, err := ctx.GetMetadataBackend().DeleteGroupUploads(group.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to delete group uploads", err)
+		return
+	}
+
+	_, _ = resp.Write([]byte(fmt.Sprintf("%d uploads removed", deleted)))
+}
+
+// GetGroupStatistics return the group statistics and quotas
+func GetGroupStatistics(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	group, err := getGroup(ctx, req, false)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	stats, err := ctx.GetMetadataBackend().GetGroupStatistics(group)
+	if err != nil {
+		ctx.InternalServerError("unable to get group statistics", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, stats)
+}
+
+// GetGroupTokens return the group tokens
+func GetGroupTokens(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	group, err := getGroup(ctx, req, true)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	pagingQuery := ctx.GetPagingQuery()
+
+	tokens, cursor, err := ctx.GetMetadataBackend().GetGroupTokens(group.ID, pagingQuery)
+	if err != nil {
+		ctx.InternalServerError("unable to get group tokens", err)
+		return
+	}
+
+	pagingResponse := common.NewPagingResponse(tokens, cursor)
+	common.WriteJSONResponse(resp, pagingResponse)
+}
+
+// CreateGroupToken create a new group token
+func CreateGroupToken(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	group, err := getGroup(ctx, req, true)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	// Read request body
+	defer func() { _ = req.Body.Close() }()
+
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		ctx.BadRequest("unable to read request body : %s", err)
+		return
+	}
+
+	params := &common.GroupToken{}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, params)
+		if err != nil {
+			ctx.BadRequest("unable to deserialize request body : %s", err)
+			return
+		}
+	}
+
+	token := group.NewToken()
+	token.Comment = params.Comment
+
+	err = ctx.GetMetadataBackend().CreateGroupToken(token)
+	if err != nil {
+		ctx.InternalServerError("unable to create group token", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, token)
+}
+
+// RevokeGroupToken remove a group token
+func RevokeGroupToken(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	group, err := getGroup(ctx, req, true)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	vars := mux.Vars(req)
+	tokenStr := vars["token"]
+	if tokenStr == "" {
+		ctx.MissingParameter("token")
+		return
+	}
+
+	token, err := ctx.GetMetadataBackend().GetGroupToken(tokenStr)
+	if err != nil {
+		ctx.InternalServerError("unable to get group token", err)
+		return
+	}
+
+	if token == nil || token.GroupID != group.ID {
+		ctx.NotFound("token not found")
+		return
+	}
+
+	_, err = ctx.GetMetadataBackend().DeleteGroupToken(token.Token)
+	if err != nil {
+		ctx.InternalServerError("unable to delete group token", err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// getGroup return the group from the URL params if the user is a member of the group
+// If manager is true the user must also be a manager of the group. Administrators can manage all groups.
+func getGroup(ctx *context.Context, req *http.Request, manager bool) (group *common.Group, err error) {
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		return nil, common.NewHTTPError("missing user, please login first", nil, http.StatusUnauthorized)
+	}
+
+	vars := mux.Vars(req)
+	groupID := vars["groupID"]
+	if groupID == "" {
+		return nil, common.NewHTTPError("missing group id", nil, http.StatusBadRequest)
+	}
+
+	group, err = ctx.GetMetadataBackend().GetGroup(groupID)
+	if err != nil {
+		return nil, common.NewHTTPError("unable to get group", err, http.StatusInternalServerError)
+	}
+	if group == nil {
+		return nil, common.NewHTTPError(fmt.Sprintf("group %s not found", groupID), nil, http.StatusNotFound)
+	}
+
+	member, err := ctx.GetMetadataBackend().GetGroupMember(group.ID, user.ID)
+	if err != nil {
+		return nil, common.NewHTTPError("unable to get group member", err, http.StatusInternalServerError)
+	}
+	if member != nil {
+		group.Role = member.Role
+	}
+
+	if ctx.IsAdmin() {
+		return group, nil
+	}
+
+	// Do not disclose the existence of the group to non members
+	if member == nil {
+		return nil, common.NewHTTPError(fmt.Sprintf("group %s not found", groupID), nil, http.StatusNotFound)
+	}
+
+	if manager && !member.IsManager() {
+		return nil, common.NewHTTPError("you need to be a manager of this group", nil, http.StatusForbidden)
+	}
+
+	return group, nil
+}
+
+// readJSONParams deserialize the json parameters from the request body
+// On error the response is written and false is returned
+func readJSONParams(ctx *context.Context, resp http.ResponseWriter, req *http.Request, params interface{}) bool {
+	defer func() { _ = req.Body.Close() }()
+
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		ctx.BadRequest("unable to read request body : %s", err)
+		return false
+	}
+
+	if len(body) > 0 {
+		err = json.Unmarshal(body, params)
+		if err != nil {
+			ctx.BadRequest("unable to deserialize request body : %s", err)
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkGroupName verify that no other group has the same name
+// On error the response is written and false is returned
+func checkGroupName(ctx *context.Context, group *common.Group) bool {
+	other, err := ctx.GetMetadataBackend().GetGroupByName(group.Name)
+	if err != nil {
+		ctx.InternalServerError("unable to get group", err)
+		return false
+	}
+	if other != nil && other.ID != group.ID {
+		ctx.BadRequest("group name %s is already used", group.Name)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func createTestGroup(t *testing.T, ctx *context.Context, group *common.Group, user *common.User, role string) {
+	err := ctx.GetMetadataBackend().CreateGroup(group)
+	require.NoError(t, err, "unable to create group")
+
+	if user != nil {
+		err = ctx.GetMetadataBackend().SaveGroupMember(&common.GroupMember{GroupID: group.ID, UserID: user.ID, Role: role})
+		require.NoError(t, err, "unable to add group member")
+	}
+}
+
+func createTestUser(t *testing.T, ctx *context.Context, login string) (user *common.User) {
+	user = common.NewUser(common.ProviderLocal, login)
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+	return user
+}
+
+func TestCreateGroup(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := createTestUser(t, ctx, "user")
+	ctx.SetUser(user)
+
+	reqBody, err := json.Marshal(&common.Group{Name: "team"})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/groups", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateGroup(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	group := &common.Group{}
+	err = json.Unmarshal(respBody, group)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.NotZero(t, group.ID, "missing group id")
+	require.Equal(t, "team", group.Name, "invalid group name")
+	require.Equal(t, common.GroupRoleManager, group.Role, "invalid group role")
+
+	member, err := ctx.GetMetadataBackend().GetGroupMember(group.ID, user.ID)
+	require.NoError(t, err, "unable to get group member")
+	require.True(t, member.IsManager(), "creator is not a group manager")
+
+	// Group names are unique
+	req, err = http.NewRequest("POST", "/groups", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	CreateGroup(ctx, rr, req)
+	context.TestBadRequest(t, rr, "group name team is already used")
+}
+
+func TestCreateGroupQuotasNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(createTestUser(t, ctx, "user"))
+
+	reqBody, err := json.Marshal(&common.Group{Name: "team", MaxUploads: 10})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/groups", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateGroup(ctx, rr, req)
+	context.TestForbidden(t, rr, "only administrators can set group quotas")
+}
+
+func TestCreateGroupNoUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("POST", "/groups", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateGroup(ctx, rr, req)
+	context.TestUnauthorized(t, rr, "missing user, please login first")
+}
+
+func TestGetGroups(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := createTestUser(t, ctx, "user")
+	ctx.SetUser(user)
+
+	createTestGroup(t, ctx, common.NewGroup("team1"), user, common.GroupRoleMember)
+	createTestGroup(t, ctx, common.NewGroup("team2"), user, common.GroupRoleManager)
+	createTestGroup(t, ctx, common.NewGroup("team3"), nil, "")
+
+	req, err := http.NewRequest("GET", "/groups", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetGroups(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var groups []*common.Group
+	err = json.Unmarshal(respBody, &groups)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Len(t, groups, 2, "invalid group count")
+}
+
+func TestGetGroup(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := createTestUser(t, ctx, "user")
+	ctx.SetUser(user)
+
+	group := common.NewGroup("team")
+	createTestGroup(t, ctx, group, user, common.GroupRoleMember)
+
+	req, err := http.NewRequest("GET", "/groups/"+group.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID})
+
+	rr := ctx.NewRecorder(req)
+	GetGroup(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	result := &common.Group{}
+	err = json.Unmarshal(respBody, result)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, common.GroupRoleMember, result.Role, "invalid group role")
+	require.Len(t, result.Members, 1, "invalid group member count")
+}
+
+func TestGetGroupNotMember(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(createTestUser(t, ctx, "user"))
+
+	group := common.NewGroup("team")
+	createTestGroup(t, ctx, group, nil, "")
+
+	req, err := http.NewRequest("GET", "/groups/"+group.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID})
+
+	rr := ctx.NewRecorder(req)
+	GetGroup(ctx, rr, req)
+	context.TestNotFound(t, rr, "group "+group.ID+" not found")
+}
+
+func TestUpdateGroupQuotas(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := createTestUser(t, ctx, "user")
+	ctx.SetUser(user)
+
+	group := common.NewGroup("team")
+	createTestGroup(t, ctx, group, user, common.GroupRoleManager)
+
+	reqBody, err := json.Marshal(&common.Group{Name: "renamed", MaxSize: 1000})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/groups/"+group.ID, bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID})
+
+	rr := ctx.NewRecorder(req)
+	UpdateGroup(ctx, rr, req)
+	context.TestForbidden(t, rr, "only administrators can set group quotas")
+
+	user.IsAdmin = true
+
+	req, err = http.NewRequest("POST", "/groups/"+group.ID, bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID})
+
+	rr = ctx.NewRecorder(req)
+	UpdateGroup(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result, err := ctx.GetMetadataBackend().GetGroup(group.ID)
+	require.NoError(t, err, "unable to get group")
+	require.Equal(t, "renamed", result.Name, "invalid group name")
+	require.Equal(t, int64(1000), result.MaxSize, "invalid group max size")
+}
+
+func TestRemoveGroupNotManager(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := createTestUser(t, ctx, "user")
+	ctx.SetUser(user)
+
+	group := common.NewGroup("team")
+	createTestGroup(t, ctx, group, user, common.GroupRoleMember)
+
+	req, err := http.NewRequest("DELETE", "/groups/"+group.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID})
+
+	rr := ctx.NewRecorder(req)
+	RemoveGroup(ctx, rr, req)
+	context.TestForbidden(t, rr, "you need to be a manager of this group")
+}
+
+func TestRemoveGroup(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := createTestUser(t, ctx, "user")
+	ctx.SetUser(user)
+
+	group := common.NewGroup("team")
+	createTestGroup(t, ctx, group, user, common.GroupRoleManager)
+
+	upload := &common.Upload{GroupID: group.ID}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("DELETE", "/groups/"+group.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID})
+
+	rr := ctx.NewRecorder(req)
+	RemoveGroup(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result, err := ctx.GetMetadataBackend().GetGroup(group.ID)
+	require.NoError(t, err, "unable to get group")
+	require.Nil(t, result, "group has not been deleted")
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Nil(t, u, "group upload has not been deleted")
+}
+
+func TestAddAndRemoveGroupMember(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	manager := createTestUser(t, ctx, "manager")
+	ctx.SetUser(manager)
+
+	other := createTestUser(t, ctx, "other")
+
+	group := common.NewGroup("team")
+	createTestGroup(t, ctx, group, manager, common.GroupRoleManager)
+
+	reqBody, err := json.Marshal(&common.GroupMember{UserID: other.ID})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/groups/"+group.ID+"/members", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID})
+
+	rr := ctx.NewRecorder(req)
+	AddGroupMember(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	member, err := ctx.GetMetadataBackend().GetGroupMember(group.ID, other.ID)
+	require.NoError(t, err, "unable to get group member")
+	require.NotNil(t, member, "missing group member")
+	require.Equal(t, common.GroupRoleMember, member.Role, "invalid default role")
+
+	// Members can leave the group by themselves
+	ctx.SetUser(other)
+
+	req, err = http.NewRequest("DELETE", "/groups/"+group.ID+"/members/"+other.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID, "userID": other.ID})
+
+	rr = ctx.NewRecorder(req)
+	RemoveGroupMember(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	member, err = ctx.GetMetadataBackend().GetGroupMember(group.ID, other.ID)
+	require.NoError(t, err, "unable to get group member")
+	require.Nil(t, member, "group member has not been removed")
+}
+
+func TestAddGroupMemberInvalid(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	manager := createTestUser(t, ctx, "manager")
+	ctx.SetUser(manager)
+
+	group := common.NewGroup("team")
+	createTestGroup(t, ctx, group, manager, common.GroupRoleManager)
+
+	reqBody, err := json.Marshal(&common.GroupMember{UserID: manager.ID, Role: "admin"})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/groups/"+group.ID+"/members", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID})
+
+	rr := ctx.NewRecorder(req)
+	AddGroupMember(ctx, rr, req)
+	context.TestInvalidParameter(t, rr, "role")
+
+	reqBody, err = json.Marshal(&common.GroupMember{UserID: "local:nobody"})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err = http.NewRequest("POST", "/groups/"+group.ID+"/members", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID})
+
+	rr = ctx.NewRecorder(req)
+	AddGroupMember(ctx, rr, req)
+	context.TestNotFound(t, rr, "user local:nobody not found")
+}
+
+func TestGetGroupUploads(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := createTestUser(t, ctx, "user")
+	ctx.SetUser(user)
+
+	group := common.NewGroup("team")
+	createTestGroup(t, ctx, group, user, common.GroupRoleManager)
+
+	for i := 0; i < 3; i++ {
+		createTestUpload(t, ctx, &common.Upload{GroupID: group.ID, User: "local:other", RemoteIP: "1.2.3.4"})
+	}
+	createTestUpload(t, ctx, &common.Upload{User: user.ID})
+
+	ctx.SetPagingQuery(&common.PagingQuery{})
+
+	req, err := http.NewRequest("GET", "/groups/"+group.ID+"/uploads", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID})
+
+	rr := ctx.NewRecorder(req)
+	GetGroupUploads(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var response struct {
+		Results []*common.Upload `json:"results"`
+	}
+	err = json.Unmarshal(respBody, &response)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Len(t, response.Results, 3, "invalid upload count")
+	for _, upload := range response.Results {
+		require.Equal(t, "", upload.RemoteIP, "upload has not been sanitized")
+		require.True(t, upload.IsAdmin, "invalid upload admin status")
+	}
+
+	req, err = http.NewRequest("DELETE", "/groups/"+group.ID+"/uploads", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID})
+
+	rr = ctx.NewRecorder(req)
+	RemoveGroupUploads(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err = ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+	require.Equal(t, "3 uploads removed", string(respBody), "invalid response")
+}
+
+func TestGetGroupStatistics(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := createTestUser(t, ctx, "user")
+	ctx.SetUser(user)
+
+	group := common.NewGroup("team")
+	group.MaxUploads = 10
+	createTestGroup(t, ctx, group, user, common.GroupRoleMember)
+
+	upload := &common.Upload{GroupID: group.ID}
+	file := upload.NewFile()
+	file.Size = 42
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("GET", "/groups/"+group.ID+"/stats", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID})
+
+	rr := ctx.NewRecorder(req)
+	GetGroupStatistics(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	stats := &common.GroupStats{}
+	err = json.Unmarshal(respBody, stats)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, 1, stats.Uploads, "invalid upload count")
+	require.Equal(t, 1, stats.Files, "invalid file count")
+	require.Equal(t, int64(42), stats.TotalSize, "invalid total size")
+	require.Equal(t, 10, stats.MaxUploads, "invalid max uploads")
+}
+
+func TestCreateAndRevokeGroupToken(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := createTestUser(t, ctx, "user")
+	ctx.SetUser(user)
+
+	group := common.NewGroup("team")
+	createTestGroup(t, ctx, group, user, common.GroupRoleManager)
+
+	reqBody, err := json.Marshal(&common.GroupToken{Comment: "ci"})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/groups/"+group.ID+"/tokens", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID})
+
+	rr := ctx.NewRecorder(req)
+	CreateGroupToken(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	token := &common.GroupToken{}
+	err = json.Unmarshal(respBody, token)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.NotZero(t, token.Token, "missing token")
+	require.Equal(t, "ci", token.Comment, "invalid token comment")
+
+	req, err = http.NewRequest("DELETE", "/groups/"+group.ID+"/tokens/"+token.Token, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"groupID": group.ID, "token": token.Token})
+
+	rr = ctx.NewRecorder(req)
+	RevokeGroupToken(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result, err := ctx.GetMetadataBackend().GetGroupToken(token.Token)
+	require.NoError(t, err, "unable to get group token")
+	require.Nil(t, result, "group token has not been deleted")
+}
@@ -50,23 +50,35 @@ func LocalLogin(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 		return
 	}
 
+	userID := common.GetUserID(common.ProviderLocal, loginParams.Login)
+
+	// Protect against brute force attacks
+	authFailureKey := common.UserAuthFailureKey(userID)
+	if !ctx.CheckAuthLockout(authFailureKey) {
+		return
+	}
+
 	// Get user from metadata backend
-	user, err := ctx.GetMetadataBackend().GetUser(common.GetUserID(common.ProviderLocal, loginParams.Login))
+	user, err := ctx.GetMetadataBackend().GetUser(userID)
 	if err != nil {
 		ctx.InternalServerError("unable to get user from metadata backend", err)
 		return
 	}
 
 	if user == nil {
+		ctx.AuthFailed(authFailureKey)
 		ctx.Forbidden("invalid credentials")
 		return
 	}
 
 	if !common.CheckPasswordHash(loginParams.Password, user.Password) {
+		ctx.AuthFailed(authFailureKey)
 		ctx.Forbidden("invalid credentials")
 		return
 	}
 
+	ctx.AuthSucceeded(authFailureKey)
+
 	// Set Plik session cookie and xsrf cookie
 	sessionCookie, xsrfCookie, err := ctx.GetAuthenticator().GenAuthCookies(user)
 	if err != nil {
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"bytes"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/root-gg/utils"
@@ -137,3 +139,56 @@ func TestLocalLoginInvalidPassword(t *testing.T) {
 
 	context.TestForbidden(t, rr, "invalid credentials")
 }
+
+func TestLocalLoginLockout(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Authentication = true
+	ctx.GetConfig().AuthFailureThreshold = 2
+
+	login := func(password string) *httptest.ResponseRecorder {
+		credentials, _ := utils.ToJson(struct{ Login, Password string }{"user", password})
+		req, err := http.NewRequest("POST", "/auth/local/login", bytes.NewBuffer(credentials))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		LocalLogin(ctx, rr, req)
+		return rr
+	}
+
+	context.TestForbidden(t, login("invalid"), "invalid credentials")
+	context.TestForbidden(t, login("invalid"), "invalid credentials")
+
+	rr := login("invalid")
+	context.TestTooManyRequests(t, rr, "too many failed authentication attempts")
+	require.NotEqual(t, "", rr.Header().Get("Retry-After"), "missing Retry-After header")
+
+	// Clear the lockout
+	deleted, err := ctx.GetMetadataBackend().DeleteAuthFailure(common.UserAuthFailureKey(common.GetUserID(common.ProviderLocal, "user")))
+	require.NoError(t, err, "unable to clear lockout")
+	require.True(t, deleted, "lockout not cleared")
+
+	context.TestForbidden(t, login("invalid"), "invalid credentials")
+}
+
+func TestLocalLoginSourceIPLockout(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Authentication = true
+	ctx.GetConfig().AuthFailureThreshold = 0
+	ctx.GetConfig().AuthFailureIPThreshold = 2
+	ctx.SetSourceIP(net.ParseIP("1.2.3.4"))
+
+	for i, login := range []string{"user1", "user2", "user3"} {
+		credentials, _ := utils.ToJson(struct{ Login, Password string }{login, "invalid"})
+		req, err := http.NewRequest("POST", "/auth/local/login", bytes.NewBuffer(credentials))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		LocalLogin(ctx, rr, req)
+
+		if i < 2 {
+			context.TestForbidden(t, rr, "invalid credentials")
+		} else {
+			context.TestTooManyRequests(t, rr, "too many failed authentication attempts")
+		}
+	}
+}
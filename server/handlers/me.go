@@ -73,6 +73,37 @@ func GetUserUploads(ctx *context.Context, resp http.ResponseWriter, req *http.Re
 	common.WriteJSONResponse(resp, pagingResponse)
 }
 
+// GetSharedUploads return the uploads shared with the user through an upload access control list
+func GetSharedUploads(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	config := ctx.GetConfig()
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	pagingQuery := ctx.GetPagingQuery()
+
+	// Get uploads
+	uploads, cursor, err := ctx.GetMetadataBackend().GetSharedUploads(user.GetACLSubjects(), true, pagingQuery)
+	if err != nil {
+		ctx.InternalServerError("unable to get shared uploads", err)
+		return
+	}
+
+	// Remove all private information (ip, data backend details, ...) before
+	// sending metadata back to the client
+	for _, upload := range uploads {
+		upload.Sanitize()
+		upload.DownloadDomain = config.DownloadDomain
+	}
+
+	pagingResponse := common.NewPagingResponse(uploads, cursor)
+	common.WriteJSONResponse(resp, pagingResponse)
+}
+
 // RemoveUserUploads delete all user uploads
 func RemoveUserUploads(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
 	user, token, err := getUserAndToken(ctx, req)
@@ -140,6 +171,58 @@ func DeleteAccount(ctx *context.Context, resp http.ResponseWriter, req *http.Req
 	_, _ = resp.Write([]byte("ok"))
 }
 
+// notificationParams are the email notification preferences, omitted preferences are left unchanged
+type notificationParams struct {
+	NotifyDownloads      *bool `json:"notifyDownloads"`
+	NotifyExpiration     *bool `json:"notifyExpiration"`
+	NotifyUploadRequests *bool `json:"notifyUploadRequests"`
+}
+
+// UpdateUserNotifications update the email notifications the user opted-in to
+func UpdateUserNotifications(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	config := ctx.GetConfig()
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	if !config.EmailNotifications {
+		ctx.BadRequest("email notifications are disabled")
+		return
+	}
+
+	params := &notificationParams{}
+	if !readJSONParams(ctx, resp, req, params) {
+		return
+	}
+
+	if params.NotifyDownloads != nil {
+		user.NotifyDownloads = *params.NotifyDownloads
+	}
+	if params.NotifyExpiration != nil {
+		user.NotifyExpiration = *params.NotifyExpiration
+	}
+	if params.NotifyUploadRequests != nil {
+		user.NotifyUploadRequests = *params.NotifyUploadRequests
+	}
+
+	if user.Email == "" && (user.NotifyDownloads || user.NotifyExpiration || user.NotifyUploadRequests) {
+		ctx.BadRequest("missing user email address")
+		return
+	}
+
+	err := ctx.GetMetadataBackend().UpdateUser(user)
+	if err != nil {
+		ctx.InternalServerError("unable to update user", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, user)
+}
+
 func getUserAndToken(ctx *context.Context, req *http.Request) (user *common.User, token *common.Token, err error) {
 	// Get user from context
 	user = ctx.GetUser()
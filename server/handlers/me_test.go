@@ -90,6 +90,63 @@ func TestDeleteUser(t *testing.T) {
 	require.Equal(t, string(respBody), "ok", "invalid response body")
 }
 
+func TestUpdateUserNotifications(t *testing.T) {
+	config := common.NewConfiguration()
+	config.EmailNotifications = true
+	ctx := newTestingContext(config)
+
+	user := common.NewUser(common.ProviderLocal, "user1")
+	user.Email = "user1@root.gg"
+	user.NotifyExpiration = true
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create test user")
+	ctx.SetUser(user)
+
+	req, err := http.NewRequest("POST", "/me/notifications", bytes.NewBufferString(`{"notifyDownloads":true}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	UpdateUserNotifications(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result := &common.User{}
+	err = json.Unmarshal(rr.Body.Bytes(), result)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.True(t, result.NotifyDownloads, "invalid download notification preference")
+	require.True(t, result.NotifyExpiration, "invalid expiration notification preference")
+	require.False(t, result.NotifyUploadRequests, "invalid upload request notification preference")
+
+	user, err = ctx.GetMetadataBackend().GetUser(user.ID)
+	require.NoError(t, err, "unable to get user")
+	require.True(t, user.NotifyDownloads, "invalid download notification preference")
+}
+
+func TestUpdateUserNotificationsMissingEmail(t *testing.T) {
+	config := common.NewConfiguration()
+	config.EmailNotifications = true
+	ctx := newTestingContext(config)
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user1"))
+
+	req, err := http.NewRequest("POST", "/me/notifications", bytes.NewBufferString(`{"notifyDownloads":true}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	UpdateUserNotifications(ctx, rr, req)
+	context.TestBadRequest(t, rr, "missing user email address")
+}
+
+func TestUpdateUserNotificationsDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user1"))
+
+	req, err := http.NewRequest("POST", "/me/notifications", bytes.NewBufferString(`{"notifyDownloads":true}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	UpdateUserNotifications(ctx, rr, req)
+	context.TestBadRequest(t, rr, "email notifications are disabled")
+}
+
 func TestDeleteUserNoUser(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -486,3 +543,63 @@ func TestGetUserStatisticsNoUser(t *testing.T) {
 
 	context.TestUnauthorized(t, rr, "please login first")
 }
+
+func TestGetSharedUploads(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user1")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create test user")
+
+	ctx.SetUser(user)
+
+	upload1 := &common.Upload{ACL: []string{common.ACLUserSubject(user.ID)}}
+	upload1.User = "owner"
+	upload1.RemoteIP = "1.2.3.4"
+	createTestUpload(t, ctx, upload1)
+
+	upload2 := &common.Upload{ACL: []string{common.ACLUserSubject("local:user2")}}
+	createTestUpload(t, ctx, upload2)
+
+	upload3 := &common.Upload{}
+	upload3.User = user.ID
+	createTestUpload(t, ctx, upload3)
+
+	req, err := http.NewRequest("GET", "/me/shared", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	// Create paging query
+	ctx.SetPagingQuery(&common.PagingQuery{})
+
+	rr := ctx.NewRecorder(req)
+	GetSharedUploads(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var response struct {
+		Results []*common.Upload `json:"results"`
+	}
+	err = json.Unmarshal(respBody, &response)
+	require.NoError(t, err, "unable to unmarshal response body %s", respBody)
+
+	uploads := response.Results
+	require.Len(t, uploads, 1, "invalid upload count")
+	require.Equal(t, upload1.ID, uploads[0].ID, "invalid shared upload")
+	require.Equal(t, "", uploads[0].User, "upload has not been sanitized")
+	require.Equal(t, "", uploads[0].RemoteIP, "upload has not been sanitized")
+	require.Nil(t, uploads[0].ACL, "upload acl should not be visible")
+}
+
+func TestGetSharedUploadsNoUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("GET", "/me/shared", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetSharedUploads(ctx, rr, req)
+
+	context.TestUnauthorized(t, rr, "missing user, please login first")
+}
@@ -121,9 +121,13 @@ func getRedirectURL(ctx *context.Context, callbackPath string) (redirectURL stri
 }
 
 func handleHTTPError(ctx *context.Context, err error) {
-	if httpError, ok := err.(common.HTTPError); ok {
-		ctx.Fail(httpError.Message, httpError.Err, httpError.StatusCode)
-	} else {
+	httpError, ok := err.(common.HTTPError)
+	if !ok {
 		ctx.InternalServerError("unexpected error", err)
+	} else if httpError.StatusCode == http.StatusInternalServerError {
+		// Only return the error details to the user in debug mode
+		ctx.InternalServerError(httpError.Message, httpError.Err)
+	} else {
+		ctx.Fail(httpError.Message, httpError.Err, httpError.StatusCode)
 	}
 }
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/paste"
+)
+
+// pasteFormOverhead is the size allowed for the form fields and encoding on top of the paste size
+const pasteFormOverhead = 64 * 1024
+
+// CreatePaste add a text snippet to the upload from a form or the raw request body
+func CreatePaste(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	config := ctx.GetConfig()
+
+	// Get upload from context
+	upload := ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	// Check authorization
+	if !ctx.IsUploadAdmin() {
+		ctx.Forbidden("you are not allowed to add file to this upload")
+		return
+	}
+
+	// Pastes are limited by PasteMaxSize instead of MaxFileSize
+	maxPasteSize, ok := ctx.GetMaxPasteSize(upload)
+	if !ok {
+		return
+	}
+
+	content, fileName, languageName, ok := readPaste(ctx, resp, req, maxPasteSize)
+	if !ok {
+		return
+	}
+
+	if len(content) == 0 {
+		ctx.MissingParameter("paste content")
+		return
+	}
+
+	if int64(len(content)) > maxPasteSize {
+		ctx.BadRequest("paste too big (limit is set to %d bytes)", maxPasteSize)
+		return
+	}
+
+	if !utf8.Valid(content) {
+		ctx.BadRequest("paste content must be UTF-8 text")
+		return
+	}
+
+	// The language hint defaults to the extension of the file name
+	var language *paste.Language
+	if languageName != "" {
+		language = paste.GetLanguage(languageName)
+		if language == nil {
+			ctx.InvalidParameter("language, supported languages are %s", strings.Join(paste.GetLanguages(), ", "))
+			return
+		}
+	} else {
+		language = paste.GetLanguageFromFileName(fileName)
+	}
+
+	if fileName == "" {
+		fileName = paste.GetFileName(language)
+	}
+
+	file, ok := createFile(ctx, upload, fileName, "")
+	if !ok {
+		return
+	}
+
+	file.Paste = true
+	if language != nil {
+		file.Language = language.Name
+	}
+
+	file.Status = common.FileUploading
+	err := ctx.GetMetadataBackend().UpdateFile(file, common.FileMissing)
+	if err != nil {
+		ctx.InternalServerError("unable to update file status", err)
+		return
+	}
+
+	err = saveFile(ctx, upload, file, bytes.NewReader(content), maxPasteSize)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	// Remove all private information (ip, data backend details, ...) before
+	// sending metadata back to the client
+	file.Sanitize()
+
+	url := getDownloadURL(config, fmt.Sprintf("/paste/%s/%s/%s", upload.ID, file.ID, file.Name))
+	writeQuickResponse(ctx, resp, upload, file, url)
+}
+
+// readPaste read the paste content, file name and language from the form fields
+// or from the raw request body and the query string
+func readPaste(ctx *context.Context, resp http.ResponseWriter, req *http.Request, maxPasteSize int64) (content []byte, fileName string, language string, ok bool) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data", "application/x-www-form-urlencoded":
+		req.Body = http.MaxBytesReader(resp, req.Body, maxPasteSize+pasteFormOverhead)
+
+		var err error
+		if mediaType == "multipart/form-data" {
+			err = req.ParseMultipartForm(maxPasteSize + pasteFormOverhead)
+		} else {
+			err = req.ParseForm()
+		}
+		if err != nil {
+			ctx.InvalidParameter("form : %s", err)
+			return nil, "", "", false
+		}
+		if req.MultipartForm != nil {
+			defer func() { _ = req.MultipartForm.RemoveAll() }()
+		}
+
+		content = []byte(req.PostFormValue("content"))
+		fileName = req.PostFormValue("name")
+		language = req.PostFormValue("language")
+
+		// curl -F content=@file.txt sends the content as a file
+		if len(content) == 0 && req.MultipartForm != nil && len(req.MultipartForm.File["content"]) > 0 {
+			header := req.MultipartForm.File["content"][0]
+			reader, err := header.Open()
+			if err != nil {
+				ctx.InvalidParameter("form : %s", err)
+				return nil, "", "", false
+			}
+			defer func() { _ = reader.Close() }()
+
+			content, err = ioutil.ReadAll(io.LimitReader(reader, maxPasteSize+1))
+			if err != nil {
+				ctx.InvalidParameter("form : %s", err)
+				return nil, "", "", false
+			}
+
+			if fileName == "" {
+				fileName = header.Filename
+			}
+		}
+	default:
+		var err error
+		content, err = ioutil.ReadAll(io.LimitReader(req.Body, maxPasteSize+1))
+		if err != nil {
+			ctx.BadRequest("unable to read request body : %s", err)
+			return nil, "", "", false
+		}
+
+		fileName = req.URL.Query().Get("name")
+		language = req.URL.Query().Get("language")
+	}
+
+	return content, fileName, language, true
+}
+
+// GetPaste display a paste with its syntax highlighted
+func GetPaste(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	log := ctx.GetLogger()
+
+	upload, file, ok := getPaste(ctx, req)
+	if !ok {
+		return
+	}
+
+	// The page is rendered by the server and only the inline stylesheet is allowed whatever the server configuration
+	resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	resp.Header().Set("Content-Security-Policy", paste.ContentSecurityPolicy)
+	setPasteHeaders(resp, upload)
+
+	// HEAD Request => Do not print the paste, user just wants http headers
+	if req.Method != "GET" {
+		return
+	}
+
+	content, ok := readPasteContent(ctx, file)
+	if !ok {
+		return
+	}
+
+	err := paste.Render(resp, file.Name, paste.GetLanguage(file.Language), string(content))
+	if err != nil {
+		log.Warningf("error while rendering paste : %s", err)
+		return
+	}
+
+	ctx.NotifyEvent(common.EventFileDownloaded, upload, file)
+}
+
+// GetPasteRaw display the text of a paste
+func GetPasteRaw(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	log := ctx.GetLogger()
+
+	upload, file, ok := getPaste(ctx, req)
+	if !ok {
+		return
+	}
+
+	resp.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	resp.Header().Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'; sandbox")
+	resp.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, file.Name))
+	resp.Header().Set("Content-Length", strconv.Itoa(int(file.Size)))
+	setPasteHeaders(resp, upload)
+
+	// HEAD Request => Do not print the paste, user just wants http headers
+	if req.Method != "GET" {
+		return
+	}
+
+	reader, err := ctx.GetDataBackend().GetFile(file)
+	if err != nil {
+		ctx.InternalServerError("unable to get file from data backend", err)
+		return
+	}
+	defer func() { _ = reader.Close() }()
+
+	_, err = io.Copy(resp, reader)
+	if err != nil {
+		log.Warningf("error while copying paste to response : %s", err)
+		return
+	}
+
+	ctx.NotifyEvent(common.EventFileDownloaded, upload, file)
+}
+
+// getPaste check that the file is a paste that can be displayed and count the download
+func getPaste(ctx *context.Context, req *http.Request) (upload *common.Upload, file *common.File, ok bool) {
+	if !checkDownloadDomain(ctx) {
+		return nil, nil, false
+	}
+
+	// Get upload from context
+	upload = ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	// Get file from context
+	file = ctx.GetFile()
+	if file == nil {
+		panic("missing file from context")
+	}
+
+	if !file.Paste {
+		ctx.NotFound("file %s (%s) is not a paste", file.Name, file.ID)
+		return nil, nil, false
+	}
+
+	if file.Status != common.FileUploaded {
+		ctx.NotFound("file %s (%s) is not available : %s", file.Name, file.ID, file.Status)
+		return nil, nil, false
+	}
+
+	// Pastes are read in memory to be highlighted
+	if file.Size > ctx.GetConfig().PasteMaxSize {
+		ctx.BadRequest("paste too big (limit is set to %d bytes)", ctx.GetConfig().PasteMaxSize)
+		return nil, nil, false
+	}
+
+	// One shot pastes are removed once displayed, either highlighted or raw
+	if req.Method == "GET" && !countDownload(ctx, upload, file) {
+		return nil, nil, false
+	}
+
+	return upload, file, true
+}
+
+// readPasteContent read the whole paste from the data backend, up to PasteMaxSize bytes
+func readPasteContent(ctx *context.Context, file *common.File) (content []byte, ok bool) {
+	maxPasteSize := ctx.GetConfig().PasteMaxSize
+
+	reader, err := ctx.GetDataBackend().GetFile(file)
+	if err != nil {
+		ctx.InternalServerError("unable to get file from data backend", err)
+		return nil, false
+	}
+	defer func() { _ = reader.Close() }()
+
+	content, err = ioutil.ReadAll(io.LimitReader(reader, maxPasteSize+1))
+	if err != nil {
+		ctx.InternalServerError("unable to read file from data backend", err)
+		return nil, false
+	}
+
+	if int64(len(content)) > maxPasteSize {
+		ctx.BadRequest("paste too big (limit is set to %d bytes)", maxPasteSize)
+		return nil, false
+	}
+
+	return content, true
+}
+
+// setPasteHeaders set the security and cache headers of the paste views
+func setPasteHeaders(resp http.ResponseWriter, upload *common.Upload) {
+	resp.Header().Set("X-Content-Type-Options", "nosniff")
+	resp.Header().Set("X-Frame-Options", "DENY")
+	resp.Header().Set("Referrer-Policy", "no-referrer")
+
+	if upload.IsDownloadLimited() {
+		resp.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate") // HTTP 1.1
+		resp.Header().Set("Pragma", "no-cache")                                   // HTTP 1.0
+		resp.Header().Set("Expires", "0")                                         // Proxies
+	}
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/paste"
+)
+
+func newPasteTestingContext(t *testing.T) (ctx *context.Context) {
+	ctx = newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+	ctx.SetQuick(true)
+	createTestUpload(t, ctx, &common.Upload{})
+	return ctx
+}
+
+func createTestPaste(t *testing.T, ctx *context.Context, upload *common.Upload, content string, language string) (file *common.File) {
+	file = upload.NewFile()
+	file.Name = "paste.txt"
+	file.Status = common.FileUploaded
+	file.Paste = true
+	file.Language = language
+	file.Size = int64(len(content))
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBufferString(content))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	return file
+}
+
+func getUploadFile(t *testing.T, ctx *context.Context) *common.File {
+	files, err := ctx.GetMetadataBackend().GetFiles(ctx.GetUpload().ID)
+	require.NoError(t, err, "unable to get upload files")
+	require.Len(t, files, 1, "invalid upload files")
+	return files[0]
+}
+
+func TestCreatePaste(t *testing.T) {
+	ctx := newPasteTestingContext(t)
+
+	req, err := http.NewRequest("POST", "/paste?language=golang", bytes.NewBufferString("package main\n"))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreatePaste(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	file := getUploadFile(t, ctx)
+	require.True(t, file.Paste, "file should be a paste")
+	require.Equal(t, "go", file.Language, "invalid language")
+	require.Equal(t, "paste.go", file.Name, "invalid file name")
+	require.Equal(t, common.FileUploaded, file.Status, "invalid file status")
+	require.Equal(t, int64(13), file.Size, "invalid file size")
+
+	url := fmt.Sprintf("http://127.0.0.1:8080/paste/%s/%s/paste.go\n", file.UploadID, file.ID)
+	require.Equal(t, url, rr.Body.String(), "invalid paste url")
+}
+
+func TestCreatePasteForm(t *testing.T) {
+	ctx := newPasteTestingContext(t)
+
+	form := url.Values{}
+	form.Set("content", "key: value")
+	form.Set("name", "config.yml")
+
+	req, err := http.NewRequest("POST", "/paste", strings.NewReader(form.Encode()))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := ctx.NewRecorder(req)
+	CreatePaste(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	file := getUploadFile(t, ctx)
+	require.Equal(t, "config.yml", file.Name, "invalid file name")
+	require.Equal(t, "yaml", file.Language, "language should be detected from the file name")
+}
+
+func TestCreatePasteMultipartFile(t *testing.T) {
+	ctx := newPasteTestingContext(t)
+
+	buffer := &bytes.Buffer{}
+	multipartWriter := multipart.NewWriter(buffer)
+	writer, err := multipartWriter.CreateFormFile("content", "server.log")
+	require.NoError(t, err, "unable to create multipart form file")
+	_, err = writer.Write([]byte("error: something went wrong"))
+	require.NoError(t, err, "unable to write multipart form file")
+	err = multipartWriter.WriteField("language", "text")
+	require.NoError(t, err, "unable to write multipart form field")
+	require.NoError(t, multipartWriter.Close(), "unable to close multipart writer")
+
+	req, err := http.NewRequest("POST", "/paste", buffer)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", multipartWriter.FormDataContentType())
+
+	rr := ctx.NewRecorder(req)
+	CreatePaste(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	file := getUploadFile(t, ctx)
+	require.Equal(t, "server.log", file.Name, "invalid file name")
+	require.Equal(t, "text", file.Language, "invalid language")
+	require.Equal(t, int64(27), file.Size, "invalid file size")
+}
+
+func TestCreatePasteMaxSize(t *testing.T) {
+	ctx := newPasteTestingContext(t)
+	ctx.GetConfig().MaxFileSize = 1
+	ctx.GetConfig().PasteMaxSize = 10
+
+	// The paste size limit is independent from the file size limit
+	req, err := http.NewRequest("POST", "/paste", bytes.NewBufferString("0123456789"))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreatePaste(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	req, err = http.NewRequest("POST", "/paste", bytes.NewBufferString("0123456789A"))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	CreatePaste(ctx, rr, req)
+	context.TestBadRequest(t, rr, "paste too big (limit is set to 10 bytes)")
+}
+
+func TestCreatePasteInvalid(t *testing.T) {
+	ctx := newPasteTestingContext(t)
+
+	req, err := http.NewRequest("POST", "/paste", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	rr := ctx.NewRecorder(req)
+	CreatePaste(ctx, rr, req)
+	context.TestMissingParameter(t, rr, "paste content")
+
+	req, err = http.NewRequest("POST", "/paste", bytes.NewBuffer([]byte{0xff, 0xfe, 0xfd}))
+	require.NoError(t, err, "unable to create new request")
+	rr = ctx.NewRecorder(req)
+	CreatePaste(ctx, rr, req)
+	context.TestBadRequest(t, rr, "paste content must be UTF-8 text")
+
+	req, err = http.NewRequest("POST", "/paste?language=brainfuck", bytes.NewBufferString("+++"))
+	require.NoError(t, err, "unable to create new request")
+	rr = ctx.NewRecorder(req)
+	CreatePaste(ctx, rr, req)
+	context.TestInvalidParameter(t, rr, "language")
+}
+
+func TestCreatePasteNotAdmin(t *testing.T) {
+	ctx := newPasteTestingContext(t)
+	ctx.SetUploadAdmin(false)
+
+	req, err := http.NewRequest("POST", "/paste", bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreatePaste(ctx, rr, req)
+	context.TestForbidden(t, rr, "you are not allowed to add file to this upload")
+}
+
+func TestGetPaste(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := &common.Upload{}
+	createTestPaste(t, ctx, upload, "func main() {}", "go")
+
+	req, err := http.NewRequest("GET", "/paste/"+upload.ID+"/"+ctx.GetFile().ID+"/paste.txt", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetPaste(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"), "invalid content type")
+	require.Equal(t, paste.ContentSecurityPolicy, rr.Header().Get("Content-Security-Policy"), "invalid content security policy")
+	require.Contains(t, rr.Body.String(), `<span class="k">func</span> main() {}`, "invalid highlighted code")
+}
+
+func TestGetPasteRaw(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := &common.Upload{}
+	createTestPaste(t, ctx, upload, "<script>alert(1)</script>", "xml")
+
+	req, err := http.NewRequest("GET", "/paste/"+upload.ID+"/"+ctx.GetFile().ID+"/paste.txt/raw", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetPasteRaw(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.Equal(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"), "invalid content type")
+	require.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"), "invalid content type options")
+	require.Equal(t, "<script>alert(1)</script>", rr.Body.String(), "invalid paste content")
+}
+
+func TestGetPasteOneShot(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := &common.Upload{OneShot: true}
+	file := createTestPaste(t, ctx, upload, "secret", "")
+
+	req, err := http.NewRequest("GET", "/paste/"+upload.ID+"/"+file.ID+"/paste.txt", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetPaste(ctx, rr, req)
+	context.TestOK(t, rr)
+	require.Equal(t, "no-cache, no-store, must-revalidate", rr.Header().Get("Cache-Control"), "invalid cache control")
+
+	req, err = http.NewRequest("GET", "/paste/"+upload.ID+"/"+file.ID+"/paste.txt/raw", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	GetPasteRaw(ctx, rr, req)
+	context.TestNotFound(t, rr, fmt.Sprintf("file %s (%s) is not available : removed", file.Name, file.ID))
+}
+
+func TestGetPasteNotPaste(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := &common.Upload{}
+	file := createTestPaste(t, ctx, upload, "data", "")
+	file.Paste = false
+
+	req, err := http.NewRequest("GET", "/paste/"+upload.ID+"/"+file.ID+"/paste.txt", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetPaste(ctx, rr, req)
+	context.TestNotFound(t, rr, fmt.Sprintf("file %s (%s) is not a paste", file.Name, file.ID))
+}
+
+func TestGetPasteTooBig(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().PasteMaxSize = 10
+	upload := &common.Upload{OneShot: true}
+	file := createTestPaste(t, ctx, upload, "this paste is too big", "")
+
+	req, err := http.NewRequest("GET", "/paste/"+upload.ID+"/"+file.ID+"/paste.txt", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetPaste(ctx, rr, req)
+	context.TestBadRequest(t, rr, "paste too big (limit is set to 10 bytes)")
+
+	// The rejected paste is not counted as a download
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+}
+
+func TestReadPasteContentTooBig(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().PasteMaxSize = 10
+	upload := &common.Upload{}
+	file := createTestPaste(t, ctx, upload, "this paste is too big", "")
+
+	req, err := http.NewRequest("GET", "/paste/"+upload.ID+"/"+file.ID+"/paste.txt", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	_, ok := readPasteContent(ctx, file)
+	require.False(t, ok, "the paste should not be read")
+	context.TestBadRequest(t, rr, "paste too big (limit is set to 10 bytes)")
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/metadata"
+	"github.com/root-gg/plik/server/preview"
+)
+
+// generatePreview generate the preview of an uploaded file and save its kind in the metadata backend
+// The preview is removed if the file has been removed in the meantime
+func generatePreview(metadataBackend *metadata.Backend, dataBackend data.Backend, file *common.File, maxFileSize int64) (err error) {
+	kind, err := preview.Generate(dataBackend, file, maxFileSize)
+	if err != nil {
+		return err
+	}
+
+	err = metadataBackend.UpdateFilePreview(file, kind)
+	if err != nil {
+		if kind != common.PreviewNone {
+			_ = preview.Remove(dataBackend, file)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetFilePreview download the thumbnail or the text snippet of a file
+func GetFilePreview(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	log := ctx.GetLogger()
+	config := ctx.GetConfig()
+
+	if !checkDownloadDomain(ctx) {
+		return
+	}
+
+	if !config.Previews {
+		ctx.BadRequest("file previews are disabled")
+		return
+	}
+
+	// Get upload from context
+	upload := ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	// Get file from context
+	file := ctx.GetFile()
+	if file == nil {
+		panic("missing file from context")
+	}
+
+	// Previews would disclose the content of one shot, download capped and stream files
+	if upload.Stream || upload.IsDownloadLimited() {
+		ctx.BadRequest("file previews are not available for one shot or stream uploads")
+		return
+	}
+
+	if file.Status != common.FileUploaded {
+		ctx.NotFound("file %s (%s) is not available : %s", file.Name, file.ID, file.Status)
+		return
+	}
+
+	// Files uploaded before previews were available are processed on demand
+	if file.Preview == "" {
+		err := generatePreview(ctx.GetMetadataBackend(), ctx.GetDataBackend(), file, config.PreviewMaxFileSize)
+		if err != nil {
+			ctx.InternalServerError("unable to generate file preview", err)
+			return
+		}
+	}
+
+	if !file.HasPreview() {
+		ctx.NotFound("no preview available for file %s", file.Name)
+		return
+	}
+
+	// Previews are never rendered as active content whatever the server configuration
+	resp.Header().Set("Content-Type", preview.GetContentType(file.Preview))
+	resp.Header().Set("X-Content-Type-Options", "nosniff")
+	resp.Header().Set("X-Frame-Options", "DENY")
+	resp.Header().Set("Content-Security-Policy", "default-src 'none'; img-src 'self'; style-src 'unsafe-inline'; form-action 'none'; frame-ancestors 'none'; sandbox")
+	resp.Header().Set("Cache-Control", "private, max-age=3600")
+
+	// HEAD Request => Do not print the preview, user just wants http headers
+	if req.Method != "GET" {
+		return
+	}
+
+	reader, err := preview.Get(ctx.GetDataBackend(), file)
+	if err != nil {
+		ctx.InternalServerError("unable to get file preview from data backend", err)
+		return
+	}
+	defer func() { _ = reader.Close() }()
+
+	_, err = io.Copy(resp, reader)
+	if err != nil {
+		log.Warningf("error while copying file preview to response : %s", err)
+		return
+	}
+}
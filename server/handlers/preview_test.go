@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/preview"
+)
+
+func createTestPreviewFile(t *testing.T, ctx *context.Context, mimeType string, content []byte) (upload *common.Upload, file *common.File) {
+	upload = &common.Upload{}
+	file = upload.NewFile()
+	file.Name = "file"
+	file.Type = mimeType
+	file.Status = common.FileUploaded
+	file.Size = int64(len(content))
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer(content))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	return upload, file
+}
+
+func getFilePreview(t *testing.T, ctx *context.Context, method string, upload *common.Upload, file *common.File) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"/preview", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetFilePreview(ctx, rr, req)
+	return rr
+}
+
+func TestGetFilePreviewImage(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	buf := bytes.NewBuffer(nil)
+	err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 1024, 1024)))
+	require.NoError(t, err, "unable to encode image")
+
+	upload, file := createTestPreviewFile(t, ctx, "image/png", buf.Bytes())
+
+	rr := getFilePreview(t, ctx, "GET", upload, file)
+	context.TestOK(t, rr)
+
+	require.Equal(t, "image/png", rr.Header().Get("Content-Type"), "invalid content type")
+	require.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"), "missing nosniff header")
+	require.Contains(t, rr.Header().Get("Content-Security-Policy"), "sandbox", "missing content security policy")
+
+	img, err := png.Decode(rr.Body)
+	require.NoError(t, err, "unable to decode preview")
+	require.Equal(t, image.Rect(0, 0, preview.ThumbnailSize, preview.ThumbnailSize), img.Bounds(), "invalid preview size")
+
+	result, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.PreviewImage, result.Preview, "invalid file preview")
+}
+
+func TestGetFilePreviewText(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := createTestPreviewFile(t, ctx, "text/html; charset=utf-8", []byte("<script>alert(1)</script>"))
+
+	rr := getFilePreview(t, ctx, "GET", upload, file)
+	context.TestOK(t, rr)
+
+	require.Equal(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"), "invalid content type")
+
+	content, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+	require.Equal(t, "<script>alert(1)</script>", string(content), "invalid preview")
+}
+
+func TestGetFilePreviewHead(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := createTestPreviewFile(t, ctx, "text/plain", []byte("data"))
+
+	rr := getFilePreview(t, ctx, "HEAD", upload, file)
+	context.TestOK(t, rr)
+	require.Equal(t, 0, rr.Body.Len(), "unexpected response body")
+}
+
+func TestGetFilePreviewNotAvailable(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := createTestPreviewFile(t, ctx, "application/octet-stream", []byte("data"))
+
+	rr := getFilePreview(t, ctx, "GET", upload, file)
+	context.TestNotFound(t, rr, "no preview available for file file")
+
+	result, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.PreviewNone, result.Preview, "invalid file preview")
+}
+
+func TestGetFilePreviewDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Previews = false
+	upload, file := createTestPreviewFile(t, ctx, "text/plain", []byte("data"))
+
+	rr := getFilePreview(t, ctx, "GET", upload, file)
+	context.TestBadRequest(t, rr, "file previews are disabled")
+}
+
+func TestGetFilePreviewOneShot(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := createTestPreviewFile(t, ctx, "text/plain", []byte("data"))
+	upload.OneShot = true
+
+	rr := getFilePreview(t, ctx, "GET", upload, file)
+	context.TestBadRequest(t, rr, "file previews are not available for one shot or stream uploads")
+}
+
+func TestGetFilePreviewFileNotUploaded(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := createTestPreviewFile(t, ctx, "text/plain", []byte("data"))
+	file.Status = common.FileRemoved
+
+	rr := getFilePreview(t, ctx, "GET", upload, file)
+	context.TestNotFound(t, rr, "is not available")
+}
+
+func TestGenerateFilePreviewRemovedFile(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	_, file := createTestPreviewFile(t, ctx, "text/plain", []byte("data"))
+
+	err := ctx.GetMetadataBackend().RemoveFile(file)
+	require.NoError(t, err, "unable to remove file")
+
+	err = generatePreview(ctx.GetMetadataBackend(), ctx.GetDataBackend(), file, 1<<20)
+	require.Error(t, err, "missing error")
+
+	_, err = preview.Get(ctx.GetDataBackend(), file)
+	require.Error(t, err, "orphan preview not removed")
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// quickResponse is the JSON output of a quick mode upload
+type quickResponse struct {
+	UploadID    string       `json:"uploadId"`
+	UploadToken string       `json:"uploadToken"`
+	URL         string       `json:"url"`
+	AdminURL    string       `json:"adminUrl"`
+	File        *common.File `json:"file"`
+}
+
+// getDownloadURL return the URL of a path on the download domain
+func getDownloadURL(config *common.Configuration, path string) string {
+	if config.GetDownloadDomain() != nil {
+		return config.GetDownloadDomain().String() + path
+	}
+	return config.GetServerURL().String() + path
+}
+
+// writeQuickResponse print the file URL and the upload admin URL in the requested format
+func writeQuickResponse(ctx *context.Context, resp http.ResponseWriter, upload *common.Upload, file *common.File, url string) {
+	config := ctx.GetConfig()
+
+	// The upload token lets the uploader add or remove files and delete the upload from the web interface
+	adminURL := fmt.Sprintf("%s/#/?id=%s&uploadToken=%s", config.GetPublicURL(), upload.ID, upload.UploadToken)
+	resp.Header().Set("X-Plik-Admin-URL", adminURL)
+
+	switch ctx.GetQuickOutput() {
+	case common.QuickOutputJSON:
+		common.WriteJSONResponse(resp, &quickResponse{
+			UploadID:    upload.ID,
+			UploadToken: upload.UploadToken,
+			URL:         url,
+			AdminURL:    adminURL,
+			File:        file,
+		})
+	case common.QuickOutputShell:
+		resp.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		variables := []string{
+			"PLIK_UPLOAD_ID=" + shellQuote(upload.ID),
+			"PLIK_UPLOAD_TOKEN=" + shellQuote(upload.UploadToken),
+			"PLIK_FILE_ID=" + shellQuote(file.ID),
+			"PLIK_URL=" + shellQuote(url),
+			"PLIK_ADMIN_URL=" + shellQuote(adminURL),
+		}
+		_, _ = resp.Write([]byte(strings.Join(variables, "\n") + "\n"))
+	default:
+		_, _ = resp.Write([]byte(url + "\n"))
+	}
+}
+
+// shellQuote single quote a value so it can be safely evaluated by a POSIX shell
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 )
 
@@ -27,5 +28,7 @@ func RemoveUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	ctx.NotifyEvent(common.EventUploadRemoved, upload, nil)
+
 	_, _ = resp.Write([]byte("ok"))
 }
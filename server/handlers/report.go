@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// moderationParams are the parameters of a moderation action
+type moderationParams struct {
+	Action string `json:"action"`
+	FileID string `json:"fileId"`
+}
+
+// CreateReport report an upload or a file to the administrators
+func CreateReport(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	params := &common.Report{}
+	if !readJSONParams(ctx, resp, req, params) {
+		return
+	}
+
+	// Only keep the fields a reporter can set
+	report := &common.Report{
+		UploadID: params.UploadID,
+		FileID:   params.FileID,
+		Reason:   params.Reason,
+		Message:  params.Message,
+		Email:    params.Email,
+	}
+
+	if report.UploadID == "" {
+		ctx.MissingParameter("upload id")
+		return
+	}
+
+	// Only existing uploads and files can be reported
+	upload, err := ctx.GetMetadataBackend().GetUpload(report.UploadID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload", err)
+		return
+	}
+	if upload == nil || upload.IsExpired() {
+		ctx.NotFound("upload %s not found", report.UploadID)
+		return
+	}
+
+	if report.FileID != "" {
+		file, err := ctx.GetMetadataBackend().GetFile(report.FileID)
+		if err != nil {
+			ctx.InternalServerError("unable to get file", err)
+			return
+		}
+		if file == nil || file.UploadID != upload.ID {
+			ctx.NotFound("file %s not found", report.FileID)
+			return
+		}
+	}
+
+	err = report.PrepareInsert()
+	if err != nil {
+		ctx.BadRequest(err.Error())
+		return
+	}
+
+	if sourceIP := ctx.GetSourceIP(); sourceIP != nil {
+		report.RemoteIP = sourceIP.String()
+	}
+	if user := ctx.GetUser(); user != nil {
+		report.User = user.ID
+	}
+
+	err = ctx.GetMetadataBackend().CreateReport(report)
+	if err != nil {
+		ctx.InternalServerError("unable to create report", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, report)
+}
+
+// GetReports return the moderation queue
+func GetReports(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	status := req.URL.Query().Get("status")
+	switch status {
+	case "", common.ReportOpen, common.ReportResolved, common.ReportDismissed:
+	default:
+		ctx.InvalidParameter("status")
+		return
+	}
+
+	reports, cursor, err := ctx.GetMetadataBackend().GetReports(status, ctx.GetPagingQuery())
+	if err != nil {
+		ctx.InternalServerError("unable to get reports", err)
+		return
+	}
+
+	pagingResponse := common.NewPagingResponse(reports, cursor)
+	common.WriteJSONResponse(resp, pagingResponse)
+}
+
+// ModerateReport apply a moderation action to the reported upload or file
+func ModerateReport(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	reportID := mux.Vars(req)["reportID"]
+	if reportID == "" {
+		ctx.MissingParameter("report id")
+		return
+	}
+
+	report, err := ctx.GetMetadataBackend().GetReport(reportID)
+	if err != nil {
+		ctx.InternalServerError("unable to get report", err)
+		return
+	}
+	if report == nil {
+		ctx.NotFound("report %s not found", reportID)
+		return
+	}
+
+	params := &moderationParams{}
+	if !readJSONParams(ctx, resp, req, params) {
+		return
+	}
+
+	if !common.IsValidModerationAction(params.Action) {
+		ctx.InvalidParameter("action")
+		return
+	}
+
+	// Dismissing a report does not affect the reported content
+	if params.Action == common.ModerationDismiss {
+		report.Status = common.ReportDismissed
+		report.Action = params.Action
+		err = ctx.GetMetadataBackend().UpdateReport(report)
+		if err != nil {
+			ctx.InternalServerError("unable to update report", err)
+			return
+		}
+
+		common.WriteJSONResponse(resp, report)
+		return
+	}
+
+	upload, err := ctx.GetMetadataBackend().GetUpload(report.UploadID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload", err)
+		return
+	}
+	if upload == nil {
+		ctx.NotFound("upload %s not found", report.UploadID)
+		return
+	}
+
+	err = moderate(ctx, upload, report.FileID, params.Action, report.ID)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	report, err = ctx.GetMetadataBackend().GetReport(reportID)
+	if err != nil {
+		ctx.InternalServerError("unable to get report", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, report)
+}
+
+// ModerateUpload apply a moderation action to an upload or to one of its files
+func ModerateUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	upload, err := getAdminUpload(ctx, req)
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	params := &moderationParams{}
+	if !readJSONParams(ctx, resp, req, params) {
+		return
+	}
+
+	if !common.IsValidModerationAction(params.Action) || params.Action == common.ModerationDismiss {
+		ctx.InvalidParameter("action")
+		return
+	}
+
+	err = moderate(ctx, upload, params.FileID, params.Action, "")
+	if err != nil {
+		handleHTTPError(ctx, err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// GetBlockedHashes return the file hash blocklist
+func GetBlockedHashes(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	hashes, cursor, err := ctx.GetMetadataBackend().GetBlockedHashes(ctx.GetPagingQuery())
+	if err != nil {
+		ctx.InternalServerError("unable to get blocked hashes", err)
+		return
+	}
+
+	pagingResponse := common.NewPagingResponse(hashes, cursor)
+	common.WriteJSONResponse(resp, pagingResponse)
+}
+
+// AddBlockedHash add a file hash to the blocklist
+func AddBlockedHash(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	hash := &common.BlockedHash{}
+	if !readJSONParams(ctx, resp, req, hash) {
+		return
+	}
+
+	if hash.Hash == "" {
+		ctx.MissingParameter("hash")
+		return
+	}
+
+	err := ctx.GetMetadataBackend().AddBlockedHash(hash)
+	if err != nil {
+		ctx.InternalServerError("unable to add blocked hash", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, hash)
+}
+
+// RemoveBlockedHash remove a file hash from the blocklist
+func RemoveBlockedHash(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	hash := mux.Vars(req)["hash"]
+	if hash == "" {
+		ctx.MissingParameter("hash")
+		return
+	}
+
+	deleted, err := ctx.GetMetadataBackend().DeleteBlockedHash(hash)
+	if err != nil {
+		ctx.InternalServerError("unable to remove blocked hash", err)
+		return
+	}
+	if !deleted {
+		ctx.NotFound("hash %s not found", hash)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// moderate apply a moderation action to an upload or to one of its files and update the matching reports
+//   - freeze : the upload is only available to administrators until it is restored or taken down
+//   - restore : lift a freeze and dismiss the reports
+//   - takedown : remove the upload or the file and add the file hashes to the blocklist
+func moderate(ctx *context.Context, upload *common.Upload, fileID string, action string, reportID string) (err error) {
+	backend := ctx.GetMetadataBackend()
+
+	switch action {
+	case common.ModerationFreeze, common.ModerationRestore:
+		upload.Frozen = action == common.ModerationFreeze
+		err = backend.UpdateUpload(upload)
+		if err != nil {
+			return common.NewHTTPError("unable to update upload", err, http.StatusInternalServerError)
+		}
+
+		// Frozen content is pending review so the reports stay open
+		status := common.ReportOpen
+		if action == common.ModerationRestore {
+			status = common.ReportDismissed
+		}
+		err = backend.ResolveReports(upload.ID, "", status, action)
+		if err != nil {
+			return common.NewHTTPError("unable to update reports", err, http.StatusInternalServerError)
+		}
+	case common.ModerationTakedown:
+		var files []*common.File
+		if fileID != "" {
+			file, err := backend.GetFile(fileID)
+			if err != nil {
+				return common.NewHTTPError("unable to get file", err, http.StatusInternalServerError)
+			}
+			if file == nil || file.UploadID != upload.ID {
+				return common.NewHTTPError(fmt.Sprintf("file %s not found", fileID), nil, http.StatusNotFound)
+			}
+			files = append(files, file)
+		} else {
+			files, err = backend.GetFiles(upload.ID)
+			if err != nil {
+				return common.NewHTTPError("unable to get upload files", err, http.StatusInternalServerError)
+			}
+		}
+
+		// Previous versions of the files can still be downloaded
+		versions, err := backend.GetUploadFileVersions(upload.ID)
+		if err != nil {
+			return common.NewHTTPError("unable to get file versions", err, http.StatusInternalServerError)
+		}
+
+		// Prevent the same content from being uploaded again
+		for _, file := range files {
+			if file.Md5 != "" {
+				hash := &common.BlockedHash{Hash: file.Md5, ReportID: reportID, Comment: fmt.Sprintf("takedown of %s/%s %s", upload.ID, file.ID, file.Name)}
+				err = backend.AddBlockedHash(hash)
+				if err != nil {
+					return common.NewHTTPError("unable to add blocked hash", err, http.StatusInternalServerError)
+				}
+			}
+
+			for _, version := range versions {
+				if version.FileID != file.ID {
+					continue
+				}
+
+				if version.Md5 != "" {
+					hash := &common.BlockedHash{Hash: version.Md5, ReportID: reportID, Comment: fmt.Sprintf("takedown of %s/%s %s version %d", upload.ID, file.ID, file.Name, version.Version)}
+					err = backend.AddBlockedHash(hash)
+					if err != nil {
+						return common.NewHTTPError("unable to add blocked hash", err, http.StatusInternalServerError)
+					}
+				}
+
+				// Whatever the status of the current version is
+				err = backend.UpdateFileVersionStatus(version, common.FileUploaded, common.FileRemoved)
+				if err != nil {
+					return common.NewHTTPError("unable to remove file version", err, http.StatusInternalServerError)
+				}
+			}
+		}
+
+		// Files are then deleted from the data backend by the cleaning routine
+		if fileID != "" {
+			err = backend.RemoveFile(files[0])
+			if err != nil {
+				return common.NewHTTPError("unable to remove file", err, http.StatusInternalServerError)
+			}
+		} else {
+			err = backend.DeleteUpload(upload.ID)
+			if err != nil {
+				return common.NewHTTPError("unable to delete upload", err, http.StatusInternalServerError)
+			}
+		}
+
+		err = backend.ResolveReports(upload.ID, fileID, common.ReportResolved, action)
+		if err != nil {
+			return common.NewHTTPError("unable to update reports", err, http.StatusInternalServerError)
+		}
+	default:
+		return common.NewHTTPError("invalid action", nil, http.StatusBadRequest)
+	}
+
+	return nil
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func createTestReport(t *testing.T, ctx *context.Context, report *common.Report) {
+	err := report.PrepareInsert()
+	require.NoError(t, err, "invalid report")
+	err = ctx.GetMetadataBackend().CreateReport(report)
+	require.NoError(t, err, "unable to create report")
+}
+
+func TestCreateReport(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetSourceIP(net.ParseIP("1.2.3.4"))
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	createTestUpload(t, ctx, upload)
+
+	params := &common.Report{UploadID: upload.ID, FileID: file.ID, Reason: "malware", Message: "virus", Status: common.ReportDismissed}
+	reqBody, err := json.Marshal(params)
+	require.NoError(t, err, "unable to marshal json body")
+
+	req, err := http.NewRequest("POST", "/report", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateReport(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var report *common.Report
+	err = json.Unmarshal(respBody, &report)
+	require.NoError(t, err, "unable to unmarshal response body %s", respBody)
+	require.NotEmpty(t, report.ID, "missing report id")
+	require.Equal(t, common.ReportOpen, report.Status, "invalid report status")
+	require.Equal(t, "1.2.3.4", report.RemoteIP, "invalid report ip")
+
+	result, err := ctx.GetMetadataBackend().GetReport(report.ID)
+	require.NoError(t, err, "unable to get report")
+	require.NotNil(t, result, "missing report")
+	require.Equal(t, file.ID, result.FileID, "invalid report file")
+}
+
+func TestCreateReportInvalid(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	test := func(params *common.Report, check func(rr *httptest.ResponseRecorder)) {
+		reqBody, err := json.Marshal(params)
+		require.NoError(t, err, "unable to marshal json body")
+
+		req, err := http.NewRequest("POST", "/report", bytes.NewBuffer(reqBody))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		CreateReport(ctx, rr, req)
+		check(rr)
+	}
+
+	test(&common.Report{Reason: "malware"}, func(rr *httptest.ResponseRecorder) { context.TestMissingParameter(t, rr, "upload id") })
+	test(&common.Report{UploadID: "missing", Reason: "malware"}, func(rr *httptest.ResponseRecorder) { context.TestNotFound(t, rr, "upload missing not found") })
+	test(&common.Report{UploadID: upload.ID, FileID: "missing", Reason: "malware"}, func(rr *httptest.ResponseRecorder) { context.TestNotFound(t, rr, "file missing not found") })
+	test(&common.Report{UploadID: upload.ID, Reason: "foo"}, func(rr *httptest.ResponseRecorder) { context.TestBadRequest(t, rr, "invalid report reason") })
+}
+
+func TestGetReports(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	createTestReport(t, ctx, &common.Report{UploadID: "upload", Reason: "spam"})
+	createTestReport(t, ctx, &common.Report{UploadID: "upload", Reason: "spam"})
+
+	req, err := http.NewRequest("GET", "/reports?status=open", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	ctx.SetPagingQuery(&common.PagingQuery{})
+	rr := ctx.NewRecorder(req)
+	GetReports(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var response common.PagingResponse
+	err = json.Unmarshal(respBody, &response)
+	require.NoError(t, err, "unable to unmarshal response body %s", respBody)
+	require.Equal(t, 2, len(response.Results), "invalid report count")
+
+	req, err = http.NewRequest("GET", "/reports?status=foo", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	GetReports(ctx, rr, req)
+	context.TestInvalidParameter(t, rr, "status")
+}
+
+func TestGetReportsNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("GET", "/reports", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetReports(ctx, rr, req)
+	context.TestForbidden(t, rr, "you need administrator privileges")
+}
+
+func moderateReport(t *testing.T, ctx *context.Context, report *common.Report, action string) *httptest.ResponseRecorder {
+	reqBody, err := json.Marshal(&moderationParams{Action: action})
+	require.NoError(t, err, "unable to marshal json body")
+
+	req, err := http.NewRequest("POST", "/reports/"+report.ID, bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"reportID": report.ID})
+
+	rr := ctx.NewRecorder(req)
+	ModerateReport(ctx, rr, req)
+	return rr
+}
+
+func TestModerateReportFreezeRestore(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	report := &common.Report{UploadID: upload.ID, Reason: "phishing"}
+	createTestReport(t, ctx, report)
+
+	rr := moderateReport(t, ctx, report, common.ModerationFreeze)
+	context.TestOK(t, rr)
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.True(t, u.Frozen, "upload should be frozen")
+
+	r, err := ctx.GetMetadataBackend().GetReport(report.ID)
+	require.NoError(t, err, "unable to get report")
+	require.Equal(t, common.ReportOpen, r.Status, "invalid report status")
+	require.Equal(t, common.ModerationFreeze, r.Action, "invalid report action")
+
+	rr = moderateReport(t, ctx, report, common.ModerationRestore)
+	context.TestOK(t, rr)
+
+	u, err = ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.False(t, u.Frozen, "upload should not be frozen")
+
+	r, err = ctx.GetMetadataBackend().GetReport(report.ID)
+	require.NoError(t, err, "unable to get report")
+	require.Equal(t, common.ReportDismissed, r.Status, "invalid report status")
+}
+
+func TestModerateReportTakedown(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	file.Md5 = contentMD5
+	other := upload.NewFile()
+	other.Status = common.FileUploaded
+	other.Md5 = "other"
+	createTestUpload(t, ctx, upload)
+
+	// Previous versions must be taken down along with the file
+	version := &common.FileVersion{ID: common.GenerateRandomID(16), FileID: file.ID, UploadID: upload.ID, Version: 0, Status: common.FileUploaded, Md5: "previous"}
+	err := ctx.GetMetadataBackend().CreateFileVersion(version)
+	require.NoError(t, err, "unable to create file version")
+
+	otherVersion := &common.FileVersion{ID: common.GenerateRandomID(16), FileID: other.ID, UploadID: upload.ID, Version: 0, Status: common.FileUploaded, Md5: "other previous"}
+	err = ctx.GetMetadataBackend().CreateFileVersion(otherVersion)
+	require.NoError(t, err, "unable to create file version")
+
+	report := &common.Report{UploadID: upload.ID, FileID: file.ID, Reason: "copyright"}
+	createTestReport(t, ctx, report)
+
+	rr := moderateReport(t, ctx, report, common.ModerationTakedown)
+	context.TestOK(t, rr)
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
+
+	f, err = ctx.GetMetadataBackend().GetFile(other.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+
+	blocked, err := ctx.GetMetadataBackend().IsBlockedHash(contentMD5)
+	require.NoError(t, err, "unable to check blocked hash")
+	require.True(t, blocked, "hash should be blocked")
+
+	blocked, err = ctx.GetMetadataBackend().IsBlockedHash("other")
+	require.NoError(t, err, "unable to check blocked hash")
+	require.False(t, blocked, "hash should not be blocked")
+
+	blocked, err = ctx.GetMetadataBackend().IsBlockedHash("previous")
+	require.NoError(t, err, "unable to check blocked hash")
+	require.True(t, blocked, "previous version hash should be blocked")
+
+	blocked, err = ctx.GetMetadataBackend().IsBlockedHash("other previous")
+	require.NoError(t, err, "unable to check blocked hash")
+	require.False(t, blocked, "hash should not be blocked")
+
+	v, err := ctx.GetMetadataBackend().GetFileVersion(file.ID, 0)
+	require.NoError(t, err, "unable to get file version")
+	require.Equal(t, common.FileRemoved, v.Status, "invalid file version status")
+
+	v, err = ctx.GetMetadataBackend().GetFileVersion(other.ID, 0)
+	require.NoError(t, err, "unable to get file version")
+	require.Equal(t, common.FileUploaded, v.Status, "invalid file version status")
+
+	r, err := ctx.GetMetadataBackend().GetReport(report.ID)
+	require.NoError(t, err, "unable to get report")
+	require.Equal(t, common.ReportResolved, r.Status, "invalid report status")
+	require.Equal(t, common.ModerationTakedown, r.Action, "invalid report action")
+}
+
+func TestModerateReportDismiss(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	report := &common.Report{UploadID: "missing", Reason: "spam"}
+	createTestReport(t, ctx, report)
+
+	rr := moderateReport(t, ctx, report, common.ModerationDismiss)
+	context.TestOK(t, rr)
+
+	r, err := ctx.GetMetadataBackend().GetReport(report.ID)
+	require.NoError(t, err, "unable to get report")
+	require.Equal(t, common.ReportDismissed, r.Status, "invalid report status")
+
+	rr = moderateReport(t, ctx, report, "foo")
+	context.TestInvalidParameter(t, rr, "action")
+
+	rr = moderateReport(t, ctx, &common.Report{ID: "missing"}, common.ModerationDismiss)
+	context.TestNotFound(t, rr, "report missing not found")
+}
+
+func TestModerateUpload(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	file.Md5 = contentMD5
+	createTestUpload(t, ctx, upload)
+
+	reqBody, err := json.Marshal(&moderationParams{Action: common.ModerationTakedown})
+	require.NoError(t, err, "unable to marshal json body")
+
+	req, err := http.NewRequest("POST", "/uploads/"+upload.ID+"/moderate", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+	rr := ctx.NewRecorder(req)
+	ModerateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Nil(t, u, "upload should be deleted")
+
+	blocked, err := ctx.GetMetadataBackend().IsBlockedHash(contentMD5)
+	require.NoError(t, err, "unable to check blocked hash")
+	require.True(t, blocked, "hash should be blocked")
+}
+
+func TestBlockedHashes(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	reqBody, err := json.Marshal(&common.BlockedHash{Hash: contentMD5, Comment: "known malware"})
+	require.NoError(t, err, "unable to marshal json body")
+
+	req, err := http.NewRequest("POST", "/blocklist", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AddBlockedHash(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	req, err = http.NewRequest("GET", "/blocklist", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	ctx.SetPagingQuery(&common.PagingQuery{})
+	rr = ctx.NewRecorder(req)
+	GetBlockedHashes(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var response common.PagingResponse
+	err = json.Unmarshal(respBody, &response)
+	require.NoError(t, err, "unable to unmarshal response body %s", respBody)
+	require.Equal(t, 1, len(response.Results), "invalid blocked hash count")
+
+	req, err = http.NewRequest("DELETE", "/blocklist/"+contentMD5, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"hash": contentMD5})
+
+	rr = ctx.NewRecorder(req)
+	RemoveBlockedHash(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	rr = ctx.NewRecorder(req)
+	RemoveBlockedHash(ctx, rr, req)
+	context.TestNotFound(t, rr, "hash "+contentMD5+" not found")
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// CreateShareLink create a signed link granting temporary access to a file or to the upload archive
+func CreateShareLink(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	config := ctx.GetConfig()
+
+	// Get upload from context
+	upload := ctx.GetUpload()
+	if upload == nil {
+		ctx.InternalServerError("missing upload from context", nil)
+		return
+	}
+
+	// Check authorization
+	if !ctx.IsUploadAdmin() {
+		ctx.Forbidden("you are not allowed to share this upload")
+		return
+	}
+
+	// Read request body
+	defer func() { _ = req.Body.Close() }()
+
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		ctx.BadRequest("unable to read request body : %s", err)
+		return
+	}
+
+	link := &common.ShareLink{}
+
+	// Deserialize json body
+	if len(body) > 0 {
+		err = json.Unmarshal(body, link)
+		if err != nil {
+			ctx.BadRequest("unable to deserialize request body : %s", err)
+			return
+		}
+	}
+
+	files, err := ctx.GetMetadataBackend().GetFiles(upload.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload files", err)
+		return
+	}
+	upload.Files = files
+
+	if link.IsArchive() && upload.Stream {
+		ctx.BadRequest("archive feature is not available in stream mode")
+		return
+	}
+
+	err = link.PrepareInsert(upload)
+	if err != nil {
+		ctx.BadRequest(err.Error())
+		return
+	}
+
+	// Uploads created by previous Plik versions have no share secret yet
+	if upload.ShareSecret == "" {
+		upload.ShareSecret = common.GenerateShareSecret()
+		err = ctx.GetMetadataBackend().UpdateUpload(upload)
+		if err != nil {
+			ctx.InternalServerError("unable to update upload metadata", err)
+			return
+		}
+	}
+
+	err = link.Sign(upload)
+	if err != nil {
+		ctx.InternalServerError("unable to sign share link", err)
+		return
+	}
+
+	link.URL = config.DownloadDomain + config.Path
+	if link.IsArchive() {
+		link.URL += fmt.Sprintf("/archive/%s/archive.zip", upload.ID)
+	} else {
+		file := upload.GetFile(link.FileID)
+		link.URL += fmt.Sprintf("/file/%s/%s/%s", upload.ID, file.ID, url.PathEscape(file.Name))
+	}
+	link.URL += "?share=" + url.QueryEscape(link.Token)
+
+	common.WriteJSONResponse(resp, link)
+}
+
+// RevokeShareLinks rotate the upload share secret to revoke all the share links of the upload
+func RevokeShareLinks(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	// Get upload from context
+	upload := ctx.GetUpload()
+	if upload == nil {
+		ctx.InternalServerError("missing upload from context", nil)
+		return
+	}
+
+	// Check authorization
+	if !ctx.IsUploadAdmin() {
+		ctx.Forbidden("you are not allowed to manage the share links of this upload")
+		return
+	}
+
+	upload.ShareSecret = common.GenerateShareSecret()
+	err := ctx.GetMetadataBackend().UpdateUpload(upload)
+	if err != nil {
+		ctx.InternalServerError("unable to update upload metadata", err)
+		return
+	}
+
+	err = ctx.GetMetadataBackend().DeleteShareLinkDownloads(upload.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to delete share link downloads", err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
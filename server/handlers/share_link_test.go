@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func TestCreateShareLink(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "my file"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	body, err := json.Marshal(&common.ShareLink{FileID: file.ID, TTL: 60, MaxDownloads: 2})
+	require.NoError(t, err, "unable to serialize request body")
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/share", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateShareLink(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	link := &common.ShareLink{}
+	err = json.Unmarshal(respBody, link)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	require.Equal(t, upload.ID, link.UploadID, "invalid share link upload id")
+	require.Equal(t, file.ID, link.FileID, "invalid share link file id")
+	require.Equal(t, 2, link.MaxDownloads, "invalid share link max downloads")
+	require.Equal(t, "/file/"+upload.ID+"/"+file.ID+"/my%20file?share="+url.QueryEscape(link.Token), link.URL, "invalid share link url")
+
+	parsed, err := common.ParseShareLink(link.Token, upload)
+	require.NoError(t, err, "unable to parse share link")
+	require.Equal(t, link.ID, parsed.ID, "invalid share link id")
+}
+
+func TestCreateShareLinkArchive(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().DownloadDomain = "https://dl.plik"
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	// Uploads created by previous versions have no share secret
+	upload.ShareSecret = ""
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/share", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateShareLink(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	link := &common.ShareLink{}
+	err = json.Unmarshal(respBody, link)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.True(t, link.IsArchive(), "invalid archive share link")
+	require.Equal(t, "https://dl.plik/archive/"+upload.ID+"/archive.zip?share="+url.QueryEscape(link.Token), link.URL, "invalid share link url")
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.NotEmpty(t, u.ShareSecret, "missing upload share secret")
+
+	_, err = common.ParseShareLink(link.Token, u)
+	require.NoError(t, err, "unable to parse share link")
+}
+
+func TestCreateShareLinkNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/share", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateShareLink(ctx, rr, req)
+	context.TestForbidden(t, rr, "you are not allowed to share this upload")
+}
+
+func TestCreateShareLinkInvalidFile(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	body, err := json.Marshal(&common.ShareLink{FileID: "invalid"})
+	require.NoError(t, err, "unable to serialize request body")
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/share", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateShareLink(ctx, rr, req)
+	context.TestBadRequest(t, rr, "file invalid not found")
+}
+
+func TestRevokeShareLinks(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	link := &common.ShareLink{}
+	err := link.PrepareInsert(upload)
+	require.NoError(t, err, "unable to prepare share link")
+	err = link.Sign(upload)
+	require.NoError(t, err, "unable to sign share link")
+
+	req, err := http.NewRequest("DELETE", "/upload/"+upload.ID+"/share", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	RevokeShareLinks(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+
+	_, err = common.ParseShareLink(link.Token, u)
+	require.Error(t, err, "able to parse revoked share link")
+}
+
+func TestRevokeShareLinksNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("DELETE", "/upload/"+upload.ID+"/share", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	RevokeShareLinks(ctx, rr, req)
+	context.TestForbidden(t, rr, "you are not allowed to manage the share links of this upload")
+}
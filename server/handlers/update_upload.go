@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// UpdateUpload update the comments, expiration date and options of an existing upload
+func UpdateUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	config := ctx.GetConfig()
+
+	// Get upload from context
+	upload := ctx.GetUpload()
+	if upload == nil {
+		ctx.InternalServerError("missing upload from context", nil)
+		return
+	}
+
+	// Check authorization
+	if !ctx.IsUploadAdmin() {
+		ctx.Forbidden("you are not allowed to update this upload")
+		return
+	}
+
+	update := &common.UploadUpdate{}
+	if !readJSONParams(ctx, resp, req, update) {
+		return
+	}
+
+	// Upload options can't be set when uploading through an upload request, only the requesting user can change them
+	if update.HasOptions() && upload.RequestID != "" {
+		user := ctx.GetUser()
+		if user == nil || user.ID != upload.User {
+			ctx.Forbidden("upload options can't be changed for uploads created through an upload request")
+			return
+		}
+	}
+
+	err := update.Apply(upload, config)
+	if err != nil {
+		ctx.BadRequest(err.Error())
+		return
+	}
+
+	// Protect upload with HTTP basic auth
+	// Add Authorization header to the response for convenience
+	if update.Password != nil {
+		header := common.EncodeAuthBasicHeader(upload.Login, upload.Password)
+		resp.Header().Add("Authorization", "Basic "+header)
+
+		// Save only a salted hash of this string to authenticate further requests
+		upload.Password, err = common.HashUploadPassword(header)
+		if err != nil {
+			ctx.BadRequest("unable to generate password hash : %s", err)
+			return
+		}
+	}
+
+	// Check the updated upload against the external upload policy
+	if update.HasOptions() && !ctx.CheckUploadPolicy(upload) {
+		return
+	}
+
+	// Only save the updated columns so concurrent moderation or share link changes are not overwritten
+	updated, err := ctx.GetMetadataBackend().UpdateUploadColumns(upload, update.Columns(upload))
+	if err != nil {
+		ctx.InternalServerError("unable to update upload", err)
+		return
+	}
+	if !updated {
+		ctx.Forbidden("upload %s has been frozen or removed in the meantime", upload.ID)
+		return
+	}
+
+	files, err := ctx.GetMetadataBackend().GetFiles(upload.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload files", err)
+		return
+	}
+	upload.Files = files
+
+	// Remove all private information (ip, data backend details, ...) before
+	// sending metadata back to the client
+	requestID := upload.RequestID
+	upload.Sanitize()
+	upload.DownloadDomain = config.DownloadDomain
+	upload.RequestID = requestID
+	upload.IsAdmin = true
+
+	common.WriteJSONResponse(resp, upload)
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func TestUpdateUpload(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{Comments: "foo", TTL: 60}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("PATCH", "/upload/"+upload.ID, bytes.NewBufferString(`{"comments":"bar","ttl":86400,"removable":true}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	UpdateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result := &common.Upload{}
+	err = json.Unmarshal(rr.Body.Bytes(), result)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, "bar", result.Comments, "invalid comments")
+	require.True(t, result.Removable, "invalid removable")
+	require.True(t, result.IsAdmin, "invalid admin")
+	require.Len(t, result.Files, 1, "invalid file count")
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Equal(t, "bar", u.Comments, "invalid comments")
+	require.Equal(t, 86400, u.TTL, "invalid ttl")
+	require.WithinDuration(t, time.Now().Add(24*time.Hour), *u.ExpireAt, 5*time.Second, "invalid expiration date")
+}
+
+func TestUpdateUploadPassword(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("PATCH", "/upload/"+upload.ID, bytes.NewBufferString(`{"login":"foo","password":"bar"}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	UpdateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+	require.Equal(t, "Basic "+common.EncodeAuthBasicHeader("foo", "bar"), rr.Header().Get("Authorization"), "invalid authorization header")
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.True(t, u.ProtectedByPassword, "upload should be protected by password")
+	require.Equal(t, "foo", u.Login, "invalid login")
+	require.NotEqual(t, "bar", u.Password, "password should be hashed")
+}
+
+func TestUpdateUploadInvalidTTL(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxTTL = 3600
+	ctx := newTestingContext(config)
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{TTL: 60}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("PATCH", "/upload/"+upload.ID, bytes.NewBufferString(`{"ttl":86400}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	UpdateUpload(ctx, rr, req)
+	context.TestBadRequest(t, rr, "invalid ttl. (maximum allowed is : 3600)")
+}
+
+func TestUpdateUploadNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("PATCH", "/upload/"+upload.ID, bytes.NewBufferString(`{"comments":"bar"}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	UpdateUpload(ctx, rr, req)
+	context.TestForbidden(t, rr, "you are not allowed to update this upload")
+}
+
+func TestUpdateUploadRequestOptions(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{User: "user", RequestID: "request"}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("PATCH", "/upload/"+upload.ID, bytes.NewBufferString(`{"ttl":60}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	UpdateUpload(ctx, rr, req)
+	context.TestForbidden(t, rr, "upload options can't be changed for uploads created through an upload request")
+
+	// Comments can still be changed by the uploader
+	req, err = http.NewRequest("PATCH", "/upload/"+upload.ID, bytes.NewBufferString(`{"comments":"bar"}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	UpdateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestUpdateUploadConcurrentChanges(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{Comments: "foo", TTL: 60}
+	createTestUpload(t, ctx, upload)
+
+	// The share link is rotated after the upload has been read
+	stale := *upload
+	ctx.SetUpload(&stale)
+	upload.ShareSecret = common.GenerateShareSecret()
+	err := ctx.GetMetadataBackend().UpdateUpload(upload)
+	require.NoError(t, err, "unable to update upload")
+
+	req, err := http.NewRequest("PATCH", "/upload/"+upload.ID, bytes.NewBufferString(`{"comments":"bar"}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	UpdateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Equal(t, "bar", u.Comments, "invalid comments")
+	require.Equal(t, upload.ShareSecret, u.ShareSecret, "share secret should not be overwritten")
+}
+
+func TestUpdateUploadFrozenInTheMeantime(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{Comments: "foo", TTL: 60}
+	createTestUpload(t, ctx, upload)
+
+	// The upload is frozen by an administrator after it has been read
+	stale := *upload
+	ctx.SetUpload(&stale)
+	upload.Frozen = true
+	err := ctx.GetMetadataBackend().UpdateUpload(upload)
+	require.NoError(t, err, "unable to update upload")
+
+	req, err := http.NewRequest("PATCH", "/upload/"+upload.ID, bytes.NewBufferString(`{"comments":"bar"}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	UpdateUpload(ctx, rr, req)
+	context.TestForbidden(t, rr, "upload "+upload.ID+" has been frozen or removed in the meantime")
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.True(t, u.Frozen, "upload should still be frozen")
+	require.Equal(t, "foo", u.Comments, "invalid comments")
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// UploadLogin check the credentials of a password protected upload and set an upload session cookie
+// so that the browser can access the upload without providing a basic auth Authorization header
+func UploadLogin(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	log := ctx.GetLogger()
+
+	// Get the upload id from the url params
+	vars := mux.Vars(req)
+	uploadID := vars["uploadID"]
+	if uploadID == "" {
+		ctx.MissingParameter("upload id")
+		return
+	}
+
+	// Get upload metadata
+	upload, err := ctx.GetMetadataBackend().GetUpload(uploadID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload metadata", err)
+		return
+	}
+	if upload == nil || upload.IsExpired() {
+		ctx.NotFound("upload %s not found", uploadID)
+		return
+	}
+
+	if !upload.ProtectedByPassword {
+		ctx.BadRequest("upload is not protected by password")
+		return
+	}
+
+	defer func() { _ = req.Body.Close() }()
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+
+	loginParams := &LoginParams{}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		// HTML form
+		loginParams.Login = req.PostFormValue("login")
+		loginParams.Password = req.PostFormValue("password")
+	} else {
+		// Read request body
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			ctx.BadRequest("unable to read request body : %s", err)
+			return
+		}
+
+		err = json.Unmarshal(body, loginParams)
+		if err != nil {
+			ctx.BadRequest("unable to deserialize request body : %s", err)
+			return
+		}
+	}
+
+	if loginParams.Login == "" {
+		loginParams.Login = "plik"
+	}
+
+	if loginParams.Password == "" {
+		ctx.MissingParameter("password")
+		return
+	}
+
+	// Protect against brute force attacks
+	authFailureKey := common.UploadAuthFailureKey(upload.ID)
+	if !ctx.CheckAuthLockout(authFailureKey) {
+		return
+	}
+
+	credentials := common.EncodeAuthBasicHeader(loginParams.Login, loginParams.Password)
+	ok, legacy := common.CheckUploadPassword(credentials, upload.Password)
+	if !ok {
+		ctx.AuthFailed(authFailureKey)
+		ctx.Forbidden("invalid credentials")
+		return
+	}
+
+	ctx.AuthSucceeded(authFailureKey)
+
+	if legacy {
+		// Upgrade the legacy md5 password hash before signing the cookie with the hash fingerprint
+		upload.Password, err = common.HashUploadPassword(credentials)
+		if err != nil {
+			ctx.InternalServerError("unable to hash upload password", err)
+			return
+		}
+
+		err = ctx.GetMetadataBackend().UpdateUpload(upload)
+		if err != nil {
+			ctx.InternalServerError("unable to update upload metadata", err)
+			return
+		}
+		log.Infof("upgraded legacy password hash of upload %s", upload.ID)
+	}
+
+	cookie, err := ctx.GetAuthenticator().GenUploadSessionCookie(upload)
+	if err != nil {
+		ctx.InternalServerError("unable to generate upload session cookie", err)
+		return
+	}
+	http.SetCookie(resp, cookie)
+
+	_, _ = resp.Write([]byte("ok"))
+}
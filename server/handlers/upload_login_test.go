@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/root-gg/utils"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func createPasswordProtectedUpload(t *testing.T, ctx *context.Context, login string, password string) (upload *common.Upload) {
+	upload = &common.Upload{}
+	upload.ProtectedByPassword = true
+	upload.Login = login
+	upload.PrepareInsertForTests()
+
+	var err error
+	upload.Password, err = common.HashUploadPassword(common.EncodeAuthBasicHeader(login, password))
+	require.NoError(t, err, "unable to hash upload password")
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	return upload
+}
+
+func getUploadSessionCookie(resp *http.Response, upload *common.Upload) *http.Cookie {
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == common.UploadSessionCookieName(upload.ID) {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestUploadLogin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := createPasswordProtectedUpload(t, ctx, "foo", "bar")
+
+	credentials, _ := utils.ToJson(struct{ Login, Password string }{"foo", "bar"})
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/login", bytes.NewBuffer(credentials))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+	rr := ctx.NewRecorder(req)
+	UploadLogin(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	cookie := getUploadSessionCookie(rr.Result(), upload)
+	require.NotNil(t, cookie, "missing upload session cookie")
+
+	err = ctx.GetAuthenticator().ParseUploadSessionCookie(cookie.Value, upload)
+	require.NoError(t, err, "invalid upload session cookie")
+}
+
+func TestUploadLoginForm(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := createPasswordProtectedUpload(t, ctx, "plik", "bar")
+
+	form := url.Values{}
+	form.Set("password", "bar")
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/login", strings.NewReader(form.Encode()))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+	rr := ctx.NewRecorder(req)
+	UploadLogin(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.NotNil(t, getUploadSessionCookie(rr.Result(), upload), "missing upload session cookie")
+}
+
+func TestUploadLoginInvalidCredentials(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := createPasswordProtectedUpload(t, ctx, "foo", "bar")
+
+	credentials, _ := utils.ToJson(struct{ Login, Password string }{"foo", "invalid"})
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/login", bytes.NewBuffer(credentials))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+	rr := ctx.NewRecorder(req)
+	UploadLogin(ctx, rr, req)
+	context.TestForbidden(t, rr, "invalid credentials")
+	require.Nil(t, getUploadSessionCookie(rr.Result(), upload), "unexpected upload session cookie")
+}
+
+func TestUploadLoginMissingPassword(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := createPasswordProtectedUpload(t, ctx, "foo", "bar")
+
+	credentials, _ := utils.ToJson(struct{ Login string }{"foo"})
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/login", bytes.NewBuffer(credentials))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+	rr := ctx.NewRecorder(req)
+	UploadLogin(ctx, rr, req)
+	context.TestMissingParameter(t, rr, "password")
+}
+
+func TestUploadLoginNotProtected(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	upload.PrepareInsertForTests()
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/login", bytes.NewBuffer([]byte("{}")))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+	rr := ctx.NewRecorder(req)
+	UploadLogin(ctx, rr, req)
+	context.TestBadRequest(t, rr, "upload is not protected by password")
+}
+
+func TestUploadLoginNotFound(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("POST", "/upload/foo/login", bytes.NewBuffer([]byte("{}")))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": "foo"})
+
+	rr := ctx.NewRecorder(req)
+	UploadLogin(ctx, rr, req)
+	context.TestNotFound(t, rr, "upload foo not found")
+}
+
+func TestUploadLoginLegacyHash(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	upload.ProtectedByPassword = true
+	upload.PrepareInsertForTests()
+
+	var err error
+	upload.Password, err = utils.Md5sum(common.EncodeAuthBasicHeader("foo", "bar"))
+	require.NoError(t, err, "unable to md5sum upload password")
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	credentials, _ := utils.ToJson(struct{ Login, Password string }{"foo", "bar"})
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/login", bytes.NewBuffer(credentials))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+	rr := ctx.NewRecorder(req)
+	UploadLogin(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	_, legacy := common.CheckUploadPassword(common.EncodeAuthBasicHeader("foo", "bar"), result.Password)
+	require.False(t, legacy, "legacy password hash has not been upgraded")
+
+	cookie := getUploadSessionCookie(rr.Result(), upload)
+	require.NotNil(t, cookie, "missing upload session cookie")
+	err = ctx.GetAuthenticator().ParseUploadSessionCookie(cookie.Value, result)
+	require.NoError(t, err, "invalid upload session cookie")
+}
+
+func TestUploadLoginLockout(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().AuthFailureThreshold = 1
+	upload := createPasswordProtectedUpload(t, ctx, "foo", "bar")
+
+	login := func(password string) *httptest.ResponseRecorder {
+		credentials, _ := utils.ToJson(struct{ Login, Password string }{"foo", password})
+		req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/login", bytes.NewBuffer(credentials))
+		require.NoError(t, err, "unable to create new request")
+		req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+		rr := ctx.NewRecorder(req)
+		UploadLogin(ctx, rr, req)
+		return rr
+	}
+
+	context.TestForbidden(t, login("invalid"), "invalid credentials")
+
+	// Even valid credentials are rejected during the lockout
+	context.TestTooManyRequests(t, login("bar"), "too many failed authentication attempts")
+}
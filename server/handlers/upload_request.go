@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// CreateUploadRequest create a new upload request to let anyone knowing the request URL upload files to the user
+func CreateUploadRequest(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	config := ctx.GetConfig()
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	// Read request body
+	defer func() { _ = req.Body.Close() }()
+
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		ctx.BadRequest("unable to read request body : %s", err)
+		return
+	}
+
+	request := &common.UploadRequest{}
+
+	// Deserialize json body
+	if len(body) > 0 {
+		err = json.Unmarshal(body, request)
+		if err != nil {
+			ctx.BadRequest("unable to deserialize request body : %s", err)
+			return
+		}
+	}
+
+	request.User = user.ID
+
+	// Protect upload request with HTTP basic auth
+	if request.Password != "" {
+		if request.Login == "" {
+			request.Login = "plik"
+		}
+
+		request.ProtectedByPassword = true
+
+		// Save only a salted hash of the base64 version of "login:password"
+		header := common.EncodeAuthBasicHeader(request.Login, request.Password)
+		request.Password, err = common.HashUploadPassword(header)
+		if err != nil {
+			ctx.BadRequest("unable to generate password hash : %s", err)
+			return
+		}
+	} else {
+		request.ProtectedByPassword = false
+	}
+
+	err = request.PrepareInsert(config)
+	if err != nil {
+		ctx.BadRequest(err.Error())
+		return
+	}
+
+	err = ctx.GetMetadataBackend().CreateUploadRequest(request)
+	if err != nil {
+		ctx.InternalServerError("unable to create upload request", err)
+		return
+	}
+
+	request.Sanitize()
+	request.URL = getUploadRequestURL(config, request)
+
+	common.WriteJSONResponse(resp, request)
+}
+
+// GetUploadRequests return the upload requests of the user
+func GetUploadRequests(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	config := ctx.GetConfig()
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	pagingQuery := ctx.GetPagingQuery()
+
+	requests, cursor, err := ctx.GetMetadataBackend().GetUploadRequests(user.ID, pagingQuery)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload requests", err)
+		return
+	}
+
+	for _, request := range requests {
+		request.Sanitize()
+		request.URL = getUploadRequestURL(config, request)
+	}
+
+	pagingResponse := common.NewPagingResponse(requests, cursor)
+	common.WriteJSONResponse(resp, pagingResponse)
+}
+
+// RemoveUploadRequest delete an upload request, uploads created through the request are kept
+func RemoveUploadRequest(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	// Get upload request to remove from URL params
+	vars := mux.Vars(req)
+	requestID, ok := vars["requestID"]
+	if !ok || requestID == "" {
+		ctx.MissingParameter("upload request id")
+		return
+	}
+
+	request, err := ctx.GetMetadataBackend().GetUploadRequest(requestID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload request", err)
+		return
+	}
+
+	if request == nil || request.User != user.ID {
+		ctx.NotFound("upload request not found")
+		return
+	}
+
+	_, err = ctx.GetMetadataBackend().DeleteUploadRequest(request.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to delete upload request", err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// GetUploadRequest return the public information of an upload request
+func GetUploadRequest(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	// Get upload request from context
+	request := ctx.GetUploadRequest()
+	if request == nil {
+		ctx.InternalServerError("missing upload request from context", nil)
+		return
+	}
+
+	request.Sanitize()
+
+	common.WriteJSONResponse(resp, request)
+}
+
+// getUploadRequestURL return the webapp URL of an upload request
+func getUploadRequestURL(config *common.Configuration, request *common.UploadRequest) string {
+	return config.Path + "/#/?request=" + request.ID
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func createTestUploadRequest(t *testing.T, ctx *context.Context, request *common.UploadRequest) {
+	request.ID = common.GenerateRandomID(16)
+	err := ctx.GetMetadataBackend().CreateUploadRequest(request)
+	require.NoError(t, err, "unable to create upload request")
+}
+
+func newUploadRequestTestingContext(t *testing.T) (ctx *context.Context, user *common.User) {
+	ctx = newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Authentication = true
+
+	user = common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	return ctx, user
+}
+
+func TestCreateUploadRequest(t *testing.T) {
+	ctx, user := newUploadRequestTestingContext(t)
+	ctx.SetUser(user)
+
+	reqBody, err := json.Marshal(&common.UploadRequest{Message: "please send me the logs", MaxFiles: 3, Password: "secret"})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/me/requests", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadRequest(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	result := &common.UploadRequest{}
+	err = json.Unmarshal(respBody, result)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	require.NotEmpty(t, result.ID, "missing upload request id")
+	require.Equal(t, "please send me the logs", result.Message, "invalid upload request message")
+	require.Equal(t, 3, result.MaxFiles, "invalid upload request max files")
+	require.True(t, result.ProtectedByPassword, "upload request should be password protected")
+	require.Empty(t, result.Password, "password should be sanitized")
+	require.Equal(t, "/#/?request="+result.ID, result.URL, "invalid upload request url")
+
+	request, err := ctx.GetMetadataBackend().GetUploadRequest(result.ID)
+	require.NoError(t, err, "unable to get upload request")
+	require.Equal(t, user.ID, request.User, "invalid upload request user")
+
+	ok, _ := common.CheckUploadPassword(common.EncodeAuthBasicHeader("plik", "secret"), request.Password)
+	require.True(t, ok, "invalid upload request password hash")
+}
+
+func TestCreateUploadRequestNoUser(t *testing.T) {
+	ctx, _ := newUploadRequestTestingContext(t)
+
+	req, err := http.NewRequest("POST", "/me/requests", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadRequest(ctx, rr, req)
+	context.TestUnauthorized(t, rr, "missing user, please login first")
+}
+
+func TestCreateUploadRequestInvalidTTL(t *testing.T) {
+	ctx, user := newUploadRequestTestingContext(t)
+	ctx.SetUser(user)
+
+	reqBody, err := json.Marshal(&common.UploadRequest{TTL: -1})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/me/requests", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadRequest(ctx, rr, req)
+	context.TestBadRequest(t, rr, "cannot set infinite ttl")
+}
+
+func TestGetUploadRequests(t *testing.T) {
+	ctx, user := newUploadRequestTestingContext(t)
+	ctx.SetUser(user)
+	ctx.SetPagingQuery(&common.PagingQuery{})
+
+	createTestUploadRequest(t, ctx, &common.UploadRequest{User: user.ID, Login: "plik", Password: "hash"})
+	createTestUploadRequest(t, ctx, &common.UploadRequest{User: user.ID})
+
+	req, err := http.NewRequest("GET", "/me/requests", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetUploadRequests(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var response common.PagingResponse
+	err = json.Unmarshal(respBody, &response)
+	require.NoError(t, err, "unable to unmarshal response body %s", respBody)
+	require.Equal(t, 2, len(response.Results), "invalid upload request count")
+	require.NotContains(t, string(respBody), "hash", "password should be sanitized")
+}
+
+func TestRemoveUploadRequest(t *testing.T) {
+	ctx, user := newUploadRequestTestingContext(t)
+	ctx.SetUser(user)
+
+	request := &common.UploadRequest{User: user.ID}
+	createTestUploadRequest(t, ctx, request)
+
+	req, err := http.NewRequest("DELETE", "/me/requests/"+request.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"requestID": request.ID})
+
+	rr := ctx.NewRecorder(req)
+	RemoveUploadRequest(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result, err := ctx.GetMetadataBackend().GetUploadRequest(request.ID)
+	require.NoError(t, err, "unable to get upload request")
+	require.Nil(t, result, "upload request should be deleted")
+}
+
+func TestRemoveUploadRequestNotOwner(t *testing.T) {
+	ctx, user := newUploadRequestTestingContext(t)
+
+	request := &common.UploadRequest{User: user.ID}
+	createTestUploadRequest(t, ctx, request)
+
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "other"))
+
+	req, err := http.NewRequest("DELETE", "/me/requests/"+request.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"requestID": request.ID})
+
+	rr := ctx.NewRecorder(req)
+	RemoveUploadRequest(ctx, rr, req)
+	context.TestNotFound(t, rr, "upload request not found")
+}
+
+func TestGetUploadRequest(t *testing.T) {
+	ctx, user := newUploadRequestTestingContext(t)
+	ctx.SetUploadRequest(&common.UploadRequest{ID: "request", User: user.ID, Message: "hello", Login: "plik", Password: "hash"})
+
+	req, err := http.NewRequest("GET", "/request/request", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetUploadRequest(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	result := &common.UploadRequest{}
+	err = json.Unmarshal(respBody, result)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, "hello", result.Message, "invalid upload request message")
+	require.Empty(t, result.Password, "password should be sanitized")
+}
+
+func TestCreateUploadThroughUploadRequest(t *testing.T) {
+	ctx, user := newUploadRequestTestingContext(t)
+	ctx.GetConfig().NoAnonymousUploads = true
+	ctx.SetWhitelisted(false)
+
+	ctx.SetUploadRequest(&common.UploadRequest{ID: "request", User: user.ID})
+
+	// Upload options are ignored
+	reqBody, err := json.Marshal(&common.Upload{OneShot: true, ACL: []string{"user:local:other"}})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/request/request/upload", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	result := &common.Upload{}
+	err = json.Unmarshal(respBody, result)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.NotEmpty(t, result.UploadToken, "missing upload token")
+	require.Empty(t, result.ACL, "access control list should not be returned")
+
+	upload, err := ctx.GetMetadataBackend().GetUpload(result.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Equal(t, user.ID, upload.User, "invalid upload user")
+	require.Equal(t, "request", upload.RequestID, "invalid upload request id")
+	require.False(t, upload.OneShot, "upload options should be ignored")
+	require.True(t, upload.Restricted, "upload should be restricted")
+}
+
+func TestAddFileUploadRequestLimits(t *testing.T) {
+	ctx, user := newUploadRequestTestingContext(t)
+	ctx.SetUploadAdmin(true)
+
+	request := &common.UploadRequest{User: user.ID, MaxFiles: 1, MaxSize: 5}
+	createTestUploadRequest(t, ctx, request)
+
+	upload := &common.Upload{User: user.ID, RequestID: request.ID}
+	createTestUpload(t, ctx, upload)
+
+	addFile := func() *http.Request {
+		reader, contentType, err := getMultipartFormData("file", bytes.NewBuffer([]byte(content)))
+		require.NoError(t, err, "unable get multipart form data")
+
+		req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+		require.NoError(t, err, "unable to create new request")
+		req.Header.Set("Content-Type", contentType)
+		return req
+	}
+
+	req := addFile()
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestBadRequest(t, rr, "file too big (limit is set to 5 bytes)")
+
+	// Reach the file limit
+	file := upload.NewFile()
+	file.Size = 1
+	file.Status = common.FileUploaded
+	err := ctx.GetMetadataBackend().CreateFile(file)
+	require.NoError(t, err, "unable to create file")
+
+	req = addFile()
+	rr = ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestForbidden(t, rr, "upload request file limit reached, limit is 1 files")
+
+	// Expire the upload request
+	_, err = ctx.GetMetadataBackend().DeleteUploadRequest(request.ID)
+	require.NoError(t, err, "unable to delete upload request")
+
+	req = addFile()
+	rr = ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestForbidden(t, rr, "upload request has expired")
+}
+
+func TestAddFileUploadRequestExpired(t *testing.T) {
+	ctx, user := newUploadRequestTestingContext(t)
+	ctx.SetUploadAdmin(true)
+
+	deadline := time.Now().Add(-time.Minute)
+	request := &common.UploadRequest{User: user.ID, ExpireAt: &deadline}
+	createTestUploadRequest(t, ctx, request)
+
+	upload := &common.Upload{User: user.ID, RequestID: request.ID}
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData("file", bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestForbidden(t, rr, "upload request has expired")
+}
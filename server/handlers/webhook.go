@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// webhookParams are the user editable webhook fields
+type webhookParams struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+	Events string `json:"events"`
+}
+
+// CreateUserWebhook register a webhook for all the uploads of the user
+func CreateUserWebhook(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	params := &webhookParams{}
+	if !readJSONParams(ctx, resp, req, params) {
+		return
+	}
+
+	webhook := &common.Webhook{User: user.ID, URL: params.URL, Secret: params.Secret, Events: params.Events}
+	createWebhook(ctx, resp, webhook)
+}
+
+// GetUserWebhooks return the webhooks registered for all the uploads of the user
+func GetUserWebhooks(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	webhooks, cursor, err := ctx.GetMetadataBackend().GetUserWebhooks(user.ID, ctx.GetPagingQuery())
+	if err != nil {
+		ctx.InternalServerError("unable to get webhooks", err)
+		return
+	}
+
+	pagingResponse := common.NewPagingResponse(webhooks, cursor)
+	common.WriteJSONResponse(resp, pagingResponse)
+}
+
+// RemoveUserWebhook remove a webhook of the user
+func RemoveUserWebhook(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	webhook, ok := getWebhook(ctx, req)
+	if !ok {
+		return
+	}
+
+	if webhook.User != user.ID || webhook.UploadID != "" {
+		ctx.NotFound("webhook not found")
+		return
+	}
+
+	removeWebhook(ctx, resp, webhook)
+}
+
+// CreateUploadWebhook register a webhook for an upload
+func CreateUploadWebhook(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	upload := ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	if !ctx.IsUploadAdmin() {
+		ctx.Forbidden("you are not allowed to add webhooks to this upload")
+		return
+	}
+
+	params := &webhookParams{}
+	if !readJSONParams(ctx, resp, req, params) {
+		return
+	}
+
+	webhook := &common.Webhook{UploadID: upload.ID, URL: params.URL, Secret: params.Secret, Events: params.Events}
+	createWebhook(ctx, resp, webhook)
+}
+
+// GetUploadWebhooks return the webhooks registered for an upload
+func GetUploadWebhooks(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	upload := ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	if !ctx.IsUploadAdmin() {
+		ctx.Forbidden("you are not allowed to get the webhooks of this upload")
+		return
+	}
+
+	webhooks, err := ctx.GetMetadataBackend().GetUploadWebhooks(upload.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get webhooks", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, webhooks)
+}
+
+// RemoveUploadWebhook remove a webhook of an upload
+func RemoveUploadWebhook(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	upload := ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	if !ctx.IsUploadAdmin() {
+		ctx.Forbidden("you are not allowed to remove the webhooks of this upload")
+		return
+	}
+
+	webhook, ok := getWebhook(ctx, req)
+	if !ok {
+		return
+	}
+
+	if webhook.UploadID != upload.ID {
+		ctx.NotFound("webhook not found")
+		return
+	}
+
+	removeWebhook(ctx, resp, webhook)
+}
+
+func createWebhook(ctx *context.Context, resp http.ResponseWriter, webhook *common.Webhook) {
+	if !ctx.GetConfig().UserWebhooks && !ctx.IsAdmin() {
+		ctx.Forbidden("only administrators can register webhooks")
+		return
+	}
+
+	err := webhook.PrepareInsert(ctx.GetConfig().GetFetchAllowedNetworks())
+	if err != nil {
+		ctx.BadRequest(err.Error())
+		return
+	}
+
+	err = ctx.GetMetadataBackend().CreateWebhook(webhook)
+	if err != nil {
+		ctx.InternalServerError("unable to create webhook", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, webhook)
+}
+
+// getWebhook return the webhook from the URL params
+// On error the response is written and false is returned
+func getWebhook(ctx *context.Context, req *http.Request) (webhook *common.Webhook, ok bool) {
+	vars := mux.Vars(req)
+	webhookID, ok := vars["webhookID"]
+	if !ok || webhookID == "" {
+		ctx.MissingParameter("webhook ID")
+		return nil, false
+	}
+
+	webhook, err := ctx.GetMetadataBackend().GetWebhook(webhookID)
+	if err != nil {
+		ctx.InternalServerError("unable to get webhook", err)
+		return nil, false
+	}
+	if webhook == nil {
+		ctx.NotFound("webhook not found")
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+func removeWebhook(ctx *context.Context, resp http.ResponseWriter, webhook *common.Webhook) {
+	_, err := ctx.GetMetadataBackend().DeleteWebhook(webhook.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to delete webhook", err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
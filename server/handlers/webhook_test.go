@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/webhook"
+)
+
+func createTestWebhook(t *testing.T, ctx *context.Context, webhook *common.Webhook) {
+	err := webhook.PrepareInsert(nil)
+	require.NoError(t, err, "unable to prepare webhook")
+	err = ctx.GetMetadataBackend().CreateWebhook(webhook)
+	require.NoError(t, err, "unable to create webhook")
+}
+
+func TestCreateUserWebhook(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().UserWebhooks = true
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+
+	body, err := json.Marshal(&webhookParams{URL: "https://ci.example.com/hook", Events: common.EventFileUploaded})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/me/webhooks", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUserWebhook(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	result := &common.Webhook{}
+	err = json.Unmarshal(respBody, result)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.NotEmpty(t, result.Secret, "missing webhook secret")
+
+	webhook, err := ctx.GetMetadataBackend().GetWebhook(result.ID)
+	require.NoError(t, err, "unable to get webhook")
+	require.NotNil(t, webhook, "missing webhook")
+	require.Equal(t, user.ID, webhook.User, "invalid webhook user")
+	require.Equal(t, common.EventFileUploaded, webhook.Events, "invalid webhook events")
+}
+
+func TestCreateUserWebhookInvalid(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().UserWebhooks = true
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+
+	body, err := json.Marshal(&webhookParams{URL: "https://ci.example.com/hook", Events: "foo"})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/me/webhooks", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUserWebhook(ctx, rr, req)
+	context.TestBadRequest(t, rr, "invalid webhook event foo")
+}
+
+func TestCreateUserWebhookAdminOnly(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+
+	body, err := json.Marshal(&webhookParams{URL: "https://ci.example.com/hook"})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/me/webhooks", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUserWebhook(ctx, rr, req)
+	context.TestForbidden(t, rr, "only administrators can register webhooks")
+
+	user.IsAdmin = true
+	req, err = http.NewRequest("POST", "/me/webhooks", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	CreateUserWebhook(ctx, rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestCreateUserWebhookDeniedAddress(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().UserWebhooks = true
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+
+	body, err := json.Marshal(&webhookParams{URL: "http://169.254.169.254/latest/meta-data"})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/me/webhooks", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUserWebhook(ctx, rr, req)
+	context.TestBadRequest(t, rr, "webhook address 169.254.169.254 is not allowed")
+}
+
+func TestCreateUserWebhookNoUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("POST", "/me/webhooks", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUserWebhook(ctx, rr, req)
+	context.TestUnauthorized(t, rr, "missing user, please login first")
+}
+
+func TestGetUserWebhooks(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+	ctx.SetPagingQuery(&common.PagingQuery{})
+
+	createTestWebhook(t, ctx, &common.Webhook{User: user.ID, URL: "https://ci.example.com/hook"})
+	createTestWebhook(t, ctx, &common.Webhook{User: user.ID, URL: "https://ci.example.com/hook"})
+	createTestWebhook(t, ctx, &common.Webhook{User: "other", URL: "https://ci.example.com/hook"})
+
+	req, err := http.NewRequest("GET", "/me/webhooks", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetUserWebhooks(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var response common.PagingResponse
+	err = json.Unmarshal(respBody, &response)
+	require.NoError(t, err, "unable to unmarshal response body %s", respBody)
+	require.Equal(t, 2, len(response.Results), "invalid webhook count")
+}
+
+func TestRemoveUserWebhook(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+
+	webhook := &common.Webhook{User: "other", URL: "https://ci.example.com/hook"}
+	createTestWebhook(t, ctx, webhook)
+
+	req, err := http.NewRequest("DELETE", "/me/webhooks/"+webhook.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"webhookID": webhook.ID})
+
+	rr := ctx.NewRecorder(req)
+	RemoveUserWebhook(ctx, rr, req)
+	context.TestNotFound(t, rr, "webhook not found")
+
+	webhook = &common.Webhook{User: user.ID, URL: "https://ci.example.com/hook"}
+	createTestWebhook(t, ctx, webhook)
+	req = mux.SetURLVars(req, map[string]string{"webhookID": webhook.ID})
+
+	rr = ctx.NewRecorder(req)
+	RemoveUserWebhook(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result, err := ctx.GetMetadataBackend().GetWebhook(webhook.ID)
+	require.NoError(t, err, "unable to get webhook")
+	require.Nil(t, result, "webhook should be deleted")
+}
+
+func TestUploadWebhooks(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().UserWebhooks = true
+	ctx.SetUploadAdmin(true)
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	body, err := json.Marshal(&webhookParams{URL: "https://ci.example.com/hook"})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/webhooks", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadWebhook(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	req, err = http.NewRequest("GET", "/upload/"+upload.ID+"/webhooks", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	GetUploadWebhooks(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var webhooks []*common.Webhook
+	err = json.Unmarshal(rr.Body.Bytes(), &webhooks)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Len(t, webhooks, 1, "invalid webhook count")
+	require.Equal(t, upload.ID, webhooks[0].UploadID, "invalid webhook upload")
+
+	req, err = http.NewRequest("DELETE", "/upload/"+upload.ID+"/webhooks/"+webhooks[0].ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"webhookID": webhooks[0].ID})
+
+	rr = ctx.NewRecorder(req)
+	RemoveUploadWebhook(ctx, rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestUploadWebhooksNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/webhooks", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadWebhook(ctx, rr, req)
+	context.TestForbidden(t, rr, "you are not allowed to add webhooks to this upload")
+}
+
+func TestAddFileNotifyEvent(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUploadAdmin(true)
+	ctx.SetWebhooks(webhook.NewDispatcher(ctx.GetConfig(), ctx.GetMetadataBackend()))
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	createTestWebhook(t, ctx, &common.Webhook{UploadID: upload.ID, URL: "https://ci.example.com/hook", Events: common.EventFileUploaded})
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	deliveries, err := ctx.GetMetadataBackend().GetDueWebhookDeliveries(time.Now(), 0)
+	require.NoError(t, err, "unable to get webhook deliveries")
+	require.Len(t, deliveries, 1, "invalid delivery count")
+	require.Equal(t, common.EventFileUploaded, deliveries[0].Event, "invalid delivery event")
+	require.Contains(t, deliveries[0].Payload, file.ID, "invalid delivery payload")
+}
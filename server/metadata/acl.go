@@ -0,0 +1,91 @@
+package metadata
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	paginator "github.com/pilagod/gorm-cursor-paginator"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// createUploadACL save the upload access control list entries
+func createUploadACL(tx *gorm.DB, upload *common.Upload) (err error) {
+	for _, subject := range upload.ACL {
+		err = tx.Create(&common.UploadACL{UploadID: upload.ID, Subject: subject}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetUploadACL return the access control list subjects of an upload
+func (b *Backend) GetUploadACL(uploadID string) (acl []string, err error) {
+	err = b.db.Model(&common.UploadACL{}).Where(&common.UploadACL{UploadID: uploadID}).Order("subject").Pluck("subject", &acl).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return acl, nil
+}
+
+// GetSharedUploads return the non expired uploads whose access control list matches one of the subjects
+// set withFiles to also fetch the files
+func (b *Backend) GetSharedUploads(subjects []string, withFiles bool, pagingQuery *common.PagingQuery) (uploads []*common.Upload, cursor *paginator.Cursor, err error) {
+	if pagingQuery == nil {
+		return nil, nil, fmt.Errorf("missing paging query")
+	}
+
+	shared := b.db.Model(&common.UploadACL{}).Select("upload_id").Where("subject IN (?)", subjects).QueryExpr()
+
+	stmt := b.db.Model(&common.Upload{}).
+		Where("id IN (?)", shared).
+		Where("expire_at IS NULL OR expire_at > ?", time.Now())
+
+	if withFiles {
+		stmt = stmt.Preload("Files")
+	}
+
+	p := pagingQuery.Paginator()
+	p.SetKeys("CreatedAt", "ID")
+
+	err = p.Paginate(stmt, &uploads).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := p.GetNextCursor()
+
+	return uploads, &c, err
+}
+
+// AddUploadACL save an upload access control list entry
+func (b *Backend) AddUploadACL(entry *common.UploadACL) (err error) {
+	return b.db.Create(entry).Error
+}
+
+// ForEachUploadACL execute f for every upload access control list entry
+func (b *Backend) ForEachUploadACL(f func(entry *common.UploadACL) error) (err error) {
+	rows, err := b.db.Model(&common.UploadACL{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		entry := &common.UploadACL{}
+		err = b.db.ScanRows(rows, entry)
+		if err != nil {
+			return err
+		}
+		err = f(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
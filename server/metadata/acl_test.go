@@ -0,0 +1,68 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestBackend_GetUploadACL(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{ACL: []string{"user:local:bob", "group:google:example.com"}}
+	createUpload(t, b, upload)
+
+	acl, err := b.GetUploadACL(upload.ID)
+	require.NoError(t, err, "get upload acl error")
+	require.Equal(t, []string{"group:google:example.com", "user:local:bob"}, acl, "invalid upload acl")
+
+	u, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.True(t, u.Restricted, "upload should be restricted")
+}
+
+func TestBackend_GetSharedUploads(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload1 := &common.Upload{ACL: []string{"user:local:bob"}}
+	createUpload(t, b, upload1)
+
+	upload2 := &common.Upload{ACL: []string{"group:google:example.com"}}
+	createUpload(t, b, upload2)
+
+	upload3 := &common.Upload{ACL: []string{"user:local:alice"}}
+	createUpload(t, b, upload3)
+
+	deadline := time.Now().Add(-time.Hour)
+	expired := &common.Upload{ACL: []string{"user:local:bob"}, ExpireAt: &deadline}
+	createUpload(t, b, expired)
+
+	uploads, _, err := b.GetSharedUploads([]string{"user:local:bob", "group:google:example.com"}, false, &common.PagingQuery{})
+	require.NoError(t, err, "get shared uploads error")
+	require.Len(t, uploads, 2, "invalid shared uploads count")
+
+	ids := []string{uploads[0].ID, uploads[1].ID}
+	require.Contains(t, ids, upload1.ID, "missing shared upload")
+	require.Contains(t, ids, upload2.ID, "missing shared upload")
+}
+
+func TestBackend_PurgeDeletedUploadsACL(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{ACL: []string{"user:local:bob"}}
+	createUpload(t, b, upload)
+
+	err := b.DeleteUpload(upload.ID)
+	require.NoError(t, err, "delete upload error")
+
+	removed, err := b.PurgeDeletedUploads(0)
+	require.NoError(t, err, "purge deleted uploads error")
+	require.Equal(t, 1, removed, "invalid purged uploads count")
+
+	acl, err := b.GetUploadACL(upload.ID)
+	require.NoError(t, err, "get upload acl error")
+	require.Len(t, acl, 0, "upload acl has not been purged")
+}
@@ -0,0 +1,56 @@
+package metadata
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// SaveArchiveIndex replace the archive members of a file and save the archive format
+// format is common.ArchiveInvalid if the file could not be indexed
+func (b *Backend) SaveArchiveIndex(file *common.File, format string, members []*common.ArchiveMember) (err error) {
+	return b.db.Transaction(func(tx *gorm.DB) (err error) {
+		err = tx.Where(&common.ArchiveMember{FileID: file.ID}).Delete(&common.ArchiveMember{}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, member := range members {
+			member.FileID = file.ID
+			err = tx.Create(member).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.Model(&common.File{}).Where(&common.File{ID: file.ID}).UpdateColumn("archive", format).Error
+		if err != nil {
+			return err
+		}
+
+		file.Archive = format
+		return nil
+	})
+}
+
+// GetArchiveMembers return the indexed members of an archive file
+func (b *Backend) GetArchiveMembers(fileID string) (members []*common.ArchiveMember, err error) {
+	err = b.db.Where(&common.ArchiveMember{FileID: fileID}).Order("member_index").Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// GetArchiveMember return an indexed member of an archive file ( return nil and no error if not found )
+func (b *Backend) GetArchiveMember(fileID string, index int) (member *common.ArchiveMember, err error) {
+	member = &common.ArchiveMember{}
+	err = b.db.Where("file_id = ? AND member_index = ?", fileID, index).Take(member).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
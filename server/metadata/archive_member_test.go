@@ -0,0 +1,69 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestBackend_SaveArchiveIndex(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "archive.zip"
+	createUpload(t, b, upload)
+
+	members := []*common.ArchiveMember{
+		{Index: 1, Name: "foo/bar", Size: 42},
+		{Index: 0, Name: "foo", Size: 12},
+	}
+	err := b.SaveArchiveIndex(file, "zip", members)
+	require.NoError(t, err, "save archive index error")
+	require.Equal(t, "zip", file.Archive, "invalid file archive")
+
+	result, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, "zip", result.Archive, "invalid file archive")
+
+	members, err = b.GetArchiveMembers(file.ID)
+	require.NoError(t, err, "get archive members error")
+	require.Len(t, members, 2, "invalid member count")
+	require.Equal(t, "foo", members[0].Name, "invalid member order")
+	require.Equal(t, "foo/bar", members[1].Name, "invalid member order")
+
+	// Index again
+	err = b.SaveArchiveIndex(file, common.ArchiveInvalid, nil)
+	require.NoError(t, err, "save archive index error")
+
+	members, err = b.GetArchiveMembers(file.ID)
+	require.NoError(t, err, "get archive members error")
+	require.Len(t, members, 0, "invalid member count")
+
+	result, err = b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, common.ArchiveInvalid, result.Archive, "invalid file archive")
+}
+
+func TestBackend_GetArchiveMember(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	createUpload(t, b, upload)
+
+	err := b.SaveArchiveIndex(file, "tar", []*common.ArchiveMember{{Index: 0, Name: "foo", Size: 12, Offset: 512}})
+	require.NoError(t, err, "save archive index error")
+
+	member, err := b.GetArchiveMember(file.ID, 0)
+	require.NoError(t, err, "get archive member error")
+	require.NotNil(t, member, "missing archive member")
+	require.Equal(t, "foo", member.Name, "invalid member name")
+	require.Equal(t, int64(512), member.Offset, "invalid member offset")
+
+	member, err = b.GetArchiveMember(file.ID, 1)
+	require.NoError(t, err, "get archive member error")
+	require.Nil(t, member, "unexpected archive member")
+}
@@ -0,0 +1,99 @@
+package metadata
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// GetAuthFailure return the auth failure record for key ( return nil and no error if not found )
+func (b *Backend) GetAuthFailure(key string) (failure *common.AuthFailure, err error) {
+	failure = &common.AuthFailure{}
+
+	err = b.db.Take(failure, &common.AuthFailure{Key: key}).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return failure, nil
+}
+
+// IncrementAuthFailure atomically increment the failure count for key and update the lockout deadline
+func (b *Backend) IncrementAuthFailure(key string, threshold int, duration time.Duration, maxDuration time.Duration) (failure *common.AuthFailure, err error) {
+	err = b.db.Transaction(func(tx *gorm.DB) (err error) {
+		result := tx.Model(&common.AuthFailure{}).Where(&common.AuthFailure{Key: key}).Updates(map[string]interface{}{
+			"count":      gorm.Expr("count + 1"),
+			"updated_at": time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			failure = &common.AuthFailure{Key: key, Count: 1}
+		} else {
+			failure = &common.AuthFailure{}
+			err = tx.Take(failure, &common.AuthFailure{Key: key}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		failure.Lock(threshold, duration, maxDuration)
+
+		return tx.Save(failure).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return failure, nil
+}
+
+// DeleteAuthFailure remove the auth failure record for key, this clears any lockout
+func (b *Backend) DeleteAuthFailure(key string) (deleted bool, err error) {
+	result := b.db.Delete(&common.AuthFailure{Key: key})
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to delete auth failure metadata")
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// PurgeAuthFailures delete unlocked auth failure records that have not been updated since the deadline
+func (b *Backend) PurgeAuthFailures(deadline time.Time) (removed int, err error) {
+	result := b.db.Where("updated_at < ?", deadline).Where("locked_until IS NULL OR locked_until < ?", time.Now()).Delete(&common.AuthFailure{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+// ForEachAuthFailure execute f for every auth failure record in the database
+func (b *Backend) ForEachAuthFailure(f func(failure *common.AuthFailure) error) (err error) {
+	rows, err := b.db.Model(&common.AuthFailure{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		failure := &common.AuthFailure{}
+		err = b.db.ScanRows(rows, failure)
+		if err != nil {
+			return err
+		}
+		err = f(failure)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package metadata
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestBackend_IncrementAuthFailure(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	failure, err := b.GetAuthFailure("key")
+	require.NoError(t, err, "get auth failure error")
+	require.Nil(t, failure, "unexpected auth failure")
+
+	for i := 1; i <= 3; i++ {
+		failure, err = b.IncrementAuthFailure("key", 3, time.Minute, time.Hour)
+		require.NoError(t, err, "increment auth failure error")
+		require.Equal(t, i, failure.Count, "invalid auth failure count")
+	}
+	require.True(t, failure.IsLocked(), "missing lockout")
+
+	failure, err = b.GetAuthFailure("key")
+	require.NoError(t, err, "get auth failure error")
+	require.NotNil(t, failure, "missing auth failure")
+	require.Equal(t, 3, failure.Count, "invalid auth failure count")
+	require.True(t, failure.IsLocked(), "missing lockout")
+}
+
+func TestBackend_DeleteAuthFailure(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	_, err := b.IncrementAuthFailure("key", 3, time.Minute, time.Hour)
+	require.NoError(t, err, "increment auth failure error")
+
+	deleted, err := b.DeleteAuthFailure("key")
+	require.NoError(t, err, "delete auth failure error")
+	require.True(t, deleted, "auth failure not deleted")
+
+	deleted, err = b.DeleteAuthFailure("key")
+	require.NoError(t, err, "delete auth failure error")
+	require.False(t, deleted, "auth failure deleted twice")
+
+	failure, err := b.GetAuthFailure("key")
+	require.NoError(t, err, "get auth failure error")
+	require.Nil(t, failure, "unexpected auth failure")
+}
+
+func TestBackend_PurgeAuthFailures(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	_, err := b.IncrementAuthFailure("failing", 3, time.Minute, time.Hour)
+	require.NoError(t, err, "increment auth failure error")
+
+	for i := 0; i < 3; i++ {
+		_, err = b.IncrementAuthFailure("locked", 3, time.Minute, time.Hour)
+		require.NoError(t, err, "increment auth failure error")
+	}
+
+	removed, err := b.PurgeAuthFailures(time.Now().Add(-time.Hour))
+	require.NoError(t, err, "purge auth failures error")
+	require.Equal(t, 0, removed, "invalid purged auth failures count")
+
+	removed, err = b.PurgeAuthFailures(time.Now().Add(time.Second))
+	require.NoError(t, err, "purge auth failures error")
+	require.Equal(t, 1, removed, "invalid purged auth failures count")
+
+	failure, err := b.GetAuthFailure("locked")
+	require.NoError(t, err, "get auth failure error")
+	require.NotNil(t, failure, "locked auth failure should not be purged")
+}
+
+func TestBackend_ForEachAuthFailure(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	_, err := b.IncrementAuthFailure("key", 3, time.Minute, time.Hour)
+	require.NoError(t, err, "increment auth failure error")
+
+	count := 0
+	f := func(failure *common.AuthFailure) error {
+		count++
+		require.Equal(t, "key", failure.Key, "invalid auth failure key")
+		return nil
+	}
+	err = b.ForEachAuthFailure(f)
+	require.NoError(t, err, "for each auth failure error")
+	require.Equal(t, 1, count, "invalid auth failure count")
+
+	f = func(failure *common.AuthFailure) error {
+		return fmt.Errorf("expected")
+	}
+	err = b.ForEachAuthFailure(f)
+	require.Errorf(t, err, "expected")
+}
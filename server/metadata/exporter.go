@@ -19,6 +19,15 @@ const (
 	metadataTypeUser
 	metadataTypeToken
 	metadataTypeSetting
+	metadataTypeGroup
+	metadataTypeGroupMember
+	metadataTypeGroupToken
+	metadataTypeUploadRequest
+	metadataTypeReport
+	metadataTypeBlockedHash
+	metadataTypeWebhook
+	metadataTypeFileVersion
+	metadataTypeUploadACL
 )
 
 type object struct {
@@ -50,6 +59,15 @@ func newExporter(path string) (e *exporter, err error) {
 	gob.Register(&common.User{})
 	gob.Register(&common.Token{})
 	gob.Register(&common.Setting{})
+	gob.Register(&common.Group{})
+	gob.Register(&common.GroupMember{})
+	gob.Register(&common.GroupToken{})
+	gob.Register(&common.UploadRequest{})
+	gob.Register(&common.Report{})
+	gob.Register(&common.BlockedHash{})
+	gob.Register(&common.Webhook{})
+	gob.Register(&common.FileVersion{})
+	gob.Register(&common.UploadACL{})
 	e.encoder = gob.NewEncoder(e.compressor)
 
 	return e, nil
@@ -80,6 +98,51 @@ func (e *exporter) addSetting(setting *common.Setting) (err error) {
 	return e.encoder.Encode(obj)
 }
 
+func (e *exporter) addGroup(group *common.Group) (err error) {
+	obj := &object{Type: metadataTypeGroup, Object: group}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addGroupMember(member *common.GroupMember) (err error) {
+	obj := &object{Type: metadataTypeGroupMember, Object: member}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addGroupToken(token *common.GroupToken) (err error) {
+	obj := &object{Type: metadataTypeGroupToken, Object: token}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addUploadRequest(request *common.UploadRequest) (err error) {
+	obj := &object{Type: metadataTypeUploadRequest, Object: request}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addReport(report *common.Report) (err error) {
+	obj := &object{Type: metadataTypeReport, Object: report}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addBlockedHash(hash *common.BlockedHash) (err error) {
+	obj := &object{Type: metadataTypeBlockedHash, Object: hash}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addWebhook(webhook *common.Webhook) (err error) {
+	obj := &object{Type: metadataTypeWebhook, Object: webhook}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addFileVersion(version *common.FileVersion) (err error) {
+	obj := &object{Type: metadataTypeFileVersion, Object: version}
+	return e.encoder.Encode(obj)
+}
+
+func (e *exporter) addUploadACL(entry *common.UploadACL) (err error) {
+	obj := &object{Type: metadataTypeUploadACL, Object: entry}
+	return e.encoder.Encode(obj)
+}
+
 func (e *exporter) close() (err error) {
 	err = e.compressor.Close()
 	if err != nil {
@@ -121,6 +184,46 @@ func (b *Backend) Export(path string) (err error) {
 	}
 	fmt.Printf("exported %d tokens\n", count)
 
+	count = 0
+	err = b.ForEachGroup(func(group *common.Group) error {
+		count++
+		return e.addGroup(group)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d groups\n", count)
+
+	count = 0
+	err = b.ForEachGroupMember(func(member *common.GroupMember) error {
+		count++
+		return e.addGroupMember(member)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d group members\n", count)
+
+	count = 0
+	err = b.ForEachGroupToken(func(token *common.GroupToken) error {
+		count++
+		return e.addGroupToken(token)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d group tokens\n", count)
+
+	count = 0
+	err = b.ForEachUploadRequest(func(request *common.UploadRequest) error {
+		count++
+		return e.addUploadRequest(request)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d upload requests\n", count)
+
 	count = 0
 	err = b.ForEachUpload(func(upload *common.Upload) error {
 		count++
@@ -131,6 +234,16 @@ func (b *Backend) Export(path string) (err error) {
 	}
 	fmt.Printf("exported %d uploads\n", count)
 
+	count = 0
+	err = b.ForEachUploadACL(func(entry *common.UploadACL) error {
+		count++
+		return e.addUploadACL(entry)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d upload acl entries\n", count)
+
 	count = 0
 	err = b.ForEachFile(func(file *common.File) error {
 		count++
@@ -141,6 +254,16 @@ func (b *Backend) Export(path string) (err error) {
 	}
 	fmt.Printf("exported %d files\n", count)
 
+	count = 0
+	err = b.ForEachFileVersion(func(version *common.FileVersion) error {
+		count++
+		return e.addFileVersion(version)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d file versions\n", count)
+
 	count = 0
 	err = b.ForEachSetting(func(setting *common.Setting) error {
 		count++
@@ -151,5 +274,35 @@ func (b *Backend) Export(path string) (err error) {
 	}
 	fmt.Printf("exported %d settings\n", count)
 
+	count = 0
+	err = b.ForEachReport(func(report *common.Report) error {
+		count++
+		return e.addReport(report)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d reports\n", count)
+
+	count = 0
+	err = b.ForEachBlockedHash(func(hash *common.BlockedHash) error {
+		count++
+		return e.addBlockedHash(hash)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d blocked hashes\n", count)
+
+	count = 0
+	err = b.ForEachWebhook(func(webhook *common.Webhook) error {
+		count++
+		return e.addWebhook(webhook)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d webhooks\n", count)
+
 	return nil
 }
@@ -14,13 +14,27 @@ func createMetadata(t *testing.T, b *Backend) {
 	createUser(t, b, user)
 
 	upload := &common.Upload{}
-	upload.NewFile()
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
 	upload.User = user.ID
 	upload.Token = user.Tokens[0].Token
+	upload.ACL = []string{common.ACLUserSubject("other")}
 	createUpload(t, b, upload)
 
+	err := b.AddFileVersion(file, &common.File{DataID: common.GenerateRandomID(16)}, 0)
+	require.NoError(t, err)
+
+	group := common.NewGroup("team")
+	createGroup(t, b, group)
+
+	err = b.SaveGroupMember(&common.GroupMember{GroupID: group.ID, UserID: user.ID, Role: common.GroupRoleManager})
+	require.NoError(t, err)
+
+	err = b.CreateGroupToken(group.NewToken())
+	require.NoError(t, err)
+
 	setting := &common.Setting{Key: "foo", Value: "bar"}
-	err := b.CreateSetting(setting)
+	err = b.CreateSetting(setting)
 	require.NoError(t, err)
 }
 
@@ -35,4 +49,25 @@ func TestBackend_Export(t *testing.T) {
 	b = newTestMetadataBackend()
 	err = b.Import(path)
 	require.NoError(t, err, "import error %s", err)
+
+	count := 0
+	err = b.ForEachFileVersion(func(version *common.FileVersion) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err, "for each file version error %s", err)
+	require.Equal(t, 1, count, "invalid file version count")
+
+	var entries []*common.UploadACL
+	err = b.ForEachUploadACL(func(entry *common.UploadACL) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	require.NoError(t, err, "for each upload acl error %s", err)
+	require.Len(t, entries, 1, "invalid upload acl count")
+	require.Equal(t, common.ACLUserSubject("other"), entries[0].Subject, "invalid upload acl subject")
+
+	acl, err := b.GetUploadACL(entries[0].UploadID)
+	require.NoError(t, err, "get upload acl error %s", err)
+	require.Equal(t, []string{common.ACLUserSubject("other")}, acl, "invalid upload acl")
 }
@@ -36,8 +36,9 @@ func (b *Backend) GetFiles(uploadID string) (files []*common.File, err error) {
 }
 
 // UpdateFile update a file in DB. Status ensure the file status has not changed since loaded
+// and the version ensure a new version of the file has not been uploaded in the meantime
 func (b *Backend) UpdateFile(file *common.File, status string) error {
-	result := b.db.Where(&common.File{ID: file.ID, Status: status}).Save(file)
+	result := b.db.Where(&common.File{ID: file.ID, Status: status}).Where("version = ?", file.Version).Save(file)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -63,14 +64,108 @@ func (b *Backend) UpdateFileStatus(file *common.File, oldStatus string, newStatu
 	return nil
 }
 
+// UpdateFilePreview save the kind of preview generated for an uploaded file
+// Fails if the file has been removed in the meantime so the caller can remove the orphan preview
+func (b *Backend) UpdateFilePreview(file *common.File, preview string) error {
+	result := b.db.Model(&common.File{}).Where(&common.File{ID: file.ID, Status: common.FileUploaded}).UpdateColumn("preview", preview)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("%s file not found", common.FileUploaded)
+	}
+
+	file.Preview = preview
+
+	return nil
+}
+
+// UpdateFileFetchProgress save the number of bytes of the file fetched so far and the size announced by the remote server
+func (b *Backend) UpdateFileFetchProgress(file *common.File, size int64, fetchSize int64) error {
+	result := b.db.Model(&common.File{}).Where(&common.File{ID: file.ID, Status: common.FileUploading}).UpdateColumns(map[string]interface{}{"size": size, "fetch_size": fetchSize})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("%s file not found", common.FileUploading)
+	}
+
+	file.Size = size
+	file.FetchSize = fetchSize
+
+	return nil
+}
+
+// FailFileFetch save the reason of a server-side fetch failure and change the file status to removed
+// so the data already fetched is deleted from the data backend by the server
+func (b *Backend) FailFileFetch(file *common.File, fetchError string) error {
+	result := b.db.Model(&common.File{}).Where(&common.File{ID: file.ID, Status: common.FileUploading}).UpdateColumns(map[string]interface{}{"status": common.FileRemoved, "fetch_error": fetchError})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("%s file not found", common.FileUploading)
+	}
+
+	file.Status = common.FileRemoved
+	file.FetchError = fetchError
+
+	return nil
+}
+
+// IncrementFileDownloads atomically count a download of an uploaded file
+// The file status is changed to removed once the download cap is reached
+// Return false if the file is not available anymore
+func (b *Backend) IncrementFileDownloads(file *common.File, maxDownloads int) (ok bool, err error) {
+	err = b.db.Transaction(func(tx *gorm.DB) (err error) {
+		result := tx.Model(&common.File{}).
+			Where("id = ?", file.ID).
+			Where("status = ?", common.FileUploaded).
+			Where("downloads < ?", maxDownloads).
+			UpdateColumn("downloads", gorm.Expr("downloads + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		err = tx.Model(&common.File{}).
+			Where("id = ?", file.ID).
+			Where("downloads >= ?", maxDownloads).
+			UpdateColumn("status", common.FileRemoved).Error
+		if err != nil {
+			return err
+		}
+
+		ok = true
+		return nil
+	})
+	if err != nil || !ok {
+		return false, err
+	}
+
+	file.Downloads++
+	if file.Downloads >= maxDownloads {
+		file.Status = common.FileRemoved
+	}
+
+	return true, nil
+}
+
 // RemoveFile change the file status to removed
 // The file will then be deleted from the data backend by the server and the status changed to deleted.
+// The previous versions of the file are removed as well.
 func (b *Backend) RemoveFile(file *common.File) error {
 	switch file.Status {
 	case common.FileMissing, common.FileUploading, "":
 		return b.UpdateFileStatus(file, file.Status, common.FileDeleted)
-	case common.FileUploaded:
-		return b.UpdateFileStatus(file, file.Status, common.FileRemoved)
+	case common.FileUploaded, common.FileScanning, common.FileQuarantined:
+		err := b.UpdateFileStatus(file, file.Status, common.FileRemoved)
+		if err != nil {
+			return err
+		}
+		return b.removeFileVersions(file.ID)
 	//case common.FileRemoved, common.FileDeleted:
 	//	return nil
 	default:
@@ -102,8 +197,37 @@ func (b *Backend) ForEachUploadFiles(uploadID string, f func(file *common.File)
 }
 
 // ForEachRemovedFile execute f for each file with the status "removed"
-func (b *Backend) ForEachRemovedFile(f func(file *common.File) error) (err error) {
-	rows, err := b.db.Model(&common.File{}).Where(&common.File{Status: common.FileRemoved}).Rows()
+// limit is the maximum number of files to iterate over, 0 means no limit
+func (b *Backend) ForEachRemovedFile(limit int, f func(file *common.File) error) (err error) {
+	stmt := b.db.Model(&common.File{}).Where(&common.File{Status: common.FileRemoved})
+	if limit > 0 {
+		stmt = stmt.Limit(limit)
+	}
+
+	rows, err := stmt.Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		file := &common.File{}
+		err = b.db.ScanRows(rows, file)
+		if err != nil {
+			return err
+		}
+		err = f(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForEachFileWithStatus execute f for each file having one of the given statuses
+func (b *Backend) ForEachFileWithStatus(statuses []string, f func(file *common.File) error) (err error) {
+	rows, err := b.db.Model(&common.File{}).Where("status IN (?)", statuses).Rows()
 	if err != nil {
 		return err
 	}
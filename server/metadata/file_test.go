@@ -122,6 +122,103 @@ func TestBackend_UpdateFileStatus(t *testing.T) {
 	require.Error(t, err, "update file status error expected")
 }
 
+func TestBackend_UpdateFilePreview(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	createUpload(t, b, upload)
+
+	err := b.UpdateFilePreview(file, common.PreviewImage)
+	require.NoError(t, err, "update file preview error")
+	require.Equal(t, common.PreviewImage, file.Preview, "invalid file preview")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, common.PreviewImage, f.Preview, "invalid file preview")
+
+	err = b.RemoveFile(file)
+	require.NoError(t, err, "remove file error")
+
+	err = b.UpdateFilePreview(file, common.PreviewText)
+	require.Error(t, err, "update file preview error expected")
+}
+
+func TestBackend_UpdateFileFetchProgress(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploading
+	createUpload(t, b, upload)
+
+	err := b.UpdateFileFetchProgress(file, 42, 100)
+	require.NoError(t, err, "update file fetch progress error")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, int64(42), f.Size, "invalid file size")
+	require.Equal(t, int64(100), f.FetchSize, "invalid file fetch size")
+
+	err = b.UpdateFileStatus(file, common.FileUploading, common.FileUploaded)
+	require.NoError(t, err, "update file status error")
+
+	err = b.UpdateFileFetchProgress(file, 100, 100)
+	require.Error(t, err, "update file fetch progress error expected")
+}
+
+func TestBackend_FailFileFetch(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploading
+	createUpload(t, b, upload)
+
+	err := b.FailFileFetch(file, "fetch error")
+	require.NoError(t, err, "fail file fetch error")
+	require.Equal(t, common.FileRemoved, file.Status, "invalid file status")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
+	require.Equal(t, "fetch error", f.FetchError, "invalid file fetch error")
+
+	err = b.FailFileFetch(file, "fetch error")
+	require.Error(t, err, "fail file fetch error expected")
+}
+
+func TestBackend_IncrementFileDownloads(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	createUpload(t, b, upload)
+
+	ok, err := b.IncrementFileDownloads(file, 2)
+	require.NoError(t, err, "increment file downloads error")
+	require.True(t, ok, "file should be available")
+	require.Equal(t, 1, file.Downloads, "invalid file downloads")
+	require.Equal(t, common.FileUploaded, file.Status, "invalid file status")
+
+	ok, err = b.IncrementFileDownloads(file, 2)
+	require.NoError(t, err, "increment file downloads error")
+	require.True(t, ok, "file should be available")
+	require.Equal(t, 2, file.Downloads, "invalid file downloads")
+	require.Equal(t, common.FileRemoved, file.Status, "invalid file status")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, 2, f.Downloads, "invalid file downloads")
+	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
+
+	ok, err = b.IncrementFileDownloads(f, 2)
+	require.NoError(t, err, "increment file downloads error")
+	require.False(t, ok, "file should not be available")
+}
+
 func TestBackend_RemoveFile(t *testing.T) {
 	b := newTestMetadataBackend()
 
@@ -194,17 +291,44 @@ func TestBackend_ForEachRemovedFiles(t *testing.T) {
 		return nil
 	}
 
-	err := b.ForEachRemovedFile(f)
+	err := b.ForEachRemovedFile(0, f)
 	require.NoError(t, err, "for each upload file error")
 	require.Len(t, files, 2, "file count mismatch")
 
 	f = func(file *common.File) error {
 		return fmt.Errorf("expected")
 	}
-	err = b.ForEachRemovedFile(f)
+	err = b.ForEachRemovedFile(0, f)
 	require.Error(t, err, "for each upload file error expected")
 }
 
+func TestBackend_ForEachFileWithStatus(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	upload.NewFile().Status = common.FileUploaded
+	upload.NewFile().Status = common.FileScanning
+	upload.NewFile().Status = common.FileQuarantined
+	upload.NewFile().Status = common.FileRemoved
+	createUpload(t, b, upload)
+
+	var files []*common.File
+	f := func(file *common.File) error {
+		files = append(files, file)
+		return nil
+	}
+
+	err := b.ForEachFileWithStatus([]string{common.FileScanning, common.FileQuarantined}, f)
+	require.NoError(t, err, "for each file with status error")
+	require.Len(t, files, 2, "file count mismatch")
+
+	f = func(file *common.File) error {
+		return fmt.Errorf("expected")
+	}
+	err = b.ForEachFileWithStatus([]string{common.FileUploaded}, f)
+	require.Error(t, err, "for each file with status error expected")
+}
+
 func TestBackend_CountUploadFiles(t *testing.T) {
 	b := newTestMetadataBackend()
 
@@ -0,0 +1,183 @@
+package metadata
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// AddFileVersion replace the data of an uploaded file by a new version and keep the current data as a previous version
+// version contains the data key and the information of the new version data
+// Only the maxVersions most recent previous versions are kept, the older ones are removed, 0 means no limit
+func (b *Backend) AddFileVersion(file *common.File, version *common.File, maxVersions int) (err error) {
+	previous := common.NewFileVersion(file)
+	now := time.Now()
+
+	err = b.db.Transaction(func(tx *gorm.DB) (err error) {
+		err = tx.Create(previous).Error
+		if err != nil {
+			return err
+		}
+
+		// The version number ensure the file has not been updated since loaded
+		result := tx.Model(&common.File{}).Where("id = ? AND status = ? AND version = ?", file.ID, common.FileUploaded, file.Version).Updates(map[string]interface{}{
+			"data_id":            version.DataID,
+			"version":            previous.Version + 1,
+			"version_created_at": now,
+			"md5":                version.Md5,
+			"type":               version.Type,
+			"size":               version.Size,
+			"metadata_stripped":  version.MetadataStripped,
+			"archive":            "",
+			"preview":            "",
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(1) {
+			return fmt.Errorf("file has been updated in the meantime")
+		}
+
+		// The archive members of the previous version are indexed again from the new version
+		err = tx.Where(&common.ArchiveMember{FileID: file.ID}).Delete(&common.ArchiveMember{}).Error
+		if err != nil {
+			return err
+		}
+
+		if maxVersions > 0 {
+			var versions []string
+			err = tx.Model(&common.FileVersion{}).Where(&common.FileVersion{FileID: file.ID, Status: common.FileUploaded}).
+				Order("version desc").Pluck("id", &versions).Error
+			if err != nil {
+				return err
+			}
+
+			if len(versions) > maxVersions {
+				err = tx.Model(&common.FileVersion{}).Where("id IN (?)", versions[maxVersions:]).Update("status", common.FileRemoved).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	file.DataID = version.DataID
+	file.Version = previous.Version + 1
+	file.VersionCreatedAt = &now
+	file.Md5 = version.Md5
+	file.Type = version.Type
+	file.Size = version.Size
+	file.MetadataStripped = version.MetadataStripped
+	file.Archive = ""
+	file.Preview = ""
+
+	return nil
+}
+
+// CreateFileVersion persist a previous version of a file to the database
+func (b *Backend) CreateFileVersion(version *common.FileVersion) (err error) {
+	return b.db.Create(version).Error
+}
+
+// GetFileVersion return a previous version of a file ( nil and no error if not found )
+func (b *Backend) GetFileVersion(fileID string, version int) (fileVersion *common.FileVersion, err error) {
+	fileVersion = &common.FileVersion{}
+	err = b.db.Where(&common.FileVersion{FileID: fileID, Version: version}).Take(fileVersion).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return fileVersion, nil
+}
+
+// GetUploadFileVersions return the previous versions of the upload files that can still be downloaded, most recent first
+func (b *Backend) GetUploadFileVersions(uploadID string) (versions []*common.FileVersion, err error) {
+	err = b.db.Where(&common.FileVersion{UploadID: uploadID, Status: common.FileUploaded}).Order("version desc").Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// UpdateFileVersionStatus update a file version status in DB. oldStatus ensure the status has not changed since loaded
+func (b *Backend) UpdateFileVersionStatus(version *common.FileVersion, oldStatus string, newStatus string) error {
+	result := b.db.Model(&common.FileVersion{}).Where(&common.FileVersion{ID: version.ID, Status: oldStatus}).Update(&common.FileVersion{Status: newStatus})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("%s file version not found", oldStatus)
+	}
+
+	version.Status = newStatus
+
+	return nil
+}
+
+// removeFileVersions change the status of the previous versions of a file to removed
+// They will then be deleted from the data backend by the server and their status changed to deleted.
+func (b *Backend) removeFileVersions(fileID string) error {
+	return b.db.Model(&common.FileVersion{}).Where(&common.FileVersion{FileID: fileID, Status: common.FileUploaded}).Update("status", common.FileRemoved).Error
+}
+
+// ForEachRemovedFileVersion execute f for each file version with the status "removed"
+// limit is the maximum number of file versions to iterate over, 0 means no limit
+func (b *Backend) ForEachRemovedFileVersion(limit int, f func(version *common.FileVersion) error) (err error) {
+	stmt := b.db.Model(&common.FileVersion{}).Where(&common.FileVersion{Status: common.FileRemoved})
+	if limit > 0 {
+		stmt = stmt.Limit(limit)
+	}
+
+	rows, err := stmt.Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		version := &common.FileVersion{}
+		err = b.db.ScanRows(rows, version)
+		if err != nil {
+			return err
+		}
+		err = f(version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForEachFileVersion execute f for every file version in the database
+func (b *Backend) ForEachFileVersion(f func(version *common.FileVersion) error) (err error) {
+	rows, err := b.db.Model(&common.FileVersion{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		version := &common.FileVersion{}
+		err = b.db.ScanRows(rows, version)
+		if err != nil {
+			return err
+		}
+		err = f(version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func addTestFileVersion(t *testing.T, b *Backend, file *common.File, size int64, maxVersions int) {
+	version := &common.File{DataID: common.GenerateRandomID(16), Size: size, Md5: "md5", Type: "text/plain"}
+	err := b.AddFileVersion(file, version, maxVersions)
+	require.NoError(t, err, "unable to add file version")
+}
+
+func TestBackend_AddFileVersion(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	file.Size = 1
+	file.Archive = common.ArchiveInvalid
+	createUpload(t, b, upload)
+
+	addTestFileVersion(t, b, file, 2, 0)
+	require.Equal(t, 2, file.Version, "invalid file version")
+	require.Equal(t, int64(2), file.Size, "invalid file size")
+	require.NotEqual(t, file.ID, file.GetDataID(), "invalid file data id")
+	require.NotNil(t, file.VersionCreatedAt, "missing version creation date")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, 2, f.Version, "invalid file version")
+	require.Equal(t, file.DataID, f.DataID, "invalid file data id")
+	require.Equal(t, int64(2), f.Size, "invalid file size")
+	require.Empty(t, f.Archive, "archive index should be reset")
+
+	version, err := b.GetFileVersion(file.ID, 1)
+	require.NoError(t, err, "get file version error")
+	require.NotNil(t, version, "missing file version")
+	require.Equal(t, file.ID, version.DataID, "invalid version data id")
+	require.Equal(t, int64(1), version.Size, "invalid version size")
+	require.Equal(t, common.FileUploaded, version.Status, "invalid version status")
+
+	version, err = b.GetFileVersion(file.ID, 2)
+	require.NoError(t, err, "get file version error")
+	require.Nil(t, version, "the current version is not a previous version")
+}
+
+func TestBackend_AddFileVersion_Updated(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	createUpload(t, b, upload)
+
+	stale := *file
+	addTestFileVersion(t, b, file, 2, 0)
+
+	err := b.AddFileVersion(&stale, &common.File{DataID: common.GenerateRandomID(16)}, 0)
+	require.EqualError(t, err, "file has been updated in the meantime")
+
+	versions, err := b.GetUploadFileVersions(upload.ID)
+	require.NoError(t, err, "get file versions error")
+	require.Len(t, versions, 1, "the failed version should have been rolled back")
+}
+
+func TestBackend_AddFileVersion_MaxVersions(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	createUpload(t, b, upload)
+
+	for i := 0; i < 4; i++ {
+		addTestFileVersion(t, b, file, 1, 2)
+	}
+	require.Equal(t, 5, file.Version, "invalid file version")
+
+	versions, err := b.GetUploadFileVersions(upload.ID)
+	require.NoError(t, err, "get file versions error")
+	require.Len(t, versions, 2, "invalid version count")
+	require.Equal(t, 4, versions[0].Version, "invalid version order")
+	require.Equal(t, 3, versions[1].Version, "invalid version order")
+
+	var removed []int
+	err = b.ForEachRemovedFileVersion(0, func(version *common.FileVersion) error {
+		removed = append(removed, version.Version)
+		return b.UpdateFileVersionStatus(version, common.FileRemoved, common.FileDeleted)
+	})
+	require.NoError(t, err, "for each removed file version error")
+	require.ElementsMatch(t, []int{1, 2}, removed, "invalid removed versions")
+}
+
+func TestBackend_RemoveFile_Versions(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	createUpload(t, b, upload)
+
+	addTestFileVersion(t, b, file, 1, 0)
+
+	err := b.RemoveFile(file)
+	require.NoError(t, err, "remove file error")
+
+	version, err := b.GetFileVersion(file.ID, 1)
+	require.NoError(t, err, "get file version error")
+	require.Equal(t, common.FileRemoved, version.Status, "invalid version status")
+}
+
+func TestBackend_FileVersions_Statistics(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	file.Size = 1
+	createUpload(t, b, upload)
+
+	addTestFileVersion(t, b, file, 2, 0)
+	addTestFileVersion(t, b, file, 4, 0)
+
+	// Every version counts in the storage used but not in the file count
+	uploads, files, totalSize, err := b.GetUploadStatistics(nil, nil)
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, 1, uploads, "invalid upload count")
+	require.Equal(t, 1, files, "invalid file count")
+	require.Equal(t, int64(7), totalSize, "invalid file size")
+
+	uploads, files, totalSize, err = b.getUploadStatistics(map[string]string{"id": upload.ID})
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, int64(7), totalSize, "invalid file size")
+
+	searched, _, err := b.SearchUploads(&common.UploadFilter{MinSize: 7}, false, &common.PagingQuery{})
+	require.NoError(t, err, "search uploads error")
+	require.Len(t, searched, 1, "upload size should include the file versions")
+}
+
+func TestBackend_PurgeDeletedUploads_Versions(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	createUpload(t, b, upload)
+
+	addTestFileVersion(t, b, file, 1, 0)
+
+	err := b.DeleteUpload(upload.ID)
+	require.NoError(t, err, "delete upload error")
+
+	err = b.UpdateFileStatus(file, common.FileRemoved, common.FileDeleted)
+	require.NoError(t, err, "update file status error")
+
+	// The upload is kept until the previous versions are deleted from the data backend
+	removed, err := b.PurgeDeletedUploads(0)
+	require.NoError(t, err, "purge deleted uploads error")
+	require.Equal(t, 0, removed, "invalid removed upload count")
+
+	version, err := b.GetFileVersion(file.ID, 1)
+	require.NoError(t, err, "get file version error")
+	err = b.UpdateFileVersionStatus(version, common.FileRemoved, common.FileDeleted)
+	require.NoError(t, err, "update file version status error")
+
+	removed, err = b.PurgeDeletedUploads(0)
+	require.NoError(t, err, "purge deleted uploads error")
+	require.Equal(t, 1, removed, "invalid removed upload count")
+
+	version, err = b.GetFileVersion(file.ID, 1)
+	require.NoError(t, err, "get file version error")
+	require.Nil(t, version, "file version should have been purged")
+}
+
+func TestBackend_UpdateFile_Versioned(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	createUpload(t, b, upload)
+
+	stale := *file
+	addTestFileVersion(t, b, file, 2, 0)
+
+	// A file loaded before the new version must not overwrite it
+	stale.Virus = "virus"
+	err := b.UpdateFile(&stale, common.FileUploaded)
+	require.EqualError(t, err, "invalid file status")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, file.DataID, f.DataID, "invalid file data id")
+	require.Empty(t, f.Virus, "invalid file virus")
+}
@@ -0,0 +1,321 @@
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	paginator "github.com/pilagod/gorm-cursor-paginator"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CreateGroup create a new group in DB
+func (b *Backend) CreateGroup(group *common.Group) (err error) {
+	return b.db.Create(group).Error
+}
+
+// UpdateGroup update group info in DB
+func (b *Backend) UpdateGroup(group *common.Group) (err error) {
+	result := b.db.Save(group)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("no group updated")
+	}
+
+	return nil
+}
+
+// GetGroup return a group from DB ( return nil and no error if not found )
+func (b *Backend) GetGroup(ID string) (group *common.Group, err error) {
+	group = &common.Group{}
+	err = b.db.Where(&common.Group{ID: ID}).Take(group).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return group, err
+}
+
+// GetGroupByName return a group from DB ( return nil and no error if not found )
+func (b *Backend) GetGroupByName(name string) (group *common.Group, err error) {
+	group = &common.Group{}
+	err = b.db.Where(&common.Group{Name: name}).Take(group).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return group, err
+}
+
+// GetUserGroups return all the groups a user is a member of with the role of the user in each group
+func (b *Backend) GetUserGroups(userID string) (groups []*common.Group, err error) {
+	var members []*common.GroupMember
+	err = b.db.Where(&common.GroupMember{UserID: userID}).Order("created_at").Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+
+	groups = []*common.Group{}
+	for _, member := range members {
+		group, err := b.GetGroup(member.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		if group == nil {
+			continue
+		}
+		group.Role = member.Role
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// ForEachGroup execute f for every group in the database
+func (b *Backend) ForEachGroup(f func(group *common.Group) error) (err error) {
+	rows, err := b.db.Model(&common.Group{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		group := &common.Group{}
+		err = b.db.ScanRows(rows, group)
+		if err != nil {
+			return err
+		}
+		err = f(group)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForEachGroupMember execute f for every group member in the database
+func (b *Backend) ForEachGroupMember(f func(member *common.GroupMember) error) (err error) {
+	rows, err := b.db.Model(&common.GroupMember{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		member := &common.GroupMember{}
+		err = b.db.ScanRows(rows, member)
+		if err != nil {
+			return err
+		}
+		err = f(member)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForEachGroupToken execute f for every group token in the database
+func (b *Backend) ForEachGroupToken(f func(token *common.GroupToken) error) (err error) {
+	rows, err := b.db.Model(&common.GroupToken{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		token := &common.GroupToken{}
+		err = b.db.ScanRows(rows, token)
+		if err != nil {
+			return err
+		}
+		err = f(token)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteGroup delete a group, its members, tokens and uploads from the DB
+func (b *Backend) DeleteGroup(groupID string) (deleted bool, err error) {
+	_, err = b.DeleteGroupUploads(groupID)
+	if err != nil {
+		return false, err
+	}
+
+	err = b.db.Transaction(func(tx *gorm.DB) (err error) {
+		err = tx.Where(&common.GroupMember{GroupID: groupID}).Delete(&common.GroupMember{}).Error
+		if err != nil {
+			return fmt.Errorf("unable to delete group members metadata")
+		}
+
+		err = tx.Where(&common.GroupToken{GroupID: groupID}).Delete(&common.GroupToken{}).Error
+		if err != nil {
+			return fmt.Errorf("unable to delete group tokens metadata")
+		}
+
+		result := tx.Delete(&common.Group{ID: groupID})
+		if result.Error != nil {
+			return fmt.Errorf("unable to delete group metadata")
+		}
+
+		deleted = result.RowsAffected > 0
+		return nil
+	})
+
+	return deleted, err
+}
+
+// SaveGroupMember add a user to a group or update the role of an existing member
+func (b *Backend) SaveGroupMember(member *common.GroupMember) (err error) {
+	return b.db.Save(member).Error
+}
+
+// GetGroupMember return the membership of a user in a group ( return nil and no error if not found )
+func (b *Backend) GetGroupMember(groupID string, userID string) (member *common.GroupMember, err error) {
+	member = &common.GroupMember{}
+	err = b.db.Where(&common.GroupMember{GroupID: groupID, UserID: userID}).Take(member).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return member, err
+}
+
+// GetGroupMembers return all the members of a group
+func (b *Backend) GetGroupMembers(groupID string) (members []*common.GroupMember, err error) {
+	members = []*common.GroupMember{}
+	err = b.db.Where(&common.GroupMember{GroupID: groupID}).Order("user_id").Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// DeleteGroupMember remove a user from a group
+func (b *Backend) DeleteGroupMember(groupID string, userID string) (deleted bool, err error) {
+	result := b.db.Where(&common.GroupMember{GroupID: groupID, UserID: userID}).Delete(&common.GroupMember{})
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to delete group member metadata")
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// CreateGroupToken create a new group token in DB
+func (b *Backend) CreateGroupToken(token *common.GroupToken) (err error) {
+	return b.db.Create(token).Error
+}
+
+// GetGroupToken return a group token from the DB ( return nil and non error if not found )
+func (b *Backend) GetGroupToken(tokenStr string) (token *common.GroupToken, err error) {
+	token = &common.GroupToken{}
+	err = b.db.Where(&common.GroupToken{Token: tokenStr}).Take(token).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return token, err
+}
+
+// GetGroupTokens return all tokens of a group
+func (b *Backend) GetGroupTokens(groupID string, pagingQuery *common.PagingQuery) (tokens []*common.GroupToken, cursor *paginator.Cursor, err error) {
+	if pagingQuery == nil {
+		return nil, nil, fmt.Errorf("missing paging query")
+	}
+
+	stmt := b.db.Model(&common.GroupToken{}).Where(&common.GroupToken{GroupID: groupID})
+
+	p := pagingQuery.Paginator()
+	p.SetKeys("CreatedAt", "Token")
+
+	err = p.Paginate(stmt, &tokens).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := p.GetNextCursor()
+	return tokens, &c, err
+}
+
+// DeleteGroupToken remove a group token from the DB
+func (b *Backend) DeleteGroupToken(tokenStr string) (deleted bool, err error) {
+	result := b.db.Delete(&common.GroupToken{Token: tokenStr})
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to delete group token metadata")
+	}
+
+	return result.RowsAffected > 0, err
+}
+
+// GetGroupUploads return the uploads owned by a group
+func (b *Backend) GetGroupUploads(groupID string, withFiles bool, pagingQuery *common.PagingQuery) (uploads []*common.Upload, cursor *paginator.Cursor, err error) {
+	if pagingQuery == nil {
+		return nil, nil, fmt.Errorf("missing paging query")
+	}
+	if groupID == "" {
+		return nil, nil, fmt.Errorf("missing group id")
+	}
+
+	stmt := b.db.Model(&common.Upload{}).Where(&common.Upload{GroupID: groupID})
+
+	if withFiles {
+		stmt = stmt.Preload("Files")
+	}
+
+	p := pagingQuery.Paginator()
+	p.SetKeys("CreatedAt", "ID")
+
+	err = p.Paginate(stmt, &uploads).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := p.GetNextCursor()
+
+	return uploads, &c, err
+}
+
+// DeleteGroupUploads delete all the uploads owned by a group
+func (b *Backend) DeleteGroupUploads(groupID string) (removed int, err error) {
+	if groupID == "" {
+		return 0, fmt.Errorf("missing group id")
+	}
+
+	var uploads []*common.Upload
+	err = b.db.Select("id").Where(&common.Upload{GroupID: groupID}).Find(&uploads).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var errors []error
+	for _, upload := range uploads {
+		err = b.DeleteUpload(upload.ID)
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		removed++
+	}
+
+	if len(errors) > 0 {
+		return removed, fmt.Errorf("unable to delete all group uploads")
+	}
+
+	return removed, nil
+}
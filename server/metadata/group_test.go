@@ -0,0 +1,263 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func createGroup(t *testing.T, b *Backend, group *common.Group) {
+	err := b.CreateGroup(group)
+	require.NoError(t, err, "create group error : %s", err)
+}
+
+func TestBackend_CreateGroup(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	group := common.NewGroup("team")
+	createGroup(t, b, group)
+	require.NotZero(t, group.CreatedAt, "missing creation date")
+
+	err := b.CreateGroup(common.NewGroup("team"))
+	require.Error(t, err, "duplicate group name error expected")
+}
+
+func TestBackend_GetGroup(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	group, err := b.GetGroup("group")
+	require.NoError(t, err, "get group error")
+	require.Nil(t, group, "non nil group")
+
+	group = common.NewGroup("team")
+	group.MaxUploads = 10
+	createGroup(t, b, group)
+
+	result, err := b.GetGroup(group.ID)
+	require.NoError(t, err, "get group error")
+	require.NotNil(t, result, "nil group")
+	require.Equal(t, group.Name, result.Name, "invalid group name")
+	require.Equal(t, 10, result.MaxUploads, "invalid group max uploads")
+
+	result, err = b.GetGroupByName("team")
+	require.NoError(t, err, "get group error")
+	require.NotNil(t, result, "nil group")
+	require.Equal(t, group.ID, result.ID, "invalid group id")
+
+	result, err = b.GetGroupByName("other")
+	require.NoError(t, err, "get group error")
+	require.Nil(t, result, "non nil group")
+}
+
+func TestBackend_UpdateGroup(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	group := common.NewGroup("team")
+	createGroup(t, b, group)
+
+	group.Name = "renamed"
+	group.MaxSize = 1000
+	err := b.UpdateGroup(group)
+	require.NoError(t, err, "update group error")
+
+	result, err := b.GetGroup(group.ID)
+	require.NoError(t, err, "get group error")
+	require.Equal(t, "renamed", result.Name, "invalid group name")
+	require.Equal(t, int64(1000), result.MaxSize, "invalid group max size")
+}
+
+func TestBackend_GroupMembers(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	createUser(t, b, user)
+
+	group := common.NewGroup("team")
+	createGroup(t, b, group)
+
+	member, err := b.GetGroupMember(group.ID, user.ID)
+	require.NoError(t, err, "get group member error")
+	require.Nil(t, member, "non nil group member")
+
+	err = b.SaveGroupMember(&common.GroupMember{GroupID: group.ID, UserID: user.ID, Role: common.GroupRoleMember})
+	require.NoError(t, err, "save group member error")
+
+	// Update role
+	err = b.SaveGroupMember(&common.GroupMember{GroupID: group.ID, UserID: user.ID, Role: common.GroupRoleManager})
+	require.NoError(t, err, "save group member error")
+
+	member, err = b.GetGroupMember(group.ID, user.ID)
+	require.NoError(t, err, "get group member error")
+	require.NotNil(t, member, "nil group member")
+	require.True(t, member.IsManager(), "invalid group member role")
+
+	members, err := b.GetGroupMembers(group.ID)
+	require.NoError(t, err, "get group members error")
+	require.Len(t, members, 1, "invalid group member count")
+
+	groups, err := b.GetUserGroups(user.ID)
+	require.NoError(t, err, "get user groups error")
+	require.Len(t, groups, 1, "invalid user group count")
+	require.Equal(t, group.ID, groups[0].ID, "invalid group id")
+	require.Equal(t, common.GroupRoleManager, groups[0].Role, "invalid group role")
+
+	deleted, err := b.DeleteGroupMember(group.ID, user.ID)
+	require.NoError(t, err, "delete group member error")
+	require.True(t, deleted, "invalid deleted value")
+
+	deleted, err = b.DeleteGroupMember(group.ID, user.ID)
+	require.NoError(t, err, "delete group member error")
+	require.False(t, deleted, "invalid deleted value")
+}
+
+func TestBackend_GroupTokens(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	group := common.NewGroup("team")
+	createGroup(t, b, group)
+
+	for i := 0; i < 10; i++ {
+		err := b.CreateGroupToken(group.NewToken())
+		require.NoError(t, err, "create group token error")
+	}
+
+	tokens, cursor, err := b.GetGroupTokens(group.ID, common.NewPagingQuery().WithLimit(5))
+	require.NoError(t, err, "get group tokens error")
+	require.Len(t, tokens, 5, "invalid token count")
+	require.NotNil(t, cursor, "invalid nil cursor")
+
+	token, err := b.GetGroupToken(tokens[0].Token)
+	require.NoError(t, err, "get group token error")
+	require.NotNil(t, token, "nil group token")
+	require.Equal(t, group.ID, token.GroupID, "invalid token group")
+
+	deleted, err := b.DeleteGroupToken(token.Token)
+	require.NoError(t, err, "delete group token error")
+	require.True(t, deleted, "invalid deleted value")
+
+	token, err = b.GetGroupToken(token.Token)
+	require.NoError(t, err, "get group token error")
+	require.Nil(t, token, "non nil group token")
+}
+
+func TestBackend_GroupUploads(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	group := common.NewGroup("team")
+	createGroup(t, b, group)
+
+	for i := 0; i < 3; i++ {
+		upload := &common.Upload{GroupID: group.ID}
+		createUpload(t, b, upload)
+	}
+	createUpload(t, b, &common.Upload{})
+
+	uploads, _, err := b.GetGroupUploads(group.ID, true, common.NewPagingQuery().WithLimit(10))
+	require.NoError(t, err, "get group uploads error")
+	require.Len(t, uploads, 3, "invalid upload count")
+
+	_, _, err = b.GetGroupUploads("", true, common.NewPagingQuery().WithLimit(10))
+	require.Error(t, err, "missing group id error expected")
+
+	removed, err := b.DeleteGroupUploads(group.ID)
+	require.NoError(t, err, "delete group uploads error")
+	require.Equal(t, 3, removed, "invalid removed count")
+
+	uploads, _, err = b.GetGroupUploads(group.ID, true, common.NewPagingQuery().WithLimit(10))
+	require.NoError(t, err, "get group uploads error")
+	require.Len(t, uploads, 0, "invalid upload count")
+}
+
+func TestBackend_DeleteGroup(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	createUser(t, b, user)
+
+	group := common.NewGroup("team")
+	createGroup(t, b, group)
+
+	err := b.SaveGroupMember(&common.GroupMember{GroupID: group.ID, UserID: user.ID, Role: common.GroupRoleManager})
+	require.NoError(t, err, "save group member error")
+
+	token := group.NewToken()
+	err = b.CreateGroupToken(token)
+	require.NoError(t, err, "create group token error")
+
+	upload := &common.Upload{GroupID: group.ID}
+	createUpload(t, b, upload)
+
+	deleted, err := b.DeleteGroup(group.ID)
+	require.NoError(t, err, "delete group error")
+	require.True(t, deleted, "invalid deleted value")
+
+	result, err := b.GetGroup(group.ID)
+	require.NoError(t, err, "get group error")
+	require.Nil(t, result, "non nil group")
+
+	member, err := b.GetGroupMember(group.ID, user.ID)
+	require.NoError(t, err, "get group member error")
+	require.Nil(t, member, "non nil group member")
+
+	gt, err := b.GetGroupToken(token.Token)
+	require.NoError(t, err, "get group token error")
+	require.Nil(t, gt, "non nil group token")
+
+	u, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Nil(t, u, "non nil upload")
+
+	deleted, err = b.DeleteGroup(group.ID)
+	require.NoError(t, err, "delete group error")
+	require.False(t, deleted, "invalid deleted value")
+}
+
+func TestBackend_DeleteUserKeepsGroupUploads(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	createUser(t, b, user)
+
+	group := common.NewGroup("team")
+	createGroup(t, b, group)
+
+	err := b.SaveGroupMember(&common.GroupMember{GroupID: group.ID, UserID: user.ID, Role: common.GroupRoleMember})
+	require.NoError(t, err, "save group member error")
+
+	groupUpload := &common.Upload{User: user.ID, Token: token.Token, GroupID: group.ID}
+	createUpload(t, b, groupUpload)
+
+	userUpload := &common.Upload{User: user.ID}
+	file := userUpload.NewFile()
+	file.Status = common.FileUploaded
+	createUpload(t, b, userUpload)
+
+	deleted, err := b.DeleteUser(user.ID)
+	require.NoError(t, err, "delete user error")
+	require.True(t, deleted, "invalid deleted value")
+
+	u, err := b.GetUpload(userUpload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Nil(t, u, "user upload has not been deleted")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, common.FileRemoved, f.Status, "user upload file has not been removed")
+
+	u, err = b.GetUpload(groupUpload.ID)
+	require.NoError(t, err, "get upload error")
+	require.NotNil(t, u, "group upload has been deleted")
+	require.Equal(t, "", u.User, "group upload user has not been detached")
+	require.Equal(t, "", u.Token, "group upload token has not been detached")
+
+	tokenResult, err := b.GetToken(token.Token)
+	require.NoError(t, err, "get token error")
+	require.Nil(t, tokenResult, "user token has not been deleted")
+
+	member, err := b.GetGroupMember(group.ID, user.ID)
+	require.NoError(t, err, "get group member error")
+	require.Nil(t, member, "group member has not been deleted")
+}
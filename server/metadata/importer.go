@@ -35,6 +35,15 @@ func newImporter(path string) (i *importer, err error) {
 	gob.Register(&common.User{})
 	gob.Register(&common.Token{})
 	gob.Register(&common.Setting{})
+	gob.Register(&common.Group{})
+	gob.Register(&common.GroupMember{})
+	gob.Register(&common.GroupToken{})
+	gob.Register(&common.UploadRequest{})
+	gob.Register(&common.Report{})
+	gob.Register(&common.BlockedHash{})
+	gob.Register(&common.Webhook{})
+	gob.Register(&common.FileVersion{})
+	gob.Register(&common.UploadACL{})
 	i.decoder = gob.NewDecoder(i.decompressor)
 
 	return i, nil
@@ -53,7 +62,7 @@ func (b *Backend) Import(path string) (err error) {
 
 	defer func() { _ = i.close() }()
 
-	var uploads, files, users, tokens, settings int
+	var uploads, files, users, tokens, settings, groups, groupMembers, groupTokens, uploadRequests, reports, blockedHashes, webhooks, fileVersions, uploadACL int
 	for {
 		obj := &object{}
 		err = i.decoder.Decode(obj)
@@ -71,7 +80,10 @@ func (b *Backend) Import(path string) (err error) {
 			}
 			uploads++
 		case metadataTypeFile:
-			err = b.CreateFile(obj.Object.(*common.File))
+			file := obj.Object.(*common.File)
+			// Archive indexes are not exported, archives will be indexed again on demand
+			file.Archive = ""
+			err = b.CreateFile(file)
 			if err != nil {
 				return err
 			}
@@ -94,6 +106,60 @@ func (b *Backend) Import(path string) (err error) {
 				return err
 			}
 			settings++
+		case metadataTypeGroup:
+			err = b.CreateGroup(obj.Object.(*common.Group))
+			if err != nil {
+				return err
+			}
+			groups++
+		case metadataTypeGroupMember:
+			err = b.SaveGroupMember(obj.Object.(*common.GroupMember))
+			if err != nil {
+				return err
+			}
+			groupMembers++
+		case metadataTypeGroupToken:
+			err = b.CreateGroupToken(obj.Object.(*common.GroupToken))
+			if err != nil {
+				return err
+			}
+			groupTokens++
+		case metadataTypeUploadRequest:
+			err = b.CreateUploadRequest(obj.Object.(*common.UploadRequest))
+			if err != nil {
+				return err
+			}
+			uploadRequests++
+		case metadataTypeReport:
+			err = b.CreateReport(obj.Object.(*common.Report))
+			if err != nil {
+				return err
+			}
+			reports++
+		case metadataTypeBlockedHash:
+			err = b.AddBlockedHash(obj.Object.(*common.BlockedHash))
+			if err != nil {
+				return err
+			}
+			blockedHashes++
+		case metadataTypeWebhook:
+			err = b.CreateWebhook(obj.Object.(*common.Webhook))
+			if err != nil {
+				return err
+			}
+			webhooks++
+		case metadataTypeFileVersion:
+			err = b.CreateFileVersion(obj.Object.(*common.FileVersion))
+			if err != nil {
+				return err
+			}
+			fileVersions++
+		case metadataTypeUploadACL:
+			err = b.AddUploadACL(obj.Object.(*common.UploadACL))
+			if err != nil {
+				return err
+			}
+			uploadACL++
 		default:
 			return fmt.Errorf("invalid object type")
 		}
@@ -104,6 +170,15 @@ func (b *Backend) Import(path string) (err error) {
 	fmt.Printf("imported %d users\n", users)
 	fmt.Printf("imported %d tokens\n", tokens)
 	fmt.Printf("imported %d settings\n", settings)
+	fmt.Printf("imported %d groups\n", groups)
+	fmt.Printf("imported %d group members\n", groupMembers)
+	fmt.Printf("imported %d group tokens\n", groupTokens)
+	fmt.Printf("imported %d upload requests\n", uploadRequests)
+	fmt.Printf("imported %d reports\n", reports)
+	fmt.Printf("imported %d blocked hashes\n", blockedHashes)
+	fmt.Printf("imported %d webhooks\n", webhooks)
+	fmt.Printf("imported %d file versions\n", fileVersions)
+	fmt.Printf("imported %d upload acl entries\n", uploadACL)
 
 	return nil
 }
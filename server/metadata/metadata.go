@@ -69,7 +69,7 @@ func NewBackend(config *Config) (b *Backend, err error) {
 	}
 
 	if config.EraseFirst {
-		err = b.db.DropTableIfExists("files", "uploads", "tokens", "users", "settings", "migrations").Error
+		err = b.db.DropTableIfExists("webhook_deliveries", "webhooks", "reports", "blocked_hashes", "upload_requests", "group_members", "group_tokens", "groups", "archive_members", "file_versions", "files", "uploads", "tokens", "users", "settings", "auth_failures", "share_link_downloads", "upload_acls", "migrations").Error
 		if err != nil {
 			return nil, fmt.Errorf("unable to drop tables : %s", err)
 		}
@@ -90,6 +90,217 @@ func NewBackend(config *Config) (b *Backend, err error) {
 func (b *Backend) initializeDB() (err error) {
 	m := gormigrate.New(b.db, gormigrate.DefaultOptions, []*gormigrate.Migration{
 		// you migrations here
+		{
+			ID: "add_auth_failures_table",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.AuthFailure{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.DropTableIfExists("auth_failures").Error
+			},
+		},
+		{
+			ID: "add_share_links",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.Upload{}, &common.ShareLinkDownload{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				err := tx.DropTableIfExists("share_link_downloads").Error
+				if err != nil {
+					return err
+				}
+				return tx.Model(&common.Upload{}).DropColumn("share_secret").Error
+			},
+		},
+		{
+			ID: "add_upload_acls",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.Upload{}, &common.UploadACL{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				err := tx.DropTableIfExists("upload_acls").Error
+				if err != nil {
+					return err
+				}
+				return tx.Model(&common.Upload{}).DropColumn("restricted").Error
+			},
+		},
+		{
+			ID: "add_groups",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.Upload{}, &common.Group{}, &common.GroupMember{}, &common.GroupToken{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				err := tx.DropTableIfExists("group_members", "group_tokens", "groups").Error
+				if err != nil {
+					return err
+				}
+				return tx.Model(&common.Upload{}).DropColumn("group_id").Error
+			},
+		},
+		{
+			ID: "add_upload_requests",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.Upload{}, &common.UploadRequest{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				err := tx.DropTableIfExists("upload_requests").Error
+				if err != nil {
+					return err
+				}
+				return tx.Model(&common.Upload{}).DropColumn("request_id").Error
+			},
+		},
+		{
+			ID: "add_reports_and_blocklist",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.Upload{}, &common.Report{}, &common.BlockedHash{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				err := tx.DropTableIfExists("reports", "blocked_hashes").Error
+				if err != nil {
+					return err
+				}
+				return tx.Model(&common.Upload{}).DropColumn("frozen").Error
+			},
+		},
+		{
+			ID: "add_file_virus",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.File{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Model(&common.File{}).DropColumn("virus").Error
+			},
+		},
+		{
+			ID: "add_webhooks",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.Webhook{}, &common.WebhookDelivery{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.DropTableIfExists("webhook_deliveries", "webhooks").Error
+			},
+		},
+		{
+			ID: "add_email_notifications",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.User{}, &common.Upload{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, column := range []string{"notify_downloads", "notify_expiration", "notify_upload_requests"} {
+					err := tx.Model(&common.User{}).DropColumn(column).Error
+					if err != nil {
+						return err
+					}
+				}
+				return tx.Model(&common.Upload{}).DropColumn("notified_expire_at").Error
+			},
+		},
+		{
+			ID: "add_file_path",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.File{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Model(&common.File{}).DropColumn("path").Error
+			},
+		},
+		{
+			ID: "add_archive_members",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.File{}, &common.ArchiveMember{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				err := tx.DropTableIfExists("archive_members").Error
+				if err != nil {
+					return err
+				}
+				return tx.Model(&common.File{}).DropColumn("archive").Error
+			},
+		},
+		{
+			ID: "add_file_preview",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.File{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Model(&common.File{}).DropColumn("preview").Error
+			},
+		},
+		{
+			ID: "add_strip_metadata",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.Upload{}, &common.File{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				err := tx.Model(&common.Upload{}).DropColumn("strip_metadata").Error
+				if err != nil {
+					return err
+				}
+				return tx.Model(&common.File{}).DropColumn("metadata_stripped").Error
+			},
+		},
+		{
+			ID: "add_file_fetch",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.File{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, column := range []string{"source", "fetch_size", "fetch_error"} {
+					err := tx.Model(&common.File{}).DropColumn(column).Error
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "add_max_downloads",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.Upload{}, &common.File{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				err := tx.Model(&common.Upload{}).DropColumn("max_downloads").Error
+				if err != nil {
+					return err
+				}
+				return tx.Model(&common.File{}).DropColumn("downloads").Error
+			},
+		},
+		{
+			ID: "add_paste",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.File{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				err := tx.Model(&common.File{}).DropColumn("paste").Error
+				if err != nil {
+					return err
+				}
+				return tx.Model(&common.File{}).DropColumn("language").Error
+			},
+		},
+		{
+			ID: "add_file_versions",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&common.File{}, &common.FileVersion{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				err := tx.DropTableIfExists("file_versions").Error
+				if err != nil {
+					return err
+				}
+				for _, column := range []string{"version", "version_created_at", "data_id"} {
+					err = tx.Model(&common.File{}).DropColumn(column).Error
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
 	})
 
 	m.InitSchema(func(tx *gorm.DB) error {
@@ -105,6 +316,19 @@ func (b *Backend) initializeDB() (err error) {
 			&common.User{},
 			&common.Token{},
 			&common.Setting{},
+			&common.AuthFailure{},
+			&common.ShareLinkDownload{},
+			&common.UploadACL{},
+			&common.Group{},
+			&common.GroupMember{},
+			&common.GroupToken{},
+			&common.UploadRequest{},
+			&common.Report{},
+			&common.BlockedHash{},
+			&common.Webhook{},
+			&common.WebhookDelivery{},
+			&common.ArchiveMember{},
+			&common.FileVersion{},
 		).Error
 		if err != nil {
 			return err
@@ -0,0 +1,168 @@
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	paginator "github.com/pilagod/gorm-cursor-paginator"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CreateReport create a new abuse report in DB
+func (b *Backend) CreateReport(report *common.Report) (err error) {
+	return b.db.Create(report).Error
+}
+
+// GetReport return an abuse report from DB ( return nil and no error if not found )
+func (b *Backend) GetReport(ID string) (report *common.Report, err error) {
+	report = &common.Report{}
+	err = b.db.Where(&common.Report{ID: ID}).Take(report).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return report, err
+}
+
+// UpdateReport update an abuse report in DB
+func (b *Backend) UpdateReport(report *common.Report) (err error) {
+	result := b.db.Save(report)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("no report updated")
+	}
+
+	return nil
+}
+
+// GetReports return the abuse reports ( the moderation queue )
+// status is an optional filter
+func (b *Backend) GetReports(status string, pagingQuery *common.PagingQuery) (reports []*common.Report, cursor *paginator.Cursor, err error) {
+	if pagingQuery == nil {
+		return nil, nil, fmt.Errorf("missing paging query")
+	}
+
+	stmt := b.db.Model(&common.Report{})
+	if status != "" {
+		stmt = stmt.Where(&common.Report{Status: status})
+	}
+
+	p := pagingQuery.Paginator()
+	p.SetKeys("CreatedAt", "ID")
+
+	err = p.Paginate(stmt, &reports).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := p.GetNextCursor()
+	return reports, &c, err
+}
+
+// ResolveReports update the status and the action of all the open reports about an upload
+// fileID is an optional filter, upload level reports are not matched by a file filter
+func (b *Backend) ResolveReports(uploadID string, fileID string, status string, action string) (err error) {
+	return b.db.Model(&common.Report{}).Where(&common.Report{UploadID: uploadID, FileID: fileID, Status: common.ReportOpen}).Updates(&common.Report{Status: status, Action: action}).Error
+}
+
+// ForEachReport execute f for every abuse report in the database
+func (b *Backend) ForEachReport(f func(report *common.Report) error) (err error) {
+	rows, err := b.db.Model(&common.Report{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		report := &common.Report{}
+		err = b.db.ScanRows(rows, report)
+		if err != nil {
+			return err
+		}
+		err = f(report)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddBlockedHash add a file hash to the blocklist
+func (b *Backend) AddBlockedHash(hash *common.BlockedHash) (err error) {
+	if hash.Hash == "" {
+		return fmt.Errorf("missing hash")
+	}
+	return b.db.Save(hash).Error
+}
+
+// IsBlockedHash return true if the file hash is in the blocklist
+func (b *Backend) IsBlockedHash(hash string) (blocked bool, err error) {
+	if hash == "" {
+		return false, nil
+	}
+
+	var count int
+	err = b.db.Model(&common.BlockedHash{}).Where(&common.BlockedHash{Hash: hash}).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// GetBlockedHashes return the blocklist
+func (b *Backend) GetBlockedHashes(pagingQuery *common.PagingQuery) (hashes []*common.BlockedHash, cursor *paginator.Cursor, err error) {
+	if pagingQuery == nil {
+		return nil, nil, fmt.Errorf("missing paging query")
+	}
+
+	p := pagingQuery.Paginator()
+	p.SetKeys("CreatedAt", "Hash")
+
+	err = p.Paginate(b.db.Model(&common.BlockedHash{}), &hashes).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := p.GetNextCursor()
+	return hashes, &c, err
+}
+
+// DeleteBlockedHash remove a file hash from the blocklist
+func (b *Backend) DeleteBlockedHash(hash string) (deleted bool, err error) {
+	result := b.db.Delete(&common.BlockedHash{Hash: hash})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// ForEachBlockedHash execute f for every hash in the blocklist
+func (b *Backend) ForEachBlockedHash(f func(hash *common.BlockedHash) error) (err error) {
+	rows, err := b.db.Model(&common.BlockedHash{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		hash := &common.BlockedHash{}
+		err = b.db.ScanRows(rows, hash)
+		if err != nil {
+			return err
+		}
+		err = f(hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
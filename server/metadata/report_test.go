@@ -0,0 +1,142 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func createReport(t *testing.T, b *Backend, report *common.Report) {
+	if report.ID == "" {
+		report.ID = common.GenerateRandomID(16)
+	}
+	if report.Status == "" {
+		report.Status = common.ReportOpen
+	}
+	err := b.CreateReport(report)
+	require.NoError(t, err, "create report error : %s", err)
+}
+
+func TestBackend_CreateReport(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	report := &common.Report{UploadID: "upload", Reason: "malware"}
+	createReport(t, b, report)
+	require.NotZero(t, report.CreatedAt, "missing creation date")
+
+	result, err := b.GetReport(report.ID)
+	require.NoError(t, err, "get report error")
+	require.NotNil(t, result, "missing report")
+	require.Equal(t, report.Reason, result.Reason, "invalid report reason")
+
+	result, err = b.GetReport("missing")
+	require.NoError(t, err, "get report error")
+	require.Nil(t, result, "report should not exist")
+}
+
+func TestBackend_UpdateReport(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	report := &common.Report{UploadID: "upload", Reason: "malware"}
+	createReport(t, b, report)
+
+	report.Status = common.ReportDismissed
+	err := b.UpdateReport(report)
+	require.NoError(t, err, "update report error")
+
+	result, err := b.GetReport(report.ID)
+	require.NoError(t, err, "get report error")
+	require.Equal(t, common.ReportDismissed, result.Status, "invalid report status")
+}
+
+func TestBackend_GetReports(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	for i := 0; i < 3; i++ {
+		createReport(t, b, &common.Report{UploadID: "upload", Reason: "spam"})
+	}
+	createReport(t, b, &common.Report{UploadID: "upload", Reason: "spam", Status: common.ReportDismissed})
+
+	reports, cursor, err := b.GetReports("", &common.PagingQuery{})
+	require.NoError(t, err, "get reports error")
+	require.NotNil(t, cursor, "missing cursor")
+	require.Len(t, reports, 4, "invalid report count")
+
+	reports, _, err = b.GetReports(common.ReportOpen, &common.PagingQuery{})
+	require.NoError(t, err, "get reports error")
+	require.Len(t, reports, 3, "invalid report count")
+
+	_, _, err = b.GetReports("", nil)
+	require.Error(t, err, "missing paging query error expected")
+}
+
+func TestBackend_ResolveReports(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	report1 := &common.Report{UploadID: "upload", Reason: "spam"}
+	createReport(t, b, report1)
+	report2 := &common.Report{UploadID: "other", Reason: "spam"}
+	createReport(t, b, report2)
+
+	report3 := &common.Report{UploadID: "upload", FileID: "file", Reason: "spam"}
+	createReport(t, b, report3)
+
+	err := b.ResolveReports("upload", "file", common.ReportResolved, common.ModerationTakedown)
+	require.NoError(t, err, "resolve reports error")
+
+	result, err := b.GetReport(report1.ID)
+	require.NoError(t, err, "get report error")
+	require.Equal(t, common.ReportOpen, result.Status, "invalid report status")
+
+	result, err = b.GetReport(report3.ID)
+	require.NoError(t, err, "get report error")
+	require.Equal(t, common.ReportResolved, result.Status, "invalid report status")
+
+	err = b.ResolveReports("upload", "", common.ReportResolved, common.ModerationTakedown)
+	require.NoError(t, err, "resolve reports error")
+
+	result, err = b.GetReport(report1.ID)
+	require.NoError(t, err, "get report error")
+	require.Equal(t, common.ReportResolved, result.Status, "invalid report status")
+	require.Equal(t, common.ModerationTakedown, result.Action, "invalid report action")
+
+	result, err = b.GetReport(report2.ID)
+	require.NoError(t, err, "get report error")
+	require.Equal(t, common.ReportOpen, result.Status, "invalid report status")
+}
+
+func TestBackend_BlockedHashes(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	blocked, err := b.IsBlockedHash("hash")
+	require.NoError(t, err, "is blocked hash error")
+	require.False(t, blocked, "hash should not be blocked")
+
+	err = b.AddBlockedHash(&common.BlockedHash{Hash: "hash"})
+	require.NoError(t, err, "add blocked hash error")
+
+	// Adding the same hash twice is not an error
+	err = b.AddBlockedHash(&common.BlockedHash{Hash: "hash", Comment: "again"})
+	require.NoError(t, err, "add blocked hash error")
+
+	err = b.AddBlockedHash(&common.BlockedHash{})
+	require.Error(t, err, "missing hash error expected")
+
+	blocked, err = b.IsBlockedHash("hash")
+	require.NoError(t, err, "is blocked hash error")
+	require.True(t, blocked, "hash should be blocked")
+
+	hashes, _, err := b.GetBlockedHashes(&common.PagingQuery{})
+	require.NoError(t, err, "get blocked hashes error")
+	require.Len(t, hashes, 1, "invalid blocked hash count")
+
+	deleted, err := b.DeleteBlockedHash("hash")
+	require.NoError(t, err, "delete blocked hash error")
+	require.True(t, deleted, "hash should be deleted")
+
+	blocked, err = b.IsBlockedHash("hash")
+	require.NoError(t, err, "is blocked hash error")
+	require.False(t, blocked, "hash should not be blocked")
+}
@@ -2,6 +2,9 @@ package metadata
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jinzhu/gorm"
 
@@ -66,3 +69,78 @@ func (b *Backend) ForEachSetting(f func(setting *common.Setting) error) (err err
 
 	return nil
 }
+
+// AcquireLease try to acquire or renew the lease stored in the setting key for duration
+// The lease can be acquired if it does not exist, if it has expired or if it is already held by owner
+func (b *Backend) AcquireLease(key string, owner string, duration time.Duration) (acquired bool, err error) {
+	value := fmt.Sprintf("%s %d", owner, time.Now().Add(duration).Unix())
+
+	setting, err := b.GetSetting(key)
+	if err != nil {
+		return false, err
+	}
+
+	if setting == nil {
+		err = b.CreateSetting(&common.Setting{Key: key, Value: value})
+		if err != nil {
+			// Another instance might have created the lease in the meantime
+			setting, err2 := b.GetSetting(key)
+			if err2 == nil && setting != nil {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	holder, expire, err := parseLease(setting.Value)
+	if err != nil {
+		return false, err
+	}
+
+	if holder != owner && time.Now().Unix() < expire {
+		return false, nil
+	}
+
+	// Compare and swap the previous lease value so only one instance can win the lease
+	result := b.db.Model(&common.Setting{}).Where(&common.Setting{Key: key, Value: setting.Value}).Update(&common.Setting{Value: value})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == int64(1), nil
+}
+
+// ReleaseLease release the lease stored in the setting key if it is held by owner
+func (b *Backend) ReleaseLease(key string, owner string) (err error) {
+	setting, err := b.GetSetting(key)
+	if err != nil || setting == nil {
+		return err
+	}
+
+	holder, _, err := parseLease(setting.Value)
+	if err != nil {
+		return err
+	}
+
+	if holder != owner {
+		return nil
+	}
+
+	return b.db.Where(&common.Setting{Key: key, Value: setting.Value}).Delete(&common.Setting{}).Error
+}
+
+// parseLease parse a "<owner> <expire unix timestamp>" lease value
+func parseLease(value string) (owner string, expire int64, err error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return "", 0, fmt.Errorf("invalid lease %s", value)
+	}
+
+	expire, err = strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid lease %s", value)
+	}
+
+	return fields[0], expire, nil
+}
@@ -3,6 +3,7 @@ package metadata
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
 	"github.com/stretchr/testify/require"
@@ -100,3 +101,69 @@ func TestBackend_ForEachSetting(t *testing.T) {
 	err = b.ForEachSetting(f)
 	require.Errorf(t, err, "expected")
 }
+
+func TestAcquireLease(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	acquired, err := b.AcquireLease("lease", "foo", time.Hour)
+	require.NoError(t, err, "acquire lease error")
+	require.True(t, acquired, "lease should be acquired")
+
+	acquired, err = b.AcquireLease("lease", "bar", time.Hour)
+	require.NoError(t, err, "acquire lease error")
+	require.False(t, acquired, "lease should be held by another owner")
+
+	acquired, err = b.AcquireLease("lease", "foo", time.Hour)
+	require.NoError(t, err, "acquire lease error")
+	require.True(t, acquired, "lease should be renewed")
+
+	// Expire the lease
+	err = b.UpdateSetting("lease", mustGetSetting(t, b, "lease"), "foo 0")
+	require.NoError(t, err, "update setting error")
+
+	acquired, err = b.AcquireLease("lease", "bar", time.Hour)
+	require.NoError(t, err, "acquire lease error")
+	require.True(t, acquired, "expired lease should be acquired")
+}
+
+func TestAcquireLeaseInvalid(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	err := b.CreateSetting(&common.Setting{Key: "lease", Value: "foo"})
+	require.NoError(t, err, "create setting error")
+
+	_, err = b.AcquireLease("lease", "foo", time.Hour)
+	require.Error(t, err, "invalid lease error expected")
+}
+
+func TestReleaseLease(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	err := b.ReleaseLease("lease", "foo")
+	require.NoError(t, err, "release lease error")
+
+	acquired, err := b.AcquireLease("lease", "foo", time.Hour)
+	require.NoError(t, err, "acquire lease error")
+	require.True(t, acquired, "lease should be acquired")
+
+	err = b.ReleaseLease("lease", "bar")
+	require.NoError(t, err, "release lease error")
+
+	acquired, err = b.AcquireLease("lease", "bar", time.Hour)
+	require.NoError(t, err, "acquire lease error")
+	require.False(t, acquired, "lease should still be held")
+
+	err = b.ReleaseLease("lease", "foo")
+	require.NoError(t, err, "release lease error")
+
+	acquired, err = b.AcquireLease("lease", "bar", time.Hour)
+	require.NoError(t, err, "acquire lease error")
+	require.True(t, acquired, "released lease should be acquired")
+}
+
+func mustGetSetting(t *testing.T, b *Backend, key string) string {
+	setting, err := b.GetSetting(key)
+	require.NoError(t, err, "get setting error")
+	require.NotNil(t, setting, "missing setting")
+	return setting.Value
+}
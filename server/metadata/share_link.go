@@ -0,0 +1,76 @@
+package metadata
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// IncrementShareLinkDownloads atomically count a download of a share link
+// Return false if the share link download cap has already been reached
+func (b *Backend) IncrementShareLinkDownloads(link *common.ShareLink) (ok bool, err error) {
+	err = b.db.Transaction(func(tx *gorm.DB) (err error) {
+		result := tx.Model(&common.ShareLinkDownload{}).
+			Where("id = ?", link.ID).
+			Where("count < ?", link.MaxDownloads).
+			Update("count", gorm.Expr("count + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			ok = true
+			return nil
+		}
+
+		var count int
+		err = tx.Model(&common.ShareLinkDownload{}).Where("id = ?", link.ID).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			// Download cap reached
+			return nil
+		}
+
+		download := &common.ShareLinkDownload{ID: link.ID, UploadID: link.UploadID, Count: 1, ExpireAt: link.ExpireAt}
+		err = tx.Create(download).Error
+		if err != nil {
+			return err
+		}
+
+		ok = true
+		return nil
+	})
+
+	return ok, err
+}
+
+// GetShareLinkDownloads return the download count of a share link
+func (b *Backend) GetShareLinkDownloads(linkID string) (count int, err error) {
+	download := &common.ShareLinkDownload{}
+	err = b.db.Take(download, &common.ShareLinkDownload{ID: linkID}).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return download.Count, nil
+}
+
+// DeleteShareLinkDownloads delete the download counters of all the share links of an upload
+func (b *Backend) DeleteShareLinkDownloads(uploadID string) (err error) {
+	return b.db.Where(&common.ShareLinkDownload{UploadID: uploadID}).Delete(&common.ShareLinkDownload{}).Error
+}
+
+// PurgeShareLinkDownloads delete the download counters of expired share links
+func (b *Backend) PurgeShareLinkDownloads(deadline time.Time) (removed int, err error) {
+	result := b.db.Where("expire_at < ?", deadline).Delete(&common.ShareLinkDownload{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return int(result.RowsAffected), nil
+}
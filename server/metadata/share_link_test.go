@@ -0,0 +1,65 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestBackend_IncrementShareLinkDownloads(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	link := &common.ShareLink{ID: "link", UploadID: "upload", MaxDownloads: 2, ExpireAt: time.Now().Add(time.Hour)}
+
+	for i := 1; i <= 2; i++ {
+		ok, err := b.IncrementShareLinkDownloads(link)
+		require.NoError(t, err, "increment share link downloads error")
+		require.True(t, ok, "download cap reached too early")
+
+		count, err := b.GetShareLinkDownloads(link.ID)
+		require.NoError(t, err, "get share link downloads error")
+		require.Equal(t, i, count, "invalid share link download count")
+	}
+
+	ok, err := b.IncrementShareLinkDownloads(link)
+	require.NoError(t, err, "increment share link downloads error")
+	require.False(t, ok, "download cap not reached")
+}
+
+func TestBackend_DeleteShareLinkDownloads(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	link := &common.ShareLink{ID: "link", UploadID: "upload", MaxDownloads: 1, ExpireAt: time.Now().Add(time.Hour)}
+	_, err := b.IncrementShareLinkDownloads(link)
+	require.NoError(t, err, "increment share link downloads error")
+
+	err = b.DeleteShareLinkDownloads("upload")
+	require.NoError(t, err, "delete share link downloads error")
+
+	count, err := b.GetShareLinkDownloads(link.ID)
+	require.NoError(t, err, "get share link downloads error")
+	require.Equal(t, 0, count, "invalid share link download count")
+}
+
+func TestBackend_PurgeShareLinkDownloads(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	expired := &common.ShareLink{ID: "expired", UploadID: "upload", MaxDownloads: 1, ExpireAt: time.Now().Add(-time.Hour)}
+	_, err := b.IncrementShareLinkDownloads(expired)
+	require.NoError(t, err, "increment share link downloads error")
+
+	valid := &common.ShareLink{ID: "valid", UploadID: "upload", MaxDownloads: 1, ExpireAt: time.Now().Add(time.Hour)}
+	_, err = b.IncrementShareLinkDownloads(valid)
+	require.NoError(t, err, "increment share link downloads error")
+
+	removed, err := b.PurgeShareLinkDownloads(time.Now())
+	require.NoError(t, err, "purge share link downloads error")
+	require.Equal(t, 1, removed, "invalid purged share link downloads count")
+
+	count, err := b.GetShareLinkDownloads(valid.ID)
+	require.NoError(t, err, "get share link downloads error")
+	require.Equal(t, 1, count, "invalid share link download count")
+}
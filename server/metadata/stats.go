@@ -1,18 +1,32 @@
 package metadata
 
-import "github.com/root-gg/plik/server/common"
+import (
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
 
 // GetUploadStatistics return statistics about uploads
 // for userID and tokenStr params : nil doesn't activate the filter, empty string enables the filter with an empty value to generate statistics about anonymous upload
 func (b *Backend) GetUploadStatistics(userID *string, tokenStr *string) (uploads int, files int, size int64, err error) {
-
-	// Count uploads
-	stmt := b.db.Model(&common.Upload{})
+	filters := make(map[string]string)
 	if userID != nil {
-		stmt = stmt.Where("uploads.user = ?", userID)
+		filters["user"] = *userID
 	}
 	if tokenStr != nil {
-		stmt = stmt.Where("uploads.token = ?", tokenStr)
+		filters["token"] = *tokenStr
+	}
+
+	return b.getUploadStatistics(filters)
+}
+
+// getUploadStatistics return statistics about uploads matching all the upload column filters
+func (b *Backend) getUploadStatistics(filters map[string]string) (uploads int, files int, size int64, err error) {
+
+	// Count uploads
+	stmt := b.db.Model(&common.Upload{})
+	for column, value := range filters {
+		stmt = stmt.Where("uploads."+column+" = ?", value)
 	}
 	err = stmt.Count(&uploads).Error
 	if err != nil {
@@ -21,13 +35,10 @@ func (b *Backend) GetUploadStatistics(userID *string, tokenStr *string) (uploads
 
 	// Count files
 	stmt = b.db.Model(&common.File{}).Select("count(files.id), coalesce(sum(size),0)").Where("files.status = ?", common.FileUploaded)
-	if userID != nil || tokenStr != nil {
+	if len(filters) > 0 {
 		stmt = stmt.Joins("join uploads on uploads.id = files.upload_id")
-		if userID != nil {
-			stmt = stmt.Where("uploads.user = ?", userID)
-		}
-		if tokenStr != nil {
-			stmt = stmt.Where("uploads.token = ?", tokenStr)
+		for column, value := range filters {
+			stmt = stmt.Where("uploads."+column+" = ?", value)
 		}
 	}
 
@@ -36,7 +47,22 @@ func (b *Backend) GetUploadStatistics(userID *string, tokenStr *string) (uploads
 		return 0, 0, 0, err
 	}
 
-	return uploads, files, size, nil
+	// The previous versions of the files count in the storage used
+	var versionsSize int64
+	stmt = b.db.Model(&common.FileVersion{}).Select("coalesce(sum(file_versions.size),0)").Where("file_versions.status = ?", common.FileUploaded)
+	if len(filters) > 0 {
+		stmt = stmt.Joins("join uploads on uploads.id = file_versions.upload_id")
+		for column, value := range filters {
+			stmt = stmt.Where("uploads."+column+" = ?", value)
+		}
+	}
+
+	err = stmt.Row().Scan(&versionsSize)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return uploads, files, size + versionsSize, nil
 }
 
 // GetUserStatistics return statistics about user uploads
@@ -56,6 +82,40 @@ func (b *Backend) GetUserStatistics(userID string, tokenStr *string) (stats *com
 	return stats, nil
 }
 
+// GetGroupStatistics return statistics about group uploads and the group quotas
+func (b *Backend) GetGroupStatistics(group *common.Group) (stats *common.GroupStats, err error) {
+	uploads, files, size, err := b.getUploadStatistics(map[string]string{"group_id": group.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	stats = &common.GroupStats{
+		Uploads:    uploads,
+		Files:      files,
+		TotalSize:  size,
+		MaxUploads: group.MaxUploads,
+		MaxSize:    group.MaxSize,
+	}
+
+	return stats, nil
+}
+
+// GetUploadRequestStatistics return statistics about the uploads created through an upload request
+func (b *Backend) GetUploadRequestStatistics(requestID string) (stats *common.UserStats, err error) {
+	uploads, files, size, err := b.getUploadStatistics(map[string]string{"request_id": requestID})
+	if err != nil {
+		return nil, err
+	}
+
+	stats = &common.UserStats{
+		Uploads:   uploads,
+		Files:     files,
+		TotalSize: size,
+	}
+
+	return stats, nil
+}
+
 // GetServerStatistics return statistics about user all uploads
 func (b *Backend) GetServerStatistics() (stats *common.ServerStats, err error) {
 	users, err := b.CountUsers()
@@ -85,3 +145,66 @@ func (b *Backend) GetServerStatistics() (stats *common.ServerStats, err error) {
 
 	return stats, nil
 }
+
+// GetCleaningStatistics return statistics about what the next cleaning would delete
+func (b *Backend) GetCleaningStatistics() (stats *common.CleaningStats, err error) {
+	stats = &common.CleaningStats{}
+	now := time.Now()
+
+	// Expired uploads and their uploaded files
+	err = b.db.Model(&common.Upload{}).Where("expire_at < ?", now).Count(&stats.ExpiredUploads).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.db.Model(&common.File{}).Select("count(files.id), coalesce(sum(files.size),0)").
+		Joins("join uploads on uploads.id = files.upload_id").
+		Where("uploads.deleted_at IS NULL AND uploads.expire_at < ?", now).
+		Where("files.status = ?", common.FileUploaded).
+		Row().Scan(&stats.ExpiredFiles, &stats.ExpiredSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var expiredVersionsSize int64
+	err = b.db.Model(&common.FileVersion{}).Select("coalesce(sum(file_versions.size),0)").
+		Joins("join uploads on uploads.id = file_versions.upload_id").
+		Where("uploads.deleted_at IS NULL AND uploads.expire_at < ?", now).
+		Where("file_versions.status = ?", common.FileUploaded).
+		Row().Scan(&expiredVersionsSize)
+	if err != nil {
+		return nil, err
+	}
+	stats.ExpiredSize += expiredVersionsSize
+
+	// Files waiting to be deleted from the data backend
+	err = b.db.Model(&common.File{}).Select("count(files.id), coalesce(sum(files.size),0)").
+		Where("files.status = ?", common.FileRemoved).
+		Row().Scan(&stats.RemovedFiles, &stats.RemovedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var removedVersionsSize int64
+	err = b.db.Model(&common.FileVersion{}).Select("coalesce(sum(file_versions.size),0)").
+		Where("file_versions.status = ?", common.FileRemoved).
+		Row().Scan(&removedVersionsSize)
+	if err != nil {
+		return nil, err
+	}
+	stats.RemovedSize += removedVersionsSize
+
+	// Soft deleted uploads
+	err = b.db.Model(&common.Upload{}).Unscoped().Where("deleted_at IS NOT NULL").Count(&stats.DeletedUploads).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Expired upload requests
+	err = b.db.Model(&common.UploadRequest{}).Where("expire_at < ?", now).Count(&stats.UploadRequests).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -3,6 +3,7 @@ package metadata
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -199,3 +200,71 @@ func TestBackend_GetServerStatistics(t *testing.T) {
 	require.Equal(t, 200, stats.Files, "invalid file count")
 	require.Equal(t, int64(400), stats.TotalSize, "invalid file size")
 }
+
+func TestBackend_GetGroupStatistics(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	group := common.NewGroup("team")
+	group.MaxUploads = 100
+	group.MaxSize = 1000
+
+	for i := 1; i <= 20; i++ {
+		upload := &common.Upload{Comments: fmt.Sprintf("%d", i)}
+		if i%2 == 0 {
+			upload.GroupID = group.ID
+		}
+		for j := 1; j <= 10; j++ {
+			file := upload.NewFile()
+			file.Size = 2
+			file.Status = common.FileUploaded
+		}
+		createUpload(t, b, upload)
+	}
+
+	stats, err := b.GetGroupStatistics(group)
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, 10, stats.Uploads, "invalid upload count")
+	require.Equal(t, 100, stats.Files, "invalid file count")
+	require.Equal(t, int64(200), stats.TotalSize, "invalid file size")
+	require.Equal(t, 100, stats.MaxUploads, "invalid max uploads")
+	require.Equal(t, int64(1000), stats.MaxSize, "invalid max size")
+}
+
+func TestBackend_GetCleaningStatistics(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	deadline := time.Now().Add(-time.Hour)
+
+	expired := &common.Upload{ExpireAt: &deadline}
+	file := expired.NewFile()
+	file.Size = 10
+	file.Status = common.FileUploaded
+	createUpload(t, b, expired)
+
+	upload := &common.Upload{}
+	file = upload.NewFile()
+	file.Size = 5
+	file.Status = common.FileRemoved
+	file = upload.NewFile()
+	file.Size = 100
+	file.Status = common.FileUploaded
+	createUpload(t, b, upload)
+
+	stats, err := b.GetCleaningStatistics()
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, 1, stats.ExpiredUploads, "invalid expired upload count")
+	require.Equal(t, 1, stats.ExpiredFiles, "invalid expired file count")
+	require.Equal(t, int64(10), stats.ExpiredSize, "invalid expired size")
+	require.Equal(t, 1, stats.RemovedFiles, "invalid removed file count")
+	require.Equal(t, int64(5), stats.RemovedSize, "invalid removed size")
+	require.Equal(t, 0, stats.DeletedUploads, "invalid deleted upload count")
+
+	err = b.DeleteUpload(upload.ID)
+	require.NoError(t, err, "delete upload error")
+
+	stats, err = b.GetCleaningStatistics()
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, 2, stats.RemovedFiles, "invalid removed file count")
+	require.Equal(t, int64(105), stats.RemovedSize, "invalid removed size")
+	require.Equal(t, 1, stats.DeletedUploads, "invalid deleted upload count")
+}
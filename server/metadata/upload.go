@@ -12,7 +12,27 @@ import (
 
 // CreateUpload create a new upload in DB
 func (b *Backend) CreateUpload(upload *common.Upload) (err error) {
-	return b.db.Create(upload).Error
+	return b.db.Transaction(func(tx *gorm.DB) (err error) {
+		err = tx.Create(upload).Error
+		if err != nil {
+			return err
+		}
+
+		return createUploadACL(tx, upload)
+	})
+}
+
+// UpdateUpload update upload metadata in DB ( files are not updated )
+func (b *Backend) UpdateUpload(upload *common.Upload) (err error) {
+	result := b.db.Set("gorm:save_associations", false).Save(upload)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("no upload updated")
+	}
+
+	return nil
 }
 
 // GetUpload return an upload from the DB ( return nil and no error if not found )
@@ -64,21 +84,86 @@ func (b *Backend) GetUploads(userID string, tokenStr string, withFiles bool, pag
 	return uploads, &c, err
 }
 
+// SearchUploads return the uploads matching the filter across all users
+func (b *Backend) SearchUploads(filter *common.UploadFilter, withFiles bool, pagingQuery *common.PagingQuery) (uploads []*common.Upload, cursor *paginator.Cursor, err error) {
+	if pagingQuery == nil {
+		return nil, nil, fmt.Errorf("missing paging query")
+	}
+	if filter == nil {
+		filter = &common.UploadFilter{}
+	}
+
+	stmt := b.db.Model(&common.Upload{})
+
+	if filter.User != "" {
+		stmt = stmt.Where("uploads.user = ?", filter.User)
+	}
+	if filter.Token != "" {
+		stmt = stmt.Where("uploads.token = ?", filter.Token)
+	}
+	if filter.Anonymous != nil {
+		if *filter.Anonymous {
+			stmt = stmt.Where("uploads.user = ''")
+		} else {
+			stmt = stmt.Where("uploads.user <> ''")
+		}
+	}
+	if filter.Expired != nil {
+		if *filter.Expired {
+			stmt = stmt.Where("uploads.expire_at < ?", time.Now())
+		} else {
+			stmt = stmt.Where("uploads.expire_at IS NULL OR uploads.expire_at >= ?", time.Now())
+		}
+	}
+	if filter.CreatedAfter != nil {
+		stmt = stmt.Where("uploads.created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		stmt = stmt.Where("uploads.created_at < ?", *filter.CreatedBefore)
+	}
+
+	// The previous versions of the files count in the upload size
+	uploadSize := "((SELECT coalesce(sum(files.size),0) FROM files WHERE files.upload_id = uploads.id AND files.status = ?) + " +
+		"(SELECT coalesce(sum(file_versions.size),0) FROM file_versions WHERE file_versions.upload_id = uploads.id AND file_versions.status = ?))"
+	if filter.MinSize > 0 {
+		stmt = stmt.Where(uploadSize+" >= ?", common.FileUploaded, common.FileUploaded, filter.MinSize)
+	}
+	if filter.MaxSize > 0 {
+		stmt = stmt.Where(uploadSize+" <= ?", common.FileUploaded, common.FileUploaded, filter.MaxSize)
+	}
+
+	if withFiles {
+		stmt = stmt.Preload("Files")
+	}
+
+	p := pagingQuery.Paginator()
+	p.SetKeys("CreatedAt", "ID")
+
+	err = p.Paginate(stmt, &uploads).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := p.GetNextCursor()
+
+	return uploads, &c, err
+}
+
 // RemoveUploadFiles set the file status to removed for all files of an upload
 // The files are then deleted by the servers and their status set to removed
 func (b *Backend) RemoveUploadFiles(uploadID string) (err error) {
+	// Don't keep the rows open while updating the files as it might be called within a transaction
+	files, err := b.GetFiles(uploadID)
+	if err != nil {
+		return err
+	}
+
 	var errors []error
-	f := func(file *common.File) (err error) {
+	for _, file := range files {
 		err = b.RemoveFile(file)
 		if err != nil {
 			errors = append(errors, err)
 		}
-		return nil
-	}
-
-	err = b.ForEachUploadFiles(uploadID, f)
-	if err != nil {
-		return err
 	}
 	if len(errors) > 0 {
 		return fmt.Errorf("unable to remove %d files", len(errors))
@@ -102,9 +187,16 @@ func (b *Backend) DeleteUpload(uploadID string) (err error) {
 	return nil
 }
 
-// DeleteExpiredUploads soft delete all expired uploads
-func (b *Backend) DeleteExpiredUploads() (removed int, err error) {
-	rows, err := b.db.Model(&common.Upload{}).Where("expire_at < ?", time.Now()).Rows()
+// DeleteExpiredUploads soft delete expired uploads
+// limit is the maximum number of uploads to delete, 0 means no limit
+// f, if not nil, is executed for each deleted upload
+func (b *Backend) DeleteExpiredUploads(limit int, f func(upload *common.Upload)) (removed int, err error) {
+	stmt := b.db.Model(&common.Upload{}).Where("expire_at < ?", time.Now())
+	if limit > 0 {
+		stmt = stmt.Limit(limit)
+	}
+
+	rows, err := stmt.Rows()
 	if err != nil {
 		return 0, fmt.Errorf("unable to fetch expired uploads : %s", err)
 	}
@@ -124,6 +216,10 @@ func (b *Backend) DeleteExpiredUploads() (removed int, err error) {
 			continue
 		}
 
+		if f != nil {
+			f(upload)
+		}
+
 		removed++
 	}
 
@@ -134,10 +230,73 @@ func (b *Backend) DeleteExpiredUploads() (removed int, err error) {
 	return removed, nil
 }
 
+// GetExpiringUploads return the user uploads expiring before deadline whose owner has not been notified yet
+// limit is the maximum number of uploads to return, 0 means no limit
+func (b *Backend) GetExpiringUploads(deadline time.Time, limit int) (uploads []*common.Upload, err error) {
+	stmt := b.db.Where("uploads.user <> '' AND uploads.expire_at >= ? AND uploads.expire_at < ?", time.Now(), deadline)
+	stmt = stmt.Where("uploads.notified_expire_at IS NULL OR uploads.notified_expire_at <> uploads.expire_at")
+	if limit > 0 {
+		stmt = stmt.Limit(limit)
+	}
+
+	err = stmt.Order("expire_at").Find(&uploads).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return uploads, nil
+}
+
+// UpdateUploadColumns update only the given columns of an upload
+// Nothing is updated if the upload has been deleted or frozen/unfrozen since it has been read
+func (b *Backend) UpdateUploadColumns(upload *common.Upload, columns map[string]interface{}) (updated bool, err error) {
+	if len(columns) == 0 {
+		return true, nil
+	}
+
+	result := b.db.Model(&common.Upload{}).Where("id = ? AND frozen = ?", upload.ID, upload.Frozen).UpdateColumns(columns)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// UpdateUploadExpiration update only the TTL and the expiration date of an upload
+func (b *Backend) UpdateUploadExpiration(uploadID string, ttl int, expireAt *time.Time) (err error) {
+	result := b.db.Model(&common.Upload{}).Where("id = ?", uploadID).UpdateColumns(map[string]interface{}{"ttl": ttl, "expire_at": expireAt})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return fmt.Errorf("no upload updated")
+	}
+
+	return nil
+}
+
+// SetUploadExpirationNotified record that the upload owner has been notified of the upload expiration date
+// Extending the upload afterwards will trigger a new notification
+func (b *Backend) SetUploadExpirationNotified(upload *common.Upload) (err error) {
+	err = b.db.Model(&common.Upload{}).Where("id = ?", upload.ID).UpdateColumn("notified_expire_at", upload.ExpireAt).Error
+	if err != nil {
+		return err
+	}
+
+	upload.NotifiedExpireAt = upload.ExpireAt
+	return nil
+}
+
 // PurgeDeletedUploads ensure all files from an expired upload have been deleted
 // Then delete the upload and files for good
-func (b *Backend) PurgeDeletedUploads() (removed int, err error) {
-	rows, err := b.db.Model(&common.Upload{}).Unscoped().Where("deleted_at IS NOT NULL").Rows()
+// limit is the maximum number of uploads to purge, 0 means no limit
+func (b *Backend) PurgeDeletedUploads(limit int) (removed int, err error) {
+	stmt := b.db.Model(&common.Upload{}).Unscoped().Where("deleted_at IS NOT NULL")
+	if limit > 0 {
+		stmt = stmt.Limit(limit)
+	}
+
+	rows, err := stmt.Rows()
 	if err != nil {
 		return 0, fmt.Errorf("unable to fetch deletred uploads : %s", err)
 	}
@@ -162,6 +321,31 @@ func (b *Backend) PurgeDeletedUploads() (removed int, err error) {
 			continue
 		}
 
+		err = b.db.Model(&common.FileVersion{}).Not(&common.FileVersion{Status: common.FileDeleted}).Where(&common.FileVersion{UploadID: upload.ID}).Count(&count).Error
+		if err != nil {
+			return removed, err
+		}
+		if count > 0 {
+			// TODO log properly
+			fmt.Printf("Can't remove upload %s because %d file versions are still not deleted\n", upload.ID, count)
+			continue
+		}
+
+		// Delete the archive members of the upload files from the database
+		files := b.db.Model(&common.File{}).Select("id").Where(&common.File{UploadID: upload.ID}).QueryExpr()
+		err = b.db.Where("file_id IN (?)", files).Delete(&common.ArchiveMember{}).Error
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+
+		// Delete the previous versions of the upload files from the database
+		err = b.db.Where(&common.FileVersion{UploadID: upload.ID}).Delete(&common.FileVersion{}).Error
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+
 		// Delete the upload files from the database
 		err = b.db.Where(&common.File{UploadID: upload.ID}).Delete(&common.File{}).Error
 		if err != nil {
@@ -169,6 +353,20 @@ func (b *Backend) PurgeDeletedUploads() (removed int, err error) {
 			continue
 		}
 
+		// Delete the upload access control list from the database
+		err = b.db.Where(&common.UploadACL{UploadID: upload.ID}).Delete(&common.UploadACL{}).Error
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+
+		// Delete the upload webhooks from the database
+		err = b.db.Where(&common.Webhook{UploadID: upload.ID}).Delete(&common.Webhook{}).Error
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+
 		// Delete the upload from the database
 		err = b.db.Unscoped().Delete(upload).Error
 		if err != nil {
@@ -0,0 +1,109 @@
+package metadata
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	paginator "github.com/pilagod/gorm-cursor-paginator"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CreateUploadRequest create a new upload request in DB
+func (b *Backend) CreateUploadRequest(request *common.UploadRequest) (err error) {
+	return b.db.Create(request).Error
+}
+
+// GetUploadRequest return an upload request from DB ( return nil and no error if not found )
+func (b *Backend) GetUploadRequest(ID string) (request *common.UploadRequest, err error) {
+	request = &common.UploadRequest{}
+	err = b.db.Where(&common.UploadRequest{ID: ID}).Take(request).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return request, err
+}
+
+// GetUploadRequests return the upload requests of a user
+func (b *Backend) GetUploadRequests(userID string, pagingQuery *common.PagingQuery) (requests []*common.UploadRequest, cursor *paginator.Cursor, err error) {
+	if pagingQuery == nil {
+		return nil, nil, fmt.Errorf("missing paging query")
+	}
+	if userID == "" {
+		return nil, nil, fmt.Errorf("missing user id")
+	}
+
+	stmt := b.db.Model(&common.UploadRequest{}).Where(&common.UploadRequest{User: userID})
+
+	p := pagingQuery.Paginator()
+	p.SetKeys("CreatedAt", "ID")
+
+	err = p.Paginate(stmt, &requests).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := p.GetNextCursor()
+	return requests, &c, err
+}
+
+// CountUploadRequestFiles count the files of the uploads created through an upload request
+func (b *Backend) CountUploadRequestFiles(requestID string) (count int, err error) {
+	err = b.db.Model(&common.File{}).
+		Joins("join uploads on uploads.id = files.upload_id").
+		Where("uploads.request_id = ?", requestID).
+		Where("files.status NOT IN (?)", []string{common.FileRemoved, common.FileDeleted}).
+		Count(&count).Error
+	if err != nil {
+		return -1, err
+	}
+
+	return count, nil
+}
+
+// ForEachUploadRequest execute f for every upload request in the database
+func (b *Backend) ForEachUploadRequest(f func(request *common.UploadRequest) error) (err error) {
+	rows, err := b.db.Model(&common.UploadRequest{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		request := &common.UploadRequest{}
+		err = b.db.ScanRows(rows, request)
+		if err != nil {
+			return err
+		}
+		err = f(request)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteUploadRequest remove an upload request from the DB, uploads created through the request are kept
+func (b *Backend) DeleteUploadRequest(requestID string) (deleted bool, err error) {
+	result := b.db.Delete(&common.UploadRequest{ID: requestID})
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to delete upload request metadata")
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// PurgeUploadRequests delete the upload requests that expired before the deadline
+func (b *Backend) PurgeUploadRequests(deadline time.Time) (removed int, err error) {
+	result := b.db.Where("expire_at < ?", deadline).Delete(&common.UploadRequest{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return int(result.RowsAffected), nil
+}
@@ -0,0 +1,134 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func createUploadRequest(t *testing.T, b *Backend, request *common.UploadRequest) {
+	if request.ID == "" {
+		request.ID = common.GenerateRandomID(16)
+	}
+	err := b.CreateUploadRequest(request)
+	require.NoError(t, err, "create upload request error : %s", err)
+}
+
+func TestBackend_CreateUploadRequest(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	createUser(t, b, &common.User{ID: "user"})
+
+	request := &common.UploadRequest{User: "user", Message: "please send me the logs"}
+	createUploadRequest(t, b, request)
+	require.NotZero(t, request.CreatedAt, "missing creation date")
+
+	result, err := b.GetUploadRequest(request.ID)
+	require.NoError(t, err, "get upload request error")
+	require.NotNil(t, result, "missing upload request")
+	require.Equal(t, request.Message, result.Message, "invalid upload request message")
+
+	result, err = b.GetUploadRequest("missing")
+	require.NoError(t, err, "get upload request error")
+	require.Nil(t, result, "upload request should not exist")
+}
+
+func TestBackend_GetUploadRequests(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	createUser(t, b, &common.User{ID: "user"})
+	createUser(t, b, &common.User{ID: "other"})
+
+	for i := 0; i < 3; i++ {
+		createUploadRequest(t, b, &common.UploadRequest{User: "user"})
+	}
+	createUploadRequest(t, b, &common.UploadRequest{User: "other"})
+
+	requests, cursor, err := b.GetUploadRequests("user", &common.PagingQuery{})
+	require.NoError(t, err, "get upload requests error")
+	require.NotNil(t, cursor, "missing cursor")
+	require.Len(t, requests, 3, "invalid upload request count")
+
+	_, _, err = b.GetUploadRequests("user", nil)
+	require.Error(t, err, "missing paging query error expected")
+}
+
+func TestBackend_DeleteUploadRequest(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	createUser(t, b, &common.User{ID: "user"})
+
+	request := &common.UploadRequest{User: "user"}
+	createUploadRequest(t, b, request)
+
+	upload := &common.Upload{User: "user", RequestID: request.ID}
+	createUpload(t, b, upload)
+
+	deleted, err := b.DeleteUploadRequest(request.ID)
+	require.NoError(t, err, "delete upload request error")
+	require.True(t, deleted, "upload request not deleted")
+
+	result, err := b.GetUploadRequest(request.ID)
+	require.NoError(t, err, "get upload request error")
+	require.Nil(t, result, "upload request should be deleted")
+
+	// Uploads created through the request are kept
+	u, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.NotNil(t, u, "upload should not be deleted")
+}
+
+func TestBackend_CountUploadRequestFiles(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{RequestID: "request"}
+	upload.NewFile().Status = common.FileUploaded
+	upload.NewFile().Status = common.FileMissing
+	upload.NewFile().Status = common.FileRemoved
+	createUpload(t, b, upload)
+
+	createUpload(t, b, &common.Upload{Files: []*common.File{{Name: "other"}}})
+
+	count, err := b.CountUploadRequestFiles("request")
+	require.NoError(t, err, "count upload request files error")
+	require.Equal(t, 2, count, "invalid upload request file count")
+}
+
+func TestBackend_PurgeUploadRequests(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	createUser(t, b, &common.User{ID: "user"})
+
+	expired := time.Now().Add(-time.Hour)
+	createUploadRequest(t, b, &common.UploadRequest{User: "user", ExpireAt: &expired})
+
+	valid := time.Now().Add(time.Hour)
+	createUploadRequest(t, b, &common.UploadRequest{User: "user", ExpireAt: &valid})
+
+	createUploadRequest(t, b, &common.UploadRequest{User: "user"})
+
+	removed, err := b.PurgeUploadRequests(time.Now())
+	require.NoError(t, err, "purge upload requests error")
+	require.Equal(t, 1, removed, "invalid purged upload request count")
+}
+
+func TestBackend_GetUploadRequestStatistics(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{RequestID: "request"}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	file.Size = 42
+	createUpload(t, b, upload)
+
+	createUpload(t, b, &common.Upload{})
+
+	stats, err := b.GetUploadRequestStatistics("request")
+	require.NoError(t, err, "get upload request statistics error")
+	require.Equal(t, 1, stats.Uploads, "invalid upload count")
+	require.Equal(t, 1, stats.Files, "invalid file count")
+	require.Equal(t, int64(42), stats.TotalSize, "invalid total size")
+}
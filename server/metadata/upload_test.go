@@ -48,6 +48,97 @@ func TestBackend_GetUpload(t *testing.T) {
 	require.Equal(t, upload.UploadToken, result.UploadToken, "invalid upload token")
 }
 
+func TestBackend_UpdateUpload(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+
+	createUpload(t, b, upload)
+
+	upload.Comments = "foo bar"
+	file.Name = "updated"
+	err := b.UpdateUpload(upload)
+	require.NoError(t, err, "update upload error")
+
+	result, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Equal(t, "foo bar", result.Comments, "invalid upload comments")
+
+	files, err := b.GetFiles(upload.ID)
+	require.NoError(t, err, "get files error")
+	require.Len(t, files, 1, "invalid file count")
+	require.Equal(t, "file", files[0].Name, "files should not be updated")
+}
+
+func TestBackend_UpdateUploadColumns(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{Comments: "foo"}
+	createUpload(t, b, upload)
+
+	updated, err := b.UpdateUploadColumns(upload, map[string]interface{}{"comments": "bar"})
+	require.NoError(t, err, "update upload columns error")
+	require.True(t, updated, "upload should be updated")
+
+	result, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Equal(t, "bar", result.Comments, "invalid upload comments")
+	require.Equal(t, upload.ShareSecret, result.ShareSecret, "invalid upload share secret")
+
+	// Frozen uploads are not updated unless they were already frozen
+	result.Frozen = true
+	err = b.UpdateUpload(result)
+	require.NoError(t, err, "update upload error")
+
+	updated, err = b.UpdateUploadColumns(upload, map[string]interface{}{"comments": "baz"})
+	require.NoError(t, err, "update upload columns error")
+	require.False(t, updated, "frozen upload should not be updated")
+
+	updated, err = b.UpdateUploadColumns(result, map[string]interface{}{"comments": "baz"})
+	require.NoError(t, err, "update upload columns error")
+	require.True(t, updated, "upload should be updated")
+
+	result, err = b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Equal(t, "baz", result.Comments, "invalid upload comments")
+	require.True(t, result.Frozen, "upload should still be frozen")
+}
+
+func TestBackend_UpdateUploadExpiration(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	upload := &common.Upload{TTL: 60}
+	createUpload(t, b, upload)
+
+	// Concurrent changes must not be overwritten
+	upload.Comments = "foo bar"
+	err := b.UpdateUpload(upload)
+	require.NoError(t, err, "update upload error")
+
+	deadline := time.Now().Add(time.Hour)
+	err = b.UpdateUploadExpiration(upload.ID, 3600, &deadline)
+	require.NoError(t, err, "update upload expiration error")
+
+	result, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Equal(t, 3600, result.TTL, "invalid upload ttl")
+	require.Equal(t, deadline.Unix(), result.ExpireAt.Unix(), "invalid upload expiration date")
+	require.Equal(t, "foo bar", result.Comments, "invalid upload comments")
+
+	err = b.UpdateUploadExpiration(upload.ID, -1, nil)
+	require.NoError(t, err, "update upload expiration error")
+
+	result, err = b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Equal(t, -1, result.TTL, "invalid upload ttl")
+	require.Nil(t, result.ExpireAt, "invalid upload expiration date")
+
+	err = b.UpdateUploadExpiration("not found", -1, nil)
+	require.Error(t, err, "missing update upload expiration error")
+}
+
 func TestBackend_GetUpload_NotFound(t *testing.T) {
 	b := newTestMetadataBackend()
 
@@ -222,37 +313,101 @@ func TestBackend_DeleteExpiredUploads(t *testing.T) {
 	err = b.db.Save(upload3).Error
 	require.NoError(t, err, "update upload error")
 
-	removed, err := b.DeleteExpiredUploads()
+	var expired []string
+	removed, err := b.DeleteExpiredUploads(0, func(upload *common.Upload) { expired = append(expired, upload.ID) })
 	require.Nil(t, err, "delete expired upload error")
 	require.Equal(t, 1, removed, "removed expired upload count mismatch")
+	require.Equal(t, []string{upload3.ID}, expired, "invalid expired uploads")
+}
+
+func TestBackend_DeleteExpiredUploadsLimit(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	deadline := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		createUpload(t, b, &common.Upload{ExpireAt: &deadline})
+	}
+
+	removed, err := b.DeleteExpiredUploads(2, nil)
+	require.Nil(t, err, "delete expired upload error")
+	require.Equal(t, 2, removed, "removed expired upload count mismatch")
+
+	removed, err = b.DeleteExpiredUploads(2, nil)
+	require.Nil(t, err, "delete expired upload error")
+	require.Equal(t, 1, removed, "removed expired upload count mismatch")
+}
+
+func TestBackend_GetExpiringUploads(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	soon := time.Now().Add(time.Hour)
+	later := time.Now().Add(48 * time.Hour)
+	expired := time.Now().Add(-time.Hour)
+
+	upload1 := &common.Upload{User: "user", ExpireAt: &soon}
+	createUpload(t, b, upload1)
+	createUpload(t, b, &common.Upload{User: "user", ExpireAt: &later})
+	createUpload(t, b, &common.Upload{User: "user", ExpireAt: &expired})
+	createUpload(t, b, &common.Upload{User: "user"})
+	createUpload(t, b, &common.Upload{ExpireAt: &soon})
+
+	uploads, err := b.GetExpiringUploads(time.Now().Add(24*time.Hour), 0)
+	require.NoError(t, err, "get expiring uploads error")
+	require.Len(t, uploads, 1, "invalid expiring upload count")
+	require.Equal(t, upload1.ID, uploads[0].ID, "invalid expiring upload")
+
+	err = b.SetUploadExpirationNotified(uploads[0])
+	require.NoError(t, err, "set upload expiration notified error")
+
+	uploads, err = b.GetExpiringUploads(time.Now().Add(24*time.Hour), 0)
+	require.NoError(t, err, "get expiring uploads error")
+	require.Len(t, uploads, 0, "invalid expiring upload count")
+
+	// Extending the upload re-arms the notification
+	extended := soon.Add(time.Hour)
+	upload1.ExpireAt = &extended
+	err = b.UpdateUpload(upload1)
+	require.NoError(t, err, "update upload error")
+
+	uploads, err = b.GetExpiringUploads(time.Now().Add(24*time.Hour), 0)
+	require.NoError(t, err, "get expiring uploads error")
+	require.Len(t, uploads, 1, "invalid expiring upload count")
 }
 
 func TestBackend_PurgeDeletedUploads(t *testing.T) {
 	b := newTestMetadataBackend()
 
 	upload := &common.Upload{}
-	upload.NewFile().Status = common.FileUploaded
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
 	createUpload(t, b, upload)
 
-	purged, err := b.PurgeDeletedUploads()
+	err := b.SaveArchiveIndex(file, "zip", []*common.ArchiveMember{{Name: "foo"}})
+	require.NoError(t, err, "save archive index error")
+
+	purged, err := b.PurgeDeletedUploads(0)
 	require.NoError(t, err, "purge deleted upload error")
 	require.Equal(t, 0, purged, "invalid purged count")
 
 	err = b.DeleteUpload(upload.ID)
 	require.NoError(t, err, "delete upload error")
 
-	purged, err = b.PurgeDeletedUploads()
+	purged, err = b.PurgeDeletedUploads(0)
 	require.NoError(t, err, "purge deleted upload error")
 
 	f := func(file *common.File) error {
 		return b.UpdateFileStatus(file, file.Status, common.FileDeleted)
 	}
-	err = b.ForEachRemovedFile(f)
+	err = b.ForEachRemovedFile(0, f)
 	require.NoError(t, err, "delete files error")
 
-	purged, err = b.PurgeDeletedUploads()
+	purged, err = b.PurgeDeletedUploads(0)
 	require.NoError(t, err, "purge deleted upload error")
 	require.Equal(t, 1, purged, "invalid purged count")
+
+	members, err := b.GetArchiveMembers(file.ID)
+	require.NoError(t, err, "get archive members error")
+	require.Len(t, members, 0, "archive members not purged")
 }
 
 func TestBackend_ForEachUpload(t *testing.T) {
@@ -279,3 +434,45 @@ func TestBackend_ForEachUpload(t *testing.T) {
 	err = b.ForEachUpload(f)
 	require.Errorf(t, err, "expected")
 }
+
+func TestBackend_SearchUploads(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	expired := time.Now().Add(-time.Hour)
+	createUpload(t, b, &common.Upload{User: "user", Token: "token", ExpireAt: &expired})
+
+	big := &common.Upload{User: "user"}
+	file := big.NewFile()
+	file.Status = common.FileUploaded
+	file.Size = 1000
+	createUpload(t, b, big)
+
+	createUpload(t, b, &common.Upload{})
+
+	count := func(filter *common.UploadFilter) int {
+		uploads, cursor, err := b.SearchUploads(filter, true, &common.PagingQuery{})
+		require.NoError(t, err, "search uploads error")
+		require.NotNil(t, cursor, "missing cursor")
+		return len(uploads)
+	}
+
+	yes := true
+	no := false
+
+	require.Equal(t, 3, count(nil), "invalid upload count")
+	require.Equal(t, 2, count(&common.UploadFilter{User: "user"}), "invalid user upload count")
+	require.Equal(t, 1, count(&common.UploadFilter{Token: "token"}), "invalid token upload count")
+	require.Equal(t, 1, count(&common.UploadFilter{Anonymous: &yes}), "invalid anonymous upload count")
+	require.Equal(t, 2, count(&common.UploadFilter{Anonymous: &no}), "invalid non anonymous upload count")
+	require.Equal(t, 1, count(&common.UploadFilter{Expired: &yes}), "invalid expired upload count")
+	require.Equal(t, 2, count(&common.UploadFilter{Expired: &no}), "invalid non expired upload count")
+	require.Equal(t, 1, count(&common.UploadFilter{MinSize: 500}), "invalid min size upload count")
+	require.Equal(t, 2, count(&common.UploadFilter{MaxSize: 500}), "invalid max size upload count")
+
+	future := time.Now().Add(time.Hour)
+	require.Equal(t, 0, count(&common.UploadFilter{CreatedAfter: &future}), "invalid created after upload count")
+	require.Equal(t, 3, count(&common.UploadFilter{CreatedBefore: &future}), "invalid created before upload count")
+
+	_, _, err := b.SearchUploads(nil, false, nil)
+	require.Error(t, err, "missing paging query error expected")
+}
@@ -124,17 +124,52 @@ func (b *Backend) DeleteUserUploads(userID string, tokenStr string) (removed int
 // DeleteUser delete a user from the DB
 func (b *Backend) DeleteUser(userID string) (deleted bool, err error) {
 	err = b.db.Transaction(func(tx *gorm.DB) (err error) {
-		_, err = b.DeleteUserUploads(userID, "")
+		// Group uploads are not deleted with the user who created them
+		err = tx.Model(&common.Upload{}).Where("uploads.user = ? AND uploads.group_id <> ''", userID).Updates(map[string]interface{}{"user": "", "token": ""}).Error
 		if err != nil {
-			return err
+			return fmt.Errorf("unable to detach group uploads")
+		}
+
+		// Delete user uploads within the transaction
+		var uploadIDs []string
+		err = tx.Model(&common.Upload{}).Where(&common.Upload{User: userID}).Pluck("id", &uploadIDs).Error
+		if err != nil {
+			return fmt.Errorf("unable to get user uploads")
+		}
+
+		txBackend := &Backend{Config: b.Config, db: tx}
+		for _, uploadID := range uploadIDs {
+			err = txBackend.DeleteUpload(uploadID)
+			if err != nil {
+				return err
+			}
 		}
 
 		// Delete user tokens
-		err = tx.Delete(&common.Token{UserID: userID}).Error
+		// The token is the primary key, deleting a token with only the user id set would delete every token
+		err = tx.Where(&common.Token{UserID: userID}).Delete(&common.Token{}).Error
 		if err != nil {
 			return fmt.Errorf("unable to delete tokens metadata")
 		}
 
+		// Delete user upload requests
+		err = tx.Where(&common.UploadRequest{User: userID}).Delete(&common.UploadRequest{}).Error
+		if err != nil {
+			return fmt.Errorf("unable to delete upload requests metadata")
+		}
+
+		// Delete user webhooks
+		err = tx.Where(&common.Webhook{User: userID}).Delete(&common.Webhook{}).Error
+		if err != nil {
+			return fmt.Errorf("unable to delete webhooks metadata")
+		}
+
+		// Delete user group memberships
+		err = tx.Where(&common.GroupMember{UserID: userID}).Delete(&common.GroupMember{}).Error
+		if err != nil {
+			return fmt.Errorf("unable to delete group members metadata")
+		}
+
 		// Delete user
 		result := tx.Unscoped().Delete(&common.User{ID: userID})
 		if result.Error != nil {
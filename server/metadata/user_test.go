@@ -131,6 +131,31 @@ func TestBackend_DeleteUser(t *testing.T) {
 	require.Nil(t, user, "user not nil")
 }
 
+func TestBackend_DeleteUserTokens(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	createUser(t, b, user)
+
+	other := common.NewUser(common.ProviderLocal, "other")
+	otherToken := other.NewToken()
+	createUser(t, b, other)
+
+	deleted, err := b.DeleteUser(user.ID)
+	require.NoError(t, err, "delete user error")
+	require.True(t, deleted, "invalid deleted value")
+
+	result, err := b.GetToken(token.Token)
+	require.NoError(t, err, "get token error")
+	require.Nil(t, result, "user token should be deleted")
+
+	// Only the tokens of the deleted user are deleted
+	result, err = b.GetToken(otherToken.Token)
+	require.NoError(t, err, "get token error")
+	require.NotNil(t, result, "other user token should not be deleted")
+}
+
 func TestBackend_ForEachUserUploads(t *testing.T) {
 	b := newTestMetadataBackend()
 
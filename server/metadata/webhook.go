@@ -0,0 +1,169 @@
+package metadata
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	paginator "github.com/pilagod/gorm-cursor-paginator"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CreateWebhook create a new webhook in DB
+func (b *Backend) CreateWebhook(webhook *common.Webhook) (err error) {
+	return b.db.Create(webhook).Error
+}
+
+// GetWebhook return a webhook from DB ( return nil and no error if not found )
+func (b *Backend) GetWebhook(ID string) (webhook *common.Webhook, err error) {
+	webhook = &common.Webhook{}
+	err = b.db.Where(&common.Webhook{ID: ID}).Take(webhook).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return webhook, err
+}
+
+// GetUserWebhooks return the webhooks a user registered for all his uploads
+func (b *Backend) GetUserWebhooks(userID string, pagingQuery *common.PagingQuery) (webhooks []*common.Webhook, cursor *paginator.Cursor, err error) {
+	if pagingQuery == nil {
+		return nil, nil, fmt.Errorf("missing paging query")
+	}
+	if userID == "" {
+		return nil, nil, fmt.Errorf("missing user id")
+	}
+
+	stmt := b.db.Model(&common.Webhook{}).Where("webhooks.user = ? AND webhooks.upload_id = ''", userID)
+
+	p := pagingQuery.Paginator()
+	p.SetKeys("CreatedAt", "ID")
+
+	err = p.Paginate(stmt, &webhooks).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := p.GetNextCursor()
+	return webhooks, &c, err
+}
+
+// GetUploadWebhooks return the webhooks registered for an upload
+func (b *Backend) GetUploadWebhooks(uploadID string) (webhooks []*common.Webhook, err error) {
+	err = b.db.Where(&common.Webhook{UploadID: uploadID}).Order("created_at").Find(&webhooks).Error
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// GetWebhooksForUpload return the user and upload webhooks to notify of an upload event
+func (b *Backend) GetWebhooksForUpload(upload *common.Upload) (webhooks []*common.Webhook, err error) {
+	stmt := b.db.Model(&common.Webhook{}).Where("webhooks.upload_id = ?", upload.ID)
+	if upload.User != "" {
+		stmt = stmt.Or("webhooks.user = ? AND webhooks.upload_id = ''", upload.User)
+	}
+
+	err = stmt.Find(&webhooks).Error
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook remove a webhook from the DB, pending deliveries are still sent
+func (b *Backend) DeleteWebhook(webhookID string) (deleted bool, err error) {
+	result := b.db.Delete(&common.Webhook{ID: webhookID})
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to delete webhook metadata")
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// ForEachWebhook execute f for every webhook in the database
+func (b *Backend) ForEachWebhook(f func(webhook *common.Webhook) error) (err error) {
+	rows, err := b.db.Model(&common.Webhook{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		webhook := &common.Webhook{}
+		err = b.db.ScanRows(rows, webhook)
+		if err != nil {
+			return err
+		}
+		err = f(webhook)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateWebhookDelivery add a webhook delivery to the outbox
+func (b *Backend) CreateWebhookDelivery(delivery *common.WebhookDelivery) (err error) {
+	return b.db.Create(delivery).Error
+}
+
+// GetWebhookDelivery return a webhook delivery from DB ( return nil and no error if not found )
+func (b *Backend) GetWebhookDelivery(ID string) (delivery *common.WebhookDelivery, err error) {
+	delivery = &common.WebhookDelivery{}
+	err = b.db.Where(&common.WebhookDelivery{ID: ID}).Take(delivery).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return delivery, err
+}
+
+// GetDueWebhookDeliveries return the pending webhook deliveries to send before the deadline
+// limit is the maximum number of deliveries to return, 0 means no limit
+func (b *Backend) GetDueWebhookDeliveries(deadline time.Time, limit int) (deliveries []*common.WebhookDelivery, err error) {
+	stmt := b.db.Where(&common.WebhookDelivery{Status: common.WebhookDeliveryPending}).Where("next_attempt_at <= ?", deadline).Order("next_attempt_at")
+	if limit > 0 {
+		stmt = stmt.Limit(limit)
+	}
+
+	err = stmt.Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ClaimWebhookDelivery reserve a webhook delivery attempt until the deadline
+// The attempts counter ensure only one Plik instance sends the delivery
+func (b *Backend) ClaimWebhookDelivery(delivery *common.WebhookDelivery, deadline time.Time) (claimed bool, err error) {
+	result := b.db.Model(&common.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ?", delivery.ID, common.WebhookDeliveryPending, delivery.Attempts).
+		Updates(map[string]interface{}{"attempts": delivery.Attempts + 1, "next_attempt_at": deadline})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return false, nil
+	}
+
+	delivery.Attempts++
+	delivery.NextAttemptAt = deadline
+	return true, nil
+}
+
+// UpdateWebhookDelivery update a webhook delivery in DB
+func (b *Backend) UpdateWebhookDelivery(delivery *common.WebhookDelivery) (err error) {
+	return b.db.Save(delivery).Error
+}
+
+// DeleteWebhookDelivery remove a webhook delivery from the outbox
+func (b *Backend) DeleteWebhookDelivery(deliveryID string) (err error) {
+	return b.db.Delete(&common.WebhookDelivery{ID: deliveryID}).Error
+}
@@ -0,0 +1,130 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func createWebhook(t *testing.T, b *Backend, webhook *common.Webhook) {
+	err := webhook.PrepareInsert(nil)
+	require.NoError(t, err, "prepare webhook error")
+	err = b.CreateWebhook(webhook)
+	require.NoError(t, err, "create webhook error")
+}
+
+func TestBackend_CreateWebhook(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	webhook := &common.Webhook{User: "user", URL: "https://ci.example.com/hook"}
+	createWebhook(t, b, webhook)
+
+	result, err := b.GetWebhook(webhook.ID)
+	require.NoError(t, err, "get webhook error")
+	require.NotNil(t, result, "missing webhook")
+	require.Equal(t, webhook.URL, result.URL, "invalid webhook url")
+	require.Equal(t, webhook.Secret, result.Secret, "invalid webhook secret")
+
+	result, err = b.GetWebhook("nope")
+	require.NoError(t, err, "get webhook error")
+	require.Nil(t, result, "unexpected webhook")
+}
+
+func TestBackend_GetUserWebhooks(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	createWebhook(t, b, &common.Webhook{User: "user", URL: "https://ci.example.com/hook"})
+	createWebhook(t, b, &common.Webhook{User: "user", URL: "https://ci.example.com/hook"})
+	createWebhook(t, b, &common.Webhook{User: "user", UploadID: "upload", URL: "https://ci.example.com/hook"})
+	createWebhook(t, b, &common.Webhook{User: "other", URL: "https://ci.example.com/hook"})
+
+	webhooks, cursor, err := b.GetUserWebhooks("user", &common.PagingQuery{})
+	require.NoError(t, err, "get user webhooks error")
+	require.NotNil(t, cursor, "missing cursor")
+	require.Len(t, webhooks, 2, "invalid webhook count")
+
+	_, _, err = b.GetUserWebhooks("", &common.PagingQuery{})
+	require.Error(t, err, "missing user error expected")
+}
+
+func TestBackend_GetWebhooksForUpload(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	createWebhook(t, b, &common.Webhook{User: "user", URL: "https://ci.example.com/hook"})
+	createWebhook(t, b, &common.Webhook{UploadID: "upload", URL: "https://ci.example.com/hook"})
+	createWebhook(t, b, &common.Webhook{UploadID: "other", URL: "https://ci.example.com/hook"})
+	createWebhook(t, b, &common.Webhook{User: "other", URL: "https://ci.example.com/hook"})
+
+	webhooks, err := b.GetWebhooksForUpload(&common.Upload{ID: "upload", User: "user"})
+	require.NoError(t, err, "get upload webhooks error")
+	require.Len(t, webhooks, 2, "invalid webhook count")
+
+	webhooks, err = b.GetWebhooksForUpload(&common.Upload{ID: "upload"})
+	require.NoError(t, err, "get upload webhooks error")
+	require.Len(t, webhooks, 1, "invalid webhook count")
+
+	webhooks, err = b.GetUploadWebhooks("upload")
+	require.NoError(t, err, "get upload webhooks error")
+	require.Len(t, webhooks, 1, "invalid webhook count")
+}
+
+func TestBackend_DeleteWebhook(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	webhook := &common.Webhook{User: "user", URL: "https://ci.example.com/hook"}
+	createWebhook(t, b, webhook)
+
+	deleted, err := b.DeleteWebhook(webhook.ID)
+	require.NoError(t, err, "delete webhook error")
+	require.True(t, deleted, "webhook should have been deleted")
+
+	deleted, err = b.DeleteWebhook(webhook.ID)
+	require.NoError(t, err, "delete webhook error")
+	require.False(t, deleted, "webhook should not have been deleted")
+}
+
+func TestBackend_WebhookDeliveries(t *testing.T) {
+	b := newTestMetadataBackend()
+
+	due := &common.WebhookDelivery{ID: "due", URL: "https://ci.example.com/hook", Status: common.WebhookDeliveryPending, NextAttemptAt: time.Now().Add(-time.Minute)}
+	err := b.CreateWebhookDelivery(due)
+	require.NoError(t, err, "create webhook delivery error")
+
+	later := &common.WebhookDelivery{ID: "later", URL: "https://ci.example.com/hook", Status: common.WebhookDeliveryPending, NextAttemptAt: time.Now().Add(time.Hour)}
+	err = b.CreateWebhookDelivery(later)
+	require.NoError(t, err, "create webhook delivery error")
+
+	failed := &common.WebhookDelivery{ID: "failed", URL: "https://ci.example.com/hook", Status: common.WebhookDeliveryFailed, NextAttemptAt: time.Now().Add(-time.Minute)}
+	err = b.CreateWebhookDelivery(failed)
+	require.NoError(t, err, "create webhook delivery error")
+
+	deliveries, err := b.GetDueWebhookDeliveries(time.Now(), 10)
+	require.NoError(t, err, "get due webhook deliveries error")
+	require.Len(t, deliveries, 1, "invalid delivery count")
+	require.Equal(t, "due", deliveries[0].ID)
+
+	// Only one claim can succeed
+	stale := *deliveries[0]
+	claimed, err := b.ClaimWebhookDelivery(deliveries[0], time.Now().Add(time.Minute))
+	require.NoError(t, err, "claim webhook delivery error")
+	require.True(t, claimed, "delivery should have been claimed")
+	require.Equal(t, 1, deliveries[0].Attempts, "invalid attempts")
+
+	claimed, err = b.ClaimWebhookDelivery(&stale, time.Now().Add(time.Minute))
+	require.NoError(t, err, "claim webhook delivery error")
+	require.False(t, claimed, "delivery should not have been claimed twice")
+
+	deliveries, err = b.GetDueWebhookDeliveries(time.Now(), 10)
+	require.NoError(t, err, "get due webhook deliveries error")
+	require.Len(t, deliveries, 0, "invalid delivery count")
+
+	err = b.DeleteWebhookDelivery("due")
+	require.NoError(t, err, "delete webhook delivery error")
+
+	delivery, err := b.GetWebhookDelivery("due")
+	require.NoError(t, err, "get webhook delivery error")
+	require.Nil(t, delivery, "unexpected webhook delivery")
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"crypto/x509"
 	"net/http"
 
 	"github.com/root-gg/plik/server/common"
@@ -24,7 +25,12 @@ func Authenticate(allowToken bool) context.Middleware {
 							return
 						}
 						if token == nil {
-							ctx.Forbidden("invalid token")
+							// Get group from group token header
+							if !authenticateGroupToken(ctx, tokenHeader) {
+								return
+							}
+
+							next.ServeHTTP(resp, req)
 							return
 						}
 
@@ -88,9 +94,91 @@ func Authenticate(allowToken bool) context.Middleware {
 					// Save user in the request context
 					ctx.SetUser(user)
 				}
+
+				if ctx.GetUser() == nil && config.IsClientCertAuthentication() && req.TLS != nil && len(req.TLS.VerifiedChains) > 0 {
+					// Browsers send the client certificate with cross-site requests too. Plik doesn't allow
+					// cross-origin requests so a custom header can only be set by a same-origin page or an API client
+					if req.Method != "GET" && req.Method != "HEAD" && req.Header.Get("X-XSRFToken") == "" {
+						ctx.Forbidden("missing xsrf header")
+						return
+					}
+
+					// Get user from the client certificate verified during the TLS handshake
+					user := getCertificateUser(ctx, req.TLS.VerifiedChains[0][0])
+					if user == nil {
+						return
+					}
+
+					// Save user in the request context
+					ctx.SetUser(user)
+				}
 			}
 
 			next.ServeHTTP(resp, req)
 		})
 	}
 }
+
+// authenticateGroupToken save the group owning a group token and the token in the request context
+// On error the response is written and false is returned
+func authenticateGroupToken(ctx *context.Context, tokenStr string) bool {
+	groupToken, err := ctx.GetMetadataBackend().GetGroupToken(tokenStr)
+	if err != nil {
+		ctx.InternalServerError("unable to get token", err)
+		return false
+	}
+	if groupToken == nil {
+		ctx.Forbidden("invalid token")
+		return false
+	}
+
+	group, err := ctx.GetMetadataBackend().GetGroup(groupToken.GroupID)
+	if err != nil {
+		ctx.InternalServerError("unable to get group", err)
+		return false
+	}
+	if group == nil {
+		ctx.Forbidden("invalid token")
+		return false
+	}
+
+	// Save group and token in the request context
+	ctx.SetGroup(group)
+	ctx.SetToken(&common.Token{Token: groupToken.Token, Comment: groupToken.Comment})
+
+	return true
+}
+
+// getCertificateUser return the user mapped from a verified client certificate, users are created on first use
+// On error the response is written and nil is returned
+func getCertificateUser(ctx *context.Context, cert *x509.Certificate) (user *common.User) {
+	login, err := common.GetCertificateLogin(cert, ctx.GetConfig().SslClientUserAttribute)
+	if err != nil {
+		ctx.Forbidden("invalid client certificate : %s", err)
+		return nil
+	}
+
+	userID := common.GetUserID(common.ProviderCert, login)
+	user, err = ctx.GetMetadataBackend().GetUser(userID)
+	if err != nil {
+		ctx.InternalServerError("unable to get user", err)
+		return nil
+	}
+
+	if user == nil {
+		user = common.NewCertificateUser(cert, login)
+		err = ctx.GetMetadataBackend().CreateUser(user)
+		if err != nil {
+			// The user might have been created by a concurrent request
+			existing, errGet := ctx.GetMetadataBackend().GetUser(userID)
+			if errGet != nil || existing == nil {
+				ctx.InternalServerError("unable to create user", err)
+				return nil
+			}
+			return existing
+		}
+		ctx.GetLogger().Infof("created user %s from client certificate", user.ID)
+	}
+
+	return user
+}
@@ -2,6 +2,9 @@ package middleware
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"net/http"
 	"testing"
 
@@ -52,6 +55,47 @@ func TestAuthenticateToken(t *testing.T) {
 	require.Equal(t, token.Token, tokenFromContext.Token, "invalid token from context")
 }
 
+func TestAuthenticateGroupToken(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Authentication = true
+
+	group := common.NewGroup("team")
+	err := ctx.GetMetadataBackend().CreateGroup(group)
+	require.NoError(t, err, "unable to create group")
+
+	token := group.NewToken()
+	err = ctx.GetMetadataBackend().CreateGroupToken(token)
+	require.NoError(t, err, "unable to create group token")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("X-PlikToken", token.Token)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Nil(t, ctx.GetUser(), "invalid user from context")
+	require.Equal(t, group.ID, ctx.GetGroup().ID, "invalid group from context")
+	require.Equal(t, token.Token, ctx.GetToken().Token, "invalid token from context")
+}
+
+func TestAuthenticateInvalidToken(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Authentication = true
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("X-PlikToken", "invalid")
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestForbidden(t, rr, "invalid token")
+}
+
 func TestAuthenticateInvalidSessionCookie(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	ctx.GetConfig().Authentication = true
@@ -184,3 +228,91 @@ func TestAuthenticateAdminUser(t *testing.T) {
 	require.Equal(t, user.ID, ctx.GetUser().ID, "invalid user from context")
 	require.True(t, ctx.IsAdmin(), "context is not admin")
 }
+
+func newClientCertRequest(t *testing.T, cert *x509.Certificate) *http.Request {
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	return req
+}
+
+func TestAuthenticateClientCertificate(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Authentication = true
+	ctx.GetConfig().SslClientAuth = common.SslClientAuthRequest
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "runner"}}
+
+	// User is created on first use
+	req := newClientCertRequest(t, cert)
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+
+	user := ctx.GetUser()
+	require.NotNil(t, user, "missing user from context")
+	require.Equal(t, common.GetUserID(common.ProviderCert, "runner"), user.ID, "invalid user from context")
+
+	user, err := ctx.GetMetadataBackend().GetUser(user.ID)
+	require.NoError(t, err, "unable to get user")
+	require.NotNil(t, user, "user has not been created")
+	require.Equal(t, "runner", user.Login, "invalid user login")
+
+	// Existing user is reused
+	config := ctx.GetConfig()
+	metadataBackend := ctx.GetMetadataBackend()
+	ctx = &context.Context{}
+	ctx.SetConfig(config)
+	ctx.SetLogger(config.NewLogger())
+	ctx.SetMetadataBackend(metadataBackend)
+	req = newClientCertRequest(t, cert)
+	rr = ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Equal(t, user.ID, ctx.GetUser().ID, "invalid user from context")
+}
+
+func TestAuthenticateClientCertificateXSRF(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Authentication = true
+	ctx.GetConfig().SslClientAuth = common.SslClientAuthRequest
+
+	req := newClientCertRequest(t, &x509.Certificate{Subject: pkix.Name{CommonName: "runner"}})
+	req.Method = "POST"
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "missing xsrf header")
+	require.Nil(t, ctx.GetUser(), "unexpected user from context")
+
+	req.Header.Set("X-XSRFToken", "1")
+	rr = ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.NotNil(t, ctx.GetUser(), "missing user from context")
+}
+
+func TestAuthenticateClientCertificateMissingAttribute(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Authentication = true
+	ctx.GetConfig().SslClientAuth = common.SslClientAuthRequest
+	ctx.GetConfig().SslClientUserAttribute = common.CertificateAttributeEmail
+
+	req := newClientCertRequest(t, &x509.Certificate{Subject: pkix.Name{CommonName: "runner"}})
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestForbidden(t, rr, "invalid client certificate")
+}
+
+func TestAuthenticateClientCertificateDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Authentication = true
+
+	req := newClientCertRequest(t, &x509.Certificate{Subject: pkix.Name{CommonName: "runner"}})
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Nil(t, ctx.GetUser(), "unexpected user from context")
+}
@@ -1,27 +1,78 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/root-gg/plik/server/common"
 
 	"github.com/root-gg/plik/server/context"
 )
 
+// Quick mode upload options and response format headers
+const (
+	headerTTL          = "X-Plik-TTL"
+	headerOneShot      = "X-Plik-OneShot"
+	headerStream       = "X-Plik-Stream"
+	headerLogin        = "X-Plik-Login"
+	headerPassword     = "X-Plik-Password"
+	headerComments     = "X-Plik-Comments"
+	headerMaxDownloads = "X-Plik-Max-Downloads"
+	headerOutput       = "X-Plik-Output"
+)
+
 // CreateUpload create a new upload on the fly to be used in the next handler
 func CreateUpload(ctx *context.Context, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
-		if !ctx.IsWhitelisted() {
+		// Upload requests let anyone knowing the request URL upload files to the requesting user
+		if ctx.GetUploadRequest() == nil && !ctx.IsWhitelisted() {
 			ctx.Forbidden("untrusted source IP address")
 			return
 		}
 
+		output := req.Header.Get(headerOutput)
+		if output == "" {
+			output = common.QuickOutputText
+		}
+		if !common.IsValidQuickOutput(output) {
+			ctx.InvalidParameter("%s header, expected %s, %s or %s", headerOutput, common.QuickOutputText, common.QuickOutputJSON, common.QuickOutputShell)
+			return
+		}
+
 		// Create upload
 		upload := &common.Upload{}
 
+		// Upload options can't be set when uploading through an upload request
+		if ctx.GetUploadRequest() == nil {
+			err := setUploadOptionsFromHeaders(req, upload)
+			if err != nil {
+				ctx.BadRequest(err.Error())
+				return
+			}
+		}
+
 		// Assign context parameters ( ip / user / token )
 		ctx.ConfigureUploadFromContext(upload)
 
+		// Protect upload with HTTP basic auth
+		// Save only a salted hash of the base64 version of "login:password" to authenticate further requests
+		if upload.Password != "" {
+			if upload.Login == "" {
+				upload.Login = "plik"
+			}
+
+			upload.ProtectedByPassword = true
+
+			var err error
+			upload.Password, err = common.HashUploadPassword(common.EncodeAuthBasicHeader(upload.Login, upload.Password))
+			if err != nil {
+				ctx.BadRequest("unable to generate password hash : %s", err)
+				return
+			}
+		}
+
 		// Set and validate upload parameters
 		err := upload.PrepareInsert(ctx.GetConfig())
 		if err != nil {
@@ -29,6 +80,16 @@ func CreateUpload(ctx *context.Context, next http.Handler) http.Handler {
 			return
 		}
 
+		// Check group quotas
+		if !ctx.CheckUploadGroup(upload) {
+			return
+		}
+
+		// Check the upload against the external upload policy
+		if !ctx.CheckUploadPolicy(upload) {
+			return
+		}
+
 		// Save the upload metadata
 		err = ctx.GetMetadataBackend().CreateUpload(upload)
 		if err != nil {
@@ -36,13 +97,62 @@ func CreateUpload(ctx *context.Context, next http.Handler) http.Handler {
 			return
 		}
 
+		ctx.NotifyEvent(common.EventUploadCreated, upload, nil)
+
 		// Save upload in the request context
 		ctx.SetUpload(upload)
 		ctx.SetUploadAdmin(true)
 
 		// Change the output of the addFile handler
 		ctx.SetQuick(true)
+		ctx.SetQuickOutput(output)
 
 		next.ServeHTTP(resp, req)
 	})
 }
+
+// setUploadOptionsFromHeaders set the upload options from the quick mode request headers
+func setUploadOptionsFromHeaders(req *http.Request, upload *common.Upload) (err error) {
+	if value := req.Header.Get(headerTTL); value != "" {
+		// Either a number of seconds ( -1 for no expiration ) or a duration like 24h
+		upload.TTL, err = strconv.Atoi(value)
+		if err != nil {
+			duration, errDuration := time.ParseDuration(value)
+			if errDuration != nil || duration < time.Second {
+				return fmt.Errorf("invalid %s header", headerTTL)
+			}
+			upload.TTL = int(duration.Seconds())
+		}
+		if upload.TTL == 0 {
+			return fmt.Errorf("invalid %s header", headerTTL)
+		}
+	}
+
+	if value := req.Header.Get(headerOneShot); value != "" {
+		upload.OneShot, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s header", headerOneShot)
+		}
+	}
+
+	// The file URL is only returned once the whole file has been sent so nobody could download a streamed file
+	if req.Header.Get(headerStream) != "" {
+		return fmt.Errorf("stream mode is not available in quick mode, create the upload first")
+	}
+
+	if value := req.Header.Get(headerMaxDownloads); value != "" {
+		upload.MaxDownloads, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s header", headerMaxDownloads)
+		}
+	}
+
+	upload.Comments = req.Header.Get(headerComments)
+	upload.Login = req.Header.Get(headerLogin)
+	upload.Password = req.Header.Get(headerPassword)
+	if upload.Login != "" && upload.Password == "" {
+		return fmt.Errorf("missing %s header", headerPassword)
+	}
+
+	return nil
+}
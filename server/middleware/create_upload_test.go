@@ -58,3 +58,136 @@ func TestCreateUploadNotWhitelisted(t *testing.T) {
 	CreateUpload(ctx, common.DummyHandler).ServeHTTP(rr, req)
 	context.TestForbidden(t, rr, "untrusted source IP address")
 }
+
+func TestCreateUploadRequest(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Authentication = true
+	ctx.GetConfig().NoAnonymousUploads = true
+	ctx.SetWhitelisted(false)
+
+	ctx.SetUploadRequest(&common.UploadRequest{ID: "request", User: "local:user"})
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+
+	upload, err := ctx.GetMetadataBackend().GetUpload(ctx.GetUpload().ID)
+	require.NoError(t, err, "metadata backend error")
+
+	require.Equal(t, "local:user", upload.User, "invalid upload user")
+	require.Equal(t, "request", upload.RequestID, "invalid upload request")
+	require.True(t, upload.Restricted, "upload should be restricted")
+
+	acl, err := ctx.GetMetadataBackend().GetUploadACL(upload.ID)
+	require.NoError(t, err, "metadata backend error")
+	require.True(t, common.MatchACL(acl, []string{common.ACLUserSubject("local:user")}), "invalid upload access control list")
+}
+
+func TestCreateUploadOptionsFromHeaders(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().OneShot = true
+	ctx.GetConfig().ProtectedByPassword = true
+
+	req, err := http.NewRequest("PUT", "/file.txt", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("X-Plik-TTL", "1h")
+	req.Header.Set("X-Plik-OneShot", "true")
+	req.Header.Set("X-Plik-Password", "secret")
+	req.Header.Set("X-Plik-Comments", "comments")
+	req.Header.Set("X-Plik-Max-Downloads", "3")
+	req.Header.Set("X-Plik-Output", "json")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+
+	upload, err := ctx.GetMetadataBackend().GetUpload(ctx.GetUpload().ID)
+	require.NoError(t, err, "metadata backend error")
+
+	require.Equal(t, 3600, upload.TTL, "invalid ttl")
+	require.True(t, upload.OneShot, "upload should be one shot")
+	require.True(t, upload.ProtectedByPassword, "upload should be protected by password")
+	require.Equal(t, "plik", upload.Login, "invalid login")
+	require.NotEqual(t, "secret", upload.Password, "password should be hashed")
+	require.Equal(t, "comments", upload.Comments, "invalid comments")
+	require.Equal(t, 3, upload.MaxDownloads, "invalid max downloads")
+	require.Equal(t, common.QuickOutputJSON, ctx.GetQuickOutput(), "invalid quick output")
+}
+
+func TestCreateUploadInvalidHeaders(t *testing.T) {
+	headers := map[string]string{
+		"X-Plik-TTL":           "tomorrow",
+		"X-Plik-OneShot":       "maybe",
+		"X-Plik-Max-Downloads": "many",
+	}
+
+	for header, value := range headers {
+		ctx := newTestingContext(common.NewConfiguration())
+
+		req, err := http.NewRequest("PUT", "/file.txt", &bytes.Buffer{})
+		require.NoError(t, err, "unable to create new request")
+		req.Header.Set(header, value)
+
+		rr := ctx.NewRecorder(req)
+		CreateUpload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+		context.TestBadRequest(t, rr, "invalid "+header+" header")
+	}
+}
+
+func TestCreateUploadStreamHeader(t *testing.T) {
+	for _, value := range []string{"true", "false"} {
+		ctx := newTestingContext(common.NewConfiguration())
+
+		req, err := http.NewRequest("PUT", "/file.txt", &bytes.Buffer{})
+		require.NoError(t, err, "unable to create new request")
+		req.Header.Set("X-Plik-Stream", value)
+
+		rr := ctx.NewRecorder(req)
+		CreateUpload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+		context.TestBadRequest(t, rr, "stream mode is not available in quick mode")
+	}
+}
+
+func TestCreateUploadOptionsDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().OneShot = false
+
+	req, err := http.NewRequest("PUT", "/file.txt", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("X-Plik-OneShot", "true")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestBadRequest(t, rr, "one shot uploads are not enabled")
+}
+
+func TestCreateUploadInvalidOutput(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("PUT", "/file.txt", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("X-Plik-Output", "xml")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestInvalidParameter(t, rr, "X-Plik-Output header")
+}
+
+func TestCreateUploadRequestIgnoreOptionHeaders(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Authentication = true
+	ctx.SetUploadRequest(&common.UploadRequest{ID: "request", User: "local:user"})
+
+	req, err := http.NewRequest("PUT", "/request/request/file.txt", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("X-Plik-Comments", "comments")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+
+	require.Empty(t, ctx.GetUpload().Comments, "upload request uploads options can't be set")
+}
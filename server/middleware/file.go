@@ -55,6 +55,12 @@ func File(ctx *context.Context, next http.Handler) http.Handler {
 			return
 		}
 
+		// A share link only grants access to the file it has been signed for
+		if link := ctx.GetShareLink(); link != nil && link.FileID != file.ID {
+			ctx.Forbidden("share link is not valid for this file")
+			return
+		}
+
 		// Save file in the request context
 		ctx.SetFile(file)
 
@@ -135,3 +135,32 @@ func TestFile(t *testing.T) {
 	require.NotNil(t, f, "missing file from context")
 	require.Equal(t, file.ID, f.ID, "invalid file from context")
 }
+
+func TestFileShareLinkInvalidFile(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "filename"
+	ctx.SetUpload(upload)
+	ctx.SetShareLink(&common.ShareLink{UploadID: upload.ID, FileID: "other"})
+
+	upload.PrepareInsertForTests()
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "create upload error")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"fileID":   file.ID,
+		"filename": file.Name,
+	}
+	req = mux.SetURLVars(req, vars)
+
+	rr := ctx.NewRecorder(req)
+	File(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestForbidden(t, rr, "share link is not valid for this file")
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/root-gg/plik/server/context"
+)
+
+// FileVersions reject the new file versions when file versioning is disabled
+func FileVersions(ctx *context.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if !ctx.GetConfig().FileVersions {
+			ctx.BadRequest("file versions are disabled")
+			return
+		}
+
+		next.ServeHTTP(resp, req)
+	})
+}
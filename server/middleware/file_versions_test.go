@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func TestFileVersions(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("POST", "/file/uploadID/fileID/filename/versions", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	FileVersions(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestFileVersionsDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FileVersions = false
+
+	req, err := http.NewRequest("POST", "/file/uploadID/fileID/filename/versions", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	FileVersions(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestBadRequest(t, rr, "file versions are disabled")
+}
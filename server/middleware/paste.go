@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/root-gg/plik/server/context"
+)
+
+// Paste reject the paste requests when paste mode is disabled
+func Paste(ctx *context.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if !ctx.GetConfig().Paste {
+			ctx.BadRequest("paste mode is disabled")
+			return
+		}
+
+		next.ServeHTTP(resp, req)
+	})
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func TestPaste(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("POST", "/paste", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	Paste(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestPasteDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Paste = false
+
+	req, err := http.NewRequest("POST", "/paste", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	Paste(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestBadRequest(t, rr, "paste mode is disabled")
+}
@@ -7,8 +7,7 @@ import (
 
 	"github.com/gorilla/mux"
 
-	"github.com/root-gg/utils"
-
+	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 )
 
@@ -46,6 +45,12 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 			return
 		}
 
+		// Frozen uploads are only available to administrators pending moderation
+		if upload.Frozen && !ctx.IsAdmin() {
+			ctx.UnavailableForLegalReasons("upload %s has been frozen pending moderation", uploadID)
+			return
+		}
+
 		// Save upload in the request context
 		ctx.SetUpload(upload)
 
@@ -68,13 +73,40 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 					user := ctx.GetUser()
 					if user != nil && upload.User == ctx.GetUser().ID {
 						ctx.SetUploadAdmin(true)
+					} else if user != nil && upload.GroupID != "" {
+						// Group managers can manage all the group uploads
+						member, err := ctx.GetMetadataBackend().GetGroupMember(upload.GroupID, user.ID)
+						if err != nil {
+							ctx.InternalServerError("unable to get group member", err)
+							return
+						}
+						if member.IsManager() {
+							ctx.SetUploadAdmin(true)
+						}
 					}
 				}
 			}
 		}
 
+		// Signed share links ( see handlers.CreateShareLink ) grant read access to a single file or to the upload archive
+		if shareToken := req.URL.Query().Get("share"); shareToken != "" {
+			if !checkShareLink(ctx, req, upload, shareToken) {
+				return
+			}
+		}
+
+		// Restricted uploads can only be accessed by the users matching the upload access control list
+		if upload.Restricted && !ctx.IsUploadAdmin() && ctx.GetShareLink() == nil {
+			if !checkUploadACL(ctx, upload) {
+				return
+			}
+		}
+
 		forbidden := func(message string) {
-			resp.Header().Set("WWW-Authenticate", "Basic realm=\"plik\"")
+			// Do not trigger the browser basic auth prompt for the webapp ajax requests
+			if req.Header.Get("X-Requested-With") != "XMLHttpRequest" {
+				resp.Header().Set("WWW-Authenticate", "Basic realm=\"plik\"")
+			}
 
 			message = fmt.Sprintf("please provide valid credentials to access this upload : %s", message)
 
@@ -84,14 +116,24 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 		}
 
 		// Handle basic auth if upload is password protected
-		if upload.ProtectedByPassword && !ctx.IsUploadAdmin() {
+		if upload.ProtectedByPassword && !ctx.IsUploadAdmin() && ctx.GetShareLink() == nil {
+			// A valid upload session cookie ( see handlers.UploadLogin ) replaces the Authorization header
+			if cookie, err := req.Cookie(common.UploadSessionCookieName(upload.ID)); err == nil {
+				err = ctx.GetAuthenticator().ParseUploadSessionCookie(cookie.Value, upload)
+				if err == nil {
+					next.ServeHTTP(resp, req)
+					return
+				}
+				log.Debugf("invalid upload session cookie : %s", err)
+			}
+
 			if req.Header.Get("Authorization") == "" {
 				forbidden("missing Authorization header")
 				return
 			}
 
 			// Basic auth Authorization header must be set to
-			// "Basic base64("login:password")". Only the md5sum
+			// "Basic base64("login:password")". Only a salted hash
 			// of the base64 string is saved in the upload metadata
 			auth := strings.Split(req.Header.Get("Authorization"), " ")
 			if len(auth) != 2 {
@@ -102,18 +144,107 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 				forbidden("invalid http authorization scheme")
 				return
 			}
-			var md5sum string
-			md5sum, err = utils.Md5sum(auth[1])
-			if err != nil {
-				forbidden("unable to hash credentials")
+
+			// Protect against brute force attacks
+			authFailureKey := common.UploadAuthFailureKey(upload.ID)
+			if !ctx.CheckAuthLockout(authFailureKey) {
 				return
 			}
-			if md5sum != upload.Password {
+
+			ok, legacy := common.CheckUploadPassword(auth[1], upload.Password)
+			if !ok {
+				ctx.AuthFailed(authFailureKey)
 				forbidden("invalid credentials")
 				return
 			}
+
+			ctx.AuthSucceeded(authFailureKey)
+
+			if legacy {
+				// Transparently upgrade legacy md5 password hash
+				err = upgradeUploadPassword(ctx, upload, auth[1])
+				if err != nil {
+					log.Warningf("unable to upgrade upload password hash : %s", err)
+				}
+			}
 		}
 
 		next.ServeHTTP(resp, req)
 	})
 }
+
+// checkUploadACL verify that the authenticated user matches the upload access control list
+// On error the response is written and false is returned
+func checkUploadACL(ctx *context.Context, upload *common.Upload) bool {
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Forbidden("please login to access this upload")
+		return false
+	}
+
+	acl, err := ctx.GetMetadataBackend().GetUploadACL(upload.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload access control list", err)
+		return false
+	}
+
+	if !common.MatchACL(acl, user.GetACLSubjects()) {
+		ctx.Forbidden("you are not allowed to access this upload")
+		return false
+	}
+
+	return true
+}
+
+// checkShareLink validate a share link token and save the share link in the request context
+// On error the response is written and false is returned
+func checkShareLink(ctx *context.Context, req *http.Request, upload *common.Upload, token string) bool {
+	link, err := common.ParseShareLink(token, upload)
+	if err != nil {
+		ctx.Forbidden("invalid share link : %s", err)
+		return false
+	}
+
+	if req.Method != "GET" && req.Method != "HEAD" {
+		ctx.Forbidden("share links only grant read access")
+		return false
+	}
+
+	// File links are only valid on the file download routes and archive links on the archive route
+	vars := mux.Vars(req)
+	if vars["filename"] == "" || vars["fileID"] != link.FileID {
+		ctx.Forbidden("share link is not valid for this resource")
+		return false
+	}
+
+	if !link.IsAuthorizedIP(ctx.GetSourceIP()) {
+		ctx.Forbidden("share link is not valid from this IP address")
+		return false
+	}
+
+	if link.MaxDownloads > 0 && req.Method == "GET" {
+		ok, err := ctx.GetMetadataBackend().IncrementShareLinkDownloads(link)
+		if err != nil {
+			ctx.InternalServerError("unable to update share link downloads", err)
+			return false
+		}
+		if !ok {
+			ctx.Forbidden("share link download limit reached")
+			return false
+		}
+	}
+
+	ctx.SetShareLink(link)
+	return true
+}
+
+// upgradeUploadPassword replace a legacy md5 upload password hash by a salted hash
+func upgradeUploadPassword(ctx *context.Context, upload *common.Upload, credentials string) (err error) {
+	hash, err := common.HashUploadPassword(credentials)
+	if err != nil {
+		return err
+	}
+
+	upload.Password = hash
+	return ctx.GetMetadataBackend().UpdateUpload(upload)
+}
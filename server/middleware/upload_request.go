@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// UploadRequest retrieve the requested upload request metadata from the metadataBackend and save it to the request context.
+func UploadRequest(ctx *context.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		log := ctx.GetLogger()
+
+		// Get the upload request id from the url params
+		vars := mux.Vars(req)
+		requestID := vars["requestID"]
+		if requestID == "" {
+			ctx.MissingParameter("upload request id")
+			return
+		}
+
+		// Get upload request metadata
+		request, err := ctx.GetMetadataBackend().GetUploadRequest(requestID)
+		if err != nil {
+			ctx.InternalServerError("unable to get upload request metadata", err)
+			return
+		}
+		if request == nil {
+			ctx.NotFound("upload request %s not found", requestID)
+			return
+		}
+
+		// Update request logger prefix
+		prefix := fmt.Sprintf("%s[request:%s]", log.Prefix, requestID)
+		log.SetPrefix(prefix)
+
+		// Test if upload request is not expired
+		if request.IsExpired() {
+			ctx.NotFound("upload request %s has expired", requestID)
+			return
+		}
+
+		// Handle basic auth if upload request is password protected
+		if request.ProtectedByPassword {
+			if !checkUploadRequestPassword(ctx, req, resp, request) {
+				return
+			}
+		}
+
+		// Save upload request in the request context
+		ctx.SetUploadRequest(request)
+
+		next.ServeHTTP(resp, req)
+	})
+}
+
+// checkUploadRequestPassword verify the basic auth credentials of a password protected upload request
+// On error the response is written and false is returned
+func checkUploadRequestPassword(ctx *context.Context, req *http.Request, resp http.ResponseWriter, request *common.UploadRequest) bool {
+	forbidden := func(message string) {
+		// Do not trigger the browser basic auth prompt for the webapp ajax requests
+		if req.Header.Get("X-Requested-With") != "XMLHttpRequest" {
+			resp.Header().Set("WWW-Authenticate", "Basic realm=\"plik\"")
+		}
+
+		message = fmt.Sprintf("please provide valid credentials to access this upload request : %s", message)
+
+		// Shouldn't redirect here to let the browser ask for credentials and retry
+		ctx.SetRedirectOnFailure(false)
+		ctx.Fail(message, nil, http.StatusUnauthorized)
+	}
+
+	if req.Header.Get("Authorization") == "" {
+		forbidden("missing Authorization header")
+		return false
+	}
+
+	// Basic auth Authorization header must be set to
+	// "Basic base64("login:password")". Only a salted hash
+	// of the base64 string is saved in the upload request metadata
+	auth := strings.Split(req.Header.Get("Authorization"), " ")
+	if len(auth) != 2 {
+		forbidden("invalid Authorization header")
+		return false
+	}
+	if auth[0] != "Basic" {
+		forbidden("invalid http authorization scheme")
+		return false
+	}
+
+	// Protect against brute force attacks
+	authFailureKey := common.UploadRequestAuthFailureKey(request.ID)
+	if !ctx.CheckAuthLockout(authFailureKey) {
+		return false
+	}
+
+	ok, _ := common.CheckUploadPassword(auth[1], request.Password)
+	if !ok {
+		ctx.AuthFailed(authFailureKey)
+		forbidden("invalid credentials")
+		return false
+	}
+
+	ctx.AuthSucceeded(authFailureKey)
+	return true
+}
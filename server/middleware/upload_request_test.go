@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func createTestUploadRequest(t *testing.T, ctx *context.Context, request *common.UploadRequest) {
+	err := ctx.GetMetadataBackend().CreateUser(&common.User{ID: request.User})
+	require.NoError(t, err, "unable to create user")
+
+	request.ID = common.GenerateRandomID(16)
+	err = ctx.GetMetadataBackend().CreateUploadRequest(request)
+	require.NoError(t, err, "unable to create upload request")
+}
+
+func serveUploadRequest(t *testing.T, ctx *context.Context, requestID string, authorization string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"requestID": requestID})
+	if authorization != "" {
+		req.Header.Add("Authorization", authorization)
+	}
+
+	rr := ctx.NewRecorder(req)
+	UploadRequest(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestUploadRequestNoRequestID(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	context.TestMissingParameter(t, serveUploadRequest(t, ctx, "", ""), "upload request id")
+}
+
+func TestUploadRequestNotFound(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	context.TestNotFound(t, serveUploadRequest(t, ctx, "missing", ""), "upload request missing not found")
+}
+
+func TestUploadRequest(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	request := &common.UploadRequest{User: "user"}
+	createTestUploadRequest(t, ctx, request)
+
+	rr := serveUploadRequest(t, ctx, request.ID, "")
+	context.TestOK(t, rr)
+	require.NotNil(t, ctx.GetUploadRequest(), "missing upload request from context")
+	require.Equal(t, request.ID, ctx.GetUploadRequest().ID, "invalid upload request")
+}
+
+func TestUploadRequestExpired(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	deadline := time.Now().Add(-time.Minute)
+	request := &common.UploadRequest{User: "user", ExpireAt: &deadline}
+	createTestUploadRequest(t, ctx, request)
+
+	context.TestNotFound(t, serveUploadRequest(t, ctx, request.ID, ""), "has expired")
+}
+
+func TestUploadRequestPassword(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().AuthFailureThreshold = 2
+
+	var err error
+	request := &common.UploadRequest{User: "user", ProtectedByPassword: true}
+	request.Password, err = common.HashUploadPassword(common.EncodeAuthBasicHeader("login", "password"))
+	require.NoError(t, err, "unable to hash upload request credentials")
+	createTestUploadRequest(t, ctx, request)
+
+	context.TestUnauthorized(t, serveUploadRequest(t, ctx, request.ID, ""), "missing Authorization header")
+	context.TestUnauthorized(t, serveUploadRequest(t, ctx, request.ID, "Bearer token"), "invalid http authorization scheme")
+
+	basic := func(login string, password string) string {
+		return "Basic " + common.EncodeAuthBasicHeader(login, password)
+	}
+
+	context.TestOK(t, serveUploadRequest(t, ctx, request.ID, basic("login", "password")))
+
+	context.TestUnauthorized(t, serveUploadRequest(t, ctx, request.ID, basic("login", "invalid")), "invalid credentials")
+	context.TestUnauthorized(t, serveUploadRequest(t, ctx, request.ID, basic("login", "invalid")), "invalid credentials")
+	context.TestTooManyRequests(t, serveUploadRequest(t, ctx, request.ID, basic("login", "password")), "too many failed authentication attempts")
+}
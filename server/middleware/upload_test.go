@@ -3,7 +3,9 @@ package middleware
 import (
 	"bytes"
 	"encoding/base64"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -97,6 +99,38 @@ func TestUploadExpired(t *testing.T) {
 	context.TestNotFound(t, rr, "upload "+upload.ID+" has expired")
 }
 
+func TestUploadFrozen(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{Frozen: true}
+	upload.PrepareInsertForTests()
+
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"uploadID": upload.ID,
+	}
+	req = mux.SetURLVars(req, vars)
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestUnavailableForLegalReasons(t, rr, "upload "+upload.ID+" has been frozen pending moderation")
+
+	// Administrators can still review the upload
+	ctx.SetUser(&common.User{ID: "admin", IsAdmin: true})
+
+	rr = ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestOK(t, rr)
+}
+
 func TestUploadToken(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -226,6 +260,49 @@ func TestUploadUserAdmin(t *testing.T) {
 	require.True(t, ctx.IsAdmin(), "invalid admin status")
 }
 
+func TestUploadGroupManager(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().Authentication = true
+
+	manager := common.NewUser(common.ProviderLocal, "manager")
+	err := ctx.GetMetadataBackend().CreateUser(manager)
+	require.NoError(t, err, "unable to create user")
+
+	member := common.NewUser(common.ProviderLocal, "member")
+	err = ctx.GetMetadataBackend().CreateUser(member)
+	require.NoError(t, err, "unable to create user")
+
+	group := common.NewGroup("team")
+	err = ctx.GetMetadataBackend().CreateGroup(group)
+	require.NoError(t, err, "unable to create group")
+
+	err = ctx.GetMetadataBackend().SaveGroupMember(&common.GroupMember{GroupID: group.ID, UserID: manager.ID, Role: common.GroupRoleManager})
+	require.NoError(t, err, "unable to add group member")
+	err = ctx.GetMetadataBackend().SaveGroupMember(&common.GroupMember{GroupID: group.ID, UserID: member.ID, Role: common.GroupRoleMember})
+	require.NoError(t, err, "unable to add group member")
+
+	upload := &common.Upload{GroupID: group.ID, User: "local:other"}
+	upload.PrepareInsertForTests()
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	for _, user := range []*common.User{manager, member} {
+		ctx.SetUser(user)
+		ctx.SetUploadAdmin(false)
+
+		req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+		require.NoError(t, err, "unable to create new request")
+		req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+		rr := ctx.NewRecorder(req)
+		Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+		require.Equal(t, user == manager, ctx.IsUploadAdmin(), "invalid upload admin status for %s", user.ID)
+	}
+}
+
 func TestUploadPasswordMissingHeader(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	ctx.GetConfig().Authentication = true
@@ -349,10 +426,10 @@ func TestUploadPassword(t *testing.T) {
 	upload.PrepareInsertForTests()
 
 	// The Authorization header will contain the base64 version of "login:password"
-	// Save only the md5sum of this string to authenticate further requests
+	// Save only a salted hash of this string to authenticate further requests
 	b64str := base64.StdEncoding.EncodeToString([]byte(upload.Login + ":" + upload.Password))
-	upload.Password, err = utils.Md5sum(b64str)
-	require.NoError(t, err, "unable to b64encode upload credentials")
+	upload.Password, err = common.HashUploadPassword(b64str)
+	require.NoError(t, err, "unable to hash upload credentials")
 
 	err = ctx.GetMetadataBackend().CreateUpload(upload)
 	require.NoError(t, err, "Unable to create upload")
@@ -376,3 +453,338 @@ func TestUploadPassword(t *testing.T) {
 	require.Equal(t, upload.ID, ctx.GetUpload().ID, "invalid upload from context")
 	require.False(t, ctx.IsUploadAdmin(), "invalid upload admin status")
 }
+
+func TestUploadPasswordLegacyHash(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	var err error
+
+	upload := &common.Upload{}
+	upload.ProtectedByPassword = true
+	upload.PrepareInsertForTests()
+
+	b64str := common.EncodeAuthBasicHeader("login", "password")
+	upload.Password, err = utils.Md5sum(b64str)
+	require.NoError(t, err, "unable to md5sum upload credentials")
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+	req.Header.Add("Authorization", "Basic "+b64str)
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+
+	// The legacy md5 hash must have been upgraded
+	result, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.NotEqual(t, upload.Password, result.Password, "legacy password hash has not been upgraded")
+
+	ok, legacy := common.CheckUploadPassword(b64str, result.Password)
+	require.True(t, ok, "invalid upgraded password hash")
+	require.False(t, legacy, "invalid upgraded password hash")
+}
+
+func TestUploadPasswordSessionCookie(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetAuthenticator(&common.SessionAuthenticator{SignatureKey: "secret_key"})
+
+	var err error
+
+	upload := &common.Upload{}
+	upload.ProtectedByPassword = true
+	upload.PrepareInsertForTests()
+	upload.Password, err = common.HashUploadPassword(common.EncodeAuthBasicHeader("login", "password"))
+	require.NoError(t, err, "unable to hash upload credentials")
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	cookie, err := ctx.GetAuthenticator().GenUploadSessionCookie(upload)
+	require.NoError(t, err, "unable to generate upload session cookie")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+	req.AddCookie(cookie)
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Equal(t, upload.ID, ctx.GetUpload().ID, "invalid upload from context")
+	require.False(t, ctx.IsUploadAdmin(), "invalid upload admin status")
+}
+
+func TestUploadPasswordInvalidSessionCookie(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetAuthenticator(&common.SessionAuthenticator{SignatureKey: "secret_key"})
+
+	upload := &common.Upload{}
+	upload.ProtectedByPassword = true
+	upload.PrepareInsertForTests()
+
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	otherUpload := &common.Upload{}
+	otherUpload.PrepareInsertForTests()
+	cookie, err := ctx.GetAuthenticator().GenUploadSessionCookie(otherUpload)
+	require.NoError(t, err, "unable to generate upload session cookie")
+	cookie.Name = common.UploadSessionCookieName(upload.ID)
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.AddCookie(cookie)
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestUnauthorized(t, rr, "please provide valid credentials to access this upload")
+	require.Equal(t, "", rr.Header().Get("WWW-Authenticate"), "ajax requests should not trigger basic auth prompt")
+}
+
+func TestUploadPasswordLockout(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().AuthFailureThreshold = 2
+
+	var err error
+
+	upload := &common.Upload{}
+	upload.ProtectedByPassword = true
+	upload.PrepareInsertForTests()
+	upload.Password, err = common.HashUploadPassword(common.EncodeAuthBasicHeader("login", "password"))
+	require.NoError(t, err, "unable to hash upload credentials")
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	get := func(login string, password string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+		require.NoError(t, err, "unable to create new request")
+		req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+		req.Header.Add("Authorization", "Basic "+common.EncodeAuthBasicHeader(login, password))
+
+		rr := ctx.NewRecorder(req)
+		Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	// A successful authentication resets the failure count
+	context.TestUnauthorized(t, get("login", "invalid"), "invalid credentials")
+	require.Equal(t, http.StatusOK, get("login", "password").Code, "invalid handler response status code")
+
+	context.TestUnauthorized(t, get("login", "invalid"), "invalid credentials")
+	context.TestUnauthorized(t, get("login", "invalid"), "invalid credentials")
+	context.TestTooManyRequests(t, get("login", "password"), "too many failed authentication attempts")
+}
+
+func newShareLinkTestUpload(t *testing.T, ctx *context.Context) (upload *common.Upload, file *common.File) {
+	var err error
+
+	upload = &common.Upload{}
+	upload.ProtectedByPassword = true
+	file = upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	upload.PrepareInsertForTests()
+	upload.Password, err = common.HashUploadPassword(common.EncodeAuthBasicHeader("login", "password"))
+	require.NoError(t, err, "unable to hash upload credentials")
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	return upload, file
+}
+
+func newShareLink(t *testing.T, upload *common.Upload, link *common.ShareLink) *common.ShareLink {
+	err := link.PrepareInsert(upload)
+	require.NoError(t, err, "unable to prepare share link")
+	err = link.Sign(upload)
+	require.NoError(t, err, "unable to sign share link")
+	return link
+}
+
+func shareLinkRequest(t *testing.T, ctx *context.Context, method string, upload *common.Upload, fileID string, token string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, "?share="+token, &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	vars := map[string]string{"uploadID": upload.ID, "filename": "file"}
+	if fileID != "" {
+		vars["fileID"] = fileID
+	}
+	req = mux.SetURLVars(req, vars)
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestUploadShareLink(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := newShareLinkTestUpload(t, ctx)
+	link := newShareLink(t, upload, &common.ShareLink{FileID: file.ID})
+
+	rr := shareLinkRequest(t, ctx, "GET", upload, file.ID, link.Token)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.NotNil(t, ctx.GetShareLink(), "missing share link from context")
+	require.Equal(t, link.ID, ctx.GetShareLink().ID, "invalid share link from context")
+	require.False(t, ctx.IsUploadAdmin(), "share link should not grant upload admin")
+}
+
+func TestUploadShareLinkInvalid(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := newShareLinkTestUpload(t, ctx)
+
+	rr := shareLinkRequest(t, ctx, "GET", upload, file.ID, "invalid")
+	context.TestForbidden(t, rr, "invalid share link")
+}
+
+func TestUploadShareLinkRevoked(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := newShareLinkTestUpload(t, ctx)
+	link := newShareLink(t, upload, &common.ShareLink{FileID: file.ID})
+
+	upload.ShareSecret = common.GenerateShareSecret()
+	err := ctx.GetMetadataBackend().UpdateUpload(upload)
+	require.NoError(t, err, "unable to update upload")
+
+	rr := shareLinkRequest(t, ctx, "GET", upload, file.ID, link.Token)
+	context.TestForbidden(t, rr, "invalid share link")
+}
+
+func TestUploadShareLinkInvalidResource(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := newShareLinkTestUpload(t, ctx)
+
+	// File link on the archive route
+	link := newShareLink(t, upload, &common.ShareLink{FileID: file.ID})
+	rr := shareLinkRequest(t, ctx, "GET", upload, "", link.Token)
+	context.TestForbidden(t, rr, "share link is not valid for this resource")
+
+	// Archive link on a file route
+	link = newShareLink(t, upload, &common.ShareLink{})
+	rr = shareLinkRequest(t, ctx, "GET", upload, file.ID, link.Token)
+	context.TestForbidden(t, rr, "share link is not valid for this resource")
+
+	// Write access
+	rr = shareLinkRequest(t, ctx, "DELETE", upload, "", link.Token)
+	context.TestForbidden(t, rr, "share links only grant read access")
+}
+
+func TestUploadShareLinkSourceIP(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := newShareLinkTestUpload(t, ctx)
+	link := newShareLink(t, upload, &common.ShareLink{FileID: file.ID, CIDR: "10.0.0.0/8"})
+
+	ctx.SetSourceIP(net.ParseIP("1.2.3.4"))
+	rr := shareLinkRequest(t, ctx, "GET", upload, file.ID, link.Token)
+	context.TestForbidden(t, rr, "share link is not valid from this IP address")
+
+	ctx = newTestingContext(ctx.GetConfig())
+	upload, file = newShareLinkTestUpload(t, ctx)
+	link = newShareLink(t, upload, &common.ShareLink{FileID: file.ID, CIDR: "10.0.0.0/8"})
+
+	ctx.SetSourceIP(net.ParseIP("10.0.0.1"))
+	rr = shareLinkRequest(t, ctx, "GET", upload, file.ID, link.Token)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+}
+
+func TestUploadShareLinkMaxDownloads(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload, file := newShareLinkTestUpload(t, ctx)
+	link := newShareLink(t, upload, &common.ShareLink{FileID: file.ID, MaxDownloads: 1})
+
+	// HEAD requests are not counted
+	rr := shareLinkRequest(t, ctx, "HEAD", upload, file.ID, link.Token)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+
+	rr = shareLinkRequest(t, ctx, "GET", upload, file.ID, link.Token)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+
+	rr = shareLinkRequest(t, ctx, "GET", upload, file.ID, link.Token)
+	context.TestForbidden(t, rr, "share link download limit reached")
+}
+
+func newRestrictedUpload(t *testing.T, ctx *context.Context, acl ...string) (upload *common.Upload) {
+	upload = &common.Upload{ACL: acl}
+	upload.PrepareInsertForTests()
+
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	return upload
+}
+
+func restrictedUploadRequest(t *testing.T, ctx *context.Context, upload *common.Upload) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestUploadACL(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := newRestrictedUpload(t, ctx, common.ACLUserSubject(common.GetUserID(common.ProviderLocal, "bob")))
+
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "bob"))
+	rr := restrictedUploadRequest(t, ctx, upload)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+}
+
+func TestUploadACLGroup(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := newRestrictedUpload(t, ctx, common.ACLGroupSubject(common.ProviderGoogle, "example.com"))
+
+	user := common.NewUser(common.ProviderGoogle, "bob@example.com")
+	user.Email = "bob@example.com"
+	ctx.SetUser(user)
+
+	rr := restrictedUploadRequest(t, ctx, upload)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+}
+
+func TestUploadACLAnonymous(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := newRestrictedUpload(t, ctx, common.ACLUserSubject(common.GetUserID(common.ProviderLocal, "bob")))
+
+	rr := restrictedUploadRequest(t, ctx, upload)
+	context.TestForbidden(t, rr, "please login to access this upload")
+}
+
+func TestUploadACLForbidden(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := newRestrictedUpload(t, ctx, common.ACLUserSubject(common.GetUserID(common.ProviderLocal, "bob")))
+
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "alice"))
+	rr := restrictedUploadRequest(t, ctx, upload)
+	context.TestForbidden(t, rr, "you are not allowed to access this upload")
+}
+
+func TestUploadACLUploadAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := &common.Upload{ACL: []string{common.ACLUserSubject(common.GetUserID(common.ProviderLocal, "bob"))}}
+	upload.UploadToken = "token"
+	upload.PrepareInsertForTests()
+
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+	req.Header.Set("X-UploadToken", upload.UploadToken)
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+}
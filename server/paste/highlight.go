@@ -0,0 +1,251 @@
+package paste
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Classes of the highlighted tokens
+const (
+	classComment = "c"
+	classString  = "s"
+	classNumber  = "n"
+	classKeyword = "k"
+)
+
+// style is the stylesheet of the highlighted view, it is allowed by its hash in the Content-Security-Policy
+const style = `body{margin:0;font-family:sans-serif;color:#24292e;background:#fff}
+header{padding:8px 16px;border-bottom:1px solid #e1e4e8;background:#f6f8fa}
+header .language{margin:0 8px;color:#6a737d}
+pre{margin:0;padding:8px 0;overflow:auto;font-size:13px;line-height:1.5}
+code{font-family:monospace;counter-reset:line}
+.l:before{counter-increment:line;content:counter(line);display:inline-block;width:4em;margin-right:16px;padding-right:8px;border-right:1px solid #e1e4e8;color:#959da5;text-align:right;user-select:none}
+.c{color:#6a737d;font-style:italic}
+.s{color:#032f62}
+.n{color:#005cc5}
+.k{color:#d73a49;font-weight:bold}`
+
+// ContentSecurityPolicy of the highlighted view, only the inline stylesheet is allowed
+var ContentSecurityPolicy string
+
+var pageTemplate = template.Must(template.New("paste").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="referrer" content="no-referrer">
+<title>{{.Name}}</title>
+<style>{{.Style}}</style>
+</head>
+<body>
+<header><span>{{.Name}}</span><span class="language">{{.Language}}</span><a href="{{.RawURL}}">raw</a></header>
+<pre><code>{{.Code}}</code></pre>
+</body>
+</html>
+`))
+
+func init() {
+	hash := sha256.Sum256([]byte(style))
+	ContentSecurityPolicy = fmt.Sprintf("default-src 'none'; style-src 'sha256-%s'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'; sandbox",
+		base64.StdEncoding.EncodeToString(hash[:]))
+}
+
+// Render write the syntax highlighted HTML page of a paste
+// The raw view is expected at the "raw" sub-path of the page URL
+func Render(w io.Writer, name string, language *Language, text string) error {
+	page := struct {
+		Name     string
+		Language string
+		RawURL   string
+		Style    template.CSS
+		Code     template.HTML
+	}{
+		Name:   name,
+		RawURL: url.PathEscape(name) + "/raw",
+		Style:  template.CSS(style),
+		Code:   template.HTML(highlight(language, text)),
+	}
+	if language != nil {
+		page.Language = language.Name
+	}
+
+	return pageTemplate.Execute(w, page)
+}
+
+// highlight return the escaped HTML of the text with each line and token in a span
+func highlight(language *Language, text string) string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.TrimSuffix(text, "\n")
+
+	h := &highlighter{}
+	h.buf.WriteString(`<span class="l">`)
+	if language == nil {
+		h.write("", text)
+	} else {
+		t := &tokenizer{language: language}
+		for i := 0; i < len(text); {
+			n, class := t.next(text[i:])
+			h.write(class, text[i:i+n])
+			i += n
+		}
+	}
+	h.buf.WriteString(`</span>`)
+
+	return h.buf.String()
+}
+
+// highlighter split the tokens spanning multiple lines so each line stays in its own span
+type highlighter struct {
+	buf strings.Builder
+}
+
+func (h *highlighter) write(class string, token string) {
+	for i, line := range strings.Split(token, "\n") {
+		if i > 0 {
+			h.buf.WriteString("</span>\n<span class=\"l\">")
+		}
+		if line == "" {
+			continue
+		}
+		if class == "" {
+			h.buf.WriteString(html.EscapeString(line))
+		} else {
+			h.buf.WriteString(`<span class="` + class + `">` + html.EscapeString(line) + `</span>`)
+		}
+	}
+}
+
+// tokenizer return the length and class of the next token of the text
+type tokenizer struct {
+	language *Language
+	inTag    bool
+}
+
+func (t *tokenizer) next(s string) (n int, class string) {
+	for _, delimiters := range t.language.BlockComments {
+		if strings.HasPrefix(s, delimiters[0]) {
+			return scanDelimited(s, delimiters[0], delimiters[1]), classComment
+		}
+	}
+
+	if t.language.Tags {
+		return t.nextMarkup(s)
+	}
+
+	for _, prefix := range t.language.LineComments {
+		if strings.HasPrefix(s, prefix) {
+			if end := strings.IndexByte(s, '\n'); end >= 0 {
+				return end, classComment
+			}
+			return len(s), classComment
+		}
+	}
+
+	for _, delimiter := range t.language.RawStrings {
+		if strings.HasPrefix(s, delimiter) {
+			return scanDelimited(s, delimiter, delimiter), classString
+		}
+	}
+
+	for _, delimiter := range t.language.Strings {
+		if strings.HasPrefix(s, delimiter) {
+			return scanString(s, delimiter), classString
+		}
+	}
+
+	switch {
+	case isDigit(s[0]):
+		n = 1
+		for n < len(s) && (isIdentifier(s[n]) || s[n] == '.') {
+			n++
+		}
+		return n, classNumber
+	case isIdentifierStart(s[0]):
+		n = 1
+		for n < len(s) && isIdentifier(s[n]) {
+			n++
+		}
+		if t.language.isKeyword(s[:n]) {
+			return n, classKeyword
+		}
+		return n, ""
+	}
+
+	return 1, ""
+}
+
+// nextMarkup highlight the tag names and the attribute values of markup languages
+func (t *tokenizer) nextMarkup(s string) (n int, class string) {
+	if !t.inTag {
+		if s[0] == '<' && len(s) > 1 && (isIdentifierStart(s[1]) || strings.IndexByte("/!?", s[1]) >= 0) {
+			t.inTag = true
+			n = 1
+			for n < len(s) && strings.IndexByte("/!?", s[n]) >= 0 {
+				n++
+			}
+			for n < len(s) && (isIdentifier(s[n]) || strings.IndexByte("-:.", s[n]) >= 0) {
+				n++
+			}
+			return n, classKeyword
+		}
+		return 1, ""
+	}
+
+	switch {
+	case s[0] == '>':
+		t.inTag = false
+		return 1, classKeyword
+	case strings.HasPrefix(s, "/>"):
+		t.inTag = false
+		return 2, classKeyword
+	case s[0] == '"' || s[0] == '\'':
+		return scanDelimited(s, s[:1], s[:1]), classString
+	}
+
+	return 1, ""
+}
+
+// scanDelimited return the length of the token from the start delimiter to the end delimiter or the end of the text
+func scanDelimited(s string, start string, end string) int {
+	i := strings.Index(s[len(start):], end)
+	if i < 0 {
+		return len(s)
+	}
+	return len(start) + i + len(end)
+}
+
+// scanString return the length of a single line string with backslash escapes
+func scanString(s string, delimiter string) int {
+	i := len(delimiter)
+	for i < len(s) {
+		switch {
+		case s[i] == '\\':
+			i += 2
+			continue
+		case s[i] == '\n':
+			return i
+		case strings.HasPrefix(s[i:], delimiter):
+			return i + len(delimiter)
+		}
+		i++
+	}
+	return len(s)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentifier(c byte) bool {
+	return isIdentifierStart(c) || isDigit(c)
+}
@@ -0,0 +1,251 @@
+package paste
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultName is the file name of the pastes created without a name
+const DefaultName = "paste"
+
+// Language describe how to highlight the syntax of a paste
+type Language struct {
+	Name       string
+	Aliases    []string
+	Extensions []string
+
+	Keywords   []string
+	IgnoreCase bool // Keywords are not case sensitive
+
+	LineComments  []string
+	BlockComments [][2]string
+
+	Strings    []string // Single line string delimiters, backslash escapes the next character
+	RawStrings []string // String delimiters without escaping that can span multiple lines
+
+	Tags bool // Highlight the <tag attribute="value"> of markup languages
+
+	keywords map[string]bool
+}
+
+// languages are the supported languages
+var languages = []*Language{
+	{
+		Name:         "c",
+		Aliases:      []string{"cpp", "c++", "h"},
+		Extensions:   []string{".c", ".h", ".cc", ".cpp", ".hpp"},
+		Keywords:     []string{"auto", "bool", "break", "case", "char", "class", "const", "continue", "default", "delete", "do", "double", "else", "enum", "extern", "false", "float", "for", "goto", "if", "include", "define", "inline", "int", "long", "namespace", "new", "nullptr", "private", "protected", "public", "register", "return", "short", "signed", "sizeof", "static", "struct", "switch", "template", "this", "true", "typedef", "union", "unsigned", "using", "virtual", "void", "volatile", "while"},
+		LineComments: []string{"//"},
+		BlockComments: [][2]string{
+			{"/*", "*/"},
+		},
+		Strings: []string{`"`, "'"},
+	},
+	{
+		Name:       "css",
+		Extensions: []string{".css"},
+		Keywords:   []string{"important", "inherit", "initial", "none", "auto"},
+		BlockComments: [][2]string{
+			{"/*", "*/"},
+		},
+		Strings: []string{`"`, "'"},
+	},
+	{
+		Name:         "go",
+		Aliases:      []string{"golang"},
+		Extensions:   []string{".go"},
+		Keywords:     []string{"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough", "false", "for", "func", "go", "goto", "if", "import", "interface", "iota", "map", "nil", "package", "range", "return", "select", "struct", "switch", "true", "type", "var"},
+		LineComments: []string{"//"},
+		BlockComments: [][2]string{
+			{"/*", "*/"},
+		},
+		Strings:    []string{`"`, "'"},
+		RawStrings: []string{"`"},
+	},
+	{
+		Name:         "ini",
+		Aliases:      []string{"toml", "conf", "cfg"},
+		Extensions:   []string{".ini", ".toml", ".conf", ".cfg"},
+		Keywords:     []string{"true", "false"},
+		LineComments: []string{"#", ";"},
+		Strings:      []string{`"`, "'"},
+	},
+	{
+		Name:         "java",
+		Aliases:      []string{"kotlin"},
+		Extensions:   []string{".java", ".kt"},
+		Keywords:     []string{"abstract", "boolean", "break", "case", "catch", "class", "continue", "default", "do", "double", "else", "enum", "extends", "false", "final", "finally", "float", "for", "fun", "if", "implements", "import", "instanceof", "int", "interface", "long", "new", "null", "package", "private", "protected", "public", "return", "static", "super", "switch", "synchronized", "this", "throw", "throws", "true", "try", "val", "var", "void", "while"},
+		LineComments: []string{"//"},
+		BlockComments: [][2]string{
+			{"/*", "*/"},
+		},
+		Strings: []string{`"`, "'"},
+	},
+	{
+		Name:         "javascript",
+		Aliases:      []string{"js", "typescript", "ts"},
+		Extensions:   []string{".js", ".mjs", ".ts", ".jsx", ".tsx"},
+		Keywords:     []string{"async", "await", "break", "case", "catch", "class", "const", "continue", "default", "delete", "do", "else", "export", "extends", "false", "finally", "for", "from", "function", "if", "import", "in", "instanceof", "interface", "let", "new", "null", "return", "super", "switch", "this", "throw", "true", "try", "type", "typeof", "undefined", "var", "void", "while", "yield"},
+		LineComments: []string{"//"},
+		BlockComments: [][2]string{
+			{"/*", "*/"},
+		},
+		Strings:    []string{`"`, "'"},
+		RawStrings: []string{"`"},
+	},
+	{
+		Name:       "json",
+		Extensions: []string{".json"},
+		Keywords:   []string{"true", "false", "null"},
+		Strings:    []string{`"`},
+	},
+	{
+		Name:         "php",
+		Extensions:   []string{".php"},
+		Keywords:     []string{"array", "as", "break", "case", "catch", "class", "const", "continue", "default", "echo", "else", "elseif", "extends", "false", "foreach", "for", "function", "if", "implements", "include", "namespace", "new", "null", "private", "protected", "public", "require", "return", "static", "switch", "this", "throw", "true", "try", "use", "while"},
+		LineComments: []string{"//", "#"},
+		BlockComments: [][2]string{
+			{"/*", "*/"},
+		},
+		Strings: []string{`"`, "'"},
+	},
+	{
+		Name:         "python",
+		Aliases:      []string{"py"},
+		Extensions:   []string{".py"},
+		Keywords:     []string{"and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del", "elif", "else", "except", "False", "finally", "for", "from", "global", "if", "import", "in", "is", "lambda", "None", "nonlocal", "not", "or", "pass", "raise", "return", "self", "True", "try", "while", "with", "yield"},
+		LineComments: []string{"#"},
+		Strings:      []string{`"`, "'"},
+		RawStrings:   []string{`"""`, "'''"},
+	},
+	{
+		Name:         "ruby",
+		Aliases:      []string{"rb"},
+		Extensions:   []string{".rb"},
+		Keywords:     []string{"begin", "break", "case", "class", "def", "do", "else", "elsif", "end", "ensure", "false", "for", "if", "in", "module", "next", "nil", "require", "rescue", "return", "self", "then", "true", "unless", "until", "when", "while", "yield"},
+		LineComments: []string{"#"},
+		Strings:      []string{`"`, "'"},
+	},
+	{
+		Name:         "rust",
+		Aliases:      []string{"rs"},
+		Extensions:   []string{".rs"},
+		Keywords:     []string{"as", "break", "const", "continue", "crate", "else", "enum", "false", "fn", "for", "if", "impl", "in", "let", "loop", "match", "mod", "move", "mut", "pub", "ref", "return", "self", "Self", "static", "struct", "super", "trait", "true", "type", "unsafe", "use", "where", "while"},
+		LineComments: []string{"//"},
+		BlockComments: [][2]string{
+			{"/*", "*/"},
+		},
+		Strings: []string{`"`},
+	},
+	{
+		Name:         "shell",
+		Aliases:      []string{"sh", "bash", "zsh"},
+		Extensions:   []string{".sh", ".bash", ".zsh"},
+		Keywords:     []string{"case", "do", "done", "echo", "elif", "else", "esac", "exit", "export", "fi", "for", "function", "if", "in", "local", "return", "set", "then", "until", "while"},
+		LineComments: []string{"#"},
+		Strings:      []string{`"`, "'"},
+	},
+	{
+		Name:         "sql",
+		Extensions:   []string{".sql"},
+		Keywords:     []string{"add", "alter", "and", "as", "asc", "by", "create", "delete", "desc", "distinct", "drop", "exists", "from", "group", "having", "in", "index", "inner", "insert", "into", "is", "join", "key", "left", "like", "limit", "not", "null", "on", "or", "order", "primary", "right", "select", "set", "table", "union", "update", "values", "where"},
+		IgnoreCase:   true,
+		LineComments: []string{"--"},
+		BlockComments: [][2]string{
+			{"/*", "*/"},
+		},
+		Strings: []string{"'", `"`},
+	},
+	{
+		Name:       "text",
+		Aliases:    []string{"plain", "txt", "log"},
+		Extensions: []string{".txt", ".log"},
+	},
+	{
+		Name:       "xml",
+		Aliases:    []string{"html", "svg"},
+		Extensions: []string{".xml", ".html", ".htm", ".svg"},
+		BlockComments: [][2]string{
+			{"<!--", "-->"},
+		},
+		Tags: true,
+	},
+	{
+		Name:         "yaml",
+		Aliases:      []string{"yml"},
+		Extensions:   []string{".yaml", ".yml"},
+		Keywords:     []string{"true", "false", "null", "yes", "no"},
+		LineComments: []string{"#"},
+		Strings:      []string{`"`, "'"},
+	},
+}
+
+func init() {
+	for _, language := range languages {
+		language.keywords = make(map[string]bool)
+		for _, keyword := range language.Keywords {
+			if language.IgnoreCase {
+				keyword = strings.ToLower(keyword)
+			}
+			language.keywords[keyword] = true
+		}
+	}
+}
+
+// GetLanguage return the language matching a name or an alias, nil if the language is not supported
+func GetLanguage(name string) *Language {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, language := range languages {
+		if language.Name == name {
+			return language
+		}
+		for _, alias := range language.Aliases {
+			if alias == name {
+				return language
+			}
+		}
+	}
+	return nil
+}
+
+// GetLanguageFromFileName return the language matching the extension of a file name, nil if unknown
+func GetLanguageFromFileName(fileName string) *Language {
+	extension := strings.ToLower(path.Ext(fileName))
+	if extension == "" {
+		return nil
+	}
+	for _, language := range languages {
+		for _, e := range language.Extensions {
+			if e == extension {
+				return language
+			}
+		}
+	}
+	return nil
+}
+
+// GetLanguages return the names of the supported languages
+func GetLanguages() (names []string) {
+	for _, language := range languages {
+		names = append(names, language.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetFileName return the file name of a paste created without a name
+func GetFileName(language *Language) string {
+	if language == nil || len(language.Extensions) == 0 {
+		return DefaultName + ".txt"
+	}
+	return DefaultName + language.Extensions[0]
+}
+
+// isKeyword return true if the word is a keyword of the language
+func (language *Language) isKeyword(word string) bool {
+	if language.IgnoreCase {
+		word = strings.ToLower(word)
+	}
+	return language.keywords[word]
+}
@@ -0,0 +1,94 @@
+package paste
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLanguage(t *testing.T) {
+	require.Equal(t, "go", GetLanguage("go").Name, "invalid language")
+	require.Equal(t, "go", GetLanguage(" Golang ").Name, "invalid language alias")
+	require.Equal(t, "shell", GetLanguage("bash").Name, "invalid language alias")
+	require.Nil(t, GetLanguage("brainfuck"), "unsupported language")
+	require.Nil(t, GetLanguage(""), "empty language")
+}
+
+func TestGetLanguageFromFileName(t *testing.T) {
+	require.Equal(t, "python", GetLanguageFromFileName("script.py").Name, "invalid language")
+	require.Equal(t, "yaml", GetLanguageFromFileName("config.YML").Name, "invalid language")
+	require.Nil(t, GetLanguageFromFileName("STDIN"), "file without extension")
+	require.Nil(t, GetLanguageFromFileName("archive.zip"), "unknown extension")
+}
+
+func TestGetFileName(t *testing.T) {
+	require.Equal(t, "paste.txt", GetFileName(nil), "invalid file name")
+	require.Equal(t, "paste.go", GetFileName(GetLanguage("go")), "invalid file name")
+}
+
+func TestGetLanguages(t *testing.T) {
+	names := GetLanguages()
+	require.Contains(t, names, "go", "missing language")
+	require.Contains(t, names, "text", "missing language")
+}
+
+func TestHighlight(t *testing.T) {
+	code := "package main\n\n// main <entrypoint>\nfunc main() {\n\tfmt.Println(\"a \\\" b\", 42)\n}\n"
+	expected := `<span class="l"><span class="k">package</span> main</span>
+<span class="l"></span>
+<span class="l"><span class="c">// main &lt;entrypoint&gt;</span></span>
+<span class="l"><span class="k">func</span> main() {</span>
+<span class="l">	fmt.Println(<span class="s">&#34;a \&#34; b&#34;</span>, <span class="n">42</span>)</span>
+<span class="l">}</span>`
+	require.Equal(t, expected, highlight(GetLanguage("go"), code), "invalid highlighted code")
+}
+
+func TestHighlightMultilineToken(t *testing.T) {
+	code := "/* first\nsecond */ SELECT 1"
+	expected := `<span class="l"><span class="c">/* first</span></span>
+<span class="l"><span class="c">second */</span> <span class="k">SELECT</span> <span class="n">1</span></span>`
+	require.Equal(t, expected, highlight(GetLanguage("sql"), code), "invalid highlighted code")
+}
+
+func TestHighlightMarkup(t *testing.T) {
+	code := `<a href="x">don't</a><!-- <b> -->`
+	expected := `<span class="l"><span class="k">&lt;a</span> href=<span class="s">&#34;x&#34;</span><span class="k">&gt;</span>don&#39;t<span class="k">&lt;/a</span><span class="k">&gt;</span><span class="c">&lt;!-- &lt;b&gt; --&gt;</span></span>`
+	require.Equal(t, expected, highlight(GetLanguage("html"), code), "invalid highlighted code")
+}
+
+func TestHighlightPlainText(t *testing.T) {
+	code := "<script>alert(1)</script>\r\nline 2"
+	expected := `<span class="l">&lt;script&gt;alert(1)&lt;/script&gt;</span>
+<span class="l">line 2</span>`
+	require.Equal(t, expected, highlight(nil, code), "invalid highlighted code")
+}
+
+func TestHighlightUnterminated(t *testing.T) {
+	for _, code := range []string{`"abc\`, "/* abc", "x = '''abc", "<a href=\"x"} {
+		for _, language := range languages {
+			require.NotPanics(t, func() { highlight(language, code) }, "unable to highlight %q as %s", code, language.Name)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := Render(buf, "my paste.go", GetLanguage("go"), "<script>alert(1)</script>")
+	require.NoError(t, err, "unable to render paste")
+
+	page := buf.String()
+	require.Contains(t, page, "<title>my paste.go</title>", "missing title")
+	require.Contains(t, page, `href="my%20paste.go/raw"`, "missing raw link")
+	require.Contains(t, page, "&lt;script&gt;", "code should be escaped")
+	require.NotContains(t, page, "<script>", "code should be escaped")
+
+	// The stylesheet must match the hash allowed by the Content-Security-Policy
+	start := strings.Index(page, "<style>") + len("<style>")
+	end := strings.Index(page, "</style>")
+	hash := sha256.Sum256([]byte(page[start:end]))
+	require.Contains(t, ContentSecurityPolicy, "'sha256-"+base64.StdEncoding.EncodeToString(hash[:])+"'", "invalid stylesheet hash")
+}
@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"bytes"
+	goContext "context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"strings"
+)
+
+// ExecHook run an executable reading the JSON request on stdin and writing the JSON response on stdout
+type ExecHook struct {
+	command string
+	args    []string
+}
+
+// NewExecHook create a new executable hook
+// The command is split on white spaces into the executable path and its arguments, quoting is not supported
+func NewExecHook(command string) *ExecHook {
+	hook := &ExecHook{command: command}
+	if fields := strings.Fields(command); len(fields) > 0 {
+		hook.command = fields[0]
+		hook.args = fields[1:]
+	}
+	return hook
+}
+
+// Call run the hook executable
+func (h *ExecHook) Call(ctx goContext.Context, request *Request) (response *Response, err error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize policy request : %s", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.command, h.args...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("policy hook %s failed : %s %s", h.command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	response = &Response{}
+	err = json.Unmarshal(stdout.Bytes(), response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy hook response : %s", err)
+	}
+
+	return response, nil
+}
+
+// HTTPHook POST the JSON request to an HTTP endpoint expecting the JSON response in the body
+type HTTPHook struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPHook create a new HTTP hook
+func NewHTTPHook(url string) *HTTPHook {
+	return &HTTPHook{url: url, client: &http.Client{}}
+}
+
+// Call POST the request to the hook URL
+func (h *HTTPHook) Call(ctx goContext.Context, request *Request) (response *Response, err error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize policy request : %s", err)
+	}
+
+	req, err := http.NewRequest("POST", h.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create policy request : %s", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to call policy hook : %s", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read policy hook response : %s", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected policy hook response status %d : %s", resp.StatusCode, strings.TrimSpace(string(content)))
+	}
+
+	response = &Response{}
+	err = json.Unmarshal(content, response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy hook response : %s", err)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,115 @@
+package policy
+
+import (
+	goContext "context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/root-gg/logger"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// UploadStage when an upload is about to be created
+const UploadStage = "upload"
+
+// FileStage when the first bytes of a file have been received, before any data is accepted
+const FileStage = "file"
+
+// CompleteStage when all the file data has been received
+const CompleteStage = "complete"
+
+// HeadSize is the maximum number of first bytes of a file sent to the hook
+const HeadSize = 512
+
+// Request is sent to the policy hook
+type Request struct {
+	Stage    string         `json:"stage"`
+	Upload   *common.Upload `json:"upload"`
+	FileName string         `json:"fileName,omitempty"`
+	MimeType string         `json:"mimeType,omitempty"`
+	Head     []byte         `json:"head,omitempty"`
+	Size     int64          `json:"size,omitempty"`
+	Md5      string         `json:"md5,omitempty"`
+}
+
+// Response is expected from the policy hook
+type Response struct {
+	Accept  bool   `json:"accept"`
+	Message string `json:"message"`
+}
+
+// Hook is an external admission policy
+type Hook interface {
+	Call(ctx goContext.Context, request *Request) (response *Response, err error)
+}
+
+// Policy call the configured hook to accept or reject uploads and files
+type Policy struct {
+	hook     Hook
+	timeout  time.Duration
+	failOpen bool
+}
+
+// NewPolicy create a new policy from the configuration ( nil if no hook is configured )
+func NewPolicy(config *common.Configuration) (policy *Policy, err error) {
+	var hook Hook
+	switch {
+	case config.PolicyHookCommand != "":
+		hook = NewExecHook(config.PolicyHookCommand)
+	case config.PolicyHookURL != "":
+		u, err := url.Parse(config.PolicyHookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid policy hook URL %s", config.PolicyHookURL)
+		}
+		hook = NewHTTPHook(config.PolicyHookURL)
+	default:
+		return nil, nil
+	}
+
+	return New(hook, time.Duration(config.PolicyHookTimeout)*time.Second, config.PolicyHookFailOpen), nil
+}
+
+// New create a new policy calling hook
+func New(hook Hook, timeout time.Duration, failOpen bool) (policy *Policy) {
+	return &Policy{hook: hook, timeout: timeout, failOpen: failOpen}
+}
+
+// NewRequest create a new policy request for upload
+// Upload secrets and files are not sent to the hook
+func NewRequest(stage string, upload *common.Upload) (request *Request) {
+	u := *upload
+	u.Files = nil
+	u.UploadToken = ""
+	u.Password = ""
+	u.ShareSecret = ""
+
+	return &Request{Stage: stage, Upload: &u}
+}
+
+// Check call the hook and return a common.HTTPError if the request is rejected
+func (p *Policy) Check(log *logger.Logger, request *Request) (err error) {
+	ctx, cancel := goContext.WithTimeout(goContext.Background(), p.timeout)
+	defer cancel()
+
+	response, err := p.hook.Call(ctx, request)
+	if err != nil {
+		if p.failOpen {
+			log.Warningf("unable to call policy hook, accepting %s : %s", request.Stage, err)
+			return nil
+		}
+		return common.NewHTTPError("unable to check upload policy", err, http.StatusServiceUnavailable)
+	}
+
+	if !response.Accept {
+		message := response.Message
+		if message == "" {
+			message = fmt.Sprintf("%s rejected by upload policy", request.Stage)
+		}
+		return common.NewHTTPError(message, nil, http.StatusForbidden)
+	}
+
+	return nil
+}
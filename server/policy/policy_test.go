@@ -0,0 +1,210 @@
+package policy
+
+import (
+	goContext "context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+type testHook struct {
+	response *Response
+	err      error
+	delay    time.Duration
+	requests []*Request
+}
+
+func (h *testHook) Call(ctx goContext.Context, request *Request) (response *Response, err error) {
+	h.requests = append(h.requests, request)
+	select {
+	case <-time.After(h.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return h.response, h.err
+}
+
+func TestNewPolicy(t *testing.T) {
+	config := common.NewConfiguration()
+	p, err := NewPolicy(config)
+	require.NoError(t, err)
+	require.Nil(t, p, "policy should be disabled")
+
+	config.PolicyHookCommand = "/bin/true"
+	p, err = NewPolicy(config)
+	require.NoError(t, err)
+	require.IsType(t, &ExecHook{}, p.hook)
+
+	config = common.NewConfiguration()
+	config.PolicyHookURL = "http://127.0.0.1:8081/policy"
+	p, err = NewPolicy(config)
+	require.NoError(t, err)
+	require.IsType(t, &HTTPHook{}, p.hook)
+
+	config.PolicyHookURL = "ftp://127.0.0.1/policy"
+	_, err = NewPolicy(config)
+	require.Error(t, err)
+}
+
+func TestNewRequest(t *testing.T) {
+	upload := &common.Upload{UploadToken: "token", Password: "password", ShareSecret: "secret", Comments: "foo"}
+	upload.NewFile()
+
+	request := NewRequest(UploadStage, upload)
+	require.Equal(t, UploadStage, request.Stage)
+	require.Equal(t, "foo", request.Upload.Comments)
+	require.Empty(t, request.Upload.UploadToken)
+	require.Empty(t, request.Upload.Password)
+	require.Empty(t, request.Upload.ShareSecret)
+	require.Nil(t, request.Upload.Files)
+
+	require.Equal(t, "token", upload.UploadToken, "upload should not be modified")
+	require.Len(t, upload.Files, 1, "upload should not be modified")
+}
+
+func TestCheckAccept(t *testing.T) {
+	hook := &testHook{response: &Response{Accept: true}}
+	p := New(hook, time.Second, false)
+
+	err := p.Check(common.NewConfiguration().NewLogger(), NewRequest(UploadStage, &common.Upload{}))
+	require.NoError(t, err)
+	require.Len(t, hook.requests, 1)
+}
+
+func TestCheckReject(t *testing.T) {
+	hook := &testHook{response: &Response{Accept: false, Message: "no way"}}
+	p := New(hook, time.Second, false)
+
+	err := p.Check(common.NewConfiguration().NewLogger(), NewRequest(UploadStage, &common.Upload{}))
+	require.Error(t, err)
+	httpError, ok := err.(common.HTTPError)
+	require.True(t, ok, "invalid error type")
+	require.Equal(t, http.StatusForbidden, httpError.StatusCode)
+	require.Equal(t, "no way", httpError.Message)
+
+	hook.response.Message = ""
+	err = p.Check(common.NewConfiguration().NewLogger(), NewRequest(FileStage, &common.Upload{}))
+	require.Error(t, err)
+	require.Equal(t, "file rejected by upload policy", err.(common.HTTPError).Message)
+}
+
+func TestCheckFailClosed(t *testing.T) {
+	hook := &testHook{err: fmt.Errorf("unavailable")}
+	p := New(hook, time.Second, false)
+
+	err := p.Check(common.NewConfiguration().NewLogger(), NewRequest(UploadStage, &common.Upload{}))
+	require.Error(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, err.(common.HTTPError).StatusCode)
+}
+
+func TestCheckFailOpen(t *testing.T) {
+	hook := &testHook{err: fmt.Errorf("unavailable")}
+	p := New(hook, time.Second, true)
+
+	err := p.Check(common.NewConfiguration().NewLogger(), NewRequest(UploadStage, &common.Upload{}))
+	require.NoError(t, err)
+}
+
+func TestCheckTimeout(t *testing.T) {
+	hook := &testHook{response: &Response{Accept: true}, delay: time.Second}
+	p := New(hook, 10*time.Millisecond, false)
+
+	err := p.Check(common.NewConfiguration().NewLogger(), NewRequest(UploadStage, &common.Upload{}))
+	require.Error(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, err.(common.HTTPError).StatusCode)
+}
+
+func TestExecHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plik-policy")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	script := filepath.Join(dir, "policy.sh")
+	content := `#!/bin/sh
+if [ "$1" != "--strict" ]; then
+	echo "missing argument" >&2
+	exit 1
+fi
+if grep -q '"fileName":"evil.exe"'; then
+	echo '{"accept":false,"message":"executables are not allowed"}'
+else
+	echo '{"accept":true}'
+fi
+`
+	err = ioutil.WriteFile(script, []byte(content), 0700)
+	require.NoError(t, err)
+
+	hook := NewExecHook(script + " --strict")
+
+	request := NewRequest(FileStage, &common.Upload{})
+	request.FileName = "evil.exe"
+	response, err := hook.Call(goContext.Background(), request)
+	require.NoError(t, err)
+	require.False(t, response.Accept)
+	require.Equal(t, "executables are not allowed", response.Message)
+
+	request.FileName = "file.txt"
+	response, err = hook.Call(goContext.Background(), request)
+	require.NoError(t, err)
+	require.True(t, response.Accept)
+
+	hook = NewExecHook(script)
+	_, err = hook.Call(goContext.Background(), request)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "missing argument")
+}
+
+func TestExecHookFailure(t *testing.T) {
+	hook := NewExecHook("/non/existing/policy")
+	_, err := hook.Call(goContext.Background(), NewRequest(UploadStage, &common.Upload{}))
+	require.Error(t, err)
+}
+
+func TestHTTPHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		request := &Request{}
+		err := json.NewDecoder(req.Body).Decode(request)
+		if err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		response := &Response{Accept: request.MimeType != "application/x-msdownload", Message: "nope"}
+		_ = json.NewEncoder(resp).Encode(response)
+	}))
+	defer server.Close()
+
+	hook := NewHTTPHook(server.URL)
+
+	request := NewRequest(FileStage, &common.Upload{})
+	request.MimeType = "application/x-msdownload"
+	response, err := hook.Call(goContext.Background(), request)
+	require.NoError(t, err)
+	require.False(t, response.Accept)
+
+	request.MimeType = "text/plain"
+	response, err = hook.Call(goContext.Background(), request)
+	require.NoError(t, err)
+	require.True(t, response.Accept)
+}
+
+func TestHTTPHookInvalidStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	hook := NewHTTPHook(server.URL)
+	_, err := hook.Call(goContext.Background(), NewRequest(UploadStage, &common.Upload{}))
+	require.Error(t, err)
+}
@@ -0,0 +1,217 @@
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"  // Register the gif decoder
+	_ "image/jpeg" // Register the jpeg decoder
+	"image/png"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+)
+
+// ThumbnailSize is the maximum width and height of the generated thumbnails
+const ThumbnailSize = 256
+
+// MaxImagePixels is the maximum number of pixels of the images to decode
+// Larger images are likely decompression bombs and don't get a thumbnail
+const MaxImagePixels = 40 * 1000 * 1000
+
+// MaxTextSize is the maximum size of the text snippets
+const MaxTextSize = 16 * 1024
+
+// imageTypes are the mime types decoded by the registered image decoders
+var imageTypes = []string{"image/png", "image/jpeg", "image/gif"}
+
+// textTypes are the mime types not starting with text/ that are previewed as text
+var textTypes = []string{"application/json", "application/javascript", "application/xml", "application/x-sh"}
+
+// GetKind returns the kind of preview that can be generated from a file mime type
+func GetKind(mimeType string) string {
+	mimeType = strings.TrimSpace(strings.Split(mimeType, ";")[0])
+
+	for _, t := range imageTypes {
+		if mimeType == t {
+			return common.PreviewImage
+		}
+	}
+
+	if strings.HasPrefix(mimeType, "text/") {
+		return common.PreviewText
+	}
+	for _, t := range textTypes {
+		if mimeType == t {
+			return common.PreviewText
+		}
+	}
+
+	return common.PreviewNone
+}
+
+// GetContentType returns the content type of a generated preview
+func GetContentType(kind string) string {
+	if kind == common.PreviewImage {
+		return "image/png"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// Generate a preview of the file and store it in the data backend as a derived object
+// Returns common.PreviewNone if the file can't be previewed, an error is only returned for backend failures
+func Generate(backend data.Backend, file *common.File, maxFileSize int64) (kind string, err error) {
+	kind = GetKind(file.Type)
+	if kind == common.PreviewNone {
+		return kind, nil
+	}
+	if kind == common.PreviewImage && file.Size > maxFileSize {
+		return common.PreviewNone, nil
+	}
+
+	reader, err := backend.GetFile(file)
+	if err != nil {
+		return "", fmt.Errorf("unable to get file from data backend : %s", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	var content []byte
+	if kind == common.PreviewImage {
+		// Never trust the metadata size to bound the memory usage
+		buf, err := ioutil.ReadAll(io.LimitReader(reader, maxFileSize+1))
+		if err != nil {
+			return "", fmt.Errorf("unable to read file : %s", err)
+		}
+		if int64(len(buf)) > maxFileSize {
+			return common.PreviewNone, nil
+		}
+		content = Thumbnail(buf)
+	} else {
+		buf, err := ioutil.ReadAll(io.LimitReader(reader, MaxTextSize+utf8.UTFMax))
+		if err != nil {
+			return "", fmt.Errorf("unable to read file : %s", err)
+		}
+		content = Snippet(buf)
+	}
+
+	if content == nil {
+		return common.PreviewNone, nil
+	}
+
+	previewFile := file.GetPreviewFile()
+	previewFile.Size = int64(len(content))
+	err = backend.AddFile(previewFile, bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("unable to add preview to data backend : %s", err)
+	}
+
+	return kind, nil
+}
+
+// Get the preview of a file from the data backend
+func Get(backend data.Backend, file *common.File) (reader io.ReadCloser, err error) {
+	return backend.GetFile(file.GetPreviewFile())
+}
+
+// Remove the preview of a file from the data backend
+func Remove(backend data.Backend, file *common.File) (err error) {
+	return backend.RemoveFile(file.GetPreviewFile())
+}
+
+// Thumbnail decodes an image and returns a png encoded thumbnail fitting in ThumbnailSize
+// Returns nil if the image can't be decoded or is too large
+func Thumbnail(buf []byte) []byte {
+	config, _, err := image.DecodeConfig(bytes.NewReader(buf))
+	if err != nil {
+		return nil
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > MaxImagePixels {
+		return nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(buf))
+	if err != nil {
+		return nil
+	}
+
+	out := bytes.NewBuffer(nil)
+	err = png.Encode(out, resize(img, ThumbnailSize))
+	if err != nil {
+		return nil
+	}
+
+	return out.Bytes()
+}
+
+// resize scales an image down to fit in a size x size square using a box filter
+// Images already fitting are only converted
+func resize(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	dstWidth, dstHeight := width, height
+	if width > size || height > size {
+		if width >= height {
+			dstWidth, dstHeight = size, height*size/width
+		} else {
+			dstWidth, dstHeight = width*size/height, size
+		}
+		if dstWidth < 1 {
+			dstWidth = 1
+		}
+		if dstHeight < 1 {
+			dstHeight = 1
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		y0 := bounds.Min.Y + y*height/dstHeight
+		y1 := bounds.Min.Y + (y+1)*height/dstHeight
+		for x := 0; x < dstWidth; x++ {
+			x0 := bounds.Min.X + x*width/dstWidth
+			x1 := bounds.Min.X + (x+1)*width/dstWidth
+
+			// Average the premultiplied colors of the source pixels
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+
+	return dst
+}
+
+// Snippet returns the beginning of a text file truncated to MaxTextSize on a rune boundary
+// Returns nil if the content does not look like utf-8 text
+func Snippet(buf []byte) []byte {
+	if bytes.IndexByte(buf, 0) >= 0 {
+		return nil
+	}
+
+	if len(buf) > MaxTextSize {
+		buf = buf[:MaxTextSize]
+		// Drop the last rune if it has been truncated
+		for i := 0; i < utf8.UTFMax && len(buf) > 0 && !utf8.Valid(buf); i++ {
+			buf = buf[:len(buf)-1]
+		}
+	}
+
+	if !utf8.Valid(buf) {
+		return nil
+	}
+
+	return buf
+}
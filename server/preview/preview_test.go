@@ -0,0 +1,199 @@
+package preview
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	data_test "github.com/root-gg/plik/server/data/testing"
+)
+
+func newTestImage(width int, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	return img
+}
+
+func newTestFile(t *testing.T, backend *data_test.Backend, mimeType string, content []byte) *common.File {
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Type = mimeType
+	file.Size = int64(len(content))
+	upload.PrepareInsertForTests()
+
+	err := backend.AddFile(file, bytes.NewReader(content))
+	require.NoError(t, err, "unable to add file")
+
+	return file
+}
+
+func readTestPreview(t *testing.T, backend *data_test.Backend, file *common.File) []byte {
+	reader, err := Get(backend, file)
+	require.NoError(t, err, "unable to get preview")
+
+	content, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read preview")
+
+	return content
+}
+
+func TestGetKind(t *testing.T) {
+	require.Equal(t, common.PreviewImage, GetKind("image/png"), "invalid kind")
+	require.Equal(t, common.PreviewImage, GetKind("image/jpeg"), "invalid kind")
+	require.Equal(t, common.PreviewText, GetKind("text/plain; charset=utf-8"), "invalid kind")
+	require.Equal(t, common.PreviewText, GetKind("application/json"), "invalid kind")
+	require.Equal(t, common.PreviewNone, GetKind("image/svg+xml"), "invalid kind")
+	require.Equal(t, common.PreviewNone, GetKind("application/octet-stream"), "invalid kind")
+	require.Equal(t, common.PreviewNone, GetKind(""), "invalid kind")
+}
+
+func TestThumbnail(t *testing.T) {
+	encoders := map[string]func(w io.Writer, img image.Image) error{
+		"png":  png.Encode,
+		"jpeg": func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, nil) },
+		"gif":  func(w io.Writer, img image.Image) error { return gif.Encode(w, img, nil) },
+	}
+
+	for name, encode := range encoders {
+		buf := bytes.NewBuffer(nil)
+		err := encode(buf, newTestImage(1024, 512))
+		require.NoError(t, err, "unable to encode %s image", name)
+
+		thumbnail := Thumbnail(buf.Bytes())
+		require.NotNil(t, thumbnail, "missing %s thumbnail", name)
+
+		img, err := png.Decode(bytes.NewReader(thumbnail))
+		require.NoError(t, err, "unable to decode %s thumbnail", name)
+		require.Equal(t, ThumbnailSize, img.Bounds().Dx(), "invalid %s thumbnail width", name)
+		require.Equal(t, ThumbnailSize/2, img.Bounds().Dy(), "invalid %s thumbnail height", name)
+
+		r, _, _, a := img.At(10, 10).RGBA()
+		require.True(t, r > 0xf000 && a == 0xffff, "invalid %s thumbnail color", name)
+	}
+}
+
+func TestThumbnailSmallImage(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	err := png.Encode(buf, newTestImage(16, 1))
+	require.NoError(t, err, "unable to encode image")
+
+	img, err := png.Decode(bytes.NewReader(Thumbnail(buf.Bytes())))
+	require.NoError(t, err, "unable to decode thumbnail")
+	require.Equal(t, image.Rect(0, 0, 16, 1), img.Bounds(), "invalid thumbnail size")
+}
+
+func TestThumbnailInvalidImage(t *testing.T) {
+	require.Nil(t, Thumbnail([]byte("not an image")), "unexpected thumbnail")
+}
+
+func TestThumbnailTooManyPixels(t *testing.T) {
+	// A paletted image compresses very well, only the header is needed to refuse it
+	buf := bytes.NewBuffer(nil)
+	err := png.Encode(buf, image.NewPaletted(image.Rect(0, 0, 10000, 5000), color.Palette{color.Black}))
+	require.NoError(t, err, "unable to encode image")
+
+	require.Nil(t, Thumbnail(buf.Bytes()), "unexpected thumbnail")
+}
+
+func TestSnippet(t *testing.T) {
+	require.Equal(t, []byte("hello"), Snippet([]byte("hello")), "invalid snippet")
+	require.Nil(t, Snippet([]byte("binary\x00data")), "unexpected snippet")
+	require.Nil(t, Snippet([]byte{0xff, 0xfe, 'a'}), "unexpected snippet")
+
+	// Multi-byte runes are not cut in half
+	text := strings.Repeat("a", MaxTextSize-1) + "é"
+	snippet := Snippet([]byte(text))
+	require.Equal(t, MaxTextSize-1, len(snippet), "invalid snippet size")
+}
+
+func TestGenerateImage(t *testing.T) {
+	backend := data_test.NewBackend()
+
+	buf := bytes.NewBuffer(nil)
+	err := png.Encode(buf, newTestImage(512, 512))
+	require.NoError(t, err, "unable to encode image")
+
+	file := newTestFile(t, backend, "image/png", buf.Bytes())
+	kind, err := Generate(backend, file, 1<<20)
+	require.NoError(t, err, "unable to generate preview")
+	require.Equal(t, common.PreviewImage, kind, "invalid preview kind")
+
+	img, err := png.Decode(bytes.NewReader(readTestPreview(t, backend, file)))
+	require.NoError(t, err, "unable to decode preview")
+	require.Equal(t, ThumbnailSize, img.Bounds().Dx(), "invalid preview width")
+
+	err = Remove(backend, file)
+	require.NoError(t, err, "unable to remove preview")
+
+	_, err = Get(backend, file)
+	require.Error(t, err, "preview not removed")
+}
+
+func TestGenerateImageTooLarge(t *testing.T) {
+	backend := data_test.NewBackend()
+
+	buf := bytes.NewBuffer(nil)
+	err := png.Encode(buf, newTestImage(512, 512))
+	require.NoError(t, err, "unable to encode image")
+
+	file := newTestFile(t, backend, "image/png", buf.Bytes())
+	kind, err := Generate(backend, file, 10)
+	require.NoError(t, err, "unable to generate preview")
+	require.Equal(t, common.PreviewNone, kind, "invalid preview kind")
+
+	// The metadata size is not trusted
+	file.Size = 1
+	kind, err = Generate(backend, file, 10)
+	require.NoError(t, err, "unable to generate preview")
+	require.Equal(t, common.PreviewNone, kind, "invalid preview kind")
+}
+
+func TestGenerateText(t *testing.T) {
+	backend := data_test.NewBackend()
+
+	text := strings.Repeat("package main\n", 10000)
+	file := newTestFile(t, backend, "text/plain; charset=utf-8", []byte(text))
+
+	kind, err := Generate(backend, file, 1<<20)
+	require.NoError(t, err, "unable to generate preview")
+	require.Equal(t, common.PreviewText, kind, "invalid preview kind")
+	require.Equal(t, text[:MaxTextSize], string(readTestPreview(t, backend, file)), "invalid preview")
+}
+
+func TestGenerateNotPreviewable(t *testing.T) {
+	backend := data_test.NewBackend()
+
+	file := newTestFile(t, backend, "application/octet-stream", []byte("data"))
+	kind, err := Generate(backend, file, 1<<20)
+	require.NoError(t, err, "unable to generate preview")
+	require.Equal(t, common.PreviewNone, kind, "invalid preview kind")
+
+	file = newTestFile(t, backend, "image/png", []byte("not an image"))
+	kind, err = Generate(backend, file, 1<<20)
+	require.NoError(t, err, "unable to generate preview")
+	require.Equal(t, common.PreviewNone, kind, "invalid preview kind")
+}
+
+func TestGenerateBackendError(t *testing.T) {
+	backend := data_test.NewBackend()
+
+	file := newTestFile(t, backend, "text/plain", []byte("data"))
+	backend.SetError(io.ErrClosedPipe)
+
+	_, err := Generate(backend, file, 1<<20)
+	require.Error(t, err, "missing error")
+}
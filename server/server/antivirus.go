@@ -0,0 +1,166 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/root-gg/plik/server/clamd"
+	"github.com/root-gg/plik/server/common"
+)
+
+// antivirusRoutine periodically re-scan files with clamd
+// Only the Plik instance holding the antivirus lease actually scans the files
+func (ps *PlikServer) antivirusRoutine() {
+	log := ps.config.NewLogger()
+	interval := time.Duration(ps.config.ClamdRescanInterval) * time.Second
+	for {
+		ps.mu.Lock()
+		done := ps.done
+		ps.mu.Unlock()
+
+		if done {
+			break
+		}
+
+		time.Sleep(interval)
+
+		acquired, err := ps.metadataBackend.AcquireLease(common.AntivirusLeaseSettingKey, ps.instanceID, 2*interval)
+		if err != nil {
+			log.Warningf("unable to acquire antivirus lease : %s", err)
+			continue
+		}
+		if !acquired {
+			log.Debugf("Another instance is in charge of the antivirus re-scans")
+			continue
+		}
+
+		scanned, err := ps.Rescan()
+		if scanned > 0 {
+			log.Infof("re-scanned %d files", scanned)
+		}
+		if err != nil {
+			log.Warning(err.Error())
+		}
+	}
+}
+
+// Rescan scan again the files waiting for an antivirus verdict.
+// When clamd signatures have been updated since the last run every uploaded and quarantined file is scanned again.
+func (ps *PlikServer) Rescan() (scanned int, err error) {
+	log := ps.config.NewLogger()
+
+	if ps.antivirus == nil {
+		return 0, fmt.Errorf("antivirus is not enabled")
+	}
+
+	version, err := ps.antivirus.Version()
+	if err != nil {
+		return 0, err
+	}
+	signatures := clamd.SignaturesVersion(version)
+
+	setting, err := ps.metadataBackend.GetSetting(common.AntivirusSignaturesSettingKey)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get antivirus signatures version : %s", err)
+	}
+
+	statuses := []string{common.FileScanning}
+	if setting == nil || setting.Value != signatures {
+		log.Infof("clamd signatures updated to %s, re-scanning all files", signatures)
+		statuses = append(statuses, common.FileUploaded, common.FileQuarantined)
+	}
+
+	var errors []error
+	f := func(file *common.File) (err error) {
+		err = ps.scanFile(file)
+		if err != nil {
+			errors = append(errors, err)
+			log.Warningf("unable to scan file %s/%s : %s", file.UploadID, file.ID, err)
+			return nil
+		}
+
+		scanned++
+		return nil
+	}
+
+	err = ps.metadataBackend.ForEachFileWithStatus(statuses, f)
+	if err != nil {
+		return scanned, err
+	}
+
+	// Files that could not be scanned are left in the scanning status and retried at every pass,
+	// there is no need to scan every other file again until the next signatures update
+	if setting == nil {
+		err = ps.metadataBackend.CreateSetting(&common.Setting{Key: common.AntivirusSignaturesSettingKey, Value: signatures})
+	} else if setting.Value != signatures {
+		err = ps.metadataBackend.UpdateSetting(common.AntivirusSignaturesSettingKey, setting.Value, signatures)
+	}
+	if err != nil {
+		return scanned, fmt.Errorf("unable to save antivirus signatures version : %s", err)
+	}
+
+	if len(errors) > 0 {
+		return scanned, fmt.Errorf("unable to scan %d files", len(errors))
+	}
+
+	return scanned, nil
+}
+
+// scanFile scan a file from the data backend and update its status with the antivirus verdict
+func (ps *PlikServer) scanFile(file *common.File) (err error) {
+	result, err := ps.scan(file)
+	if err == clamd.ErrSizeLimitExceeded {
+		// Scanning it again would fail the same way
+		if ps.config.ClamdQuarantineOversized {
+			result = &clamd.Result{Infected: true, Signature: clamd.SizeLimitSignature}
+		} else {
+			ps.config.NewLogger().Warningf("file %s/%s is too big to be scanned", file.UploadID, file.ID)
+			result = &clamd.Result{}
+		}
+	} else if err != nil {
+		return err
+	}
+
+	status := common.FileUploaded
+	virus := ""
+	if result.Infected {
+		status = common.FileQuarantined
+		virus = result.Signature
+		ps.config.NewLogger().Warningf("file %s/%s has been quarantined : %s", file.UploadID, file.ID, virus)
+	}
+
+	if file.Status == status && file.Virus == virus {
+		return nil
+	}
+
+	oldStatus := file.Status
+	file.Status = status
+	file.Virus = virus
+	err = ps.metadataBackend.UpdateFile(file, oldStatus)
+	if err != nil {
+		return err
+	}
+
+	// Files waiting for the antivirus are only available now
+	if oldStatus == common.FileScanning && status == common.FileUploaded {
+		ps.notifyFileEvent(common.EventFileUploaded, file)
+	}
+
+	return nil
+}
+
+// scan stream a file from the data backend to clamd
+// Files bigger than the configured limit are not sent to clamd at all
+func (ps *PlikServer) scan(file *common.File) (result *clamd.Result, err error) {
+	if maxSize := ps.antivirus.MaxSize(); maxSize > 0 && file.Size > maxSize {
+		return nil, clamd.ErrSizeLimitExceeded
+	}
+
+	reader, err := ps.dataBackend.GetFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get file from data backend : %s", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	return ps.antivirus.Scan(reader)
+}
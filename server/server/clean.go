@@ -7,9 +7,11 @@ import (
 	"time"
 
 	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/preview"
 )
 
 // UploadsCleaningRoutine periodicaly remove expired uploads
+// Only the Plik instance holding the cleaning lease actually cleans the uploads
 func (ps *PlikServer) uploadsCleaningRoutine() {
 	log := ps.config.NewLogger()
 	for {
@@ -20,25 +22,56 @@ func (ps *PlikServer) uploadsCleaningRoutine() {
 		if done {
 			break
 		}
-		// Sleep between 2 hours and 3 hours
-		// This is a dirty trick to avoid frontends doing this at the same time
-		r, _ := rand.Int(rand.Reader, big.NewInt(int64(ps.cleaningRandomDelay)))
-		randomSleep := r.Int64() + int64(ps.cleaningMinOffset)
 
-		log.Infof("Will clean old uploads in %d seconds.", randomSleep)
-		time.Sleep(time.Duration(randomSleep) * time.Second)
-		log.Infof("Cleaning expired uploads...")
+		log.Infof("Will clean old uploads in %d seconds.", int(ps.getCleaningDelay()/time.Second))
+		time.Sleep(ps.getCleaningDelay())
 
+		acquired, err := ps.metadataBackend.AcquireLease(common.CleaningLeaseSettingKey, ps.instanceID, ps.getCleaningLeaseDuration())
+		if err != nil {
+			log.Warningf("unable to acquire cleaning lease : %s", err)
+			continue
+		}
+		if !acquired {
+			log.Debugf("Another instance is in charge of cleaning expired uploads")
+			continue
+		}
+
+		log.Infof("Cleaning expired uploads...")
 		ps.Clean()
 	}
 }
 
+// getCleaningDelay return the delay before the next cleaning
+func (ps *PlikServer) getCleaningDelay() time.Duration {
+	delay := int64(ps.config.CleaningInterval)
+	if ps.config.CleaningRandomDelay > 0 {
+		r, _ := rand.Int(rand.Reader, big.NewInt(int64(ps.config.CleaningRandomDelay)))
+		delay += r.Int64()
+	}
+	return time.Duration(delay) * time.Second
+}
+
+// getCleaningLeaseDuration return how long the cleaning lease is held
+// The lease outlives the delay between two cleanings so the leader keeps renewing it
+// and another instance takes over if the leader stops cleaning
+func (ps *PlikServer) getCleaningLeaseDuration() time.Duration {
+	return 2 * time.Duration(ps.config.CleaningInterval+ps.config.CleaningRandomDelay) * time.Second
+}
+
 // Clean delete expired data and metadata
 func (ps *PlikServer) Clean() {
 	log := ps.config.NewLogger()
+	limit := ps.config.CleaningBatchSize
 
 	// 1 - soft delete expired uploads
-	removed, err := ps.metadataBackend.DeleteExpiredUploads()
+	ps.initializeWebhooks()
+	notify := func(upload *common.Upload) {
+		err := ps.webhooks.Notify(common.EventUploadExpired, upload, nil)
+		if err != nil {
+			log.Warningf("unable to queue %s webhooks : %s", common.EventUploadExpired, err)
+		}
+	}
+	removed, err := ps.metadataBackend.DeleteExpiredUploads(limit, notify)
 	if removed > 0 {
 		log.Infof("removed %d expired uploads", removed)
 	}
@@ -57,16 +90,45 @@ func (ps *PlikServer) Clean() {
 
 	// 3 - purge deleted uploads
 
-	purged, err := ps.metadataBackend.PurgeDeletedUploads()
+	purged, err := ps.metadataBackend.PurgeDeletedUploads(limit)
 	if purged > 0 {
 		log.Infof("purged %d deleted uploads", purged)
 	}
 	if err != nil {
 		log.Warning(err.Error())
 	}
+
+	// 4 - forget old authentication failures
+
+	deadline := time.Now().Add(-time.Duration(ps.config.AuthMaxLockoutDuration) * time.Second)
+	forgotten, err := ps.metadataBackend.PurgeAuthFailures(deadline)
+	if forgotten > 0 {
+		log.Infof("purged %d authentication failures", forgotten)
+	}
+	if err != nil {
+		log.Warning(err.Error())
+	}
+
+	// 5 - forget download counters of expired share links
+	expired, err := ps.metadataBackend.PurgeShareLinkDownloads(time.Now())
+	if expired > 0 {
+		log.Infof("purged %d share link download counters", expired)
+	}
+	if err != nil {
+		log.Warning(err.Error())
+	}
+
+	// 6 - delete expired upload requests
+	requests, err := ps.metadataBackend.PurgeUploadRequests(time.Now())
+	if requests > 0 {
+		log.Infof("purged %d expired upload requests", requests)
+	}
+	if err != nil {
+		log.Warning(err.Error())
+	}
 }
 
-// PurgeDeletedFiles delete "removed" files from the data backend
+// PurgeDeletedFiles delete "removed" files and file versions from the data backend
 func (ps *PlikServer) PurgeDeletedFiles() (deleted int, err error) {
 	log := ps.config.NewLogger()
 
@@ -79,6 +141,14 @@ func (ps *PlikServer) PurgeDeletedFiles() (deleted int, err error) {
 			return
 		}
 
+		// A preview that can't be removed must not prevent the file from being marked as deleted
+		if file.HasPreview() {
+			err = preview.Remove(ps.dataBackend, file)
+			if err != nil {
+				log.Warningf("unable to delete file preview %s/%s : %s", file.UploadID, file.ID, err)
+			}
+		}
+
 		err = ps.metadataBackend.UpdateFileStatus(file, common.FileRemoved, common.FileDeleted)
 		if err != nil {
 			errors = append(errors, err)
@@ -90,7 +160,40 @@ func (ps *PlikServer) PurgeDeletedFiles() (deleted int, err error) {
 		return nil
 	}
 
-	err = ps.metadataBackend.ForEachRemovedFile(f)
+	err = ps.metadataBackend.ForEachRemovedFile(ps.config.CleaningBatchSize, f)
+	if err != nil {
+		return deleted, err
+	}
+
+	// Previous versions of the files are stored under their own data key
+	fv := func(version *common.FileVersion) (err error) {
+		file := version.GetDataFile()
+		err = ps.dataBackend.RemoveFile(file)
+		if err != nil {
+			errors = append(errors, err)
+			log.Warningf("unable to delete file version %s/%s/%d : %s", version.UploadID, version.FileID, version.Version, err)
+			return
+		}
+
+		if file.HasPreview() {
+			err = preview.Remove(ps.dataBackend, file)
+			if err != nil {
+				log.Warningf("unable to delete file version preview %s/%s/%d : %s", version.UploadID, version.FileID, version.Version, err)
+			}
+		}
+
+		err = ps.metadataBackend.UpdateFileVersionStatus(version, common.FileRemoved, common.FileDeleted)
+		if err != nil {
+			errors = append(errors, err)
+			log.Warningf("unable to update deleted file version %s/%s/%d : %s", version.UploadID, version.FileID, version.Version, err)
+			return
+		}
+
+		deleted++
+		return nil
+	}
+
+	err = ps.metadataBackend.ForEachRemovedFileVersion(ps.config.CleaningBatchSize, fv)
 	if err != nil {
 		return deleted, err
 	}
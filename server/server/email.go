@@ -0,0 +1,45 @@
+package server
+
+import (
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// expirationNoticeInterval is the delay between two checks for expiring uploads
+const expirationNoticeInterval = 10 * time.Minute
+
+// expirationNoticeRoutine periodically warn the users about their uploads about to expire
+// Only the Plik instance holding the notification lease actually sends the emails
+func (ps *PlikServer) expirationNoticeRoutine() {
+	log := ps.config.NewLogger()
+	for {
+		ps.mu.Lock()
+		done := ps.done
+		ps.mu.Unlock()
+
+		if done {
+			break
+		}
+
+		time.Sleep(expirationNoticeInterval)
+
+		acquired, err := ps.metadataBackend.AcquireLease(common.NotificationLeaseSettingKey, ps.instanceID, 2*expirationNoticeInterval)
+		if err != nil {
+			log.Warningf("unable to acquire notification lease : %s", err)
+			continue
+		}
+		if !acquired {
+			log.Debugf("Another instance is in charge of the expiration notifications")
+			continue
+		}
+
+		notified, err := ps.notifier.NotifyExpiringUploads(ps.config.CleaningBatchSize)
+		if notified > 0 {
+			log.Infof("notified %d expiring uploads", notified)
+		}
+		if err != nil {
+			log.Warning(err.Error())
+		}
+	}
+}
@@ -16,6 +16,7 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/root-gg/plik/server/clamd"
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 	"github.com/root-gg/plik/server/data"
@@ -23,9 +24,13 @@ import (
 	"github.com/root-gg/plik/server/data/stream"
 	"github.com/root-gg/plik/server/data/swift"
 	data_test "github.com/root-gg/plik/server/data/testing"
+	"github.com/root-gg/plik/server/email"
+	"github.com/root-gg/plik/server/fetch"
 	"github.com/root-gg/plik/server/handlers"
 	"github.com/root-gg/plik/server/metadata"
 	"github.com/root-gg/plik/server/middleware"
+	"github.com/root-gg/plik/server/policy"
+	"github.com/root-gg/plik/server/webhook"
 )
 
 // PlikServer is a Plik Server instance
@@ -38,23 +43,28 @@ type PlikServer struct {
 
 	authenticator *common.SessionAuthenticator
 
+	antivirus *clamd.Client
+	policy    *policy.Policy
+	webhooks  *webhook.Dispatcher
+	notifier  *email.Notifier
+
+	fetchLimiter *fetch.Limiter
+
 	httpServer *http.Server
 
 	mu      sync.Mutex
 	started bool
 	done    bool
 
-	cleaningRandomDelay int
-	cleaningMinOffset   int
+	// Identify this instance in the cleaning lease
+	instanceID string
 }
 
 // NewPlikServer create a new Plik Server instance
 func NewPlikServer(config *common.Configuration) (ps *PlikServer) {
 	ps = new(PlikServer)
 	ps.config = config
-
-	ps.cleaningRandomDelay = 3600
-	ps.cleaningMinOffset = 7200
+	ps.instanceID = common.GenerateRandomID(16)
 
 	return ps
 }
@@ -111,10 +121,39 @@ func (ps *PlikServer) start() (err error) {
 		return fmt.Errorf("unable to initialize session authenticator : %s", err)
 	}
 
+	err = ps.initializeAntivirus()
+	if err != nil {
+		return fmt.Errorf("unable to initialize antivirus : %s", err)
+	}
+
+	err = ps.initializePolicy()
+	if err != nil {
+		return fmt.Errorf("unable to initialize upload policy : %s", err)
+	}
+
+	ps.initializeWebhooks()
+
+	ps.fetchLimiter = fetch.NewLimiter(ps.config.FetchMaxConcurrent, ps.config.FetchMaxConcurrentPerUser)
+
+	err = ps.initializeNotifier()
+	if err != nil {
+		return fmt.Errorf("unable to initialize email notifications : %s", err)
+	}
+
 	if ps.config.IsAutoClean() {
 		go ps.uploadsCleaningRoutine()
 	}
 
+	go ps.webhooksRoutine()
+
+	if ps.antivirus != nil {
+		go ps.antivirusRoutine()
+	}
+
+	if ps.notifier != nil {
+		go ps.expirationNoticeRoutine()
+	}
+
 	handler := ps.getHTTPHandler()
 
 	var proto string
@@ -122,6 +161,8 @@ func (ps *PlikServer) start() (err error) {
 	if ps.config.SslEnabled {
 		proto = "https"
 		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS10}
+		tlsConfig.ClientAuth = ps.config.GetSslClientAuthType()
+		tlsConfig.ClientCAs = ps.config.GetSslClientCAs()
 
 		if ps.config.SslCert == "" || ps.config.SslKey == "" {
 			return fmt.Errorf("unable to start plik server without ssl certificates")
@@ -202,6 +243,26 @@ func (ps *PlikServer) shutdown(timeout time.Duration) (err error) {
 	}
 
 	if ps.metadataBackend != nil {
+		// Let another instance take over the cleaning right away
+		err = ps.metadataBackend.ReleaseLease(common.CleaningLeaseSettingKey, ps.instanceID)
+		if err != nil {
+			log.Warningf("unable to release cleaning lease : %s", err)
+		}
+
+		if ps.antivirus != nil {
+			err = ps.metadataBackend.ReleaseLease(common.AntivirusLeaseSettingKey, ps.instanceID)
+			if err != nil {
+				log.Warningf("unable to release antivirus lease : %s", err)
+			}
+		}
+
+		if ps.notifier != nil {
+			err = ps.metadataBackend.ReleaseLease(common.NotificationLeaseSettingKey, ps.instanceID)
+			if err != nil {
+				log.Warningf("unable to release notification lease : %s", err)
+			}
+		}
+
 		err = ps.metadataBackend.Shutdown()
 		if err != nil {
 			log.Warningf("unable to shutdown metadata backend : %s", err)
@@ -233,18 +294,38 @@ func (ps *PlikServer) getHTTPHandler() (handler http.Handler) {
 	// HTTP Api routes configuration
 	router := mux.NewRouter()
 	router.Handle("/", tokenChain.Append(middleware.CreateUpload).Then(handlers.AddFile)).Methods("POST")
+	router.Handle("/{filename}", tokenChain.Append(middleware.CreateUpload).Then(handlers.AddFile)).Methods("PUT")
+	router.Handle("/paste", tokenChain.Append(middleware.Paste, middleware.CreateUpload).Then(handlers.CreatePaste)).Methods("POST")
 	router.Handle("/config", stdChain.Then(handlers.GetConfiguration)).Methods("GET")
 	router.Handle("/version", stdChain.Then(handlers.GetVersion)).Methods("GET")
 	router.Handle("/upload", tokenChain.Then(handlers.CreateUpload)).Methods("POST")
 	router.Handle("/upload/{uploadID}", authChain.Append(middleware.Upload).Then(handlers.GetUpload)).Methods("GET")
 	router.Handle("/upload/{uploadID}", tokenChain.Append(middleware.Upload).Then(handlers.RemoveUpload)).Methods("DELETE")
+	router.Handle("/upload/{uploadID}", tokenChain.Append(middleware.Upload).Then(handlers.UpdateUpload)).Methods("PATCH")
+	router.Handle("/upload/{uploadID}/login", stdChain.Then(handlers.UploadLogin)).Methods("POST")
+	router.Handle("/upload/{uploadID}/share", tokenChain.Append(middleware.Upload).Then(handlers.CreateShareLink)).Methods("POST")
+	router.Handle("/upload/{uploadID}/share", tokenChain.Append(middleware.Upload).Then(handlers.RevokeShareLinks)).Methods("DELETE")
+	router.Handle("/upload/{uploadID}/webhooks", tokenChain.Append(middleware.Upload).Then(handlers.GetUploadWebhooks)).Methods("GET")
+	router.Handle("/upload/{uploadID}/webhooks", tokenChain.Append(middleware.Upload).Then(handlers.CreateUploadWebhook)).Methods("POST")
+	router.Handle("/upload/{uploadID}/webhooks/{webhookID}", tokenChain.Append(middleware.Upload).Then(handlers.RemoveUploadWebhook)).Methods("DELETE")
+	router.Handle("/request/{requestID}", stdChain.Append(middleware.UploadRequest).Then(handlers.GetUploadRequest)).Methods("GET")
+	router.Handle("/request/{requestID}", stdChain.Append(middleware.UploadRequest, middleware.CreateUpload).Then(handlers.AddFile)).Methods("POST")
+	router.Handle("/request/{requestID}/{filename}", stdChain.Append(middleware.UploadRequest, middleware.CreateUpload).Then(handlers.AddFile)).Methods("PUT")
+	router.Handle("/request/{requestID}/upload", stdChain.Append(middleware.UploadRequest).Then(handlers.CreateUpload)).Methods("POST")
 	router.Handle("/file/{uploadID}", tokenChain.Append(middleware.Upload).Then(handlers.AddFile)).Methods("POST")
+	router.Handle("/file/{uploadID}/fetch", tokenChain.Append(middleware.Upload).Then(handlers.FetchFile)).Methods("POST")
 	router.Handle("/file/{uploadID}/{fileID}/{filename}", tokenChain.Append(middleware.Upload, middleware.File).Then(handlers.AddFile)).Methods("POST")
 	router.Handle("/file/{uploadID}/{fileID}/{filename}", tokenChain.Append(middleware.Upload, middleware.File).Then(handlers.RemoveFile)).Methods("DELETE")
+	router.Handle("/file/{uploadID}/{fileID}/{filename}/versions", tokenChain.Append(middleware.FileVersions, middleware.Upload, middleware.File).Then(handlers.AddFileVersion)).Methods("POST", "PUT")
 	router.Handle("/file/{uploadID}/{fileID}/{filename}", authChainWithRedirect.AppendChain(getFileChain).Then(handlers.GetFile)).Methods("HEAD", "GET")
+	router.Handle("/file/{uploadID}/{fileID}/{filename}/preview", authChainWithRedirect.AppendChain(getFileChain).Then(handlers.GetFilePreview)).Methods("HEAD", "GET")
+	router.Handle("/file/{uploadID}/{fileID}/{filename}/contents", authChainWithRedirect.AppendChain(getFileChain).Then(handlers.GetArchiveContents)).Methods("GET")
+	router.Handle("/file/{uploadID}/{fileID}/{filename}/contents/{index}", authChainWithRedirect.AppendChain(getFileChain).Then(handlers.GetArchiveMember)).Methods("HEAD", "GET")
+	router.Handle("/paste/{uploadID}/{fileID}/{filename}", authChainWithRedirect.AppendChain(getFileChain).Then(handlers.GetPaste)).Methods("HEAD", "GET")
+	router.Handle("/paste/{uploadID}/{fileID}/{filename}/raw", authChainWithRedirect.AppendChain(getFileChain).Then(handlers.GetPasteRaw)).Methods("HEAD", "GET")
 	router.Handle("/stream/{uploadID}/{fileID}/{filename}", tokenChain.Append(middleware.Upload, middleware.File).Then(handlers.AddFile)).Methods("POST")
 	router.Handle("/stream/{uploadID}/{fileID}/{filename}", authChainWithRedirect.AppendChain(getFileChain).Then(handlers.GetFile)).Methods("HEAD", "GET")
-	router.Handle("/archive/{uploadID}/{filename}", authChainWithRedirect.Append(middleware.Upload).Then(handlers.GetArchive)).Methods("HEAD", "GET")
+	router.Handle("/archive/{uploadID}/{filename}", authChainWithRedirect.Append(middleware.Upload).Then(handlers.GetArchive)).Methods("HEAD", "GET", "POST")
 	router.Handle("/auth/google/login", authChain.Then(handlers.GoogleLogin)).Methods("GET")
 	router.Handle("/auth/google/callback", stdChainWithRedirect.Then(handlers.GoogleCallback)).Methods("GET")
 	router.Handle("/auth/ovh/login", authChain.Then(handlers.OvhLogin)).Methods("GET")
@@ -253,14 +334,52 @@ func (ps *PlikServer) getHTTPHandler() (handler http.Handler) {
 	router.Handle("/auth/logout", authChain.Then(handlers.Logout)).Methods("GET")
 	router.Handle("/me", authChain.Then(handlers.UserInfo)).Methods("GET")
 	router.Handle("/me", authChain.Then(handlers.DeleteAccount)).Methods("DELETE")
+	router.Handle("/me/notifications", authChain.Then(handlers.UpdateUserNotifications)).Methods("POST")
 	router.Handle("/me/token", pagingChain.Then(handlers.GetUserTokens)).Methods("GET")
 	router.Handle("/me/token", authChain.Then(handlers.CreateToken)).Methods("POST")
 	router.Handle("/me/token/{token}", authChain.Then(handlers.RevokeToken)).Methods("DELETE")
 	router.Handle("/me/uploads", pagingChain.Then(handlers.GetUserUploads)).Methods("GET")
 	router.Handle("/me/uploads", authChain.Then(handlers.RemoveUserUploads)).Methods("DELETE")
+	router.Handle("/me/shared", pagingChain.Then(handlers.GetSharedUploads)).Methods("GET")
 	router.Handle("/me/stats", authChain.Then(handlers.GetUserStatistics)).Methods("GET")
+	router.Handle("/me/requests", pagingChain.Then(handlers.GetUploadRequests)).Methods("GET")
+	router.Handle("/me/requests", authChain.Then(handlers.CreateUploadRequest)).Methods("POST")
+	router.Handle("/me/requests/{requestID}", authChain.Then(handlers.RemoveUploadRequest)).Methods("DELETE")
+	router.Handle("/me/webhooks", pagingChain.Then(handlers.GetUserWebhooks)).Methods("GET")
+	router.Handle("/me/webhooks", authChain.Then(handlers.CreateUserWebhook)).Methods("POST")
+	router.Handle("/me/webhooks/{webhookID}", authChain.Then(handlers.RemoveUserWebhook)).Methods("DELETE")
+	router.Handle("/groups", authChain.Then(handlers.GetGroups)).Methods("GET")
+	router.Handle("/groups", authChain.Then(handlers.CreateGroup)).Methods("POST")
+	router.Handle("/groups/{groupID}", authChain.Then(handlers.GetGroup)).Methods("GET")
+	router.Handle("/groups/{groupID}", authChain.Then(handlers.UpdateGroup)).Methods("POST")
+	router.Handle("/groups/{groupID}", authChain.Then(handlers.RemoveGroup)).Methods("DELETE")
+	router.Handle("/groups/{groupID}/members", authChain.Then(handlers.AddGroupMember)).Methods("POST")
+	router.Handle("/groups/{groupID}/members/{userID}", authChain.Then(handlers.RemoveGroupMember)).Methods("DELETE")
+	router.Handle("/groups/{groupID}/uploads", pagingChain.Then(handlers.GetGroupUploads)).Methods("GET")
+	router.Handle("/groups/{groupID}/uploads", authChain.Then(handlers.RemoveGroupUploads)).Methods("DELETE")
+	router.Handle("/groups/{groupID}/stats", authChain.Then(handlers.GetGroupStatistics)).Methods("GET")
+	router.Handle("/groups/{groupID}/tokens", pagingChain.Then(handlers.GetGroupTokens)).Methods("GET")
+	router.Handle("/groups/{groupID}/tokens", authChain.Then(handlers.CreateGroupToken)).Methods("POST")
+	router.Handle("/groups/{groupID}/tokens/{token}", authChain.Then(handlers.RevokeGroupToken)).Methods("DELETE")
 	router.Handle("/stats", authChain.Then(handlers.GetServerStatistics)).Methods("GET")
 	router.Handle("/users", pagingChain.Then(handlers.GetUsers)).Methods("GET")
+	router.Handle("/users", authChain.Then(handlers.CreateUser)).Methods("POST")
+	router.Handle("/users/{userID}", authChain.Then(handlers.GetUser)).Methods("GET")
+	router.Handle("/users/{userID}", authChain.Then(handlers.UpdateUser)).Methods("POST")
+	router.Handle("/users/{userID}", authChain.Then(handlers.RemoveUser)).Methods("DELETE")
+	router.Handle("/users/{userID}/tokens", pagingChain.Then(handlers.AdminGetUserTokens)).Methods("GET")
+	router.Handle("/users/{userID}/tokens", authChain.Then(handlers.AdminCreateUserToken)).Methods("POST")
+	router.Handle("/users/{userID}/tokens/{token}", authChain.Then(handlers.AdminRevokeUserToken)).Methods("DELETE")
+	router.Handle("/uploads", pagingChain.Then(handlers.GetUploads)).Methods("GET")
+	router.Handle("/uploads/{uploadID}", authChain.Then(handlers.AdminUpdateUpload)).Methods("POST")
+	router.Handle("/uploads/{uploadID}", authChain.Then(handlers.AdminRemoveUpload)).Methods("DELETE")
+	router.Handle("/uploads/{uploadID}/moderate", authChain.Then(handlers.ModerateUpload)).Methods("POST")
+	router.Handle("/report", authChain.Then(handlers.CreateReport)).Methods("POST")
+	router.Handle("/reports", pagingChain.Then(handlers.GetReports)).Methods("GET")
+	router.Handle("/reports/{reportID}", authChain.Then(handlers.ModerateReport)).Methods("POST")
+	router.Handle("/blocklist", pagingChain.Then(handlers.GetBlockedHashes)).Methods("GET")
+	router.Handle("/blocklist", authChain.Then(handlers.AddBlockedHash)).Methods("POST")
+	router.Handle("/blocklist/{hash}", authChain.Then(handlers.RemoveBlockedHash)).Methods("DELETE")
 	router.Handle("/qrcode", stdChain.Then(handlers.GetQrCode)).Methods("GET")
 
 	if !ps.config.NoWebInterface {
@@ -378,7 +497,8 @@ func (ps *PlikServer) WithAuthenticator(authenticator *common.SessionAuthenticat
 
 // Initialize the session authenticator
 func (ps *PlikServer) initializeAuthenticator() (err error) {
-	if ps.authenticator == nil && ps.config.Authentication {
+	// The authenticator is also needed to sign password protected upload session cookies
+	if ps.authenticator == nil {
 		if ps.metadataBackend == nil {
 			return fmt.Errorf("metadata backend must be initialized before the authenticator")
 		}
@@ -409,6 +529,78 @@ func (ps *PlikServer) initializeAuthenticator() (err error) {
 	return err
 }
 
+// WithAntivirus configure the clamd client to use ( call before Start() )
+func (ps *PlikServer) WithAntivirus(antivirus *clamd.Client) *PlikServer {
+	if ps.antivirus == nil {
+		ps.antivirus = antivirus
+	}
+	return ps
+}
+
+// Initialize the clamd client if an address is configured
+func (ps *PlikServer) initializeAntivirus() (err error) {
+	if ps.antivirus == nil && ps.config.Antivirus {
+		ps.antivirus, err = clamd.NewClient(ps.config.ClamdAddress, time.Duration(ps.config.ClamdTimeout)*time.Second, ps.config.ClamdMaxScanSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ps.antivirus != nil {
+		// Files will be left in the scanning status until clamd is reachable
+		err = ps.antivirus.Ping()
+		if err != nil {
+			ps.config.NewLogger().Warningf("clamd is not reachable : %s", err)
+		}
+	}
+
+	return nil
+}
+
+// WithPolicy configure the upload policy to use ( call before Start() )
+func (ps *PlikServer) WithPolicy(policy *policy.Policy) *PlikServer {
+	if ps.policy == nil {
+		ps.policy = policy
+	}
+	return ps
+}
+
+// Initialize the upload policy if a hook is configured
+func (ps *PlikServer) initializePolicy() (err error) {
+	if ps.policy == nil {
+		ps.policy, err = policy.NewPolicy(ps.config)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Initialize the webhook dispatcher
+// Background tasks might run without starting the server so this is safe to call several times
+func (ps *PlikServer) initializeWebhooks() {
+	if ps.webhooks == nil {
+		ps.webhooks = webhook.NewDispatcher(ps.config, ps.metadataBackend)
+	}
+}
+
+// Initialize the email notifier if an SMTP server is configured
+// Background tasks might run without starting the server so this is safe to call several times
+func (ps *PlikServer) initializeNotifier() (err error) {
+	if ps.notifier == nil {
+		mailer, err := email.NewMailer(ps.config)
+		if err != nil {
+			return err
+		}
+		if mailer != nil {
+			ps.notifier = email.NewNotifier(ps.config, ps.metadataBackend, mailer)
+		}
+	}
+
+	return nil
+}
+
 // GetConfig return the server configuration
 func (ps *PlikServer) GetConfig() *common.Configuration {
 	return ps.config
@@ -429,6 +621,11 @@ func (ps *PlikServer) GetStreamBackend() data.Backend {
 	return ps.streamBackend
 }
 
+// GetAntivirus return the configured clamd client ( nil if the antivirus is disabled )
+func (ps *PlikServer) GetAntivirus() *clamd.Client {
+	return ps.antivirus
+}
+
 // SetupContext sets necessary context values
 func (ps *PlikServer) setupContext(ctx *context.Context) {
 	ctx.SetConfig(ps.config)
@@ -437,4 +634,9 @@ func (ps *PlikServer) setupContext(ctx *context.Context) {
 	ctx.SetDataBackend(ps.dataBackend)
 	ctx.SetStreamBackend(ps.streamBackend)
 	ctx.SetAuthenticator(ps.authenticator)
+	ctx.SetAntivirus(ps.antivirus)
+	ctx.SetPolicy(ps.policy)
+	ctx.SetWebhooks(ps.webhooks)
+	ctx.SetNotifier(ps.notifier)
+	ctx.SetFetchLimiter(ps.fetchLimiter)
 }
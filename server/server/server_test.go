@@ -9,6 +9,8 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/root-gg/plik/server/clamd"
+	clamd_test "github.com/root-gg/plik/server/clamd/testing"
 	"github.com/root-gg/plik/server/common"
 	data_test "github.com/root-gg/plik/server/data/testing"
 )
@@ -125,12 +127,79 @@ func TestClean(t *testing.T) {
 	require.Error(t, err, "missing get file error")
 }
 
+func TestCleanFilePreview(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	file.Preview = common.PreviewText
+	upload.TTL = 1
+	deadline := time.Now().Add(-10 * time.Minute)
+	upload.ExpireAt = &deadline
+	upload.PrepareInsertForTests()
+
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to save upload")
+
+	err = ps.dataBackend.AddFile(file, bytes.NewBufferString("data data data"))
+	require.NoError(t, err, "unable to save file")
+
+	err = ps.dataBackend.AddFile(file.GetPreviewFile(), bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to save file preview")
+
+	ps.Clean()
+
+	_, err = ps.dataBackend.GetFile(file.GetPreviewFile())
+	require.Error(t, err, "missing get file preview error")
+}
+
+func TestCleanFileVersions(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	upload.TTL = 1
+	deadline := time.Now().Add(-10 * time.Minute)
+	upload.ExpireAt = &deadline
+	upload.PrepareInsertForTests()
+
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to save upload")
+
+	err = ps.dataBackend.AddFile(file, bytes.NewBufferString("version 1"))
+	require.NoError(t, err, "unable to save file")
+
+	previous := *file
+	version := &common.File{UploadID: upload.ID, DataID: common.GenerateRandomID(16)}
+	err = ps.dataBackend.AddFile(version, bytes.NewBufferString("version 2"))
+	require.NoError(t, err, "unable to save file version")
+
+	err = ps.metadataBackend.AddFileVersion(file, version, 0)
+	require.NoError(t, err, "unable to add file version")
+
+	ps.Clean()
+
+	_, err = ps.dataBackend.GetFile(&previous)
+	require.Error(t, err, "missing get previous version error")
+
+	_, err = ps.dataBackend.GetFile(file)
+	require.Error(t, err, "missing get file error")
+
+	u, err := ps.metadataBackend.GetUpload(upload.ID)
+	require.NoError(t, err, "unexpected unable to get upload")
+	require.Nil(t, u, "should be unable to get expired upload after clean")
+}
+
 func TestAutoClean(t *testing.T) {
 	ps := newPlikServer()
 	defer ps.ShutdownNow()
 
-	ps.cleaningRandomDelay = 1
-	ps.cleaningMinOffset = 1
+	ps.config.CleaningInterval = 1
+	ps.config.CleaningRandomDelay = 0
 	ps.config.AutoClean(true)
 
 	err := ps.Start()
@@ -153,3 +222,194 @@ func TestAutoClean(t *testing.T) {
 	require.NoError(t, err, "unexpected unable to get upload")
 	require.Nil(t, u, "should be unable to get expired upload after clean")
 }
+
+func TestRescan(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	clamd, err := clamd_test.NewServer()
+	require.NoError(t, err, "unable to start fake clamd")
+	defer func() { _ = clamd.Close() }()
+
+	ps.config.ClamdAddress = clamd.Address()
+	ps.config.Antivirus = true
+	err = ps.initializeAntivirus()
+	require.NoError(t, err, "unable to initialize antivirus")
+	require.NotNil(t, ps.GetAntivirus(), "missing antivirus")
+
+	upload := &common.Upload{}
+	clean := upload.NewFile()
+	clean.Status = common.FileUploaded
+	infected := upload.NewFile()
+	infected.Status = common.FileUploaded
+	scanning := upload.NewFile()
+	scanning.Status = common.FileScanning
+	upload.PrepareInsertForTests()
+
+	err = ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to save upload")
+
+	for _, file := range upload.Files {
+		err = ps.dataBackend.AddFile(file, bytes.NewBufferString("data data data"))
+		require.NoError(t, err, "unable to save file")
+	}
+	_ = ps.dataBackend.RemoveFile(infected)
+	err = ps.dataBackend.AddFile(infected, bytes.NewBufferString("data EICAR data"))
+	require.NoError(t, err, "unable to save file")
+
+	// First run scans every file with the current signatures
+	scanned, err := ps.Rescan()
+	require.NoError(t, err, "unable to rescan files")
+	require.Equal(t, 3, scanned, "invalid scanned file count")
+
+	for _, file := range upload.Files {
+		f, err := ps.metadataBackend.GetFile(file.ID)
+		require.NoError(t, err, "unable to get file")
+		require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+	}
+
+	// Nothing to do until the signatures are updated
+	scanned, err = ps.Rescan()
+	require.NoError(t, err, "unable to rescan files")
+	require.Equal(t, 0, scanned, "invalid scanned file count")
+
+	clamd.AddSignature("EICAR", "Eicar-Signature")
+	clamd.SetVersion("ClamAV 0.103.2/26201/Tue Jun 15 08:01:48 2021")
+
+	scanned, err = ps.Rescan()
+	require.NoError(t, err, "unable to rescan files")
+	require.Equal(t, 3, scanned, "invalid scanned file count")
+
+	f, err := ps.metadataBackend.GetFile(infected.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileQuarantined, f.Status, "invalid file status")
+	require.Equal(t, "Eicar-Signature", f.Virus, "invalid virus")
+
+	f, err = ps.metadataBackend.GetFile(clean.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+}
+
+func TestRescanSizeLimit(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	clamdServer, err := clamd_test.NewServer()
+	require.NoError(t, err, "unable to start fake clamd")
+	defer func() { _ = clamdServer.Close() }()
+
+	ps.config.ClamdAddress = clamdServer.Address()
+	ps.config.ClamdMaxScanSize = 10
+	ps.config.Antivirus = true
+	err = ps.initializeAntivirus()
+	require.NoError(t, err, "unable to initialize antivirus")
+
+	upload := &common.Upload{}
+	small := upload.NewFile()
+	small.Status = common.FileUploaded
+	small.Size = 4
+	big := upload.NewFile()
+	big.Status = common.FileUploaded
+	big.Size = 20
+	tooBigForClamd := upload.NewFile()
+	tooBigForClamd.Status = common.FileScanning
+	tooBigForClamd.Size = 8
+	upload.PrepareInsertForTests()
+
+	err = ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to save upload")
+
+	err = ps.dataBackend.AddFile(small, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to save file")
+	err = ps.dataBackend.AddFile(big, bytes.NewBufferString("data data data data "))
+	require.NoError(t, err, "unable to save file")
+	err = ps.dataBackend.AddFile(tooBigForClamd, bytes.NewBufferString("datadata"))
+	require.NoError(t, err, "unable to save file")
+
+	// Clamd refuses the stream of the scanning file
+	clamdServer.SetError("INSTREAM size limit exceeded.")
+	scanned, err := ps.Rescan()
+	require.NoError(t, err, "unable to rescan files")
+	require.Equal(t, 3, scanned, "invalid scanned file count")
+	require.Equal(t, 2, clamdServer.Scans(), "the big file should not be sent to clamd")
+
+	// Files too big to be scanned are available unscanned by default
+	for _, file := range upload.Files {
+		f, err := ps.metadataBackend.GetFile(file.ID)
+		require.NoError(t, err, "unable to get file")
+		require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+	}
+
+	// Nothing is scanned again until the signatures are updated
+	scanned, err = ps.Rescan()
+	require.NoError(t, err, "unable to rescan files")
+	require.Equal(t, 0, scanned, "invalid scanned file count")
+	require.Equal(t, 2, clamdServer.Scans(), "invalid clamd scan count")
+
+	// Or they can be quarantined
+	ps.config.ClamdQuarantineOversized = true
+	clamdServer.SetError("")
+	clamdServer.SetVersion("ClamAV 0.103.2/26201/Tue Jun 15 08:01:48 2021")
+
+	scanned, err = ps.Rescan()
+	require.NoError(t, err, "unable to rescan files")
+	require.Equal(t, 3, scanned, "invalid scanned file count")
+	require.Equal(t, 4, clamdServer.Scans(), "the big file should not be sent to clamd")
+
+	f, err := ps.metadataBackend.GetFile(big.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileQuarantined, f.Status, "invalid file status")
+	require.Equal(t, clamd.SizeLimitSignature, f.Virus, "invalid virus")
+}
+
+func TestRescanSavesSignaturesOnErrors(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	clamdServer, err := clamd_test.NewServer()
+	require.NoError(t, err, "unable to start fake clamd")
+	defer func() { _ = clamdServer.Close() }()
+
+	ps.config.ClamdAddress = clamdServer.Address()
+	ps.config.Antivirus = true
+	err = ps.initializeAntivirus()
+	require.NoError(t, err, "unable to initialize antivirus")
+
+	upload := &common.Upload{}
+	for i := 0; i < 3; i++ {
+		file := upload.NewFile()
+		file.Status = common.FileUploaded
+	}
+	upload.PrepareInsertForTests()
+
+	err = ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to save upload")
+
+	for _, file := range upload.Files {
+		err = ps.dataBackend.AddFile(file, bytes.NewBufferString("data"))
+		require.NoError(t, err, "unable to save file")
+	}
+
+	clamdServer.SetError("Can't allocate memory")
+	_, err = ps.Rescan()
+	require.Error(t, err, "missing rescan error")
+	require.Equal(t, 3, clamdServer.Scans(), "invalid clamd scan count")
+
+	setting, err := ps.metadataBackend.GetSetting(common.AntivirusSignaturesSettingKey)
+	require.NoError(t, err, "unable to get setting")
+	require.NotNil(t, setting, "missing signatures setting")
+	require.Equal(t, "26200", setting.Value, "invalid signatures setting")
+
+	// Only the files waiting for a verdict are scanned again
+	f, err := ps.metadataBackend.GetFile(upload.Files[0].ID)
+	require.NoError(t, err, "unable to get file")
+	f.Status = common.FileScanning
+	err = ps.metadataBackend.UpdateFile(f, common.FileUploaded)
+	require.NoError(t, err, "unable to update file")
+
+	clamdServer.SetError("")
+	scanned, err := ps.Rescan()
+	require.NoError(t, err, "unable to rescan files")
+	require.Equal(t, 1, scanned, "invalid scanned file count")
+	require.Equal(t, 4, clamdServer.Scans(), "invalid clamd scan count")
+}
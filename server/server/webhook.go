@@ -0,0 +1,63 @@
+package server
+
+import (
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// webhookBatchSize is the maximum number of webhook deliveries sent at each poll
+const webhookBatchSize = 100
+
+// webhooksRoutine periodically send the pending webhook deliveries of the outbox
+// Every Plik instance sends deliveries, each delivery attempt is claimed by a single instance
+func (ps *PlikServer) webhooksRoutine() {
+	log := ps.config.NewLogger()
+	interval := time.Duration(ps.config.WebhookPollInterval) * time.Second
+	for {
+		ps.mu.Lock()
+		done := ps.done
+		ps.mu.Unlock()
+
+		if done {
+			break
+		}
+
+		time.Sleep(interval)
+
+		delivered, err := ps.webhooks.Deliver(webhookBatchSize)
+		if delivered > 0 {
+			log.Debugf("delivered %d webhooks", delivered)
+		}
+		if err != nil {
+			log.Warning(err.Error())
+		}
+	}
+}
+
+// notifyFileEvent queue the webhook deliveries and send the email notifications of a file event from a background routine
+func (ps *PlikServer) notifyFileEvent(event string, file *common.File) {
+	log := ps.config.NewLogger()
+
+	upload, err := ps.metadataBackend.GetUpload(file.UploadID)
+	if err != nil {
+		log.Warningf("unable to get upload %s : %s", file.UploadID, err)
+		return
+	}
+	if upload == nil {
+		return
+	}
+
+	ps.initializeWebhooks()
+	err = ps.webhooks.Notify(event, upload, file)
+	if err != nil {
+		log.Warningf("unable to queue %s webhooks : %s", event, err)
+	}
+
+	if ps.notifier != nil {
+		err = ps.notifier.Notify(event, upload, file)
+		if err != nil {
+			log.Warningf("unable to send %s email notification : %s", event, err)
+		}
+	}
+}
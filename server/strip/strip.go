@@ -0,0 +1,395 @@
+package strip
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+var jpegMagic = []byte{0xFF, 0xD8, 0xFF}
+var pngMagic = []byte("\x89PNG\r\n\x1a\n")
+
+// JPEG markers
+const (
+	markerTEM  = 0x01
+	markerRST0 = 0xD0
+	markerRST7 = 0xD7
+	markerEOI  = 0xD9
+	markerSOS  = 0xDA
+	markerAPP1 = 0xE1 // EXIF and XMP
+	markerAPPD = 0xED // IPTC ( Photoshop IRB )
+)
+
+// pngMetadataChunks are the PNG chunks holding EXIF, XMP, textual metadata or the last modification time
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+// WebP VP8X flags of the metadata chunks
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// InvalidImageError is returned when an image looks like a JPEG, PNG or WebP file but can't be parsed
+type InvalidImageError struct {
+	format string
+	msg    string
+}
+
+func (e *InvalidImageError) Error() string {
+	return fmt.Sprintf("invalid %s image : %s", e.format, e.msg)
+}
+
+func invalidImage(format string, msg string) error {
+	return &InvalidImageError{format: format, msg: msg}
+}
+
+// IsInvalidImage returns true if the error is an InvalidImageError
+func IsInvalidImage(err error) bool {
+	var invalid *InvalidImageError
+	return errors.As(err, &invalid)
+}
+
+// Reader strips the metadata of the images read from the underlying reader
+type Reader struct {
+	pipe     *io.PipeReader
+	stripped bool
+}
+
+// NewReader returns a reader streaming the content of r with the image metadata removed
+// Close must be called to release the stripping goroutine if the reader is not read until EOF
+func NewReader(r io.Reader) *Reader {
+	pipeReader, pipeWriter := io.Pipe()
+	reader := &Reader{pipe: pipeReader}
+
+	go func() {
+		stripped, err := Strip(pipeWriter, r)
+		// Set before closing the pipe so it's visible once the reader gets EOF
+		reader.stripped = stripped
+		_ = pipeWriter.CloseWithError(err)
+	}()
+
+	return reader
+}
+
+// Read implements io.Reader
+func (r *Reader) Read(p []byte) (n int, err error) {
+	return r.pipe.Read(p)
+}
+
+// Close implements io.Closer
+func (r *Reader) Close() error {
+	return r.pipe.Close()
+}
+
+// Stripped returns true if the content was an image and has been stripped, only valid after EOF
+func (r *Reader) Stripped() bool {
+	return r.stripped
+}
+
+// Strip copies src to dst removing the EXIF, XMP and IPTC metadata of JPEG, PNG and WebP images
+// Other files are copied as is and stripped is false
+func Strip(dst io.Writer, src io.Reader) (stripped bool, err error) {
+	r := bufio.NewReaderSize(src, 64*1024)
+
+	// Short files are not images, Peek then returns io.EOF
+	head, err := r.Peek(12)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, jpegMagic):
+		return true, stripJPEG(dst, r)
+	case bytes.HasPrefix(head, pngMagic):
+		return true, stripPNG(dst, r)
+	case len(head) == 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return true, stripWebP(dst, r)
+	}
+
+	_, err = io.Copy(dst, r)
+	return false, err
+}
+
+// copyN copies exactly n bytes, a short source is an invalid image
+func copyN(format string, dst io.Writer, src io.Reader, n int64) error {
+	_, err := io.CopyN(dst, src, n)
+	if err == io.EOF {
+		return invalidImage(format, "unexpected end of file")
+	}
+	return err
+}
+
+// readFull reads exactly len(buf) bytes, a short source is an invalid image
+func readFull(format string, src io.Reader, buf []byte) error {
+	_, err := io.ReadFull(src, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return invalidImage(format, "unexpected end of file")
+	}
+	return err
+}
+
+// drain discards what follows the end of the image as it could hide anything
+func drain(src io.Reader) error {
+	_, err := io.Copy(ioutil.Discard, src)
+	return err
+}
+
+// stripJPEG removes the APP1 and APP13 segments of a JPEG image
+func stripJPEG(dst io.Writer, r *bufio.Reader) (err error) {
+	// Start of image
+	err = copyN("jpeg", dst, r, 2)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	for {
+		err = readFull("jpeg", r, header[:2])
+		if err != nil {
+			return err
+		}
+		if header[0] != 0xFF {
+			return invalidImage("jpeg", "invalid marker")
+		}
+
+		// Skip fill bytes
+		for header[1] == 0xFF {
+			header[1], err = r.ReadByte()
+			if err == io.EOF {
+				return invalidImage("jpeg", "unexpected end of file")
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		marker := header[1]
+		switch {
+		case marker == markerEOI:
+			_, err = dst.Write(header[:2])
+			if err != nil {
+				return err
+			}
+			return drain(r)
+		case marker == markerSOS:
+			_, err = dst.Write(header[:2])
+			if err != nil {
+				return err
+			}
+			return copyScan(dst, r)
+		case marker == markerTEM || (marker >= markerRST0 && marker <= markerRST7):
+			// Standalone markers have no length
+			_, err = dst.Write(header[:2])
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = readFull("jpeg", r, header[2:4])
+		if err != nil {
+			return err
+		}
+		length := int64(binary.BigEndian.Uint16(header[2:4]))
+		if length < 2 {
+			return invalidImage("jpeg", "invalid segment length")
+		}
+
+		if marker == markerAPP1 || marker == markerAPPD {
+			err = copyN("jpeg", ioutil.Discard, r, length-2)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, err = dst.Write(header)
+		if err != nil {
+			return err
+		}
+		err = copyN("jpeg", dst, r, length-2)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// copyScan copies the entropy coded data and the following segments up to the end of image marker
+// 0xFF bytes of the entropy coded data are always followed by 0x00 so 0xFF 0xD9 can only be the end of image
+func copyScan(dst io.Writer, r *bufio.Reader) error {
+	for {
+		chunk, err := r.ReadSlice(0xFF)
+		if len(chunk) > 0 {
+			if _, errWrite := dst.Write(chunk); errWrite != nil {
+				return errWrite
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			// Tolerate truncated images as nothing can be hidden after the end of the file
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		next, err := r.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Fill bytes are handled by the next iteration
+		if next == 0xFF {
+			_ = r.UnreadByte()
+			continue
+		}
+
+		_, err = dst.Write([]byte{next})
+		if err != nil {
+			return err
+		}
+
+		if next == markerEOI {
+			return drain(r)
+		}
+	}
+}
+
+// stripPNG removes the metadata chunks of a PNG image
+func stripPNG(dst io.Writer, r *bufio.Reader) (err error) {
+	err = copyN("png", dst, r, int64(len(pngMagic)))
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 8)
+	for {
+		err = readFull("png", r, header)
+		if err != nil {
+			return err
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		if length > 0x7FFFFFFF {
+			return invalidImage("png", "invalid chunk length")
+		}
+		chunkType := string(header[4:8])
+
+		// Chunk data is followed by a 4 bytes CRC
+		if pngMetadataChunks[chunkType] {
+			err = copyN("png", ioutil.Discard, r, length+4)
+		} else {
+			_, err = dst.Write(header)
+			if err != nil {
+				return err
+			}
+			err = copyN("png", dst, r, length+4)
+		}
+		if err != nil {
+			return err
+		}
+
+		if chunkType == "IEND" {
+			return drain(r)
+		}
+	}
+}
+
+// stripWebP blanks the EXIF and XMP chunks of a WebP image
+// The RIFF header holds the size of the file before the metadata chunks, so instead of being removed the
+// metadata chunks are replaced by zero filled JUNK chunks of the same size that decoders ignore.
+func stripWebP(dst io.Writer, r *bufio.Reader) (err error) {
+	header := make([]byte, 12)
+	err = readFull("webp", r, header)
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(header)
+	if err != nil {
+		return err
+	}
+
+	// The RIFF size includes the "WEBP" fourcc
+	remaining := int64(binary.LittleEndian.Uint32(header[4:8])) - 4
+	if remaining < 0 {
+		return invalidImage("webp", "invalid RIFF size")
+	}
+
+	chunk := make([]byte, 8)
+	for remaining > 0 {
+		if remaining < 8 {
+			return invalidImage("webp", "invalid chunk header")
+		}
+		err = readFull("webp", r, chunk)
+		if err != nil {
+			return err
+		}
+
+		fourcc := string(chunk[:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		padded := size + size&1
+		if 8+padded > remaining {
+			return invalidImage("webp", "invalid chunk size")
+		}
+		remaining -= 8 + padded
+
+		switch fourcc {
+		case "VP8X":
+			if size < 1 || size > 1024 {
+				return invalidImage("webp", "invalid VP8X chunk size")
+			}
+			payload := make([]byte, padded)
+			err = readFull("webp", r, payload)
+			if err != nil {
+				return err
+			}
+			payload[0] &^= webpFlagEXIF | webpFlagXMP
+
+			_, err = dst.Write(chunk)
+			if err != nil {
+				return err
+			}
+			_, err = dst.Write(payload)
+		case "EXIF", "XMP ":
+			copy(chunk[:4], "JUNK")
+			_, err = dst.Write(chunk)
+			if err != nil {
+				return err
+			}
+			err = copyN("webp", ioutil.Discard, r, padded)
+			if err != nil {
+				return err
+			}
+			_, err = io.CopyN(dst, zeroReader{}, padded)
+		default:
+			_, err = dst.Write(chunk)
+			if err != nil {
+				return err
+			}
+			err = copyN("webp", dst, r, padded)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return drain(r)
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
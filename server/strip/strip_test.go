@@ -0,0 +1,174 @@
+package strip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "GPS 48.8584N 2.2945E serial 1234"
+
+func newTestJPEG(t *testing.T) []byte {
+	buf := bytes.NewBuffer(nil)
+	err := jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 64, 64)), nil)
+	require.NoError(t, err, "unable to encode jpeg")
+
+	// Insert EXIF ( APP1 ) and IPTC ( APP13 ) segments right after the start of image
+	segments := bytes.NewBuffer(nil)
+	for _, marker := range []byte{markerAPP1, markerAPPD} {
+		payload := []byte("Exif\x00\x00" + secret)
+		segments.Write([]byte{0xFF, marker})
+		_ = binary.Write(segments, binary.BigEndian, uint16(len(payload)+2))
+		segments.Write(payload)
+	}
+
+	content := buf.Bytes()
+	return append(append(append([]byte{}, content[:2]...), segments.Bytes()...), content[2:]...)
+}
+
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := bytes.NewBuffer(nil)
+	_ = binary.Write(chunk, binary.BigEndian, uint32(len(data)))
+	chunk.WriteString(chunkType)
+	chunk.Write(data)
+	_ = binary.Write(chunk, binary.BigEndian, crc32.ChecksumIEEE(append([]byte(chunkType), data...)))
+	return chunk.Bytes()
+}
+
+func newTestPNG(t *testing.T) []byte {
+	buf := bytes.NewBuffer(nil)
+	err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 64, 64)))
+	require.NoError(t, err, "unable to encode png")
+
+	// Insert metadata chunks right before IEND
+	content := buf.Bytes()
+	iend := len(content) - 12
+	metadata := append(pngChunk("tEXt", []byte("Comment\x00"+secret)), pngChunk("eXIf", []byte(secret))...)
+	return append(append(append([]byte{}, content[:iend]...), metadata...), content[iend:]...)
+}
+
+func webpChunk(fourcc string, data []byte) []byte {
+	chunk := bytes.NewBuffer(nil)
+	chunk.WriteString(fourcc)
+	_ = binary.Write(chunk, binary.LittleEndian, uint32(len(data)))
+	chunk.Write(data)
+	if len(data)%2 == 1 {
+		chunk.WriteByte(0)
+	}
+	return chunk.Bytes()
+}
+
+func newTestWebP() []byte {
+	chunks := bytes.NewBuffer(nil)
+	chunks.Write(webpChunk("VP8X", []byte{webpFlagEXIF | webpFlagXMP | 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 0}))
+	chunks.Write(webpChunk("VP8L", []byte("fake image data")))
+	chunks.Write(webpChunk("EXIF", []byte(secret+"!")))
+	chunks.Write(webpChunk("XMP ", []byte(secret)))
+
+	riff := bytes.NewBuffer(nil)
+	riff.WriteString("RIFF")
+	_ = binary.Write(riff, binary.LittleEndian, uint32(chunks.Len()+4))
+	riff.WriteString("WEBP")
+	riff.Write(chunks.Bytes())
+	return riff.Bytes()
+}
+
+func strip(t *testing.T, content []byte) (stripped bool, output []byte) {
+	buf := bytes.NewBuffer(nil)
+	stripped, err := Strip(buf, bytes.NewReader(content))
+	require.NoError(t, err, "unable to strip image")
+	return stripped, buf.Bytes()
+}
+
+func TestStripJPEG(t *testing.T) {
+	content := newTestJPEG(t)
+	require.Contains(t, string(content), secret, "invalid test image")
+
+	stripped, output := strip(t, append(content, []byte("trailing "+secret)...))
+	require.True(t, stripped, "image not stripped")
+	require.NotContains(t, string(output), secret, "metadata not stripped")
+
+	expected := bytes.NewBuffer(nil)
+	err := jpeg.Encode(expected, image.NewGray(image.Rect(0, 0, 64, 64)), nil)
+	require.NoError(t, err, "unable to encode jpeg")
+	require.Equal(t, expected.Bytes(), output, "invalid stripped image")
+
+	_, err = jpeg.Decode(bytes.NewReader(output))
+	require.NoError(t, err, "unable to decode stripped image")
+}
+
+func TestStripPNG(t *testing.T) {
+	content := newTestPNG(t)
+	_, err := png.Decode(bytes.NewReader(content))
+	require.NoError(t, err, "invalid test image")
+
+	stripped, output := strip(t, content)
+	require.True(t, stripped, "image not stripped")
+	require.NotContains(t, string(output), secret, "metadata not stripped")
+
+	_, err = png.Decode(bytes.NewReader(output))
+	require.NoError(t, err, "unable to decode stripped image")
+}
+
+func TestStripWebP(t *testing.T) {
+	content := newTestWebP()
+
+	stripped, output := strip(t, content)
+	require.True(t, stripped, "image not stripped")
+	require.NotContains(t, string(output), secret, "metadata not stripped")
+	require.Len(t, output, len(content), "invalid stripped image size")
+
+	require.Equal(t, byte(0x10), output[20], "invalid VP8X flags")
+	require.Equal(t, 2, bytes.Count(output, []byte("JUNK")), "invalid JUNK chunk count")
+	require.Contains(t, string(output), "fake image data", "missing image data")
+}
+
+func TestStripNotAnImage(t *testing.T) {
+	for _, content := range []string{"", "foo", "not an image but some text " + secret} {
+		stripped, output := strip(t, []byte(content))
+		require.False(t, stripped, "unexpected stripping")
+		require.Equal(t, content, string(output), "invalid output")
+	}
+}
+
+func TestStripInvalidImage(t *testing.T) {
+	images := map[string][]byte{
+		"jpeg": newTestJPEG(t)[:10],
+		"png":  newTestPNG(t)[:20],
+		"webp": newTestWebP()[:30],
+	}
+
+	for format, content := range images {
+		_, err := Strip(ioutil.Discard, bytes.NewReader(content))
+		require.Error(t, err, "missing error for %s", format)
+		require.True(t, IsInvalidImage(err), "invalid error type for %s : %s", format, err)
+	}
+
+	_, err := Strip(ioutil.Discard, bytes.NewReader([]byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x01}))
+	require.Error(t, err, "missing error")
+	require.Contains(t, err.Error(), "invalid segment length", "invalid error")
+}
+
+func TestReader(t *testing.T) {
+	reader := NewReader(bytes.NewReader(newTestJPEG(t)))
+	output, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read")
+	require.True(t, reader.Stripped(), "image not stripped")
+	require.NotContains(t, string(output), secret, "metadata not stripped")
+
+	reader = NewReader(bytes.NewReader(newTestJPEG(t)[:10]))
+	_, err = ioutil.ReadAll(reader)
+	require.True(t, IsInvalidImage(err), "invalid error")
+
+	// Closing the reader releases the stripping goroutine
+	reader = NewReader(bytes.NewReader(make([]byte, 1<<20)))
+	err = reader.Close()
+	require.NoError(t, err, "unable to close reader")
+}
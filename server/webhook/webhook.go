@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/root-gg/logger"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/fetch"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+// maxBackoff is the maximum delay between two attempts of a webhook delivery
+const maxBackoff = time.Hour
+
+// Dispatcher queue upload lifecycle events in the metadata outbox and deliver them to the webhooks
+type Dispatcher struct {
+	config          *common.Configuration
+	metadataBackend *metadata.Backend
+	client          *http.Client
+	serverClient    *http.Client
+	log             *logger.Logger
+}
+
+// NewDispatcher create a new webhook dispatcher
+func NewDispatcher(config *common.Configuration, metadataBackend *metadata.Backend) (dispatcher *Dispatcher) {
+	dispatcher = &Dispatcher{config: config, metadataBackend: metadataBackend}
+
+	// User and upload webhooks must not reach the internal network of the server,
+	// the server-wide webhooks are set by the administrators in the configuration file
+	dispatcher.client = fetch.NewWebhookClient(config)
+	dispatcher.serverClient = &http.Client{Timeout: time.Duration(config.WebhookTimeout) * time.Second}
+	dispatcher.log = config.NewLogger()
+	return dispatcher
+}
+
+// Notify add a delivery to the outbox for every webhook subscribed to the event
+func (d *Dispatcher) Notify(eventType string, upload *common.Upload, file *common.File) (err error) {
+	webhooks, err := d.metadataBackend.GetWebhooksForUpload(upload)
+	if err != nil {
+		return fmt.Errorf("unable to get webhooks : %s", err)
+	}
+	webhooks = append(append([]*common.Webhook{}, d.config.Webhooks...), webhooks...)
+
+	var payload []byte
+	for _, webhook := range webhooks {
+		if !webhook.Match(eventType) {
+			continue
+		}
+
+		// The same payload is sent to every webhook
+		if payload == nil {
+			payload, err = json.Marshal(common.NewEvent(eventType, upload, file))
+			if err != nil {
+				return fmt.Errorf("unable to serialize event : %s", err)
+			}
+		}
+
+		delivery := &common.WebhookDelivery{
+			ID:            common.GenerateRandomID(16),
+			WebhookID:     webhook.ID,
+			URL:           webhook.URL,
+			Secret:        webhook.Secret,
+			Event:         eventType,
+			Payload:       string(payload),
+			Status:        common.WebhookDeliveryPending,
+			NextAttemptAt: time.Now(),
+		}
+
+		err = d.metadataBackend.CreateWebhookDelivery(delivery)
+		if err != nil {
+			return fmt.Errorf("unable to queue webhook delivery : %s", err)
+		}
+	}
+
+	return nil
+}
+
+// Deliver send the pending webhook deliveries that are due
+// limit is the maximum number of deliveries to send, 0 means no limit
+func (d *Dispatcher) Deliver(limit int) (delivered int, err error) {
+	deliveries, err := d.metadataBackend.GetDueWebhookDeliveries(time.Now(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get webhook deliveries : %s", err)
+	}
+
+	for _, delivery := range deliveries {
+		// Another Plik instance might be sending the same delivery
+		claimed, err := d.metadataBackend.ClaimWebhookDelivery(delivery, time.Now().Add(2*d.client.Timeout))
+		if err != nil {
+			return delivered, fmt.Errorf("unable to claim webhook delivery : %s", err)
+		}
+		if !claimed {
+			continue
+		}
+
+		err = d.send(delivery)
+		if err == nil {
+			err = d.metadataBackend.DeleteWebhookDelivery(delivery.ID)
+			if err != nil {
+				return delivered, fmt.Errorf("unable to delete webhook delivery : %s", err)
+			}
+			delivered++
+			continue
+		}
+
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= d.config.WebhookMaxAttempts {
+			d.log.Warningf("giving up %s webhook delivery %s to %s after %d attempts : %s", delivery.Event, delivery.ID, delivery.URL, delivery.Attempts, err)
+			delivery.Status = common.WebhookDeliveryFailed
+		} else {
+			d.log.Debugf("unable to deliver %s webhook %s to %s : %s", delivery.Event, delivery.ID, delivery.URL, err)
+			delivery.NextAttemptAt = time.Now().Add(backoff(delivery.Attempts))
+		}
+
+		err = d.metadataBackend.UpdateWebhookDelivery(delivery)
+		if err != nil {
+			return delivered, fmt.Errorf("unable to update webhook delivery : %s", err)
+		}
+	}
+
+	return delivered, nil
+}
+
+// send POST the delivery payload to the webhook URL
+func (d *Dispatcher) send(delivery *common.WebhookDelivery) (err error) {
+	payload := []byte(delivery.Payload)
+
+	req, err := http.NewRequest("POST", delivery.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "plik/"+common.GetBuildInfo().Version)
+	req.Header.Set("X-Plik-Event", delivery.Event)
+	req.Header.Set("X-Plik-Delivery", delivery.ID)
+	req.Header.Set("X-Plik-Signature", common.SignWebhookPayload(delivery.Secret, payload))
+
+	client := d.client
+	if d.isServerWebhook(delivery.URL) {
+		client = d.serverClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1048576))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// isServerWebhook return true if the URL is the URL of a server-wide webhook
+func (d *Dispatcher) isServerWebhook(URL string) bool {
+	for _, webhook := range d.config.Webhooks {
+		if webhook.URL == URL {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff return the delay before the next attempt of a delivery ( 10s, 20s, 40s, ... up to 1 hour )
+func backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 10 {
+		return maxBackoff
+	}
+
+	delay := time.Duration(1<<uint(attempts-1)) * 10 * time.Second
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+func newTestDispatcher(t *testing.T, allowedNetworks ...string) (dispatcher *Dispatcher) {
+	metadataBackendConfig := &metadata.Config{Driver: "sqlite3", ConnectionString: "/tmp/plik.test.db", EraseFirst: true}
+	metadataBackend, err := metadata.NewBackend(metadataBackendConfig)
+	require.NoError(t, err, "unable to create metadata backend")
+
+	config := common.NewConfiguration()
+	config.WebhookTimeout = 1
+	config.WebhookMaxAttempts = 2
+	config.FetchAllowedNetworks = allowedNetworks
+	require.NoError(t, config.Initialize(), "unable to initialize config")
+	return NewDispatcher(config, metadataBackend)
+}
+
+type testReceiver struct {
+	server   *httptest.Server
+	status   int
+	requests []*http.Request
+	payloads [][]byte
+	mu       sync.Mutex
+}
+
+func newTestReceiver() (receiver *testReceiver) {
+	receiver = &testReceiver{status: http.StatusOK}
+	receiver.server = httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+
+		payload, _ := ioutil.ReadAll(req.Body)
+		receiver.requests = append(receiver.requests, req)
+		receiver.payloads = append(receiver.payloads, payload)
+		resp.WriteHeader(receiver.status)
+	}))
+	return receiver
+}
+
+func TestNotifyAndDeliver(t *testing.T) {
+	d := newTestDispatcher(t, "127.0.0.1")
+	receiver := newTestReceiver()
+	defer receiver.server.Close()
+
+	d.config.Webhooks = []*common.Webhook{{URL: receiver.server.URL, Secret: "secret", Events: common.EventFileUploaded}}
+
+	userWebhook := &common.Webhook{User: "user", URL: receiver.server.URL, Events: common.EventUploadCreated}
+	require.NoError(t, userWebhook.PrepareInsert(d.config.GetFetchAllowedNetworks()))
+	require.NoError(t, d.metadataBackend.CreateWebhook(userWebhook))
+
+	upload := &common.Upload{ID: "upload", User: "user", UploadToken: "token"}
+	file := upload.NewFile()
+
+	err := d.Notify(common.EventUploadCreated, upload, nil)
+	require.NoError(t, err, "unable to notify event")
+	err = d.Notify(common.EventFileUploaded, upload, file)
+	require.NoError(t, err, "unable to notify event")
+	err = d.Notify(common.EventFileDownloaded, upload, file)
+	require.NoError(t, err, "unable to notify event")
+
+	delivered, err := d.Deliver(0)
+	require.NoError(t, err, "unable to deliver webhooks")
+	require.Equal(t, 2, delivered, "invalid delivered count")
+	require.Len(t, receiver.requests, 2, "invalid request count")
+
+	for i, req := range receiver.requests {
+		event := &common.Event{}
+		err = json.Unmarshal(receiver.payloads[i], event)
+		require.NoError(t, err, "invalid payload")
+		require.Equal(t, req.Header.Get("X-Plik-Event"), event.Type)
+		require.Equal(t, "upload", event.Upload.ID)
+		require.Empty(t, event.Upload.UploadToken, "upload token leaked")
+
+		secret := userWebhook.Secret
+		if event.Type == common.EventFileUploaded {
+			secret = "secret"
+			require.Equal(t, file.ID, event.File.ID)
+		}
+		require.Equal(t, common.SignWebhookPayload(secret, receiver.payloads[i]), req.Header.Get("X-Plik-Signature"))
+	}
+
+	// The outbox is empty
+	deliveries, err := d.metadataBackend.GetDueWebhookDeliveries(time.Now().Add(time.Hour), 0)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 0, "invalid pending delivery count")
+}
+
+func TestDeliverRetry(t *testing.T) {
+	d := newTestDispatcher(t)
+	receiver := newTestReceiver()
+	defer receiver.server.Close()
+	receiver.status = http.StatusInternalServerError
+
+	d.config.Webhooks = []*common.Webhook{{URL: receiver.server.URL}}
+
+	err := d.Notify(common.EventUploadRemoved, &common.Upload{ID: "upload"}, nil)
+	require.NoError(t, err, "unable to notify event")
+
+	delivered, err := d.Deliver(0)
+	require.NoError(t, err, "unable to deliver webhooks")
+	require.Equal(t, 0, delivered, "invalid delivered count")
+	require.Len(t, receiver.requests, 1, "invalid request count")
+
+	// The next attempt is delayed
+	delivered, err = d.Deliver(0)
+	require.NoError(t, err, "unable to deliver webhooks")
+	require.Len(t, receiver.requests, 1, "invalid request count")
+
+	deliveries, err := d.metadataBackend.GetDueWebhookDeliveries(time.Now().Add(time.Hour), 0)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1, "invalid pending delivery count")
+	require.Equal(t, 1, deliveries[0].Attempts)
+	require.Equal(t, "unexpected response status 500", deliveries[0].LastError)
+
+	// Give up after WebhookMaxAttempts
+	deliveries[0].NextAttemptAt = time.Now().Add(-time.Second)
+	require.NoError(t, d.metadataBackend.UpdateWebhookDelivery(deliveries[0]))
+
+	_, err = d.Deliver(0)
+	require.NoError(t, err, "unable to deliver webhooks")
+	require.Len(t, receiver.requests, 2, "invalid request count")
+
+	delivery, err := d.metadataBackend.GetWebhookDelivery(deliveries[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, common.WebhookDeliveryFailed, delivery.Status)
+}
+
+func TestDeliverDeniedAddress(t *testing.T) {
+	d := newTestDispatcher(t)
+	receiver := newTestReceiver()
+	defer receiver.server.Close()
+
+	// Literal addresses of the denied networks are rejected
+	userWebhook := &common.Webhook{User: "user", URL: receiver.server.URL}
+	require.Error(t, userWebhook.PrepareInsert(d.config.GetFetchAllowedNetworks()), "denied address expected")
+
+	// Host names are checked once resolved
+	userWebhook.URL = strings.Replace(receiver.server.URL, "127.0.0.1", "localhost", 1)
+	require.NoError(t, userWebhook.PrepareInsert(d.config.GetFetchAllowedNetworks()))
+	require.NoError(t, d.metadataBackend.CreateWebhook(userWebhook))
+
+	err := d.Notify(common.EventUploadCreated, &common.Upload{ID: "upload", User: "user"}, nil)
+	require.NoError(t, err, "unable to notify event")
+
+	delivered, err := d.Deliver(0)
+	require.NoError(t, err, "unable to deliver webhooks")
+	require.Equal(t, 0, delivered, "invalid delivered count")
+	require.Len(t, receiver.requests, 0, "the user webhook should not reach the loopback address")
+
+	deliveries, err := d.metadataBackend.GetDueWebhookDeliveries(time.Now().Add(time.Hour), 0)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1, "invalid pending delivery count")
+	require.Contains(t, deliveries[0].LastError, "is not allowed")
+
+	// Server-wide webhooks are trusted
+	d.config.Webhooks = []*common.Webhook{{URL: receiver.server.URL}}
+	err = d.Notify(common.EventUploadRemoved, &common.Upload{ID: "upload"}, nil)
+	require.NoError(t, err, "unable to notify event")
+
+	delivered, err = d.Deliver(0)
+	require.NoError(t, err, "unable to deliver webhooks")
+	require.Equal(t, 1, delivered, "invalid delivered count")
+	require.Len(t, receiver.requests, 1, "invalid request count")
+}
+
+func TestBackoff(t *testing.T) {
+	require.Equal(t, 10*time.Second, backoff(0))
+	require.Equal(t, 10*time.Second, backoff(1))
+	require.Equal(t, 20*time.Second, backoff(2))
+	require.Equal(t, 40*time.Second, backoff(3))
+	require.Equal(t, time.Hour, backoff(10))
+	require.Equal(t, time.Hour, backoff(100))
+}
@@ -10,14 +10,25 @@ plik.controller('HomeCtrl', ['$scope', '$api', '$config', '$dialog', '$location'
             $scope.refreshUser();
         };
 
+        $scope.displayShared = function () {
+            $scope.display = 'shared';
+            $scope.getSharedUploads();
+        };
+
         $scope.displayTokens = function () {
             $scope.display = 'tokens';
             $scope.refreshUser();
         };
 
+        $scope.displayRequests = function () {
+            $scope.display = 'requests';
+            $scope.getUploadRequests();
+        };
+
         // Get server config
         $config.config
             .then(function (config) {
+                $scope.config = config;
                 // Check if authentication is enabled server side
                 if (!config.authentication) {
                     $location.path('/');
@@ -49,6 +60,19 @@ plik.controller('HomeCtrl', ['$scope', '$api', '$config', '$dialog', '$location'
             loadUser($config.refreshUser());
         };
 
+        // Update user email notification preferences
+        $scope.updateNotifications = function () {
+            $api.updateNotifications({
+                notifyDownloads: $scope.user.notifyDownloads,
+                notifyExpiration: $scope.user.notifyExpiration,
+                notifyUploadRequests: $scope.user.notifyUploadRequests
+            })
+                .then(null, function (error) {
+                    $dialog.alert(error);
+                    $scope.refreshUser();
+                });
+        };
+
         // page size
         $scope.limit = 50;
 
@@ -70,6 +94,23 @@ plik.controller('HomeCtrl', ['$scope', '$api', '$config', '$dialog', '$location'
                 });
         };
 
+        // Get the list of uploads shared with the user
+        $scope.getSharedUploads = function (more) {
+            if (!more) {
+                $scope.shared = [];
+                $scope.shared_cursor = undefined;
+            }
+
+            $api.getSharedUploads($scope.limit, $scope.shared_cursor)
+                .then(function (result) {
+                    $scope.shared = $scope.shared.concat(result.results);
+                    $scope.shared_cursor = result.after;
+                })
+                .then(null, function (error) {
+                    $dialog.alert(error);
+                });
+        };
+
         // Get user upload list
         $scope.getTokens = function (more) {
             if (!more) {
@@ -88,6 +129,59 @@ plik.controller('HomeCtrl', ['$scope', '$api', '$config', '$dialog', '$location'
                 });
         };
 
+        // Get user upload requests
+        $scope.getUploadRequests = function (more) {
+            if (!more) {
+                $scope.requests = [];
+                $scope.requests_cursor = undefined;
+            }
+
+            $api.getUploadRequests($scope.limit, $scope.requests_cursor)
+                .then(function (result) {
+                    $scope.requests = $scope.requests.concat(result.results);
+                    $scope.requests_cursor = result.after;
+                })
+                .then(null, function (error) {
+                    $dialog.alert(error);
+                });
+        };
+
+        // Create a new upload request
+        $scope.createUploadRequest = function (message) {
+            $api.createUploadRequest({message: message})
+                .then(function () {
+                    $scope.getUploadRequests();
+                })
+                .then(null, function (error) {
+                    $dialog.alert(error);
+                });
+        };
+
+        // Delete an upload request
+        $scope.removeUploadRequest = function (request) {
+            $dialog.alert({
+                title: "Really ?",
+                message: "Deleting an upload request will not delete the uploads created through it.",
+                confirm: true
+            }).result.then(
+                function () {
+                    $api.removeUploadRequest(request)
+                        .then(function () {
+                            $scope.getUploadRequests();
+                        })
+                        .then(null, function (error) {
+                            $dialog.alert(error);
+                        });
+                }, function () {
+                    // Avoid "Possibly unhandled rejection"
+                });
+        };
+
+        // Get the absolute URL of an upload request
+        $scope.getUploadRequestUrl = function (request) {
+            return window.location.origin + request.url;
+        };
+
         // Get user statistics
         $scope.getUserStats = function () {
             $api.getUserStats()
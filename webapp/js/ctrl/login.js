@@ -22,11 +22,21 @@ plik.controller('LoginCtrl', ['$scope', '$api', '$config', '$location', '$dialog
                 }
             });
 
+        // Redirect to the upload that required authentication if any, or to the user home
+        var redirect = function () {
+            var id = $location.search().id;
+            if (id) {
+                $location.search({id: id});
+                $location.path('/');
+            } else {
+                $location.search({});
+                $location.path('/home');
+            }
+        };
+
         // Get user from session
         $config.getUser()
-            .then(function () {
-                $location.path('/home');
-            })
+            .then(redirect)
             .then(null, function (error) {
                 if (error.status !== 401 && error.status !== 403) {
                     $dialog.alert(error);
@@ -62,7 +72,7 @@ plik.controller('LoginCtrl', ['$scope', '$api', '$config', '$location', '$dialog
             $api.login("local", $scope.username, $scope.password)
                 .then(function () {
                     $config.refreshUser();
-                    $location.path('/home');
+                    redirect();
                 })
                 .then(null, function (error) {
                     $dialog.alert(error);
@@ -14,7 +14,9 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
             .then(function (config) {
                 $scope.config = config;
                 $scope.setDefaultTTL();
-                if ( config.noAnonymousUploads ) {
+                if (config.forceStripMetadata) $scope.upload.stripMetadata = true;
+                // Upload requests let anonymous users upload files to the requesting user
+                if ( config.noAnonymousUploads && !$location.search().request ) {
                     // Redirect to login page if user is not authenticated
                     $config.getUser()
                         .then(null, function (error) {
@@ -48,14 +50,38 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
             var err = $location.search().err;
             if (!_.isUndefined(err)) {
                 var code = $location.search().errcode;
-                $dialog.alert({status: code, message: err}).result.then($scope.mainpage);
+                // Restricted upload file download from an anonymous browser
+                var match = /\/(?:file|stream|archive)\/([a-zA-Z0-9]+)\//.exec($location.search().uri || '');
+                if (code === '403' && match) {
+                    $scope.loginToAccess(match[1], {status: code, message: err});
+                } else {
+                    $dialog.alert({status: code, message: err}).result.then($scope.mainpage);
+                }
                 return;
+            } else if ($location.search().request) {
+                // Upload files to a registered user through an upload request
+                $scope.loadRequest($location.search().request);
             } else {
                 // Load current upload id
                 $scope.load($location.search().id);
             }
         };
 
+        // Load upload request from id
+        $scope.loadRequest = function (id) {
+            $api.getUploadRequest(id, $scope.basicAuth)
+                .then(function (request) {
+                    $scope.request = request;
+                })
+                .then(null, function (error) {
+                    if (error.status === 401) {
+                        $scope.getRequestPassword(id);
+                    } else {
+                        $dialog.alert(error).result.then($scope.mainpage);
+                    }
+                });
+        };
+
         // Load upload from id
         $scope.load = function (id) {
             if (!id) return;
@@ -75,10 +101,51 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
                     }
                 })
                 .then(null, function (error) {
+                    if (error.status === 401) {
+                        // Password protected upload
+                        $scope.uploadLogin(id);
+                    } else if (error.status === 403) {
+                        // Upload restricted by an access control list
+                        $scope.loginToAccess(id, error);
+                    } else {
+                        $dialog.alert(error).result.then($scope.mainpage);
+                    }
+                });
+        };
+
+        // Redirect anonymous users to the login page to access a restricted upload
+        $scope.loginToAccess = function (id, error) {
+            $config.getUser()
+                .then(function () {
                     $dialog.alert(error).result.then($scope.mainpage);
+                })
+                .then(null, function () {
+                    $location.search({id: id});
+                    $location.path('/login');
                 });
         };
 
+        // Ask credentials of a password protected upload to get an upload session cookie
+        $scope.uploadLogin = function (id) {
+            $dialog.openDialog({
+                backdrop: true,
+                backdropClick: true,
+                templateUrl: 'partials/password.html',
+                controller: 'PasswordController'
+            }).result.then(
+                function (result) {
+                    $api.uploadLogin(id, result.login, result.password)
+                        .then(function () {
+                            $scope.load(id);
+                        })
+                        .then(null, function (error) {
+                            $dialog.alert(error).result.then(function () {
+                                $scope.uploadLogin(id);
+                            });
+                        });
+                }, $scope.mainpage);
+        };
+
         // Reference is needed to match files ids
         var reference = -1;
         var nextRef = function () {
@@ -106,8 +173,15 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
                     return;
                 }
 
+                // Directory of the file when a folder is dropped or selected
+                var relativePath = file.webkitRelativePath || file.path || '';
+                var sep = relativePath.lastIndexOf('/');
+                file.filePath = sep > 0 ? relativePath.substr(0, sep) : '';
+
                 // Already added file names
-                var names = _.pluck($scope.files, 'name');
+                var names = _.map(_.where($scope.files, {filePath: file.filePath}), function (f) {
+                    return f.name;
+                });
 
                 // iPhone/iPad/iPod fix
                 // Apple mobile devices does not populate file name
@@ -120,7 +194,7 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
                     file.reference = nextRef();
 
                     // Extract file name and extension and add increment
-                    var sep = file.name.lastIndexOf('.');
+                    sep = file.name.lastIndexOf('.');
                     var name = sep ? file.name.substr(0, sep) : file.name;
                     var ext = file.name.substr(sep + 1);
                     name = name + '.' + file.reference + '.' + ext;
@@ -188,6 +262,22 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
             if ($scope.upload.id) {
                 // When adding file to an existing upload
                 $scope.uploadFiles();
+            } else if ($scope.request) {
+                // Upload options are set by the upload request
+                $api.createRequestUpload($scope.request.id, $scope.basicAuth)
+                    .then(function (upload) {
+                        $scope.upload = upload;
+                        // Files are added to the upload one by one
+                        _.each($scope.files, function (file) {
+                            file.metadata = {fileName: file.fileName, status: "toUpload"};
+                        });
+                        $location.search('request', null);
+                        $location.search('id', $scope.upload.id);
+                        $scope.uploadFiles();
+                    })
+                    .then(null, function (error) {
+                        $dialog.alert(error);
+                    });
             } else {
                 // Get TTL value
                 if (!$scope.checkTTL()) return;
@@ -223,6 +313,7 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
                         fileName: file.fileName,
                         fileType: file.fileType,
                         fileSize: file.fileSize,
+                        path: file.filePath,
                         reference: file.reference
                     });
                 });
@@ -279,6 +370,28 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
             });
         };
 
+        // Report the upload to the administrators
+        $scope.reportUpload = function () {
+            $dialog.openDialog({
+                backdrop: true,
+                backdropClick: true,
+                templateUrl: 'partials/report.html',
+                controller: 'ReportController'
+            }).result.then(
+                function (report) {
+                    report.uploadId = $scope.upload.id;
+                    $api.createReport(report)
+                        .then(function () {
+                            $dialog.alert({status: 100, message: "Thank you, the administrators will review this upload"});
+                        })
+                        .then(null, function (error) {
+                            $dialog.alert(error);
+                        });
+                }, function () {
+                    // Avoid "Possibly unhandled rejection"
+                });
+        };
+
         // Remove the whole upload
         // Remove a file from the servers
         $scope.removeUpload = function () {
@@ -302,6 +415,20 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
                 });
         };
 
+        // Extend the upload expiration date by the default TTL from now
+        $scope.extendUpload = function () {
+            if (!$scope.upload.admin) return;
+
+            $api.updateUpload($scope.upload, {ttl: $scope.config.defaultTTL})
+                .then(function (upload) {
+                    $scope.upload.ttl = upload.ttl;
+                    $scope.upload.expireAt = upload.expireAt;
+                })
+                .then(null, function (error) {
+                    $dialog.alert(error);
+                });
+        };
+
         // Remove a file from the servers
         $scope.deleteFile = function (file) {
             if (!$scope.upload.removable && !$scope.upload.admin) return;
@@ -345,6 +472,7 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
             if (file.metadata.status === 'toUpload') return true;
             else if (file.metadata.status === 'uploading') return true;
             else if (file.metadata.status === 'uploaded') return true;
+            else if (file.metadata.status === 'scanning') return true;
             else if ($scope.upload.stream && file.metadata.status === 'missing') return true;
             return false;
         };
@@ -404,10 +532,149 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
             return getFileUrl($scope.getMode(), $scope.upload.id, file.metadata.id, file.metadata.fileName, dl);
         };
 
-        // Return zip archive download URL
-        $scope.getZipArchiveUrl = function (dl) {
+        // Return the download URL of a previous version of the file
+        $scope.getFileVersionUrl = function (file, version) {
+            return $scope.getFileUrl(file) + '?version=' + version.version;
+        };
+
+        // Return the syntax highlighted view URL of a paste
+        $scope.getPasteUrl = function (file) {
+            return getFileUrl('paste', $scope.upload.id, file.metadata.id, file.metadata.fileName);
+        };
+
+        // Can a preview of the file be displayed
+        $scope.hasPreview = function (file, kind) {
+            if (!$scope.config.previews || !file.metadata) return false;
+            if ($scope.upload.oneShot || $scope.upload.stream) return false;
+            if (file.metadata.preview) return file.metadata.preview === kind;
+            // Previews are generated in the background right after the upload
+            var type = file.metadata.fileType || '';
+            if (kind === 'image') return /^image\/(png|jpeg|gif)/.test(type);
+            return type.indexOf('text/') === 0;
+        };
+
+        // Return file preview URL
+        $scope.getPreviewUrl = function (file) {
+            return getFileUrl('file', $scope.upload.id, file.metadata.id, file.metadata.fileName + '/preview');
+        };
+
+        // Extensions of the uploaded archives that can be browsed
+        var browsableArchives = ['.zip', '.tar', '.tar.gz', '.tgz', '.tar.zst'];
+
+        // Is the file an archive whose members can be listed
+        $scope.isBrowsableArchive = function (file) {
+            if (!file.metadata || !file.metadata.fileName) return false;
+            if ($scope.upload.oneShot || $scope.upload.stream) return false;
+            if (file.metadata.archive === 'invalid') return false;
+            return _.some(browsableArchives, function (extension) {
+                return file.metadata.fileName.endsWith(extension);
+            });
+        };
+
+        // Display or hide the members of an uploaded archive
+        $scope.toggleArchiveContents = function (file) {
+            if (file.members) {
+                file.members = null;
+                return;
+            }
+
+            $api.getArchiveContents($scope.upload.id, file.metadata.id, file.metadata.fileName, $scope.upload.uploadToken)
+                .then(function (members) {
+                    file.members = members;
+                })
+                .then(null, function (error) {
+                    $dialog.alert(error);
+                });
+        };
+
+        // Return archive member download URL
+        $scope.getArchiveMemberUrl = function (file, member) {
+            return getFileUrl('file', $scope.upload.id, file.metadata.id, file.metadata.fileName + '/contents/' + member.index);
+        };
+
+        // Available archive formats
+        $scope.archiveFormats = ['zip', 'tar', 'tar.gz', 'tar.zst'];
+        $scope.archive = {format: 'zip'};
+
+        // Return the files selected to be downloaded in an archive
+        $scope.getSelectedFiles = function () {
+            return _.filter($scope.files, function (file) {
+                return file.selected && $scope.isDownloadable(file);
+            });
+        };
+
+        // Return archive download URL
+        // If a folder is given only the files of this folder are archived
+        // If some files are selected only those files are archived
+        $scope.getArchiveUrl = function (dl, folder) {
             if (!$scope.upload.id) return;
-            return getFileUrl("archive", $scope.upload.id, null, "archive.zip", dl);
+            var name = folder ? folder.substr(folder.lastIndexOf('/') + 1) : "archive";
+            var url = getFileUrl("archive", $scope.upload.id, null, name + "." + $scope.archive.format, dl);
+
+            var params = [];
+            if (folder) params.push("path=" + encodeURIComponent(folder));
+            var selected = $scope.getSelectedFiles();
+            if (selected.length) {
+                params.push("files=" + _.map(selected, function (file) {
+                    return file.metadata.id;
+                }).join(','));
+            }
+            if (!params.length) return url;
+            return url + (dl ? "&" : "?") + params.join('&');
+        };
+
+        // Folder currently browsed in the upload directory tree
+        $scope.currentPath = '';
+
+        // Return the directory of a file inside the upload
+        $scope.getFilePath = function (file) {
+            if (file.metadata && file.metadata.path) return file.metadata.path;
+            return file.filePath || '';
+        };
+
+        // Return true if the upload files are organized in folders
+        $scope.hasFolders = function () {
+            return _.some($scope.files, function (file) {
+                return $scope.getFilePath(file) !== '';
+            });
+        };
+
+        // Return true if the file is in the browsed folder
+        $scope.inCurrentFolder = function (file) {
+            return $scope.getFilePath(file) === $scope.currentPath;
+        };
+
+        // Return the sub-folders of the browsed folder
+        $scope.getSubFolders = function () {
+            var prefix = $scope.currentPath ? $scope.currentPath + '/' : '';
+            var folders = [];
+            _.each($scope.files, function (file) {
+                var path = $scope.getFilePath(file);
+                if (!path || path.indexOf(prefix) !== 0 || path === $scope.currentPath) return;
+                var name = path.substr(prefix.length).split('/')[0];
+                if (!_.contains(folders, name)) folders.push(name);
+            });
+            return folders.sort();
+        };
+
+        // Return the breadcrumb of the browsed folder
+        $scope.getBreadcrumb = function () {
+            if (!$scope.currentPath) return [];
+            var path = '';
+            return _.map($scope.currentPath.split('/'), function (name) {
+                path = path ? path + '/' + name : name;
+                return {name: name, path: path};
+            });
+        };
+
+        // Browse a folder of the upload directory tree
+        $scope.openFolder = function (path) {
+            $scope.currentPath = path;
+        };
+
+        // Browse a sub-folder of the current folder
+        $scope.openSubFolder = function (name) {
+            $scope.openFolder($scope.currentPath ? $scope.currentPath + '/' + name : name);
         };
 
         // Return QR Code image url
@@ -477,6 +744,22 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
                 });
         };
 
+        // Upload request basic auth credentials dialog
+        $scope.getRequestPassword = function (id) {
+            $dialog.openDialog({
+                backdrop: true,
+                backdropClick: true,
+                templateUrl: 'partials/password.html',
+                controller: 'PasswordController'
+            }).result.then(
+                function (result) {
+                    $scope.basicAuth = btoa(result.login + ":" + result.password);
+                    $scope.loadRequest(id);
+                }, function () {
+                    // Avoid "Possibly unhandled rejection"
+                });
+        };
+
         $scope.ttlUnits = ["days", "hours", "minutes"];
         $scope.ttlUnit = "days";
         $scope.ttlValue = 30;
@@ -3,10 +3,12 @@ angular.module('api', ['ngFileUpload']).factory('$api', function ($http, $q, Upl
     var api = {base: window.location.origin + window.location.pathname.replace(/\/$/, '')};
 
     // Make the actual HTTP call and return a promise
-    api.call = function (url, method, params, data, uploadToken) {
+    api.call = function (url, method, params, data, uploadToken, basicAuth) {
         var promise = $q.defer();
-        var headers = {};
+        // Avoid the browser basic auth prompt for password protected uploads
+        var headers = {'X-Requested-With': 'XMLHttpRequest'};
         if (uploadToken) headers['X-UploadToken'] = uploadToken;
+        if (basicAuth) headers['Authorization'] = "Basic " + basicAuth;
         if (api.fake_user) headers['X-Plik-Impersonate'] = api.fake_user.id;
         $http({
             url: url,
@@ -32,11 +34,16 @@ angular.module('api', ['ngFileUpload']).factory('$api', function ($http, $q, Upl
         if (uploadToken) headers['X-UploadToken'] = uploadToken;
         if (basicAuth) headers['Authorization'] = "Basic " + basicAuth;
 
+        // The path field must be sent before the file field
+        var data = {};
+        if (file.filePath) data.path = file.filePath;
+        data.file = Upload.rename(file, file.fileName);
+
         Upload
             .upload({
                 url: url,
                 method: 'POST',
-                file: Upload.rename(file, file.fileName),
+                data: data,
                 headers: headers
             })
             .then(function success(resp) {
@@ -56,18 +63,54 @@ angular.module('api', ['ngFileUpload']).factory('$api', function ($http, $q, Upl
         return api.call(url, 'GET', {}, {}, uploadToken);
     };
 
+    // Get the members of an uploaded archive
+    api.getArchiveContents = function (uploadId, fileId, fileName, uploadToken) {
+        var url = api.base + '/file/' + uploadId + '/' + fileId + '/' + encodeURIComponent(fileName) + '/contents';
+        return api.call(url, 'GET', {}, {}, uploadToken);
+    };
+
+    // Get an upload session cookie for a password protected upload
+    api.uploadLogin = function (uploadId, login, password) {
+        var url = api.base + '/upload/' + uploadId + '/login';
+        return api.call(url, 'POST', {}, {login: login, password: password});
+    };
+
     // Create an upload with current settings
     api.createUpload = function (upload) {
         var url = api.base + '/upload';
         return api.call(url, 'POST', {}, upload);
     };
 
+    // Get the public information of an upload request
+    api.getUploadRequest = function (requestId, basicAuth) {
+        var url = api.base + '/request/' + requestId;
+        return api.call(url, 'GET', {}, {}, null, basicAuth);
+    };
+
+    // Create an upload through an upload request
+    api.createRequestUpload = function (requestId, basicAuth) {
+        var url = api.base + '/request/' + requestId + '/upload';
+        return api.call(url, 'POST', {}, {}, null, basicAuth);
+    };
+
     // Remove an upload
     api.removeUpload = function (upload) {
         var url = api.base + '/upload/' + upload.id;
         return api.call(url, 'DELETE', {}, {}, upload.uploadToken);
     };
 
+    // Update upload comments, expiration date or options
+    api.updateUpload = function (upload, update) {
+        var url = api.base + '/upload/' + upload.id;
+        return api.call(url, 'PATCH', {}, update, upload.uploadToken);
+    };
+
+    // Report an upload or a file to the administrators
+    api.createReport = function (report) {
+        var url = api.base + '/report';
+        return api.call(url, 'POST', {}, report);
+    };
+
     // Upload a file
     api.uploadFile = function (upload, file, progres_cb, basicAuth) {
         var mode = upload.stream ? "stream" : "file";
@@ -122,6 +165,30 @@ angular.module('api', ['ngFileUpload']).factory('$api', function ($http, $q, Upl
         return api.call(url, 'GET', {token: token, limit: limit, after: cursor});
     };
 
+    // Get uploads shared with the user
+    api.getSharedUploads = function (limit, cursor) {
+        var url = api.base + '/me/shared';
+        return api.call(url, 'GET', {limit: limit, after: cursor});
+    };
+
+    // Get user upload requests
+    api.getUploadRequests = function (limit, cursor) {
+        var url = api.base + '/me/requests';
+        return api.call(url, 'GET', {limit: limit, after: cursor});
+    };
+
+    // Create a new upload request
+    api.createUploadRequest = function (request) {
+        var url = api.base + '/me/requests';
+        return api.call(url, 'POST', {}, request);
+    };
+
+    // Delete an upload request
+    api.removeUploadRequest = function (request) {
+        var url = api.base + '/me/requests/' + request.id;
+        return api.call(url, 'DELETE');
+    };
+
     // Get user statistics
     api.getUserStats = function () {
         var url = api.base + '/me/stats';
@@ -146,6 +213,12 @@ angular.module('api', ['ngFileUpload']).factory('$api', function ($http, $q, Upl
         return api.call(url, 'POST', {}, {comment: comment});
     };
 
+    // Update user email notification preferences
+    api.updateNotifications = function (preferences) {
+        var url = api.base + '/me/notifications';
+        return api.call(url, 'POST', {}, preferences);
+    };
+
     // Revoke an upload token
     api.revokeToken = function (token) {
         var url = api.base + '/me/token/' + token;
@@ -89,6 +89,17 @@ plik.controller('PasswordController', ['$scope',
         };
     }]);
 
+// Abuse report dialog controller
+plik.controller('ReportController', ['$scope',
+    function ($scope) {
+        $scope.reasons = ["copyright", "malware", "phishing", "illegal", "spam", "other"];
+        $scope.report = {reason: "other", message: "", email: ""};
+
+        $scope.close = function () {
+            $scope.$close($scope.report);
+        };
+    }]);
+
 // QRCode dialog controller
 plik.controller('QRCodeController', ['$scope', 'args',
     function ($scope, args) {